@@ -194,4 +194,6 @@ export interface FeatureToggles {
   azureMonitorPrometheusExemplars?: boolean;
   pinNavItems?: boolean;
   authZGRPCServer?: boolean;
+  grafanaManagedRecordingRulesWriteBatching?: boolean;
+  grafanaManagedRecordingRulesWriteRetries?: boolean;
 }
@@ -9,7 +9,45 @@ export enum QueryEditorMode {
 
 export type PromQueryFormat = 'time_series' | 'table' | 'heatmap';
 
+export type PromQueryPriority = 'interactive' | 'background';
+
+export type PromNonFiniteValues = 'keep' | 'drop' | 'interpolate';
+
+export type PromHistogramBucketLayout = 'linear' | 'log';
+
+/**
+ * The values of the interval variables of a query. A variable set either as a duration or in milliseconds
+ * has both values resolved from it
+ */
+export interface PromIntervalContext {
+  /**
+   * The value of $__interval, like 30s
+   */
+  interval?: string;
+  /**
+   * The value of $__interval_ms
+   */
+  intervalMs?: number;
+  /**
+   * The value of $__rate_interval, like 2m0s
+   */
+  rateInterval?: string;
+  /**
+   * The value of $__rate_interval_ms
+   */
+  rateIntervalMs?: number;
+}
+
 export interface Prometheus extends common.DataQuery {
+  /**
+   * Additional query parameters sent to Prometheus with this query (e.g. engine=thanos).
+   * These are added to the custom query parameters configured on the data source
+   */
+  customQueryParameters?: Record<string, string>;
+  /**
+   * Thanos only: whether the series of replicas are deduplicated, Thanos deduplicates them by default
+   */
+  dedup?: boolean;
   /**
    * Specifies which editor is being used to prepare the query. It can be "code" or "builder"
    */
@@ -26,6 +64,20 @@ export interface Prometheus extends common.DataQuery {
    * Query format to determine how to display data points in panel. It can be "time_series", "table", "heatmap"
    */
   format?: PromQueryFormat;
+  /**
+   * Additional HTTP headers sent to Prometheus with this query (e.g. X-Scope-OrgID).
+   * Only the headers allowed by the data source can be set
+   */
+  headers?: Record<string, string>;
+  /**
+   * Native histograms only: the layout of the buckets set by histogramBuckets, linear by default
+   */
+  histogramBucketLayout?: PromHistogramBucketLayout;
+  /**
+   * Native histograms only: the number of buckets the exponential buckets of the data source are merged into,
+   * so the heatmap has the same buckets over the whole time range. Zero keeps the buckets of the data source
+   */
+  histogramBuckets?: number;
   /**
    * Returns only the latest value that Prometheus has scraped for the requested time series
    */
@@ -35,14 +87,108 @@ export interface Prometheus extends common.DataQuery {
    * See https://github.com/grafana/grafana/issues/48081
    */
   intervalFactor?: number;
+  /**
+   * The values of the interval variables resolved by the frontend for the query. When set, they replace the ones
+   * calculated by the backend in the expression, so alert rules and server side expressions interpolate
+   * $__interval and $__rate_interval like the panel the query comes from. The backend returns the values it used
+   * in the intervalContext of the custom metadata of the first frame
+   */
+  intervalContext?: PromIntervalContext;
   /**
    * Series name override or template. Ex. {{hostname}} will be replaced with label value for hostname
    */
   legendFormat?: string;
+  /**
+   * How far back (e.g. 15m) Prometheus looks for the latest sample of a series, overriding its
+   * lookback delta (5m by default). Widen it for sparsely scraped metrics
+   */
+  lookbackDelta?: string;
+  /**
+   * Thanos only: the maximum resolution of downsampled data used by the query. Ex. 5m, 1h or auto
+   */
+  maxSourceResolution?: string;
+  /**
+   * Bypass the result cache of the data source for this query. Mimir and Cortex are sent Cache-Control: no-store,
+   * so the results cache of their query frontend is neither read nor written either. Useful for live panels whose
+   * latest results change on every refresh
+   */
+  noCache?: boolean;
+  /**
+   * How the NaN and infinite samples of series are handled, kept by default. Some data sources send NaN
+   * samples, like the ones of divisions by zero, which break thresholds and alert conditions
+   */
+  nonFiniteValues?: PromNonFiniteValues;
+  /**
+   * Thanos only: whether a partial response is returned when some store APIs are unavailable
+   */
+  partialResponse?: boolean;
+  /**
+   * Whether users wait for the results of the query, interactive by default. Background queries wait for
+   * the interactive ones when the data source limits its concurrent queries, and the priority is sent in the
+   * header set by the data source, like the QoS header of a query frontend
+   */
+  priority?: PromQueryPriority;
   /**
    * Returns a Range vector, comprised of a set of time series containing a range of data points over time for each time series
    */
   range?: boolean;
+  /**
+   * Add the sample values as sent by the data source in a string field next to the value field, for values
+   * that cannot be represented by a float64 without rounding. Not supported by alerting
+   */
+  rawValues?: boolean;
+  /**
+   * Divides the number of points per series: 1 for full resolution, 2 for half, up to 10.
+   * It takes precedence over intervalFactor
+   */
+  resolution?: number;
+  /**
+   * Request query statistics (samples scanned and timings) from Prometheus and attach them to the result,
+   * with the time Grafana spent on each phase of the query (queue, connect, first byte, decode and conversion)
+   */
+  stats?: boolean;
+  /**
+   * A fixed step (e.g. 30s) used for the query instead of the calculated interval. Ranges with more points
+   * per series than Prometheus allows are queried in parts, the step is only raised when there are too many
+   */
+  step?: string;
+  /**
+   * Step of the subqueries without one (e.g. rate(up[1h:])), which otherwise use the evaluation
+   * interval of Prometheus. Either a duration (e.g. 1m) or $__interval for the step of the query
+   */
+  subqueryStep?: string;
+  /**
+   * Annotations only: comma separated labels whose values are the tags of the events
+   */
+  tagKeys?: string;
+  /**
+   * Mimir only: the tenants the query reads, sent as the X-Scope-OrgID header of this query instead of the
+   * tenant of the data source. Several tenants are federated into a single result. The tenants must be
+   * allowed by the data source, or X-Scope-OrgID when it does not allow a list of tenants
+   */
+  tenants?: string[];
+  /**
+   * Annotations only: text of the events. Ex. {{instance}} will be replaced with label value for instance
+   */
+  textFormat?: string;
+  /**
+   * Maximum duration of the query (e.g. 2m), overriding the timeout of the data source.
+   * It is bounded by the maximum query timeout configured on the data source
+   */
+  timeout?: string;
+  /**
+   * Annotations only: title of the events. Ex. {{instance}} will be replaced with label value for instance
+   */
+  titleFormat?: string;
+  /**
+   * Annotations only: use the values of the series, in milliseconds, as the time of the events
+   */
+  useValueForTime?: boolean;
+  /**
+   * Timezone of the dashboard the range is aligned to step boundaries in, either an offset
+   * from UTC (e.g. +02:00) or a timezone name (e.g. Europe/Berlin). It takes precedence over utcOffsetSec
+   */
+  utcOffset?: string;
   scopes?: Array<ScopeSpec & Pick<Scope['metadata'], 'name'>>;
   adhocFilters?: ScopeSpecFilter[];
   groupByKeys?: string[];
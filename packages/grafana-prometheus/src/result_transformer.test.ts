@@ -2,6 +2,7 @@
 import {
   cacheFieldDisplayNames,
   createDataFrame,
+  DataFrameType,
   FieldType,
   type DataQueryRequest,
   type DataQueryResponse,
@@ -809,6 +810,70 @@ describe('Prometheus Result Transformer', () => {
       expect(series.data[2].fields[3].values).toEqual([10, 0, 0]);
     });
 
+    describe('heatmap buckets', () => {
+      const options = {
+        targets: [
+          {
+            format: 'heatmap',
+            refId: 'A',
+          },
+        ],
+      } as unknown as DataQueryRequest<PromQuery>;
+      const bucket = (le: string, values: number[], times = [4, 5, 6]) =>
+        createDataFrame({
+          refId: 'A',
+          fields: [
+            { name: 'Time', type: FieldType.time, values: times },
+            { name: 'Value', type: FieldType.number, values, labels: { le } },
+          ],
+        });
+
+      it('are de-accumulated without a +Inf bucket', () => {
+        const response = {
+          state: 'Done',
+          data: [bucket('2', [20, 10, 30]), bucket('1', [10, 10, 0])],
+        } as unknown as DataQueryResponse;
+
+        const series = transformV2(response, options, {});
+        expect(series.data[0].fields.map((f) => f.name)).toEqual(['Time', '1', '2']);
+        expect(series.data[0].fields[1].values).toEqual([10, 10, 0]);
+        expect(series.data[0].fields[2].values).toEqual([10, 0, 30]);
+      });
+
+      it('are kept when they are not cumulative', () => {
+        const response = {
+          state: 'Done',
+          data: [bucket('1', [10, 10, 0]), bucket('2', [5, 10, 30]), bucket('+Inf', [1, 0, 2])],
+        } as unknown as DataQueryResponse;
+
+        const series = transformV2(response, options, {});
+        expect(series.data[0].fields[1].values).toEqual([10, 10, 0]);
+        expect(series.data[0].fields[2].values).toEqual([5, 10, 30]);
+        expect(series.data[0].fields[3].values).toEqual([1, 0, 2]);
+      });
+
+      it('with the same bound are merged', () => {
+        const response = {
+          state: 'Done',
+          data: [
+            bucket('1.0', [10, 10], [4, 5]),
+            bucket('1', [20], [6]),
+            bucket('+Inf', [20, 20, 30]),
+            bucket('2.50', [15, 20], [4, 5]),
+            bucket('2.5', [25], [6]),
+          ],
+        } as unknown as DataQueryResponse;
+
+        const series = transformV2(response, options, {});
+        expect(series.data).toHaveLength(1);
+        expect(series.data[0].fields.map((f) => f.name)).toEqual(['Time', '1', '2.5', '+Inf']);
+        expect(series.data[0].fields[0].values).toEqual([4, 5, 6]);
+        expect(series.data[0].fields[1].values).toEqual([10, 10, 20]);
+        expect(series.data[0].fields[2].values).toEqual([5, 10, 5]);
+        expect(series.data[0].fields[3].values).toEqual([5, 0, 5]);
+      });
+    });
+
     it('Retains exemplar frames when data returned is a heatmap', () => {
       const options = {
         targets: [
@@ -1067,6 +1132,32 @@ describe('Prometheus Result Transformer', () => {
       expect(tableDf.fields[3].name).toBe('Value');
     });
 
+    it('keeps the tables converted by the backend', () => {
+      const df = createDataFrame({
+        refId: 'A',
+        meta: { type: DataFrameType.TimeSeriesLong, preferredVisualisationType: 'table' },
+        fields: [
+          { name: 'Time', type: FieldType.time, values: [6, 5] },
+          { name: 'job', type: FieldType.string, values: ['api', 'db'] },
+          { name: 'le', type: FieldType.number, values: [0.5, Infinity] },
+          { name: 'Value', type: FieldType.number, values: [3, 4] },
+        ],
+      });
+      const other = createDataFrame({
+        refId: 'B',
+        fields: [
+          { name: 'time', type: FieldType.time, values: [6] },
+          { name: 'value', type: FieldType.number, values: [1], labels: { job: 'api' } },
+        ],
+      });
+
+      const [tableDf] = transformDFToTable([df, other]);
+      expect(tableDf.fields.map((f) => f.name)).toEqual(['Time', 'job', 'le', 'Value #A']);
+      expect(tableDf.fields[2].values).toEqual([0.5, Infinity]);
+      expect(tableDf.length).toBe(2);
+      expect(tableDf.meta?.preferredVisualisationType).toBe('rawPrometheus');
+    });
+
     // Queries do not always return results
     it('transforms dataFrame and empty dataFrame mock responses to table dataFrames', () => {
       const value1 = 'value1';
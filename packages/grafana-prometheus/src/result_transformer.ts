@@ -146,10 +146,12 @@ export function transformV2(
 
     // Then iterate through the resultant object
     forOwn(heatmapResultsGroupedByValues, (dataFrames, key) => {
-      // Sort frames within each grouping
-      const sortedHeatmap = dataFrames.sort(sortSeriesByLabel);
+      // Sort frames within each grouping, with the buckets of the same bound (le="1.0" and le="1") merged
+      const sortedHeatmap = mergeEquivalentBuckets(dataFrames.sort(sortSeriesByLabel));
+      // Buckets that are not cumulative already count the observations of their own range
+      const buckets = isCumulativeBuckets(sortedHeatmap) ? transformToHistogramOverTime(sortedHeatmap) : sortedHeatmap;
       // And push the sorted grouping with the rest
-      processedHeatmapResultsGroupedByQuery.push(mergeHeatmapFrames(transformToHistogramOverTime(sortedHeatmap)));
+      processedHeatmapResultsGroupedByQuery.push(mergeHeatmapFrames(buckets));
     });
   }
 
@@ -186,8 +188,26 @@ export function transformDFToTable(dfs: DataFrame[]): DataFrame[] {
   const refIds = Object.keys(dataFramesByRefId);
 
   const frames = refIds.map((refId) => {
-    // Create timeField, valueField and labelFields
     const valueText = getValueText(refIds.length, refId);
+
+    // Queries with the table format are converted by the backend, only their value field is renamed
+    const backendTable = dataFramesByRefId[refId].find(isBackendTable);
+    if (backendTable) {
+      return {
+        ...backendTable,
+        fields: backendTable.fields.map((field) =>
+          field.name === TIME_SERIES_VALUE_FIELD_NAME && field.type === FieldType.number
+            ? { ...field, name: valueText }
+            : field
+        ),
+        meta: {
+          ...backendTable.meta,
+          preferredVisualisationType: 'rawPrometheus' as const,
+        },
+      };
+    }
+
+    // Create timeField, valueField and labelFields
     const valueField = getValueField({ data: [], valueName: valueText });
     const timeField = getTimeField([]);
     const labelFields: Field[] = [];
@@ -240,6 +260,10 @@ export function transformDFToTable(dfs: DataFrame[]): DataFrame[] {
   return frames;
 }
 
+function isBackendTable(df: DataFrame): boolean {
+  return df.meta?.type === DataFrameType.TimeSeriesLong && df.meta?.preferredVisualisationType === 'table';
+}
+
 function getValueText(responseLength: number, refId = '') {
   return responseLength > 1 ? `Value #${refId}` : 'Value';
 }
@@ -360,6 +384,107 @@ function mergeHeatmapFrames(frames: DataFrame[]): DataFrame[] {
   ];
 }
 
+/**
+ * Merges the adjacent buckets of a sorted histogram whose le labels are the same bound written differently,
+ * like le="1.0" and le="1", which happens when the client library exposing them changes. Their counts are
+ * summed by timestamp and the merged bucket is named after the bound, so the heatmap has a single row for it.
+ * @internal
+ */
+export function mergeEquivalentBuckets(seriesList: DataFrame[]): DataFrame[] {
+  const merged: DataFrame[] = [];
+
+  for (const frame of seriesList) {
+    const bound = bucketBound(frame);
+    const previous = merged[merged.length - 1];
+    if (bound !== undefined && previous && bucketBound(previous) === bound) {
+      merged[merged.length - 1] = sumBuckets(previous, frame, bound);
+    } else {
+      merged.push(frame);
+    }
+  }
+
+  return merged;
+}
+
+// bucketBound returns the upper bound of the bucket of a frame, from its le label
+function bucketBound(frame: DataFrame): number | undefined {
+  const le = frame.fields.find((field) => field.type === FieldType.number)?.labels?.[HISTOGRAM_QUANTILE_LABEL_NAME];
+  if (le === undefined) {
+    return undefined;
+  }
+  const bound = parseSampleValue(le);
+  return isNaN(bound) ? undefined : bound;
+}
+
+function sumBuckets(first: DataFrame, second: DataFrame, bound: number): DataFrame {
+  const counts = new Map<number, number>();
+  for (const frame of [first, second]) {
+    const timeField = frame.fields.find((field) => field.type === FieldType.time)!;
+    const valueField = frame.fields.find((field) => field.type === FieldType.number)!;
+    timeField.values.forEach((time: number, i: number) => {
+      const value = valueField.values[i];
+      if (value === null || value === undefined || isNaN(value)) {
+        return;
+      }
+      counts.set(time, (counts.get(time) ?? 0) + value);
+    });
+  }
+
+  // The times of the first bucket are kept as they are, unless the second one has other times
+  const firstTimes: number[] = first.fields.find((field) => field.type === FieldType.time)!.values;
+  const hasOtherTimes = [...counts.keys()].some((time) => !firstTimes.includes(time));
+  const times = hasOtherTimes ? [...new Set([...firstTimes, ...counts.keys()])].sort((a, b) => a - b) : firstTimes;
+
+  const le = bound === Number.POSITIVE_INFINITY ? '+Inf' : String(bound);
+  const firstLe = first.fields.find((field) => field.type === FieldType.number)!.labels?.[HISTOGRAM_QUANTILE_LABEL_NAME];
+  return {
+    ...first,
+    // Frames are named after their bucket when the legend is not set
+    name: first.name === firstLe ? le : first.name,
+    length: times.length,
+    fields: first.fields.map((field) => {
+      if (field.type === FieldType.time) {
+        return { ...field, values: times };
+      }
+      if (field.type === FieldType.number) {
+        return {
+          ...field,
+          labels: { ...field.labels, [HISTOGRAM_QUANTILE_LABEL_NAME]: le },
+          config: { ...field.config, displayNameFromDS: le },
+          values: times.map((time) => counts.get(time) ?? null),
+        };
+      }
+      return field;
+    }),
+  };
+}
+
+/**
+ * Returns whether the counts of sorted buckets are cumulative, as the ones of Prometheus histograms are: each bucket
+ * counts the observations of the ones below it too. Buckets without a +Inf one are still cumulative. A bucket counting
+ * less than the one below it at any time means the buckets already count their own range, like the ones of queries
+ * computing them, and de-accumulating them would clamp their counts to zero.
+ * @internal
+ */
+export function isCumulativeBuckets(seriesList: DataFrame[]): boolean {
+  for (let i = seriesList.length - 1; i > 0; i--) {
+    const topSeries = seriesList[i].fields.find((s) => s.type === FieldType.number);
+    const bottomSeries = seriesList[i - 1].fields.find((s) => s.type === FieldType.number);
+    if (!topSeries || !bottomSeries) {
+      // The transform refuses them
+      return true;
+    }
+
+    for (let j = 0; j < topSeries.values.length; j++) {
+      if (topSeries.values[j] - (bottomSeries.values[j] || 0) < -1e-9) {
+        return false;
+      }
+    }
+  }
+
+  return true;
+}
+
 /** @internal */
 export function transformToHistogramOverTime(seriesList: DataFrame[]): DataFrame[] {
   /*      t1 = timestamp1, t2 = timestamp2 etc.
@@ -167,6 +167,11 @@ var adminCommands = []*cli.Command{
 			},
 		},
 	},
+	{
+		Name:   "secrets-encrypt",
+		Usage:  "Encrypts the value read from stdin with the secrets service, to be used as $__encrypted{...} in the settings that support it",
+		Action: runRunnerCommand(secretsEncryptCommand),
+	},
 	{
 		Name:  "user-manager",
 		Usage: "Runs different helpful user commands",
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/server"
+	"github.com/grafana/grafana/pkg/services/secrets"
+)
+
+// secretsEncryptCommand encrypts the value read from stdin with the secrets service, so it can be
+// written as $__encrypted{...} in the settings that support it
+func secretsEncryptCommand(_ utils.CommandLine, runner server.Runner) error {
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("can't read value from stdin: %w", err)
+	}
+	value = []byte(strings.TrimRight(string(value), "\r\n"))
+	if len(value) == 0 {
+		return fmt.Errorf("can't encrypt an empty value")
+	}
+
+	encrypted, err := runner.SecretsService.Encrypt(context.Background(), value, secrets.WithoutScope())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt value: %w", err)
+	}
+	fmt.Printf("$__encrypted{%s}\n", base64.StdEncoding.EncodeToString(encrypted))
+	return nil
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// APIResponse is the envelope of every Prometheus HTTP API response
+type APIResponse[T any] struct {
+	Status    string   `json:"status"`
+	Data      T        `json:"data"`
+	ErrorType string   `json:"errorType"`
+	Error     string   `json:"error"`
+	Warnings  []string `json:"warnings"`
+}
+
+// DecodeAPIResponse reads the body of an API response and closes it.
+// An error is returned when Prometheus reports one, or when the response is not successful.
+func DecodeAPIResponse[T any](res *http.Response, logger log.Logger) (*APIResponse[T], error) {
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			logger.Error("Failed to close response body", "err", err)
+		}
+	}()
+
+	rsp := &APIResponse[T]{}
+	if err := json.NewDecoder(res.Body).Decode(rsp); err != nil {
+		return nil, fmt.Errorf("unexpected response with status %s: %w", res.Status, err)
+	}
+	if rsp.Status == "error" {
+		return nil, fmt.Errorf("%s: %s", rsp.ErrorType, rsp.Error)
+	}
+	if res.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("unexpected response with status %s", res.Status)
+	}
+	return rsp, nil
+}
+
+// WithCustomParameters adds the custom query parameters params to qv.
+// Parameters already set by the client, like query or step, are never overridden.
+func WithCustomParameters(qv map[string]string, params map[string]string) map[string]string {
+	for key, val := range params {
+		if _, exists := qv[key]; exists {
+			continue
+		}
+		qv[key] = val
+	}
+	return qv
+}
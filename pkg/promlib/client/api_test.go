@@ -0,0 +1,36 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeAPIResponse(t *testing.T) {
+	response := func(status int, body string) *http.Response {
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	rsp, err := DecodeAPIResponse[[]string](response(http.StatusOK, `{"status":"success","data":["job"],"warnings":["partial"]}`), log.New())
+	require.NoError(t, err)
+	require.Equal(t, []string{"job"}, rsp.Data)
+	require.Equal(t, []string{"partial"}, rsp.Warnings)
+
+	_, err = DecodeAPIResponse[[]string](response(http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"invalid parameter"}`), log.New())
+	require.EqualError(t, err, "bad_data: invalid parameter")
+
+	_, err = DecodeAPIResponse[[]string](response(http.StatusBadGateway, `{"status":"success","data":[]}`), log.New())
+	require.ErrorContains(t, err, "unexpected response with status")
+
+	_, err = DecodeAPIResponse[[]string](response(http.StatusOK, `<html>`), log.New())
+	require.Error(t, err)
+}
+
+func TestWithCustomParameters(t *testing.T) {
+	qv := WithCustomParameters(map[string]string{"query": "up"}, map[string]string{"query": "down", "dedup": "false"})
+	require.Equal(t, map[string]string{"query": "up", "dedup": "false"}, qv)
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -11,11 +12,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/golang/snappy"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/prometheus/prompb"
 
+	"github.com/grafana/grafana/pkg/promlib/middleware"
 	"github.com/grafana/grafana/pkg/promlib/models"
 )
 
+// DefaultMaxGetURLLength is the length of the URL of GET queries above which they are sent with POST.
+// Many proxies and load balancers reject or truncate URLs longer than 8KiB.
+const DefaultMaxGetURLLength = 8192
+
 type doer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
@@ -27,10 +35,35 @@ type Client struct {
 	doer    doer
 	method  string
 	baseUrl string
+
+	// GET queries with a longer URL are sent with POST, zero to never switch
+	maxGetURLLength int
+
+	// The methods of the endpoints sent with another method than the one of the client, see ParseEndpointMethods
+	endpointMethods map[string]string
 }
 
 func NewClient(d doer, method, baseUrl string) *Client {
-	return &Client{doer: d, method: method, baseUrl: baseUrl}
+	return &Client{doer: d, method: method, baseUrl: baseUrl, maxGetURLLength: DefaultMaxGetURLLength}
+}
+
+// SetMaxGetURLLength sets the length of the URL of GET queries above which they are sent with POST, zero to never switch.
+func (c *Client) SetMaxGetURLLength(n int) {
+	c.maxGetURLLength = n
+}
+
+// SetEndpointMethods sets the methods of the endpoints sent with another method than the one of the
+// client, by endpoint like api/v1/series. The method of the resource calls to them is replaced too.
+func (c *Client) SetEndpointMethods(methods map[string]string) {
+	c.endpointMethods = methods
+}
+
+// methodOf returns the method requests to endpoint are sent with
+func (c *Client) methodOf(endpoint string) string {
+	if method, ok := c.endpointMethods[strings.TrimPrefix(endpoint, "/")]; ok {
+		return method
+	}
+	return c.method
 }
 
 func (c *Client) QueryRange(ctx context.Context, q *models.Query) (*http.Response, error) {
@@ -41,13 +74,14 @@ func (c *Client) QueryRange(ctx context.Context, q *models.Query) (*http.Respons
 		"end":   formatTime(tr.End),
 		"step":  strconv.FormatFloat(tr.Step.Seconds(), 'f', -1, 64),
 	}
+	withLookbackDelta(qv, q)
 
-	req, err := c.createQueryRequest(ctx, "api/v1/query_range", qv)
+	req, err := c.createQueryRequest(ctx, "api/v1/query_range", withQueryParameters(qv, q))
 	if err != nil {
 		return nil, err
 	}
 
-	return c.doer.Do(req)
+	return c.doer.Do(withQueryHeaders(req, q))
 }
 
 func (c *Client) QueryInstant(ctx context.Context, q *models.Query) (*http.Response, error) {
@@ -58,23 +92,40 @@ func (c *Client) QueryInstant(ctx context.Context, q *models.Query) (*http.Respo
 	// Instead of aligning we use time point directly.
 	// https://prometheus.io/docs/prometheus/latest/querying/api/#instant-queries
 	qv := map[string]string{"query": q.Expr, "time": formatTime(q.End)}
-	req, err := c.createQueryRequest(ctx, "api/v1/query", qv)
+	withLookbackDelta(qv, q)
+	req, err := c.createQueryRequest(ctx, "api/v1/query", withQueryParameters(qv, q))
 	if err != nil {
 		return nil, err
 	}
 
-	return c.doer.Do(req)
+	return c.doer.Do(withQueryHeaders(req, q))
 }
 
 func (c *Client) QueryExemplars(ctx context.Context, q *models.Query) (*http.Response, error) {
-	tr := q.TimeRange()
+	tr := q.QueriedTimeRange()
 	qv := map[string]string{
 		"query": q.Expr,
 		"start": formatTime(tr.Start),
 		"end":   formatTime(tr.End),
 	}
 
-	req, err := c.createQueryRequest(ctx, "api/v1/query_exemplars", qv)
+	req, err := c.createQueryRequest(ctx, "api/v1/query_exemplars", withQueryParameters(qv, q))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.doer.Do(withQueryHeaders(req, q))
+}
+
+// QueryAPI calls an endpoint of the Prometheus HTTP API that does not evaluate PromQL, like api/v1/metadata.
+// These endpoints only support GET, so the configured method is ignored.
+func (c *Client) QueryAPI(ctx context.Context, endpoint string, qv map[string]string) (*http.Response, error) {
+	u, err := c.createUrl(endpoint, qv)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := createRequest(ctx, http.MethodGet, u, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +133,46 @@ func (c *Client) QueryExemplars(ctx context.Context, q *models.Query) (*http.Res
 	return c.doer.Do(req)
 }
 
+// RemoteRead reads the raw samples of the series matched by matchers in the query range with the remote read protocol.
+// The server answers with the first of the accepted response types it supports.
+func (c *Client) RemoteRead(ctx context.Context, q *models.Query, matchers []*prompb.LabelMatcher, responseTypes []prompb.ReadRequest_ResponseType) (*http.Response, error) {
+	tr := q.TimeRange()
+	// The samples before the start are read too, the first steps are evaluated from them
+	start := tr.Start.Add(-q.Lookback())
+	rr := &prompb.ReadRequest{
+		Queries: []*prompb.Query{{
+			StartTimestampMs: start.UnixMilli(),
+			EndTimestampMs:   tr.End.UnixMilli(),
+			Matchers:         matchers,
+			Hints: &prompb.ReadHints{
+				StepMs:  tr.Step.Milliseconds(),
+				StartMs: start.UnixMilli(),
+				EndMs:   tr.End.UnixMilli(),
+			},
+		}},
+		AcceptedResponseTypes: responseTypes,
+	}
+	b, err := rr.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := c.createUrl("api/v1/read", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(snappy.Encode(nil, b)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+
+	return c.doer.Do(withQueryHeaders(req, q))
+}
+
 func (c *Client) QueryResource(ctx context.Context, req *backend.CallResourceRequest) (*http.Response, error) {
 	// The way URL is represented in CallResourceRequest and what we need for the fetch function is different
 	// so here we have to do a bit of parsing, so we can then compose it with the base url in correct way.
@@ -97,7 +188,14 @@ func (c *Client) QueryResource(ctx context.Context, req *backend.CallResourceReq
 
 	// We use method from the request, as for resources front end may do a fallback to GET if POST does not work
 	// nad we want to respect that.
-	httpRequest, err := createRequest(ctx, req.Method, u, bytes.NewReader(req.Body))
+	method, body := req.Method, req.Body
+	if m, ok := c.endpointMethods[strings.TrimPrefix(req.Path, "/")]; ok && m != strings.ToUpper(method) {
+		if method, body, err = c.switchMethod(m, u, body); err != nil {
+			return nil, err
+		}
+	}
+
+	httpRequest, err := createRequest(ctx, method, u, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -105,19 +203,77 @@ func (c *Client) QueryResource(ctx context.Context, req *backend.CallResourceReq
 	return c.doer.Do(httpRequest)
 }
 
-func (c *Client) createQueryRequest(ctx context.Context, endpoint string, qv map[string]string) (*http.Request, error) {
-	if strings.ToUpper(c.method) == http.MethodPost {
-		u, err := c.createUrl(endpoint, nil)
+// switchMethod moves the parameters of a resource call between the form body and the URL u, so it is
+// sent with method. Calls whose URL would be too long for GET stay POST.
+func (c *Client) switchMethod(method string, u *url.URL, body []byte) (string, []byte, error) {
+	switch method {
+	case http.MethodGet:
+		form, err := url.ParseQuery(string(body))
 		if err != nil {
-			return nil, err
+			return "", nil, fmt.Errorf("error reading the form of the request: %w", err)
 		}
-
-		v := make(url.Values)
-		for key, val := range qv {
-			v.Set(key, val)
+		qv := u.Query()
+		for key, values := range form {
+			qv[key] = append(qv[key], values...)
+		}
+		rawQuery := qv.Encode()
+		if c.maxGetURLLength > 0 && len(u.String())-len(u.RawQuery)+len(rawQuery) > c.maxGetURLLength {
+			return http.MethodPost, body, nil
 		}
+		u.RawQuery = rawQuery
+		return http.MethodGet, nil, nil
+	default:
+		body = []byte(u.RawQuery)
+		u.RawQuery = ""
+		return http.MethodPost, body, nil
+	}
+}
+
+// withQueryParameters adds the optional parameters of the query to qv.
+func withQueryParameters(qv map[string]string, q *models.Query) map[string]string {
+	if q.PartialResponse != nil {
+		qv["partial_response"] = strconv.FormatBool(*q.PartialResponse)
+	}
+	if q.MaxSourceResolution != "" {
+		qv["max_source_resolution"] = q.MaxSourceResolution
+	}
+	if q.Dedup != nil {
+		qv["dedup"] = strconv.FormatBool(*q.Dedup)
+	}
+	if q.Stats {
+		qv["stats"] = "all"
+	}
+	if q.Timeout > 0 {
+		// Prometheus stops evaluating the query once it times out on our side
+		qv["timeout"] = strconv.FormatFloat(q.Timeout.Seconds(), 'f', -1, 64)
+	}
+	return WithCustomParameters(qv, q.CustomQueryParameters)
+}
 
-		return createRequest(ctx, c.method, u, strings.NewReader(v.Encode()))
+// withLookbackDelta adds the lookback delta of the query to qv, only query evaluation uses it.
+func withLookbackDelta(qv map[string]string, q *models.Query) {
+	if q.LookbackDelta > 0 {
+		qv["lookback_delta"] = strconv.FormatFloat(q.LookbackDelta.Seconds(), 'f', -1, 64)
+	}
+}
+
+// withQueryHeaders sets the headers of the query on req, and its priority on its context
+func withQueryHeaders(req *http.Request, q *models.Query) *http.Request {
+	priority := q.Priority
+	if priority == "" {
+		priority = models.QueryPriorityInteractive
+	}
+	req = req.WithContext(middleware.WithPriority(req.Context(), priority))
+	for key, val := range q.Headers {
+		req.Header.Set(key, val)
+	}
+	return req
+}
+
+func (c *Client) createQueryRequest(ctx context.Context, endpoint string, qv map[string]string) (*http.Request, error) {
+	method := c.methodOf(endpoint)
+	if strings.ToUpper(method) == http.MethodPost {
+		return c.createPostQueryRequest(ctx, endpoint, qv)
 	}
 
 	u, err := c.createUrl(endpoint, qv)
@@ -125,7 +281,26 @@ func (c *Client) createQueryRequest(ctx context.Context, endpoint string, qv map
 		return nil, err
 	}
 
-	return createRequest(ctx, c.method, u, http.NoBody)
+	// Query endpoints accept POST too, which does not fail on long URLs
+	if c.maxGetURLLength > 0 && len(u.String()) > c.maxGetURLLength {
+		return c.createPostQueryRequest(ctx, endpoint, qv)
+	}
+
+	return createRequest(ctx, method, u, http.NoBody)
+}
+
+func (c *Client) createPostQueryRequest(ctx context.Context, endpoint string, qv map[string]string) (*http.Request, error) {
+	u, err := c.createUrl(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	v := make(url.Values)
+	for key, val := range qv {
+		v.Set(key, val)
+	}
+
+	return createRequest(ctx, http.MethodPost, u, strings.NewReader(v.Encode()))
 }
 
 func (c *Client) createUrl(endpoint string, qs map[string]string) (*url.URL, error) {
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/grafana/pkg/promlib/middleware"
 	"github.com/grafana/grafana/pkg/promlib/models"
 )
 
@@ -82,9 +85,88 @@ func TestClient(t *testing.T) {
 		})
 	})
 
+	t.Run("QueryResource with the method of the endpoint", func(t *testing.T) {
+		doer := &MockDoer{}
+		client := NewClient(doer, http.MethodPost, "http://localhost:9090")
+		client.SetEndpointMethods(map[string]string{"api/v1/series": http.MethodGet, "api/v1/labels": http.MethodPost})
+
+		t.Run("moves the form of a POST request to the URL", func(t *testing.T) {
+			_, err := client.QueryResource(context.Background(), &backend.CallResourceRequest{
+				Path:   "/api/v1/series",
+				Method: http.MethodPost,
+				URL:    "/api/v1/series?limit=10",
+				Body:   []byte("match%5B%5D=ALERTS&start=1655271408"),
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.MethodGet, doer.Req.Method)
+			require.Equal(t, "http://localhost:9090/api/v1/series?limit=10&match%5B%5D=ALERTS&start=1655271408", doer.Req.URL.String())
+			body, err := io.ReadAll(doer.Req.Body)
+			require.NoError(t, err)
+			require.Empty(t, body)
+		})
+
+		t.Run("keeps POST when the URL would be too long", func(t *testing.T) {
+			client.SetMaxGetURLLength(64)
+			t.Cleanup(func() { client.SetMaxGetURLLength(DefaultMaxGetURLLength) })
+			body := "match%5B%5D=" + strings.Repeat("a", 64)
+			_, err := client.QueryResource(context.Background(), &backend.CallResourceRequest{
+				Path:   "/api/v1/series",
+				Method: http.MethodPost,
+				URL:    "/api/v1/series",
+				Body:   []byte(body),
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.MethodPost, doer.Req.Method)
+		})
+
+		t.Run("moves the parameters of a GET request to the form", func(t *testing.T) {
+			_, err := client.QueryResource(context.Background(), &backend.CallResourceRequest{
+				Path:   "api/v1/labels",
+				Method: http.MethodGet,
+				URL:    "api/v1/labels?start=1655272558&end=1655294158",
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.MethodPost, doer.Req.Method)
+			require.Equal(t, "application/x-www-form-urlencoded", doer.Req.Header.Get("Content-Type"))
+			require.Equal(t, "http://localhost:9090/api/v1/labels", doer.Req.URL.String())
+			body, err := io.ReadAll(doer.Req.Body)
+			require.NoError(t, err)
+			require.Equal(t, "start=1655272558&end=1655294158", string(body))
+		})
+
+		t.Run("keeps the method of other endpoints", func(t *testing.T) {
+			_, err := client.QueryResource(context.Background(), &backend.CallResourceRequest{
+				Path:   "api/v1/label/job/values",
+				Method: http.MethodGet,
+				URL:    "api/v1/label/job/values",
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.MethodGet, doer.Req.Method)
+		})
+	})
+
 	t.Run("QueryRange", func(t *testing.T) {
 		doer := &MockDoer{}
 
+		t.Run("sends the query with the method of the endpoint", func(t *testing.T) {
+			client := NewClient(doer, http.MethodPost, "http://localhost:9090")
+			client.SetEndpointMethods(map[string]string{"api/v1/query_range": http.MethodGet})
+			_, err := client.QueryRange(context.Background(), &models.Query{
+				Expr:       "up",
+				Start:      time.Unix(0, 0),
+				End:        time.Unix(1234, 0),
+				RangeQuery: true,
+				Step:       1 * time.Second,
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.MethodGet, doer.Req.Method)
+			require.Equal(t, "http://localhost:9090/api/v1/query_range?end=1234&query=up&start=0&step=1", doer.Req.URL.String())
+
+			_, err = client.QueryInstant(context.Background(), &models.Query{Expr: "up", End: time.Unix(1234, 0)})
+			require.NoError(t, err)
+			require.Equal(t, http.MethodPost, doer.Req.Method)
+		})
+
 		t.Run("sends correct POST query", func(t *testing.T) {
 			client := NewClient(doer, http.MethodPost, "http://localhost:9090")
 			req := &models.Query{
@@ -137,5 +219,168 @@ func TestClient(t *testing.T) {
 			require.Equal(t, []byte{}, body)
 			require.Equal(t, "http://localhost:9090/api/v1/query_range?end=1234&query=rate%28ALERTS%7Bjob%3D%22test%22+%5B%24__rate_interval%5D%7D%29&start=0&step=1", doer.Req.URL.String())
 		})
+
+		t.Run("sends custom query parameters without overriding the query", func(t *testing.T) {
+			client := NewClient(doer, http.MethodGet, "http://localhost:9090")
+			req := &models.Query{
+				Expr:       "up",
+				Start:      time.Unix(0, 0),
+				End:        time.Unix(1234, 0),
+				RangeQuery: true,
+				Step:       1 * time.Second,
+				CustomQueryParameters: map[string]string{
+					"dedup": "false",
+					"query": "down",
+				},
+			}
+			res, err := client.QueryRange(context.Background(), req)
+			defer func() {
+				if res != nil && res.Body != nil {
+					if err := res.Body.Close(); err != nil {
+						fmt.Println("Error", "err", err)
+					}
+				}
+			}()
+			require.NoError(t, err)
+			require.NotNil(t, doer.Req)
+			require.Equal(t, "http://localhost:9090/api/v1/query_range?dedup=false&end=1234&query=up&start=0&step=1", doer.Req.URL.String())
+		})
+
+		t.Run("sends Thanos query options", func(t *testing.T) {
+			client := NewClient(doer, http.MethodGet, "http://localhost:9090")
+			partialResponse, dedup := false, true
+			req := &models.Query{
+				Expr:                "up",
+				Start:               time.Unix(0, 0),
+				End:                 time.Unix(1234, 0),
+				RangeQuery:          true,
+				Step:                1 * time.Second,
+				PartialResponse:     &partialResponse,
+				MaxSourceResolution: "5m",
+				Dedup:               &dedup,
+			}
+			res, err := client.QueryRange(context.Background(), req)
+			defer func() {
+				if res != nil && res.Body != nil {
+					if err := res.Body.Close(); err != nil {
+						fmt.Println("Error", "err", err)
+					}
+				}
+			}()
+			require.NoError(t, err)
+			require.NotNil(t, doer.Req)
+			require.Equal(t, "http://localhost:9090/api/v1/query_range?dedup=true&end=1234&max_source_resolution=5m&partial_response=false&query=up&start=0&step=1", doer.Req.URL.String())
+		})
+
+		t.Run("sends the lookback delta of the query", func(t *testing.T) {
+			client := NewClient(doer, http.MethodGet, "http://localhost:9090")
+			req := &models.Query{
+				Expr:          "up",
+				Start:         time.Unix(0, 0),
+				End:           time.Unix(1234, 0),
+				RangeQuery:    true,
+				Step:          1 * time.Second,
+				LookbackDelta: 15 * time.Minute,
+			}
+			res, err := client.QueryRange(context.Background(), req)
+			defer func() {
+				if res != nil && res.Body != nil {
+					if err := res.Body.Close(); err != nil {
+						fmt.Println("Error", "err", err)
+					}
+				}
+			}()
+			require.NoError(t, err)
+			require.NotNil(t, doer.Req)
+			require.Equal(t, "http://localhost:9090/api/v1/query_range?end=1234&lookback_delta=900&query=up&start=0&step=1", doer.Req.URL.String())
+		})
+
+		t.Run("sends long GET queries with POST", func(t *testing.T) {
+			client := NewClient(doer, http.MethodGet, "http://localhost:9090")
+			client.SetMaxGetURLLength(100)
+			req := &models.Query{
+				Expr:       `sum(rate(http_requests_total{job="api", handler=~"/api/v1/(query|query_range|series)"}[5m])) by (handler)`,
+				Start:      time.Unix(0, 0),
+				End:        time.Unix(1234, 0),
+				RangeQuery: true,
+				Step:       1 * time.Second,
+			}
+			res, err := client.QueryRange(context.Background(), req)
+			defer func() {
+				if res != nil && res.Body != nil {
+					if err := res.Body.Close(); err != nil {
+						fmt.Println("Error", "err", err)
+					}
+				}
+			}()
+			require.NoError(t, err)
+			require.NotNil(t, doer.Req)
+			require.Equal(t, http.MethodPost, doer.Req.Method)
+			require.Equal(t, "http://localhost:9090/api/v1/query_range", doer.Req.URL.String())
+			require.Equal(t, "application/x-www-form-urlencoded", doer.Req.Header.Get("Content-Type"))
+			body, err := io.ReadAll(doer.Req.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), "query=sum%28rate")
+		})
+
+		t.Run("sends the headers of the query", func(t *testing.T) {
+			client := NewClient(doer, http.MethodPost, "http://localhost:9090")
+			req := &models.Query{
+				Expr:       "up",
+				Start:      time.Unix(0, 0),
+				End:        time.Unix(1234, 0),
+				RangeQuery: true,
+				Step:       1 * time.Second,
+				Headers:    map[string]string{"X-Scope-OrgID": "tenant-a"},
+			}
+			res, err := client.QueryRange(context.Background(), req)
+			defer func() {
+				if res != nil && res.Body != nil {
+					if err := res.Body.Close(); err != nil {
+						fmt.Println("Error", "err", err)
+					}
+				}
+			}()
+			require.NoError(t, err)
+			require.NotNil(t, doer.Req)
+			require.Equal(t, "tenant-a", doer.Req.Header.Get("X-Scope-OrgID"))
+			require.Equal(t, "application/x-www-form-urlencoded", doer.Req.Header.Get("Content-Type"))
+			require.Empty(t, doer.Req.Header.Get("Cache-Control"))
+		})
+
+		t.Run("sets the priority of the query", func(t *testing.T) {
+			var received []string
+			rt := middleware.PriorityHeader("X-Query-Priority").CreateMiddleware(sdkhttpclient.Options{}, sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				received = append(received, req.Header.Get("X-Query-Priority"))
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			}))
+			client := NewClient(&http.Client{Transport: rt}, http.MethodPost, "http://localhost:9090")
+			for _, priority := range []models.QueryPriority{"", models.QueryPriorityBackground} {
+				res, err := client.QueryInstant(context.Background(), &models.Query{Expr: "up", End: time.Unix(1234, 0), InstantQuery: true, Priority: priority})
+				require.NoError(t, err)
+				require.NoError(t, res.Body.Close())
+			}
+			require.Equal(t, []string{"interactive", "background"}, received)
+		})
+	})
+
+	t.Run("QueryAPI", func(t *testing.T) {
+		doer := &MockDoer{}
+
+		t.Run("sends a GET request even if POST is configured", func(t *testing.T) {
+			client := NewClient(doer, http.MethodPost, "http://localhost:9090")
+			res, err := client.QueryAPI(context.Background(), "api/v1/metadata", map[string]string{"metric": "up"})
+			defer func() {
+				if res != nil && res.Body != nil {
+					if err := res.Body.Close(); err != nil {
+						fmt.Println("Error", "err", err)
+					}
+				}
+			}()
+			require.NoError(t, err)
+			require.NotNil(t, doer.Req)
+			require.Equal(t, http.MethodGet, doer.Req.Method)
+			require.Equal(t, "http://localhost:9090/api/v1/metadata?metric=up", doer.Req.URL.String())
+		})
 	})
 }
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// endpointMethodKeys are the keys of jsonData.httpMethods, by the endpoint whose method they set
+var endpointMethodKeys = map[string]string{
+	"query":       "api/v1/query",
+	"query_range": "api/v1/query_range",
+	"series":      "api/v1/series",
+	"labels":      "api/v1/labels",
+}
+
+// ParseEndpointMethods returns the HTTP methods of the endpoints set in jsonData.httpMethods, like
+// {"query_range": "GET"} for a query frontend caching only GET requests. The methods are by endpoint,
+// and the keys are query, query_range, series and labels.
+func ParseEndpointMethods(jsonData map[string]any) (map[string]string, error) {
+	v, ok := jsonData["httpMethods"]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	settings, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("httpMethods must be an object, got %T", v)
+	}
+
+	methods := make(map[string]string, len(settings))
+	for key, value := range settings {
+		endpoint, ok := endpointMethodKeys[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid endpoint %q in httpMethods, expected query, query_range, series or labels", key)
+		}
+		method, _ := value.(string)
+		switch method = strings.ToUpper(method); method {
+		case http.MethodGet, http.MethodPost:
+		default:
+			return nil, fmt.Errorf("invalid method %v of endpoint %q in httpMethods, expected GET or POST", value, key)
+		}
+		methods[endpoint] = method
+	}
+	return methods, nil
+}
+
+// HasEndpointMethod returns whether one of the endpoints of methods is sent with method
+func HasEndpointMethod(methods map[string]string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEndpointMethods(t *testing.T) {
+	methods, err := ParseEndpointMethods(map[string]any{})
+	require.NoError(t, err)
+	require.Empty(t, methods)
+
+	methods, err = ParseEndpointMethods(map[string]any{"httpMethods": map[string]any{"query_range": "get", "series": "POST"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"api/v1/query_range": http.MethodGet, "api/v1/series": http.MethodPost}, methods)
+	require.True(t, HasEndpointMethod(methods, http.MethodPost))
+	require.False(t, HasEndpointMethod(map[string]string{"api/v1/query": http.MethodGet}, http.MethodPost))
+
+	_, err = ParseEndpointMethods(map[string]any{"httpMethods": map[string]any{"exemplars": "GET"}})
+	require.ErrorContains(t, err, "invalid endpoint")
+	_, err = ParseEndpointMethods(map[string]any{"httpMethods": map[string]any{"query": "PUT"}})
+	require.ErrorContains(t, err, "invalid method")
+	_, err = ParseEndpointMethods(map[string]any{"httpMethods": "GET"})
+	require.Error(t, err)
+}
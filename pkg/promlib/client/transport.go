@@ -2,19 +2,43 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
 	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/data/utils/maputil"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 
 	"github.com/grafana/grafana/pkg/promlib/middleware"
+	"github.com/grafana/grafana/pkg/promlib/models"
 	"github.com/grafana/grafana/pkg/promlib/utils"
 )
 
+// defaultQueueTimeout bounds how long requests wait for a slot when the data source limits
+// its concurrent queries without setting concurrentQueriesQueueTimeout
+const defaultQueueTimeout = 30 * time.Second
+
+// defaultRetryBackoff is the wait before the first retry when the data source retries transient
+// errors without setting queryRetryBackoff
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// responseEncodings are the encodings of the responses asked for by each jsonData.responseCompression,
+// in order of preference. Without it, Go asks for gzip responses.
+var responseEncodings = map[string][]string{
+	"zstd": {"zstd", "gzip"},
+	"gzip": {"gzip"},
+	"none": {"identity"},
+}
+
+// ShardingControlHeader is the header of the number of shards the Mimir query-frontend splits queries in
+const ShardingControlHeader = "Sharding-Control"
+
 // CreateTransportOptions creates options for the http client.
 func CreateTransportOptions(ctx context.Context, settings backend.DataSourceInstanceSettings, logger log.Logger) (*sdkhttpclient.Options, error) {
 	opts, err := settings.HTTPClientOptions(ctx)
@@ -27,14 +51,106 @@ func CreateTransportOptions(ctx context.Context, settings backend.DataSourceInst
 		return nil, fmt.Errorf("error reading settings: %w", err)
 	}
 	httpMethod, _ := maputil.GetStringOptional(jsonData, "httpMethod")
+	endpointMethods, err := ParseEndpointMethods(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	// The endpoints sent with POST would be refused by ForceHttpGet
+	if HasEndpointMethod(endpointMethods, http.MethodPost) {
+		httpMethod = http.MethodPost
+	}
+
+	opts.Middlewares = middlewares(logger, httpMethod, settings.UID)
+
+	// The other settings of the connection pool, like httpMaxIdleConnsPerHost, are read by the SDK
+	maxConnsPerHost, err := utils.GetInt64Optional(jsonData, "httpMaxConnsPerHost")
+	if err != nil {
+		return nil, err
+	}
+	if maxConnsPerHost > 0 && opts.Timeouts != nil {
+		opts.Timeouts.MaxConnsPerHost = int(maxConnsPerHost)
+	}
+
+	// New connections resume the TLS sessions of the previous ones instead of a full handshake, which
+	// matters behind load balancers closing idle connections
+	tlsSessionCacheSize, err := utils.GetInt64Optional(jsonData, "tlsSessionCacheSize")
+	if err != nil {
+		return nil, err
+	}
+	if tlsSessionCacheSize > 0 {
+		sessionCache := tls.NewLRUClientSessionCache(int(tlsSessionCacheSize))
+		opts.ConfigureTLSConfig = func(_ sdkhttpclient.Options, tlsConfig *tls.Config) {
+			tlsConfig.ClientSessionCache = sessionCache
+		}
+	}
+
+	if _, ok := jsonData["allowedQueryTenants"]; ok {
+		// The tenant of the data source would replace the ones of the queries, so it is only the default
+		if tenant := opts.Header.Values(models.TenantHeader); len(tenant) > 0 {
+			opts.Header = opts.Header.Clone()
+			opts.Header.Del(models.TenantHeader)
+			opts.Middlewares = append(opts.Middlewares, middleware.DefaultHeader(models.TenantHeader, tenant))
+		}
+	}
+
+	if header, err := maputil.GetStringOptional(jsonData, "queryPriorityHeader"); err != nil {
+		return nil, err
+	} else if header != "" {
+		opts.Middlewares = append(opts.Middlewares, middleware.PriorityHeader(header))
+	}
+
+	if compression, err := maputil.GetStringOptional(jsonData, "responseCompression"); err != nil {
+		return nil, err
+	} else if compression != "" {
+		encodings, ok := responseEncodings[compression]
+		if !ok {
+			return nil, fmt.Errorf("invalid responseCompression %q, it must be zstd, gzip or none", compression)
+		}
+		opts.Middlewares = append(opts.Middlewares, middleware.ResponseCompression(encodings))
+	}
 
-	opts.Middlewares = middlewares(logger, httpMethod)
+	maxConcurrent, err := utils.GetInt64Optional(jsonData, "maxConcurrentQueries")
+	if err != nil {
+		return nil, err
+	}
+	if maxConcurrent > 0 {
+		queueTimeout := defaultQueueTimeout
+		if v, err := maputil.GetStringOptional(jsonData, "concurrentQueriesQueueTimeout"); err != nil {
+			return nil, err
+		} else if v != "" {
+			if queueTimeout, err = gtime.ParseIntervalStringToTimeDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid concurrentQueriesQueueTimeout: %w", err)
+			}
+		}
+		// The middlewares are created once for each data source instance, which all its requests share
+		opts.Middlewares = append(opts.Middlewares, middleware.ConcurrencyLimit(logger, int(maxConcurrent), queueTimeout))
+	}
+
+	maxRetries, err := utils.GetInt64Optional(jsonData, "maxQueryRetries")
+	if err != nil {
+		return nil, err
+	}
+	if maxRetries > 0 {
+		backoff := defaultRetryBackoff
+		if v, err := maputil.GetStringOptional(jsonData, "queryRetryBackoff"); err != nil {
+			return nil, err
+		} else if v != "" {
+			if backoff, err = gtime.ParseIntervalStringToTimeDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid queryRetryBackoff: %w", err)
+			}
+		}
+		// After the concurrency limit, so that retries keep the slot of their request
+		opts.Middlewares = append(opts.Middlewares, middleware.Retry(logger, int(maxRetries), backoff))
+	}
 
 	return &opts, nil
 }
 
-func middlewares(logger log.Logger, httpMethod string) []sdkhttpclient.Middleware {
+func middlewares(logger log.Logger, httpMethod string, uid string) []sdkhttpclient.Middleware {
 	middlewares := []sdkhttpclient.Middleware{
+		// First, so the wait of the other middlewares is timed
+		middleware.Timings(),
+		middleware.ConnectionMetrics(uid),
 		// TODO: probably isn't needed anymore and should by done by http infra code
 		middleware.CustomQueryParameters(logger),
 	}
@@ -2,10 +2,12 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"testing"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,6 +24,126 @@ func TestCreateTransportOptions(t *testing.T) {
 		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
 		require.NoError(t, err)
 		require.Equal(t, http.Header{"Foo": []string{"bar"}}, opts.Header)
-		require.Equal(t, 1, len(opts.Middlewares))
+		require.Equal(t, []string{"timings", "connection-metrics", "prom-custom-query-parameters"}, middlewareNames(opts))
 	})
+
+	t.Run("tenant of the data source is a default when queries can read other tenants", func(t *testing.T) {
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: []byte(`{"httpHeaderName1": "X-Scope-OrgID", "httpHeaderName2": "foo", "allowedQueryTenants": ["team-b"]}`),
+			DecryptedSecureJSONData: map[string]string{
+				"httpHeaderValue1": "team-a",
+				"httpHeaderValue2": "bar",
+			},
+		}
+		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.Equal(t, http.Header{"Foo": []string{"bar"}}, opts.Header)
+		require.Contains(t, middlewareNames(opts), "default-header")
+
+		settings.JSONData = []byte(`{"httpHeaderName1": "X-Scope-OrgID"}`)
+		opts, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.Equal(t, http.Header{"X-Scope-Orgid": []string{"team-a"}}, opts.Header)
+		require.NotContains(t, middlewareNames(opts), "default-header")
+	})
+
+	t.Run("limits concurrent queries when configured", func(t *testing.T) {
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: []byte(`{"maxConcurrentQueries": 4, "concurrentQueriesQueueTimeout": "5s"}`),
+		}
+		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.Contains(t, middlewareNames(opts), "concurrency-limit")
+
+		settings.JSONData = []byte(`{"maxConcurrentQueries": 4, "concurrentQueriesQueueTimeout": "soon"}`)
+		_, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.ErrorContains(t, err, "invalid concurrentQueriesQueueTimeout")
+
+		settings.JSONData = []byte(`{"maxConcurrentQueries": -1}`)
+		_, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.Error(t, err)
+	})
+
+	t.Run("sends the priority of queries when configured", func(t *testing.T) {
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: []byte(`{"queryPriorityHeader": "X-Query-Priority"}`),
+		}
+		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.Contains(t, middlewareNames(opts), "priority-header")
+	})
+
+	t.Run("negotiates the compression of responses when configured", func(t *testing.T) {
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: []byte(`{"responseCompression": "zstd"}`),
+		}
+		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.Contains(t, middlewareNames(opts), "response-compression")
+
+		settings.JSONData = []byte(`{"responseCompression": "brotli"}`)
+		_, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.ErrorContains(t, err, "invalid responseCompression")
+	})
+
+	t.Run("does not refuse the endpoints sent with POST when the data source uses GET", func(t *testing.T) {
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: []byte(`{"httpMethod": "GET"}`),
+		}
+		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.Contains(t, middlewareNames(opts), "force-http-get")
+
+		settings.JSONData = []byte(`{"httpMethod": "GET", "httpMethods": {"series": "POST"}}`)
+		opts, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.NotContains(t, middlewareNames(opts), "force-http-get")
+
+		settings.JSONData = []byte(`{"httpMethods": {"series": "PUT"}}`)
+		_, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.ErrorContains(t, err, "invalid method")
+	})
+
+	t.Run("tunes the connections when configured", func(t *testing.T) {
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: []byte(`{"httpMaxConnsPerHost": 8, "tlsSessionCacheSize": 16}`),
+		}
+		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		require.Equal(t, 8, opts.Timeouts.MaxConnsPerHost)
+
+		tlsConfig := &tls.Config{}
+		opts.ConfigureTLSConfig(*opts, tlsConfig)
+		require.NotNil(t, tlsConfig.ClientSessionCache)
+
+		settings.JSONData = []byte(`{"tlsSessionCacheSize": -1}`)
+		_, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.Error(t, err)
+	})
+
+	t.Run("retries transient errors when configured", func(t *testing.T) {
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: []byte(`{"maxQueryRetries": 2, "queryRetryBackoff": "100ms", "maxConcurrentQueries": 4}`),
+		}
+		opts, err := CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.NoError(t, err)
+		// After the concurrency limit
+		names := middlewareNames(opts)
+		require.Equal(t, []string{"concurrency-limit", "retry"}, names[len(names)-2:])
+
+		settings.JSONData = []byte(`{"maxQueryRetries": 2, "queryRetryBackoff": "later"}`)
+		_, err = CreateTransportOptions(context.Background(), settings, backend.NewLoggerWith("logger", "test"))
+		require.ErrorContains(t, err, "invalid queryRetryBackoff")
+	})
+}
+
+// middlewareNames returns the names of the middlewares of opts, in order
+func middlewareNames(opts *sdkhttpclient.Options) []string {
+	names := make([]string, 0, len(opts.Middlewares))
+	for _, m := range opts.Middlewares {
+		if named, ok := m.(sdkhttpclient.MiddlewareName); ok {
+			names = append(names, named.MiddlewareName())
+		}
+	}
+	return names
 }
@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"math"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// rebucketHistograms merges the cells of the native histogram frames of a result into the same
+// buckets at every time, between the lowest and the highest bound of all of them. The buckets have
+// the same width, or grow exponentially when log is set. The count of a cell is spread over the
+// buckets it overlaps in proportion to the overlap, measured on the scale of the buckets.
+// With log buckets, the count of the cells that are not above zero is added to the first bucket.
+func rebucketHistograms(frames []*data.Frame, buckets int, log bool) {
+	var cells []*data.Frame
+	for _, frame := range frames {
+		if frame.Meta != nil && frame.Meta.Type == "heatmap-cells" && len(frame.Fields) == 5 {
+			cells = append(cells, frame)
+		}
+	}
+	edges := bucketEdges(cells, buckets, log)
+	if edges == nil {
+		return
+	}
+
+	scale := func(v float64) float64 { return v }
+	if log {
+		scale = math.Log
+	}
+
+	for _, frame := range cells {
+		hist := newHistogramInfo()
+		hist.yMin.Labels = frame.Fields[1].Labels
+
+		counts := make([]float64, buckets)
+		flush := func(t time.Time) {
+			for i, c := range counts {
+				hist.time.Append(t)
+				hist.yMin.Append(edges[i])
+				hist.yMax.Append(edges[i+1])
+				hist.count.Append(c)
+				hist.yLayout.Append(int8(0))
+				counts[i] = 0
+			}
+		}
+
+		for row := 0; row < frame.Rows(); row++ {
+			t := frame.Fields[0].At(row).(time.Time)
+			if row > 0 && !t.Equal(frame.Fields[0].At(row-1).(time.Time)) {
+				flush(frame.Fields[0].At(row - 1).(time.Time))
+			}
+
+			lo, hi := frame.Fields[1].At(row).(float64), frame.Fields[2].At(row).(float64)
+			count := frame.Fields[3].At(row).(float64)
+			if log && lo <= 0 {
+				counts[0] += count
+				continue
+			}
+			if hi <= lo {
+				counts[bucketOf(edges, lo)] += count
+				continue
+			}
+			width := scale(hi) - scale(lo)
+			for i := bucketOf(edges, lo); i < buckets && edges[i] < hi; i++ {
+				overlap := scale(math.Min(hi, edges[i+1])) - scale(math.Max(lo, edges[i]))
+				if overlap > 0 {
+					counts[i] += count * overlap / width
+				}
+			}
+		}
+		if frame.Rows() > 0 {
+			flush(frame.Fields[0].At(frame.Rows() - 1).(time.Time))
+		}
+
+		frame.Fields = data.Fields{hist.time, hist.yMin, hist.yMax, hist.count, hist.yLayout}
+	}
+}
+
+// bucketEdges returns the buckets+1 bounds of the buckets the cells are merged into, or nil when
+// there is no range to split
+func bucketEdges(frames []*data.Frame, buckets int, log bool) []float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, frame := range frames {
+		for row := 0; row < frame.Rows(); row++ {
+			yMin, yMax := frame.Fields[1].At(row).(float64), frame.Fields[2].At(row).(float64)
+			if log {
+				// Only the positive bounds can be on a logarithmic scale
+				if yMin <= 0 {
+					yMin = yMax
+				}
+				if yMin <= 0 {
+					continue
+				}
+			}
+			lo, hi = math.Min(lo, yMin), math.Max(hi, yMax)
+		}
+	}
+	if math.IsInf(lo, 0) || math.IsInf(hi, 0) || hi <= lo {
+		return nil
+	}
+
+	edges := make([]float64, buckets+1)
+	for i := range edges {
+		if log {
+			edges[i] = lo * math.Pow(hi/lo, float64(i)/float64(buckets))
+		} else {
+			edges[i] = lo + (hi-lo)*float64(i)/float64(buckets)
+		}
+	}
+	// Avoid rounding errors on the bounds of the range
+	edges[0], edges[buckets] = lo, hi
+	return edges
+}
+
+// bucketOf returns the index of the bucket v falls into, the first or last one when it is outside
+// of the bounds
+func bucketOf(edges []float64, v float64) int {
+	for i := 1; i < len(edges)-1; i++ {
+		if v < edges[i] {
+			return i - 1
+		}
+	}
+	return len(edges) - 2
+}
@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	sdkjsoniter "github.com/grafana/grafana-plugin-sdk-go/data/utils/jsoniter"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebucketHistograms(t *testing.T) {
+	// The buckets of the two times differ, as the schema of the histogram changed between them
+	body := `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"job":"api"},"histograms":[
+			[1,{"count":"12","sum":"30","buckets":[[0,"0","1","4"],[0,"1","2","8"]]}],
+			[2,{"count":"8","sum":"30","buckets":[[0,"0","4","8"]]}]]}]}}`
+	read := func(t *testing.T, opt Options) ([]float64, []float64, []float64, []time.Time) {
+		t.Helper()
+		rsp := ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), opt)
+		require.NoError(t, rsp.Error)
+		require.Len(t, rsp.Frames, 1)
+		frame := rsp.Frames[0]
+		require.Equal(t, data.FrameType("heatmap-cells"), frame.Meta.Type)
+		require.Equal(t, "api", frame.Fields[1].Labels["job"])
+
+		var yMin, yMax, count []float64
+		var times []time.Time
+		for row := 0; row < frame.Rows(); row++ {
+			times = append(times, frame.Fields[0].At(row).(time.Time))
+			yMin = append(yMin, frame.Fields[1].At(row).(float64))
+			yMax = append(yMax, frame.Fields[2].At(row).(float64))
+			count = append(count, frame.Fields[3].At(row).(float64))
+		}
+		return yMin, yMax, count, times
+	}
+
+	t.Run("buckets of the data source are kept by default", func(t *testing.T) {
+		yMin, _, count, _ := read(t, Options{})
+		require.Equal(t, []float64{0, 1, 0}, yMin)
+		require.Equal(t, []float64{4, 8, 8}, count)
+	})
+
+	t.Run("linear buckets", func(t *testing.T) {
+		yMin, yMax, count, times := read(t, Options{HistogramBuckets: 2})
+		require.Equal(t, []float64{0, 2, 0, 2}, yMin)
+		require.Equal(t, []float64{2, 4, 2, 4}, yMax)
+		require.Equal(t, []float64{12, 0, 4, 4}, count)
+		require.Equal(t, []time.Time{time.Unix(1, 0).UTC(), time.Unix(1, 0).UTC(), time.Unix(2, 0).UTC(), time.Unix(2, 0).UTC()}, times)
+	})
+
+	t.Run("log buckets", func(t *testing.T) {
+		yMin, yMax, count, _ := read(t, Options{HistogramBuckets: 2, HistogramLogBuckets: true})
+		// The lowest positive bound is 1, the cells starting at zero are counted in the first bucket
+		require.Equal(t, []float64{1, 2, 1, 2}, yMin)
+		require.Equal(t, []float64{2, 4, 2, 4}, yMax)
+		require.Equal(t, []float64{12, 0, 8, 0}, count)
+	})
+}
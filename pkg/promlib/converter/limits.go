@@ -0,0 +1,46 @@
+package converter
+
+import "fmt"
+
+// ResponseLimitError is returned when a response exceeds one of the limits of Options.
+// The counts are the ones observed when decoding stopped.
+type ResponseLimitError struct {
+	// The exceeded limit: series, samples or bytes
+	Limit string
+	Max   int64
+
+	Series  int64
+	Samples int64
+	Bytes   int64
+}
+
+func (e *ResponseLimitError) Error() string {
+	return fmt.Sprintf("response too large: more than %d %s (observed %d series, %d samples, %d bytes)", e.Max, e.Limit, e.Series, e.Samples, e.Bytes)
+}
+
+// limitCounter counts the series and samples of a result while it is read
+type limitCounter struct {
+	opt     Options
+	series  int64
+	samples int64
+}
+
+func (c *limitCounter) addSeries() error {
+	c.series++
+	if c.opt.MaxSeries > 0 && c.series > c.opt.MaxSeries {
+		return c.err("series", c.opt.MaxSeries)
+	}
+	return nil
+}
+
+func (c *limitCounter) addSample() error {
+	c.samples++
+	if c.opt.MaxSamples > 0 && c.samples > c.opt.MaxSamples {
+		return c.err("samples", c.opt.MaxSamples)
+	}
+	return nil
+}
+
+func (c *limitCounter) err(limit string, max int64) error {
+	return &ResponseLimitError{Limit: limit, Max: max, Series: c.series, Samples: c.samples}
+}
@@ -21,8 +21,30 @@ func logf(format string, a ...any) {
 
 type Options struct {
 	Dataplane bool
+
+	// The expected number of samples of each series of a matrix result, used to allocate
+	// the fields once instead of growing them while the samples are read. Zero when unknown
+	PointsPerSeries int
+
+	// Limits of the series and samples of a matrix or vector result, zero for no limit.
+	// Decoding stops with a ResponseLimitError as soon as one is exceeded
+	MaxSeries  int64
+	MaxSamples int64
+
+	// Whether the series of matrix and vector results have a RawValue string field with
+	// the values as they were sent, which a float64 may not represent exactly
+	RawValues bool
+
+	// The number of buckets the cells of native histograms are merged into, so they are the same
+	// at every time of the result. Zero keeps the buckets of the data source. The buckets have the
+	// same width, or grow exponentially with HistogramLogBuckets
+	HistogramBuckets    int
+	HistogramLogBuckets bool
 }
 
+// RawValueFieldName is the name of the field of the values as they were sent
+const RawValueFieldName = "RawValue"
+
 func rspErr(e error) backend.DataResponse {
 	return backend.DataResponse{Error: e}
 }
@@ -35,6 +57,7 @@ func ReadPrometheusStyleResult(jIter *jsoniter.Iterator, opt Options) backend.Da
 	errorType := ""
 	promErrString := ""
 	warnings := []data.Notice{}
+	infos := []data.Notice{}
 
 l1Fields:
 	for l1Field, err := iter.ReadObject(); ; l1Field, err = iter.ReadObject() {
@@ -64,7 +87,12 @@ l1Fields:
 			}
 
 		case "warnings":
-			if warnings, err = readWarnings(iter); err != nil {
+			if warnings, err = readNotices(iter, data.NoticeSeverityWarning); err != nil {
+				return rspErr(err)
+			}
+
+		case "infos":
+			if infos, err = readNotices(iter, data.NoticeSeverityInfo); err != nil {
 				return rspErr(err)
 			}
 
@@ -90,27 +118,29 @@ l1Fields:
 		}
 	}
 
-	if len(warnings) > 0 {
+	if notices := append(warnings, infos...); len(notices) > 0 {
+		// Partial responses often come back without data, keep a frame so the notices explaining why are not lost
+		if len(rsp.Frames) == 0 {
+			rsp.Frames = append(rsp.Frames, data.NewFrame(""))
+		}
 		for _, frame := range rsp.Frames {
-			if frame.Meta == nil {
-				frame.Meta = &data.FrameMeta{}
-			}
-			frame.Meta.Notices = warnings
+			frame.AppendNotices(notices...)
 		}
 	}
 
 	return rsp
 }
 
-func readWarnings(iter *sdkjsoniter.Iterator) ([]data.Notice, error) {
-	warnings := []data.Notice{}
+// readNotices reads an array of messages, like warnings or infos, as notices with the given severity
+func readNotices(iter *sdkjsoniter.Iterator, severity data.NoticeSeverity) ([]data.Notice, error) {
+	notices := []data.Notice{}
 	next, err := iter.WhatIsNext()
 	if err != nil {
 		return nil, err
 	}
 
 	if next != sdkjsoniter.ArrayValue {
-		return warnings, nil
+		return notices, nil
 	}
 
 	for more, err := iter.ReadArray(); more; more, err = iter.ReadArray() {
@@ -127,14 +157,14 @@ func readWarnings(iter *sdkjsoniter.Iterator) ([]data.Notice, error) {
 				return nil, err
 			}
 			notice := data.Notice{
-				Severity: data.NoticeSeverityWarning,
+				Severity: severity,
 				Text:     s,
 			}
-			warnings = append(warnings, notice)
+			notices = append(notices, notice)
 		}
 	}
 
-	return warnings, nil
+	return notices, nil
 }
 
 func readPrometheusData(iter *sdkjsoniter.Iterator, opt Options) backend.DataResponse {
@@ -159,6 +189,7 @@ func readPrometheusData(iter *sdkjsoniter.Iterator, opt Options) backend.DataRes
 	var resultBytes []byte
 
 	encodingFlags := make([]string, 0)
+	var stats any
 
 l1Fields:
 	for l1Field, err := iter.ReadObject(); ; l1Field, err = iter.ReadObject() {
@@ -192,31 +223,28 @@ l1Fields:
 			if len(resultBytes) > 0 {
 				ji := sdkjsoniter.NewIterator(jsoniter.ParseBytes(sdkjsoniter.ConfigDefault, resultBytes))
 				rsp = readResult(resultType, rsp, ji, opt, encodingFlags)
+				if rsp.Error != nil {
+					return rsp
+				}
 			}
 		case "result":
 			// for some rare cases resultType is coming after the result.
 			// when that happens we save the bytes and parse them after reading resultType
 			// see: https://github.com/grafana/grafana/issues/64693
 			if resultTypeFound {
+				// the rest of the result can't be read after an error, stop here
 				rsp = readResult(resultType, rsp, iter, opt, encodingFlags)
+				if rsp.Error != nil {
+					return rsp
+				}
 			} else {
 				resultBytes, _ = iter.SkipAndReturnBytes()
 			}
 
 		case "stats":
-			v, err := iter.Read()
-			if err != nil {
-				rspErr(err)
-			}
-			if len(rsp.Frames) > 0 {
-				meta := rsp.Frames[0].Meta
-				if meta == nil {
-					meta = &data.FrameMeta{}
-					rsp.Frames[0].Meta = meta
-				}
-				meta.Custom = map[string]any{
-					"stats": v,
-				}
+			// stats usually come after the result, they are attached once all frames are read
+			if stats, err = iter.Read(); err != nil {
+				return rspErr(err)
 			}
 
 		case "":
@@ -237,9 +265,35 @@ l1Fields:
 		}
 	}
 
+	if stats != nil && len(rsp.Frames) > 0 {
+		meta := rsp.Frames[0].Meta
+		if meta == nil {
+			meta = &data.FrameMeta{}
+			rsp.Frames[0].Meta = meta
+		}
+		meta.Custom = customMetaWithStats(meta.Custom, stats)
+	}
+
 	return rsp
 }
 
+// customMetaWithStats adds stats to the existing custom metadata of a frame
+func customMetaWithStats(custom any, stats any) map[string]any {
+	m := map[string]any{}
+	switch c := custom.(type) {
+	case map[string]string:
+		for k, v := range c {
+			m[k] = v
+		}
+	case map[string]any:
+		for k, v := range c {
+			m[k] = v
+		}
+	}
+	m["stats"] = stats
+	return m
+}
+
 // will read the result object based on the resultType and return a DataResponse
 func readResult(resultType string, rsp backend.DataResponse, iter *sdkjsoniter.Iterator, opt Options, encodingFlags []string) backend.DataResponse {
 	switch resultType {
@@ -550,7 +604,7 @@ func readScalar(iter *sdkjsoniter.Iterator, dataPlane bool) backend.DataResponse
 	valueField.Name = data.TimeSeriesValueFieldName
 	valueField.Labels = data.Labels{}
 
-	t, v, err := readTimeValuePair(iter)
+	t, v, _, err := readTimeValuePair(iter)
 	if err != nil {
 		rsp.Error = err
 		return rsp
@@ -575,16 +629,16 @@ func readScalar(iter *sdkjsoniter.Iterator, dataPlane bool) backend.DataResponse
 
 func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt Options) backend.DataResponse {
 	rsp := backend.DataResponse{}
+	counter := &limitCounter{opt: opt}
 
 	for more, err := iter.ReadArray(); more; more, err = iter.ReadArray() {
 		if err != nil {
 			return rspErr(err)
 		}
-		timeField := data.NewFieldFromFieldType(data.FieldTypeTime, 0)
-		timeField.Name = data.TimeSeriesTimeFieldName
-		valueField := data.NewFieldFromFieldType(data.FieldTypeFloat64, 0)
-		valueField.Name = data.TimeSeriesValueFieldName
-		valueField.Labels = data.Labels{}
+		if err := counter.addSeries(); err != nil {
+			return rspErr(err)
+		}
+		timeField, valueField, rawField := newSeriesFields(resultType, opt)
 
 		var histogram *histogramInfo
 
@@ -599,12 +653,18 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 				}
 
 			case "value":
-				t, v, err := readTimeValuePair(iter)
+				t, v, raw, err := readTimeValuePair(iter)
 				if err != nil {
 					return rspErr(err)
 				}
+				if err := counter.addSample(); err != nil {
+					return rspErr(err)
+				}
 				timeField.Append(t)
 				valueField.Append(v)
+				if rawField != nil {
+					rawField.Append(raw)
+				}
 
 			// nolint:goconst
 			case "values":
@@ -612,12 +672,18 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 					if err != nil {
 						return rspErr(err)
 					}
-					t, v, err := readTimeValuePair(iter)
+					t, v, raw, err := readTimeValuePair(iter)
 					if err != nil {
 						return rspErr(err)
 					}
+					if err := counter.addSample(); err != nil {
+						return rspErr(err)
+					}
 					timeField.Append(t)
 					valueField.Append(v)
+					if rawField != nil {
+						rawField.Append(raw)
+					}
 				}
 
 			case "histogram":
@@ -662,6 +728,9 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 			rsp.Frames = append(rsp.Frames, frame)
 		} else {
 			frame := data.NewFrame("", timeField, valueField)
+			if rawField != nil {
+				frame.Fields = append(frame.Fields, rawField)
+			}
 			frame.Meta = &data.FrameMeta{
 				Type:   data.FrameTypeTimeSeriesMulti,
 				Custom: resultTypeToCustomMeta(resultType),
@@ -676,35 +745,56 @@ func readMatrixOrVectorMulti(iter *sdkjsoniter.Iterator, resultType string, opt
 		}
 	}
 
+	if opt.HistogramBuckets > 0 {
+		rebucketHistograms(rsp.Frames, opt.HistogramBuckets, opt.HistogramLogBuckets)
+	}
+
 	return rsp
 }
 
-func readTimeValuePair(iter *sdkjsoniter.Iterator) (time.Time, float64, error) {
+// newSeriesFields returns the time, value and raw value fields of a series, the raw value one is nil
+// unless raw values are asked for. For matrix results they have room for the expected number of samples.
+func newSeriesFields(resultType string, opt Options) (*data.Field, *data.Field, *data.Field) {
+	capacity := 0
+	if resultType == "matrix" && opt.PointsPerSeries > 0 {
+		capacity = opt.PointsPerSeries
+	}
+	timeField := data.NewField(data.TimeSeriesTimeFieldName, nil, make([]time.Time, 0, capacity))
+	valueField := data.NewField(data.TimeSeriesValueFieldName, data.Labels{}, make([]float64, 0, capacity))
+	var rawField *data.Field
+	if opt.RawValues {
+		rawField = data.NewField(RawValueFieldName, nil, make([]string, 0, capacity))
+	}
+	return timeField, valueField, rawField
+}
+
+// readTimeValuePair reads a sample, returning its value both parsed and as it was sent
+func readTimeValuePair(iter *sdkjsoniter.Iterator) (time.Time, float64, string, error) {
 	if _, err := iter.ReadArray(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	t, err := iter.ReadFloat64()
 	if err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	if _, err = iter.ReadArray(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	var v string
 	if v, err = iter.ReadString(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	if _, err = iter.ReadArray(); err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, "", err
 	}
 
 	tt := timeFromFloat(t)
 	fv, err := strconv.ParseFloat(v, 64)
-	return tt, fv, err
+	return tt, fv, v, err
 }
 
 type histogramInfo struct {
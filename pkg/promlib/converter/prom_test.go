@@ -28,6 +28,7 @@ var files = []string{
 	"prom-scalar",
 	"prom-series",
 	"prom-warnings",
+	"prom-warnings-no-data",
 	"prom-error",
 	"prom-exemplars-a",
 	"prom-exemplars-b",
@@ -43,6 +44,15 @@ func TestReadPromFrames(t *testing.T) {
 	}
 }
 
+func TestReadPromFramesWithPointsPerSeries(t *testing.T) {
+	// Pre-allocating the fields, with more or less room than needed, does not change the frames
+	for _, points := range []int{1, 1000} {
+		for _, name := range []string{"prom-matrix", "prom-matrix-with-nans", "prom-vector"} {
+			t.Run(name, runScenario(name, Options{PointsPerSeries: points}))
+		}
+	}
+}
+
 func runScenario(name string, opts Options) func(t *testing.T) {
 	return func(t *testing.T) {
 		// Safe to disable, this is a test.
@@ -64,6 +74,40 @@ func runScenario(name string, opts Options) func(t *testing.T) {
 	}
 }
 
+func TestReadPromFramesLimits(t *testing.T) {
+	read := func(t *testing.T, opts Options) error {
+		f, err := os.Open(path.Join("testdata", "prom-matrix.json"))
+		require.NoError(t, err)
+		defer func() { _ = f.Close() }()
+		return ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, f, 1024), opts).Error
+	}
+
+	require.NoError(t, read(t, Options{MaxSeries: 2, MaxSamples: 6}))
+
+	var limitErr *ResponseLimitError
+	require.ErrorAs(t, read(t, Options{MaxSeries: 1}), &limitErr)
+	require.Equal(t, ResponseLimitError{Limit: "series", Max: 1, Series: 2, Samples: 3}, *limitErr)
+
+	require.ErrorAs(t, read(t, Options{MaxSamples: 4}), &limitErr)
+	require.Equal(t, ResponseLimitError{Limit: "samples", Max: 4, Series: 2, Samples: 5}, *limitErr)
+	require.Equal(t, "response too large: more than 4 samples (observed 2 series, 5 samples, 0 bytes)", limitErr.Error())
+}
+
+func TestReadPromFramesRawValues(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"__name__":"bytes_total"},"values":[[1,"18446744073709551615"],[2,"1e-3"]]}]}}`
+	rsp := ReadPrometheusStyleResult(jsoniter.Parse(sdkjsoniter.ConfigDefault, strings.NewReader(body), 1024), Options{RawValues: true})
+	require.NoError(t, rsp.Error)
+	require.Len(t, rsp.Frames, 1)
+
+	fields := rsp.Frames[0].Fields
+	require.Len(t, fields, 3)
+	require.Equal(t, RawValueFieldName, fields[2].Name)
+	require.Equal(t, "18446744073709551615", fields[2].At(0))
+	require.Equal(t, "1e-3", fields[2].At(1))
+	require.Equal(t, 0.001, fields[1].At(1))
+}
+
 func TestTimeConversions(t *testing.T) {
 	// include millisecond precision
 	assert.Equal(t,
@@ -0,0 +1,135 @@
+package promlib
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/patrickmn/go-cache"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+const flavorCacheKey = "flavor"
+
+// detectedFlavorExpiration is how long a detected flavor is cached. Flavors that could not be
+// detected expire with the default expiration of the cache, so they are detected again sooner.
+const detectedFlavorExpiration = 10 * time.Minute
+
+type FlavorRequest struct {
+	PluginContext backend.PluginContext
+}
+
+// Flavor is the application serving the Prometheus API of a data source, with the features it supports
+type Flavor struct {
+	// One of the Kind constants, or the configured prometheusType when it is not detected
+	Application string `json:"application"`
+	Version     string `json:"version,omitempty"`
+	// Whether the application was detected from the build info, it is the configured one otherwise
+	Detected     bool                `json:"detected"`
+	Capabilities models.Capabilities `json:"capabilities"`
+}
+
+// minimumVersions holds the first version of an application supporting each capability, empty when none does.
+// The Thanos options are sent to Prometheus, which ignores them, as Thanos reports the same build info.
+var minimumVersions = map[string]struct{ nativeHistograms, protobuf, exemplars, thanosOptions, cacheBypass, querySharding string }{
+	KindPrometheus:      {nativeHistograms: "2.40.0", protobuf: "2.13.0", exemplars: "2.26.0", thanosOptions: "0.0.0"},
+	KindMimir:           {nativeHistograms: "2.7.0", protobuf: "0.0.0", exemplars: "0.0.0", cacheBypass: "0.0.0", querySharding: "0.0.0"},
+	KindCortex:          {protobuf: "0.0.0", exemplars: "1.11.0", cacheBypass: "0.0.0"},
+	KindThanos:          {nativeHistograms: "0.31.0", exemplars: "0.22.0", thanosOptions: "0.0.0"},
+	KindVictoriaMetrics: {},
+}
+
+func (s *Service) GetFlavor(ctx context.Context, req FlavorRequest) (*Flavor, error) {
+	ds, err := s.getInstance(ctx, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+	flavor := getFlavor(ctx, ds, s.logger.FromContext(ctx))
+	return &flavor, nil
+}
+
+// getFlavor detects the flavor of the data source from its build info, falling back to the configured
+// prometheusType when there is none, like with Cortex.
+func getFlavor(ctx context.Context, i *instance, logger log.Logger) Flavor {
+	if f, found := i.versionCache.Get(flavorCacheKey); found {
+		return f.(Flavor)
+	}
+
+	flavor := Flavor{Application: i.prometheusType}
+	expiration := cache.DefaultExpiration
+	if buildInfo, err := getBuildInfo(ctx, i); err != nil {
+		logger.Debug("Failed to detect the flavor of the data source, using the configured one", "err", err)
+	} else {
+		flavor = Flavor{Application: detectApplication(ctx, i, buildInfo.Data), Version: buildInfo.Data.Version, Detected: true}
+		if flavor.Application == KindVictoriaMetrics {
+			// The reported version is not the one of VictoriaMetrics
+			flavor.Version = ""
+		}
+		expiration = detectedFlavorExpiration
+	}
+	flavor.Capabilities = capabilities(flavor.Application, flavor.Version)
+
+	i.versionCache.Set(flavorCacheKey, flavor, expiration)
+	return flavor
+}
+
+// capabilities returns the capabilities of a version of an application. All of them are
+// assumed for unknown applications, so features keep being used when detection fails.
+func capabilities(application, version string) models.Capabilities {
+	v, ok := minimumVersions[application]
+	if !ok {
+		return models.AllCapabilities
+	}
+	return models.Capabilities{
+		NativeHistograms: versionAtLeast(version, v.nativeHistograms),
+		Protobuf:         versionAtLeast(version, v.protobuf),
+		Exemplars:        versionAtLeast(version, v.exemplars),
+		ThanosOptions:    versionAtLeast(version, v.thanosOptions),
+		CacheBypass:      versionAtLeast(version, v.cacheBypass),
+		QuerySharding:    versionAtLeast(version, v.querySharding),
+	}
+}
+
+// versionAtLeast returns whether version is minimum or later. An empty minimum is never reached,
+// while versions that cannot be parsed, like the ones of development builds, are assumed to be recent.
+func versionAtLeast(version, minimum string) bool {
+	if minimum == "" {
+		return false
+	}
+	have, ok := parseVersion(version)
+	if !ok {
+		return true
+	}
+	want, _ := parseVersion(minimum)
+	for i := range have {
+		if have[i] != want[i] {
+			return have[i] > want[i]
+		}
+	}
+	return true
+}
+
+// parseVersion parses a major.minor.patch version, ignoring its pre-release and build metadata
+func parseVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) > len(parsed) {
+		return parsed, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
@@ -0,0 +1,110 @@
+package promlib
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func Test_GetFlavor(t *testing.T) {
+	getFlavorWithEndpoints := func(t *testing.T, status int, body, jsonData string, endpoints map[string]string) *Flavor {
+		t.Helper()
+		rt := heuristicsSuccessRoundTripper{res: io.NopCloser(strings.NewReader(body)), status: status, endpoints: endpoints}
+		logger := backend.NewLoggerWith("logger", "test")
+		s := &Service{
+			im:     datasource.NewInstanceManager(newInstanceSettings(newHeuristicsSDKProvider(rt), logger, mockExtendClientOpts, nil)),
+			logger: logger,
+		}
+		pluginCtx := getPluginContext()
+		pluginCtx.DataSourceInstanceSettings.JSONData = []byte(jsonData)
+		flavor, err := s.GetFlavor(context.Background(), FlavorRequest{PluginContext: pluginCtx})
+		require.NoError(t, err)
+		return flavor
+	}
+	getFlavor := func(t *testing.T, status int, body, jsonData string) *Flavor {
+		t.Helper()
+		return getFlavorWithEndpoints(t, status, body, jsonData, nil)
+	}
+
+	t.Run("old Prometheus does not support native histograms", func(t *testing.T) {
+		flavor := getFlavor(t, http.StatusOK, `{"status":"success","data":{"version":"2.30.3","revision":"abc"}}`, `{}`)
+		require.Equal(t, &Flavor{
+			Application:  KindPrometheus,
+			Version:      "2.30.3",
+			Detected:     true,
+			Capabilities: models.Capabilities{Protobuf: true, Exemplars: true, ThanosOptions: true},
+		}, flavor)
+	})
+
+	t.Run("Mimir is detected from its application", func(t *testing.T) {
+		flavor := getFlavor(t, http.StatusOK, `{"status":"success","data":{"application":"Grafana Mimir","version":"2.12.0"}}`, `{}`)
+		require.Equal(t, KindMimir, flavor.Application)
+		require.Equal(t, models.Capabilities{NativeHistograms: true, Protobuf: true, Exemplars: true, CacheBypass: true, QuerySharding: true}, flavor.Capabilities)
+	})
+
+	t.Run("VictoriaMetrics is detected from its active queries endpoint", func(t *testing.T) {
+		flavor := getFlavorWithEndpoints(t, http.StatusOK, `{"status":"success","data":{"version":"2.24.0"}}`, `{}`, map[string]string{
+			"/api/v1/status/active_queries": `{"status":"ok","data":[]}`,
+		})
+		require.Equal(t, KindVictoriaMetrics, flavor.Application)
+		require.Empty(t, flavor.Version)
+		require.Equal(t, models.Capabilities{}, flavor.Capabilities)
+
+		// The version it reports is not enough, a Prometheus of that version does not have the endpoint
+		flavor = getFlavor(t, http.StatusOK, `{"status":"success","data":{"version":"2.24.0"}}`, `{}`)
+		require.Equal(t, KindPrometheus, flavor.Application)
+		require.Equal(t, "2.24.0", flavor.Version)
+	})
+
+	t.Run("Thanos is detected from its application or its configured type", func(t *testing.T) {
+		flavor := getFlavor(t, http.StatusOK, `{"status":"success","data":{"application":"Thanos","version":"0.35.1"}}`, `{}`)
+		require.Equal(t, KindThanos, flavor.Application)
+		require.Equal(t, models.Capabilities{NativeHistograms: true, Exemplars: true, ThanosOptions: true}, flavor.Capabilities)
+
+		flavor = getFlavor(t, http.StatusOK, `{"status":"success","data":{"version":"0.35.1"}}`, `{"prometheusType":"Thanos"}`)
+		require.Equal(t, KindThanos, flavor.Application)
+
+		// Its build info is the one of Prometheus, whatever its version
+		flavor = getFlavor(t, http.StatusOK, `{"status":"success","data":{"version":"0.35.1"}}`, `{"prometheusType":"Prometheus"}`)
+		require.Equal(t, KindPrometheus, flavor.Application)
+		require.True(t, flavor.Capabilities.ThanosOptions)
+	})
+
+	t.Run("Thanos options are not sent to Mimir configured as Thanos", func(t *testing.T) {
+		flavor := getFlavor(t, http.StatusOK, `{"status":"success","data":{"application":"Grafana Mimir","version":"2.12.0"}}`, `{"prometheusType":"Thanos"}`)
+		require.Equal(t, KindMimir, flavor.Application)
+		require.False(t, flavor.Capabilities.ThanosOptions)
+	})
+
+	t.Run("configured type is used without build info", func(t *testing.T) {
+		flavor := getFlavor(t, http.StatusNotFound, `not found`, `{"prometheusType":"Cortex"}`)
+		require.Equal(t, &Flavor{
+			Application:  KindCortex,
+			Capabilities: models.Capabilities{Protobuf: true, Exemplars: true, CacheBypass: true},
+		}, flavor)
+
+		flavor = getFlavor(t, http.StatusNotFound, `not found`, `{}`)
+		require.False(t, flavor.Detected)
+		require.Equal(t, models.AllCapabilities, flavor.Capabilities)
+	})
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	require.True(t, versionAtLeast("2.40.0", "2.40.0"))
+	require.True(t, versionAtLeast("v2.45.1-rc.0", "2.40.0"))
+	require.True(t, versionAtLeast("3.0", "2.40.0"))
+	require.False(t, versionAtLeast("2.39.9", "2.40.0"))
+	require.False(t, versionAtLeast("0.30.2", "0.31.0"))
+	// Unknown versions are assumed to be recent, but an empty minimum is never reached
+	require.True(t, versionAtLeast("", "2.40.0"))
+	require.True(t, versionAtLeast("r258-abc", "2.7.0"))
+	require.False(t, versionAtLeast("3.0.0", ""))
+}
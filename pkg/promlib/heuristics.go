@@ -6,16 +6,26 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 )
 
 const (
-	KindPrometheus = "Prometheus"
-	KindMimir      = "Mimir"
+	KindPrometheus      = "Prometheus"
+	KindMimir           = "Mimir"
+	KindThanos          = "Thanos"
+	KindCortex          = "Cortex"
+	KindVictoriaMetrics = "VictoriaMetrics"
 )
 
+// victoriaMetricsVersion is the Prometheus version VictoriaMetrics reports in its build info, without a revision
+const victoriaMetricsVersion = "2.24.0"
+
+// victoriaMetricsProbePath is an endpoint of the Prometheus API of VictoriaMetrics that Prometheus does not have
+const victoriaMetricsProbePath = "api/v1/status/active_queries"
+
 var (
 	ErrNoBuildInfo = errors.New("no build info")
 )
@@ -30,13 +40,14 @@ type BuildInfoResponse struct {
 }
 
 type BuildInfoResponseData struct {
-	Version   string            `json:"version"`
-	Revision  string            `json:"revision"`
-	Branch    string            `json:"branch"`
-	Features  map[string]string `json:"features"`
-	BuildUser string            `json:"buildUser"`
-	BuildDate string            `json:"buildDate"`
-	GoVersion string            `json:"goVersion"`
+	Application string            `json:"application"`
+	Version     string            `json:"version"`
+	Revision    string            `json:"revision"`
+	Branch      string            `json:"branch"`
+	Features    map[string]string `json:"features"`
+	BuildUser   string            `json:"buildUser"`
+	BuildDate   string            `json:"buildDate"`
+	GoVersion   string            `json:"goVersion"`
 }
 
 func (s *Service) GetBuildInfo(ctx context.Context, req BuildInfoRequest) (*BuildInfoResponse, error) {
@@ -102,13 +113,46 @@ func getHeuristics(ctx context.Context, i *instance, logger log.Logger) (*Heuris
 		logger.Warn("Failed to get prometheus buildinfo", "err", err.Error())
 		return nil, fmt.Errorf("failed to get buildinfo: %w", err)
 	}
-	if len(buildInfo.Data.Features) == 0 {
-		// If there are no features then this is a Prometheus datasource
-		heuristics.Application = KindPrometheus
-		heuristics.Features.RulerApiEnabled = false
-	} else {
-		heuristics.Application = KindMimir
-		heuristics.Features.RulerApiEnabled = true
-	}
+	heuristics.Application = detectApplication(ctx, i, buildInfo.Data)
+	heuristics.Features.RulerApiEnabled = heuristics.Application == KindMimir
 	return &heuristics, nil
 }
+
+// applicationKind returns the kind of application that reported the build info. Thanos reports the
+// same build info as Prometheus, without an application, so it is only told apart when it names
+// itself or when it is the configured prometheusType.
+func applicationKind(data BuildInfoResponseData, configured string) string {
+	application := strings.ToLower(data.Application)
+	switch {
+	case len(data.Features) > 0 || strings.Contains(application, "mimir"):
+		return KindMimir
+	case strings.Contains(application, "thanos") || configured == KindThanos:
+		return KindThanos
+	}
+	return KindPrometheus
+}
+
+// detectApplication returns the kind of application of the data source that reported the build info,
+// see applicationKind. VictoriaMetrics reports the build info of an old Prometheus, without a revision,
+// for compatibility with the clients checking it. When the build info is that one, the data source
+// is asked for an endpoint only VictoriaMetrics has to tell them apart.
+func detectApplication(ctx context.Context, i *instance, data BuildInfoResponseData) string {
+	kind := applicationKind(data, i.prometheusType)
+	if kind == KindPrometheus && data.Version == victoriaMetricsVersion && data.Revision == "" && isVictoriaMetrics(ctx, i) {
+		return KindVictoriaMetrics
+	}
+	return kind
+}
+
+// isVictoriaMetrics returns whether the data source lists its active queries like VictoriaMetrics does
+func isVictoriaMetrics(ctx context.Context, i *instance) bool {
+	resp, err := i.resource.Execute(ctx, &backend.CallResourceRequest{Path: victoriaMetricsProbePath})
+	if err != nil || resp.Status != http.StatusOK {
+		return false
+	}
+	var res struct {
+		Status string            `json:"status"`
+		Data   []json.RawMessage `json:"data"`
+	}
+	return json.Unmarshal(resp.Body, &res) == nil && res.Status == "ok"
+}
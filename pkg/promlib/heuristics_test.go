@@ -19,9 +19,19 @@ import (
 type heuristicsSuccessRoundTripper struct {
 	res    io.ReadCloser
 	status int
+	// The bodies of the other endpoints, by the suffix of their path. They are not found when not set.
+	endpoints map[string]string
 }
 
 func (rt *heuristicsSuccessRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/api/v1/status/buildinfo") {
+		for path, body := range rt.endpoints {
+			if strings.HasSuffix(req.URL.Path, path) {
+				return &http.Response{Status: "200", StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+			}
+		}
+		return &http.Response{Status: "404", StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("not found")), Request: req}, nil
+	}
 	return &http.Response{
 		Status:        strconv.Itoa(rt.status),
 		StatusCode:    rt.status,
@@ -54,7 +64,7 @@ func Test_GetHeuristics(t *testing.T) {
 		httpProvider := newHeuristicsSDKProvider(rt)
 		logger := backend.NewLoggerWith("logger", "test")
 		s := &Service{
-			im:     datasource.NewInstanceManager(newInstanceSettings(httpProvider, logger, mockExtendClientOpts)),
+			im:     datasource.NewInstanceManager(newInstanceSettings(httpProvider, logger, mockExtendClientOpts, nil)),
 			logger: logger,
 		}
 
@@ -76,7 +86,7 @@ func Test_GetHeuristics(t *testing.T) {
 		httpProvider := newHeuristicsSDKProvider(rt)
 		logger := backend.NewLoggerWith("logger", "test")
 		s := &Service{
-			im:     datasource.NewInstanceManager(newInstanceSettings(httpProvider, logger, mockExtendClientOpts)),
+			im:     datasource.NewInstanceManager(newInstanceSettings(httpProvider, logger, mockExtendClientOpts, nil)),
 			logger: logger,
 		}
 
@@ -89,4 +99,25 @@ func Test_GetHeuristics(t *testing.T) {
 		assert.Equal(t, KindMimir, res.Application)
 		assert.Equal(t, Features{RulerApiEnabled: true}, res.Features)
 	})
+	t.Run("should return Thanos", func(t *testing.T) {
+		rt := heuristicsSuccessRoundTripper{
+			res:    io.NopCloser(strings.NewReader("{\"status\":\"success\",\"data\":{\"application\":\"Thanos\",\"version\":\"0.35.1\"}}")),
+			status: http.StatusOK,
+		}
+		httpProvider := newHeuristicsSDKProvider(rt)
+		logger := backend.NewLoggerWith("logger", "test")
+		s := &Service{
+			im:     datasource.NewInstanceManager(newInstanceSettings(httpProvider, logger, mockExtendClientOpts, nil)),
+			logger: logger,
+		}
+
+		req := HeuristicsRequest{
+			PluginContext: getPluginContext(),
+		}
+		res, err := s.GetHeuristics(context.Background(), req)
+		assert.NoError(t, err)
+		require.NotNil(t, res)
+		assert.Equal(t, KindThanos, res.Application)
+		assert.Equal(t, Features{RulerApiEnabled: false}, res.Features)
+	})
 }
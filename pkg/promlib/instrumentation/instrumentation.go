@@ -1,6 +1,8 @@
 package instrumentation
 
 import (
+	"strconv"
+
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -12,6 +14,30 @@ var (
 		Name:      "prometheus_plugin_backend_request_count",
 		Help:      "The total amount of prometheus backend plugin requests",
 	}, []string{"endpoint", "status", "errorSource"})
+
+	cancelledQueriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "prometheus_plugin_cancelled_queries_total",
+		Help:      "The total amount of prometheus queries cancelled before their result was received, by data source",
+	}, []string{"datasource"})
+
+	slowQueriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "prometheus_plugin_slow_queries_total",
+		Help:      "The total amount of prometheus queries slower than the slow query threshold of their data source",
+	}, []string{"datasource"})
+
+	connectionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "prometheus_plugin_connections_total",
+		Help:      "The total amount of connections prometheus requests were sent on, by data source and whether the connection was reused",
+	}, []string{"datasource", "reused"})
+
+	tlsHandshakesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "prometheus_plugin_tls_handshakes_total",
+		Help:      "The total amount of TLS handshakes of the connections to prometheus, by data source and whether the TLS session was resumed",
+	}, []string{"datasource", "resumed"})
 )
 
 const (
@@ -38,6 +64,28 @@ func UpdateQueryDataMetrics(err error, resp *backend.QueryDataResponse) {
 	pluginRequestCounter.WithLabelValues(EndpointQueryData, status, errorSource).Inc()
 }
 
+// IncCancelledQueries counts a query of the data source with uid that was cancelled
+func IncCancelledQueries(uid string) {
+	cancelledQueriesCounter.WithLabelValues(uid).Inc()
+}
+
+// IncSlowQueries counts a slow query of the data source with uid
+func IncSlowQueries(uid string) {
+	slowQueriesCounter.WithLabelValues(uid).Inc()
+}
+
+// IncConnections counts a request of the data source with uid sent on a connection, reused when it was idle
+// instead of new
+func IncConnections(uid string, reused bool) {
+	connectionsCounter.WithLabelValues(uid, strconv.FormatBool(reused)).Inc()
+}
+
+// IncTLSHandshakes counts a TLS handshake of a new connection of the data source with uid, resumed when it
+// reused a cached TLS session
+func IncTLSHandshakes(uid string, resumed bool) {
+	tlsHandshakesCounter.WithLabelValues(uid, strconv.FormatBool(resumed)).Inc()
+}
+
 func getErrorSource(err error, resp *backend.QueryDataResponse) string {
 	if err != nil {
 		return PluginSource
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func checkErrorSource(t *testing.T, expected, actual string) {
@@ -115,3 +116,35 @@ func TestGetErrorSource(t *testing.T) {
 		checkErrorSource(t, NoneSource, errorSource)
 	})
 }
+
+func TestIncCancelledQueries(t *testing.T) {
+	before := testutil.ToFloat64(cancelledQueriesCounter.WithLabelValues("cancelled-uid"))
+	IncCancelledQueries("cancelled-uid")
+	if after := testutil.ToFloat64(cancelledQueriesCounter.WithLabelValues("cancelled-uid")); after != before+1 {
+		t.Errorf("expected %v cancelled queries, but got %v", before+1, after)
+	}
+}
+
+func TestIncSlowQueries(t *testing.T) {
+	before := testutil.ToFloat64(slowQueriesCounter.WithLabelValues("slow-uid"))
+	IncSlowQueries("slow-uid")
+	if after := testutil.ToFloat64(slowQueriesCounter.WithLabelValues("slow-uid")); after != before+1 {
+		t.Errorf("expected %v slow queries, but got %v", before+1, after)
+	}
+}
+
+func TestIncConnections(t *testing.T) {
+	before := testutil.ToFloat64(connectionsCounter.WithLabelValues("connections-uid", "true"))
+	IncConnections("connections-uid", true)
+	if after := testutil.ToFloat64(connectionsCounter.WithLabelValues("connections-uid", "true")); after != before+1 {
+		t.Errorf("expected %v reused connections, but got %v", before+1, after)
+	}
+}
+
+func TestIncTLSHandshakes(t *testing.T) {
+	before := testutil.ToFloat64(tlsHandshakesCounter.WithLabelValues("handshakes-uid", "false"))
+	IncTLSHandshakes("handshakes-uid", false)
+	if after := testutil.ToFloat64(tlsHandshakesCounter.WithLabelValues("handshakes-uid", "false")); after != before+1 {
+		t.Errorf("expected %v full TLS handshakes, but got %v", before+1, after)
+	}
+}
@@ -2,8 +2,10 @@ package promlib
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -12,13 +14,16 @@ import (
 	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data/utils/maputil"
 	"github.com/patrickmn/go-cache"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 
 	"github.com/grafana/grafana/pkg/promlib/client"
 	"github.com/grafana/grafana/pkg/promlib/instrumentation"
+	"github.com/grafana/grafana/pkg/promlib/models"
 	"github.com/grafana/grafana/pkg/promlib/querydata"
 	"github.com/grafana/grafana/pkg/promlib/resource"
+	"github.com/grafana/grafana/pkg/promlib/utils"
 )
 
 type Service struct {
@@ -30,6 +35,9 @@ type instance struct {
 	queryData    *querydata.QueryData
 	resource     *resource.Resource
 	versionCache *cache.Cache
+
+	// The configured jsonData.prometheusType, used when the flavor cannot be detected
+	prometheusType string
 }
 
 type ExtendOptions func(ctx context.Context, settings backend.DataSourceInstanceSettings, clientOpts *sdkhttpclient.Options) error
@@ -39,12 +47,12 @@ func NewService(httpClientProvider *sdkhttpclient.Provider, plog log.Logger, ext
 		httpClientProvider = sdkhttpclient.NewProvider()
 	}
 	return &Service{
-		im:     datasource.NewInstanceManager(newInstanceSettings(httpClientProvider, plog, extendOptions)),
+		im:     datasource.NewInstanceManager(newInstanceSettings(httpClientProvider, plog, extendOptions, querydata.NewMemoryResultCache())),
 		logger: plog,
 	}
 }
 
-func newInstanceSettings(httpClientProvider *sdkhttpclient.Provider, log log.Logger, extendOptions ExtendOptions) datasource.InstanceFactoryFunc {
+func newInstanceSettings(httpClientProvider *sdkhttpclient.Provider, log log.Logger, extendOptions ExtendOptions, resultCache querydata.ResultCache) datasource.InstanceFactoryFunc {
 	return func(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
 		// Creates a http roundTripper.
 		opts, err := client.CreateTransportOptions(ctx, settings, log)
@@ -69,6 +77,7 @@ func newInstanceSettings(httpClientProvider *sdkhttpclient.Provider, log log.Log
 		if err != nil {
 			return nil, err
 		}
+		qd.SetResultCache(resultCache)
 
 		// Resource call management using new custom client same as querydata
 		r, err := resource.New(httpClient, settings, log)
@@ -76,11 +85,27 @@ func newInstanceSettings(httpClientProvider *sdkhttpclient.Provider, log log.Log
 			return nil, err
 		}
 
-		return instance{
-			queryData:    qd,
-			resource:     r,
-			versionCache: cache.New(time.Minute*1, time.Minute*5),
-		}, nil
+		jsonData, err := utils.GetJsonData(settings)
+		if err != nil {
+			return nil, err
+		}
+		prometheusType, _ := maputil.GetStringOptional(jsonData, "prometheusType")
+
+		in := instance{
+			queryData:      qd,
+			resource:       r,
+			versionCache:   cache.New(time.Minute*1, time.Minute*5),
+			prometheusType: prometheusType,
+		}
+
+		// Features the backend does not support are not used, the flavor is only detected when a query needs it
+		supported := func(ctx context.Context) models.Capabilities {
+			return getFlavor(ctx, &in, log.FromContext(ctx)).Capabilities
+		}
+		qd.SetCapabilities(supported)
+		r.SetCapabilities(supported)
+
+		return in, nil
 	}
 }
 
@@ -123,6 +148,51 @@ func (s *Service) CallResource(ctx context.Context, req *backend.CallResourceReq
 		return sender.Send(vResp)
 	}
 
+	if strings.EqualFold(req.Path, "build-info") {
+		body, err := json.Marshal(getFlavor(ctx, i, s.logger.FromContext(ctx)))
+		if err != nil {
+			return err
+		}
+		return sender.Send(&backend.CallResourceResponse{
+			Status:  http.StatusOK,
+			Headers: map[string][]string{"Content-Type": {"application/json"}},
+			Body:    body,
+		})
+	}
+
+	// Validation is done locally with the upstream parser, there is no need to call Prometheus
+	if strings.EqualFold(req.Path, "validate-query") {
+		vResp, err := i.resource.ValidateQuery(req)
+		if err != nil {
+			return err
+		}
+		return sender.Send(vResp)
+	}
+
+	if strings.EqualFold(req.Path, "query-builder") {
+		bResp, err := i.resource.QueryBuilder(req)
+		if err != nil {
+			return err
+		}
+		return sender.Send(bResp)
+	}
+
+	if strings.EqualFold(req.Path, "query-hints") {
+		hResp, err := i.resource.QueryHints(ctx, req)
+		if err != nil {
+			return err
+		}
+		return sender.Send(hResp)
+	}
+
+	if strings.EqualFold(req.Path, "variable-query") {
+		vResp, err := i.resource.VariableQuery(ctx, req)
+		if err != nil {
+			return err
+		}
+		return sender.Send(vResp)
+	}
+
 	resp, err := i.resource.Execute(ctx, req)
 	if err != nil {
 		return err
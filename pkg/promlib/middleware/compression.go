@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The limits of the zstd decoder, so a response cannot make it allocate more memory than the larger
+// results. 8MiB is the window the zstd format recommends decoders to support.
+const (
+	maxZstdWindowSize    = 8 << 20
+	maxZstdDecoderMemory = 64 << 20
+)
+
+// ResponseCompression asks for responses compressed with one of encodings, in order of preference, and
+// decodes them, so large results take less time to transfer. Go only negotiates gzip on its own. The
+// requests setting their own Accept-Encoding header get their responses as they are sent.
+func ResponseCompression(encodings []string) sdkhttpclient.Middleware {
+	acceptEncoding := strings.Join(encodings, ", ")
+	return sdkhttpclient.NamedMiddlewareFunc("response-compression", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Accept-Encoding") != "" {
+				return next.RoundTrip(req)
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+
+			res, err := next.RoundTrip(req)
+			if err != nil || res.Body == nil {
+				return res, err
+			}
+			encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+			if encoding != "gzip" && encoding != "zstd" {
+				return res, nil
+			}
+			res.Body = &decodingBody{body: res.Body, encoding: encoding}
+			res.Header.Del("Content-Encoding")
+			res.Header.Del("Content-Length")
+			res.ContentLength = -1
+			res.Uncompressed = true
+			return res, nil
+		})
+	})
+}
+
+// decodingBody decodes a compressed response body, its decoder is created on the first read as the
+// bodies of some responses are empty even when they are compressed
+type decodingBody struct {
+	body     io.ReadCloser
+	encoding string
+	reader   io.Reader
+	zstd     *zstd.Decoder
+	err      error
+}
+
+func (b *decodingBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		switch b.encoding {
+		case "gzip":
+			b.reader, b.err = gzip.NewReader(b.body)
+		case "zstd":
+			b.zstd, b.err = zstd.NewReader(b.body,
+				zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderMaxWindow(maxZstdWindowSize),
+				zstd.WithDecoderMaxMemory(maxZstdDecoderMemory),
+			)
+			b.reader = b.zstd
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+func (b *decodingBody) Close() error {
+	if b.zstd != nil {
+		b.zstd.Close()
+	}
+	return b.body.Close()
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCompression(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"matrix","result":[]}}`
+	compressed := map[string][]byte{"": []byte(body)}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	compressed["gzip"] = buf.Bytes()
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	compressed["zstd"] = enc.EncodeAll([]byte(body), nil)
+
+	var received http.Header
+	var encoding string
+	finalRoundTripper := sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		received = req.Header
+		header := http.Header{}
+		if encoding != "" {
+			header.Set("Content-Encoding", encoding)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(compressed[encoding]))}, nil
+	})
+	rt := ResponseCompression([]string{"zstd", "gzip"}).CreateMiddleware(sdkhttpclient.Options{}, finalRoundTripper)
+
+	for _, encoding = range []string{"zstd", "gzip", ""} {
+		t.Run("decodes responses encoded with "+encoding, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://test.com/api/v1/query_range", nil)
+			require.NoError(t, err)
+			res, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+			require.Equal(t, "zstd, gzip", received.Get("Accept-Encoding"))
+			require.Empty(t, res.Header.Get("Content-Encoding"))
+
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, body, string(b))
+		})
+	}
+
+	t.Run("requests setting their own encodings get the responses as they are sent", func(t *testing.T) {
+		encoding = "gzip"
+		req, err := http.NewRequest(http.MethodGet, "http://test.com/api/v1/query_range", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, compressed["gzip"], b)
+	})
+
+	t.Run("zstd frames with a window over the limit are an error", func(t *testing.T) {
+		encoding = "zstd"
+		enc, err := zstd.NewWriter(nil, zstd.WithWindowSize(4*maxZstdWindowSize), zstd.WithSingleSegment(false))
+		require.NoError(t, err)
+		compressed["zstd"] = enc.EncodeAll(bytes.Repeat([]byte(body), 2*maxZstdWindowSize/len(body)), nil)
+		req, err := http.NewRequest(http.MethodGet, "http://test.com/api/v1/query_range", nil)
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		_, err = io.ReadAll(res.Body)
+		require.ErrorIs(t, err, zstd.ErrWindowSizeExceeded)
+	})
+
+	t.Run("empty compressed bodies are read", func(t *testing.T) {
+		encoding = "gzip"
+		compressed["gzip"] = nil
+		req, err := http.NewRequest(http.MethodHead, "http://test.com/api/v1/query_range", nil)
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// ErrConcurrencyLimit is returned when a request waited longer than the queue timeout for the
+// requests to the data source in flight to complete
+var ErrConcurrencyLimit = errors.New("too many concurrent requests to the data source")
+
+// ConcurrencyLimit limits the requests in flight to maxConcurrent. Other requests are queued until
+// one of them completes, when its response body is closed, or fail after waiting for queueTimeout.
+// The queued requests of background queries, see WithPriority, get a slot once no other request
+// is queued, so they do not delay the queries of dashboards.
+// The limit is shared by all the round trippers created by the middleware, so it must be created
+// for each data source instance.
+func ConcurrencyLimit(logger log.Logger, maxConcurrent int, queueTimeout time.Duration) sdkhttpclient.Middleware {
+	l := &limiter{max: maxConcurrent}
+
+	return sdkhttpclient.NamedMiddlewareFunc("concurrency-limit", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			background := priorityFromContext(req.Context()) == models.QueryPriorityBackground
+			if slot := l.tryAcquire(background); slot != nil {
+				logger.FromContext(req.Context()).Debug("Queueing request, too many concurrent requests to the data source", "maxConcurrent", maxConcurrent, "background", background)
+				timer := time.NewTimer(queueTimeout)
+				defer timer.Stop()
+				select {
+				case <-slot:
+				case <-timer.C:
+					l.abandon(slot, background)
+					return nil, fmt.Errorf("%w: waited %s for one of %d requests to complete", ErrConcurrencyLimit, queueTimeout, maxConcurrent)
+				case <-req.Context().Done():
+					l.abandon(slot, background)
+					return nil, req.Context().Err()
+				}
+			}
+
+			var once sync.Once
+			release := func() { once.Do(l.release) }
+
+			res, err := next.RoundTrip(req)
+			if err != nil || res == nil || res.Body == nil {
+				release()
+				return res, err
+			}
+			res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+			return res, nil
+		})
+	})
+}
+
+// limiter hands the slots of the requests that complete to the queued requests, the ones of
+// interactive queries first
+type limiter struct {
+	max int
+
+	mtx      sync.Mutex
+	inFlight int
+	// The requests waiting for a slot, in the order they were queued. A slot is handed to a request
+	// by closing its channel.
+	interactive []chan struct{}
+	background  []chan struct{}
+}
+
+// tryAcquire takes a slot, or queues the request and returns the channel closed once it gets one
+func (l *limiter) tryAcquire(background bool) chan struct{} {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.inFlight < l.max {
+		l.inFlight++
+		return nil
+	}
+	slot := make(chan struct{})
+	if background {
+		l.background = append(l.background, slot)
+	} else {
+		l.interactive = append(l.interactive, slot)
+	}
+	return slot
+}
+
+// release hands the slot of a request that completed to the next queued request
+func (l *limiter) release() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for _, queue := range []*[]chan struct{}{&l.interactive, &l.background} {
+		if len(*queue) > 0 {
+			close((*queue)[0])
+			*queue = (*queue)[1:]
+			return
+		}
+	}
+	l.inFlight--
+}
+
+// abandon removes a request that stopped waiting from the queue, and releases its slot when it got
+// one in the meantime
+func (l *limiter) abandon(slot chan struct{}, background bool) {
+	l.mtx.Lock()
+	queue := &l.interactive
+	if background {
+		queue = &l.background
+	}
+	if i := slices.Index(*queue, slot); i >= 0 {
+		*queue = slices.Delete(*queue, i, i+1)
+		l.mtx.Unlock()
+		return
+	}
+	l.mtx.Unlock()
+	l.release()
+}
+
+// releasingBody releases the slot of its request when closed, as the data source is still
+// sending the response until then
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
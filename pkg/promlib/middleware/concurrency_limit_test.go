@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+	newRoundTripper := func(mw httpclient.Middleware) http.RoundTripper {
+		return mw.CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+	}
+	roundTrip := func(t *testing.T, rt http.RoundTripper, ctx context.Context) (*http.Response, error) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		return rt.RoundTrip(req)
+	}
+
+	t.Run("Name should be correct", func(t *testing.T) {
+		mw := ConcurrencyLimit(backend.NewLoggerWith("logger", "test"), 1, time.Second)
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, "concurrency-limit", middlewareName.MiddlewareName())
+	})
+
+	t.Run("Should queue requests until a response body is closed", func(t *testing.T) {
+		mw := ConcurrencyLimit(backend.NewLoggerWith("logger", "test"), 1, time.Minute)
+		// The limit is shared by the round trippers of the instance
+		first, second := newRoundTripper(mw), newRoundTripper(mw)
+
+		res, err := roundTrip(t, first, context.Background())
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			res, err := roundTrip(t, second, context.Background())
+			if err == nil {
+				_ = res.Body.Close()
+			}
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("request was not queued")
+		case <-time.After(50 * time.Millisecond):
+		}
+		require.NoError(t, res.Body.Close())
+		// Closing twice must not release another slot
+		require.NoError(t, res.Body.Close())
+		<-done
+	})
+
+	t.Run("Should give the slots to interactive queries before background ones", func(t *testing.T) {
+		mw := ConcurrencyLimit(backend.NewLoggerWith("logger", "test"), 1, time.Minute)
+		rt := newRoundTripper(mw)
+
+		res, err := roundTrip(t, rt, context.Background())
+		require.NoError(t, err)
+
+		order := make(chan models.QueryPriority, 2)
+		queue := func(priority models.QueryPriority) {
+			go func() {
+				res, err := roundTrip(t, rt, WithPriority(context.Background(), priority))
+				if err == nil {
+					order <- priority
+					_ = res.Body.Close()
+				}
+			}()
+		}
+		// The background query is queued first
+		queue(models.QueryPriorityBackground)
+		time.Sleep(20 * time.Millisecond)
+		queue(models.QueryPriorityInteractive)
+		time.Sleep(20 * time.Millisecond)
+
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, models.QueryPriorityInteractive, <-order)
+		require.Equal(t, models.QueryPriorityBackground, <-order)
+	})
+
+	t.Run("Should fail after waiting for the queue timeout", func(t *testing.T) {
+		mw := ConcurrencyLimit(backend.NewLoggerWith("logger", "test"), 1, 10*time.Millisecond)
+		rt := newRoundTripper(mw)
+
+		res, err := roundTrip(t, rt, context.Background())
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+
+		_, err = roundTrip(t, rt, context.Background())
+		require.ErrorIs(t, err, ErrConcurrencyLimit)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = roundTrip(t, rt, ctx)
+		require.ErrorIs(t, err, context.Canceled)
+
+		// The requests that stopped waiting left the queue, so the slot is free once released
+		require.NoError(t, res.Body.Close())
+		res, err = roundTrip(t, rt, context.Background())
+		require.NoError(t, err)
+	})
+}
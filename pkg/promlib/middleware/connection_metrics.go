@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+
+	"github.com/grafana/grafana/pkg/promlib/instrumentation"
+)
+
+// ConnectionMetrics counts the connections the requests of the data source with uid are sent on, reused
+// or new, and the TLS handshakes of the new ones, resumed or full, see instrumentation.IncConnections.
+// Every attempt of a request is counted.
+func ConnectionMetrics(uid string) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("connection-metrics", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			trace := &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					instrumentation.IncConnections(uid, info.Reused)
+				},
+				TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+					if err == nil {
+						instrumentation.IncTLSHandshakes(uid, state.DidResume)
+					}
+				},
+			}
+			return next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionMetrics(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+	rt := ConnectionMetrics("connection-metrics-uid").CreateMiddleware(sdkhttpclient.Options{}, transport)
+
+	send := func() {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+
+	const connections, handshakes = "grafana_prometheus_plugin_connections_total", "grafana_prometheus_plugin_tls_handshakes_total"
+	newConnections := counterValue(t, connections, "connection-metrics-uid", "false")
+	reusedConnections := counterValue(t, connections, "connection-metrics-uid", "true")
+	fullHandshakes := counterValue(t, handshakes, "connection-metrics-uid", "false")
+	resumedHandshakes := counterValue(t, handshakes, "connection-metrics-uid", "true")
+
+	send()
+	send()
+	require.Equal(t, newConnections+1, counterValue(t, connections, "connection-metrics-uid", "false"))
+	require.Equal(t, reusedConnections+1, counterValue(t, connections, "connection-metrics-uid", "true"))
+	require.Equal(t, fullHandshakes+1, counterValue(t, handshakes, "connection-metrics-uid", "false"))
+
+	// The session of the closed connection is resumed by the next one
+	transport.CloseIdleConnections()
+	send()
+	require.Equal(t, newConnections+2, counterValue(t, connections, "connection-metrics-uid", "false"))
+	require.Equal(t, resumedHandshakes+1, counterValue(t, handshakes, "connection-metrics-uid", "true"))
+}
+
+// counterValue returns the value of the counter name of the default registry with the datasource label
+// uid and value as its other label
+func counterValue(t *testing.T, name, uid, value string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["datasource"] == uid && (labels["reused"] == value || labels["resumed"] == value) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+)
+
+// DefaultHeader sets the header name to values on the requests that do not set it. It replaces the custom
+// header of a data source that queries can override, as custom headers replace the ones of requests.
+func DefaultHeader(name string, values []string) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("default-header", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(name) == "" {
+				req = req.Clone(req.Context())
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	})
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultHeader(t *testing.T) {
+	var received http.Header
+	finalRoundTripper := sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		received = req.Header
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	rt := DefaultHeader("X-Scope-OrgID", []string{"default"}).CreateMiddleware(sdkhttpclient.Options{}, finalRoundTripper)
+
+	req, err := http.NewRequest(http.MethodGet, "http://test.com/api/v1/query", nil)
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, "default", received.Get("X-Scope-OrgID"))
+	// The request of the caller is not changed
+	require.Empty(t, req.Header.Get("X-Scope-OrgID"))
+
+	req.Header.Set("X-Scope-OrgID", "team-a|team-b")
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"team-a|team-b"}, received.Values("X-Scope-OrgID"))
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+type priorityKey struct{}
+
+// WithPriority returns ctx for the requests of a query with priority
+func WithPriority(ctx context.Context, priority models.QueryPriority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// priorityFromContext returns the priority of the query of a request, empty when it is not the
+// request of a query
+func priorityFromContext(ctx context.Context) models.QueryPriority {
+	priority, _ := ctx.Value(priorityKey{}).(models.QueryPriority)
+	return priority
+}
+
+// PriorityHeader sets the header name to the priority of the query of requests, see WithPriority, so
+// the query frontends scheduling queries by their priority, or QoS class, can tell background queries
+// apart. The requests that are not the ones of queries are sent without it.
+func PriorityHeader(name string) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("priority-header", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if priority := priorityFromContext(req.Context()); priority != "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(name, string(priority))
+			}
+			return next.RoundTrip(req)
+		})
+	})
+}
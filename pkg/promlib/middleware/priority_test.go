@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestPriorityHeader(t *testing.T) {
+	var received http.Header
+	finalRoundTripper := sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		received = req.Header
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	rt := PriorityHeader("X-Query-Priority").CreateMiddleware(sdkhttpclient.Options{}, finalRoundTripper)
+
+	req, err := http.NewRequestWithContext(WithPriority(context.Background(), models.QueryPriorityBackground), http.MethodGet, "http://test.com/api/v1/query", nil)
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, "background", received.Get("X-Query-Priority"))
+	// The request of the caller is not changed
+	require.Empty(t, req.Header.Get("X-Query-Priority"))
+
+	// The requests of resources have no priority
+	req, err = http.NewRequest(http.MethodGet, "http://test.com/api/v1/labels", nil)
+	require.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Empty(t, received.Get("X-Query-Priority"))
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// maxRetryBackoff bounds the wait between two attempts, including the one asked by Retry-After
+const maxRetryBackoff = 10 * time.Second
+
+// readOnlyPostPaths are the endpoints of the Prometheus API that are read with POST requests,
+// which can be retried like GET ones. Other POST requests, like the ones of the ruler API, are not.
+var readOnlyPostPaths = []string{
+	"/api/v1/query",
+	"/api/v1/query_range",
+	"/api/v1/query_exemplars",
+	"/api/v1/series",
+	"/api/v1/labels",
+	"/api/v1/read",
+	"/api/v1/format_query",
+	"/api/v1/parse_query",
+}
+
+// Retry retries requests up to maxRetries times when they fail because of a transient error of the
+// data source or a gateway in front of it: a 502, 503 or 504 response, or a reset connection. The wait
+// before each retry doubles from backoff, with jitter, and a Retry-After header is honored.
+// Only requests that do not change anything, and whose body can be sent again, are retried.
+func Retry(logger log.Logger, maxRetries int, backoff time.Duration) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("retry", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !retryable(req) {
+				return next.RoundTrip(req)
+			}
+
+			for attempt := 0; ; attempt++ {
+				res, err := next.RoundTrip(req)
+				if attempt == maxRetries || !transient(res, err) {
+					return res, err
+				}
+
+				wait := retryBackoff(backoff, attempt, res)
+				logger.FromContext(req.Context()).Debug("Retrying request after a transient error", "attempt", attempt+1, "wait", wait, "status", statusCode(res), "err", err)
+				if res != nil {
+					// Drained so the connection can be reused
+					_, _ = io.Copy(io.Discard, res.Body)
+					_ = res.Body.Close()
+				}
+
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-req.Context().Done():
+					timer.Stop()
+					return nil, req.Context().Err()
+				}
+
+				if req, err = rewind(req); err != nil {
+					return nil, err
+				}
+			}
+		})
+	})
+}
+
+// retryable returns whether req can be sent again without side effects
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		for _, path := range readOnlyPostPaths {
+			if strings.HasSuffix(req.URL.Path, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// transient returns whether the result of a request is an error that may not happen again
+func transient(res *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET)
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryBackoff returns how long to wait before retrying after a number of attempts
+func retryBackoff(backoff time.Duration, attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxRetryBackoff)
+		}
+	}
+	wait := backoff
+	for i := 0; i < attempt && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, maxRetryBackoff)
+	// Full jitter, so the requests of all the panels of a dashboard are not retried at once
+	return time.Duration(rand.Int63n(int64(wait) + 1))
+}
+
+// rewind returns a copy of req whose body can be read again
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+func statusCode(res *http.Response) int {
+	if res == nil {
+		return 0
+	}
+	return res.StatusCode
+}
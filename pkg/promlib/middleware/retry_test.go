@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	// newRoundTripper returns a round tripper answering with the results in order, and the bodies it received
+	newRoundTripper := func(maxRetries int, results ...any) (http.RoundTripper, *[]string) {
+		var bodies []string
+		final := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body := ""
+			if req.Body != nil {
+				b, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+				body = string(b)
+			}
+			bodies = append(bodies, body)
+			result := results[0]
+			results = results[1:]
+			if err, ok := result.(error); ok {
+				return nil, err
+			}
+			return &http.Response{StatusCode: result.(int), Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+		})
+		mw := Retry(backend.NewLoggerWith("logger", "test"), maxRetries, time.Millisecond)
+		return mw.CreateMiddleware(httpclient.Options{}, final), &bodies
+	}
+
+	t.Run("Name should be correct", func(t *testing.T) {
+		mw := Retry(backend.NewLoggerWith("logger", "test"), 1, time.Millisecond)
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, "retry", middlewareName.MiddlewareName())
+	})
+
+	t.Run("Should retry transient errors of queries with their body", func(t *testing.T) {
+		rt, bodies := newRoundTripper(3, http.StatusBadGateway, fmt.Errorf("read: %w", syscall.ECONNRESET), http.StatusOK)
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/prometheus/api/v1/query_range", strings.NewReader("query=up"))
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, []string{"query=up", "query=up", "query=up"}, *bodies)
+	})
+
+	t.Run("Should stop after the maximum number of retries", func(t *testing.T) {
+		rt, bodies := newRoundTripper(2, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusServiceUnavailable)
+		req, err := http.NewRequest(http.MethodGet, "http://example.com/api/v1/labels", nil)
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		require.Len(t, *bodies, 3)
+	})
+
+	t.Run("Should not retry other errors", func(t *testing.T) {
+		rt, bodies := newRoundTripper(2, http.StatusInternalServerError)
+		req, err := http.NewRequest(http.MethodGet, "http://example.com/api/v1/query", nil)
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		require.Len(t, *bodies, 1)
+	})
+
+	t.Run("Should not retry requests with side effects", func(t *testing.T) {
+		rt, bodies := newRoundTripper(2, http.StatusBadGateway)
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/config/v1/rules/namespace", strings.NewReader("name: group"))
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, res.StatusCode)
+		require.Len(t, *bodies, 1)
+
+		// The body of the request could not be sent again
+		rt, bodies = newRoundTripper(2, http.StatusBadGateway)
+		req, err = http.NewRequest(http.MethodPost, "http://example.com/api/v1/query", io.NopCloser(strings.NewReader("query=up")))
+		require.NoError(t, err)
+		res, err = rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, res.StatusCode)
+		require.Len(t, *bodies, 1)
+	})
+
+	t.Run("Should honor Retry-After within bounds", func(t *testing.T) {
+		res := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+		require.Equal(t, 2*time.Second, retryBackoff(time.Millisecond, 0, res))
+		res.Header.Set("Retry-After", "3600")
+		require.Equal(t, maxRetryBackoff, retryBackoff(time.Millisecond, 0, res))
+		require.LessOrEqual(t, retryBackoff(time.Second, 100, nil), maxRetryBackoff)
+	})
+}
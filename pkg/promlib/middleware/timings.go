@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+
+	"github.com/grafana/grafana/pkg/promlib/utils"
+)
+
+// Timings records the queue, connect and first byte phases of the requests sent with a context
+// carrying utils.QueryTimings. It must be the first middleware, so the wait of the other ones is
+// part of the queue phase. The connect and first byte phases of all the attempts of a request are recorded.
+func Timings() sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("timings", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			timings := utils.QueryTimingsFromContext(req.Context())
+			if timings == nil {
+				return next.RoundTrip(req)
+			}
+
+			// The hooks of the response are called by another goroutine
+			var mu sync.Mutex
+			start := time.Now()
+			var getConn, gotConn time.Time
+			trace := &httptrace.ClientTrace{
+				GetConn: func(string) {
+					mu.Lock()
+					defer mu.Unlock()
+					now := time.Now()
+					if getConn.IsZero() {
+						timings.Add(utils.TimingPhaseQueue, now.Sub(start))
+					}
+					getConn = now
+				},
+				GotConn: func(httptrace.GotConnInfo) {
+					mu.Lock()
+					defer mu.Unlock()
+					gotConn = time.Now()
+					if !getConn.IsZero() {
+						timings.Add(utils.TimingPhaseConnect, gotConn.Sub(getConn))
+					}
+				},
+				GotFirstResponseByte: func() {
+					mu.Lock()
+					defer mu.Unlock()
+					if !gotConn.IsZero() {
+						timings.Add(utils.TimingPhaseFirstByte, time.Since(gotConn))
+					}
+				},
+			}
+			return next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/utils"
+)
+
+func TestTimings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	// A middleware after the timings one waits before the request is sent
+	wait := sdkhttpclient.NamedMiddlewareFunc("wait", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			time.Sleep(10 * time.Millisecond)
+			return next.RoundTrip(req)
+		})
+	})
+	client, err := sdkhttpclient.New(sdkhttpclient.Options{Middlewares: []sdkhttpclient.Middleware{Timings(), wait}})
+	require.NoError(t, err)
+
+	timings := utils.NewQueryTimings()
+	req, err := http.NewRequestWithContext(utils.WithQueryTimings(context.Background(), timings), http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	ms := timings.Milliseconds()
+	require.GreaterOrEqual(t, ms["queue"], 10.0)
+	require.GreaterOrEqual(t, ms["firstByte"], 20.0)
+	require.Contains(t, ms, "connect")
+	require.Zero(t, ms["decode"])
+
+	t.Run("requests without timings are sent as they are", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+}
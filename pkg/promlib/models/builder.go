@@ -0,0 +1,371 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PromVisualQuery is a query of the visual query builder: a selector on which operations are applied in order,
+// optionally combined with other builder queries by binary operators
+type PromVisualQuery struct {
+	// The name of the selected metric, empty to select series by their labels only
+	Metric string `json:"metric"`
+
+	// The label matchers of the selector
+	Labels []QueryBuilderLabelFilter `json:"labels"`
+
+	// The operations applied to the selector, the first one is applied first
+	Operations []QueryBuilderOperation `json:"operations"`
+
+	// The queries the result is combined with, in order
+	BinaryQueries []PromVisualQueryBinary `json:"binaryQueries,omitempty"`
+}
+
+// QueryBuilderLabelFilter is a label matcher of the selector of a builder query
+type QueryBuilderLabelFilter struct {
+	Label string `json:"label"`
+	// One of =, !=, =~ or !~
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// QueryBuilderOperation is an operation of a builder query, like a function, an aggregation or a binary
+// operation with a scalar. Params are strings, numbers or booleans depending on the operation.
+type QueryBuilderOperation struct {
+	ID     string `json:"id"`
+	Params []any  `json:"params"`
+}
+
+// PromVisualQueryBinary combines a builder query with another one
+type PromVisualQueryBinary struct {
+	// The binary operator, e.g. / or and
+	Operator string `json:"operator"`
+
+	// The vector matching keyword, on or ignoring
+	VectorMatchesType string `json:"vectorMatchesType,omitempty"`
+
+	// The comma separated labels of the vector matching
+	VectorMatches string `json:"vectorMatches,omitempty"`
+
+	Query PromVisualQuery `json:"query"`
+}
+
+type paramType int
+
+const (
+	paramString paramType = iota
+	paramNumber
+	paramBool
+	// A label name, rendered unquoted
+	paramLabel
+	// A range selector duration, e.g. 5m or $__rate_interval
+	paramRange
+)
+
+// operationKind defines where an operation renders its params and inner expression
+type operationKind int
+
+const (
+	// The params before the inner expression, e.g. histogram_quantile(0.9, x)
+	kindFunctionLeft operationKind = iota
+	// The params after the inner expression, e.g. clamp_min(x, 0)
+	kindFunctionRight
+	// The inner expression as a range vector before the other params, e.g. rate(x[5m])
+	kindRangeRight
+	// The inner expression as a range vector after the other params, e.g. quantile_over_time(0.5, x[5m])
+	kindRangeLeft
+	// An aggregation by or without labels, e.g. topk by(job) (5, x)
+	kindAggregation
+	// A binary operation with a scalar, e.g. x > bool 0
+	kindScalar
+	// A function ignoring the inner expression, e.g. time()
+	kindConstant
+	// The inner expression as is, the query is combined by its binary queries
+	kindNestedQuery
+)
+
+// operationDef defines an operation of the query builder
+type operationDef struct {
+	kind   operationKind
+	params []paramType
+	// The last param may be repeated, or left out
+	restParam bool
+	// The number of trailing params that can be left out
+	optional int
+
+	// The function of aggregations and the grouping keyword, by or without
+	function, grouping string
+	// The operator of binary operations with a scalar
+	operator string
+}
+
+var (
+	binaryOperators     = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true, "^": true, "==": true, "!=": true, ">": true, "<": true, ">=": true, "<=": true, "and": true, "or": true, "unless": true}
+	comparisonOperators = map[string]bool{"==": true, "!=": true, ">": true, "<": true, ">=": true, "<=": true}
+	labelMatchOperators = map[string]bool{"=": true, "!=": true, "=~": true, "!~": true}
+	// A label name, or a Grafana variable holding label names
+	labelNameRegexp = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*|\$\w+|\$\{\w+(:\w+)?\})$`)
+)
+
+// scalarOperations are the ids of the binary operations with a scalar, by operator
+var scalarOperations = map[string]string{
+	"+":  "__addition",
+	"-":  "__subtraction",
+	"*":  "__multiply_by",
+	"/":  "__divide_by",
+	"%":  "__modulo",
+	"^":  "__exponent",
+	"==": "__equal_to",
+	"!=": "__not_equal_to",
+	">":  "__greater_than",
+	"<":  "__less_than",
+	">=": "__greater_or_equal",
+	"<=": "__less_or_equal",
+}
+
+// builderOperations are the operations of the query builder, by id
+var builderOperations = func() map[string]operationDef {
+	ops := map[string]operationDef{
+		"histogram_quantile": {kind: kindFunctionLeft, params: []paramType{paramNumber}},
+		"histogram_fraction": {kind: kindFunctionLeft, params: []paramType{paramNumber, paramNumber}},
+		"quantile":           {kind: kindFunctionLeft, params: []paramType{paramNumber}},
+		"label_replace":      {kind: kindFunctionRight, params: []paramType{paramString, paramString, paramString, paramString}},
+		"label_join":         {kind: kindFunctionRight, params: []paramType{paramString, paramString, paramString}, restParam: true, optional: 1},
+		"clamp":              {kind: kindFunctionRight, params: []paramType{paramNumber, paramNumber}},
+		"clamp_max":          {kind: kindFunctionRight, params: []paramType{paramNumber}},
+		"clamp_min":          {kind: kindFunctionRight, params: []paramType{paramNumber}},
+		"round":              {kind: kindFunctionRight, params: []paramType{paramNumber}, optional: 1},
+		"pi":                 {kind: kindConstant},
+		"time":               {kind: kindConstant},
+		"vector":             {kind: kindConstant, params: []paramType{paramNumber}},
+		"holt_winters":       {kind: kindRangeRight, params: []paramType{paramRange, paramNumber, paramNumber}},
+		"predict_linear":     {kind: kindRangeRight, params: []paramType{paramRange, paramNumber}},
+		"quantile_over_time": {kind: kindRangeLeft, params: []paramType{paramRange, paramNumber}},
+		"__nested_query":     {kind: kindNestedQuery},
+	}
+
+	for _, id := range []string{
+		"abs", "absent", "acos", "acosh", "asin", "asinh", "atan", "atanh", "ceil", "cos", "cosh", "day_of_month",
+		"day_of_week", "day_of_year", "days_in_month", "deg", "exp", "floor", "group", "histogram_avg", "histogram_count",
+		"histogram_stddev", "histogram_stdvar", "histogram_sum", "hour", "ln", "log10", "log2", "minute", "month", "rad",
+		"scalar", "sgn", "sin", "sinh", "sort", "sort_desc", "sqrt", "stddev", "tan", "tanh", "timestamp", "year",
+	} {
+		ops[id] = operationDef{kind: kindFunctionLeft}
+	}
+
+	for _, id := range []string{
+		"rate", "irate", "increase", "delta", "idelta", "deriv", "changes", "resets", "sum_over_time", "avg_over_time",
+		"min_over_time", "max_over_time", "count_over_time", "last_over_time", "present_over_time", "absent_over_time",
+		"stddev_over_time",
+	} {
+		ops[id] = operationDef{kind: kindRangeRight, params: []paramType{paramRange}}
+	}
+
+	// Aggregations have a variant grouping by labels and one grouping without labels
+	aggregations := map[string][]paramType{"sum": nil, "avg": nil, "min": nil, "max": nil, "count": nil,
+		"topk": {paramNumber}, "bottomk": {paramNumber}, "count_values": {paramString}}
+	for function, params := range aggregations {
+		ops[function] = operationDef{kind: kindFunctionLeft, params: params}
+		for _, grouping := range []string{"by", "without"} {
+			ops["__"+function+"_"+grouping] = operationDef{
+				kind:      kindAggregation,
+				params:    append(append([]paramType{}, params...), paramLabel),
+				restParam: true,
+				optional:  1,
+				function:  function,
+				grouping:  grouping,
+			}
+		}
+	}
+
+	for op, id := range scalarOperations {
+		params := []paramType{paramNumber}
+		if comparisonOperators[op] {
+			params = append(params, paramBool)
+		}
+		ops[id] = operationDef{kind: kindScalar, params: params, optional: len(params) - 1, operator: op}
+	}
+	return ops
+}()
+
+// Render returns the PromQL expression of the query, like the query builder renders it.
+// An error is returned when an operation is unknown or has invalid params.
+func (q PromVisualQuery) Render() (string, error) {
+	return q.render(false)
+}
+
+func (q PromVisualQuery) render(nested bool) (string, error) {
+	if err := q.validateSelector(); err != nil {
+		return "", err
+	}
+	expr := q.Metric + renderLabels(q.Labels)
+
+	hasBinaryOp := false
+	for i, op := range q.Operations {
+		def, ok := builderOperations[op.ID]
+		if !ok {
+			return "", fmt.Errorf("operation %d: unknown operation %q", i+1, op.ID)
+		}
+		params, err := def.renderParams(op.Params)
+		if err != nil {
+			return "", fmt.Errorf("operation %d (%s): %w", i+1, op.ID, err)
+		}
+		expr = def.render(op.ID, params, expr)
+		hasBinaryOp = hasBinaryOp || def.kind == kindScalar || def.kind == kindNestedQuery
+	}
+
+	if !nested && hasBinaryOp && len(q.BinaryQueries) > 0 {
+		expr = "(" + expr + ")"
+	}
+	for i, bq := range q.BinaryQueries {
+		rendered, err := bq.render(expr)
+		if err != nil {
+			return "", fmt.Errorf("binary query %d: %w", i+1, err)
+		}
+		expr = rendered
+	}
+	if nested && (hasBinaryOp || len(q.BinaryQueries) > 0) {
+		expr = "(" + expr + ")"
+	}
+	return expr, nil
+}
+
+func (q PromVisualQuery) validateSelector() error {
+	// Operations like time() or vector(1) do not apply to a selector
+	if q.Metric == "" && len(q.Labels) == 0 && (len(q.Operations) == 0 || builderOperations[q.Operations[0].ID].kind != kindConstant) {
+		return fmt.Errorf("a metric or a label matcher is required")
+	}
+	for _, l := range q.Labels {
+		if !labelNameRegexp.MatchString(l.Label) {
+			return fmt.Errorf("invalid label name %q", l.Label)
+		}
+		if !labelMatchOperators[l.Op] {
+			return fmt.Errorf("invalid operator %q for label %q", l.Op, l.Label)
+		}
+	}
+	return nil
+}
+
+func (bq PromVisualQueryBinary) render(left string) (string, error) {
+	if !binaryOperators[bq.Operator] {
+		return "", fmt.Errorf("unknown binary operator %q", bq.Operator)
+	}
+	expr := left + " " + bq.Operator + " "
+	if bq.VectorMatches != "" {
+		if bq.VectorMatchesType != "on" && bq.VectorMatchesType != "ignoring" {
+			return "", fmt.Errorf("invalid vector matching %q, expected on or ignoring", bq.VectorMatchesType)
+		}
+		expr += bq.VectorMatchesType + "(" + bq.VectorMatches + ") "
+	}
+	right, err := bq.Query.render(true)
+	if err != nil {
+		return "", err
+	}
+	return expr + right, nil
+}
+
+// renderParams checks the params of an operation against its definition and formats them as PromQL
+func (def operationDef) renderParams(params []any) ([]string, error) {
+	if len(params) < len(def.params)-def.optional || (!def.restParam && len(params) > len(def.params)) {
+		return nil, fmt.Errorf("expected %d params, got %d", len(def.params), len(params))
+	}
+
+	rendered := make([]string, 0, len(params))
+	for i, p := range params {
+		t := def.params[min(i, len(def.params)-1)]
+		s, err := renderParam(t, p)
+		if err != nil {
+			return nil, fmt.Errorf("param %d: %w", i+1, err)
+		}
+		rendered = append(rendered, s)
+	}
+	return rendered, nil
+}
+
+func renderParam(t paramType, p any) (string, error) {
+	switch t {
+	case paramString:
+		if s, ok := p.(string); ok {
+			return strconv.Quote(s), nil
+		}
+		return "", fmt.Errorf("expected a string, got %v", p)
+	case paramNumber:
+		switch v := p.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case string:
+			// Numbers typed in the builder may be kept as strings, or be variables
+			if _, err := strconv.ParseFloat(v, 64); err == nil || strings.HasPrefix(v, "$") {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("expected a number, got %v", p)
+	case paramBool:
+		if b, ok := p.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+		return "", fmt.Errorf("expected a boolean, got %v", p)
+	case paramLabel:
+		if s, ok := p.(string); ok && (s == "" || labelNameRegexp.MatchString(s)) {
+			return s, nil
+		}
+		return "", fmt.Errorf("invalid label name %v", p)
+	case paramRange:
+		if s, ok := p.(string); ok && s != "" {
+			return s, nil
+		}
+		return "", fmt.Errorf("expected a range duration, got %v", p)
+	}
+	return "", fmt.Errorf("unknown param type %d", t)
+}
+
+// renderLabels renders the label matchers of a selector, the values are escaped as PromQL strings
+func renderLabels(labels []QueryBuilderLabelFilter) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	matchers := make([]string, 0, len(labels))
+	for _, l := range labels {
+		matchers = append(matchers, l.Label+l.Op+strconv.Quote(l.Value))
+	}
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// render renders the operation with its params, formatted as PromQL, applied to the inner expression
+func (def operationDef) render(id string, params []string, inner string) string {
+	switch def.kind {
+	case kindFunctionRight:
+		return renderFunction(id, append([]string{inner}, params...))
+	case kindRangeRight:
+		return renderFunction(id, append([]string{inner + "[" + params[0] + "]"}, params[1:]...))
+	case kindRangeLeft:
+		return renderFunction(id, append(params[1:len(params):len(params)], inner+"["+params[0]+"]"))
+	case kindAggregation:
+		paramCount := len(def.params) - 1
+		labels := make([]string, 0, len(params))
+		for _, p := range params[min(paramCount, len(params)):] {
+			// The builder adds an empty label when the grouping is selected
+			if p != "" {
+				labels = append(labels, p)
+			}
+		}
+		args := append(params[:min(paramCount, len(params)):min(paramCount, len(params))], inner)
+		return fmt.Sprintf("%s %s(%s) (%s)", def.function, def.grouping, strings.Join(labels, ", "), strings.Join(args, ", "))
+	case kindScalar:
+		if len(params) == 2 && params[1] == "true" {
+			return inner + " " + def.operator + " bool " + params[0]
+		}
+		return inner + " " + def.operator + " " + params[0]
+	case kindConstant:
+		return renderFunction(id, params)
+	case kindNestedQuery:
+		return inner
+	}
+	return renderFunction(id, append(params, inner))
+}
+
+func renderFunction(id string, args []string) string {
+	return id + "(" + strings.Join(args, ", ") + ")"
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// rangeVariableRegexp matches the Grafana variables used as the range of a range selector, e.g. [$__rate_interval]
+var rangeVariableRegexp = regexp.MustCompile(`\[(\$\w+|\$\{\w+\})\]`)
+
+// binaryPrecedence is the precedence of the binary operators of PromQL, higher binds tighter
+var binaryPrecedence = map[string]int{
+	"or": 1, "and": 2, "unless": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+	"^": 6,
+}
+
+// ReplaceRangeVariables replaces the Grafana variables used as the range of range selectors in expr by durations,
+// so the expression can be parsed. The durations have the length of the variables they replace where possible, so
+// positions in the expression do not change. It returns the expression and the variables by duration.
+func ReplaceRangeVariables(expr string) (string, map[time.Duration]string) {
+	variables := map[time.Duration]string{}
+	durations := map[string]string{}
+	replaced := rangeVariableRegexp.ReplaceAllStringFunc(expr, func(m string) string {
+		variable := m[1 : len(m)-1]
+		if d, ok := durations[variable]; ok {
+			return "[" + d + "]"
+		}
+		// Durations no one would write by hand, padded with zeros to the length of the variable
+		ms := 9999000 + len(durations)
+		d := fmt.Sprintf("%0*dms", len(variable)-2, ms)
+		durations[variable] = d
+		variables[time.Duration(ms)*time.Millisecond] = variable
+		return "[" + d + "]"
+	})
+	return replaced, variables
+}
+
+// ParseVisualQuery returns the query builder query of a PromQL expression, the reverse of PromVisualQuery.Render.
+// Range selectors may use Grafana variables, e.g. rate(x[$__rate_interval]). An error is returned when the
+// expression cannot be represented in the query builder.
+func ParseVisualQuery(expr string) (PromVisualQuery, error) {
+	replaced, variables := ReplaceRangeVariables(expr)
+	parsed, err := parser.ParseExpr(replaced)
+	if err != nil {
+		return PromVisualQuery{}, err
+	}
+	return visualQueryParser{variables: variables}.query(parsed)
+}
+
+type visualQueryParser struct {
+	variables map[time.Duration]string
+}
+
+func (p visualQueryParser) query(expr parser.Expr) (PromVisualQuery, error) {
+	switch e := expr.(type) {
+	case *parser.ParenExpr:
+		return p.query(e.Expr)
+	case *parser.VectorSelector:
+		return selectorQuery(e)
+	case *parser.Call:
+		return p.call(e)
+	case *parser.AggregateExpr:
+		return p.aggregation(e)
+	case *parser.BinaryExpr:
+		return p.binary(e)
+	}
+	return PromVisualQuery{}, fmt.Errorf("%s is not supported by the query builder", expr.String())
+}
+
+func selectorQuery(vs *parser.VectorSelector) (PromVisualQuery, error) {
+	if vs.OriginalOffset != 0 || vs.Timestamp != nil || vs.StartOrEnd != 0 {
+		return PromVisualQuery{}, fmt.Errorf("offset and @ modifiers are not supported by the query builder")
+	}
+	q := PromVisualQuery{Metric: vs.Name, Labels: []QueryBuilderLabelFilter{}, Operations: []QueryBuilderOperation{}}
+	for _, m := range vs.LabelMatchers {
+		if vs.Name != "" && m.Name == model.MetricNameLabel {
+			continue
+		}
+		q.Labels = append(q.Labels, QueryBuilderLabelFilter{Label: m.Name, Op: m.Type.String(), Value: m.Value})
+	}
+	return q, nil
+}
+
+func (p visualQueryParser) call(call *parser.Call) (PromVisualQuery, error) {
+	id := call.Func.Name
+	def, ok := builderOperations[id]
+	if !ok || (def.kind != kindFunctionLeft && def.kind != kindFunctionRight && def.kind != kindRangeLeft &&
+		def.kind != kindRangeRight && def.kind != kindConstant) {
+		return PromVisualQuery{}, fmt.Errorf("function %s is not supported by the query builder", id)
+	}
+
+	args := call.Args
+	if def.kind == kindConstant {
+		params, err := literalParams(args)
+		if err != nil {
+			return PromVisualQuery{}, err
+		}
+		return PromVisualQuery{Labels: []QueryBuilderLabelFilter{}, Operations: []QueryBuilderOperation{{ID: id, Params: params}}}, nil
+	}
+	if len(args) == 0 {
+		return PromVisualQuery{}, fmt.Errorf("function %s has no arguments", id)
+	}
+
+	var inner parser.Expr
+	switch def.kind {
+	case kindFunctionLeft, kindRangeLeft:
+		inner, args = args[len(args)-1], args[:len(args)-1]
+	default:
+		inner, args = args[0], args[1:]
+	}
+	params, err := literalParams(args)
+	if err != nil {
+		return PromVisualQuery{}, err
+	}
+
+	if def.kind == kindFunctionLeft || def.kind == kindFunctionRight {
+		return p.withOperation(inner, QueryBuilderOperation{ID: id, Params: params})
+	}
+
+	// The range selector of range functions is rendered by the function itself
+	ms, ok := inner.(*parser.MatrixSelector)
+	if !ok {
+		return PromVisualQuery{}, fmt.Errorf("only range selectors are supported as the argument of %s in the query builder", id)
+	}
+	vs, ok := ms.VectorSelector.(*parser.VectorSelector)
+	if !ok {
+		return PromVisualQuery{}, fmt.Errorf("%s is not supported by the query builder", ms.String())
+	}
+	q, err := selectorQuery(vs)
+	if err != nil {
+		return PromVisualQuery{}, err
+	}
+	q.Operations = append(q.Operations, QueryBuilderOperation{ID: id, Params: append([]any{p.rangeParam(ms.Range)}, params...)})
+	return q, nil
+}
+
+func (p visualQueryParser) aggregation(agg *parser.AggregateExpr) (PromVisualQuery, error) {
+	id := agg.Op.String()
+	var params []any
+	if agg.Param != nil {
+		param, err := literalParam(agg.Param)
+		if err != nil {
+			return PromVisualQuery{}, err
+		}
+		params = append(params, param)
+	}
+
+	switch {
+	case agg.Without:
+		id = "__" + id + "_without"
+	case len(agg.Grouping) > 0:
+		id = "__" + id + "_by"
+	}
+	if _, ok := builderOperations[id]; !ok {
+		return PromVisualQuery{}, fmt.Errorf("%s is not supported by the query builder", id)
+	}
+	for _, label := range agg.Grouping {
+		params = append(params, label)
+	}
+	if params == nil {
+		params = []any{}
+	}
+	return p.withOperation(agg.Expr, QueryBuilderOperation{ID: id, Params: params})
+}
+
+func (p visualQueryParser) binary(b *parser.BinaryExpr) (PromVisualQuery, error) {
+	op := b.Op.String()
+
+	// A binary operation with a scalar on the right is an operation of the left query
+	if scalar, ok := unwrapParens(b.RHS).(*parser.NumberLiteral); ok {
+		id, ok := scalarOperations[op]
+		if !ok {
+			return PromVisualQuery{}, fmt.Errorf("operator %s with a scalar is not supported by the query builder", op)
+		}
+		params := []any{scalar.Val}
+		if comparisonOperators[op] {
+			params = append(params, b.ReturnBool)
+		}
+		return p.withOperation(b.LHS, QueryBuilderOperation{ID: id, Params: params})
+	}
+
+	if b.ReturnBool {
+		return PromVisualQuery{}, fmt.Errorf("bool comparisons between queries are not supported by the query builder")
+	}
+	left, err := p.query(b.LHS)
+	if err != nil {
+		return PromVisualQuery{}, err
+	}
+	right, err := p.query(b.RHS)
+	if err != nil {
+		return PromVisualQuery{}, err
+	}
+
+	// Binary queries are rendered one after the other without parentheses, so they can only be
+	// appended when that does not change the order of evaluation
+	for _, bq := range left.BinaryQueries {
+		if op == "^" || binaryPrecedence[op] > binaryPrecedence[bq.Operator] {
+			return PromVisualQuery{}, fmt.Errorf("the order of the binary operations is not supported by the query builder")
+		}
+	}
+
+	bq := PromVisualQueryBinary{Operator: op, Query: right}
+	if vm := b.VectorMatching; vm != nil {
+		if vm.Card == parser.CardManyToOne || vm.Card == parser.CardOneToMany {
+			return PromVisualQuery{}, fmt.Errorf("group_left and group_right are not supported by the query builder")
+		}
+		if len(vm.MatchingLabels) > 0 {
+			bq.VectorMatchesType = "ignoring"
+			if vm.On {
+				bq.VectorMatchesType = "on"
+			}
+			bq.VectorMatches = strings.Join(vm.MatchingLabels, ", ")
+		}
+	}
+	left.BinaryQueries = append(left.BinaryQueries, bq)
+	return left, nil
+}
+
+// withOperation returns the query of inner with op applied last
+func (p visualQueryParser) withOperation(inner parser.Expr, op QueryBuilderOperation) (PromVisualQuery, error) {
+	q, err := p.query(inner)
+	if err != nil {
+		return PromVisualQuery{}, err
+	}
+	// Operations are rendered before the binary queries, they cannot apply to their result
+	if len(q.BinaryQueries) > 0 {
+		return PromVisualQuery{}, fmt.Errorf("%s of a binary operation between queries is not supported by the query builder", op.ID)
+	}
+	q.Operations = append(q.Operations, op)
+	return q, nil
+}
+
+// rangeParam returns the range of a range selector as written in the query builder, or the variable it replaces
+func (p visualQueryParser) rangeParam(d time.Duration) string {
+	if variable, ok := p.variables[d]; ok {
+		return variable
+	}
+	return model.Duration(d).String()
+}
+
+func literalParams(args parser.Expressions) ([]any, error) {
+	params := make([]any, 0, len(args))
+	for _, arg := range args {
+		param, err := literalParam(arg)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+func literalParam(expr parser.Expr) (any, error) {
+	switch e := unwrapParens(expr).(type) {
+	case *parser.NumberLiteral:
+		return e.Val, nil
+	case *parser.StringLiteral:
+		return e.Val, nil
+	}
+	return nil, fmt.Errorf("only numbers and strings are supported as params in the query builder, got %s", expr.String())
+}
+
+func unwrapParens(expr parser.Expr) parser.Expr {
+	for {
+		paren, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.Expr
+	}
+}
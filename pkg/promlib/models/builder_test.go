@@ -0,0 +1,140 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestPromVisualQueryRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		expr  string
+	}{
+		{
+			name:  "selector",
+			query: `{"metric": "up", "labels": [{"label": "job", "op": "=", "value": "api"}, {"label": "env", "op": "=~", "value": "prod|dev"}], "operations": []}`,
+			expr:  `up{job="api", env=~"prod|dev"}`,
+		},
+		{
+			name: "histogram quantile pattern",
+			query: `{"metric": "http_request_duration_seconds_bucket", "labels": [], "operations": [
+				{"id": "rate", "params": ["$__rate_interval"]},
+				{"id": "__sum_by", "params": ["le"]},
+				{"id": "histogram_quantile", "params": [0.95]}
+			]}`,
+			expr: `histogram_quantile(0.95, sum by(le) (rate(http_request_duration_seconds_bucket[$__rate_interval])))`,
+		},
+		{
+			name: "functions with params",
+			query: `{"metric": "up", "labels": [], "operations": [
+				{"id": "quantile_over_time", "params": ["5m", 0.5]},
+				{"id": "label_replace", "params": ["dst", "$1", "src", "(.*)"]},
+				{"id": "__topk_without", "params": [5, "instance", "pod"]},
+				{"id": "__greater_than", "params": [0, true]}
+			]}`,
+			expr: `topk without(instance, pod) (5, label_replace(quantile_over_time(0.5, up[5m]), "dst", "$1", "src", "(.*)")) > bool 0`,
+		},
+		{
+			name: "binary queries",
+			query: `{"metric": "errors_total", "labels": [], "operations": [{"id": "__multiply_by", "params": [100]}], "binaryQueries": [
+				{"operator": "/", "vectorMatchesType": "on", "vectorMatches": "job", "query": {"metric": "requests_total", "labels": [], "operations": [{"id": "sum", "params": []}]}}
+			]}`,
+			expr: `(errors_total * 100) / on(job) sum(requests_total)`,
+		},
+		{
+			name:  "constant",
+			query: `{"metric": "", "labels": [], "operations": [{"id": "vector", "params": [1]}]}`,
+			expr:  `vector(1)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q models.PromVisualQuery
+			require.NoError(t, json.Unmarshal([]byte(tt.query), &q))
+			expr, err := q.Render()
+			require.NoError(t, err)
+			require.Equal(t, tt.expr, expr)
+		})
+	}
+
+	t.Run("invalid queries", func(t *testing.T) {
+		for name, q := range map[string]models.PromVisualQuery{
+			"unknown operation": {Metric: "up", Operations: []models.QueryBuilderOperation{{ID: "nope"}}},
+			"missing range":     {Metric: "up", Operations: []models.QueryBuilderOperation{{ID: "rate", Params: []any{}}}},
+			"wrong param type":  {Metric: "up", Operations: []models.QueryBuilderOperation{{ID: "histogram_quantile", Params: []any{"high"}}}},
+			"too many params":   {Metric: "up", Operations: []models.QueryBuilderOperation{{ID: "abs", Params: []any{1.0}}}},
+			"invalid label":     {Metric: "up", Labels: []models.QueryBuilderLabelFilter{{Label: "a-b", Op: "=", Value: "c"}}},
+			"invalid matcher":   {Metric: "up", Labels: []models.QueryBuilderLabelFilter{{Label: "job", Op: "==", Value: "c"}}},
+			"no selector":       {Operations: []models.QueryBuilderOperation{{ID: "abs"}}},
+			"unknown operator":  {Metric: "up", BinaryQueries: []models.PromVisualQueryBinary{{Operator: "atan", Query: models.PromVisualQuery{Metric: "up"}}}},
+		} {
+			_, err := q.Render()
+			require.Error(t, err, name)
+		}
+	})
+}
+
+func TestParseVisualQuery(t *testing.T) {
+	t.Run("round trips", func(t *testing.T) {
+		for _, expr := range []string{
+			`up{job="api", env=~"prod|dev"}`,
+			`histogram_quantile(0.95, sum by(le) (rate(http_request_duration_seconds_bucket[$__rate_interval])))`,
+			`topk without(instance, pod) (5, label_replace(quantile_over_time(0.5, up[5m]), "dst", "$1", "src", "(.*)")) > bool 0`,
+			`(errors_total * 100) / on(job) sum(requests_total)`,
+			`a / b * c`,
+			`a + (b * c)`,
+			`predict_linear(node_filesystem_free_bytes[${__range}], 3600)`,
+			`vector(1)`,
+		} {
+			q, err := models.ParseVisualQuery(expr)
+			require.NoError(t, err, expr)
+			rendered, err := q.Render()
+			require.NoError(t, err, expr)
+			require.Equal(t, expr, rendered)
+		}
+	})
+
+	t.Run("operations", func(t *testing.T) {
+		q, err := models.ParseVisualQuery(`sum by(job) (rate(up{instance="a"}[$__rate_interval])) > 1`)
+		require.NoError(t, err)
+		require.Equal(t, models.PromVisualQuery{
+			Metric: "up",
+			Labels: []models.QueryBuilderLabelFilter{{Label: "instance", Op: "=", Value: "a"}},
+			Operations: []models.QueryBuilderOperation{
+				{ID: "rate", Params: []any{"$__rate_interval"}},
+				{ID: "__sum_by", Params: []any{"job"}},
+				{ID: "__greater_than", Params: []any{1.0, false}},
+			},
+		}, q)
+	})
+
+	t.Run("expressions the builder cannot represent", func(t *testing.T) {
+		for _, expr := range []string{
+			`(a + b) * c`,
+			`sum(a / b)`,
+			`up offset 1h`,
+			`rate(up[5m:1m])`,
+			`a / on(job) group_left b`,
+			`2 * up`,
+			`limitk(2, up)`,
+			`up{`,
+		} {
+			_, err := models.ParseVisualQuery(expr)
+			require.Error(t, err, expr)
+		}
+	})
+}
+
+func TestReplaceRangeVariables(t *testing.T) {
+	expr := `rate(a[$__rate_interval]) / rate(b[$__rate_interval]) + rate(c[${__range}])`
+	replaced, variables := models.ReplaceRangeVariables(expr)
+	require.Len(t, replaced, len(expr))
+	require.Equal(t, `rate(a[00000009999000ms]) / rate(b[00000009999000ms]) + rate(c[09999001ms])`, replaced)
+	require.Len(t, variables, 2)
+}
@@ -0,0 +1,23 @@
+package models
+
+// Capabilities are the optional features of the Prometheus API supported by the backend of a data source
+type Capabilities struct {
+	// Whether native histograms can be stored and queried
+	NativeHistograms bool `json:"nativeHistograms"`
+	// Whether series can be read with the protobuf remote read protocol
+	Protobuf bool `json:"protobuf"`
+	// Whether exemplars can be queried
+	Exemplars bool `json:"exemplars"`
+	// Whether the Thanos query options, like dedup and max_source_resolution, can be sent. They are not
+	// sent to the backends known to be something else than Thanos or Prometheus, which ignores them.
+	ThanosOptions bool `json:"thanosOptions"`
+	// Whether the results cache of the query frontend can be bypassed with a Cache-Control: no-store header
+	CacheBypass bool `json:"cacheBypass"`
+	// Whether the query frontend splits queries in the number of shards of a Sharding-Control header
+	QuerySharding bool `json:"querySharding"`
+}
+
+// AllCapabilities is assumed when the backend of a data source or its version is not known,
+// features are then used and fail like they did before detection. The cache bypass and sharding
+// control headers are only sent to the query frontends known to honor them.
+var AllCapabilities = Capabilities{NativeHistograms: true, Protobuf: true, Exemplars: true, ThanosOptions: true}
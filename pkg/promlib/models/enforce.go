@@ -0,0 +1,131 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// ErrUnknownUser is returned when label matchers are enforced on the queries of some users only, and
+// the user running them is not known. Their queries are denied rather than run without the matchers.
+var ErrUnknownUser = errors.New("the label matchers enforced by the data source depend on the user, who is unknown")
+
+// EnforcedMatchersRule enforces label matchers on the queries of the users it applies to
+type EnforcedMatchersRule struct {
+	// The logins and the roles of the users the rule applies to, it applies to every user when both are empty
+	Users    []string
+	Roles    []string
+	Matchers []*labels.Matcher
+}
+
+func (r EnforcedMatchersRule) forEveryone() bool {
+	return len(r.Users) == 0 && len(r.Roles) == 0
+}
+
+func (r EnforcedMatchersRule) appliesTo(user *backend.User) bool {
+	return r.forEveryone() || slices.Contains(r.Users, user.Login) || slices.Contains(r.Roles, user.Role)
+}
+
+// ParseEnforcedLabelMatchers returns the rules of jsonData.enforcedLabelMatchers, a list of objects with
+// the users and roles they apply to and the matchers they enforce, like {namespace="team-a"}
+func ParseEnforcedLabelMatchers(jsonData map[string]any) ([]EnforcedMatchersRule, error) {
+	v, ok := jsonData["enforcedLabelMatchers"]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var settings []struct {
+		Users    []string `json:"users"`
+		Roles    []string `json:"roles"`
+		Matchers string   `json:"matchers"`
+	}
+	if err := json.Unmarshal(b, &settings); err != nil {
+		return nil, fmt.Errorf("invalid enforcedLabelMatchers: %w", err)
+	}
+
+	rules := make([]EnforcedMatchersRule, 0, len(settings))
+	for _, s := range settings {
+		matchers, err := parser.ParseMetricSelector(s.Matchers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matchers %q in enforcedLabelMatchers: %w", s.Matchers, err)
+		}
+		rules = append(rules, EnforcedMatchersRule{Users: s.Users, Roles: s.Roles, Matchers: matchers})
+	}
+	return rules, nil
+}
+
+// EnforcedMatchers returns the matchers of the rules applying to user, which are all enforced. When
+// user is nil and some rules apply to specific users or roles, ErrUnknownUser is returned.
+func EnforcedMatchers(rules []EnforcedMatchersRule, user *backend.User) ([]*labels.Matcher, error) {
+	var matchers []*labels.Matcher
+	for _, rule := range rules {
+		if user == nil && !rule.forEveryone() {
+			return nil, ErrUnknownUser
+		}
+		if rule.appliesTo(user) {
+			matchers = append(matchers, rule.Matchers...)
+		}
+	}
+	return matchers, nil
+}
+
+// EnforceLabelMatchers adds matchers to every selector of expr, like prom-label-proxy. The matchers of
+// the selectors are kept, as the series they select must match both, so queries cannot read the series
+// of other label values by matching the same label.
+func EnforceLabelMatchers(expr string, matchers []*labels.Matcher) (string, error) {
+	if len(matchers) == 0 {
+		return expr, nil
+	}
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", err
+	}
+	parser.Inspect(parsed, func(node parser.Node, _ []parser.Node) error {
+		if vs, ok := node.(*parser.VectorSelector); ok {
+			vs.LabelMatchers = appendMissingMatchers(vs.LabelMatchers, matchers)
+		}
+		return nil
+	})
+	return parsed.String(), nil
+}
+
+// EnforceSeriesSelectors adds matchers to the series selectors of the match[] parameter of the series,
+// label names and label values endpoints. Without selectors, the series of all metrics are selected, so
+// the enforced matchers are returned as the only selector.
+func EnforceSeriesSelectors(selectors []string, matchers []*labels.Matcher) ([]string, error) {
+	if len(matchers) == 0 {
+		return selectors, nil
+	}
+	if len(selectors) == 0 {
+		return []string{(&parser.VectorSelector{LabelMatchers: matchers}).String()}, nil
+	}
+	enforced := make([]string, 0, len(selectors))
+	for _, selector := range selectors {
+		selectorMatchers, err := parser.ParseMetricSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		vs := &parser.VectorSelector{LabelMatchers: appendMissingMatchers(selectorMatchers, matchers)}
+		enforced = append(enforced, vs.String())
+	}
+	return enforced, nil
+}
+
+func appendMissingMatchers(existing, matchers []*labels.Matcher) []*labels.Matcher {
+	for _, m := range matchers {
+		if !slices.ContainsFunc(existing, func(e *labels.Matcher) bool {
+			return e.Name == m.Name && e.Type == m.Type && e.Value == m.Value
+		}) {
+			existing = append(existing, m)
+		}
+	}
+	return existing
+}
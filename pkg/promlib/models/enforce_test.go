@@ -0,0 +1,146 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnforcedLabelMatchers(t *testing.T) {
+	rules, err := ParseEnforcedLabelMatchers(map[string]any{})
+	require.NoError(t, err)
+	require.Empty(t, rules)
+
+	rules, err = ParseEnforcedLabelMatchers(map[string]any{"enforcedLabelMatchers": []any{
+		map[string]any{"users": []any{"alice"}, "roles": []any{"Viewer"}, "matchers": `{namespace="team-a",env!="dev"}`},
+	}})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, []string{"alice"}, rules[0].Users)
+	require.Equal(t, []string{"Viewer"}, rules[0].Roles)
+	require.Equal(t, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, "namespace", "team-a"),
+		labels.MustNewMatcher(labels.MatchNotEqual, "env", "dev"),
+	}, rules[0].Matchers)
+
+	_, err = ParseEnforcedLabelMatchers(map[string]any{"enforcedLabelMatchers": []any{map[string]any{"matchers": `{namespace=}`}}})
+	require.Error(t, err)
+	_, err = ParseEnforcedLabelMatchers(map[string]any{"enforcedLabelMatchers": []any{map[string]any{}}})
+	require.Error(t, err)
+	_, err = ParseEnforcedLabelMatchers(map[string]any{"enforcedLabelMatchers": "namespace"})
+	require.Error(t, err)
+}
+
+func TestEnforcedMatchers(t *testing.T) {
+	everyone := labels.MustNewMatcher(labels.MatchNotEqual, "env", "dev")
+	teamA := labels.MustNewMatcher(labels.MatchEqual, "namespace", "team-a")
+	viewers := labels.MustNewMatcher(labels.MatchEqual, "sensitive", "false")
+	rules := []EnforcedMatchersRule{
+		{Matchers: []*labels.Matcher{everyone}},
+		{Users: []string{"alice", "bob"}, Matchers: []*labels.Matcher{teamA}},
+		{Roles: []string{"Viewer"}, Matchers: []*labels.Matcher{viewers}},
+	}
+
+	enforced := func(rules []EnforcedMatchersRule, user *backend.User) []*labels.Matcher {
+		matchers, err := EnforcedMatchers(rules, user)
+		require.NoError(t, err)
+		return matchers
+	}
+	require.Equal(t, []*labels.Matcher{everyone, teamA}, enforced(rules, &backend.User{Login: "alice", Role: "Editor"}))
+	require.Equal(t, []*labels.Matcher{everyone, teamA, viewers}, enforced(rules, &backend.User{Login: "bob", Role: "Viewer"}))
+	require.Equal(t, []*labels.Matcher{everyone, viewers}, enforced(rules, &backend.User{Login: "carol", Role: "Viewer"}))
+	require.Empty(t, enforced(nil, &backend.User{Login: "alice"}))
+	require.Empty(t, enforced(nil, nil))
+
+	// Rules applying to everyone do not need to know the user
+	require.Equal(t, []*labels.Matcher{everyone}, enforced(rules[:1], nil))
+	// The queries of unknown users are denied when some rules apply to specific users
+	_, err := EnforcedMatchers(rules, nil)
+	require.ErrorIs(t, err, ErrUnknownUser)
+}
+
+func TestEnforceLabelMatchers(t *testing.T) {
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "namespace", "team-a")}
+
+	tests := []struct {
+		name     string
+		expr     string
+		expected string
+	}{
+		{
+			name:     "selector",
+			expr:     `up`,
+			expected: `up{namespace="team-a"}`,
+		},
+		{
+			name:     "selectors of functions, aggregations and binary operations",
+			expr:     `sum by (job) (rate(http_requests_total{job="api"}[5m])) / on (job) group_left () max(up)`,
+			expected: `sum by (job) (rate(http_requests_total{job="api",namespace="team-a"}[5m])) / on (job) group_left () max(up{namespace="team-a"})`,
+		},
+		{
+			name:     "subquery",
+			expr:     `max_over_time(rate(errors_total[1m])[1h:5m])`,
+			expected: `max_over_time(rate(errors_total{namespace="team-a"}[1m])[1h:5m])`,
+		},
+		{
+			name:     "matchers of the same label are kept, so both must match",
+			expr:     `up{namespace="team-b"}`,
+			expected: `up{namespace="team-a",namespace="team-b"}`,
+		},
+		{
+			name:     "matchers already set are not repeated",
+			expr:     `up{namespace="team-a"}`,
+			expected: `up{namespace="team-a"}`,
+		},
+		{
+			name:     "number literals do not select series",
+			expr:     `1 + 1`,
+			expected: `1 + 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := EnforceLabelMatchers(tt.expr, matchers)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, expr)
+		})
+	}
+
+	t.Run("without matchers the expression is not parsed", func(t *testing.T) {
+		expr, err := EnforceLabelMatchers(`up{`, nil)
+		require.NoError(t, err)
+		require.Equal(t, `up{`, expr)
+	})
+
+	t.Run("invalid expressions fail", func(t *testing.T) {
+		_, err := EnforceLabelMatchers(`up{`, matchers)
+		require.Error(t, err)
+	})
+}
+
+func TestEnforceSeriesSelectors(t *testing.T) {
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "namespace", "team-a")}
+
+	selectors, err := EnforceSeriesSelectors([]string{`up`, `{job="api",namespace="team-b"}`, `{namespace="team-a"}`}, matchers)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`{__name__="up",namespace="team-a"}`,
+		`{job="api",namespace="team-a",namespace="team-b"}`,
+		`{namespace="team-a"}`,
+	}, selectors)
+
+	// Without selectors the series of all metrics are selected
+	selectors, err = EnforceSeriesSelectors(nil, matchers)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{namespace="team-a"}`}, selectors)
+
+	selectors, err = EnforceSeriesSelectors(nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, selectors)
+
+	// Only selectors are accepted
+	_, err = EnforceSeriesSelectors([]string{`sum(up)`}, matchers)
+	require.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// queriedTimeRange returns the range of raw data read by expr when it is evaluated between start and end.
+// The @ and offset modifiers of selectors and subqueries, as well as range selectors, move the
+// data read outside of the evaluation range. The returned range always includes start and end.
+// If expr cannot be parsed the evaluation range is returned as is.
+func queriedTimeRange(expr string, start, end time.Time) (time.Time, time.Time) {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return start, end
+	}
+
+	minT, maxT := start, end
+	parser.Inspect(parsed, func(node parser.Node, path []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+
+		// The evaluation window of the selector is narrowed down by the subqueries around it,
+		// starting from the outermost one
+		s, e := start, end
+		for _, p := range path {
+			if sq, ok := p.(*parser.SubqueryExpr); ok {
+				s, e = applyModifiers(s, e, start, end, sq.Timestamp, sq.StartOrEnd, sq.OriginalOffset, sq.Range)
+			}
+		}
+
+		var selectorRange time.Duration
+		if len(path) > 0 {
+			if ms, ok := path[len(path)-1].(*parser.MatrixSelector); ok {
+				selectorRange = ms.Range
+			}
+		}
+		s, e = applyModifiers(s, e, start, end, vs.Timestamp, vs.StartOrEnd, vs.OriginalOffset, selectorRange)
+
+		if s.Before(minT) {
+			minT = s
+		}
+		if e.After(maxT) {
+			maxT = e
+		}
+		return nil
+	})
+
+	return minT, maxT
+}
+
+// applyModifiers returns the data range read for the evaluation window s-e,
+// given the @ timestamp, offset and range of a selector or subquery.
+func applyModifiers(s, e, queryStart, queryEnd time.Time, at *int64, startOrEnd parser.ItemType, offset, rng time.Duration) (time.Time, time.Time) {
+	switch {
+	case at != nil:
+		s = time.UnixMilli(*at).UTC()
+		e = s
+	case startOrEnd == parser.START:
+		s, e = queryStart, queryStart
+	case startOrEnd == parser.END:
+		s, e = queryEnd, queryEnd
+	}
+	return s.Add(-offset - rng), e.Add(-offset)
+}
+
+// withSubqueryStep returns expr with step set on the subqueries that do not have one.
+// Expressions without such subqueries, or that cannot be parsed, are returned as they are.
+func withSubqueryStep(expr string, step time.Duration) string {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return expr
+	}
+
+	changed := false
+	parser.Inspect(parsed, func(node parser.Node, _ []parser.Node) error {
+		if sq, ok := node.(*parser.SubqueryExpr); ok && sq.Step == 0 {
+			sq.Step = step
+			changed = true
+		}
+		return nil
+	})
+	if !changed {
+		return expr
+	}
+	return parsed.String()
+}
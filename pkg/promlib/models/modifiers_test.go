@@ -0,0 +1,109 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueriedTimeRange(t *testing.T) {
+	start := time.Unix(10000, 0).UTC()
+	end := time.Unix(20000, 0).UTC()
+
+	tests := []struct {
+		name          string
+		expr          string
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			name:          "plain selector reads the evaluation range",
+			expr:          `up`,
+			expectedStart: start,
+			expectedEnd:   end,
+		},
+		{
+			name:          "range selector extends the start",
+			expr:          `rate(http_requests_total[5m])`,
+			expectedStart: start.Add(-5 * time.Minute),
+			expectedEnd:   end,
+		},
+		{
+			name:          "offset moves the range back",
+			expr:          `rate(http_requests_total[5m] offset 1h)`,
+			expectedStart: start.Add(-time.Hour - 5*time.Minute),
+			expectedEnd:   end,
+		},
+		{
+			name:          "negative offset moves the range forward",
+			expr:          `up offset -1h`,
+			expectedStart: start,
+			expectedEnd:   end.Add(time.Hour),
+		},
+		{
+			name:          "@ timestamp anchors the selector",
+			expr:          `up @ 1000`,
+			expectedStart: time.Unix(1000, 0).UTC(),
+			expectedEnd:   end,
+		},
+		{
+			name:          "@ start() anchors the selector to the query start",
+			expr:          `rate(http_requests_total[1m] @ start() offset 1m)`,
+			expectedStart: start.Add(-2 * time.Minute),
+			expectedEnd:   end,
+		},
+		{
+			name:          "subquery offset applies to inner selectors",
+			expr:          `max_over_time(rate(http_requests_total[5m])[1h:1m] offset 1d)`,
+			expectedStart: start.Add(-24*time.Hour - time.Hour - 5*time.Minute),
+			expectedEnd:   end,
+		},
+		{
+			name:          "unparsable expression reads the evaluation range",
+			expr:          `rate(up[$__rate_interval])`,
+			expectedStart: start,
+			expectedEnd:   end,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, e := queriedTimeRange(tt.expr, start, end)
+			require.Equal(t, tt.expectedStart, s)
+			require.Equal(t, tt.expectedEnd, e)
+		})
+	}
+}
+
+func TestQuery_QueriedTimeRange(t *testing.T) {
+	q := &Query{
+		Start: time.Unix(10000, 0).UTC(),
+		End:   time.Unix(20000, 0).UTC(),
+		Step:  time.Minute,
+	}
+	require.Equal(t, q.TimeRange(), q.QueriedTimeRange())
+
+	q.QueriedStart = time.Unix(5000, 0).UTC()
+	q.QueriedEnd = time.Unix(20000, 0).UTC()
+	tr := q.QueriedTimeRange()
+	require.Equal(t, time.Unix(4980, 0).UTC(), tr.Start)
+	require.Equal(t, q.TimeRange().End, tr.End)
+}
+
+func TestWithSubqueryStep(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{`max_over_time(up[1h:])`, `max_over_time(up[1h:1m])`},
+		{`max_over_time(up[1h:30s])`, `max_over_time(up[1h:30s])`},
+		{`max_over_time(deriv(up[5m:])[1h:])`, `max_over_time(deriv(up[5m:1m])[1h:1m])`},
+		{`rate(up[5m])`, `rate(up[5m])`},
+		{`rate(up[$__rate_interval])`, `rate(up[$__rate_interval])`},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, withSubqueryStep(tt.expr, time.Minute), tt.expr)
+	}
+}
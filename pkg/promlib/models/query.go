@@ -26,6 +26,12 @@ const (
 	PromQueryFormatTimeSeries PromQueryFormat = "time_series"
 	PromQueryFormatTable      PromQueryFormat = "table"
 	PromQueryFormatHeatmap    PromQueryFormat = "heatmap"
+	// A single frame with a row for each sample and a column for each label, instead of a frame per series
+	PromQueryFormatLong PromQueryFormat = "long"
+	// A single numeric wide frame with the last value of each series, as expected by alert and recording rules
+	PromQueryFormatNumeric PromQueryFormat = "numeric"
+	// Annotation events, spanning the consecutive samples of each series with a positive value
+	PromQueryFormatAnnotations PromQueryFormat = "annotations"
 )
 
 // QueryEditorMode defines model for QueryEditorMode.
@@ -37,6 +43,42 @@ const (
 	QueryEditorModeCode    QueryEditorMode = "code"
 )
 
+// HistogramBucketLayout defines model for HistogramBucketLayout.
+// +enum
+type HistogramBucketLayout string
+
+const (
+	// Buckets of the same width
+	HistogramBucketLayoutLinear HistogramBucketLayout = "linear"
+	// Buckets growing exponentially, for values spanning several orders of magnitude
+	HistogramBucketLayoutLog HistogramBucketLayout = "log"
+)
+
+// QueryPriority defines model for QueryPriority.
+// +enum
+type QueryPriority string
+
+const (
+	// Queries whose results users wait for, like the ones of dashboards. The default
+	QueryPriorityInteractive QueryPriority = "interactive"
+	// Queries nobody waits for, like the ones of reports, which yield to the interactive ones
+	QueryPriorityBackground QueryPriority = "background"
+)
+
+// NonFiniteValues defines model for NonFiniteValues.
+// +enum
+type NonFiniteValues string
+
+const (
+	// NaN and infinite samples are returned as they are sent by the data source. The default
+	NonFiniteValuesKeep NonFiniteValues = "keep"
+	// NaN and infinite samples are removed from the series
+	NonFiniteValuesDrop NonFiniteValues = "drop"
+	// NaN and infinite samples are replaced by the line between the finite samples around them, or removed
+	// at the start and end of the series
+	NonFiniteValuesInterpolate NonFiniteValues = "interpolate"
+)
+
 // PrometheusQueryProperties defines the specific properties used for prometheus
 type PrometheusQueryProperties struct {
 	// The response format
@@ -59,22 +101,131 @@ type PrometheusQueryProperties struct {
 
 	// Used to specify how many times to divide max data points by. We use max data points under query options
 	// See https://github.com/grafana/grafana/issues/48081
-	// Deprecated: use interval
+	// Deprecated: use resolution
 	IntervalFactor int64 `json:"intervalFactor,omitempty"`
 
+	// Divides the number of points per series: 1 for full resolution, 2 for half, up to 10.
+	// It takes precedence over intervalFactor
+	Resolution int64 `json:"resolution,omitempty"`
+
+	// Step of the subqueries without one (e.g. rate(up[1h:])), which otherwise use the evaluation
+	// interval of Prometheus. Either a duration (e.g. 1m) or $__interval for the step of the query
+	SubqueryStep string `json:"subqueryStep,omitempty"`
+
+	// A fixed step (e.g. 30s) used for the query instead of the calculated interval. Ranges with more points
+	// per series than Prometheus allows are queried in parts, the step is only raised when there are too many
+	Step string `json:"step,omitempty"`
+
+	// Additional query parameters sent to Prometheus with this query (e.g. engine=thanos).
+	// These are added to the custom query parameters configured on the data source
+	CustomQueryParameters map[string]string `json:"customQueryParameters,omitempty"`
+
+	// Thanos only: whether a partial response is returned when some store APIs are unavailable
+	PartialResponse *bool `json:"partialResponse,omitempty"`
+
+	// Thanos only: the maximum resolution of downsampled data used by the query. Ex. 5m, 1h or auto
+	MaxSourceResolution string `json:"maxSourceResolution,omitempty"`
+
+	// Thanos only: whether the series of replicas are deduplicated, Thanos deduplicates them by default
+	Dedup *bool `json:"dedup,omitempty"`
+
+	// Request query statistics (samples scanned and timings) from Prometheus and attach them to the result,
+	// with the time Grafana spent on each phase of the query (queue, connect, first byte, decode and conversion)
+	Stats bool `json:"stats,omitempty"`
+
+	// Additional HTTP headers sent to Prometheus with this query (e.g. X-Scope-OrgID).
+	// Only the headers allowed by the data source can be set
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Mimir only: the tenants the query reads, sent as the X-Scope-OrgID header of this query instead of the
+	// tenant of the data source. Several tenants are federated into a single result. The tenants must be
+	// allowed by the data source, or X-Scope-OrgID when it does not allow a list of tenants
+	Tenants []string `json:"tenants,omitempty"`
+
+	// Bypass the result cache of the data source for this query. Mimir and Cortex are sent Cache-Control: no-store,
+	// so the results cache of their query frontend is neither read nor written either. Useful for live panels whose
+	// latest results change on every refresh
+	NoCache bool `json:"noCache,omitempty"`
+
+	// Whether users wait for the results of the query, interactive by default. Background queries wait for
+	// the interactive ones when the data source limits its concurrent queries, and the priority is sent in the
+	// header set by the data source, like the QoS header of a query frontend
+	Priority QueryPriority `json:"priority,omitempty"`
+
+	// Add the sample values as sent by the data source in a string field next to the value field, for values
+	// that cannot be represented by a float64 without rounding. Not supported by alerting
+	RawValues bool `json:"rawValues,omitempty"`
+
+	// How the NaN and infinite samples of series are handled, kept by default. Some data sources send NaN
+	// samples, like the ones of divisions by zero, which break thresholds and alert conditions
+	NonFiniteValues NonFiniteValues `json:"nonFiniteValues,omitempty"`
+
+	// Native histograms only: the number of buckets the exponential buckets of the data source are merged into,
+	// so the heatmap has the same buckets over the whole time range. Zero keeps the buckets of the data source
+	HistogramBuckets int64 `json:"histogramBuckets,omitempty"`
+
+	// Native histograms only: the layout of the buckets set by histogramBuckets, linear by default
+	HistogramBucketLayout HistogramBucketLayout `json:"histogramBucketLayout,omitempty"`
+
+	// How far back (e.g. 15m) Prometheus looks for the latest sample of a series, overriding its
+	// lookback delta (5m by default). Widen it for sparsely scraped metrics
+	LookbackDelta string `json:"lookbackDelta,omitempty"`
+
+	// Maximum duration of the query (e.g. 2m), overriding the timeout of the data source.
+	// It is bounded by the maximum query timeout configured on the data source
+	Timeout string `json:"timeout,omitempty"`
+
+	// Timezone of the dashboard the range is aligned to step boundaries in, either an offset
+	// from UTC (e.g. +02:00) or a timezone name (e.g. Europe/Berlin). It takes precedence over utcOffsetSec
+	UtcOffset string `json:"utcOffset,omitempty"`
+
+	// Annotations only: comma separated labels whose values are the tags of the events
+	TagKeys string `json:"tagKeys,omitempty"`
+
+	// Annotations only: title of the events. Ex. {{instance}} will be replaced with label value for instance
+	TitleFormat string `json:"titleFormat,omitempty"`
+
+	// Annotations only: text of the events. Ex. {{instance}} will be replaced with label value for instance
+	TextFormat string `json:"textFormat,omitempty"`
+
+	// Annotations only: use the values of the series, in milliseconds, as the time of the events
+	UseValueForTime bool `json:"useValueForTime,omitempty"`
+
 	// Series name override or template. Ex. {{hostname}} will be replaced with label value for hostname
 	LegendFormat string `json:"legendFormat,omitempty"`
 
+	// The values of the interval variables resolved by the frontend for the query. When set, they replace
+	// the ones calculated by the backend in the expression, so alert rules and server side expressions
+	// interpolate $__interval and $__rate_interval like the panel the query comes from
+	IntervalContext *IntervalContext `json:"intervalContext,omitempty"`
+
 	// A set of filters applied to apply to the query
 	Scopes []ScopeSpec `json:"scopes,omitempty"`
 
 	// Additional Ad-hoc filters that take precedence over Scope on conflict.
+	// Unlike scopes and group by keys, they are applied without the promQLScope feature.
 	AdhocFilters []ScopeFilter `json:"adhocFilters,omitempty"`
 
 	// Group By parameters to apply to aggregate expressions in the query
 	GroupByKeys []string `json:"groupByKeys,omitempty"`
 }
 
+// IntervalContext holds the values of the interval variables of a query. A variable set either as a
+// duration or in milliseconds has both values resolved from it
+type IntervalContext struct {
+	// The value of $__interval, like 30s
+	Interval string `json:"interval,omitempty"`
+
+	// The value of $__interval_ms
+	IntervalMs int64 `json:"intervalMs,omitempty"`
+
+	// The value of $__rate_interval, like 2m0s
+	RateInterval string `json:"rateInterval,omitempty"`
+
+	// The value of $__rate_interval_ms
+	RateIntervalMs int64 `json:"rateIntervalMs,omitempty"`
+}
+
 // ScopeSpec is a hand copy of the ScopeSpec struct from pkg/apis/scope/v0alpha1/types.go
 // to avoid import (temp fix). This also has metadata.name inlined.
 type ScopeSpec struct {
@@ -128,6 +279,34 @@ const (
 	varRateIntervalMsAlt = "${__rate_interval_ms}"
 )
 
+// QueryType defines the Prometheus API endpoint a query reads.
+// PromQL queries leave it empty, the other types return table frames.
+// +enum
+type QueryType string
+
+const (
+	// Metric metadata (type, help and unit) from /api/v1/metadata.
+	// The expr, if set, is the name of the metric to return the metadata of
+	QueryTypeMetadata QueryType = "metadata"
+	// Recording and alerting rules, with their health and state, from /api/v1/rules
+	QueryTypeRules QueryType = "rules"
+	// Pending and firing alerts from /api/v1/alerts
+	QueryTypeAlerts QueryType = "alerts"
+	// Active scrape targets, with their health and last scrape, from /api/v1/targets
+	QueryTypeTargets QueryType = "targets"
+	// Head block and cardinality statistics from /api/v1/status/tsdb
+	QueryTypeTSDBStatus QueryType = "tsdbStatus"
+)
+
+// IsAPIQuery returns whether the query reads an API endpoint instead of evaluating PromQL
+func (t QueryType) IsAPIQuery() bool {
+	switch t {
+	case QueryTypeMetadata, QueryTypeRules, QueryTypeAlerts, QueryTypeTargets, QueryTypeTSDBStatus:
+		return true
+	}
+	return false
+}
+
 type TimeSeriesQueryType string
 
 const (
@@ -139,6 +318,12 @@ const (
 
 var safeResolution = 11000
 
+// maxResolution is the largest divisor of the number of points per series
+const maxResolution = 10
+
+// maxHistogramBuckets is the largest number of buckets native histograms can be merged into
+const maxHistogramBuckets = 1000
+
 // QueryModel includes both the common and specific values
 // NOTE: this struct may have issues when decoding JSON that requires the special handling
 // registered in https://github.com/grafana/grafana-plugin-sdk-go/blob/v0.228.0/experimental/apis/data/v0alpha1/query.go#L298
@@ -163,6 +348,7 @@ type Query struct {
 	Expr          string
 	Step          time.Duration
 	LegendFormat  string
+	Format        PromQueryFormat
 	Start         time.Time
 	End           time.Time
 	RefId         string
@@ -171,6 +357,56 @@ type Query struct {
 	ExemplarQuery bool
 	UtcOffsetSec  int64
 
+	// Range of the raw data read by Expr. It is wider than Start-End when
+	// the expression uses @ or offset modifiers
+	QueriedStart time.Time
+	QueriedEnd   time.Time
+
+	CustomQueryParameters map[string]string
+
+	// Thanos query options
+	PartialResponse     *bool
+	MaxSourceResolution string
+	Dedup               *bool
+
+	Stats bool
+
+	// Zero to use the timeout of the data source
+	Timeout time.Duration
+
+	// Zero to use the lookback delta of Prometheus
+	LookbackDelta time.Duration
+
+	// How the query was adjusted to the point limit of Prometheus, nil when it was not
+	StepAdjustment *StepAdjustment
+
+	Headers map[string]string
+	NoCache bool
+	// Interactive when empty
+	Priority QueryPriority
+
+	// The tenants of the query, also set in Headers
+	Tenants []string
+
+	// Whether series have a string field with the values as sent by the data source
+	RawValues bool
+
+	// Keep when empty
+	NonFiniteValues NonFiniteValues
+
+	// Zero to keep the buckets of native histograms
+	HistogramBuckets      int
+	HistogramBucketLayout HistogramBucketLayout
+
+	// The values the interval variables of Expr were replaced with, nil when it does not use them
+	IntervalContext *IntervalContext
+
+	// Annotation options
+	TagKeys         []string
+	TitleFormat     string
+	TextFormat      string
+	UseValueForTime bool
+
 	Scopes []ScopeSpec
 }
 
@@ -195,27 +431,69 @@ func Parse(span trace.Span, query backend.DataQuery, dsScrapeInterval string, in
 	}
 	span.SetAttributes(attribute.String("rawExpr", model.Expr))
 
+	if model.HistogramBuckets < 0 || model.HistogramBuckets > maxHistogramBuckets {
+		return nil, fmt.Errorf("invalid histogram buckets %d, expected a value between 1 and %d, or 0 to keep the buckets of the data source", model.HistogramBuckets, maxHistogramBuckets)
+	}
+	switch model.HistogramBucketLayout {
+	case "", HistogramBucketLayoutLinear, HistogramBucketLayoutLog:
+	default:
+		return nil, fmt.Errorf("invalid histogram bucket layout %q, expected %q or %q", model.HistogramBucketLayout, HistogramBucketLayoutLinear, HistogramBucketLayoutLog)
+	}
+	switch model.Priority {
+	case "", QueryPriorityInteractive, QueryPriorityBackground:
+	default:
+		return nil, fmt.Errorf("invalid priority %q, expected %q or %q", model.Priority, QueryPriorityInteractive, QueryPriorityBackground)
+	}
+	switch model.NonFiniteValues {
+	case "", NonFiniteValuesKeep, NonFiniteValuesDrop, NonFiniteValuesInterpolate:
+	default:
+		return nil, fmt.Errorf("invalid non-finite values handling %q, expected %q, %q or %q", model.NonFiniteValues, NonFiniteValuesKeep, NonFiniteValuesDrop, NonFiniteValuesInterpolate)
+	}
+
+	if model.Resolution < 0 || model.Resolution > maxResolution {
+		return nil, fmt.Errorf("invalid resolution %d, expected a value between 1 and %d", model.Resolution, maxResolution)
+	}
+	resolution := model.Resolution
+	if resolution == 0 {
+		resolution = model.IntervalFactor
+	}
+
 	// Final step value for prometheus
-	calculatedStep, err := calculatePrometheusInterval(model.Interval, dsScrapeInterval, int64(model.IntervalMS), model.IntervalFactor, query, intervalCalculator)
+	calculatedStep, err := calculatePrometheusInterval(model.Interval, dsScrapeInterval, model.Step, int64(model.IntervalMS), resolution, query, intervalCalculator)
 	if err != nil {
 		return nil, err
 	}
-
-	// Interpolate variables in expr
 	timeRange := query.TimeRange.To.Sub(query.TimeRange.From)
-	expr := interpolateVariables(
-		model.Expr,
-		query.Interval,
-		calculatedStep,
-		model.Interval,
-		dsScrapeInterval,
-		timeRange,
-	)
+	// The step is limited over the range of the data read, which the @ and offset modifiers move outside of the
+	// evaluation range
+	queriedStart, queriedEnd := queriedTimeRange(
+		interpolateVariables(model.Expr, calculateIntervalContext(query.Interval, calculatedStep, model.Interval, dsScrapeInterval), timeRange),
+		query.TimeRange.From, query.TimeRange.To)
+	calculatedStep, stepAdjustment := limitStep(calculatedStep, queriedEnd.Sub(queriedStart), model.Step != "" && !isVariableInterval(model.Step))
+
+	// Interpolate variables in expr, with the interval variables resolved by the frontend when they are set
+	intervals := calculateIntervalContext(query.Interval, calculatedStep, model.Interval, dsScrapeInterval)
+	if model.IntervalContext != nil {
+		if intervals, err = model.IntervalContext.resolve(intervals); err != nil {
+			return nil, err
+		}
+	}
+	expr := interpolateVariables(model.Expr, intervals, timeRange)
+	var intervalContext *IntervalContext
+	if usesIntervalVariables(model.Expr) {
+		intervalContext = &intervals
+	}
 
-	if enableScope {
+	// Ad-hoc filters are applied whenever they are set, so they also filter the queries of alert rules and
+	// API clients. Scopes and group by keys are only applied with the promQLScope feature
+	if enableScope || len(model.AdhocFilters) > 0 {
 		var scopeFilters []ScopeFilter
-		for _, scope := range model.Scopes {
-			scopeFilters = append(scopeFilters, scope.Filters...)
+		var groupByKeys []string
+		if enableScope {
+			for _, scope := range model.Scopes {
+				scopeFilters = append(scopeFilters, scope.Filters...)
+			}
+			groupByKeys = model.GroupByKeys
 		}
 
 		if len(scopeFilters) > 0 {
@@ -238,12 +516,52 @@ func Parse(span trace.Span, query backend.DataQuery, dsScrapeInterval string, in
 			}()))
 		}
 
-		expr, err = ApplyFiltersAndGroupBy(expr, scopeFilters, model.AdhocFilters, model.GroupByKeys)
+		expr, err = ApplyFiltersAndGroupBy(expr, scopeFilters, model.AdhocFilters, groupByKeys)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if model.SubqueryStep != "" {
+		subqueryStep := calculatedStep
+		if !isVariableInterval(model.SubqueryStep) {
+			if subqueryStep, err = gtime.ParseIntervalStringToTimeDuration(model.SubqueryStep); err != nil || subqueryStep <= 0 {
+				return nil, fmt.Errorf("invalid subquery step %q", model.SubqueryStep)
+			}
+		}
+		expr = withSubqueryStep(expr, subqueryStep)
+	}
+
+	if err := validateMaxSourceResolution(model.MaxSourceResolution); err != nil {
+		return nil, err
+	}
+
+	var timeout time.Duration
+	if model.Timeout != "" {
+		if timeout, err = gtime.ParseIntervalStringToTimeDuration(model.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", model.Timeout)
+		}
+	}
+
+	headers, err := withTenants(model.Headers, model.Tenants)
+	if err != nil {
+		return nil, err
+	}
+
+	var lookbackDelta time.Duration
+	if model.LookbackDelta != "" {
+		if lookbackDelta, err = gtime.ParseIntervalStringToTimeDuration(model.LookbackDelta); err != nil || lookbackDelta <= 0 {
+			return nil, fmt.Errorf("invalid lookback delta %q", model.LookbackDelta)
+		}
+	}
+
+	utcOffsetSec := model.UtcOffsetSec
+	if model.UtcOffset != "" {
+		if utcOffsetSec, err = parseUtcOffset(model.UtcOffset, query.TimeRange.To); err != nil {
+			return nil, err
+		}
+	}
+
 	if !model.Instant && !model.Range {
 		// In older dashboards, we were not setting range query param and !range && !instant was run as range query
 		model.Range = true
@@ -252,25 +570,58 @@ func Parse(span trace.Span, query backend.DataQuery, dsScrapeInterval string, in
 	// We never want to run exemplar query for alerting
 	if fromAlert {
 		model.Exemplar = false
+		// Alert rules read the series, tables are only built for panels and API clients
+		if model.Format == PromQueryFormatTable {
+			model.Format = PromQueryFormatTimeSeries
+		}
 	}
 
+	queriedStart, queriedEnd = queriedTimeRange(expr, query.TimeRange.From, query.TimeRange.To)
+
 	span.SetAttributes(
 		attribute.String("expr", expr),
 		attribute.Int64("start_unixnano", query.TimeRange.From.UnixNano()),
 		attribute.Int64("stop_unixnano", query.TimeRange.To.UnixNano()),
+		attribute.Int64("queried_start_unixnano", queriedStart.UnixNano()),
+		attribute.Int64("queried_stop_unixnano", queriedEnd.UnixNano()),
 	)
 
 	return &Query{
 		Expr:          expr,
 		Step:          calculatedStep,
 		LegendFormat:  model.LegendFormat,
+		Format:        model.Format,
 		Start:         query.TimeRange.From,
 		End:           query.TimeRange.To,
 		RefId:         query.RefID,
 		InstantQuery:  model.Instant,
 		RangeQuery:    model.Range,
 		ExemplarQuery: model.Exemplar,
-		UtcOffsetSec:  model.UtcOffsetSec,
+		UtcOffsetSec:  utcOffsetSec,
+		QueriedStart:  queriedStart,
+		QueriedEnd:    queriedEnd,
+
+		StepAdjustment:        stepAdjustment,
+		CustomQueryParameters: model.CustomQueryParameters,
+		PartialResponse:       model.PartialResponse,
+		MaxSourceResolution:   model.MaxSourceResolution,
+		Dedup:                 model.Dedup,
+		Stats:                 model.Stats,
+		Timeout:               timeout,
+		LookbackDelta:         lookbackDelta,
+		Headers:               headers,
+		NoCache:               model.NoCache,
+		Priority:              model.Priority,
+		Tenants:               model.Tenants,
+		RawValues:             model.RawValues && !fromAlert,
+		NonFiniteValues:       model.NonFiniteValues,
+		HistogramBuckets:      int(model.HistogramBuckets),
+		HistogramBucketLayout: model.HistogramBucketLayout,
+		IntervalContext:       intervalContext,
+		TagKeys:               tagKeys(model.TagKeys),
+		TitleFormat:           model.TitleFormat,
+		TextFormat:            model.TextFormat,
+		UseValueForTime:       model.UseValueForTime,
 	}, nil
 }
 
@@ -296,12 +647,50 @@ func (query *Query) TimeRange() TimeRange {
 	}
 }
 
+// DefaultLookbackDelta is the lookback delta of Prometheus, used when a query does not set one
+const DefaultLookbackDelta = 5 * time.Minute
+
+// Lookback returns how far before each step the last sample of a series is looked up
+func (query *Query) Lookback() time.Duration {
+	if query.LookbackDelta > 0 {
+		return query.LookbackDelta
+	}
+	return DefaultLookbackDelta
+}
+
+// QueriedTimeRange returns the step aligned range of the raw data read by the query.
+// This is the range exemplars are looked up in, so they are found for the data shown when
+// the expression uses @ or offset modifiers.
+func (query *Query) QueriedTimeRange() TimeRange {
+	start, end := query.QueriedStart, query.QueriedEnd
+	if start.IsZero() || start.After(query.Start) {
+		start = query.Start
+	}
+	if end.IsZero() || end.Before(query.End) {
+		end = query.End
+	}
+	return TimeRange{
+		Step:  query.Step,
+		Start: AlignTimeRange(start, query.Step, query.UtcOffsetSec),
+		End:   AlignTimeRange(end, query.Step, query.UtcOffsetSec),
+	}
+}
+
 func calculatePrometheusInterval(
-	queryInterval, dsScrapeInterval string,
+	queryInterval, dsScrapeInterval, fixedStep string,
 	intervalMs, intervalFactor int64,
 	query backend.DataQuery,
 	intervalCalculator intervalv2.Calculator,
 ) (time.Duration, error) {
+	// A fixed step pins the resolution, see limitStep for the ranges over the point limit
+	if fixedStep != "" && !isVariableInterval(fixedStep) {
+		step, err := gtime.ParseIntervalStringToTimeDuration(fixedStep)
+		if err != nil {
+			return time.Duration(0), fmt.Errorf("invalid step %q: %w", fixedStep, err)
+		}
+		return step, nil
+	}
+
 	// we need to compare the original query model after it is overwritten below to variables so that we can
 	// calculate the rateInterval if it is equal to $__rate_interval or ${__rate_interval}
 	originalQueryInterval := queryInterval
@@ -359,24 +748,17 @@ func calculateRateInterval(
 	return rateInterval
 }
 
-// interpolateVariables interpolates built-in variables
-// expr                         PromQL query
+// calculateIntervalContext calculates the values of the interval variables
 // queryInterval                Requested interval in milliseconds. This value may be overridden by MinStep in query options
 // calculatedStep               Calculated final step value. It was calculated in calculatePrometheusInterval
 // requestedMinStep             Requested minimum step value. QueryModel.interval
 // dsScrapeInterval             Data source scrape interval in the config
-// timeRange                    Requested time range for query
-func interpolateVariables(
-	expr string,
+func calculateIntervalContext(
 	queryInterval time.Duration,
 	calculatedStep time.Duration,
 	requestedMinStep string,
 	dsScrapeInterval string,
-	timeRange time.Duration,
-) string {
-	rangeMs := timeRange.Milliseconds()
-	rangeSRounded := int64(math.Round(float64(rangeMs) / 1000.0))
-
+) IntervalContext {
 	var rateInterval time.Duration
 	if requestedMinStep == varRateInterval || requestedMinStep == varRateIntervalAlt {
 		rateInterval = calculatedStep
@@ -390,25 +772,103 @@ func interpolateVariables(
 		rateInterval = calculateRateInterval(queryInterval, requestedMinStep)
 	}
 
-	expr = strings.ReplaceAll(expr, varIntervalMs, strconv.FormatInt(int64(calculatedStep/time.Millisecond), 10))
-	expr = strings.ReplaceAll(expr, varInterval, gtime.FormatInterval(calculatedStep))
+	return IntervalContext{
+		Interval:       gtime.FormatInterval(calculatedStep),
+		IntervalMs:     int64(calculatedStep / time.Millisecond),
+		RateInterval:   rateInterval.String(),
+		RateIntervalMs: int64(rateInterval / time.Millisecond),
+	}
+}
+
+// resolve returns the interval variables of c, with the ones it does not set taken from calculated
+func (c IntervalContext) resolve(calculated IntervalContext) (IntervalContext, error) {
+	interval, intervalMs, err := resolveIntervalVariable(c.Interval, c.IntervalMs, gtime.FormatInterval)
+	if err != nil {
+		return IntervalContext{}, fmt.Errorf("invalid interval context interval: %w", err)
+	}
+	rateInterval, rateIntervalMs, err := resolveIntervalVariable(c.RateInterval, c.RateIntervalMs, time.Duration.String)
+	if err != nil {
+		return IntervalContext{}, fmt.Errorf("invalid interval context rate interval: %w", err)
+	}
+
+	if interval == "" {
+		interval, intervalMs = calculated.Interval, calculated.IntervalMs
+	}
+	if rateInterval == "" {
+		rateInterval, rateIntervalMs = calculated.RateInterval, calculated.RateIntervalMs
+	}
+	return IntervalContext{Interval: interval, IntervalMs: intervalMs, RateInterval: rateInterval, RateIntervalMs: rateIntervalMs}, nil
+}
+
+// resolveIntervalVariable returns the duration and the milliseconds of an interval variable set either way,
+// empty when it is not set
+func resolveIntervalVariable(value string, ms int64, format func(time.Duration) string) (string, int64, error) {
+	if ms < 0 {
+		return "", 0, fmt.Errorf("negative milliseconds %d", ms)
+	}
+	if value == "" {
+		if ms == 0 {
+			return "", 0, nil
+		}
+		return format(time.Duration(ms) * time.Millisecond), ms, nil
+	}
+	if ms == 0 {
+		d, err := gtime.ParseIntervalStringToTimeDuration(value)
+		if err != nil {
+			return "", 0, err
+		}
+		ms = d.Milliseconds()
+	}
+	return value, ms, nil
+}
+
+// usesIntervalVariables returns whether expr uses $__interval or $__rate_interval, in any of their forms
+func usesIntervalVariables(expr string) bool {
+	return strings.Contains(expr, "__interval") || strings.Contains(expr, "__rate_interval")
+}
+
+// interpolateVariables interpolates built-in variables
+// expr                         PromQL query
+// intervals                    Values of the interval variables
+// timeRange                    Requested time range for query
+func interpolateVariables(
+	expr string,
+	intervals IntervalContext,
+	timeRange time.Duration,
+) string {
+	rangeMs := timeRange.Milliseconds()
+	rangeSRounded := int64(math.Round(float64(rangeMs) / 1000.0))
+
+	expr = strings.ReplaceAll(expr, varIntervalMs, strconv.FormatInt(intervals.IntervalMs, 10))
+	expr = strings.ReplaceAll(expr, varInterval, intervals.Interval)
 	expr = strings.ReplaceAll(expr, varRangeMs, strconv.FormatInt(rangeMs, 10))
 	expr = strings.ReplaceAll(expr, varRangeS, strconv.FormatInt(rangeSRounded, 10))
 	expr = strings.ReplaceAll(expr, varRange, strconv.FormatInt(rangeSRounded, 10)+"s")
-	expr = strings.ReplaceAll(expr, varRateIntervalMs, strconv.FormatInt(int64(rateInterval/time.Millisecond), 10))
-	expr = strings.ReplaceAll(expr, varRateInterval, rateInterval.String())
+	expr = strings.ReplaceAll(expr, varRateIntervalMs, strconv.FormatInt(intervals.RateIntervalMs, 10))
+	expr = strings.ReplaceAll(expr, varRateInterval, intervals.RateInterval)
 
 	// Repetitive code, we should have functionality to unify these
-	expr = strings.ReplaceAll(expr, varIntervalMsAlt, strconv.FormatInt(int64(calculatedStep/time.Millisecond), 10))
-	expr = strings.ReplaceAll(expr, varIntervalAlt, gtime.FormatInterval(calculatedStep))
+	expr = strings.ReplaceAll(expr, varIntervalMsAlt, strconv.FormatInt(intervals.IntervalMs, 10))
+	expr = strings.ReplaceAll(expr, varIntervalAlt, intervals.Interval)
 	expr = strings.ReplaceAll(expr, varRangeMsAlt, strconv.FormatInt(rangeMs, 10))
 	expr = strings.ReplaceAll(expr, varRangeSAlt, strconv.FormatInt(rangeSRounded, 10))
 	expr = strings.ReplaceAll(expr, varRangeAlt, strconv.FormatInt(rangeSRounded, 10)+"s")
-	expr = strings.ReplaceAll(expr, varRateIntervalMsAlt, strconv.FormatInt(int64(rateInterval/time.Millisecond), 10))
-	expr = strings.ReplaceAll(expr, varRateIntervalAlt, rateInterval.String())
+	expr = strings.ReplaceAll(expr, varRateIntervalMsAlt, strconv.FormatInt(intervals.RateIntervalMs, 10))
+	expr = strings.ReplaceAll(expr, varRateIntervalAlt, intervals.RateInterval)
 	return expr
 }
 
+// validateMaxSourceResolution checks the value is one Thanos accepts, auto or a duration
+func validateMaxSourceResolution(resolution string) error {
+	if resolution == "" || resolution == "auto" {
+		return nil
+	}
+	if _, err := gtime.ParseIntervalStringToTimeDuration(resolution); err != nil {
+		return fmt.Errorf("invalid max source resolution %q: %w", resolution, err)
+	}
+	return nil
+}
+
 func isVariableInterval(interval string) bool {
 	if interval == varInterval || interval == varIntervalMs || interval == varRateInterval || interval == varRateIntervalMs {
 		return true
@@ -420,6 +880,63 @@ func isVariableInterval(interval string) bool {
 	return false
 }
 
+// tagKeys splits a comma separated list of labels
+func tagKeys(keys string) []string {
+	var split []string
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			split = append(split, key)
+		}
+	}
+	return split
+}
+
+// TenantHeader is the header Mimir reads the tenants of a request from
+const TenantHeader = "X-Scope-OrgID"
+
+// withTenants returns headers with the tenant header of tenants, joined with | to federate several tenants
+func withTenants(headers map[string]string, tenants []string) (map[string]string, error) {
+	if len(tenants) == 0 {
+		return headers, nil
+	}
+	for _, tenant := range tenants {
+		if tenant == "" || strings.Contains(tenant, "|") {
+			return nil, fmt.Errorf("invalid tenant %q", tenant)
+		}
+	}
+
+	withTenants := make(map[string]string, len(headers)+1)
+	for name, value := range headers {
+		if strings.EqualFold(name, TenantHeader) {
+			return nil, fmt.Errorf("tenants and the %s header cannot both be set", TenantHeader)
+		}
+		withTenants[name] = value
+	}
+	withTenants[TenantHeader] = strings.Join(tenants, "|")
+	return withTenants, nil
+}
+
+// parseUtcOffset returns the offset from UTC in seconds of an offset like +02:00 or -0530, or of a
+// timezone name at t. The offset of a timezone may change within the range when it observes
+// daylight saving time, the one at its end is used.
+func parseUtcOffset(offset string, t time.Time) (int64, error) {
+	if offset == "Z" || offset == "UTC" {
+		return 0, nil
+	}
+	for _, layout := range []string{"-07:00", "-0700", "-07"} {
+		if parsed, err := time.Parse(layout, offset); err == nil {
+			_, sec := parsed.Zone()
+			return int64(sec), nil
+		}
+	}
+	loc, err := time.LoadLocation(offset)
+	if err != nil {
+		return 0, fmt.Errorf("invalid utcOffset %q, expected an offset like +02:00 or a timezone name", offset)
+	}
+	_, sec := t.In(loc).Zone()
+	return int64(sec), nil
+}
+
 // AlignTimeRange aligns query range to step and handles the time offset.
 // It rounds start and end down to a multiple of step.
 // Prometheus caching is dependent on the range being aligned with the step.
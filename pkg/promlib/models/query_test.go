@@ -35,7 +35,8 @@ func TestParse(t *testing.T) {
 		queryJson := `{
 			"expr": "go_goroutines",
 			"refId": "A",
-			"exemplar": true
+			"exemplar": true,
+			"format": "table"
 		}`
 
 		q := backend.DataQuery{
@@ -47,6 +48,7 @@ func TestParse(t *testing.T) {
 		res, err := models.Parse(span, q, "15s", intervalCalculator, true, false)
 		require.NoError(t, err)
 		require.Equal(t, false, res.ExemplarQuery)
+		require.Equal(t, models.PromQueryFormatTimeSeries, res.Format)
 	})
 
 	t.Run("parsing query model with step", func(t *testing.T) {
@@ -66,6 +68,414 @@ func TestParse(t *testing.T) {
 		require.Equal(t, time.Second*30, res.Step)
 	})
 
+	t.Run("parsing query model with fixed step override", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(12 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "rate(go_goroutines[$__interval])",
+			"step": "2m",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, time.Minute*2, res.Step)
+		require.Equal(t, "rate(go_goroutines[2m])", res.Expr)
+	})
+
+	t.Run("parsing query model with fixed step over the point limit", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(96 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"step": "30s",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		// 30s is 11520 points, over the limit of Prometheus, the range is queried in two parts
+		require.Equal(t, 30*time.Second, res.Step)
+		require.Equal(t, &models.StepAdjustment{
+			RequestedStep: "30s",
+			Step:          "30s",
+			Chunks:        2,
+			Reason:        "Queried in 2 parts to respect the limit of 11000 points per series over 4d",
+		}, res.StepAdjustment)
+
+		// Steps needing too many parts are increased to the smallest step under the limit of all the parts
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"step": "1s",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+		res, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, 3143*time.Millisecond, res.Step)
+		require.Equal(t, 10, res.StepAdjustment.Chunks)
+		require.Equal(t, "Step increased to 3.143s to respect the limit of 11000 points per series over 4d in 10 parts", res.StepAdjustment.Reason)
+	})
+
+	t.Run("parsing query model with a step limited over the queried range", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(time.Hour),
+		}
+
+		// The offset extends the data read to 4h, over the limit with a step of 1s
+		q := queryContext(`{
+			"expr": "go_goroutines offset 3h",
+			"step": "1s",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, time.Second, res.Step)
+		require.Equal(t, 2, res.StepAdjustment.Chunks)
+		require.Equal(t, "Queried in 2 parts to respect the limit of 11000 points per series over 4h", res.StepAdjustment.Reason)
+		require.Equal(t, now.Add(-3*time.Hour), res.QueriedStart)
+	})
+
+	t.Run("parsing query model with a step respecting max data points", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+		q.MaxDataPoints = 1500
+
+		res, err := models.Parse(span, q, "1s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		// The interval is calculated from max data points, the step is under the point limit
+		require.Equal(t, time.Second*2, res.Step)
+		require.Nil(t, res.StepAdjustment)
+
+		// Fixed steps are only limited by Prometheus
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"step": "1s",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+		q.MaxDataPoints = 1500
+		res, err = models.Parse(span, q, "1s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, time.Second, res.Step)
+		require.Nil(t, res.StepAdjustment)
+	})
+
+	t.Run("parsing query model with invalid fixed step", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"step": "often",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with Thanos options", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"partialResponse": true,
+			"maxSourceResolution": "auto",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.NotNil(t, res.PartialResponse)
+		require.True(t, *res.PartialResponse)
+		require.Equal(t, "auto", res.MaxSourceResolution)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"maxSourceResolution": "sometimes",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with timeout", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"timeout": "2m",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, 2*time.Minute, res.Timeout)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"timeout": "forever",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with utcOffset", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: time.Date(2024, 7, 1, 10, 7, 0, 0, time.UTC),
+			To:   time.Date(2024, 7, 2, 10, 7, 0, 0, time.UTC),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"step": "1d",
+			"utcOffset": "+02:00",
+			"utcOffsetSec": 3600,
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, int64(7200), res.UtcOffsetSec)
+		// Daily steps start at midnight in the timezone of the dashboard
+		require.Equal(t, time.Date(2024, 6, 30, 22, 0, 0, 0, time.UTC), res.TimeRange().Start)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"utcOffset": "America/New_York",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, int64(-4*3600), res.UtcOffsetSec)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"utcOffset": "Mars/Olympus_Mons",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with adhoc filters", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "sum(rate(http_requests_total{job=\"api\", code=\"200\"}[5m])) by (code)",
+			"adhocFilters": [
+				{"key": "namespace", "operator": "equals", "value": "prod"},
+				{"key": "code", "operator": "regex-match", "value": "5.."}
+			],
+			"groupByKeys": ["namespace"],
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		// Ad-hoc filters are applied whether the promQLScope feature is enabled or not, group by keys only with it
+		for enableScope, expected := range map[bool]string{
+			false: `sum by (code) (rate(http_requests_total{code=~"5..",job="api",namespace="prod"}[5m]))`,
+			true:  `sum by (code, namespace) (rate(http_requests_total{code=~"5..",job="api",namespace="prod"}[5m]))`,
+		} {
+			res, err := models.Parse(span, q, "15s", intervalCalculator, false, enableScope)
+			require.NoError(t, err)
+			require.Equal(t, expected, res.Expr, "promQLScope enabled: %t", enableScope)
+		}
+	})
+
+	t.Run("parsing query model with tenants", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"tenants": ["team-a", "team-b"],
+			"headers": {"X-Trace-Sampled": "1"},
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"X-Scope-OrgID": "team-a|team-b", "X-Trace-Sampled": "1"}, res.Headers)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"tenants": ["team-a"],
+			"headers": {"x-scope-orgid": "team-c"},
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"tenants": ["team-a|team-c"],
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with lookback delta", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"instant": true,
+			"lookbackDelta": "15m",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, 15*time.Minute, res.LookbackDelta)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"lookbackDelta": "-5m",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with histogram buckets", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "rate(http_request_duration_seconds[5m])",
+			"format": "heatmap",
+			"histogramBuckets": 20,
+			"histogramBucketLayout": "log",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, 20, res.HistogramBuckets)
+		require.Equal(t, models.HistogramBucketLayoutLog, res.HistogramBucketLayout)
+
+		for _, invalid := range []string{`"histogramBuckets": 1001`, `"histogramBuckets": -1`, `"histogramBucketLayout": "exponential"`} {
+			q = queryContext(`{
+				"expr": "rate(http_request_duration_seconds[5m])",
+				`+invalid+`,
+				"refId": "A"
+			}`, timeRange, time.Duration(1)*time.Minute)
+
+			_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+			require.Error(t, err, invalid)
+		}
+
+		q = queryContext(`{
+			"expr": "rate(http_request_duration_seconds[5m])",
+			"histogramBuckets": -1,
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.EqualError(t, err, "invalid histogram buckets -1, expected a value between 1 and 1000, or 0 to keep the buckets of the data source")
+
+		// Zero keeps the buckets of the data source
+		q = queryContext(`{
+			"expr": "rate(http_request_duration_seconds[5m])",
+			"histogramBuckets": 0,
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+		res, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Zero(t, res.HistogramBuckets)
+	})
+
+	t.Run("parsing query model with priority", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"priority": "background",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, models.QueryPriorityBackground, res.Priority)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"priority": "urgent",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.ErrorContains(t, err, "invalid priority")
+	})
+
+	t.Run("parsing query model with non-finite values handling", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"nonFiniteValues": "interpolate",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, models.NonFiniteValuesInterpolate, res.NonFiniteValues)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"nonFiniteValues": "zero",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.ErrorContains(t, err, "invalid non-finite values handling")
+	})
+
 	t.Run("parsing query model without step parameter", func(t *testing.T) {
 		timeRange := backend.TimeRange{
 			From: now,
@@ -102,6 +512,71 @@ func TestParse(t *testing.T) {
 		require.Equal(t, time.Minute*20, res.Step)
 	})
 
+	t.Run("parsing query model with resolution", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		// resolution takes precedence over intervalFactor
+		q := queryContext(`{
+			"expr": "go_goroutines",
+			"format": "time_series",
+			"intervalFactor": 1,
+			"resolution": 10,
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, time.Minute*20, res.Step)
+
+		q = queryContext(`{
+			"expr": "go_goroutines",
+			"resolution": 11,
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with subquery step", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "max_over_time(rate(http_requests_total[5m])[1h:])",
+			"subqueryStep": "30s",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, "max_over_time(rate(http_requests_total[5m])[1h:30s])", res.Expr)
+
+		q = queryContext(`{
+			"expr": "max_over_time(rate(http_requests_total[5m])[1h:])",
+			"subqueryStep": "$__interval",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		res, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, "max_over_time(rate(http_requests_total[5m])[1h:2m])", res.Expr)
+
+		q = queryContext(`{
+			"expr": "max_over_time(up[1h:])",
+			"subqueryStep": "often",
+			"refId": "A"
+		}`, timeRange, time.Duration(1)*time.Minute)
+
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.Error(t, err)
+	})
+
 	t.Run("parsing query model with low intervalFactor", func(t *testing.T) {
 		timeRange := backend.TimeRange{
 			From: now,
@@ -436,6 +911,64 @@ func TestParse(t *testing.T) {
 		require.Equal(t, "rate(ALERTS{job=\"test\" [135000]}) + rate(ALERTS{job=\"test\" [2m15s]})", res.Expr)
 	})
 
+	t.Run("parsing query model with interval context", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "rate(ALERTS{job=\"test\" [$__rate_interval]}) / $__interval_ms",
+			"format": "time_series",
+			"refId": "A",
+			"intervalContext": {"interval": "1m", "rateIntervalMs": 300000}
+		}`, timeRange, 2*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, "rate(ALERTS{job=\"test\" [5m0s]}) / 60000", res.Expr)
+		require.Equal(t, &models.IntervalContext{Interval: "1m", IntervalMs: 60000, RateInterval: "5m0s", RateIntervalMs: 300000}, res.IntervalContext)
+		// The step is still calculated by the backend
+		require.Equal(t, 2*time.Minute, res.Step)
+	})
+
+	t.Run("parsing query model without interval context resolves the interval variables it uses", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		q := queryContext(`{
+			"expr": "rate(ALERTS{job=\"test\" [$__rate_interval]})",
+			"format": "time_series",
+			"refId": "A"
+		}`, timeRange, 2*time.Minute)
+
+		res, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Equal(t, &models.IntervalContext{Interval: "2m", IntervalMs: 120000, RateInterval: "2m15s", RateIntervalMs: 135000}, res.IntervalContext)
+
+		q = queryContext(`{"expr": "up", "refId": "A"}`, timeRange, 2*time.Minute)
+		res, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.NoError(t, err)
+		require.Nil(t, res.IntervalContext)
+	})
+
+	t.Run("parsing query model with invalid interval context", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		q := queryContext(`{"expr": "up", "refId": "A", "intervalContext": {"interval": "soon"}}`, timeRange, 2*time.Minute)
+		_, err := models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.ErrorContains(t, err, "invalid interval context interval")
+
+		q = queryContext(`{"expr": "up", "refId": "A", "intervalContext": {"rateIntervalMs": -1}}`, timeRange, 2*time.Minute)
+		_, err = models.Parse(span, q, "15s", intervalCalculator, false, false)
+		require.ErrorContains(t, err, "invalid interval context rate interval")
+	})
+
 	t.Run("parsing query model with legacy datasource reference", func(t *testing.T) {
 		timeRange := backend.TimeRange{
 			From: now,
@@ -822,6 +1355,9 @@ func TestQueryTypeDefinitions(t *testing.T) {
 			Enums: []reflect.Type{
 				reflect.TypeOf(models.PromQueryFormatTimeSeries), // pick an example value (not the root)
 				reflect.TypeOf(models.QueryEditorModeBuilder),
+				reflect.TypeOf(models.HistogramBucketLayoutLinear),
+				reflect.TypeOf(models.QueryPriorityInteractive),
+				reflect.TypeOf(models.NonFiniteValuesKeep),
 			},
 		})
 	require.NoError(t, err)
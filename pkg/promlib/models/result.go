@@ -20,13 +20,14 @@ func ResultTypeFromFrame(frame *data.Frame) ResultType {
 	if frame.Meta.Custom == nil {
 		return ResultTypeUnknown
 	}
-	custom, ok := frame.Meta.Custom.(map[string]string)
-	if !ok {
-		return ResultTypeUnknown
-	}
-
-	rt, ok := custom["resultType"]
-	if !ok {
+	var rt string
+	switch custom := frame.Meta.Custom.(type) {
+	case map[string]string:
+		rt = custom["resultType"]
+	case map[string]any:
+		// the result type is kept next to other metadata, like query stats
+		rt, _ = custom["resultType"].(string)
+	default:
 		return ResultTypeUnknown
 	}
 
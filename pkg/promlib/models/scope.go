@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
@@ -79,10 +80,12 @@ func filtersToMatchers(scopeFilters, adhocFilters []ScopeFilter) ([]*labels.Matc
 		filterMap[filter.Key] = matcher
 	}
 
+	// Matchers are sorted so the same filters always produce the same expression
 	matchers := make([]*labels.Matcher, 0, len(filterMap))
 	for _, matcher := range filterMap {
 		matchers = append(matchers, matcher)
 	}
+	sort.Slice(matchers, func(i, j int) bool { return matchers[i].Name < matchers[j].Name })
 
 	return matchers, nil
 }
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+)
+
+// maxStepChunks bounds the number of consecutive ranges a query with a fixed step is split in to
+// respect the point limit of Prometheus. Past it the step is increased.
+const maxStepChunks = 10
+
+// StepAdjustment tells how a range query was adjusted to the limit of safeResolution points per
+// series of Prometheus
+type StepAdjustment struct {
+	// The step of the query before it was adjusted
+	RequestedStep string `json:"requestedStep"`
+	Step          string `json:"step"`
+	// The number of consecutive ranges the query is split in, 1 when it is not split
+	Chunks int    `json:"chunks"`
+	Reason string `json:"reason"`
+}
+
+// limitStep returns the step to query with instead of step so the series of the query over timeRange
+// have at most safeResolution points. A fixed step is kept when the range can be queried in at most
+// maxStepChunks consecutive chunks, the others are increased to the smallest step under the limit.
+// The returned adjustment is nil when the query is not changed.
+func limitStep(step, timeRange time.Duration, fixed bool) (time.Duration, *StepAdjustment) {
+	// Aligning the range to the step can add up to two points
+	pointsPerChunk := int64(safeResolution) - 2
+	if timeRange <= 0 || step <= 0 || int64(timeRange/step) <= pointsPerChunk {
+		return step, nil
+	}
+
+	adjustment := &StepAdjustment{RequestedStep: formatStep(step), Chunks: 1}
+	if fixed {
+		chunks := ceilDiv(int64(timeRange/step), pointsPerChunk)
+		if chunks <= maxStepChunks {
+			adjustment.Step = adjustment.RequestedStep
+			adjustment.Chunks = int(chunks)
+			adjustment.Reason = fmt.Sprintf("Queried in %d parts to respect the limit of %d points per series over %s", chunks, safeResolution, gtime.FormatInterval(timeRange))
+			return step, adjustment
+		}
+		pointsPerChunk *= maxStepChunks
+		adjustment.Chunks = maxStepChunks
+	}
+
+	limited := time.Duration(ceilDiv(int64(timeRange), pointsPerChunk*int64(time.Millisecond))) * time.Millisecond
+	adjustment.Step = formatStep(limited)
+	adjustment.Reason = fmt.Sprintf("Step increased to %s to respect the limit of %d points per series over %s", adjustment.Step, safeResolution, gtime.FormatInterval(timeRange))
+	if adjustment.Chunks > 1 {
+		adjustment.Reason += fmt.Sprintf(" in %d parts", adjustment.Chunks)
+	}
+	return limited, adjustment
+}
+
+// formatStep formats step like gtime.FormatInterval, without truncating the milliseconds of steps over a second
+func formatStep(step time.Duration) string {
+	if step > time.Second && step%time.Second != 0 {
+		return step.String()
+	}
+	return gtime.FormatInterval(step)
+}
+
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,57 @@
+package models
+
+import "fmt"
+
+// VariableQueryType defines the kind of values a template variable query returns.
+// +enum
+type VariableQueryType string
+
+const (
+	// The names of the labels of the series matching match, or of all the series
+	VariableQueryTypeLabelNames VariableQueryType = "label_names"
+	// The values of label in the series matching match, or in all the series
+	VariableQueryTypeLabelValues VariableQueryType = "label_values"
+	// The series of the instant vector returned by query, with their value and timestamp
+	VariableQueryTypeQueryResult VariableQueryType = "query_result"
+	// The series matching match
+	VariableQueryTypeSeries VariableQueryType = "series"
+)
+
+// PrometheusVariableQuery is the query of a template variable of a Prometheus data source
+type PrometheusVariableQuery struct {
+	// The kind of values the variable query returns
+	QueryType VariableQueryType `json:"queryType"`
+
+	// The label the values of are returned, for label_values queries
+	Label string `json:"label,omitempty"`
+
+	// A series selector (e.g. up{job="api"}) restricting the series read by label_names and label_values
+	// queries, and selecting the series of series queries
+	Match string `json:"match,omitempty"`
+
+	// The PromQL expression evaluated by query_result queries
+	Query string `json:"query,omitempty"`
+}
+
+// Validate returns an error when a property required by the type of the query is missing
+func (q PrometheusVariableQuery) Validate() error {
+	switch q.QueryType {
+	case VariableQueryTypeLabelNames:
+		return nil
+	case VariableQueryTypeLabelValues:
+		if q.Label == "" {
+			return fmt.Errorf("label is required for %s queries", q.QueryType)
+		}
+	case VariableQueryTypeQueryResult:
+		if q.Query == "" {
+			return fmt.Errorf("query is required for %s queries", q.QueryType)
+		}
+	case VariableQueryTypeSeries:
+		if q.Match == "" {
+			return fmt.Errorf("match is required for %s queries", q.QueryType)
+		}
+	default:
+		return fmt.Errorf("unknown variable query type %q", q.QueryType)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package querydata
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// annotationEvent spans consecutive samples of a series with a positive value
+type annotationEvent struct {
+	time, timeEnd int64
+	title, text   string
+	tags          []string
+}
+
+// toAnnotationFrame converts the series frames of frames to a frame of annotation events, like the
+// annotations of the frontend. The samples of a series with a positive value that are at most a step
+// apart are grouped into a single event. Other frames are dropped.
+func toAnnotationFrame(q *models.Query, frames data.Frames) data.Frames {
+	step := q.Step.Milliseconds()
+
+	var events []annotationEvent
+	for _, frame := range frames {
+		if !isSeriesFrame(frame) {
+			continue
+		}
+		labels := frame.Fields[1].Labels
+
+		var tags []string
+		for _, key := range q.TagKeys {
+			if v, ok := labels[key]; ok {
+				tags = append(tags, v)
+			}
+		}
+
+		var event *annotationEvent
+		for i := 0; i < frame.Rows(); i++ {
+			v := frame.Fields[1].At(i).(float64)
+			ts := frame.Fields[0].At(i).(time.Time).UnixMilli()
+			if q.UseValueForTime {
+				ts, v = int64(math.Floor(v)), 1
+			}
+			if !(v > 0) {
+				continue
+			}
+
+			if event != nil && event.timeEnd+step >= ts {
+				event.timeEnd = ts
+				continue
+			}
+			if event != nil {
+				events = append(events, *event)
+			}
+			event = &annotationEvent{
+				time:    ts,
+				timeEnd: ts,
+				title:   renderLegendFormat(q.TitleFormat, labels),
+				text:    renderLegendFormat(q.TextFormat, labels),
+				tags:    tags,
+			}
+		}
+		if event != nil {
+			events = append(events, *event)
+		}
+	}
+
+	return data.Frames{annotationFrame(q, events)}
+}
+
+func annotationFrame(q *models.Query, events []annotationEvent) *data.Frame {
+	times := make([]time.Time, len(events))
+	timeEnds := make([]time.Time, len(events))
+	titles := make([]string, len(events))
+	texts := make([]string, len(events))
+	tags := make([]json.RawMessage, len(events))
+	for i, e := range events {
+		times[i] = time.UnixMilli(e.time).UTC()
+		timeEnds[i] = time.UnixMilli(e.timeEnd).UTC()
+		titles[i] = e.title
+		texts[i] = e.text
+		if e.tags == nil {
+			e.tags = []string{}
+		}
+		tags[i], _ = json.Marshal(e.tags)
+	}
+
+	frame := data.NewFrame("annotations",
+		data.NewField("time", nil, times),
+		data.NewField("timeEnd", nil, timeEnds),
+		data.NewField("title", nil, titles),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+	)
+	frame.RefID = q.RefId
+	frame.Meta = &data.FrameMeta{
+		DataTopic:           data.DataTopicAnnotations,
+		ExecutedQueryString: executedQueryString(q, nil),
+	}
+	return frame
+}
+
+// renderLegendFormat replaces the {{label}} placeholders of format with the values of labels.
+// Unknown labels are replaced with their name, as the frontend does.
+func renderLegendFormat(format string, labels data.Labels) string {
+	return legendFormatRegexp.ReplaceAllStringFunc(format, func(in string) string {
+		name := legendFormatRegexp.FindStringSubmatch(in)[1]
+		if v := labels[name]; v != "" {
+			return v
+		}
+		return name
+	})
+}
@@ -0,0 +1,58 @@
+package querydata
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestToAnnotationFrame(t *testing.T) {
+	series := func(labels data.Labels, values ...float64) *data.Frame {
+		times := make([]time.Time, len(values))
+		for i := range values {
+			times[i] = time.UnixMilli(int64(60000 * (i + 1)))
+		}
+		return data.NewFrame("",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+			data.NewField(data.TimeSeriesValueFieldName, labels, values),
+		)
+	}
+	q := &models.Query{
+		RefId:       "X",
+		Expr:        "ALERTS",
+		Step:        time.Minute,
+		TagKeys:     []string{"severity", "team"},
+		TitleFormat: "{{alertname}} on {{ instance }}",
+		TextFormat:  "{{summary}}",
+	}
+
+	frames := toAnnotationFrame(q, data.Frames{
+		series(data.Labels{"alertname": "Down", "instance": "a", "severity": "page"}, 1, 1, 0, 0, 1),
+		series(data.Labels{"alertname": "Slow"}, 0, 0),
+	})
+	require.Len(t, frames, 1)
+
+	frame := frames[0]
+	require.Equal(t, "X", frame.RefID)
+	require.Equal(t, data.DataTopicAnnotations, frame.Meta.DataTopic)
+	require.Equal(t, 2, frame.Rows())
+	require.Equal(t, []any{
+		time.UnixMilli(60000).UTC(), time.UnixMilli(120000).UTC(), "Down on a", "summary", json.RawMessage(`["page"]`),
+	}, frame.RowCopy(0))
+	require.Equal(t, []any{
+		time.UnixMilli(300000).UTC(), time.UnixMilli(300000).UTC(), "Down on a", "summary", json.RawMessage(`["page"]`),
+	}, frame.RowCopy(1))
+
+	t.Run("values are used as time", func(t *testing.T) {
+		q := &models.Query{Step: time.Minute, UseValueForTime: true}
+		frames := toAnnotationFrame(q, data.Frames{series(data.Labels{"job": "deploy"}, 1700000000000, 1700000000000)})
+		require.Equal(t, 1, frames[0].Rows())
+		require.Equal(t, time.UnixMilli(1700000000000).UTC(), frames[0].Fields[0].At(0))
+		require.Equal(t, json.RawMessage(`[]`), frames[0].Fields[4].At(0))
+	})
+}
@@ -0,0 +1,417 @@
+package querydata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// metricMetadata is the metadata of a metric as returned by /api/v1/metadata
+type metricMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// ruleGroups is the data of a /api/v1/rules response
+type ruleGroups struct {
+	Groups []ruleGroup `json:"groups"`
+}
+
+type ruleGroup struct {
+	Name     string  `json:"name"`
+	File     string  `json:"file"`
+	Interval float64 `json:"interval"`
+	Rules    []rule  `json:"rules"`
+}
+
+// rule is either an alerting or a recording rule, the fields of alerting rules are empty for recording rules
+type rule struct {
+	Type           string            `json:"type"`
+	Name           string            `json:"name"`
+	Query          string            `json:"query"`
+	Labels         map[string]string `json:"labels"`
+	Health         string            `json:"health"`
+	LastError      string            `json:"lastError"`
+	EvaluationTime float64           `json:"evaluationTime"`
+	LastEvaluation time.Time         `json:"lastEvaluation"`
+
+	State       string            `json:"state"`
+	Duration    float64           `json:"duration"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// activeAlerts is the data of a /api/v1/alerts response
+type activeAlerts struct {
+	Alerts []alert `json:"alerts"`
+}
+
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    time.Time         `json:"activeAt"`
+	Value       string            `json:"value"`
+}
+
+// scrapeTargets is the data of a /api/v1/targets response
+type scrapeTargets struct {
+	ActiveTargets []scrapeTarget `json:"activeTargets"`
+}
+
+type scrapeTarget struct {
+	Labels             map[string]string `json:"labels"`
+	ScrapePool         string            `json:"scrapePool"`
+	ScrapeURL          string            `json:"scrapeUrl"`
+	Health             string            `json:"health"`
+	LastError          string            `json:"lastError"`
+	LastScrape         time.Time         `json:"lastScrape"`
+	LastScrapeDuration float64           `json:"lastScrapeDuration"`
+	ScrapeInterval     string            `json:"scrapeInterval"`
+	ScrapeTimeout      string            `json:"scrapeTimeout"`
+}
+
+// tsdbStatus is the data of a /api/v1/status/tsdb response
+type tsdbStatus struct {
+	HeadStats                   headStats   `json:"headStats"`
+	SeriesCountByMetricName     []statEntry `json:"seriesCountByMetricName"`
+	LabelValueCountByLabelName  []statEntry `json:"labelValueCountByLabelName"`
+	MemoryInBytesByLabelName    []statEntry `json:"memoryInBytesByLabelName"`
+	SeriesCountByLabelValuePair []statEntry `json:"seriesCountByLabelValuePair"`
+}
+
+type headStats struct {
+	NumSeries     uint64 `json:"numSeries"`
+	NumLabelPairs int64  `json:"numLabelPairs"`
+	ChunkCount    int64  `json:"chunkCount"`
+	MinTime       int64  `json:"minTime"`
+	MaxTime       int64  `json:"maxTime"`
+}
+
+type statEntry struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// handleAPIQuery runs queries that read an endpoint of the Prometheus HTTP API instead of evaluating PromQL
+func (s *QueryData) handleAPIQuery(ctx context.Context, bq backend.DataQuery, queryType models.QueryType) *backend.DataResponse {
+	model := &models.PrometheusQueryProperties{}
+	if err := json.Unmarshal(bq.JSON, model); err != nil {
+		return &backend.DataResponse{
+			Error: err,
+		}
+	}
+
+	var r backend.DataResponse
+	switch queryType {
+	case models.QueryTypeMetadata:
+		r = s.metadataQuery(ctx, s.client, model)
+	case models.QueryTypeRules:
+		r = s.rulesQuery(ctx, s.client, model)
+	case models.QueryTypeAlerts:
+		r = s.alertsQuery(ctx, s.client, model)
+	case models.QueryTypeTargets:
+		r = s.targetsQuery(ctx, s.client, model)
+	case models.QueryTypeTSDBStatus:
+		r = s.tsdbStatusQuery(ctx, s.client, model)
+	default:
+		return &backend.DataResponse{
+			Error: fmt.Errorf("unsupported query type %q", queryType),
+		}
+	}
+
+	for _, frame := range r.Frames {
+		frame.RefID = bq.RefID
+	}
+	return &r
+}
+
+func (s *QueryData) metadataQuery(ctx context.Context, c *client.Client, model *models.PrometheusQueryProperties) backend.DataResponse {
+	qv := map[string]string{}
+	if model.Expr != "" {
+		qv["metric"] = model.Expr
+	}
+	return apiQuery(ctx, s, c, "api/v1/metadata", client.WithCustomParameters(qv, model.CustomQueryParameters), singleFrame(metadataFrame))
+}
+
+func (s *QueryData) rulesQuery(ctx context.Context, c *client.Client, model *models.PrometheusQueryProperties) backend.DataResponse {
+	qv := client.WithCustomParameters(map[string]string{}, model.CustomQueryParameters)
+	return apiQuery(ctx, s, c, "api/v1/rules", qv, singleFrame(rulesFrame))
+}
+
+func (s *QueryData) alertsQuery(ctx context.Context, c *client.Client, model *models.PrometheusQueryProperties) backend.DataResponse {
+	qv := client.WithCustomParameters(map[string]string{}, model.CustomQueryParameters)
+	return apiQuery(ctx, s, c, "api/v1/alerts", qv, singleFrame(alertsFrame))
+}
+
+func (s *QueryData) targetsQuery(ctx context.Context, c *client.Client, model *models.PrometheusQueryProperties) backend.DataResponse {
+	// Dropped targets are not scraped, they have no health to report
+	qv := client.WithCustomParameters(map[string]string{"state": "active"}, model.CustomQueryParameters)
+	return apiQuery(ctx, s, c, "api/v1/targets", qv, singleFrame(targetsFrame))
+}
+
+func (s *QueryData) tsdbStatusQuery(ctx context.Context, c *client.Client, model *models.PrometheusQueryProperties) backend.DataResponse {
+	qv := client.WithCustomParameters(map[string]string{}, model.CustomQueryParameters)
+	return apiQuery(ctx, s, c, "api/v1/status/tsdb", qv, tsdbStatusFrames)
+}
+
+// apiQuery calls an API endpoint and converts the data of its response to frames with toFrames
+func apiQuery[T any](ctx context.Context, s *QueryData, c *client.Client, endpoint string, qv map[string]string, toFrames func(T) data.Frames) backend.DataResponse {
+	res, err := c.QueryAPI(ctx, endpoint, qv)
+	if err != nil {
+		return backend.DataResponse{
+			Error:  err,
+			Status: backend.StatusBadGateway,
+		}
+	}
+
+	rsp, err := client.DecodeAPIResponse[T](res, s.log.FromContext(ctx))
+	if err != nil {
+		return backend.DataResponse{
+			Error:  err,
+			Status: backend.Status(res.StatusCode),
+		}
+	}
+
+	frames := toFrames(rsp.Data)
+	if len(frames) > 0 {
+		frames[0].AppendNotices(warningNotices(rsp.Warnings)...)
+	}
+	return backend.DataResponse{
+		Frames: frames,
+		Status: backend.Status(res.StatusCode),
+	}
+}
+
+// singleFrame adapts a conversion to a single frame for apiQuery
+func singleFrame[T any](toFrame func(T) *data.Frame) func(T) data.Frames {
+	return func(d T) data.Frames {
+		return data.Frames{toFrame(d)}
+	}
+}
+
+// metadataFrame returns a table frame with a row for each metric metadata entry, sorted by metric name
+func metadataFrame(metadata map[string][]metricMetadata) *data.Frame {
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics, types, helps, units := []string{}, []string{}, []string{}, []string{}
+	for _, name := range names {
+		for _, m := range metadata[name] {
+			metrics = append(metrics, name)
+			types = append(types, m.Type)
+			helps = append(helps, m.Help)
+			units = append(units, m.Unit)
+		}
+	}
+
+	frame := data.NewFrame("",
+		data.NewField("metric", nil, metrics),
+		data.NewField("type", nil, types),
+		data.NewField("help", nil, helps),
+		data.NewField("unit", nil, units),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
+
+// rulesFrame returns a table frame with a row for each rule, with the group it belongs to
+func rulesFrame(rules ruleGroups) *data.Frame {
+	frame := data.NewFrame("",
+		data.NewField("group", nil, []string{}),
+		data.NewField("file", nil, []string{}),
+		data.NewField("name", nil, []string{}),
+		data.NewField("type", nil, []string{}),
+		data.NewField("query", nil, []string{}),
+		data.NewField("labels", nil, []string{}),
+		data.NewField("annotations", nil, []string{}),
+		data.NewField("duration", nil, []float64{}).SetConfig(&data.FieldConfig{Unit: "s"}),
+		data.NewField("state", nil, []string{}),
+		data.NewField("health", nil, []string{}),
+		data.NewField("lastError", nil, []string{}),
+		data.NewField("lastEvaluation", nil, []time.Time{}),
+		data.NewField("evaluationTime", nil, []float64{}).SetConfig(&data.FieldConfig{Unit: "s"}),
+		data.NewField("interval", nil, []float64{}).SetConfig(&data.FieldConfig{Unit: "s"}),
+	)
+	for _, g := range rules.Groups {
+		for _, r := range g.Rules {
+			frame.AppendRow(
+				g.Name,
+				g.File,
+				r.Name,
+				r.Type,
+				r.Query,
+				data.Labels(r.Labels).String(),
+				data.Labels(r.Annotations).String(),
+				r.Duration,
+				r.State,
+				r.Health,
+				r.LastError,
+				r.LastEvaluation.UTC(),
+				r.EvaluationTime,
+				g.Interval,
+			)
+		}
+	}
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
+
+// targetsFrame returns a table frame with a row for each active scrape target
+func targetsFrame(targets scrapeTargets) *data.Frame {
+	frame := data.NewFrame("",
+		data.NewField("scrapePool", nil, []string{}),
+		data.NewField("scrapeUrl", nil, []string{}),
+		data.NewField("labels", nil, []string{}),
+		data.NewField("health", nil, []string{}),
+		data.NewField("lastError", nil, []string{}),
+		data.NewField("lastScrape", nil, []time.Time{}),
+		data.NewField("lastScrapeDuration", nil, []float64{}).SetConfig(&data.FieldConfig{Unit: "s"}),
+		data.NewField("scrapeInterval", nil, []string{}),
+		data.NewField("scrapeTimeout", nil, []string{}),
+	)
+	for _, t := range targets.ActiveTargets {
+		frame.AppendRow(
+			t.ScrapePool,
+			t.ScrapeURL,
+			data.Labels(t.Labels).String(),
+			t.Health,
+			t.LastError,
+			t.LastScrape.UTC(),
+			t.LastScrapeDuration,
+			t.ScrapeInterval,
+			t.ScrapeTimeout,
+		)
+	}
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
+
+// tsdbStatusFrames returns a table frame with the head block statistics,
+// followed by a table frame for each of the top lists of the cardinality statistics
+func tsdbStatusFrames(status tsdbStatus) data.Frames {
+	head := data.NewFrame("headStats",
+		data.NewField("numSeries", nil, []uint64{status.HeadStats.NumSeries}),
+		data.NewField("numLabelPairs", nil, []int64{status.HeadStats.NumLabelPairs}),
+		data.NewField("chunkCount", nil, []int64{status.HeadStats.ChunkCount}),
+		data.NewField("minTime", nil, []time.Time{time.UnixMilli(status.HeadStats.MinTime).UTC()}),
+		data.NewField("maxTime", nil, []time.Time{time.UnixMilli(status.HeadStats.MaxTime).UTC()}),
+	)
+	head.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+
+	return data.Frames{
+		head,
+		statEntriesFrame("seriesCountByMetricName", "metric", "series", status.SeriesCountByMetricName),
+		statEntriesFrame("labelValueCountByLabelName", "label", "values", status.LabelValueCountByLabelName),
+		statEntriesFrame("memoryInBytesByLabelName", "label", "bytes", status.MemoryInBytesByLabelName),
+		statEntriesFrame("seriesCountByLabelValuePair", "labelValuePair", "series", status.SeriesCountByLabelValuePair),
+	}
+}
+
+func statEntriesFrame(name, nameField, valueField string, entries []statEntry) *data.Frame {
+	names := make([]string, len(entries))
+	values := make([]uint64, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+		values[i] = e.Value
+	}
+
+	frame := data.NewFrame(name,
+		data.NewField(nameField, nil, names),
+		data.NewField(valueField, nil, values),
+	)
+	if valueField == "bytes" {
+		frame.Fields[1].SetConfig(&data.FieldConfig{Unit: "bytes"})
+	}
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
+
+// alertsFrame returns a table frame with a row for each pending or firing alert.
+// Each label gets its own column, as does each annotation, prefixed with annotation_ to avoid conflicts.
+// Alerts without a label or annotation have an empty value in its column.
+func alertsFrame(alerts activeAlerts) *data.Frame {
+	var labelKeys, annotationKeys []string
+	seenLabels, seenAnnotations := map[string]struct{}{}, map[string]struct{}{}
+	for _, a := range alerts.Alerts {
+		labelKeys = appendNewKeys(labelKeys, seenLabels, a.Labels)
+		annotationKeys = appendNewKeys(annotationKeys, seenAnnotations, a.Annotations)
+	}
+	sort.Strings(labelKeys)
+	sort.Strings(annotationKeys)
+
+	activeAt := make([]time.Time, len(alerts.Alerts))
+	states := make([]string, len(alerts.Alerts))
+	values := make([]*float64, len(alerts.Alerts))
+	labels := make([][]string, len(labelKeys))
+	for i := range labels {
+		labels[i] = make([]string, len(alerts.Alerts))
+	}
+	annotations := make([][]string, len(annotationKeys))
+	for i := range annotations {
+		annotations[i] = make([]string, len(alerts.Alerts))
+	}
+
+	for row, a := range alerts.Alerts {
+		activeAt[row] = a.ActiveAt.UTC()
+		states[row] = a.State
+		if v, err := strconv.ParseFloat(a.Value, 64); err == nil {
+			values[row] = &v
+		}
+		for i, key := range labelKeys {
+			labels[i][row] = a.Labels[key]
+		}
+		for i, key := range annotationKeys {
+			annotations[i][row] = a.Annotations[key]
+		}
+	}
+
+	frame := data.NewFrame("",
+		data.NewField("activeAt", nil, activeAt),
+		data.NewField("state", nil, states),
+		data.NewField("value", nil, values),
+	)
+	for i, key := range labelKeys {
+		frame.Fields = append(frame.Fields, data.NewField(key, nil, labels[i]))
+	}
+	for i, key := range annotationKeys {
+		frame.Fields = append(frame.Fields, data.NewField("annotation_"+key, nil, annotations[i]))
+	}
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame
+}
+
+// appendNewKeys appends the keys of m that are not in seen to keys
+func appendNewKeys(keys []string, seen map[string]struct{}, m map[string]string) []string {
+	for key := range m {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func warningNotices(warnings []string) []data.Notice {
+	notices := make([]data.Notice, 0, len(warnings))
+	for _, w := range warnings {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     w,
+		})
+	}
+	return notices
+}
@@ -0,0 +1,116 @@
+package querydata
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// The headers Grafana sets on the queries of panels, see the tracing header middleware of Grafana
+const (
+	dashboardUIDHeader = "X-Dashboard-Uid"
+	panelIDHeader      = "X-Panel-Id"
+)
+
+// QueryAuditEntry is a query executed by a data source auditing its queries
+type QueryAuditEntry struct {
+	// The UID of the data source
+	Datasource string
+	// The login of the user running the query, empty when it is not run for a user
+	User string
+	// The dashboard and the panel of the query, empty when it is not run by a panel
+	Dashboard string
+	Panel     string
+	FromAlert bool
+
+	RefID   string
+	Expr    string
+	Start   time.Time
+	End     time.Time
+	Step    time.Duration
+	Instant bool
+	Range   bool
+
+	// How long the query took, including the conversion of its result
+	Duration time.Duration
+	// Whether its result was read from the results cache instead of the data source
+	Cached bool
+	Status backend.Status
+	Error  error
+}
+
+// AuditSink receives the queries executed by the data sources setting queryAuditLog
+type AuditSink interface {
+	AuditQuery(ctx context.Context, entry QueryAuditEntry)
+}
+
+type logAuditSink struct {
+	logger log.Logger
+}
+
+// NewLogAuditSink returns an AuditSink writing the entries to logger, as structured fields
+func NewLogAuditSink(logger log.Logger) AuditSink {
+	return &logAuditSink{logger: logger}
+}
+
+func (s *logAuditSink) AuditQuery(ctx context.Context, e QueryAuditEntry) {
+	args := []any{
+		"datasource", e.Datasource,
+		"user", e.User,
+		"dashboard", e.Dashboard,
+		"panel", e.Panel,
+		"fromAlert", e.FromAlert,
+		"refId", e.RefID,
+		"expr", e.Expr,
+		"start", e.Start,
+		"end", e.End,
+		"step", e.Step,
+		"instant", e.Instant,
+		"range", e.Range,
+		"duration", e.Duration,
+		"cached", e.Cached,
+		"status", int(e.Status),
+	}
+	if e.Error != nil {
+		args = append(args, "error", e.Error)
+	}
+	s.logger.FromContext(ctx).Info("Prometheus query audit", args...)
+}
+
+// requestAuditEntry returns the entry of the queries of req with the fields of the request, which
+// tell where the queries come from
+func (s *QueryData) requestAuditEntry(req *backend.QueryDataRequest, fromAlert bool) QueryAuditEntry {
+	entry := QueryAuditEntry{
+		Datasource: s.UID,
+		Dashboard:  req.GetHTTPHeader(dashboardUIDHeader),
+		Panel:      req.GetHTTPHeader(panelIDHeader),
+		FromAlert:  fromAlert,
+	}
+	if user := req.PluginContext.User; user != nil {
+		entry.User = user.Login
+	}
+	return entry
+}
+
+// auditQuery sends the entry of query, which took since start and whose response is r, to the audit sink
+func (s *QueryData) auditQuery(ctx context.Context, request QueryAuditEntry, query *models.Query, start time.Time, r *backend.DataResponse, cached bool) {
+	entry := request
+	entry.RefID = query.RefId
+	entry.Expr = query.Expr
+	entry.Start = query.Start
+	entry.End = query.End
+	entry.Step = query.Step
+	entry.Instant = query.InstantQuery
+	entry.Range = query.RangeQuery
+	entry.Duration = time.Since(start)
+	entry.Cached = cached
+	if r != nil {
+		entry.Status = r.Status
+		entry.Error = r.Error
+	}
+	s.auditSink.AuditQuery(ctx, entry)
+}
@@ -0,0 +1,76 @@
+package querydata
+
+import (
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// rangeQueryParts splits the range query q into chunks queries of consecutive ranges, aligned to the
+// step like q. Successive parts do not share their bound, so no point is returned twice.
+func rangeQueryParts(q *models.Query, chunks int) []*models.Query {
+	tr := q.TimeRange()
+	if chunks <= 1 || tr.Step <= 0 {
+		return []*models.Query{q}
+	}
+
+	points := int(tr.End.Sub(tr.Start)/tr.Step) + 1
+	partRange := time.Duration((points+chunks-1)/chunks) * tr.Step
+	var parts []*models.Query
+	for start := tr.Start; !start.After(tr.End); start = start.Add(partRange) {
+		end := start.Add(partRange - tr.Step)
+		if end.After(tr.End) {
+			end = tr.End
+		}
+		part := *q
+		part.Start, part.End = start, end
+		parts = append(parts, &part)
+	}
+	return parts
+}
+
+// appendFrameRows appends the rows of the frames of the following part of a query to the frames of
+// the same series in frames. The frames of the series that are not in frames yet are added to it.
+func appendFrameRows(frames, next data.Frames) data.Frames {
+	byKey := make(map[string]*data.Frame, len(frames))
+	for _, frame := range frames {
+		byKey[frameKey(frame)] = frame
+	}
+	for _, frame := range next {
+		// The empty frame returned to hold the metadata of a part without any series
+		if len(frame.Fields) == 0 {
+			continue
+		}
+		key := frameKey(frame)
+		existing, ok := byKey[key]
+		if !ok {
+			byKey[key] = frame
+			frames = append(frames, frame)
+			continue
+		}
+		for i, field := range frame.Fields {
+			for row := 0; row < field.Len(); row++ {
+				existing.Fields[i].Append(field.At(row))
+			}
+		}
+	}
+	return frames
+}
+
+// frameKey identifies the series of frame by its name and the name, type and labels of its fields
+func frameKey(frame *data.Frame) string {
+	var b strings.Builder
+	b.WriteString(frame.Name)
+	for _, field := range frame.Fields {
+		b.WriteByte(0)
+		b.WriteString(field.Name)
+		b.WriteByte(0)
+		b.WriteString(field.Type().ItemTypeString())
+		b.WriteByte(0)
+		b.WriteString(field.Labels.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package querydata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestRangeQueryParts(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	q := &models.Query{Expr: "up", Step: time.Minute, Start: start, End: start.Add(100 * time.Minute), RangeQuery: true}
+
+	parts := rangeQueryParts(q, 3)
+	// 101 points in parts of 34
+	require.Len(t, parts, 3)
+	for i, part := range parts {
+		require.Equal(t, start.Add(time.Duration(i)*34*time.Minute), part.Start)
+	}
+	require.Equal(t, start.Add(33*time.Minute), parts[0].End)
+	require.Equal(t, start.Add(100*time.Minute), parts[2].End)
+
+	require.Equal(t, []*models.Query{q}, rangeQueryParts(q, 1))
+}
+
+func TestAppendFrameRows(t *testing.T) {
+	series := func(job string, times []time.Time, values []float64) *data.Frame {
+		return data.NewFrame("",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+			data.NewField(data.TimeSeriesValueFieldName, data.Labels{"job": job}, values))
+	}
+
+	frames := data.Frames{series("api", []time.Time{time.Unix(0, 0)}, []float64{1})}
+	frames = appendFrameRows(frames, data.Frames{
+		data.NewFrame(""),
+		series("api", []time.Time{time.Unix(60, 0)}, []float64{2}),
+		series("db", []time.Time{time.Unix(60, 0)}, []float64{3}),
+	})
+
+	require.Len(t, frames, 2)
+	require.Equal(t, 2, frames[0].Rows())
+	require.Equal(t, 2.0, frames[0].Fields[1].At(1))
+	require.Equal(t, data.Labels{"job": "db"}, frames[1].Fields[1].Labels)
+}
+
+func TestChunkedRangeQuery(t *testing.T) {
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		ranges = append(ranges, r.Form.Get("start")+"-"+r.Form.Get("end"))
+		start, err := strconv.ParseFloat(r.Form.Get("start"), 64)
+		require.NoError(t, err)
+		end, err := strconv.ParseFloat(r.Form.Get("end"), 64)
+		require.NoError(t, err)
+		// One point at each bound of the range
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"__name__":"up"},"values":[[%v,"1"],[%v,"1"]]}]}}`, start, end)
+	}))
+	defer srv.Close()
+
+	queryData, err := New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      []byte(`{"expr":"up","range":true,"step":"10s"}`),
+			Interval:  time.Minute,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(0, 0).Add(48 * time.Hour)},
+		}},
+	})
+	require.NoError(t, err)
+	r := res.Responses["A"]
+	require.NoError(t, r.Error)
+
+	// 17281 points are queried in two parts
+	require.Equal(t, []string{"0-86400", "86410-172800"}, ranges)
+	require.Len(t, r.Frames, 1)
+	require.Equal(t, 4, r.Frames[0].Rows())
+
+	custom, ok := r.Frames[0].Meta.Custom.(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, 2, custom["stepAdjustment"].(*models.StepAdjustment).Chunks)
+}
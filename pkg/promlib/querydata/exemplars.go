@@ -0,0 +1,58 @@
+package querydata
+
+import (
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// maxExemplarQueryRange bounds the range of a single exemplar query, the exemplars of longer ranges
+// are queried in parts. Exemplar queries over long ranges are slow or refused by Prometheus.
+const maxExemplarQueryRange = 24 * time.Hour
+
+// exemplarQueryParts splits the exemplar query q into queries of consecutive ranges of at most
+// maxRange, rounded to the step so each part is aligned like q. Successive parts share their bound.
+func exemplarQueryParts(q *models.Query, maxRange time.Duration) []*models.Query {
+	tr := q.QueriedTimeRange()
+	if tr.End.Sub(tr.Start) <= maxRange || tr.Step <= 0 {
+		return []*models.Query{q}
+	}
+
+	partRange := max(maxRange.Truncate(tr.Step), tr.Step)
+	var parts []*models.Query
+	for start := tr.Start; start.Before(tr.End); start = start.Add(partRange) {
+		end := start.Add(partRange)
+		if end.After(tr.End) {
+			end = tr.End
+		}
+		part := *q
+		part.Start, part.QueriedStart = start, start
+		part.End, part.QueriedEnd = end, end
+		parts = append(parts, &part)
+	}
+	return parts
+}
+
+// exemplarsAfter removes the exemplars at or before t from the exemplar frames of frames
+func exemplarsAfter(frames data.Frames, t time.Time) (data.Frames, error) {
+	for i, frame := range frames {
+		if !isExemplarFrame(frame) {
+			continue
+		}
+		filtered, err := frame.FilterRowsByField(0, func(v any) (bool, error) {
+			return v.(time.Time).After(t), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Filtering drops the metadata and the field configs, which tell the frame apart and hold the step
+		filtered.Meta = frame.Meta
+		for j, field := range frame.Fields {
+			filtered.Fields[j].Config = field.Config
+		}
+		frames[i] = filtered
+	}
+	return frames, nil
+}
@@ -0,0 +1,79 @@
+package querydata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+	"github.com/grafana/grafana/pkg/promlib/querydata/exemplar"
+)
+
+func TestExemplarQueryParts(t *testing.T) {
+	start := time.Unix(0, 0).UTC()
+	q := &models.Query{Expr: "up", Step: 7 * time.Hour, Start: start, End: start.Add(70 * time.Hour), ExemplarQuery: true}
+
+	parts := exemplarQueryParts(q, 24*time.Hour)
+	// 24h is rounded down to 21h, a multiple of the step
+	require.Len(t, parts, 4)
+	for i, part := range parts {
+		require.Equal(t, start.Add(time.Duration(i)*21*time.Hour), part.QueriedStart)
+	}
+	require.Equal(t, start.Add(70*time.Hour), parts[3].QueriedEnd)
+
+	require.Equal(t, []*models.Query{q}, exemplarQueryParts(q, 100*time.Hour))
+}
+
+func TestExemplarQuery(t *testing.T) {
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/api/v1/query_exemplars" {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		ranges = append(ranges, r.Form.Get("start")+"-"+r.Form.Get("end"))
+		start, err := strconv.ParseFloat(r.Form.Get("start"), 64)
+		require.NoError(t, err)
+		end, err := strconv.ParseFloat(r.Form.Get("end"), 64)
+		require.NoError(t, err)
+		// One exemplar at each bound of the range
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":[{"seriesLabels":{"__name__":"up"},"exemplars":[
+			{"labels":{"traceID":"a"},"value":"1","timestamp":%v},{"labels":{"traceID":"b"},"value":"1","timestamp":%v}]}]}`, start, end)
+	}))
+	defer srv.Close()
+
+	queryData, err := New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+	queryData.exemplarSampler = exemplar.NewNoOpSampler
+
+	res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      []byte(`{"expr":"up","exemplar":true,"interval":"1h"}`),
+			Interval:  time.Hour,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(0, 0).Add(7 * 24 * time.Hour)},
+		}},
+	})
+	require.NoError(t, err)
+	r := res.Responses["A"]
+	require.NoError(t, r.Error)
+
+	require.Equal(t, 7, len(ranges))
+	require.Len(t, r.Frames, 1)
+	// The exemplars at the bounds shared by two parts are only returned once
+	require.Equal(t, 8, r.Frames[0].Rows())
+}
@@ -0,0 +1,66 @@
+package querydata
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// parseAllowedQueryHeaders returns the canonical names of the headers queries can set, from jsonData.allowedQueryHeaders
+func parseAllowedQueryHeaders(jsonData map[string]any) (map[string]struct{}, error) {
+	return parseAllowList(jsonData, "allowedQueryHeaders", "header name", http.CanonicalHeaderKey)
+}
+
+// parseAllowedQueryTenants returns the tenants queries can read instead of the tenant of the data source,
+// from jsonData.allowedQueryTenants
+func parseAllowedQueryTenants(jsonData map[string]any) (map[string]struct{}, error) {
+	return parseAllowList(jsonData, "allowedQueryTenants", "tenant", func(s string) string { return s })
+}
+
+// parseAllowList returns the normalized values of the list of strings jsonData[key], nil when it is not set
+func parseAllowList(jsonData map[string]any, key, kind string, normalize func(string) string) (map[string]struct{}, error) {
+	v, ok := jsonData[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	values, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of %ss, got %T", key, kind, v)
+	}
+
+	allowed := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("invalid %s %v in %s", kind, value, key)
+		}
+		allowed[normalize(s)] = struct{}{}
+	}
+	return allowed, nil
+}
+
+// checkQueryHeaders returns an error when one of the headers of a query is not allowed by the data source.
+// When the data source allows a list of tenants, the tenants of the query must be in it instead of the
+// tenant header being allowed.
+func checkQueryHeaders(query *models.Query, allowed, allowedTenants map[string]struct{}) error {
+	tenantsAllowed := false
+	if len(query.Tenants) > 0 && allowedTenants != nil {
+		for _, tenant := range query.Tenants {
+			if _, ok := allowedTenants[tenant]; !ok {
+				return fmt.Errorf("tenant %q is not allowed by the data source", tenant)
+			}
+		}
+		tenantsAllowed = true
+	}
+
+	for name := range query.Headers {
+		if tenantsAllowed && http.CanonicalHeaderKey(name) == http.CanonicalHeaderKey(models.TenantHeader) {
+			continue
+		}
+		if _, ok := allowed[http.CanonicalHeaderKey(name)]; !ok {
+			return fmt.Errorf("header %q is not allowed by the data source", name)
+		}
+	}
+	return nil
+}
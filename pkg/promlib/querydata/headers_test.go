@@ -0,0 +1,65 @@
+package querydata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestParseAllowedQueryHeaders(t *testing.T) {
+	allowed, err := parseAllowedQueryHeaders(map[string]any{})
+	require.NoError(t, err)
+	require.Empty(t, allowed)
+
+	allowed, err = parseAllowedQueryHeaders(map[string]any{"allowedQueryHeaders": []any{"x-scope-orgid", "X-Trace-Sampled"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"X-Scope-Orgid": {}, "X-Trace-Sampled": {}}, allowed)
+
+	_, err = parseAllowedQueryHeaders(map[string]any{"allowedQueryHeaders": "X-Scope-OrgID"})
+	require.Error(t, err)
+
+	_, err = parseAllowedQueryHeaders(map[string]any{"allowedQueryHeaders": []any{""}})
+	require.Error(t, err)
+}
+
+func TestParseAllowedQueryTenants(t *testing.T) {
+	allowed, err := parseAllowedQueryTenants(map[string]any{})
+	require.NoError(t, err)
+	require.Nil(t, allowed)
+
+	allowed, err = parseAllowedQueryTenants(map[string]any{"allowedQueryTenants": []any{"team-a", "Team-B"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"team-a": {}, "Team-B": {}}, allowed)
+
+	_, err = parseAllowedQueryTenants(map[string]any{"allowedQueryTenants": []any{1}})
+	require.ErrorContains(t, err, "invalid tenant 1 in allowedQueryTenants")
+}
+
+func TestCheckQueryHeaders(t *testing.T) {
+	allowed := map[string]struct{}{"X-Scope-Orgid": {}}
+	headers := func(headers map[string]string) *models.Query {
+		return &models.Query{Headers: headers}
+	}
+
+	require.NoError(t, checkQueryHeaders(headers(nil), nil, nil))
+	require.NoError(t, checkQueryHeaders(headers(map[string]string{"X-Scope-OrgID": "tenant-a"}), allowed, nil))
+	require.ErrorContains(t, checkQueryHeaders(headers(map[string]string{"Authorization": "Bearer token"}), allowed, nil), `header "Authorization" is not allowed`)
+	require.Error(t, checkQueryHeaders(headers(map[string]string{"X-Scope-OrgID": "tenant-a"}), nil, nil))
+
+	t.Run("tenants allowed by the data source", func(t *testing.T) {
+		allowedTenants := map[string]struct{}{"team-a": {}, "team-b": {}}
+		tenants := func(tenants ...string) *models.Query {
+			return &models.Query{Headers: map[string]string{models.TenantHeader: strings.Join(tenants, "|")}, Tenants: tenants}
+		}
+
+		// The tenant header does not need to be allowed
+		require.NoError(t, checkQueryHeaders(tenants("team-a", "team-b"), nil, allowedTenants))
+		require.ErrorContains(t, checkQueryHeaders(tenants("team-a", "team-c"), allowed, allowedTenants), `tenant "team-c" is not allowed`)
+		// Without a list of tenants, any tenant can be read when the tenant header is allowed
+		require.NoError(t, checkQueryHeaders(tenants("team-c"), allowed, nil))
+		require.Error(t, checkQueryHeaders(tenants("team-a"), nil, nil))
+	})
+}
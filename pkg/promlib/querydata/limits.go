@@ -0,0 +1,64 @@
+package querydata
+
+import (
+	"errors"
+	"io"
+
+	"github.com/grafana/grafana/pkg/promlib/converter"
+	"github.com/grafana/grafana/pkg/promlib/utils"
+)
+
+var errResponseTooLarge = errors.New("response too large")
+
+// responseLimits bound the responses decoded for a data source, zero for no limit
+type responseLimits struct {
+	maxBytes   int64
+	maxSeries  int64
+	maxSamples int64
+}
+
+func parseResponseLimits(jsonData map[string]any) (responseLimits, error) {
+	var limits responseLimits
+	var err error
+	if limits.maxBytes, err = utils.GetInt64Optional(jsonData, "maxResponseBytes"); err != nil {
+		return limits, err
+	}
+	if limits.maxSeries, err = utils.GetInt64Optional(jsonData, "maxResponseSeries"); err != nil {
+		return limits, err
+	}
+	if limits.maxSamples, err = utils.GetInt64Optional(jsonData, "maxResponseSamples"); err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+// limitedReader fails once more than max bytes are read, when max is set
+type limitedReader struct {
+	r        io.Reader
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.max > 0 && l.read > l.max {
+		l.exceeded = true
+		return n, errResponseTooLarge
+	}
+	return n, err
+}
+
+// limitError returns the error to report when decoding stopped because of a limit, or nil
+func (l *limitedReader) limitError(decodeErr error) error {
+	if l.exceeded {
+		return &converter.ResponseLimitError{Limit: "bytes", Max: l.max, Bytes: l.read}
+	}
+	var limitErr *converter.ResponseLimitError
+	if errors.As(decodeErr, &limitErr) {
+		limitErr.Bytes = l.read
+		return limitErr
+	}
+	return nil
+}
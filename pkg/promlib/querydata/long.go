@@ -0,0 +1,119 @@
+package querydata
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/promlib/converter"
+)
+
+// toLongFrame merges the series frames of frames into a single long frame, with a time and a value
+// column and a column for each label. Other frames, like exemplars, are returned as they are after it.
+func toLongFrame(frames data.Frames) data.Frames {
+	var series, others data.Frames
+	for _, frame := range frames {
+		if isSeriesFrame(frame) {
+			series = append(series, frame)
+		} else {
+			others = append(others, frame)
+		}
+	}
+	if len(series) == 0 {
+		return frames
+	}
+
+	labelNames := seriesLabelNames(series)
+	rows := 0
+	for _, frame := range series {
+		rows += frame.Rows()
+	}
+
+	type sample struct {
+		t      time.Time
+		v      float64
+		labels data.Labels
+	}
+	samples := make([]sample, 0, rows)
+	for _, frame := range series {
+		for i := 0; i < frame.Rows(); i++ {
+			samples = append(samples, sample{
+				t:      frame.Fields[0].At(i).(time.Time),
+				v:      frame.Fields[1].At(i).(float64),
+				labels: frame.Fields[1].Labels,
+			})
+		}
+	}
+	// Long frames are sorted by time, samples of a same time keep the order of their series
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].t.Before(samples[j].t) })
+
+	times := make([]time.Time, len(samples))
+	values := make([]float64, len(samples))
+	labelValues := make([][]string, len(labelNames))
+	for i := range labelValues {
+		labelValues[i] = make([]string, len(samples))
+	}
+	for i, s := range samples {
+		times[i] = s.t
+		values[i] = s.v
+		for j, name := range labelNames {
+			labelValues[j][i] = s.labels[name]
+		}
+	}
+
+	long := data.NewFrame("",
+		data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+		data.NewField(data.TimeSeriesValueFieldName, nil, values),
+	)
+	for i, name := range labelNames {
+		long.Fields = append(long.Fields, data.NewField(name, nil, labelValues[i]))
+	}
+	long.RefID = series[0].RefID
+	long.Meta = longFrameMeta(series)
+
+	return append(data.Frames{long}, others...)
+}
+
+// isSeriesFrame returns whether frame is a float series of a matrix or vector result,
+// possibly with the raw values of the series, which the conversions of series drop
+func isSeriesFrame(frame *data.Frame) bool {
+	fields := len(frame.Fields)
+	return (fields == 2 || fields == 3 && frame.Fields[2].Name == converter.RawValueFieldName) &&
+		frame.Fields[0].Type() == data.FieldTypeTime &&
+		frame.Fields[1].Type() == data.FieldTypeFloat64
+}
+
+// seriesLabelNames returns the sorted names of the labels of all the series
+func seriesLabelNames(series data.Frames) []string {
+	seen := map[string]struct{}{}
+	for _, frame := range series {
+		for name := range frame.Fields[1].Labels {
+			seen[name] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// longFrameMeta returns the meta of the first series, as a long frame, with the notices of all the series
+func longFrameMeta(series data.Frames) *data.FrameMeta {
+	meta := &data.FrameMeta{}
+	if series[0].Meta != nil {
+		m := *series[0].Meta
+		meta = &m
+	}
+	meta.Type = data.FrameTypeTimeSeriesLong
+	meta.TypeVersion = data.FrameTypeVersion{0, 1}
+	meta.Notices = nil
+	for _, frame := range series {
+		if frame.Meta != nil {
+			meta.Notices = append(meta.Notices, frame.Meta.Notices...)
+		}
+	}
+	return meta
+}
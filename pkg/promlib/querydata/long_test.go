@@ -0,0 +1,58 @@
+package querydata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/converter"
+)
+
+func TestToLongFrame(t *testing.T) {
+	series := func(labels data.Labels, times []time.Time, values []float64) *data.Frame {
+		frame := data.NewFrame("",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+			data.NewField(data.TimeSeriesValueFieldName, labels, values),
+		)
+		frame.RefID = "A"
+		frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, ExecutedQueryString: "Expr: up"}
+		return frame
+	}
+	t1, t2 := time.Unix(60, 0).UTC(), time.Unix(120, 0).UTC()
+	exemplars := data.NewFrame("exemplar", data.NewField("Time", nil, []time.Time{t1}), data.NewField("Value", nil, []float64{1}), data.NewField("traceID", nil, []string{"abc"}))
+
+	frames := toLongFrame(data.Frames{
+		series(data.Labels{"__name__": "up", "job": "api"}, []time.Time{t1, t2}, []float64{1, 0}),
+		series(data.Labels{"__name__": "up", "instance": "db:9090"}, []time.Time{t1}, []float64{1}),
+		exemplars,
+	})
+	require.Len(t, frames, 2)
+	require.Equal(t, exemplars, frames[1])
+
+	long := frames[0]
+	require.Equal(t, "A", long.RefID)
+	require.Equal(t, data.FrameTypeTimeSeriesLong, long.Meta.Type)
+	require.Equal(t, "Expr: up", long.Meta.ExecutedQueryString)
+	require.Equal(t, []string{"Time", "Value", "__name__", "instance", "job"}, []string{
+		long.Fields[0].Name, long.Fields[1].Name, long.Fields[2].Name, long.Fields[3].Name, long.Fields[4].Name,
+	})
+	require.Equal(t, 3, long.Rows())
+	require.Equal(t, []any{t1, 1.0, "up", "", "api"}, long.RowCopy(0))
+	require.Equal(t, []any{t1, 1.0, "up", "db:9090", ""}, long.RowCopy(1))
+	require.Equal(t, []any{t2, 0.0, "up", "", "api"}, long.RowCopy(2))
+
+	t.Run("raw values are dropped", func(t *testing.T) {
+		withRaw := series(data.Labels{"__name__": "up"}, []time.Time{t1}, []float64{1})
+		withRaw.Fields = append(withRaw.Fields, data.NewField(converter.RawValueFieldName, nil, []string{"1"}))
+		frames := toLongFrame(data.Frames{withRaw})
+		require.Len(t, frames, 1)
+		require.Equal(t, []any{t1, 1.0, "up"}, frames[0].RowCopy(0))
+	})
+
+	t.Run("frames without series are left as they are", func(t *testing.T) {
+		empty := data.Frames{data.NewFrame("")}
+		require.Equal(t, empty, toLongFrame(empty))
+	})
+}
@@ -0,0 +1,161 @@
+package querydata
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+)
+
+// maxMetadataLookups bounds the number of metadata requests made to enrich the result of a single query
+const maxMetadataLookups = 10
+
+// prometheusUnits maps the base units of the Prometheus naming conventions to Grafana units.
+// Other units are used as they are.
+var prometheusUnits = map[string]string{
+	"seconds": "s",
+	"bytes":   "bytes",
+	"ratio":   "percentunit",
+	"celsius": "celsius",
+	"meters":  "lengthm",
+	"volts":   "volt",
+	"amperes": "amp",
+	"joules":  "joule",
+	"hertz":   "hertz",
+}
+
+// metricNameSuffixes are the suffixes of the series of counters, histograms and summaries,
+// which are found before the unit in the name of a metric
+var metricNameSuffixes = []string{"_total", "_sum", "_bucket"}
+
+// enrichFromMetadata sets the unit and description of the fields of frames from the metadata of their metric.
+// The metric of the fields without a name, like the ones of aggregations, is the one selected by expr when
+// it selects a single metric. Failing to read the metadata is not an error, the unit is then guessed from
+// the name of the metric.
+func (s *QueryData) enrichFromMetadata(ctx context.Context, c *client.Client, expr string, frames data.Frames) {
+	logger := s.log.FromContext(ctx)
+
+	exprMetric := ""
+	if names := selectorNames(expr); len(names) == 1 {
+		exprMetric = names[0]
+	}
+
+	metadata := map[string]metricMetadata{}
+	for _, name := range metricNames(frames, exprMetric, maxMetadataLookups) {
+		md, _, err := s.lookupMetadata(ctx, c, name)
+		if err != nil {
+			logger.Debug("Failed to read metric metadata", "metric", name, "err", err)
+		}
+		metadata[name] = md
+	}
+
+	applyMetadata(frames, exprMetric, metadata)
+}
+
+func (s *QueryData) lookupMetadata(ctx context.Context, c *client.Client, name string) (metricMetadata, bool, error) {
+	res, err := c.QueryAPI(ctx, "api/v1/metadata", map[string]string{"metric": name})
+	if err != nil {
+		return metricMetadata{}, false, err
+	}
+
+	rsp, err := client.DecodeAPIResponse[map[string][]metricMetadata](res, s.log.FromContext(ctx))
+	if err != nil {
+		return metricMetadata{}, false, err
+	}
+
+	if entries := rsp.Data[name]; len(entries) > 0 {
+		return entries[0], true, nil
+	}
+	return metricMetadata{}, false, nil
+}
+
+// fieldMetric returns the name of the metric of field, exprMetric when it has none
+func fieldMetric(field *data.Field, exprMetric string) string {
+	if name, ok := field.Labels["__name__"]; ok {
+		return name
+	}
+	if field.Type().Numeric() {
+		return exprMetric
+	}
+	return ""
+}
+
+// metricNames returns up to limit distinct metric names of the fields of frames, in the order they are found
+func metricNames(frames data.Frames, exprMetric string, limit int) []string {
+	var names []string
+	seen := map[string]struct{}{}
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			name := fieldMetric(field, exprMetric)
+			if name == "" {
+				continue
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			if len(names) == limit {
+				return names
+			}
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// applyMetadata sets the unit and description of the fields of frames that are not set yet
+func applyMetadata(frames data.Frames, exprMetric string, metadata map[string]metricMetadata) {
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			name := fieldMetric(field, exprMetric)
+			md, ok := metadata[name]
+			if !ok {
+				continue
+			}
+			unit := grafanaUnit(name, md)
+			if unit == "" && md.Help == "" {
+				continue
+			}
+			if field.Config == nil {
+				field.Config = &data.FieldConfig{}
+			}
+			if field.Config.Unit == "" {
+				field.Config.Unit = unit
+			}
+			if field.Config.Description == "" {
+				field.Config.Description = md.Help
+			}
+		}
+	}
+}
+
+// grafanaUnit returns the Grafana unit of a metric. Without a unit in its metadata, the unit is the one
+// its name ends with, following the naming conventions of Prometheus. Counters without a unit are counts.
+func grafanaUnit(name string, md metricMetadata) string {
+	unit := md.Unit
+	if unit == "" {
+		unit = nameUnit(name)
+	}
+	if unit == "" && md.Type == "counter" {
+		return "short"
+	}
+	if u, ok := prometheusUnits[unit]; ok {
+		return u
+	}
+	return unit
+}
+
+// nameUnit returns the base unit name ends with, or empty when it does not end with a known one
+func nameUnit(name string) string {
+	for _, suffix := range metricNameSuffixes {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	for unit := range prometheusUnits {
+		if strings.HasSuffix(name, "_"+unit) {
+			return unit
+		}
+	}
+	return ""
+}
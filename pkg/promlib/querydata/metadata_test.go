@@ -0,0 +1,106 @@
+package querydata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricNames(t *testing.T) {
+	frames := data.Frames{
+		data.NewFrame("",
+			data.NewField("Time", nil, []time.Time{}),
+			data.NewField("Value", data.Labels{"__name__": "up", "job": "a"}, []float64{}),
+		),
+		data.NewFrame("",
+			data.NewField("Time", nil, []time.Time{}),
+			data.NewField("Value", data.Labels{"__name__": "up", "job": "b"}, []float64{}),
+		),
+		data.NewFrame("",
+			data.NewField("Time", nil, []time.Time{}),
+			data.NewField("Value", data.Labels{"__name__": "process_cpu_seconds_total"}, []float64{}),
+		),
+		data.NewFrame("",
+			data.NewField("Time", nil, []time.Time{}),
+			data.NewField("Value", data.Labels{"job": "a"}, []float64{}),
+		),
+	}
+
+	require.Equal(t, []string{"up", "process_cpu_seconds_total"}, metricNames(frames, "", 10))
+	require.Equal(t, []string{"up"}, metricNames(frames, "", 1))
+	// The series without a name are of the metric selected by the query
+	require.Equal(t, []string{"up", "process_cpu_seconds_total", "node_load1"}, metricNames(frames, "node_load1", 10))
+}
+
+func TestApplyMetadata(t *testing.T) {
+	duration := data.NewField("Value", data.Labels{"__name__": "request_duration_seconds"}, []float64{})
+	size := data.NewField("Value", data.Labels{"__name__": "response_size"}, []float64{}).SetConfig(&data.FieldConfig{
+		DisplayNameFromDS: "size",
+		Unit:              "decbytes",
+	})
+	unknown := data.NewField("Value", data.Labels{"__name__": "unknown"}, []float64{})
+	frames := data.Frames{
+		data.NewFrame("", data.NewField("Time", nil, []time.Time{}), duration),
+		data.NewFrame("", data.NewField("Time", nil, []time.Time{}), size),
+		data.NewFrame("", data.NewField("Time", nil, []time.Time{}), unknown),
+	}
+
+	applyMetadata(frames, "", map[string]metricMetadata{
+		"request_duration_seconds": {Type: "histogram", Help: "Duration of requests", Unit: "seconds"},
+		"response_size":            {Type: "gauge", Help: "Size of responses", Unit: "bytes"},
+	})
+
+	require.Equal(t, "s", duration.Config.Unit)
+	require.Equal(t, "Duration of requests", duration.Config.Description)
+
+	// Units set by the query are kept
+	require.Equal(t, "decbytes", size.Config.Unit)
+	require.Equal(t, "size", size.Config.DisplayNameFromDS)
+	require.Equal(t, "Size of responses", size.Config.Description)
+
+	require.Nil(t, unknown.Config)
+}
+
+func TestApplyMetadataHeuristics(t *testing.T) {
+	newFrame := func(labels data.Labels) *data.Frame {
+		return data.NewFrame("", data.NewField("Time", nil, []time.Time{}), data.NewField("Value", labels, []float64{}))
+	}
+	unit := func(frame *data.Frame) string {
+		if frame.Fields[1].Config == nil {
+			return ""
+		}
+		return frame.Fields[1].Config.Unit
+	}
+
+	t.Run("unit is guessed from the name without one in the metadata", func(t *testing.T) {
+		cpu := newFrame(data.Labels{"__name__": "process_cpu_seconds_total"})
+		size := newFrame(data.Labels{"__name__": "response_size_bytes_bucket"})
+		requests := newFrame(data.Labels{"__name__": "http_requests_total"})
+		goroutines := newFrame(data.Labels{"__name__": "go_goroutines"})
+		applyMetadata(data.Frames{cpu, size, requests, goroutines}, "", map[string]metricMetadata{
+			"process_cpu_seconds_total":  {Type: "counter"},
+			"response_size_bytes_bucket": {},
+			"http_requests_total":        {Type: "counter", Help: "Requests"},
+			"go_goroutines":              {Type: "gauge"},
+		})
+
+		require.Equal(t, "s", unit(cpu))
+		require.Equal(t, "bytes", unit(size))
+		require.Equal(t, "short", unit(requests))
+		require.Equal(t, "Requests", requests.Fields[1].Config.Description)
+		require.Nil(t, goroutines.Fields[1].Config)
+		require.Nil(t, cpu.Fields[0].Config)
+	})
+
+	t.Run("series without a name are of the metric selected by the query", func(t *testing.T) {
+		sum := newFrame(data.Labels{"job": "api"})
+		applyMetadata(data.Frames{sum}, "http_request_duration_seconds", map[string]metricMetadata{
+			"http_request_duration_seconds": {Type: "histogram", Help: "Duration of requests"},
+		})
+
+		require.Equal(t, "s", unit(sum))
+		require.Equal(t, "Duration of requests", sum.Fields[1].Config.Description)
+	})
+}
@@ -0,0 +1,105 @@
+package querydata
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/promlib/converter"
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// handleNonFiniteValues drops or interpolates the NaN and infinite samples of the series frames of frames,
+// as handling says. Interpolated samples are on the line between the finite samples around them, the ones
+// without finite samples on both sides are dropped. Other frames are kept as they are.
+func handleNonFiniteValues(frames data.Frames, handling models.NonFiniteValues) data.Frames {
+	if handling != models.NonFiniteValuesDrop && handling != models.NonFiniteValuesInterpolate {
+		return frames
+	}
+	for i, frame := range frames {
+		if !isSeriesFrame(frame) || !hasNonFiniteValues(frame.Fields[1]) {
+			continue
+		}
+		if handling == models.NonFiniteValuesInterpolate {
+			interpolateNonFiniteValues(frame)
+		}
+		frames[i] = finiteRows(frame)
+	}
+	return frames
+}
+
+// finiteRows returns a copy of the series frame with the rows of its finite values, which keeps the
+// metadata of the frame and the configuration of its fields
+func finiteRows(frame *data.Frame) *data.Frame {
+	filtered := *frame
+	filtered.Fields = make(data.Fields, len(frame.Fields))
+	for i, field := range frame.Fields {
+		filtered.Fields[i] = data.NewFieldFromFieldType(field.Type(), 0)
+		filtered.Fields[i].Name = field.Name
+		filtered.Fields[i].Labels = field.Labels
+		filtered.Fields[i].Config = field.Config
+	}
+	values := frame.Fields[1]
+	for row := 0; row < values.Len(); row++ {
+		if !isFinite(values.At(row).(float64)) {
+			continue
+		}
+		for i, field := range frame.Fields {
+			filtered.Fields[i].Append(field.At(row))
+		}
+	}
+	return &filtered
+}
+
+// interpolateNonFiniteValues replaces the non-finite values of the series of frame between two finite ones
+func interpolateNonFiniteValues(frame *data.Frame) {
+	times, values := frame.Fields[0], frame.Fields[1]
+	var raw *data.Field
+	if len(frame.Fields) == 3 && frame.Fields[2].Name == converter.RawValueFieldName {
+		raw = frame.Fields[2]
+	}
+
+	prev := -1
+	for i := 0; i < values.Len(); i++ {
+		if isFinite(values.At(i).(float64)) {
+			prev = i
+			continue
+		}
+		if prev < 0 {
+			continue
+		}
+		next := i + 1
+		for next < values.Len() && !isFinite(values.At(next).(float64)) {
+			next++
+		}
+		if next == values.Len() {
+			return
+		}
+		t0, t1 := times.At(prev).(time.Time), times.At(next).(time.Time)
+		v0, v1 := values.At(prev).(float64), values.At(next).(float64)
+		for j := i; j < next; j++ {
+			v := v0 + (v1-v0)*float64(times.At(j).(time.Time).Sub(t0))/float64(t1.Sub(t0))
+			values.Set(j, v)
+			if raw != nil {
+				raw.Set(j, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		}
+		i = next
+		prev = next
+	}
+}
+
+func hasNonFiniteValues(field *data.Field) bool {
+	for i := 0; i < field.Len(); i++ {
+		if !isFinite(field.At(i).(float64)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
@@ -0,0 +1,80 @@
+package querydata
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/converter"
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestHandleNonFiniteValues(t *testing.T) {
+	series := func(values ...float64) *data.Frame {
+		times := make([]time.Time, len(values))
+		for i := range values {
+			times[i] = time.Unix(int64(i*60), 0)
+		}
+		frame := data.NewFrame("up",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+			data.NewField(data.TimeSeriesValueFieldName, data.Labels{"job": "api"}, values),
+		)
+		frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti})
+		frame.Fields[1].Config = &data.FieldConfig{Unit: "short"}
+		return frame
+	}
+	valuesOf := func(frame *data.Frame) []float64 {
+		values := make([]float64, frame.Rows())
+		for i := range values {
+			values[i] = frame.Fields[1].At(i).(float64)
+		}
+		return values
+	}
+	timesOf := func(frame *data.Frame) []int64 {
+		times := make([]int64, frame.Rows())
+		for i := range times {
+			times[i] = frame.Fields[0].At(i).(time.Time).Unix()
+		}
+		return times
+	}
+	nan, inf := math.NaN(), math.Inf(1)
+
+	t.Run("samples are kept by default", func(t *testing.T) {
+		for _, handling := range []models.NonFiniteValues{"", models.NonFiniteValuesKeep} {
+			frames := handleNonFiniteValues(data.Frames{series(1, nan, 3)}, handling)
+			require.Len(t, valuesOf(frames[0]), 3)
+		}
+	})
+
+	t.Run("non-finite samples are dropped", func(t *testing.T) {
+		frames := handleNonFiniteValues(data.Frames{series(nan, 1, inf, 3, math.Inf(-1))}, models.NonFiniteValuesDrop)
+		require.Equal(t, []float64{1, 3}, valuesOf(frames[0]))
+		require.Equal(t, []int64{60, 180}, timesOf(frames[0]))
+		require.Equal(t, data.Labels{"job": "api"}, frames[0].Fields[1].Labels)
+		require.Equal(t, data.FrameTypeTimeSeriesMulti, frames[0].Meta.Type)
+		require.Equal(t, "short", frames[0].Fields[1].Config.Unit)
+	})
+
+	t.Run("non-finite samples are interpolated between finite ones", func(t *testing.T) {
+		frames := handleNonFiniteValues(data.Frames{series(nan, 1, nan, inf, 4, 2, nan)}, models.NonFiniteValuesInterpolate)
+		require.Equal(t, []float64{1, 2, 3, 4, 2}, valuesOf(frames[0]))
+		require.Equal(t, []int64{60, 120, 180, 240, 300}, timesOf(frames[0]))
+	})
+
+	t.Run("raw values are interpolated too", func(t *testing.T) {
+		frame := series(1, nan, 3)
+		frame.Fields = append(frame.Fields, data.NewField(converter.RawValueFieldName, nil, []string{"1", "NaN", "3"}))
+		frames := handleNonFiniteValues(data.Frames{frame}, models.NonFiniteValuesInterpolate)
+		require.Equal(t, []float64{1, 2, 3}, valuesOf(frames[0]))
+		require.Equal(t, "2", frames[0].Fields[2].At(1))
+	})
+
+	t.Run("other frames are kept", func(t *testing.T) {
+		exemplars := data.NewFrame("exemplar", data.NewField("Value", nil, []float64{nan}))
+		frames := handleNonFiniteValues(data.Frames{exemplars}, models.NonFiniteValuesDrop)
+		require.Same(t, exemplars, frames[0])
+	})
+}
@@ -0,0 +1,53 @@
+package querydata
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// toNumericFrame reduces the series frames of frames to a single numeric wide frame, with a field holding
+// the last value of each series. Empty frames, which only carry metadata, are merged into it too.
+// Other frames, like exemplars, are returned as they are after it.
+func toNumericFrame(frames data.Frames) data.Frames {
+	var series, others data.Frames
+	for _, frame := range frames {
+		if isSeriesFrame(frame) || len(frame.Fields) == 0 {
+			series = append(series, frame)
+		} else {
+			others = append(others, frame)
+		}
+	}
+
+	numeric := data.NewFrame("")
+	for _, frame := range series {
+		if len(frame.Fields) == 0 || frame.Rows() == 0 {
+			continue
+		}
+		valueField := frame.Fields[1]
+		name := data.TimeSeriesValueFieldName
+		if n, ok := valueField.Labels["__name__"]; ok {
+			name = n
+		}
+		field := data.NewField(name, valueField.Labels, []float64{valueField.At(frame.Rows() - 1).(float64)})
+		field.Config = valueField.Config
+		numeric.Fields = append(numeric.Fields, field)
+	}
+
+	if len(frames) > 0 {
+		numeric.RefID = frames[0].RefID
+	}
+	numeric.Meta = &data.FrameMeta{
+		Type:        data.FrameTypeNumericWide,
+		TypeVersion: data.FrameTypeVersion{0, 1},
+	}
+	for _, frame := range series {
+		if frame.Meta == nil {
+			continue
+		}
+		if numeric.Meta.ExecutedQueryString == "" {
+			numeric.Meta.ExecutedQueryString = frame.Meta.ExecutedQueryString
+		}
+		numeric.Meta.Notices = append(numeric.Meta.Notices, frame.Meta.Notices...)
+	}
+
+	return append(data.Frames{numeric}, others...)
+}
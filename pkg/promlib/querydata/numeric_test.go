@@ -0,0 +1,54 @@
+package querydata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToNumericFrame(t *testing.T) {
+	series := func(labels data.Labels, values []float64) *data.Frame {
+		times := make([]time.Time, len(values))
+		for i := range values {
+			times[i] = time.Unix(int64(60*i), 0)
+		}
+		frame := data.NewFrame("",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+			data.NewField(data.TimeSeriesValueFieldName, labels, values),
+		)
+		frame.RefID = "A"
+		frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, ExecutedQueryString: "Expr: up"}
+		return frame
+	}
+
+	frames := toNumericFrame(data.Frames{
+		series(data.Labels{"__name__": "up", "job": "api"}, []float64{1}),
+		series(data.Labels{"job": "db"}, []float64{1, 0}),
+		series(data.Labels{"job": "empty"}, []float64{}),
+	})
+	require.Len(t, frames, 1)
+
+	numeric := frames[0]
+	require.Equal(t, "A", numeric.RefID)
+	require.Equal(t, data.FrameTypeNumericWide, numeric.Meta.Type)
+	require.Equal(t, "Expr: up", numeric.Meta.ExecutedQueryString)
+	require.Len(t, numeric.Fields, 2)
+	require.Equal(t, "up", numeric.Fields[0].Name)
+	require.Equal(t, data.Labels{"__name__": "up", "job": "api"}, numeric.Fields[0].Labels)
+	require.Equal(t, 1.0, numeric.Fields[0].At(0))
+	// The last value of range series is used
+	require.Equal(t, "Value", numeric.Fields[1].Name)
+	require.Equal(t, 0.0, numeric.Fields[1].At(0))
+
+	t.Run("no series is an empty numeric frame", func(t *testing.T) {
+		empty := data.NewFrame("")
+		empty.Meta = &data.FrameMeta{ExecutedQueryString: "Expr: up"}
+		frames := toNumericFrame(data.Frames{empty})
+		require.Len(t, frames, 1)
+		require.Equal(t, "Expr: up", frames[0].Meta.ExecutedQueryString)
+		require.Equal(t, data.FrameTypeNumericWide, frames[0].Meta.Type)
+		require.Empty(t, frames[0].Fields)
+	})
+}
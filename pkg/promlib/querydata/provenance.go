@@ -0,0 +1,98 @@
+package querydata
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+const recordingRulesCacheKey = "recordingRules"
+
+// addRecordingRuleProvenance adds a notice to the first frame for each metric of the query that is recorded
+// by a recording rule of the data source, with the expression it is recorded from.
+// Failing to read the rules is not an error, the frames are left as they are.
+func (s *QueryData) addRecordingRuleProvenance(ctx context.Context, c *client.Client, q *models.Query, identity string, frames data.Frames) {
+	if len(frames) == 0 {
+		return
+	}
+
+	names := selectorNames(q.Expr)
+	if len(names) == 0 {
+		return
+	}
+
+	rules, err := s.recordingRules(ctx, c, identity)
+	if err != nil {
+		s.log.FromContext(ctx).Debug("Failed to read recording rules", "err", err)
+		return
+	}
+
+	for _, name := range names {
+		if expr, ok := rules[name]; ok {
+			frames[0].AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityInfo,
+				Text:     fmt.Sprintf("%s is recorded from %s", name, expr),
+			})
+		}
+	}
+}
+
+// recordingRules returns the expressions of the recording rules of the data source by the name of the
+// metric they record. The rules are cached as every query of a dashboard would read them otherwise, for
+// each identity as the data source may only return some of them to a user, see resultCacheIdentity.
+func (s *QueryData) recordingRules(ctx context.Context, c *client.Client, identity string) (map[string]string, error) {
+	key := recordingRulesCacheKey + "/" + identity
+	if rules, found := s.recordingRulesCache.Get(key); found {
+		return rules.(map[string]string), nil
+	}
+
+	res, err := c.QueryAPI(ctx, "api/v1/rules", map[string]string{"type": "record"})
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := client.DecodeAPIResponse[ruleGroups](res, s.log.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	rules := map[string]string{}
+	for _, group := range rsp.Data.Groups {
+		for _, r := range group.Rules {
+			if _, exists := rules[r.Name]; r.Type == "recording" && !exists {
+				rules[r.Name] = r.Query
+			}
+		}
+	}
+	s.recordingRulesCache.Set(key, rules, cache.DefaultExpiration)
+	return rules, nil
+}
+
+// selectorNames returns the sorted distinct metric names selected by expr
+func selectorNames(expr string) []string {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]struct{}{}
+	parser.Inspect(parsed, func(node parser.Node, _ []parser.Node) error {
+		if vs, ok := node.(*parser.VectorSelector); ok && vs.Name != "" {
+			seen[vs.Name] = struct{}{}
+		}
+		return nil
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
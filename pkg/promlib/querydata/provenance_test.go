@@ -0,0 +1,54 @@
+package querydata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestSelectorNames(t *testing.T) {
+	require.Equal(t, []string{"job:requests:rate5m", "up"}, selectorNames(`up * on(job) sum(job:requests:rate5m) by (job) / up`))
+	require.Empty(t, selectorNames(`{job="api"}`))
+	require.Nil(t, selectorNames(`up{`))
+}
+
+func TestAddRecordingRuleProvenance(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "/api/v1/rules", r.URL.Path)
+		require.Equal(t, "record", r.URL.Query().Get("type"))
+		_, _ = w.Write([]byte(`{"status":"success","data":{"groups":[{"name":"api","rules":[
+			{"type":"recording","name":"job:requests:rate5m","query":"sum by (job) (rate(requests_total[5m]))"}
+		]}]}}`))
+	}))
+	defer srv.Close()
+
+	s, err := New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{"recordingRuleProvenance": true}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	q := &models.Query{Expr: `job:requests:rate5m > 0 and up`}
+	for _, identity := range []string{"1/alice", "1/alice", "1/bob"} {
+		frames := data.Frames{data.NewFrame("")}
+		s.addRecordingRuleProvenance(context.Background(), s.client, q, identity, frames)
+		require.Equal(t, []data.Notice{{
+			Severity: data.NoticeSeverityInfo,
+			Text:     "job:requests:rate5m is recorded from sum by (job) (rate(requests_total[5m]))",
+		}}, frames[0].Meta.Notices)
+	}
+
+	// The rules are read once for each identity and then cached
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,336 @@
+package querydata
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+	"github.com/grafana/grafana/pkg/promlib/models"
+	"github.com/grafana/grafana/pkg/promlib/utils"
+)
+
+// maxRemoteReadFrameSize bounds the size of a single message of a streamed remote read response, as Prometheus does
+const maxRemoteReadFrameSize = 50 * 1024 * 1024
+
+// defaultMaxSampledRemoteReadSize bounds the size of a SAMPLES response, compressed and decoded, when
+// the data source does not set maxResponseBytes
+const defaultMaxSampledRemoteReadSize = maxRemoteReadFrameSize
+
+const streamedRemoteReadContentType = "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse"
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// remoteReadResponseTypes returns the remote read response types accepted from the data source, in order of preference.
+// Remote read is opt-in, range queries use the HTTP API unless a response type is configured.
+func remoteReadResponseTypes(configured string) ([]prompb.ReadRequest_ResponseType, error) {
+	if configured == "" {
+		return nil, nil
+	}
+
+	v, ok := prompb.ReadRequest_ResponseType_value[configured]
+	if !ok {
+		return nil, fmt.Errorf("invalid remote read response type %q, expected SAMPLES or STREAMED_XOR_CHUNKS", configured)
+	}
+	return []prompb.ReadRequest_ResponseType{prompb.ReadRequest_ResponseType(v)}, nil
+}
+
+// remoteReadMatchers returns the label matchers of expr when it is a plain selector, the only
+// expressions that can be read with the remote read protocol as it does not evaluate PromQL.
+func remoteReadMatchers(expr string) ([]*prompb.LabelMatcher, bool) {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, false
+	}
+	vs, ok := parsed.(*parser.VectorSelector)
+	if !ok || vs.OriginalOffset != 0 || vs.Timestamp != nil || vs.StartOrEnd != 0 {
+		return nil, false
+	}
+
+	matchers := make([]*prompb.LabelMatcher, 0, len(vs.LabelMatchers))
+	for _, m := range vs.LabelMatchers {
+		pm := &prompb.LabelMatcher{Name: m.Name, Value: m.Value}
+		switch m.Type {
+		case labels.MatchEqual:
+			pm.Type = prompb.LabelMatcher_EQ
+		case labels.MatchNotEqual:
+			pm.Type = prompb.LabelMatcher_NEQ
+		case labels.MatchRegexp:
+			pm.Type = prompb.LabelMatcher_RE
+		case labels.MatchNotRegexp:
+			pm.Type = prompb.LabelMatcher_NRE
+		}
+		matchers = append(matchers, pm)
+	}
+	return matchers, true
+}
+
+// remoteReadQuery reads the raw samples of a plain selector with the remote read protocol. As the
+// protocol does not evaluate PromQL, they are resampled to the step of the query like the HTTP API
+// would evaluate the selector, see resampleSeries.
+func (s *QueryData) remoteReadQuery(ctx context.Context, c *client.Client, q *models.Query, matchers []*prompb.LabelMatcher, enablePrometheusDataplaneFlag bool) backend.DataResponse {
+	res, err := c.RemoteRead(ctx, q, matchers, s.remoteReadResponseTypes)
+	if err != nil {
+		return backend.DataResponse{
+			Error:  err,
+			Status: backend.StatusBadGateway,
+		}
+	}
+
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			s.log.FromContext(ctx).Error("Failed to close response body", "err", err)
+		}
+	}()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return backend.DataResponse{
+			Error:  fmt.Errorf("remote read failed with status %s: %s", res.Status, strings.TrimSpace(string(body))),
+			Status: backend.Status(res.StatusCode),
+		}
+	}
+
+	// The response type is the first accepted one the server supports
+	var series []*prompb.TimeSeries
+	body := &limitedReader{r: res.Body, max: s.limits.maxBytes}
+	start := time.Now()
+	tr := q.TimeRange()
+	if res.Header.Get("Content-Type") == streamedRemoteReadContentType {
+		series, err = readStreamedSeries(body, tr.Start.Add(-q.Lookback()).UnixMilli(), tr.End.UnixMilli())
+	} else {
+		maxSize := s.limits.maxBytes
+		if maxSize <= 0 {
+			maxSize = defaultMaxSampledRemoteReadSize
+		}
+		series, err = readSampledSeries(body, maxSize)
+	}
+	utils.QueryTimingsFromContext(ctx).Add(utils.TimingPhaseDecode, time.Since(start))
+	if limitErr := body.limitError(err); limitErr != nil {
+		return backend.DataResponse{
+			Error:  limitErr,
+			Status: backend.Status(res.StatusCode),
+		}
+	}
+	if err != nil {
+		return backend.DataResponse{
+			Error:  fmt.Errorf("failed to read remote read response: %w", err),
+			Status: backend.Status(res.StatusCode),
+		}
+	}
+
+	frames := remoteReadFrames(resampleSeries(series, tr, q.Lookback()), enablePrometheusDataplaneFlag)
+	if len(frames) == 0 {
+		frames = append(frames, data.NewFrame(""))
+	}
+	addMetadataToFrames(q, res.Request, frames, enablePrometheusDataplaneFlag)
+
+	return backend.DataResponse{
+		Frames: frames,
+		Status: backend.Status(res.StatusCode),
+	}
+}
+
+// readSampledSeries reads a SAMPLES response, a single snappy compressed ReadResponse. Responses
+// larger than maxSize, compressed or not, are an error.
+func readSampledSeries(r io.Reader, maxSize int64) ([]*prompb.TimeSeries, error) {
+	compressed, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(compressed)) > maxSize {
+		return nil, fmt.Errorf("response exceeds the limit of %d bytes", maxSize)
+	}
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if int64(size) > maxSize {
+		return nil, fmt.Errorf("decoded response of %d bytes exceeds the limit of %d bytes", size, maxSize)
+	}
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsp prompb.ReadResponse
+	if err := rsp.Unmarshal(b); err != nil {
+		return nil, err
+	}
+
+	var series []*prompb.TimeSeries
+	for _, result := range rsp.Results {
+		series = append(series, result.Timeseries...)
+	}
+	return series, nil
+}
+
+// readStreamedSeries reads a STREAMED_XOR_CHUNKS response. It is a sequence of messages made of the
+// uvarint size of a ChunkedReadResponse, its CRC32 checksum and the ChunkedReadResponse itself.
+// The chunks of a series may be split over consecutive messages. Only the samples between mint and maxt are kept
+// as chunks are sent whole, even when they cross the boundaries of the range.
+func readStreamedSeries(r io.Reader, mint, maxt int64) ([]*prompb.TimeSeries, error) {
+	br := bufio.NewReader(r)
+	var series []*prompb.TimeSeries
+	var last *prompb.TimeSeries
+	for {
+		size, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			return series, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size > maxRemoteReadFrameSize {
+			return nil, fmt.Errorf("message of %d bytes exceeds the limit of %d bytes", size, maxRemoteReadFrameSize)
+		}
+
+		var checksum [4]byte
+		if _, err := io.ReadFull(br, checksum[:]); err != nil {
+			return nil, err
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return nil, err
+		}
+		if crc32.Checksum(b, castagnoliTable) != binary.BigEndian.Uint32(checksum[:]) {
+			return nil, errors.New("message checksum mismatch")
+		}
+
+		var rsp prompb.ChunkedReadResponse
+		if err := rsp.Unmarshal(b); err != nil {
+			return nil, err
+		}
+
+		for _, cs := range rsp.ChunkedSeries {
+			if last == nil || !labelsEqual(last.Labels, cs.Labels) {
+				last = &prompb.TimeSeries{Labels: cs.Labels}
+				series = append(series, last)
+			}
+			if last.Samples, err = appendChunkSamples(last.Samples, cs.Chunks, mint, maxt); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// appendChunkSamples decodes the float samples of XOR chunks between mint and maxt. Native histogram chunks are skipped.
+func appendChunkSamples(samples []prompb.Sample, chunks []prompb.Chunk, mint, maxt int64) ([]prompb.Sample, error) {
+	for _, chk := range chunks {
+		if chk.Type != prompb.Chunk_XOR || chk.MaxTimeMs < mint || chk.MinTimeMs > maxt {
+			continue
+		}
+		c, err := chunkenc.FromData(chunkenc.EncXOR, chk.Data)
+		if err != nil {
+			return nil, err
+		}
+		it := c.Iterator(nil)
+		for it.Next() == chunkenc.ValFloat {
+			t, v := it.At()
+			if t < mint {
+				continue
+			}
+			if t > maxt {
+				break
+			}
+			samples = append(samples, prompb.Sample{Timestamp: t, Value: v})
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return samples, nil
+}
+
+// resampleSeries evaluates the samples of series at each step of tr, like Prometheus evaluates a
+// selector: the value at a step is the one of the last sample within lookback before it, unless it
+// is a stale marker. The samples must be sorted, series without a value are dropped.
+func resampleSeries(series []*prompb.TimeSeries, tr models.TimeRange, lookback time.Duration) []*prompb.TimeSeries {
+	start, end, step := tr.Start.UnixMilli(), tr.End.UnixMilli(), tr.Step.Milliseconds()
+	if step <= 0 {
+		start = end
+		step = 1
+	}
+
+	resampled := make([]*prompb.TimeSeries, 0, len(series))
+	for _, ts := range series {
+		var samples []prompb.Sample
+		i := 0
+		for t := start; t <= end; t += step {
+			for i < len(ts.Samples) && ts.Samples[i].Timestamp <= t {
+				i++
+			}
+			if i == 0 {
+				continue
+			}
+			last := ts.Samples[i-1]
+			if last.Timestamp <= t-lookback.Milliseconds() || value.IsStaleNaN(last.Value) {
+				continue
+			}
+			samples = append(samples, prompb.Sample{Timestamp: t, Value: last.Value})
+		}
+		if len(samples) > 0 {
+			resampled = append(resampled, &prompb.TimeSeries{Labels: ts.Labels, Samples: samples})
+		}
+	}
+	return resampled
+}
+
+func labelsEqual(a, b []prompb.Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// remoteReadFrames returns a time series frame for each series, like the ones of a matrix result
+func remoteReadFrames(series []*prompb.TimeSeries, enableDataplane bool) data.Frames {
+	frames := make(data.Frames, 0, len(series))
+	for _, ts := range series {
+		times := make([]time.Time, len(ts.Samples))
+		values := make([]float64, len(ts.Samples))
+		for i, sample := range ts.Samples {
+			times[i] = time.UnixMilli(sample.Timestamp).UTC()
+			values[i] = sample.Value
+		}
+
+		lbls := make(data.Labels, len(ts.Labels))
+		for _, l := range ts.Labels {
+			lbls[l.Name] = l.Value
+		}
+
+		frame := data.NewFrame("",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+			data.NewField(data.TimeSeriesValueFieldName, lbls, values),
+		)
+		frame.Meta = &data.FrameMeta{
+			Type:   data.FrameTypeTimeSeriesMulti,
+			Custom: map[string]string{"resultType": models.ResultTypeMatrix.String()},
+		}
+		if enableDataplane {
+			frame.Meta.TypeVersion = data.FrameTypeVersion{0, 1}
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
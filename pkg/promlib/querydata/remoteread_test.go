@@ -0,0 +1,186 @@
+package querydata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestRemoteReadMatchers(t *testing.T) {
+	matchers, ok := remoteReadMatchers(`up{job="api", instance!="a", env=~"prod.*", zone!~"eu.*"}`)
+	require.True(t, ok)
+	require.Equal(t, []*prompb.LabelMatcher{
+		{Type: prompb.LabelMatcher_EQ, Name: "job", Value: "api"},
+		{Type: prompb.LabelMatcher_NEQ, Name: "instance", Value: "a"},
+		{Type: prompb.LabelMatcher_RE, Name: "env", Value: "prod.*"},
+		{Type: prompb.LabelMatcher_NRE, Name: "zone", Value: "eu.*"},
+		{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"},
+	}, matchers)
+
+	for _, expr := range []string{
+		`rate(up[5m])`,
+		`sum(up)`,
+		`up offset 1h`,
+		`up @ 1000`,
+		`up{`,
+	} {
+		_, ok := remoteReadMatchers(expr)
+		require.False(t, ok, expr)
+	}
+}
+
+func TestRemoteReadResponseTypes(t *testing.T) {
+	rt, err := remoteReadResponseTypes("")
+	require.NoError(t, err)
+	require.Nil(t, rt)
+
+	rt, err = remoteReadResponseTypes("SAMPLES")
+	require.NoError(t, err)
+	require.Equal(t, []prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES}, rt)
+
+	rt, err = remoteReadResponseTypes("STREAMED_XOR_CHUNKS")
+	require.NoError(t, err)
+	require.Equal(t, []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS}, rt)
+
+	_, err = remoteReadResponseTypes("streamed")
+	require.Error(t, err)
+}
+
+func TestReadSampledSeries(t *testing.T) {
+	rsp := &prompb.ReadResponse{Results: []*prompb.QueryResult{{
+		Timeseries: []*prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+			Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 0}},
+		}},
+	}}}
+	b, err := rsp.Marshal()
+	require.NoError(t, err)
+
+	series, err := readSampledSeries(bytes.NewReader(snappy.Encode(nil, b)), defaultMaxSampledRemoteReadSize)
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	require.Equal(t, []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 0}}, series[0].Samples)
+
+	t.Run("responses over the limit are an error", func(t *testing.T) {
+		compressed := snappy.Encode(nil, b)
+		_, err := readSampledSeries(bytes.NewReader(compressed), int64(len(compressed)-1))
+		require.ErrorContains(t, err, "exceeds the limit")
+
+		// Highly compressible responses are checked before they are decoded
+		compressed = snappy.Encode(nil, make([]byte, 1<<20))
+		_, err = readSampledSeries(bytes.NewReader(compressed), 1<<19)
+		require.ErrorContains(t, err, "decoded response of 1048576 bytes exceeds the limit")
+	})
+}
+
+func TestResampleSeries(t *testing.T) {
+	up := []prompb.Label{{Name: "__name__", Value: "up"}}
+	tr := models.TimeRange{Start: time.UnixMilli(60000), End: time.UnixMilli(240000), Step: time.Minute}
+	series := resampleSeries([]*prompb.TimeSeries{
+		{
+			Labels: up,
+			// Read from before the start, and stale after 150s
+			Samples: []prompb.Sample{{Timestamp: 15000, Value: 1}, {Timestamp: 75000, Value: 2}, {Timestamp: 90000, Value: 3}, {Timestamp: 150000, Value: math.Float64frombits(value.StaleNaN)}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "down"}},
+			Samples: []prompb.Sample{{Timestamp: 0, Value: 0}},
+		},
+	}, tr, time.Minute)
+
+	// The value at each step is the one of the last sample within the lookback before it, its start excluded.
+	// Series without any are dropped
+	require.Equal(t, []*prompb.TimeSeries{{
+		Labels:  up,
+		Samples: []prompb.Sample{{Timestamp: 60000, Value: 1}, {Timestamp: 120000, Value: 3}},
+	}}, series)
+}
+
+func TestReadStreamedSeries(t *testing.T) {
+	up := []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "api"}}
+	down := []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "db"}}
+
+	var buf bytes.Buffer
+	// The chunks of the first series are split over two messages
+	writeChunkedMessage(t, &buf, prompb.ChunkedSeries{Labels: up, Chunks: []prompb.Chunk{xorChunk(t, 1000, 1)}})
+	writeChunkedMessage(t, &buf, prompb.ChunkedSeries{Labels: up, Chunks: []prompb.Chunk{xorChunk(t, 2000, 2)}})
+	writeChunkedMessage(t, &buf, prompb.ChunkedSeries{Labels: down, Chunks: []prompb.Chunk{xorChunk(t, 1000, 0)}})
+
+	series, err := readStreamedSeries(&buf, 0, 3000)
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	require.Equal(t, up, series[0].Labels)
+	require.Equal(t, []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}}, series[0].Samples)
+	require.Equal(t, down, series[1].Labels)
+	require.Equal(t, []prompb.Sample{{Timestamp: 1000, Value: 0}}, series[1].Samples)
+
+	t.Run("checksum mismatch is an error", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeChunkedMessage(t, &buf, prompb.ChunkedSeries{Labels: up, Chunks: []prompb.Chunk{xorChunk(t, 1000, 1)}})
+		b := buf.Bytes()
+		b[len(b)-1] ^= 0xff
+		_, err := readStreamedSeries(bytes.NewReader(b), 0, 3000)
+		require.ErrorContains(t, err, "checksum")
+	})
+
+	t.Run("samples of chunks crossing the range are trimmed to it", func(t *testing.T) {
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		require.NoError(t, err)
+		for ts := int64(0); ts <= 4000; ts += 1000 {
+			app.Append(ts, float64(ts/1000))
+		}
+		chunk := prompb.Chunk{MinTimeMs: 0, MaxTimeMs: 4000, Type: prompb.Chunk_XOR, Data: c.Bytes()}
+
+		var buf bytes.Buffer
+		writeChunkedMessage(t, &buf, prompb.ChunkedSeries{Labels: up, Chunks: []prompb.Chunk{chunk, xorChunk(t, 5000, 5)}})
+		series, err := readStreamedSeries(&buf, 1000, 3000)
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		require.Equal(t, []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}, {Timestamp: 3000, Value: 3}}, series[0].Samples)
+	})
+}
+
+func TestRemoteReadFrames(t *testing.T) {
+	frames := remoteReadFrames([]*prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+		Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}},
+	}}, false)
+	require.Len(t, frames, 1)
+	require.Equal(t, data.FrameTypeTimeSeriesMulti, frames[0].Meta.Type)
+	require.Equal(t, data.Labels{"__name__": "up"}, frames[0].Fields[1].Labels)
+	require.Equal(t, time.UnixMilli(1000).UTC(), frames[0].Fields[0].At(0))
+	require.Equal(t, 1.0, frames[0].Fields[1].At(0))
+}
+
+func xorChunk(t *testing.T, ts int64, v float64) prompb.Chunk {
+	t.Helper()
+	c := chunkenc.NewXORChunk()
+	app, err := c.Appender()
+	require.NoError(t, err)
+	app.Append(ts, v)
+	return prompb.Chunk{MinTimeMs: ts, MaxTimeMs: ts, Type: prompb.Chunk_XOR, Data: c.Bytes()}
+}
+
+func writeChunkedMessage(t *testing.T, buf *bytes.Buffer, series prompb.ChunkedSeries) {
+	t.Helper()
+	rsp := &prompb.ChunkedReadResponse{ChunkedSeries: []*prompb.ChunkedSeries{&series}}
+	b, err := rsp.Marshal()
+	require.NoError(t, err)
+
+	buf.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli))))
+	buf.Write(b)
+}
@@ -2,20 +2,28 @@ package querydata
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana-plugin-sdk-go/data/utils/maputil"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
 
 	"github.com/grafana/grafana/pkg/promlib/client"
+	"github.com/grafana/grafana/pkg/promlib/instrumentation"
 	"github.com/grafana/grafana/pkg/promlib/intervalv2"
 	"github.com/grafana/grafana/pkg/promlib/models"
 	"github.com/grafana/grafana/pkg/promlib/querydata/exemplar"
@@ -24,6 +32,20 @@ import (
 
 const legendFormatAuto = "__auto"
 
+// defaultMaxQueryTimeout bounds the timeout of queries when the data source does not set maxQueryTimeout
+const defaultMaxQueryTimeout = 10 * time.Minute
+
+// The jsonData.prometheusType values of the data sources whose query frontend has a results cache, they
+// do not understand the Thanos options
+const (
+	prometheusTypeMimir  = "Mimir"
+	prometheusTypeCortex = "Cortex"
+)
+
+// errQueryCancelled is the error of the queries whose request was cancelled, like the ones of a
+// dashboard that was left
+var errQueryCancelled = errors.New("query cancelled")
+
 var legendFormatRegexp = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
 
 type ExemplarEvent struct {
@@ -40,9 +62,60 @@ type QueryData struct {
 	client             *client.Client
 	log                log.Logger
 	ID                 int64
+	UID                string
 	URL                string
 	TimeInterval       string
+	PrometheusType     string
 	exemplarSampler    func() exemplar.Sampler
+
+	// Whether units and descriptions of result fields are set from the metric metadata
+	metadataEnrichment bool
+
+	// Whether the expressions of the recording rules of queried metrics are added to the results
+	recordingRuleProvenance bool
+	recordingRulesCache     *cache.Cache
+
+	limits responseLimits
+
+	// Queries with their own timeout are run with untimedClient, which does not have the timeout
+	// of the data source, and a context with their timeout bounded by maxQueryTimeout
+	untimedClient   *client.Client
+	maxQueryTimeout time.Duration
+
+	// When set, range queries of plain selectors read raw samples with the remote read protocol,
+	// resampled to their step
+	remoteReadResponseTypes []prompb.ReadRequest_ResponseType
+
+	// Canonical names of the headers queries are allowed to send
+	allowedQueryHeaders map[string]struct{}
+
+	// Tenants queries are allowed to read instead of the tenant of the data source, nil when not set
+	allowedQueryTenants map[string]struct{}
+
+	// The rules of the label matchers added to the selectors of the queries of users
+	enforcedMatchersRules []models.EnforcedMatchersRule
+
+	// Returns the features supported by the backend, all of them are used when unset
+	capabilities func(ctx context.Context) models.Capabilities
+
+	// The evaluation time of the instant queries of alert rules is rounded down to a multiple of it,
+	// zero when it is not
+	alertingInstantQueryAlignment time.Duration
+
+	// Queries taking longer are counted and get a notice, zero when they are not
+	slowQueryThreshold time.Duration
+
+	// The number of shards Mimir splits the queries it can shard in instead of its own, one disables
+	// sharding and zero keeps the one of Mimir
+	queryShards int64
+
+	// Queries are sent to auditSink when auditQueries is set
+	auditQueries bool
+	auditSink    AuditSink
+
+	// Results are cached when both are set
+	resultCache         ResultCache
+	resultCacheSettings resultCacheSettings
 }
 
 func New(
@@ -61,11 +134,118 @@ func New(
 		return nil, err
 	}
 
+	prometheusType, err := maputil.GetStringOptional(jsonData, "prometheusType")
+	if err != nil {
+		return nil, err
+	}
+
+	metadataEnrichment, err := maputil.GetBoolOptional(jsonData, "metricMetadataEnrichment")
+	if err != nil {
+		return nil, err
+	}
+
+	recordingRuleProvenance, err := maputil.GetBoolOptional(jsonData, "recordingRuleProvenance")
+	if err != nil {
+		return nil, err
+	}
+
+	auditQueries, err := maputil.GetBoolOptional(jsonData, "queryAuditLog")
+	if err != nil {
+		return nil, err
+	}
+
+	remoteReadType, err := maputil.GetStringOptional(jsonData, "remoteReadResponseType")
+	if err != nil {
+		return nil, err
+	}
+	remoteReadTypes, err := remoteReadResponseTypes(remoteReadType)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := parseResponseLimits(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedQueryHeaders, err := parseAllowedQueryHeaders(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedQueryTenants, err := parseAllowedQueryTenants(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	enforcedMatchersRules, err := models.ParseEnforcedLabelMatchers(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	resultCacheSettings, err := parseResultCacheSettings(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	// GET queries with a longer URL are sent with POST, a configured 0 disables the switch
+	maxGetURLLength := int64(client.DefaultMaxGetURLLength)
+	if v, ok := jsonData["maxGetUrlLength"]; ok && v != nil {
+		if maxGetURLLength, err = utils.GetInt64Optional(jsonData, "maxGetUrlLength"); err != nil {
+			return nil, err
+		}
+	}
+
+	maxQueryTimeout := defaultMaxQueryTimeout
+	if v, err := maputil.GetStringOptional(jsonData, "maxQueryTimeout"); err != nil {
+		return nil, err
+	} else if v != "" {
+		if maxQueryTimeout, err = gtime.ParseIntervalStringToTimeDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid maxQueryTimeout: %w", err)
+		}
+	}
+
+	var slowQueryThreshold time.Duration
+	if v, err := maputil.GetStringOptional(jsonData, "slowQueryThreshold"); err != nil {
+		return nil, err
+	} else if v != "" {
+		if slowQueryThreshold, err = gtime.ParseIntervalStringToTimeDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid slowQueryThreshold: %w", err)
+		}
+	}
+
+	queryShards, err := utils.GetInt64Optional(jsonData, "queryShards")
+	if err != nil {
+		return nil, err
+	}
+
+	var alertingInstantQueryAlignment time.Duration
+	if v, err := maputil.GetStringOptional(jsonData, "alertingInstantQueryAlignment"); err != nil {
+		return nil, err
+	} else if v != "" {
+		if alertingInstantQueryAlignment, err = gtime.ParseIntervalStringToTimeDuration(v); err != nil || alertingInstantQueryAlignment < 0 {
+			return nil, fmt.Errorf("invalid alertingInstantQueryAlignment %q", v)
+		}
+	}
+
 	if httpMethod == "" {
 		httpMethod = http.MethodPost
 	}
 
+	endpointMethods, err := client.ParseEndpointMethods(jsonData)
+	if err != nil {
+		return nil, err
+	}
+
 	promClient := client.NewClient(httpClient, httpMethod, settings.URL)
+	promClient.SetMaxGetURLLength(int(maxGetURLLength))
+	promClient.SetEndpointMethods(endpointMethods)
+
+	untimedHttpClient := *httpClient
+	untimedHttpClient.Timeout = 0
+	untimedClient := client.NewClient(&untimedHttpClient, httpMethod, settings.URL)
+	untimedClient.SetMaxGetURLLength(int(maxGetURLLength))
+	untimedClient.SetEndpointMethods(endpointMethods)
 
 	// standard deviation sampler is the default for backwards compatibility
 	exemplarSampler := exemplar.NewStandardDeviationSampler
@@ -76,12 +256,57 @@ func New(
 		log:                plog,
 		client:             promClient,
 		TimeInterval:       timeInterval,
+		PrometheusType:     prometheusType,
 		ID:                 settings.ID,
+		UID:                settings.UID,
 		URL:                settings.URL,
 		exemplarSampler:    exemplarSampler,
+		metadataEnrichment: metadataEnrichment,
+		limits:             limits,
+		untimedClient:      untimedClient,
+		maxQueryTimeout:    maxQueryTimeout,
+
+		remoteReadResponseTypes: remoteReadTypes,
+		allowedQueryHeaders:     allowedQueryHeaders,
+		allowedQueryTenants:     allowedQueryTenants,
+		enforcedMatchersRules:   enforcedMatchersRules,
+		recordingRuleProvenance: recordingRuleProvenance,
+		recordingRulesCache:     cache.New(time.Minute, 5*time.Minute),
+		resultCacheSettings:     resultCacheSettings,
+		slowQueryThreshold:      slowQueryThreshold,
+		queryShards:             queryShards,
+		auditQueries:            auditQueries,
+		auditSink:               NewLogAuditSink(plog),
+
+		alertingInstantQueryAlignment: alertingInstantQueryAlignment,
 	}, nil
 }
 
+// SetCapabilities sets the function detecting the features supported by the backend,
+// queries do not use the other ones.
+func (s *QueryData) SetCapabilities(capabilities func(ctx context.Context) models.Capabilities) {
+	s.capabilities = capabilities
+}
+
+// SetResultCache sets the cache the results of queries are stored in, when the data source sets
+// the queryResultCacheTTL they are cached for.
+func (s *QueryData) SetResultCache(resultCache ResultCache) {
+	s.resultCache = resultCache
+}
+
+// SetAuditSink sets the sink the queries are audited to, when the data source sets queryAuditLog.
+// They are logged by default.
+func (s *QueryData) SetAuditSink(auditSink AuditSink) {
+	s.auditSink = auditSink
+}
+
+func (s *QueryData) supported(ctx context.Context) models.Capabilities {
+	if s.capabilities == nil {
+		return models.AllCapabilities
+	}
+	return s.capabilities(ctx)
+}
+
 func (s *QueryData) Execute(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	fromAlert := req.Headers["FromAlert"] == "true"
 	result := backend.QueryDataResponse{
@@ -92,8 +317,21 @@ func (s *QueryData) Execute(ctx context.Context, req *backend.QueryDataRequest)
 	hasPromQLScopeFeatureFlag := cfg.FeatureToggles().IsEnabled("promQLScope")
 	hasPrometheusDataplaneFeatureFlag := cfg.FeatureToggles().IsEnabled("prometheusDataplane")
 
+	// Results are not shared between users
+	identity := resultCacheIdentity(req)
+
+	enforced, enforceErr := models.EnforcedMatchers(s.enforcedMatchersRules, req.PluginContext.User)
+	origin := s.requestAuditEntry(req, fromAlert)
+
 	for _, q := range req.Queries {
-		r := s.handleQuery(ctx, q, fromAlert, hasPromQLScopeFeatureFlag, hasPrometheusDataplaneFeatureFlag)
+		if enforceErr != nil {
+			result.Responses[q.RefID] = backend.DataResponse{
+				Error:  enforceErr,
+				Status: backend.StatusForbidden,
+			}
+			continue
+		}
+		r := s.handleQuery(ctx, q, identity, enforced, origin, fromAlert, hasPromQLScopeFeatureFlag, hasPrometheusDataplaneFeatureFlag)
 		if r == nil {
 			continue
 		}
@@ -103,9 +341,21 @@ func (s *QueryData) Execute(ctx context.Context, req *backend.QueryDataRequest)
 	return &result, nil
 }
 
-func (s *QueryData) handleQuery(ctx context.Context, bq backend.DataQuery, fromAlert, hasPromQLScopeFeatureFlag, hasPrometheusDataplaneFeatureFlag bool) *backend.DataResponse {
+func (s *QueryData) handleQuery(ctx context.Context, bq backend.DataQuery, identity string, enforced []*labels.Matcher, origin QueryAuditEntry, fromAlert, hasPromQLScopeFeatureFlag, hasPrometheusDataplaneFeatureFlag bool) *backend.DataResponse {
 	traceCtx, span := s.tracer.Start(ctx, "datasource.prometheus")
 	defer span.End()
+
+	if queryType := models.QueryType(bq.QueryType); queryType.IsAPIQuery() {
+		// The matchers cannot be enforced on the results of the API, like the rules or the targets
+		if len(enforced) > 0 {
+			return &backend.DataResponse{
+				Error:  fmt.Errorf("%s queries are not allowed, the data source enforces label matchers", queryType),
+				Status: backend.StatusForbidden,
+			}
+		}
+		return s.handleAPIQuery(traceCtx, bq, queryType)
+	}
+
 	query, err := models.Parse(span, bq, s.TimeInterval, s.intervalCalculator, fromAlert, hasPromQLScopeFeatureFlag)
 	if err != nil {
 		return &backend.DataResponse{
@@ -113,9 +363,190 @@ func (s *QueryData) handleQuery(ctx context.Context, bq backend.DataQuery, fromA
 		}
 	}
 
-	r := s.fetch(traceCtx, s.client, query, hasPrometheusDataplaneFeatureFlag)
+	if err := checkQueryHeaders(query, s.allowedQueryHeaders, s.allowedQueryTenants); err != nil {
+		return &backend.DataResponse{
+			Error:  err,
+			Status: backend.StatusBadRequest,
+		}
+	}
+
+	// The end of the range of alert queries is the scheduled tick of their rule, the alignment removes
+	// the differences left between the instances of Grafana evaluating the same rule, so they read the
+	// same samples
+	if fromAlert && query.InstantQuery && s.alertingInstantQueryAlignment > 0 {
+		query.End = models.AlignTimeRange(query.End, s.alertingInstantQueryAlignment, 0)
+	}
+
+	// After the filters of the query, so they cannot override them
+	if query.Expr, err = models.EnforceLabelMatchers(query.Expr, enforced); err != nil {
+		return &backend.DataResponse{
+			Error:  err,
+			Status: backend.StatusBadRequest,
+		}
+	}
+
+	// Thanos options are only sent when the data source is not known to be something else, whatever its
+	// configured type, as it is Prometheus for most Thanos data sources
+	if query.PartialResponse != nil || query.MaxSourceResolution != "" || query.Dedup != nil {
+		thanos := s.PrometheusType != prometheusTypeMimir && s.PrometheusType != prometheusTypeCortex
+		if s.capabilities != nil {
+			// The detected flavor falls back to the configured one
+			thanos = s.supported(traceCtx).ThanosOptions
+		}
+		if !thanos {
+			query.PartialResponse = nil
+			query.MaxSourceResolution = ""
+			query.Dedup = nil
+		}
+	}
+
+	// The results cache of the query frontend is bypassed too, the header is only sent to the ones honoring it
+	if query.NoCache {
+		bypass := s.PrometheusType == prometheusTypeMimir || s.PrometheusType == prometheusTypeCortex
+		if s.capabilities != nil {
+			bypass = s.supported(traceCtx).CacheBypass
+		}
+		if bypass {
+			query.Headers = maps.Clone(query.Headers)
+			if query.Headers == nil {
+				query.Headers = map[string]string{}
+			}
+			query.Headers["Cache-Control"] = "no-store"
+		}
+	}
+
+	// Like the Thanos options, the number of shards is only sent to the query frontends honoring it
+	if s.queryShards > 0 {
+		sharding := s.PrometheusType == prometheusTypeMimir
+		if s.capabilities != nil {
+			sharding = s.supported(traceCtx).QuerySharding
+		}
+		if sharding {
+			query.Headers = maps.Clone(query.Headers)
+			if query.Headers == nil {
+				query.Headers = map[string]string{}
+			}
+			query.Headers[client.ShardingControlHeader] = strconv.FormatInt(s.queryShards, 10)
+		}
+	}
+
+	start := time.Now()
+	s.alignCachedRange(query)
+	key := s.resultCacheKey(query, identity)
+	if key != "" {
+		if r := s.cachedQueryResult(traceCtx, key, query.RefId); r != nil {
+			if s.auditQueries {
+				s.auditQuery(ctx, origin, query, start, r, true)
+			}
+			return r
+		}
+	}
+
+	// The phases of queries asking for statistics are timed, to tell the time spent by the data source
+	// from the one spent by Grafana
+	var timings *utils.QueryTimings
+	runCtx := traceCtx
+	if query.Stats {
+		timings = utils.NewQueryTimings()
+		runCtx = utils.WithQueryTimings(traceCtx, timings)
+	}
+
+	if r := s.cancelledResponse(ctx); r != nil {
+		return r
+	}
+	r := s.runQuery(runCtx, query, identity, hasPrometheusDataplaneFeatureFlag)
+	// The error of the aborted request is replaced, and the partial result is not cached
+	if cancelled := s.cancelledResponse(ctx); cancelled != nil {
+		r = cancelled
+	} else if key != "" {
+		s.cacheQueryResult(traceCtx, key, r)
+	}
+	if s.auditQueries {
+		s.auditQuery(ctx, origin, query, start, r, false)
+	}
+	s.checkSlowQuery(traceCtx, origin, query, time.Since(start), r)
+	// After caching, the timings of a cached result would be the ones of another query
+	if r != nil && timings != nil {
+		addTimings(r.Frames, timings)
+	}
+	return r
+}
+
+// checkSlowQuery counts a query that took longer than the slow query threshold of the data source,
+// logs it with the dashboard and panel it comes from, and adds a notice to its result. The dashboards
+// and panels are not labels of the counter, as there are too many of them.
+func (s *QueryData) checkSlowQuery(ctx context.Context, origin QueryAuditEntry, query *models.Query, took time.Duration, r *backend.DataResponse) {
+	if s.slowQueryThreshold <= 0 || took <= s.slowQueryThreshold {
+		return
+	}
+	instrumentation.IncSlowQueries(s.UID)
+	s.log.FromContext(ctx).Warn("Slow query", "query", query.Expr, "duration", took, "dashboard", origin.Dashboard, "panel", origin.Panel)
+	// Notices are shown for each frame, the first one is enough
+	if r != nil && len(r.Frames) > 0 {
+		r.Frames[0].AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("The query took %s, longer than the %s slow query threshold of the data source", took.Round(time.Millisecond), s.slowQueryThreshold),
+		})
+	}
+}
+
+// cancelledResponse returns the response of a query whose request was cancelled, or nil when it was
+// not. Its requests to the data source are aborted with ctx, which closes their connection or HTTP/2
+// stream, and Prometheus, Thanos and Mimir stop evaluating the queries of the requests closed that way.
+// The queries left once a request is cancelled are not sent.
+func (s *QueryData) cancelledResponse(ctx context.Context) *backend.DataResponse {
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		return nil
+	}
+	instrumentation.IncCancelledQueries(s.UID)
+	return &backend.DataResponse{
+		Error:       fmt.Errorf("%w: %w", errQueryCancelled, ctx.Err()),
+		ErrorSource: backend.ErrorSourceDownstream,
+	}
+}
+
+// runQuery fetches the result of query and post-processes it
+func (s *QueryData) runQuery(traceCtx context.Context, query *models.Query, identity string, hasPrometheusDataplaneFeatureFlag bool) *backend.DataResponse {
+	c := s.client
+	if query.Timeout > 0 {
+		if query.Timeout > s.maxQueryTimeout {
+			query.Timeout = s.maxQueryTimeout
+		}
+		var cancel context.CancelFunc
+		traceCtx, cancel = context.WithTimeout(traceCtx, query.Timeout)
+		defer cancel()
+		c = s.untimedClient
+	}
+
+	r := s.fetch(traceCtx, c, query, hasPrometheusDataplaneFeatureFlag)
 	if r == nil {
-		s.log.FromContext(ctx).Debug("Received nil response from runQuery", "query", query.Expr)
+		s.log.FromContext(traceCtx).Debug("Received nil response from runQuery", "query", query.Expr)
+		return r
+	}
+
+	if s.metadataEnrichment && r.Error == nil {
+		s.enrichFromMetadata(traceCtx, s.client, query.Expr, r.Frames)
+	}
+	if s.recordingRuleProvenance && r.Error == nil {
+		s.addRecordingRuleProvenance(traceCtx, s.client, query, identity, r.Frames)
+	}
+	if r.Error == nil {
+		start := time.Now()
+		defer func() {
+			utils.QueryTimingsFromContext(traceCtx).Add(utils.TimingPhaseConversion, time.Since(start))
+		}()
+		// Before the series are converted to other formats, like the last value of numeric ones
+		r.Frames = handleNonFiniteValues(r.Frames, query.NonFiniteValues)
+		switch query.Format {
+		case models.PromQueryFormatLong:
+			r.Frames = toLongFrame(r.Frames)
+		case models.PromQueryFormatTable:
+			r.Frames = toTableFrame(r.Frames)
+		case models.PromQueryFormatNumeric:
+			r.Frames = toNumericFrame(r.Frames)
+		case models.PromQueryFormatAnnotations:
+			r.Frames = toAnnotationFrame(query, r.Frames)
+		}
 	}
 	return r
 }
@@ -129,8 +560,14 @@ func (s *QueryData) fetch(traceCtx context.Context, client *client.Client, q *mo
 		Error:  nil,
 	}
 
+	// The frames of queries that are both instant and range are tagged with the query they come from
+	both := q.InstantQuery && q.RangeQuery
+
 	if q.InstantQuery {
 		res := s.instantQuery(traceCtx, client, q, enablePrometheusDataplane)
+		if both {
+			tagQueryType(res.Frames, models.InstantQueryType)
+		}
 		dr.Error = res.Error
 		dr.Frames = res.Frames
 		dr.Status = res.Status
@@ -138,6 +575,9 @@ func (s *QueryData) fetch(traceCtx context.Context, client *client.Client, q *mo
 
 	if q.RangeQuery {
 		res := s.rangeQuery(traceCtx, client, q, enablePrometheusDataplane)
+		if both {
+			tagQueryType(res.Frames, models.RangeQueryType)
+		}
 		if res.Error != nil {
 			if dr.Error == nil {
 				dr.Error = res.Error
@@ -151,7 +591,9 @@ func (s *QueryData) fetch(traceCtx context.Context, client *client.Client, q *mo
 		dr.Frames = append(dr.Frames, res.Frames...)
 	}
 
-	if q.ExemplarQuery {
+	if q.ExemplarQuery && !s.supported(traceCtx).Exemplars {
+		logger.Debug("Skipping exemplar query, exemplars are not supported by the data source", "query", q.Expr)
+	} else if q.ExemplarQuery {
 		res := s.exemplarQuery(traceCtx, client, q, enablePrometheusDataplane)
 		if res.Error != nil {
 			// If exemplar query returns error, we want to only log it and
@@ -165,6 +607,17 @@ func (s *QueryData) fetch(traceCtx context.Context, client *client.Client, q *mo
 }
 
 func (s *QueryData) rangeQuery(ctx context.Context, c *client.Client, q *models.Query, enablePrometheusDataplaneFlag bool) backend.DataResponse {
+	if len(s.remoteReadResponseTypes) > 0 {
+		if matchers, ok := remoteReadMatchers(q.Expr); ok && s.supported(ctx).Protobuf {
+			return s.remoteReadQuery(ctx, c, q, matchers, enablePrometheusDataplaneFlag)
+		}
+	}
+
+	// Ranges over the point limit of Prometheus are queried in parts
+	if q.StepAdjustment != nil && q.StepAdjustment.Chunks > 1 {
+		return s.chunkedRangeQuery(ctx, c, q, enablePrometheusDataplaneFlag)
+	}
+
 	res, err := c.QueryRange(ctx, q)
 	if err != nil {
 		return backend.DataResponse{
@@ -183,6 +636,30 @@ func (s *QueryData) rangeQuery(ctx context.Context, c *client.Client, q *models.
 	return s.parseResponse(ctx, q, res, enablePrometheusDataplaneFlag)
 }
 
+func (s *QueryData) chunkedRangeQuery(ctx context.Context, c *client.Client, q *models.Query, enablePrometheusDataplaneFlag bool) backend.DataResponse {
+	var r backend.DataResponse
+	for i, part := range rangeQueryParts(q, q.StepAdjustment.Chunks) {
+		res, err := c.QueryRange(ctx, part)
+		if err != nil {
+			return backend.DataResponse{
+				Error:  err,
+				Status: backend.StatusBadGateway,
+			}
+		}
+
+		partResponse := s.decodeResponse(ctx, part, res, enablePrometheusDataplaneFlag)
+		if partResponse.Error != nil || i == 0 {
+			r = partResponse
+		} else {
+			r.Frames = appendFrameRows(r.Frames, partResponse.Frames)
+		}
+		if r.Error != nil {
+			return r
+		}
+	}
+	return s.processExemplars(ctx, q, r)
+}
+
 func (s *QueryData) instantQuery(ctx context.Context, c *client.Client, q *models.Query, enablePrometheusDataplaneFlag bool) backend.DataResponse {
 	res, err := c.QueryInstant(ctx, q)
 	if err != nil {
@@ -210,18 +687,26 @@ func (s *QueryData) instantQuery(ctx context.Context, c *client.Client, q *model
 }
 
 func (s *QueryData) exemplarQuery(ctx context.Context, c *client.Client, q *models.Query, enablePrometheusDataplaneFlag bool) backend.DataResponse {
-	res, err := c.QueryExemplars(ctx, q)
-	if err != nil {
-		return backend.DataResponse{
-			Error: err,
+	var frames data.Frames
+	for i, part := range exemplarQueryParts(q, maxExemplarQueryRange) {
+		res, err := c.QueryExemplars(ctx, part)
+		if err != nil {
+			return backend.DataResponse{
+				Error: err,
+			}
 		}
-	}
 
-	defer func() {
-		err := res.Body.Close()
-		if err != nil {
-			s.log.Warn("Failed to close response body", "error", err)
+		r := s.decodeResponse(ctx, part, res, enablePrometheusDataplaneFlag)
+		if r.Error != nil {
+			return r
 		}
-	}()
-	return s.parseResponse(ctx, q, res, enablePrometheusDataplaneFlag)
+		if i > 0 {
+			// The exemplars at the start of the part were returned with the previous one
+			if r.Frames, err = exemplarsAfter(r.Frames, part.QueriedStart); err != nil {
+				return backend.DataResponse{Error: err}
+			}
+		}
+		frames = append(frames, r.Frames...)
+	}
+	return s.processExemplars(ctx, q, backend.DataResponse{Frames: frames})
 }
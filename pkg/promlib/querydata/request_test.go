@@ -8,6 +8,8 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -355,6 +357,807 @@ func TestPrometheus_parseTimeSeriesResponse(t *testing.T) {
 	})
 }
 
+func TestPrometheus_metadataQuery(t *testing.T) {
+	tctx, err := setup()
+	require.NoError(t, err)
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{
+			Expr: "http_requests_total",
+		},
+	})
+	require.NoError(t, err)
+	query := backend.DataQuery{
+		RefID:     "A",
+		QueryType: string(models.QueryTypeMetadata),
+		JSON:      b,
+	}
+
+	res, err := execute(tctx, query, map[string][]map[string]string{
+		"http_requests_total": {{"type": "counter", "help": "Number of HTTP requests", "unit": ""}},
+		"build_info":          {{"type": "gauge", "help": "Build information", "unit": ""}},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, http.MethodGet, tctx.httpProvider.req.Method)
+	require.Equal(t, "/api/v1/metadata", tctx.httpProvider.req.URL.Path)
+	require.Equal(t, "http_requests_total", tctx.httpProvider.req.URL.Query().Get("metric"))
+
+	require.Len(t, res, 1)
+	require.Equal(t, "A", res[0].RefID)
+	require.Equal(t, 2, res[0].Rows())
+	require.Equal(t, "build_info", res[0].Fields[0].At(0))
+	require.Equal(t, "counter", res[0].Fields[1].At(1))
+	require.Equal(t, "Number of HTTP requests", res[0].Fields[2].At(1))
+}
+
+func TestPrometheus_rulesQuery(t *testing.T) {
+	tctx, err := setup()
+	require.NoError(t, err)
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{
+			CustomQueryParameters: map[string]string{"type": "alert"},
+		},
+	})
+	require.NoError(t, err)
+	query := backend.DataQuery{
+		RefID:     "A",
+		QueryType: string(models.QueryTypeRules),
+		JSON:      b,
+	}
+
+	res, err := execute(tctx, query, json.RawMessage(`{"groups": [{
+		"name": "api",
+		"file": "/etc/prometheus/rules.yaml",
+		"interval": 60,
+		"rules": [{
+			"type": "alerting",
+			"name": "HighLatency",
+			"query": "job:request_latency_seconds:mean5m > 0.5",
+			"duration": 600,
+			"labels": {"severity": "page"},
+			"annotations": {"summary": "High request latency"},
+			"state": "firing",
+			"health": "ok",
+			"lastError": "",
+			"evaluationTime": 0.002,
+			"lastEvaluation": "2024-05-01T10:00:00.5Z"
+		}, {
+			"type": "recording",
+			"name": "job:request_latency_seconds:mean5m",
+			"query": "avg by (job) (rate(request_latency_seconds_sum[5m]))",
+			"health": "err",
+			"lastError": "many-to-many matching not allowed",
+			"evaluationTime": 0.001,
+			"lastEvaluation": "2024-05-01T10:00:00Z"
+		}]
+	}]}`))
+	require.NoError(t, err)
+
+	require.Equal(t, "/api/v1/rules", tctx.httpProvider.req.URL.Path)
+	require.Equal(t, "alert", tctx.httpProvider.req.URL.Query().Get("type"))
+
+	require.Len(t, res, 1)
+	frame := res[0]
+	require.Equal(t, 2, frame.Rows())
+
+	row := map[string]any{}
+	for _, field := range frame.Fields {
+		row[field.Name] = field.At(0)
+	}
+	require.Equal(t, "api", row["group"])
+	require.Equal(t, "HighLatency", row["name"])
+	require.Equal(t, "alerting", row["type"])
+	require.Equal(t, "severity=page", row["labels"])
+	require.Equal(t, "summary=High request latency", row["annotations"])
+	require.Equal(t, 600.0, row["duration"])
+	require.Equal(t, "firing", row["state"])
+	require.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 5e8, time.UTC), row["lastEvaluation"])
+	require.Equal(t, 60.0, row["interval"])
+
+	health, _ := frame.FieldByName("health")
+	require.Equal(t, "err", health.At(1))
+	lastError, _ := frame.FieldByName("lastError")
+	require.Equal(t, "many-to-many matching not allowed", lastError.At(1))
+}
+
+func TestPrometheus_alertsQuery(t *testing.T) {
+	tctx, err := setup()
+	require.NoError(t, err)
+
+	query := backend.DataQuery{
+		RefID:     "A",
+		QueryType: string(models.QueryTypeAlerts),
+		JSON:      []byte(`{}`),
+	}
+
+	res, err := execute(tctx, query, json.RawMessage(`{"alerts": [{
+		"labels": {"alertname": "HighLatency", "severity": "page"},
+		"annotations": {"summary": "High request latency"},
+		"state": "firing",
+		"activeAt": "2024-05-01T10:00:00Z",
+		"value": "1e+00"
+	}, {
+		"labels": {"alertname": "InstanceDown", "instance": "host:9100"},
+		"annotations": {},
+		"state": "pending",
+		"activeAt": "2024-05-01T10:05:00Z",
+		"value": "0e+00"
+	}]}`))
+	require.NoError(t, err)
+
+	require.Equal(t, "/api/v1/alerts", tctx.httpProvider.req.URL.Path)
+
+	require.Len(t, res, 1)
+	frame := res[0]
+	require.Equal(t, 2, frame.Rows())
+
+	names := []string{}
+	for _, field := range frame.Fields {
+		names = append(names, field.Name)
+	}
+	require.Equal(t, []string{"activeAt", "state", "value", "alertname", "instance", "severity", "annotation_summary"}, names)
+
+	require.Equal(t, []any{time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC), "pending", "InstanceDown", "host:9100", "", ""}, []any{
+		frame.Fields[0].At(1), frame.Fields[1].At(1), frame.Fields[3].At(1), frame.Fields[4].At(1), frame.Fields[5].At(1), frame.Fields[6].At(1),
+	})
+	value, ok := frame.Fields[2].ConcreteAt(0)
+	require.True(t, ok)
+	require.Equal(t, 1.0, value)
+}
+
+func TestPrometheus_targetsQuery(t *testing.T) {
+	tctx, err := setup()
+	require.NoError(t, err)
+
+	query := backend.DataQuery{
+		RefID:     "A",
+		QueryType: string(models.QueryTypeTargets),
+		JSON:      []byte(`{}`),
+	}
+
+	res, err := execute(tctx, query, json.RawMessage(`{"activeTargets": [{
+		"discoveredLabels": {"__address__": "127.0.0.1:9090"},
+		"labels": {"instance": "127.0.0.1:9090", "job": "prometheus"},
+		"scrapePool": "prometheus",
+		"scrapeUrl": "http://127.0.0.1:9090/metrics",
+		"globalUrl": "http://example-prometheus:9090/metrics",
+		"lastError": "",
+		"lastScrape": "2024-05-01T10:00:00Z",
+		"lastScrapeDuration": 0.050688943,
+		"health": "up",
+		"scrapeInterval": "1m",
+		"scrapeTimeout": "10s"
+	}], "droppedTargets": []}`))
+	require.NoError(t, err)
+
+	require.Equal(t, "/api/v1/targets", tctx.httpProvider.req.URL.Path)
+	require.Equal(t, "active", tctx.httpProvider.req.URL.Query().Get("state"))
+
+	require.Len(t, res, 1)
+	frame := res[0]
+	require.Equal(t, 1, frame.Rows())
+
+	row := map[string]any{}
+	for _, field := range frame.Fields {
+		row[field.Name] = field.At(0)
+	}
+	require.Equal(t, "prometheus", row["scrapePool"])
+	require.Equal(t, "instance=127.0.0.1:9090, job=prometheus", row["labels"])
+	require.Equal(t, "up", row["health"])
+	require.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), row["lastScrape"])
+	require.Equal(t, 0.050688943, row["lastScrapeDuration"])
+	require.Equal(t, "1m", row["scrapeInterval"])
+}
+
+func TestPrometheus_tsdbStatusQuery(t *testing.T) {
+	tctx, err := setup()
+	require.NoError(t, err)
+
+	query := backend.DataQuery{
+		RefID:     "A",
+		QueryType: string(models.QueryTypeTSDBStatus),
+		JSON:      []byte(`{"customQueryParameters": {"limit": "2"}}`),
+	}
+
+	res, err := execute(tctx, query, json.RawMessage(`{
+		"headStats": {"numSeries": 508, "numLabelPairs": 1234, "chunkCount": 937, "minTime": 1591516800000, "maxTime": 1598896800143},
+		"seriesCountByMetricName": [{"name": "net_conntrack_dialer_conn_failed_total", "value": 20}, {"name": "prometheus_http_request_duration_seconds_bucket", "value": 20}],
+		"labelValueCountByLabelName": [{"name": "__name__", "value": 211}],
+		"memoryInBytesByLabelName": [{"name": "__name__", "value": 8266}],
+		"seriesCountByLabelValuePair": [{"name": "job=prometheus", "value": 425}]
+	}`))
+	require.NoError(t, err)
+
+	require.Equal(t, "/api/v1/status/tsdb", tctx.httpProvider.req.URL.Path)
+	require.Equal(t, "2", tctx.httpProvider.req.URL.Query().Get("limit"))
+
+	require.Len(t, res, 5)
+	require.Equal(t, "headStats", res[0].Name)
+	require.Equal(t, uint64(508), res[0].Fields[0].At(0))
+	require.Equal(t, time.UnixMilli(1591516800000).UTC(), res[0].Fields[3].At(0))
+
+	require.Equal(t, "seriesCountByMetricName", res[1].Name)
+	require.Equal(t, 2, res[1].Rows())
+	require.Equal(t, "net_conntrack_dialer_conn_failed_total", res[1].Fields[0].At(0))
+	require.Equal(t, uint64(20), res[1].Fields[1].At(0))
+
+	require.Equal(t, "memoryInBytesByLabelName", res[3].Name)
+	require.Equal(t, "bytes", res[3].Fields[1].Config.Unit)
+
+	for _, frame := range res {
+		require.Equal(t, "A", frame.RefID)
+	}
+}
+
+func TestPrometheus_queryTimeout(t *testing.T) {
+	tctx, err := setup()
+	require.NoError(t, err)
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{
+			Expr:    "up",
+			Range:   true,
+			Timeout: "1h",
+		},
+	})
+	require.NoError(t, err)
+	query := backend.DataQuery{
+		RefID:     "A",
+		JSON:      b,
+		TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+	}
+
+	_, err = execute(tctx, query, queryResult{Type: p.ValMatrix, Result: p.Matrix{}})
+	require.NoError(t, err)
+
+	// The timeout is bounded by the maximum query timeout of the data source
+	body, err := io.ReadAll(tctx.httpProvider.req.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "timeout=600")
+	deadline, ok := tctx.httpProvider.req.Context().Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(10*time.Minute), deadline, time.Minute)
+}
+
+func TestPrometheus_instantAndRangeQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/query":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up"},"value":[60,"1"]}]}}`))
+		case "/api/v1/query_range":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[0,"1"],[60,"1"]]}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{
+			Expr:    "up",
+			Instant: true,
+			Range:   true,
+		},
+	})
+	require.NoError(t, err)
+
+	res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      b,
+			Interval:  time.Minute,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+		}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, res.Responses["A"].Error)
+
+	frames := res.Responses["A"].Frames
+	require.Len(t, frames, 2)
+	require.Equal(t, map[string]string{"resultType": "vector", "queryType": "instant"}, frames[0].Meta.Custom)
+	require.Equal(t, map[string]string{"resultType": "matrix", "queryType": "range"}, frames[1].Meta.Custom)
+}
+
+func TestPrometheus_cancelledQuery(t *testing.T) {
+	received := make(chan struct{})
+	aborted := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices that the connection is closed once the body is read
+		_, _ = io.Copy(io.Discard, r.Body)
+		close(received)
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer srv.Close()
+
+	queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+		UID:      "prometheus",
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up", Range: true},
+	})
+	require.NoError(t, err)
+	query := backend.DataQuery{
+		JSON:      b,
+		Interval:  time.Minute,
+		TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+	}
+	first, second := query, query
+	first.RefID, second.RefID = "A", "B"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+	res, err := queryData.Execute(ctx, &backend.QueryDataRequest{Queries: []backend.DataQuery{first, second}})
+	require.NoError(t, err)
+
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request of the cancelled query was not aborted")
+	}
+	for _, refID := range []string{"A", "B"} {
+		require.ErrorIs(t, res.Responses[refID].Error, context.Canceled)
+		require.ErrorContains(t, res.Responses[refID].Error, "query cancelled")
+		require.Equal(t, backend.ErrorSourceDownstream, res.Responses[refID].ErrorSource)
+	}
+}
+
+type auditSink []querydata.QueryAuditEntry
+
+func (s *auditSink) AuditQuery(_ context.Context, entry querydata.QueryAuditEntry) {
+	*s = append(*s, entry)
+}
+
+func TestPrometheus_queryAuditLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	newQueryData := func(jsonData string) (*querydata.QueryData, *auditSink) {
+		queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+			UID:      "prometheus",
+			URL:      srv.URL,
+			JSONData: json.RawMessage(jsonData),
+		}, log.New())
+		require.NoError(t, err)
+		sink := &auditSink{}
+		queryData.SetAuditSink(sink)
+		return queryData, sink
+	}
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up", Range: true},
+	})
+	require.NoError(t, err)
+	req := &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{User: &backend.User{Login: "alice"}},
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      b,
+			Interval:  time.Minute,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(600, 0)},
+		}},
+	}
+	req.SetHTTPHeader("X-Dashboard-Uid", "dashboard")
+	req.SetHTTPHeader("X-Panel-Id", "2")
+
+	queryData, sink := newQueryData(`{"queryAuditLog": true}`)
+	_, err = queryData.Execute(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, *sink, 1)
+	entry := (*sink)[0]
+	require.Equal(t, "prometheus", entry.Datasource)
+	require.Equal(t, "alice", entry.User)
+	require.Equal(t, "dashboard", entry.Dashboard)
+	require.Equal(t, "2", entry.Panel)
+	require.Equal(t, "A", entry.RefID)
+	require.Equal(t, "up", entry.Expr)
+	require.Equal(t, time.Unix(0, 0).UTC(), entry.Start.UTC())
+	require.Equal(t, time.Unix(600, 0).UTC(), entry.End.UTC())
+	require.True(t, entry.Range)
+	require.False(t, entry.Cached)
+	require.NoError(t, entry.Error)
+	require.Positive(t, entry.Duration)
+
+	t.Run("queries are not audited by default", func(t *testing.T) {
+		queryData, sink := newQueryData(`{}`)
+		_, err = queryData.Execute(context.Background(), req)
+		require.NoError(t, err)
+		require.Empty(t, *sink)
+	})
+}
+
+func TestPrometheus_enforcedLabelMatchers(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		queries = append(queries, r.Form.Get("query"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{"enforcedLabelMatchers": [{"roles": ["Viewer"], "matchers": "{namespace=\"team-a\"}"}]}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	execute := func(user *backend.User, queryType string) backend.DataResponse {
+		res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{User: user},
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				QueryType: queryType,
+				JSON:      []byte(`{"expr":"sum(up)","range":true}`),
+				Interval:  time.Minute,
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(600, 0)},
+			}},
+		})
+		require.NoError(t, err)
+		return res.Responses["A"]
+	}
+
+	viewer := &backend.User{Login: "alice", Role: "Viewer"}
+	require.NoError(t, execute(viewer, "").Error)
+	require.Equal(t, []string{`sum(up{namespace="team-a"})`}, queries)
+
+	// The results of API queries cannot be restricted
+	r := execute(viewer, string(models.QueryTypeRules))
+	require.Error(t, r.Error)
+	require.Equal(t, backend.StatusForbidden, r.Status)
+
+	// The queries of unknown users are denied, as the rules depend on the user
+	r = execute(nil, "")
+	require.ErrorIs(t, r.Error, models.ErrUnknownUser)
+	require.Equal(t, backend.StatusForbidden, r.Status)
+	require.Len(t, queries, 1)
+
+	require.NoError(t, execute(&backend.User{Login: "bob", Role: "Editor"}, "").Error)
+	require.Equal(t, "sum(up)", queries[1])
+}
+
+func TestPrometheus_slowQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[0,"1"],[60,"1"]]}]}}`))
+	}))
+	defer srv.Close()
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up", Range: true},
+	})
+	require.NoError(t, err)
+	execute := func(jsonData string) data.Frames {
+		queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+			URL:      srv.URL,
+			JSONData: json.RawMessage(jsonData),
+		}, log.New())
+		require.NoError(t, err)
+		res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      b,
+				Interval:  time.Minute,
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+			}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		return res.Responses["A"].Frames
+	}
+
+	frames := execute(`{"slowQueryThreshold": "10ms"}`)
+	require.Len(t, frames[0].Meta.Notices, 1)
+	require.Equal(t, data.NoticeSeverityWarning, frames[0].Meta.Notices[0].Severity)
+	require.Contains(t, frames[0].Meta.Notices[0].Text, "longer than the 10ms slow query threshold")
+
+	require.Empty(t, execute(`{"slowQueryThreshold": "1m"}`)[0].Meta.Notices)
+	require.Empty(t, execute(`{}`)[0].Meta.Notices)
+
+	_, err = querydata.New(srv.Client(), backend.DataSourceInstanceSettings{URL: srv.URL, JSONData: json.RawMessage(`{"slowQueryThreshold": "slow"}`)}, log.New())
+	require.ErrorContains(t, err, "invalid slowQueryThreshold")
+}
+
+func TestPrometheus_alertingInstantQueryAlignment(t *testing.T) {
+	var evaluatedAt string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evaluatedAt = r.FormValue("time")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{"alertingInstantQueryAlignment": "10s"}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up", Instant: true},
+	})
+	require.NoError(t, err)
+	execute := func(headers map[string]string) string {
+		_, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+			Headers: headers,
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      b,
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.UnixMilli(127_345)},
+			}},
+		})
+		require.NoError(t, err)
+		return evaluatedAt
+	}
+
+	require.Equal(t, "120", execute(map[string]string{"FromAlert": "true"}))
+	// Only the queries of alert rules are aligned
+	require.Equal(t, "127.345", execute(nil))
+
+	_, err = querydata.New(srv.Client(), backend.DataSourceInstanceSettings{URL: srv.URL, JSONData: json.RawMessage(`{"alertingInstantQueryAlignment": "tick"}`)}, log.New())
+	require.ErrorContains(t, err, "invalid alertingInstantQueryAlignment")
+}
+
+func TestPrometheus_queryTimings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[0,"1"],[60,"1"]]}]}}`))
+	}))
+	defer srv.Close()
+
+	opts, err := client.CreateTransportOptions(context.Background(), backend.DataSourceInstanceSettings{JSONData: json.RawMessage(`{}`)}, log.New())
+	require.NoError(t, err)
+	httpClient, err := httpclient.New(*opts)
+	require.NoError(t, err)
+	queryData, err := querydata.New(httpClient, backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	execute := func(stats bool) *data.Frame {
+		b, err := json.Marshal(&models.QueryModel{
+			PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up", Range: true, Stats: stats},
+		})
+		require.NoError(t, err)
+		res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      b,
+				Interval:  time.Minute,
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+			}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		return res.Responses["A"].Frames[0]
+	}
+
+	custom, ok := execute(true).Meta.Custom.(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, "matrix", custom["resultType"])
+	timings, ok := custom["timings"].(map[string]float64)
+	require.True(t, ok)
+	for _, phase := range []string{"queue", "connect", "firstByte", "decode", "conversion"} {
+		require.Contains(t, timings, phase)
+	}
+	require.GreaterOrEqual(t, timings["firstByte"], 10.0)
+
+	// Queries are only timed with their statistics
+	require.Equal(t, map[string]string{"resultType": "matrix"}, execute(false).Meta.Custom)
+}
+
+func TestPrometheus_unsupportedExemplars(t *testing.T) {
+	exemplarQueries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/query_range":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		case "/api/v1/query_exemplars":
+			exemplarQueries++
+			_, _ = w.Write([]byte(`{"status":"success","data":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up", Range: true, Exemplar: true},
+	})
+	require.NoError(t, err)
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      b,
+			Interval:  time.Minute,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+		}},
+	}
+
+	_, err = queryData.Execute(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 1, exemplarQueries)
+
+	queryData.SetCapabilities(func(context.Context) models.Capabilities {
+		return models.Capabilities{NativeHistograms: true, Protobuf: true}
+	})
+	_, err = queryData.Execute(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 1, exemplarQueries)
+}
+
+func TestPrometheus_maxGetURLLength(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up{job=\"" + strings.Repeat("a", 10000) + "\"}", Range: true},
+	})
+	require.NoError(t, err)
+	execute := func(jsonData string) string {
+		methods = nil
+		queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+			URL:      srv.URL,
+			JSONData: json.RawMessage(jsonData),
+		}, log.New())
+		require.NoError(t, err)
+		_, err = queryData.Execute(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      b,
+				Interval:  time.Minute,
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(600, 0)},
+			}},
+		})
+		require.NoError(t, err)
+		require.Len(t, methods, 1)
+		return methods[0]
+	}
+
+	// The URL is longer than the default limit
+	require.Equal(t, http.MethodPost, execute(`{"httpMethod": "GET"}`))
+	require.Equal(t, http.MethodGet, execute(`{"httpMethod": "GET", "maxGetUrlLength": 20000}`))
+	// 0 disables the switch
+	require.Equal(t, http.MethodGet, execute(`{"httpMethod": "GET", "maxGetUrlLength": 0}`))
+}
+
+func TestPrometheus_thanosOptions(t *testing.T) {
+	var dedup []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		dedup = r.Form["dedup"]
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	disabled := false
+	b, err := json.Marshal(&models.QueryModel{
+		PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "up", Range: true, Dedup: &disabled},
+	})
+	require.NoError(t, err)
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      b,
+			Interval:  time.Minute,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+		}},
+	}
+
+	queryData.SetCapabilities(func(context.Context) models.Capabilities { return models.AllCapabilities })
+	_, err = queryData.Execute(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"false"}, dedup)
+
+	// Mimir does not understand the Thanos options
+	queryData.SetCapabilities(func(context.Context) models.Capabilities { return models.Capabilities{Exemplars: true} })
+	_, err = queryData.Execute(context.Background(), req)
+	require.NoError(t, err)
+	require.Empty(t, dedup)
+
+	// Without detection, they are sent unless the configured type is known not to understand them
+	for prometheusType, expected := range map[string][]string{"Prometheus": {"false"}, "Thanos": {"false"}, "Mimir": nil, "Cortex": nil} {
+		queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+			URL:      srv.URL,
+			JSONData: json.RawMessage(`{"prometheusType":"` + prometheusType + `"}`),
+		}, log.New())
+		require.NoError(t, err)
+		_, err = queryData.Execute(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, expected, dedup, prometheusType)
+	}
+}
+
+func TestPrometheus_queryShards(t *testing.T) {
+	var sharding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sharding = r.Header.Get(client.ShardingControlHeader)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      []byte(`{"expr":"up","range":true}`),
+			Interval:  time.Minute,
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+		}},
+	}
+
+	// Without detection, the number of shards is only sent to Mimir
+	for prometheusType, expected := range map[string]string{"Mimir": "16", "Cortex": "", "Prometheus": "", "Thanos": ""} {
+		queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+			URL:      srv.URL,
+			JSONData: json.RawMessage(`{"queryShards":16,"prometheusType":"` + prometheusType + `"}`),
+		}, log.New())
+		require.NoError(t, err)
+		_, err = queryData.Execute(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, expected, sharding, prometheusType)
+	}
+
+	// The detected flavor replaces the configured one
+	queryData, err := querydata.New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{"queryShards":16,"prometheusType":"Mimir"}`),
+	}, log.New())
+	require.NoError(t, err)
+	queryData.SetCapabilities(func(context.Context) models.Capabilities { return models.AllCapabilities })
+	_, err = queryData.Execute(context.Background(), req)
+	require.NoError(t, err)
+	require.Empty(t, sharding)
+	queryData.SetCapabilities(func(context.Context) models.Capabilities { return models.Capabilities{QuerySharding: true} })
+	_, err = queryData.Execute(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, "16", sharding)
+
+	_, err = querydata.New(srv.Client(), backend.DataSourceInstanceSettings{URL: srv.URL, JSONData: json.RawMessage(`{"queryShards":"many"}`)}, log.New())
+	require.Error(t, err)
+}
+
 type queryResult struct {
 	Type   p.ValueType `json:"resultType"`
 	Result any         `json:"result"`
@@ -3,8 +3,12 @@ package querydata
 import (
 	"context"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,7 +22,23 @@ import (
 	"github.com/grafana/grafana/pkg/promlib/utils"
 )
 
+// maxPreallocatedPoints bounds the samples the fields of a series are allocated for, the fields of the
+// series with more samples grow. Series are often sparse, preallocating each one for every step of long
+// ranges would take much more memory than their samples.
+const maxPreallocatedPoints = 1024
+
+const serverTimingHeader = "Server-Timing"
+
 func (s *QueryData) parseResponse(ctx context.Context, q *models.Query, res *http.Response, enablePrometheusDataplaneFlag bool) backend.DataResponse {
+	r := s.decodeResponse(ctx, q, res, enablePrometheusDataplaneFlag)
+	if r.Error == nil {
+		r = s.processExemplars(ctx, q, r)
+	}
+	return r
+}
+
+// decodeResponse decodes the frames of a response, with the exemplars of each series in their own frame
+func (s *QueryData) decodeResponse(ctx context.Context, q *models.Query, res *http.Response, enablePrometheusDataplaneFlag bool) backend.DataResponse {
 	defer func() {
 		if err := res.Body.Close(); err != nil {
 			s.log.FromContext(ctx).Error("Failed to close response body", "err", err)
@@ -28,10 +48,25 @@ func (s *QueryData) parseResponse(ctx context.Context, q *models.Query, res *htt
 	ctx, endSpan := utils.StartTrace(ctx, s.tracer, "datasource.prometheus.parseResponse")
 	defer endSpan()
 
-	iter := jsoniter.Parse(jsoniter.ConfigDefault, res.Body, 1024)
+	// The body is decoded while it is read, only the frames are kept in memory
+	body := &limitedReader{r: res.Body, max: s.limits.maxBytes}
+	iter := jsoniter.Parse(jsoniter.ConfigDefault, body, 64*1024)
+	start := time.Now()
 	r := converter.ReadPrometheusStyleResult(iter, converter.Options{
-		Dataplane: enablePrometheusDataplaneFlag,
+		Dataplane:       enablePrometheusDataplaneFlag,
+		PointsPerSeries: pointsPerSeries(q),
+		MaxSeries:       s.limits.maxSeries,
+		MaxSamples:      s.limits.maxSamples,
+		RawValues:       q.RawValues,
+
+		HistogramBuckets:    q.HistogramBuckets,
+		HistogramLogBuckets: q.HistogramBucketLayout == models.HistogramBucketLayoutLog,
 	})
+	utils.QueryTimingsFromContext(ctx).Add(utils.TimingPhaseDecode, time.Since(start))
+	if err := body.limitError(r.Error); err != nil {
+		// Nothing decoded so far is returned, a partial result would be misleading
+		r = backend.DataResponse{Error: err}
+	}
 	r.Status = backend.Status(res.StatusCode)
 
 	// Add frame to attach metadata
@@ -39,16 +74,11 @@ func (s *QueryData) parseResponse(ctx context.Context, q *models.Query, res *htt
 		r.Frames = append(r.Frames, data.NewFrame(""))
 	}
 
-	// The ExecutedQueryString can be viewed in QueryInspector in UI
-	for i, frame := range r.Frames {
-		addMetadataToMultiFrame(q, frame, enablePrometheusDataplaneFlag)
-		if i == 0 {
-			frame.Meta.ExecutedQueryString = executedQueryString(q)
-		}
-	}
+	addMetadataToFrames(q, res.Request, r.Frames, enablePrometheusDataplaneFlag)
+	addServerTiming(r.Frames, res.Header)
 
-	if r.Error == nil {
-		r = s.processExemplars(ctx, q, r)
+	if q.Stats && r.Error == nil {
+		addStatsNotice(r.Frames)
 	}
 
 	return r
@@ -106,6 +136,200 @@ func (s *QueryData) processExemplars(ctx context.Context, q *models.Query, dr ba
 	}
 }
 
+// pointsPerSeries returns the number of samples to allocate the series of the result of q for, the number
+// of steps of a range query up to maxPreallocatedPoints
+func pointsPerSeries(q *models.Query) int {
+	if !q.RangeQuery || q.Step <= 0 {
+		return 0
+	}
+	tr := q.TimeRange()
+	points := int(tr.End.Sub(tr.Start)/tr.Step) + 1
+	return min(points, maxPreallocatedPoints)
+}
+
+func addMetadataToFrames(q *models.Query, req *http.Request, frames data.Frames, enableDataplane bool) {
+	// The ExecutedQueryString can be viewed in QueryInspector in UI
+	for i, frame := range frames {
+		addMetadataToMultiFrame(q, frame, enableDataplane)
+		if i == 0 {
+			frame.Meta.ExecutedQueryString = executedQueryString(q, req)
+			// How the range was adjusted to the point limit of Prometheus
+			if q.StepAdjustment != nil && q.RangeQuery {
+				setCustomMetadata(frame, "stepAdjustment", q.StepAdjustment)
+			}
+			// The values the interval variables of the expression were replaced with
+			if q.IntervalContext != nil {
+				setCustomMetadata(frame, "intervalContext", q.IntervalContext)
+			}
+		}
+	}
+
+	if q.LegendFormat == legendFormatAuto {
+		addAutoDisplayNames(frames)
+	}
+}
+
+// addAutoDisplayNames names the series of a query with the __auto legend the way Grafana names fields
+// from their labels: by the value of their label when all the series have the same single label,
+// by all their labels otherwise. Series without labels are left as they are.
+func addAutoDisplayNames(frames data.Frames) {
+	var fields []*data.Field
+	for _, frame := range frames {
+		if len(frame.Fields) < 2 || len(frame.Fields[1].Labels) == 0 {
+			continue
+		}
+		fields = append(fields, frame.Fields[1])
+	}
+
+	singleLabel := singleLabelName(fields)
+	for _, field := range fields {
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		if singleLabel != "" {
+			field.Config.DisplayNameFromDS = field.Labels[singleLabel]
+		} else {
+			field.Config.DisplayNameFromDS = formatLabels(field.Labels)
+		}
+	}
+}
+
+// singleLabelName returns the name of the label of fields when they all have this one label only
+func singleLabelName(fields []*data.Field) string {
+	name := ""
+	for _, field := range fields {
+		if len(field.Labels) != 1 {
+			return ""
+		}
+		for k := range field.Labels {
+			if name != "" && k != name {
+				return ""
+			}
+			name = k
+		}
+	}
+	return name
+}
+
+// formatLabels formats labels as metric{name="value", ...}, sorted by name, like Prometheus names series.
+// Only the metric name is returned when there are no other labels.
+func formatLabels(labels data.Labels) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	metric := labels["__name__"]
+	if metric != "" && len(keys) == 0 {
+		return metric
+	}
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, labels[k]))
+	}
+	return metric + "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// tagQueryType sets the queryType custom meta of frames, so the results of a query that
+// is both instant and range can be told apart
+func tagQueryType(frames data.Frames, queryType models.TimeSeriesQueryType) {
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		switch custom := frame.Meta.Custom.(type) {
+		case map[string]string:
+			custom["queryType"] = string(queryType)
+		case map[string]any:
+			custom["queryType"] = string(queryType)
+		case nil:
+			frame.Meta.Custom = map[string]string{"queryType": string(queryType)}
+		}
+	}
+}
+
+// addTimings sets the durations of the phases of a query, in milliseconds, in the custom meta of its first frame
+func addTimings(frames data.Frames, timings *utils.QueryTimings) {
+	if len(frames) == 0 {
+		return
+	}
+	setCustomMetadata(frames[0], "timings", timings.Milliseconds())
+}
+
+// addServerTiming adds the metrics of the Server-Timing header of a response to the custom metadata of
+// its first frame. Mimir reports the statistics of sharded queries there, when its query-frontend has
+// query statistics enabled.
+func addServerTiming(frames data.Frames, header http.Header) {
+	if len(frames) == 0 {
+		return
+	}
+	metrics := parseServerTiming(header.Values(serverTimingHeader))
+	if len(metrics) == 0 {
+		return
+	}
+	setCustomMetadata(frames[0], "serverTiming", metrics)
+}
+
+// parseServerTiming returns the values of the metrics of Server-Timing header values, like
+// "querier_wall_time;dur=12.5, bytes_processed;val=2048": their duration in milliseconds, or their
+// value when they do not have one. Metrics without either are skipped.
+func parseServerTiming(values []string) map[string]float64 {
+	var metrics map[string]float64
+	for _, value := range values {
+		for _, metric := range strings.Split(value, ",") {
+			params := strings.Split(metric, ";")
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			numbers := map[string]float64{}
+			for _, param := range params[1:] {
+				key, raw, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if f, err := strconv.ParseFloat(strings.Trim(raw, `"`), 64); err == nil {
+					numbers[key] = f
+				}
+			}
+			v, ok := numbers["dur"]
+			if !ok {
+				if v, ok = numbers["val"]; !ok {
+					continue
+				}
+			}
+			if metrics == nil {
+				metrics = map[string]float64{}
+			}
+			metrics[name] = v
+		}
+	}
+	return metrics
+}
+
+// setCustomMetadata sets key in the custom metadata of frame, which keeps the values already set
+func setCustomMetadata(frame *data.Frame, key string, value any) {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	custom := map[string]any{}
+	switch c := frame.Meta.Custom.(type) {
+	case map[string]string:
+		for k, v := range c {
+			custom[k] = v
+		}
+	case map[string]any:
+		for k, v := range c {
+			custom[k] = v
+		}
+	case nil:
+	default:
+		return
+	}
+	custom[key] = value
+	frame.Meta.Custom = custom
+}
+
 func addMetadataToMultiFrame(q *models.Query, frame *data.Frame, enableDataplane bool) {
 	if frame.Meta == nil {
 		frame.Meta = &data.FrameMeta{}
@@ -157,8 +381,85 @@ func metricNameFromLabels(f *data.Field) string {
 	}
 }
 
-func executedQueryString(q *models.Query) string {
-	return "Expr: " + q.Expr + "\n" + "Step: " + q.Step.String()
+// addStatsNotice summarizes the query statistics returned by Prometheus in a notice on the frame they are attached to
+func addStatsNotice(frames data.Frames) {
+	for _, frame := range frames {
+		custom, ok := frame.Meta.Custom.(map[string]any)
+		if !ok {
+			continue
+		}
+		stats, ok := custom["stats"].(map[string]any)
+		if !ok {
+			continue
+		}
+		samples, _ := stats["samples"].(map[string]any)
+		timings, _ := stats["timings"].(map[string]any)
+
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text: fmt.Sprintf("Query statistics: %s samples scanned, %s peak samples, evaluated in %ss",
+				statValue(samples, "totalQueryableSamples"),
+				statValue(samples, "peakSamples"),
+				statValue(timings, "evalTotalTime"),
+			),
+		})
+		return
+	}
+}
+
+func statValue(stats map[string]any, key string) string {
+	v, ok := stats[key].(float64)
+	if !ok {
+		return "unknown"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// executedQueryString describes the query sent to the data source, so it can be reproduced.
+// When the request is known, it adds the time range and the parameters it was sent with.
+func executedQueryString(q *models.Query, req *http.Request) string {
+	s := "Expr: " + q.Expr + "\n" + "Step: " + q.Step.String()
+	if req == nil || req.URL == nil {
+		return s
+	}
+
+	params := requestParameters(req)
+	for _, p := range []struct{ name, param string }{{"Start", "start"}, {"End", "end"}, {"Time", "time"}} {
+		if t, err := strconv.ParseFloat(params.Get(p.param), 64); err == nil {
+			s += "\n" + p.name + ": " + time.UnixMilli(int64(math.Round(t*1000))).UTC().Format(time.RFC3339Nano)
+		}
+	}
+
+	s += "\n" + "Request: " + req.Method + " " + req.URL.Path
+	if len(params) > 0 {
+		s += "?" + params.Encode()
+	}
+	return s
+}
+
+// requestParameters returns the parameters of req, from both its URL and its form body
+func requestParameters(req *http.Request) url.Values {
+	params := req.URL.Query()
+	if req.GetBody == nil || req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		return params
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return params
+	}
+	defer func() { _ = body.Close() }()
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return params
+	}
+	form, err := url.ParseQuery(string(b))
+	if err != nil {
+		return params
+	}
+	for key, values := range form {
+		params[key] = append(params[key], values...)
+	}
+	return params
 }
 
 func getName(q *models.Query, field *data.Field) string {
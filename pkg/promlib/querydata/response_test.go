@@ -3,12 +3,20 @@ package querydata
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/grafana/pkg/promlib/converter"
 	"github.com/grafana/grafana/pkg/promlib/models"
 	"github.com/grafana/grafana/pkg/promlib/querydata/exemplar"
 )
@@ -55,4 +63,279 @@ func TestQueryData_parseResponse(t *testing.T) {
 		assert.Error(t, result.Error)
 		assert.Equal(t, result.Error.Error(), "unknown result type: ")
 	})
+
+	t.Run("resolved interval variables are attached to the custom metadata", func(t *testing.T) {
+		resBody := `{"data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[60,"1"]]}]},"status":"success"}`
+		res := &http.Response{Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		intervals := &models.IntervalContext{Interval: "1m", IntervalMs: 60000, RateInterval: "4m0s", RateIntervalMs: 240000}
+		result := qd.parseResponse(context.Background(), &models.Query{RangeQuery: true, Step: time.Minute, IntervalContext: intervals}, res, false)
+		require.NoError(t, result.Error)
+
+		custom, ok := result.Frames[0].Meta.Custom.(map[string]any)
+		require.True(t, ok)
+		require.Equal(t, intervals, custom["intervalContext"])
+		require.Equal(t, "matrix", custom["resultType"])
+	})
+
+	t.Run("step adjustment is attached to the custom metadata", func(t *testing.T) {
+		resBody := `{"data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[60,"1"]]}]},"status":"success"}`
+		res := &http.Response{Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		adjustment := &models.StepAdjustment{RequestedStep: "30s", Step: "1m", Chunks: 1, Reason: "Step increased to 1m to respect the limit of 11000 points per series over 4d"}
+		q := &models.Query{RangeQuery: true, Step: time.Minute, StepAdjustment: adjustment}
+		result := qd.parseResponse(context.Background(), q, res, false)
+		assert.Nil(t, result.Error)
+		assert.Empty(t, result.Frames[0].Meta.Notices)
+
+		custom, ok := result.Frames[0].Meta.Custom.(map[string]any)
+		require.True(t, ok)
+		assert.Equal(t, adjustment, custom["stepAdjustment"])
+	})
+}
+
+func TestQueryData_parseResponseStats(t *testing.T) {
+	qd := QueryData{exemplarSampler: exemplar.NewStandardDeviationSampler}
+	resBody := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up"},"value":[1.1,"1"]}],"stats":{"timings":{"evalTotalTime":0.0025},"samples":{"totalQueryableSamples":120,"peakSamples":12}}}}`
+
+	t.Run("stats are attached to the custom metadata with a notice", func(t *testing.T) {
+		res := &http.Response{Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		result := qd.parseResponse(context.Background(), &models.Query{Stats: true}, res, false)
+		assert.Nil(t, result.Error)
+		assert.Len(t, result.Frames, 1)
+
+		custom, ok := result.Frames[0].Meta.Custom.(map[string]any)
+		assert.True(t, ok)
+		assert.Equal(t, "vector", custom["resultType"])
+		assert.NotNil(t, custom["stats"])
+		assert.Equal(t, models.ResultTypeVector, models.ResultTypeFromFrame(result.Frames[0]))
+
+		assert.Len(t, result.Frames[0].Meta.Notices, 1)
+		assert.Equal(t, "Query statistics: 120 samples scanned, 12 peak samples, evaluated in 0.0025s", result.Frames[0].Meta.Notices[0].Text)
+	})
+
+	t.Run("no notice is added when stats are not requested", func(t *testing.T) {
+		res := &http.Response{Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		result := qd.parseResponse(context.Background(), &models.Query{}, res, false)
+		assert.Nil(t, result.Error)
+		assert.Empty(t, result.Frames[0].Meta.Notices)
+	})
+}
+
+func TestQueryData_parseResponseServerTiming(t *testing.T) {
+	qd := QueryData{exemplarSampler: exemplar.NewStandardDeviationSampler}
+	resBody := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up"},"value":[1.1,"1"]}]}}`
+
+	t.Run("metrics of the Server-Timing header are attached to the custom metadata", func(t *testing.T) {
+		res := &http.Response{
+			Header: http.Header{"Server-Timing": []string{`querier_wall_time;dur=12.5, response_time;dur=20`, `sharded_queries;val=16, cache;desc="hit"`}},
+			Body:   io.NopCloser(bytes.NewBufferString(resBody)),
+		}
+		result := qd.parseResponse(context.Background(), &models.Query{}, res, false)
+		require.NoError(t, result.Error)
+
+		custom, ok := result.Frames[0].Meta.Custom.(map[string]any)
+		require.True(t, ok)
+		require.Equal(t, "vector", custom["resultType"])
+		require.Equal(t, map[string]float64{"querier_wall_time": 12.5, "response_time": 20, "sharded_queries": 16}, custom["serverTiming"])
+	})
+
+	t.Run("nothing is attached without the header", func(t *testing.T) {
+		res := &http.Response{Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		result := qd.parseResponse(context.Background(), &models.Query{}, res, false)
+		require.NoError(t, result.Error)
+		require.NotContains(t, result.Frames[0].Meta.Custom, "serverTiming")
+	})
+}
+
+func TestParseServerTiming(t *testing.T) {
+	require.Nil(t, parseServerTiming(nil))
+	require.Nil(t, parseServerTiming([]string{"miss, db;desc=\"primary\""}))
+	require.Equal(t, map[string]float64{"db": 53}, parseServerTiming([]string{"db;val=2;dur=53"}))
+}
+
+func TestAddAutoDisplayNames(t *testing.T) {
+	series := func(labels data.Labels) *data.Frame {
+		return data.NewFrame("",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, []time.Time{}),
+			data.NewField(data.TimeSeriesValueFieldName, labels, []float64{}),
+		)
+	}
+
+	t.Run("series with the same single label are named by its value", func(t *testing.T) {
+		frames := data.Frames{series(data.Labels{"le": "0.5"}), series(data.Labels{"le": "+Inf"})}
+		addAutoDisplayNames(frames)
+		assert.Equal(t, "0.5", frames[0].Fields[1].Config.DisplayNameFromDS)
+		assert.Equal(t, "+Inf", frames[1].Fields[1].Config.DisplayNameFromDS)
+	})
+
+	t.Run("series with several labels are named by all of them", func(t *testing.T) {
+		frames := data.Frames{
+			series(data.Labels{"__name__": "up", "job": "api"}),
+			series(data.Labels{"__name__": "up", "job": "db"}),
+			series(nil),
+		}
+		addAutoDisplayNames(frames)
+		assert.Equal(t, `up{job="api"}`, frames[0].Fields[1].Config.DisplayNameFromDS)
+		assert.Equal(t, `up{job="db"}`, frames[1].Fields[1].Config.DisplayNameFromDS)
+		assert.Nil(t, frames[2].Fields[1].Config)
+	})
+
+	t.Run("series are named like Prometheus names them", func(t *testing.T) {
+		frames := data.Frames{
+			series(data.Labels{"__name__": "up"}),
+			series(data.Labels{"job": "api", "instance": "a:9090"}),
+		}
+		addAutoDisplayNames(frames)
+		assert.Equal(t, "up", frames[0].Fields[1].Config.DisplayNameFromDS)
+		assert.Equal(t, `{instance="a:9090", job="api"}`, frames[1].Fields[1].Config.DisplayNameFromDS)
+	})
+}
+
+func TestPointsPerSeries(t *testing.T) {
+	q := &models.Query{
+		Start:      time.Unix(0, 0),
+		End:        time.Unix(3600, 0),
+		Step:       time.Minute,
+		RangeQuery: true,
+	}
+	assert.Equal(t, 61, pointsPerSeries(q))
+
+	q.Step = time.Millisecond
+	assert.Equal(t, maxPreallocatedPoints, pointsPerSeries(q))
+
+	q.RangeQuery = false
+	q.InstantQuery = true
+	assert.Equal(t, 0, pointsPerSeries(q))
+}
+
+func TestQueryData_parseResponseLimits(t *testing.T) {
+	resBody := `{"data":{"resultType":"vector", "result":[{"metric":{"__name__":"a"},"value":[1.1,"2"]},{"metric":{"__name__":"b"},"value":[1.1,"3"]}]},"status":"success"}`
+
+	t.Run("too many bytes", func(t *testing.T) {
+		qd := QueryData{exemplarSampler: exemplar.NewStandardDeviationSampler, limits: responseLimits{maxBytes: 10}}
+		res := &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		result := qd.parseResponse(context.Background(), &models.Query{}, res, false)
+
+		var limitErr *converter.ResponseLimitError
+		require.ErrorAs(t, result.Error, &limitErr)
+		assert.Equal(t, "bytes", limitErr.Limit)
+		assert.Equal(t, int64(10), limitErr.Max)
+		assert.Greater(t, limitErr.Bytes, int64(10))
+	})
+
+	t.Run("too many series", func(t *testing.T) {
+		qd := QueryData{exemplarSampler: exemplar.NewStandardDeviationSampler, limits: responseLimits{maxSeries: 1}}
+		res := &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		result := qd.parseResponse(context.Background(), &models.Query{}, res, false)
+
+		var limitErr *converter.ResponseLimitError
+		require.ErrorAs(t, result.Error, &limitErr)
+		assert.Equal(t, "series", limitErr.Limit)
+		assert.Equal(t, int64(2), limitErr.Series)
+		assert.Positive(t, limitErr.Bytes)
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		qd := QueryData{exemplarSampler: exemplar.NewStandardDeviationSampler, limits: responseLimits{maxBytes: 1024, maxSeries: 2, maxSamples: 2}}
+		res := &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString(resBody))}
+		result := qd.parseResponse(context.Background(), &models.Query{}, res, false)
+		require.NoError(t, result.Error)
+		assert.Len(t, result.Frames, 2)
+	})
+}
+
+func TestParseResponseLimits(t *testing.T) {
+	limits, err := parseResponseLimits(map[string]any{"maxResponseBytes": float64(1 << 20), "maxResponseSeries": float64(500)})
+	require.NoError(t, err)
+	assert.Equal(t, responseLimits{maxBytes: 1 << 20, maxSeries: 500}, limits)
+
+	_, err = parseResponseLimits(map[string]any{"maxResponseSamples": "lots"})
+	require.Error(t, err)
+	_, err = parseResponseLimits(map[string]any{"maxResponseSamples": float64(-1)})
+	require.Error(t, err)
+}
+
+func TestExecutedQueryString(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	queryData, err := New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL + "/prometheus",
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			JSON:      []byte(`{"expr":"up","range":true,"interval":"1m","customQueryParameters":{"dedup":"false"}}`),
+			Interval:  time.Minute,
+			TimeRange: backend.TimeRange{From: time.Unix(90, 0), To: time.Unix(3630, 0)},
+		}},
+	})
+	require.NoError(t, err)
+	frames := res.Responses["A"].Frames
+	require.NotEmpty(t, frames)
+	// The range is aligned to the step
+	require.Equal(t, "Expr: up\nStep: 1m0s\nStart: 1970-01-01T00:01:00Z\nEnd: 1970-01-01T01:00:00Z\n"+
+		"Request: POST /prometheus/api/v1/query_range?dedup=false&end=3600&query=up&start=60&step=60", frames[0].Meta.ExecutedQueryString)
+
+	require.Equal(t, "Expr: up\nStep: 1m0s", executedQueryString(&models.Query{Expr: "up", Step: time.Minute}, nil))
+}
+
+func TestScalarAndStringResults(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	queryData, err := New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{}`),
+	}, log.New())
+	require.NoError(t, err)
+
+	query := func(format models.PromQueryFormat) data.Frames {
+		b, err := json.Marshal(&models.QueryModel{
+			PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: "scalar(up)", Instant: true, Format: format},
+		})
+		require.NoError(t, err)
+		res, err := queryData.Execute(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      b,
+				Interval:  time.Minute,
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+			}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		return res.Responses["A"].Frames
+	}
+
+	body = `{"status":"success","data":{"resultType":"scalar","result":[60,"0.5"]}}`
+	frames := query(models.PromQueryFormatTimeSeries)
+	require.Len(t, frames, 1)
+	require.Equal(t, data.FieldTypeFloat64, frames[0].Fields[1].Type())
+	require.Equal(t, 0.5, frames[0].Fields[1].At(0))
+
+	frames = query(models.PromQueryFormatNumeric)
+	require.Equal(t, data.FrameTypeNumericWide, frames[0].Meta.Type)
+	require.Equal(t, 0.5, frames[0].Fields[0].At(0))
+
+	frames = query(models.PromQueryFormatLong)
+	require.Equal(t, data.FrameTypeTimeSeriesLong, frames[0].Meta.Type)
+
+	body = `{"status":"success","data":{"resultType":"string","result":[60,"ok"]}}`
+	for _, format := range []models.PromQueryFormat{models.PromQueryFormatTimeSeries, models.PromQueryFormatNumeric, models.PromQueryFormatLong, models.PromQueryFormatAnnotations} {
+		frames = query(format)
+		require.NotEmpty(t, frames, format)
+	}
+	frames = query(models.PromQueryFormatTimeSeries)
+	require.Equal(t, data.FieldTypeString, frames[0].Fields[1].Type())
+	require.Equal(t, "ok", frames[0].Fields[1].At(0))
 }
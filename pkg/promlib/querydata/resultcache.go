@@ -0,0 +1,250 @@
+package querydata
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/utils/maputil"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// defaultResultCacheRounding is the interval the range of cached queries is rounded down to
+// when the data source does not set queryResultCacheRounding
+const defaultResultCacheRounding = time.Minute
+
+// maxCachedResultBytes bounds the size of the results that are cached, larger ones are not
+const maxCachedResultBytes = 10 << 20
+
+// maxMemoryResultCacheBytes bounds the size of the results cached in memory, the least recently
+// used ones are evicted past it
+const maxMemoryResultCacheBytes = 256 << 20
+
+var errResultNotFound = errors.New("result not found")
+
+// ResultCache stores the encoded results of queries, shared by the data sources it is set on.
+// Errors of Get, including the one of a missing result, are cache misses.
+type ResultCache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, expire time.Duration) error
+}
+
+// memoryResultCache is a ResultCache storing results in memory, up to maxBytes of them
+type memoryResultCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	// The elements of the cached results, the most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type memoryResult struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryResultCache returns a ResultCache storing results in memory
+func NewMemoryResultCache() ResultCache {
+	return newMemoryResultCache(maxMemoryResultCacheBytes)
+}
+
+func newMemoryResultCache(maxBytes int) *memoryResultCache {
+	return &memoryResultCache{maxBytes: maxBytes, lru: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (m *memoryResultCache) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, found := m.entries[key]
+	if !found {
+		return nil, errResultNotFound
+	}
+	r := e.Value.(*memoryResult)
+	if time.Now().After(r.expires) {
+		m.remove(e)
+		return nil, errResultNotFound
+	}
+	m.lru.MoveToFront(e)
+	return r.value, nil
+}
+
+func (m *memoryResultCache) Set(_ context.Context, key string, value []byte, expire time.Duration) error {
+	if len(value) > m.maxBytes {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, found := m.entries[key]; found {
+		m.remove(e)
+	}
+	m.entries[key] = m.lru.PushFront(&memoryResult{key: key, value: value, expires: time.Now().Add(expire)})
+	m.size += len(value)
+	for m.size > m.maxBytes {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *memoryResultCache) remove(e *list.Element) {
+	r := m.lru.Remove(e).(*memoryResult)
+	delete(m.entries, r.key)
+	m.size -= len(r.value)
+}
+
+// resultCacheSettings are the settings of a data source caching the results of its queries
+type resultCacheSettings struct {
+	// Zero when results are not cached
+	ttl time.Duration
+	// The start and end of cached queries are rounded down to a multiple of it,
+	// so the queries of successive refreshes are the same
+	rounding time.Duration
+}
+
+func parseResultCacheSettings(jsonData map[string]any) (resultCacheSettings, error) {
+	settings := resultCacheSettings{rounding: defaultResultCacheRounding}
+	for key, d := range map[string]*time.Duration{"queryResultCacheTTL": &settings.ttl, "queryResultCacheRounding": &settings.rounding} {
+		v, err := maputil.GetStringOptional(jsonData, key)
+		if err != nil {
+			return settings, err
+		}
+		if v == "" {
+			continue
+		}
+		if *d, err = gtime.ParseIntervalStringToTimeDuration(v); err != nil {
+			return settings, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return settings, nil
+}
+
+// cachedResult is a successful query response as it is cached
+type cachedResult struct {
+	Frames [][]byte `json:"frames"`
+}
+
+// resultCacheIdentity returns the identity results are cached for: the organization, the user and the
+// credentials forwarded to the data source, which may return different results for each of them.
+// The credentials are hashed, so they are not kept in the keys.
+func resultCacheIdentity(req *backend.QueryDataRequest) string {
+	h := sha256.New()
+	for _, name := range []string{backend.OAuthIdentityTokenHeaderName, backend.OAuthIdentityIDTokenHeaderName, backend.CookiesHeaderName} {
+		fmt.Fprintf(h, "%s=%q\n", name, req.GetHTTPHeader(name))
+	}
+	var login string
+	if req.PluginContext.User != nil {
+		login = req.PluginContext.User.Login
+	}
+	return fmt.Sprintf("%d/%q/%s", req.PluginContext.OrgID, login, hex.EncodeToString(h.Sum(nil)))
+}
+
+// cachesResult tells whether the result of query is cached
+func (s *QueryData) cachesResult(query *models.Query) bool {
+	if s.resultCache == nil || s.resultCacheSettings.ttl <= 0 || query.NoCache || query.RefId == "__healthcheck__" {
+		return false
+	}
+	// The range would be empty once rounded
+	rounding := s.resultCacheSettings.rounding
+	return !query.RangeQuery || rounding <= 0 || query.End.Truncate(rounding).After(query.Start.Truncate(rounding))
+}
+
+// alignCachedRange rounds the range of a query whose result is cached down to the rounding of the
+// cache, so the queries of successive refreshes are the same and a cached result is the one of the
+// range it is served for.
+func (s *QueryData) alignCachedRange(query *models.Query) {
+	rounding := s.resultCacheSettings.rounding
+	if rounding <= 0 || !s.cachesResult(query) {
+		return
+	}
+	round := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return t
+		}
+		return t.Truncate(rounding)
+	}
+	query.Start = round(query.Start)
+	query.QueriedStart = round(query.QueriedStart)
+	query.End = round(query.End)
+	query.QueriedEnd = round(query.QueriedEnd)
+}
+
+// resultCacheKey returns the key the result of query is cached with, empty when it is not cached.
+// Results are only shared by queries of the same identity, see resultCacheIdentity.
+func (s *QueryData) resultCacheKey(query *models.Query, identity string) string {
+	if !s.cachesResult(query) {
+		return ""
+	}
+
+	normalized := *query
+	normalized.RefId = ""
+	normalized.Timeout = 0
+	// Formatting and whitespace do not change the result
+	if expr, err := parser.ParseExpr(query.Expr); err == nil {
+		normalized.Expr = expr.String()
+	}
+	b, err := json.Marshal(struct {
+		ID       int64
+		URL      string
+		Identity string
+		Query    models.Query
+	}{s.ID, s.URL, identity, normalized})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return "prometheus-result:" + hex.EncodeToString(sum[:])
+}
+
+// cachedQueryResult returns the cached result of the query with key, or nil
+func (s *QueryData) cachedQueryResult(ctx context.Context, key, refID string) *backend.DataResponse {
+	b, err := s.resultCache.Get(ctx, key)
+	if err != nil {
+		return nil
+	}
+	var cached cachedResult
+	if err := json.Unmarshal(b, &cached); err != nil {
+		s.log.FromContext(ctx).Debug("Failed to decode cached query result", "err", err)
+		return nil
+	}
+	frames, err := data.UnmarshalArrowFrames(cached.Frames)
+	if err != nil {
+		s.log.FromContext(ctx).Debug("Failed to decode cached query result", "err", err)
+		return nil
+	}
+	// The result may have been cached for another query of the same expression
+	for _, frame := range frames {
+		frame.RefID = refID
+	}
+	return &backend.DataResponse{Frames: frames}
+}
+
+// cacheQueryResult caches r with key when the query was successful
+func (s *QueryData) cacheQueryResult(ctx context.Context, key string, r *backend.DataResponse) {
+	if r == nil || r.Error != nil || (r.Status != 0 && r.Status != backend.StatusOK) {
+		return
+	}
+	frames, err := r.Frames.MarshalArrow()
+	if err != nil {
+		s.log.FromContext(ctx).Debug("Failed to encode query result", "err", err)
+		return
+	}
+	b, err := json.Marshal(cachedResult{Frames: frames})
+	if err != nil || len(b) > maxCachedResultBytes {
+		return
+	}
+	if err := s.resultCache.Set(ctx, key, b, s.resultCacheSettings.ttl); err != nil {
+		s.log.FromContext(ctx).Debug("Failed to cache query result", "err", err)
+	}
+}
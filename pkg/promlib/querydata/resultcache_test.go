@@ -0,0 +1,155 @@
+package querydata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestResultCache(t *testing.T) {
+	var starts, ends []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		starts = append(starts, r.Form.Get("start"))
+		ends = append(ends, r.Form.Get("end"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"__name__":"up"},"values":[[120,"1"]]}]}}`))
+	}))
+	defer srv.Close()
+
+	queryData, err := New(srv.Client(), backend.DataSourceInstanceSettings{
+		URL:      srv.URL,
+		JSONData: json.RawMessage(`{"queryResultCacheTTL":"5m","queryResultCacheRounding":"5m"}`),
+	}, log.New())
+	require.NoError(t, err)
+	queryData.SetResultCache(NewMemoryResultCache())
+
+	query := func(refID, expr string, from time.Time, noCache bool, headers map[string]string, pluginCtx ...backend.PluginContext) *backend.DataResponse {
+		b, err := json.Marshal(&models.QueryModel{
+			PrometheusQueryProperties: models.PrometheusQueryProperties{Expr: expr, Range: true, NoCache: noCache},
+		})
+		require.NoError(t, err)
+		req := &backend.QueryDataRequest{
+			Headers: headers,
+			Queries: []backend.DataQuery{{
+				RefID:     refID,
+				JSON:      b,
+				Interval:  time.Minute,
+				TimeRange: backend.TimeRange{From: from, To: from.Add(time.Hour)},
+			}},
+		}
+		if len(pluginCtx) > 0 {
+			req.PluginContext = pluginCtx[0]
+		}
+		res, err := queryData.Execute(context.Background(), req)
+		require.NoError(t, err)
+		r := res.Responses[refID]
+		require.NoError(t, r.Error)
+		return &r
+	}
+
+	r := query("A", "up", time.Unix(400, 0), false, nil)
+	require.Len(t, starts, 1)
+	require.Equal(t, "300", starts[0], "the range of cached queries is rounded down")
+	require.Equal(t, "3900", ends[0], "the range of cached queries is rounded down")
+
+	// Queries of the same range once rounded down to 5 minutes, and of an equivalent expression, are cached
+	cached := query("B", "up  ", time.Unix(550, 0), false, nil)
+	require.Len(t, starts, 1)
+	require.Len(t, cached.Frames, len(r.Frames))
+	require.Equal(t, "B", cached.Frames[0].RefID)
+
+	query("A", "up", time.Unix(400, 0), true, nil)
+	require.Len(t, starts, 2, "queries bypassing the cache are not cached")
+
+	query("A", "up", time.Unix(400, 0), false, map[string]string{"Authorization": "Bearer other"})
+	require.Len(t, starts, 3, "results are not shared with other users")
+
+	query("A", "up", time.Unix(400, 0), false, map[string]string{"X-Dashboard-Uid": "abc"})
+	require.Len(t, starts, 3, "headers other than the forwarded credentials do not change the identity")
+
+	query("A", "up", time.Unix(400, 0), false, nil, backend.PluginContext{OrgID: 2})
+	require.Len(t, starts, 4, "results are not shared with other organizations")
+
+	query("A", "up", time.Unix(400, 0), false, nil, backend.PluginContext{User: &backend.User{Login: "other"}})
+	require.Len(t, starts, 5, "results are not shared with other users")
+
+	query("A", "up", time.Unix(700, 0), false, nil)
+	require.Len(t, starts, 6)
+}
+
+func TestNoCacheHeader(t *testing.T) {
+	for prometheusType, header := range map[string]string{"Mimir": "no-store", "Cortex": "no-store", "Prometheus": "", "Thanos": ""} {
+		t.Run(prometheusType, func(t *testing.T) {
+			var received string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Get("Cache-Control")
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+			}))
+			defer srv.Close()
+
+			queryData, err := New(srv.Client(), backend.DataSourceInstanceSettings{
+				URL:      srv.URL,
+				JSONData: json.RawMessage(`{"prometheusType":"` + prometheusType + `"}`),
+			}, log.New())
+			require.NoError(t, err)
+
+			_, err = queryData.Execute(context.Background(), &backend.QueryDataRequest{
+				Queries: []backend.DataQuery{{
+					RefID:     "A",
+					JSON:      []byte(`{"expr":"up","instant":true,"noCache":true}`),
+					TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)},
+				}},
+			})
+			require.NoError(t, err)
+			require.Equal(t, header, received)
+		})
+	}
+}
+
+func TestMemoryResultCache(t *testing.T) {
+	ctx := context.Background()
+	c := newMemoryResultCache(10)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("aaaa"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("bbbb"), time.Minute))
+	_, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+
+	// The least recently used result is evicted past the size limit
+	require.NoError(t, c.Set(ctx, "c", []byte("cccc"), time.Minute))
+	_, err = c.Get(ctx, "b")
+	require.ErrorIs(t, err, errResultNotFound)
+	v, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, []byte("aaaa"), v)
+	require.Equal(t, 8, c.size)
+
+	require.NoError(t, c.Set(ctx, "large", []byte("larger than the cache"), time.Minute))
+	_, err = c.Get(ctx, "large")
+	require.ErrorIs(t, err, errResultNotFound)
+
+	require.NoError(t, c.Set(ctx, "expired", []byte("e"), -time.Second))
+	_, err = c.Get(ctx, "expired")
+	require.ErrorIs(t, err, errResultNotFound)
+}
+
+func TestParseResultCacheSettings(t *testing.T) {
+	settings, err := parseResultCacheSettings(map[string]any{"queryResultCacheTTL": "30s"})
+	require.NoError(t, err)
+	require.Equal(t, resultCacheSettings{ttl: 30 * time.Second, rounding: defaultResultCacheRounding}, settings)
+
+	_, err = parseResultCacheSettings(map[string]any{"queryResultCacheRounding": "often"})
+	require.ErrorContains(t, err, "invalid queryResultCacheRounding")
+}
@@ -0,0 +1,87 @@
+package querydata
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// histogramBucketLabel is the label of the upper bound of the buckets of classic histograms
+const histogramBucketLabel = "le"
+
+// filterable is the Filterable config of the label columns of tables
+var filterable = true
+
+// toTableFrame merges the series frames of frames into a single table, like the table format of the frontend:
+// a time column, a filterable column for each label and a value column, with a row for each sample in the
+// order of the series. The le label of histogram buckets is a number column. Other frames, like exemplars,
+// are returned as they are after it.
+func toTableFrame(frames data.Frames) data.Frames {
+	var series, others data.Frames
+	for _, frame := range frames {
+		if isSeriesFrame(frame) {
+			series = append(series, frame)
+		} else {
+			others = append(others, frame)
+		}
+	}
+	if len(series) == 0 {
+		return frames
+	}
+
+	rows := 0
+	for _, frame := range series {
+		rows += frame.Rows()
+	}
+
+	times := make([]time.Time, 0, rows)
+	values := make([]float64, 0, rows)
+	labelNames := seriesLabelNames(series)
+	labelFields := make([]*data.Field, len(labelNames))
+	for i, name := range labelNames {
+		if name == histogramBucketLabel {
+			labelFields[i] = data.NewField(name, nil, make([]*float64, 0, rows))
+		} else {
+			labelFields[i] = data.NewField(name, nil, make([]string, 0, rows))
+		}
+		labelFields[i].Config = &data.FieldConfig{Filterable: &filterable}
+	}
+
+	for _, frame := range series {
+		labels := frame.Fields[1].Labels
+		for i := 0; i < frame.Rows(); i++ {
+			times = append(times, frame.Fields[0].At(i).(time.Time))
+			values = append(values, frame.Fields[1].At(i).(float64))
+			for j, name := range labelNames {
+				if name == histogramBucketLabel {
+					labelFields[j].Append(bucketBound(labels, name))
+				} else {
+					labelFields[j].Append(labels[name])
+				}
+			}
+		}
+	}
+
+	table := data.NewFrame("", data.NewField(data.TimeSeriesTimeFieldName, nil, times))
+	table.Fields = append(table.Fields, labelFields...)
+	table.Fields = append(table.Fields, data.NewField(data.TimeSeriesValueFieldName, nil, values))
+	table.RefID = series[0].RefID
+	table.Meta = longFrameMeta(series)
+	table.Meta.PreferredVisualization = data.VisTypeTable
+
+	return append(data.Frames{table}, others...)
+}
+
+// bucketBound returns the upper bound of the bucket of a series, nil when it has none
+func bucketBound(labels data.Labels, name string) *float64 {
+	v, ok := labels[name]
+	if !ok {
+		return nil
+	}
+	bound, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil
+	}
+	return &bound
+}
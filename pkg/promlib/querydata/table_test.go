@@ -0,0 +1,56 @@
+package querydata
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToTableFrame(t *testing.T) {
+	series := func(labels data.Labels, times []time.Time, values []float64) *data.Frame {
+		frame := data.NewFrame("",
+			data.NewField(data.TimeSeriesTimeFieldName, nil, times),
+			data.NewField(data.TimeSeriesValueFieldName, labels, values),
+		)
+		frame.RefID = "A"
+		frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesMulti, ExecutedQueryString: "Expr: up"}
+		return frame
+	}
+	t1, t2 := time.Unix(60, 0).UTC(), time.Unix(120, 0).UTC()
+	exemplars := data.NewFrame("exemplar", data.NewField("Time", nil, []time.Time{t1}), data.NewField("Value", nil, []float64{1}), data.NewField("traceID", nil, []string{"abc"}))
+
+	frames := toTableFrame(data.Frames{
+		series(data.Labels{"job": "api", "le": "0.5"}, []time.Time{t2}, []float64{3}),
+		series(data.Labels{"job": "api", "le": "+Inf"}, []time.Time{t1, t2}, []float64{4, 5}),
+		series(data.Labels{"instance": "db:9090"}, []time.Time{t1}, []float64{1}),
+		exemplars,
+	})
+	require.Len(t, frames, 2)
+	require.Equal(t, exemplars, frames[1])
+
+	table := frames[0]
+	require.Equal(t, "A", table.RefID)
+	require.Equal(t, data.VisType(data.VisTypeTable), table.Meta.PreferredVisualization)
+	require.Equal(t, "Expr: up", table.Meta.ExecutedQueryString)
+	require.Equal(t, []string{"Time", "instance", "job", "le", "Value"}, []string{
+		table.Fields[0].Name, table.Fields[1].Name, table.Fields[2].Name, table.Fields[3].Name, table.Fields[4].Name,
+	})
+	require.True(t, *table.Fields[1].Config.Filterable)
+	require.Equal(t, data.FieldTypeNullableFloat64, table.Fields[3].Type())
+
+	// Rows keep the order of the series
+	half, inf := 0.5, math.Inf(1)
+	require.Equal(t, 4, table.Rows())
+	require.Equal(t, []any{t2, "", "api", &half, 3.0}, table.RowCopy(0))
+	require.Equal(t, []any{t1, "", "api", &inf, 4.0}, table.RowCopy(1))
+	require.Equal(t, []any{t2, "", "api", &inf, 5.0}, table.RowCopy(2))
+	require.Equal(t, []any{t1, "db:9090", "", (*float64)(nil), 1.0}, table.RowCopy(3))
+
+	t.Run("frames without series are left as they are", func(t *testing.T) {
+		empty := data.Frames{data.NewFrame("")}
+		require.Equal(t, empty, toTableFrame(empty))
+	})
+}
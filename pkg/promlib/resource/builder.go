@@ -0,0 +1,52 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// BuilderRequest is the body of a query-builder resource call. Either Query is rendered
+// as a PromQL expression, or Expr is parsed into a query of the query builder.
+type BuilderRequest struct {
+	Query *models.PromVisualQuery `json:"query,omitempty"`
+	Expr  string                  `json:"expr,omitempty"`
+}
+
+// BuilderResponse holds the expression rendered from a query of the query builder and its validation,
+// or the query of the query builder parsed from an expression.
+type BuilderResponse struct {
+	Expr       string                  `json:"expr,omitempty"`
+	Validation *ValidationResponse     `json:"validation,omitempty"`
+	Query      *models.PromVisualQuery `json:"query,omitempty"`
+}
+
+// QueryBuilder converts queries of the visual query builder to PromQL and back. Rendered expressions
+// are validated and linted like the ones of the validate-query resource.
+func (r *Resource) QueryBuilder(req *backend.CallResourceRequest) (*backend.CallResourceResponse, error) {
+	var br BuilderRequest
+	if err := json.Unmarshal(req.Body, &br); err != nil {
+		return nil, fmt.Errorf("error parsing query builder request: %v", err)
+	}
+
+	if br.Query == nil {
+		q, err := models.ParseVisualQuery(br.Expr)
+		if err != nil {
+			return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return jsonResponse(http.StatusOK, BuilderResponse{Query: &q})
+	}
+
+	expr, err := br.Query.Render()
+	if err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	// Range variables do not parse, they are replaced without moving the positions of the problems
+	replaced, _ := models.ReplaceRangeVariables(expr)
+	validation := Validate(replaced)
+	return jsonResponse(http.StatusOK, BuilderResponse{Expr: expr, Validation: &validation})
+}
@@ -0,0 +1,44 @@
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResource_QueryBuilder(t *testing.T) {
+	r := &Resource{}
+	call := func(body string) (int, BuilderResponse) {
+		resp, err := r.QueryBuilder(&backend.CallResourceRequest{Path: "query-builder", Body: []byte(body)})
+		require.NoError(t, err)
+		var res BuilderResponse
+		require.NoError(t, json.Unmarshal(resp.Body, &res))
+		return resp.Status, res
+	}
+
+	t.Run("query is rendered and linted", func(t *testing.T) {
+		status, res := call(`{"query": {"metric": "node_memory_free_bytes", "labels": [], "operations": [{"id": "rate", "params": ["$__rate_interval"]}]}}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, `rate(node_memory_free_bytes[$__rate_interval])`, res.Expr)
+		require.True(t, res.Validation.Valid)
+		require.Len(t, res.Validation.Warnings, 1)
+	})
+
+	t.Run("expression is parsed", func(t *testing.T) {
+		status, res := call(`{"expr": "sum(rate(up[5m]))"}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "up", res.Query.Metric)
+		require.Len(t, res.Query.Operations, 2)
+	})
+
+	t.Run("invalid query is a bad request", func(t *testing.T) {
+		status, _ := call(`{"query": {"metric": "up", "labels": [], "operations": [{"id": "nope", "params": []}]}}`)
+		require.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = call(`{"expr": "(a + b) * c"}`)
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+}
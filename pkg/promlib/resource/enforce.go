@@ -0,0 +1,92 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// errNotEnforceable is returned for the resource calls whose results the label matchers cannot be enforced on
+var errNotEnforceable = errors.New("the data source enforces label matchers, they cannot be enforced on this endpoint")
+
+// enforceLabelMatchers returns req with the label matchers enforced on the user added to the selectors
+// of its parameters: the query of the query endpoints and the match[] selectors of the series, label
+// names and label values endpoints. The other endpoints are denied, except the build information.
+func (r *Resource) enforceLabelMatchers(req *backend.CallResourceRequest) (*backend.CallResourceRequest, error) {
+	path := strings.TrimPrefix(req.Path, "/")
+	if len(r.enforcedMatchersRules) == 0 || path == "api/v1/status/buildinfo" {
+		return req, nil
+	}
+	matchers, err := models.EnforcedMatchers(r.enforcedMatchersRules, req.PluginContext.User)
+	if err != nil || len(matchers) == 0 {
+		return req, err
+	}
+
+	var param string
+	switch {
+	case path == "api/v1/query" || path == "api/v1/query_range" || path == "api/v1/query_exemplars":
+		param = "query"
+	case path == "api/v1/series" || path == "api/v1/labels" ||
+		strings.HasPrefix(path, "api/v1/label/") && strings.HasSuffix(path, "/values"):
+		param = "match[]"
+	default:
+		return nil, errNotEnforceable
+	}
+
+	// The parameters can be in the URL and in the form of POST calls
+	reqURL, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	qv := reqURL.Query()
+	form, err := url.ParseQuery(string(req.Body))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the form of the request: %w", err)
+	}
+	for _, values := range []url.Values{qv, form} {
+		if err := enforceParam(values, param, matchers); err != nil {
+			return nil, err
+		}
+	}
+	// Without selectors, the series of all metrics are read
+	if param == "match[]" && len(qv[param]) == 0 && len(form[param]) == 0 {
+		qv[param], _ = models.EnforceSeriesSelectors(nil, matchers)
+	}
+
+	enforced := *req
+	reqURL.RawQuery = qv.Encode()
+	enforced.URL = reqURL.String()
+	if len(form) > 0 {
+		enforced.Body = []byte(form.Encode())
+	}
+	return &enforced, nil
+}
+
+// enforceParam adds matchers to the selectors of the values of param, a query or series selectors
+func enforceParam(values url.Values, param string, matchers []*labels.Matcher) error {
+	if len(values[param]) == 0 {
+		return nil
+	}
+	if param == "match[]" {
+		selectors, err := models.EnforceSeriesSelectors(values[param], matchers)
+		if err != nil {
+			return fmt.Errorf("invalid match[] selector: %w", err)
+		}
+		values[param] = selectors
+		return nil
+	}
+	for i, query := range values[param] {
+		enforced, err := models.EnforceLabelMatchers(query, matchers)
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		values[param][i] = enforced
+	}
+	return nil
+}
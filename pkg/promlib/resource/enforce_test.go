@@ -0,0 +1,128 @@
+package resource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+func TestResource_EnforcedLabelMatchers(t *testing.T) {
+	var lastForm url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		lastForm = r.Form
+		switch r.URL.Path {
+		case "/api/v1/label/job/values":
+			_, _ = w.Write([]byte(`{"status":"success","data":["api"]}`))
+		case "/api/v1/query":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	r := &Resource{
+		promClient: client.NewClient(srv.Client(), http.MethodPost, srv.URL),
+		log:        log.New(),
+		enforcedMatchersRules: []models.EnforcedMatchersRule{{
+			Roles:    []string{"Viewer"},
+			Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "namespace", "team-a")},
+		}},
+	}
+	viewer := backend.PluginContext{User: &backend.User{Login: "alice", Role: "Viewer"}}
+	call := func(pluginCtx backend.PluginContext, method, path, rawQuery, body string) int {
+		lastForm = nil
+		resp, err := r.Execute(context.Background(), &backend.CallResourceRequest{
+			PluginContext: pluginCtx,
+			Method:        method,
+			Path:          path,
+			URL:           path + "?" + rawQuery,
+			Body:          []byte(body),
+		})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	t.Run("queries in the URL and in the form", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, call(viewer, http.MethodGet, "api/v1/query", "query=sum(up)", ""))
+		require.Equal(t, `sum(up{namespace="team-a"})`, lastForm.Get("query"))
+
+		require.Equal(t, http.StatusOK, call(viewer, http.MethodPost, "api/v1/query_range", "", "query=rate(errors_total[5m])&step=60"))
+		require.Equal(t, `rate(errors_total{namespace="team-a"}[5m])`, lastForm.Get("query"))
+		require.Equal(t, "60", lastForm.Get("step"))
+	})
+
+	t.Run("series selectors", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, call(viewer, http.MethodGet, "api/v1/series", "match[]=up&match[]={job=\"api\"}", ""))
+		require.Equal(t, []string{`{__name__="up",namespace="team-a"}`, `{job="api",namespace="team-a"}`}, lastForm["match[]"])
+
+		// Without selectors the ones of the enforced matchers are added
+		require.Equal(t, http.StatusOK, call(viewer, http.MethodGet, "api/v1/label/job/values", "start=1", ""))
+		require.Equal(t, []string{`{namespace="team-a"}`}, lastForm["match[]"])
+		require.Equal(t, http.StatusOK, call(viewer, http.MethodPost, "api/v1/labels", "", "match[]=up"))
+		require.Equal(t, []string{`{__name__="up",namespace="team-a"}`}, lastForm["match[]"])
+	})
+
+	t.Run("endpoints the matchers cannot be enforced on are denied", func(t *testing.T) {
+		require.Equal(t, http.StatusForbidden, call(viewer, http.MethodGet, "api/v1/metadata", "", ""))
+		require.Equal(t, http.StatusForbidden, call(viewer, http.MethodGet, "api/v1/rules", "", ""))
+		require.Nil(t, lastForm)
+	})
+
+	t.Run("invalid selectors are rejected", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, call(viewer, http.MethodGet, "api/v1/series", "match[]=sum(up)", ""))
+		require.Nil(t, lastForm)
+	})
+
+	t.Run("the calls of unknown users are denied", func(t *testing.T) {
+		require.Equal(t, http.StatusForbidden, call(backend.PluginContext{}, http.MethodGet, "api/v1/query", "query=up", ""))
+		require.Nil(t, lastForm)
+		// Except the build information
+		require.Equal(t, http.StatusOK, call(backend.PluginContext{}, http.MethodGet, "api/v1/status/buildinfo", "", ""))
+	})
+
+	t.Run("users the rules do not apply to", func(t *testing.T) {
+		editor := backend.PluginContext{User: &backend.User{Login: "bob", Role: "Editor"}}
+		require.Equal(t, http.StatusOK, call(editor, http.MethodGet, "api/v1/metadata", "", ""))
+		require.Equal(t, http.StatusOK, call(editor, http.MethodGet, "api/v1/query", "query=up", ""))
+		require.Equal(t, "up", lastForm.Get("query"))
+	})
+
+	t.Run("variables", func(t *testing.T) {
+		resp, err := r.VariableQuery(context.Background(), &backend.CallResourceRequest{
+			PluginContext: viewer,
+			Path:          "variable-query",
+			Body:          []byte(`{"query":{"queryType":"label_values","label":"job"},"from":1000,"to":61000}`),
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.Status)
+		require.Equal(t, `{namespace="team-a"}`, lastForm.Get("match[]"))
+
+		resp, err = r.VariableQuery(context.Background(), &backend.CallResourceRequest{
+			PluginContext: viewer,
+			Path:          "variable-query",
+			Body:          []byte(`{"query":{"queryType":"query_result","query":"up"},"from":1000,"to":61000}`),
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.Status)
+		require.Equal(t, `up{namespace="team-a"}`, lastForm.Get("query"))
+
+		resp, err = r.VariableQuery(context.Background(), &backend.CallResourceRequest{
+			Path: "variable-query",
+			Body: []byte(`{"query":{"queryType":"label_names"},"from":1000,"to":61000}`),
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, resp.Status)
+	})
+}
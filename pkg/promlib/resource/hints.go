@@ -0,0 +1,188 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// HintsRequest is the body of a query-hints resource call.
+type HintsRequest struct {
+	Expr string `json:"expr"`
+}
+
+// HintsResponse holds the hints found for an expression.
+type HintsResponse struct {
+	Hints []QueryHint `json:"hints"`
+}
+
+// QueryHint suggests a change to an expression, in the format of the hints of the query editor.
+type QueryHint struct {
+	Type  string    `json:"type"`
+	Label string    `json:"label"`
+	Fix   *QueryFix `json:"fix,omitempty"`
+}
+
+// QueryFix is a change the query editor can apply to the expression.
+type QueryFix struct {
+	Label  string         `json:"label"`
+	Action QueryFixAction `json:"action"`
+}
+
+type QueryFixAction struct {
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+// rawCounterSuffixes are the metric name suffixes conventionally used by counters, other than histogram buckets.
+var rawCounterSuffixes = []string{"_total", "_count", "_sum"}
+
+func (r *Resource) QueryHints(ctx context.Context, req *backend.CallResourceRequest) (*backend.CallResourceResponse, error) {
+	var hr HintsRequest
+	if err := json.Unmarshal(req.Body, &hr); err != nil {
+		return nil, fmt.Errorf("error parsing hints request: %v", err)
+	}
+
+	hints := []QueryHint{}
+	if parsed, err := parser.ParseExpr(hr.Expr); err == nil {
+		// The metadata of the metrics cannot be restricted by label matchers, the types are then guessed
+		var types map[string]string
+		if len(r.enforcedMatchersRules) == 0 {
+			types = r.metricTypes(ctx, unwrappedMetricNames(parsed))
+		}
+		// Without native histograms, histogram metadata is the one of classic histograms
+		if len(types) > 0 && !r.supported(ctx).NativeHistograms {
+			for name, metricType := range types {
+				if metricType == "histogram" {
+					delete(types, name)
+				}
+			}
+		}
+		hints = Hints(hr.Expr, parsed, types)
+	}
+
+	body, err := json.Marshal(HintsResponse{Hints: hints})
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	}, nil
+}
+
+// Hints returns the hints for expr, parsed as parsed. types holds the metadata type (counter, gauge, histogram...)
+// of the metrics of expr that are known, the type of the other metrics is guessed from their name.
+func Hints(expr string, parsed parser.Expr, types map[string]string) []QueryHint {
+	// Only plain selectors are fixed automatically, the other expressions are left to the user
+	_, fixable := parsed.(*parser.VectorSelector)
+
+	// A hint for the first metric that needs one is enough, fixes apply to the whole expression
+	for _, name := range unwrappedMetricNames(parsed) {
+		if hint, ok := metricHint(expr, name, types, fixable); ok {
+			return []QueryHint{hint}
+		}
+	}
+	return []QueryHint{}
+}
+
+func metricHint(expr, name string, types map[string]string, fixable bool) (QueryHint, bool) {
+	metricType, known := types[name]
+	switch {
+	case strings.HasSuffix(name, "_bucket"):
+		return newHint("HISTOGRAM_QUANTILE", "Selected metric has buckets.",
+			"Consider calculating aggregated quantile by adding histogram_quantile().", "ADD_HISTOGRAM_QUANTILE", expr, fixable), true
+	case metricType == "histogram":
+		return newHint("HISTOGRAM_QUANTILE", "Selected metric is a native histogram.",
+			"Consider calculating aggregated quantile by adding histogram_quantile().", "ADD_HISTOGRAM_QUANTILE", expr, fixable), true
+	case metricType == "counter" || (!known && hasSuffix(name, rawCounterSuffixes)):
+		verb := "looks like"
+		if known {
+			verb = "is"
+		}
+		return newHint("APPLY_RATE", fmt.Sprintf("Selected metric %s a counter.", verb),
+			"Consider calculating rate of counter by adding rate().", "ADD_RATE", expr, fixable), true
+	}
+	return QueryHint{}, false
+}
+
+func newHint(hintType, label, fixLabel, fixType, expr string, fixable bool) QueryHint {
+	if !fixable {
+		return QueryHint{Type: hintType, Label: label + " " + fixLabel}
+	}
+	return QueryHint{
+		Type:  hintType,
+		Label: label,
+		Fix: &QueryFix{
+			Label:  fixLabel,
+			Action: QueryFixAction{Type: fixType, Query: expr},
+		},
+	}
+}
+
+// unwrappedMetricNames returns the distinct metric names of the selectors of parsed that are not
+// an argument of a function, e.g. the counters that are not wrapped in rate().
+func unwrappedMetricNames(parsed parser.Expr) []string {
+	var names []string
+	seen := map[string]bool{}
+	parser.Inspect(parsed, func(node parser.Node, path []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok || vs.Name == "" || seen[vs.Name] {
+			return nil
+		}
+		for _, p := range path {
+			if _, ok := p.(*parser.Call); ok {
+				return nil
+			}
+		}
+		seen[vs.Name] = true
+		names = append(names, vs.Name)
+		return nil
+	})
+	return names
+}
+
+// metricTypes returns the metadata type of the metrics in names that Prometheus knows about, read with a
+// single metadata request. Failing to read the metadata is not an error, the types are then guessed from
+// the metric names.
+func (r *Resource) metricTypes(ctx context.Context, names []string) map[string]string {
+	types := map[string]string{}
+	if r.promClient == nil || len(names) == 0 {
+		return types
+	}
+
+	// The metadata of a single metric is filtered by Prometheus, the one of all the metrics otherwise
+	qv := map[string]string{"limit_per_metric": "1"}
+	if len(names) == 1 {
+		qv["metric"] = names[0]
+	}
+	metadata, err := queryAPI[map[string][]struct {
+		Type string `json:"type"`
+	}](ctx, r, "api/v1/metadata", qv)
+	if err != nil {
+		r.log.FromContext(ctx).Debug("Failed to read metric metadata", "metrics", names, "err", err)
+		return types
+	}
+	for _, name := range names {
+		if entries := metadata[name]; len(entries) > 0 && entries[0].Type != "unknown" {
+			types[name] = entries[0].Type
+		}
+	}
+	return types
+}
+
+func hasSuffix(name string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+)
+
+func TestHints(t *testing.T) {
+	hints := func(expr string, types map[string]string) []QueryHint {
+		parsed, err := parser.ParseExpr(expr)
+		require.NoError(t, err)
+		return Hints(expr, parsed, types)
+	}
+
+	t.Run("counter selector is fixed with rate", func(t *testing.T) {
+		res := hints(`http_requests_total{job="api"}`, nil)
+		require.Len(t, res, 1)
+		require.Equal(t, "APPLY_RATE", res[0].Type)
+		require.Equal(t, "Selected metric looks like a counter.", res[0].Label)
+		require.Equal(t, QueryFixAction{Type: "ADD_RATE", Query: `http_requests_total{job="api"}`}, res[0].Fix.Action)
+	})
+
+	t.Run("metadata type takes precedence over the metric name", func(t *testing.T) {
+		res := hints(`requests`, map[string]string{"requests": "counter"})
+		require.Len(t, res, 1)
+		require.Equal(t, "Selected metric is a counter.", res[0].Label)
+
+		require.Empty(t, hints(`queue_total`, map[string]string{"queue_total": "gauge"}))
+	})
+
+	t.Run("counter in an expression is not fixed automatically", func(t *testing.T) {
+		res := hints(`sum(http_requests_total) by (job)`, nil)
+		require.Len(t, res, 1)
+		require.Equal(t, "APPLY_RATE", res[0].Type)
+		require.Nil(t, res[0].Fix)
+		require.Contains(t, res[0].Label, "adding rate()")
+	})
+
+	t.Run("wrapped counter has no hint", func(t *testing.T) {
+		require.Empty(t, hints(`sum(rate(http_requests_total[5m]))`, nil))
+	})
+
+	t.Run("histograms are fixed with histogram_quantile", func(t *testing.T) {
+		res := hints(`request_duration_seconds_bucket`, nil)
+		require.Len(t, res, 1)
+		require.Equal(t, "HISTOGRAM_QUANTILE", res[0].Type)
+		require.Equal(t, "ADD_HISTOGRAM_QUANTILE", res[0].Fix.Action.Type)
+
+		res = hints(`request_duration_seconds`, map[string]string{"request_duration_seconds": "histogram"})
+		require.Len(t, res, 1)
+		require.Equal(t, "Selected metric is a native histogram.", res[0].Label)
+	})
+}
+
+func TestResource_QueryHints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/metadata", r.URL.Path)
+		require.Equal(t, "requests", r.URL.Query().Get("metric"))
+		_, _ = w.Write([]byte(`{"status":"success","data":{"requests":[{"type":"counter","help":"Requests.","unit":""}]}}`))
+	}))
+	defer srv.Close()
+
+	r := &Resource{
+		promClient: client.NewClient(srv.Client(), http.MethodPost, srv.URL),
+		log:        log.New(),
+	}
+	resp, err := r.QueryHints(context.Background(), &backend.CallResourceRequest{
+		Path: "query-hints",
+		Body: []byte(`{"expr":"requests"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+
+	var res HintsResponse
+	require.NoError(t, json.Unmarshal(resp.Body, &res))
+	require.Len(t, res.Hints, 1)
+	require.Equal(t, "Selected metric is a counter.", res.Hints[0].Label)
+}
+
+func TestResource_metricTypes(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		_, _ = w.Write([]byte(`{"status":"success","data":{
+			"requests":[{"type":"counter","help":"Requests.","unit":""}],
+			"duration":[{"type":"histogram","help":"Duration.","unit":""}],
+			"other":[{"type":"unknown","help":"","unit":""}]}}`))
+	}))
+	defer srv.Close()
+
+	r := &Resource{
+		promClient: client.NewClient(srv.Client(), http.MethodPost, srv.URL),
+		log:        log.New(),
+	}
+	// The metadata of all the metrics is read once
+	types := r.metricTypes(context.Background(), []string{"requests", "duration", "other", "missing"})
+	require.Equal(t, map[string]string{"requests": "counter", "duration": "histogram"}, types)
+	require.Equal(t, []string{"limit_per_metric=1"}, requests)
+
+	require.Empty(t, r.metricTypes(context.Background(), nil))
+	require.Len(t, requests, 1)
+}
@@ -3,6 +3,7 @@ package resource
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -11,12 +12,19 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data/utils/maputil"
 
 	"github.com/grafana/grafana/pkg/promlib/client"
+	"github.com/grafana/grafana/pkg/promlib/models"
 	"github.com/grafana/grafana/pkg/promlib/utils"
 )
 
 type Resource struct {
 	promClient *client.Client
 	log        log.Logger
+
+	// Returns the features supported by the backend, all of them are used when unset
+	capabilities func(ctx context.Context) models.Capabilities
+
+	// The rules of the label matchers added to the selectors of the calls of users
+	enforcedMatchersRules []models.EnforcedMatchersRule
 }
 
 func New(
@@ -34,13 +42,46 @@ func New(
 		httpMethod = http.MethodPost
 	}
 
+	endpointMethods, err := client.ParseEndpointMethods(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	enforcedMatchersRules, err := models.ParseEnforcedLabelMatchers(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	promClient := client.NewClient(httpClient, httpMethod, settings.URL)
+	promClient.SetEndpointMethods(endpointMethods)
+
 	return &Resource{
-		log:        plog,
-		promClient: client.NewClient(httpClient, httpMethod, settings.URL),
+		log:                   plog,
+		promClient:            promClient,
+		enforcedMatchersRules: enforcedMatchersRules,
 	}, nil
 }
 
+// SetCapabilities sets the function detecting the features supported by the backend.
+func (r *Resource) SetCapabilities(capabilities func(ctx context.Context) models.Capabilities) {
+	r.capabilities = capabilities
+}
+
+func (r *Resource) supported(ctx context.Context) models.Capabilities {
+	if r.capabilities == nil {
+		return models.AllCapabilities
+	}
+	return r.capabilities(ctx)
+}
+
 func (r *Resource) Execute(ctx context.Context, req *backend.CallResourceRequest) (*backend.CallResourceResponse, error) {
+	req, err := r.enforceLabelMatchers(req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNotEnforceable) || errors.Is(err, models.ErrUnknownUser) {
+			status = http.StatusForbidden
+		}
+		return jsonResponse(status, map[string]string{"status": "error", "error": err.Error()})
+	}
+
 	r.log.FromContext(ctx).Debug("Sending resource query", "URL", req.URL)
 	resp, err := r.promClient.QueryResource(ctx, req)
 	if err != nil {
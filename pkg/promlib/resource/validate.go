@@ -0,0 +1,173 @@
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/promql/parser/posrange"
+)
+
+// ValidationRequest is the body of a validate-query resource call.
+type ValidationRequest struct {
+	Expr string `json:"expr"`
+}
+
+// ValidationResponse holds the syntax errors and lint warnings found in an expression.
+type ValidationResponse struct {
+	Valid    bool                `json:"valid"`
+	Errors   []ValidationProblem `json:"errors,omitempty"`
+	Warnings []ValidationProblem `json:"warnings,omitempty"`
+}
+
+// ValidationProblem describes a single problem in an expression.
+// Start and End are byte offsets in the expression, Line and Column are 1-based.
+type ValidationProblem struct {
+	Message string `json:"message"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// counterSuffixes are the metric name suffixes conventionally used by counters.
+var counterSuffixes = []string{"_total", "_count", "_sum", "_bucket"}
+
+// counterFuncs are the functions that only make sense over counters.
+var counterFuncs = map[string]bool{
+	"rate":     true,
+	"irate":    true,
+	"increase": true,
+}
+
+func (r *Resource) ValidateQuery(req *backend.CallResourceRequest) (*backend.CallResourceResponse, error) {
+	var vr ValidationRequest
+	if err := json.Unmarshal(req.Body, &vr); err != nil {
+		return nil, fmt.Errorf("error parsing validation request: %v", err)
+	}
+
+	body, err := json.Marshal(Validate(vr.Expr))
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	}, nil
+}
+
+// Validate parses expr with the upstream PromQL parser and returns syntax errors with positions.
+// When the expression parses, it is also linted for common mistakes.
+func Validate(expr string) ValidationResponse {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return ValidationResponse{Errors: parseProblems(expr, err)}
+	}
+
+	return ValidationResponse{
+		Valid:    true,
+		Warnings: lint(expr, parsed),
+	}
+}
+
+func parseProblems(expr string, err error) []ValidationProblem {
+	var errs parser.ParseErrors
+	if !errors.As(err, &errs) {
+		return []ValidationProblem{{Message: err.Error(), Line: 1, Column: 1}}
+	}
+
+	problems := make([]ValidationProblem, 0, len(errs))
+	for _, e := range errs {
+		problems = append(problems, newProblem(expr, e.Err.Error(), e.PositionRange))
+	}
+	return problems
+}
+
+func lint(expr string, parsed parser.Expr) []ValidationProblem {
+	var warnings []ValidationProblem
+
+	parser.Inspect(parsed, func(node parser.Node, _ []parser.Node) error {
+		call, ok := node.(*parser.Call)
+		if !ok {
+			return nil
+		}
+
+		switch {
+		case counterFuncs[call.Func.Name]:
+			for _, name := range metricNames(call) {
+				if !isCounterName(name) {
+					msg := fmt.Sprintf("%s() should only be used with counters, %q does not look like a counter", call.Func.Name, name)
+					warnings = append(warnings, newProblem(expr, msg, call.PositionRange()))
+				}
+			}
+		case call.Func.Name == "histogram_quantile" && len(call.Args) == 2:
+			for _, name := range metricNames(call.Args[1]) {
+				if !strings.HasSuffix(name, "_bucket") {
+					msg := fmt.Sprintf("histogram_quantile() expects classic histogram buckets, %q does not end in _bucket", name)
+					warnings = append(warnings, newProblem(expr, msg, call.PositionRange()))
+				}
+			}
+		}
+		return nil
+	})
+
+	return warnings
+}
+
+// metricNames returns the metric names selected by the vector selectors under node.
+func metricNames(node parser.Node) []string {
+	var names []string
+	parser.Inspect(node, func(n parser.Node, _ []parser.Node) error {
+		if vs, ok := n.(*parser.VectorSelector); ok && vs.Name != "" {
+			names = append(names, vs.Name)
+		}
+		return nil
+	})
+	return names
+}
+
+func isCounterName(name string) bool {
+	for _, suffix := range counterSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func newProblem(expr, msg string, pr posrange.PositionRange) ValidationProblem {
+	start, end := clampPos(expr, int(pr.Start)), clampPos(expr, int(pr.End))
+	line, column := 1, 1
+	for _, c := range expr[:start] {
+		if c == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+
+	return ValidationProblem{
+		Message: msg,
+		Start:   start,
+		End:     end,
+		Line:    line,
+		Column:  column,
+	}
+}
+
+func clampPos(expr string, pos int) int {
+	if pos < 0 {
+		return 0
+	}
+	if pos > len(expr) {
+		return len(expr)
+	}
+	return pos
+}
@@ -0,0 +1,63 @@
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("valid expression has no problems", func(t *testing.T) {
+		res := Validate(`sum(rate(http_requests_total{job="api"}[5m])) by (code)`)
+		require.True(t, res.Valid)
+		require.Empty(t, res.Errors)
+		require.Empty(t, res.Warnings)
+	})
+
+	t.Run("syntax error is returned with its position", func(t *testing.T) {
+		res := Validate("sum(up\n  by (job)")
+		require.False(t, res.Valid)
+		require.NotEmpty(t, res.Errors)
+		require.Equal(t, "unexpected <by> in aggregation", res.Errors[0].Message)
+		require.Equal(t, 9, res.Errors[0].Start)
+		require.Equal(t, 2, res.Errors[0].Line)
+		require.Equal(t, 3, res.Errors[0].Column)
+	})
+
+	t.Run("rate on a gauge is a warning", func(t *testing.T) {
+		res := Validate(`rate(node_memory_free_bytes[5m])`)
+		require.True(t, res.Valid)
+		require.Len(t, res.Warnings, 1)
+		require.Contains(t, res.Warnings[0].Message, "node_memory_free_bytes")
+		require.Equal(t, 0, res.Warnings[0].Start)
+		require.Equal(t, 1, res.Warnings[0].Column)
+	})
+
+	t.Run("histogram_quantile without buckets is a warning", func(t *testing.T) {
+		res := Validate(`histogram_quantile(0.9, rate(request_duration_seconds_sum[5m]))`)
+		require.True(t, res.Valid)
+		require.Len(t, res.Warnings, 1)
+		require.Contains(t, res.Warnings[0].Message, "_bucket")
+	})
+}
+
+func TestResource_ValidateQuery(t *testing.T) {
+	r := &Resource{}
+	resp, err := r.ValidateQuery(&backend.CallResourceRequest{
+		Path: "validate-query",
+		Body: []byte(`{"expr":"up{"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+
+	var res ValidationResponse
+	require.NoError(t, json.Unmarshal(resp.Body, &res))
+	require.False(t, res.Valid)
+	require.NotEmpty(t, res.Errors)
+
+	_, err = r.ValidateQuery(&backend.CallResourceRequest{Body: []byte(`not json`)})
+	require.Error(t, err)
+}
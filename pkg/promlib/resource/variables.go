@@ -0,0 +1,216 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+	"github.com/grafana/grafana/pkg/promlib/models"
+)
+
+// VariableQueryRequest is the body of a variable-query resource call.
+// From and To are the time range of the dashboard in epoch milliseconds.
+type VariableQueryRequest struct {
+	Query models.PrometheusVariableQuery `json:"query"`
+	From  int64                          `json:"from"`
+	To    int64                          `json:"to"`
+}
+
+// VariableQueryResponse holds the values of a template variable.
+type VariableQueryResponse struct {
+	Values []MetricFindValue `json:"values"`
+}
+
+// MetricFindValue is a value of a template variable, in the format of the variables of the frontend.
+type MetricFindValue struct {
+	Text       string `json:"text"`
+	Expandable bool   `json:"expandable,omitempty"`
+}
+
+// instantResult is the data of an api/v1/query response
+type instantResult struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+type vectorSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]any            `json:"value"`
+}
+
+// VariableQuery resolves the values of a template variable, so they do not depend on the frontend.
+func (r *Resource) VariableQuery(ctx context.Context, req *backend.CallResourceRequest) (*backend.CallResourceResponse, error) {
+	var vr VariableQueryRequest
+	if err := json.Unmarshal(req.Body, &vr); err != nil {
+		return nil, fmt.Errorf("error parsing variable query request: %v", err)
+	}
+	if err := vr.Query.Validate(); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	matchers, err := models.EnforcedMatchers(r.enforcedMatchersRules, req.PluginContext.User)
+	if err != nil {
+		return jsonResponse(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	if vr.Query.Match, vr.Query.Query, err = enforceVariableQuery(vr.Query, matchers); err != nil {
+		return jsonResponse(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	values, err := r.variableValues(ctx, vr)
+	if err != nil {
+		return jsonResponse(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
+	return jsonResponse(http.StatusOK, VariableQueryResponse{Values: values})
+}
+
+func (r *Resource) variableValues(ctx context.Context, vr VariableQueryRequest) ([]MetricFindValue, error) {
+	q := vr.Query
+	start, end := formatMillis(vr.From), formatMillis(vr.To)
+	rangeParams := func() map[string]string {
+		qv := map[string]string{"start": start, "end": end}
+		if q.Match != "" {
+			qv["match[]"] = q.Match
+		}
+		return qv
+	}
+
+	switch q.QueryType {
+	case models.VariableQueryTypeLabelNames:
+		names, err := queryAPI[[]string](ctx, r, "api/v1/labels", rangeParams())
+		return textValues(names), err
+	case models.VariableQueryTypeLabelValues:
+		values, err := queryAPI[[]string](ctx, r, "api/v1/label/"+url.PathEscape(q.Label)+"/values", rangeParams())
+		return textValues(values), err
+	case models.VariableQueryTypeSeries:
+		series, err := queryAPI[[]map[string]string](ctx, r, "api/v1/series", rangeParams())
+		if err != nil {
+			return nil, err
+		}
+		values := make([]MetricFindValue, 0, len(series))
+		for _, labels := range series {
+			values = append(values, MetricFindValue{Text: seriesText(labels), Expandable: true})
+		}
+		return values, nil
+	case models.VariableQueryTypeQueryResult:
+		result, err := queryAPI[instantResult](ctx, r, "api/v1/query", map[string]string{"query": q.Query, "time": end})
+		if err != nil {
+			return nil, err
+		}
+		return queryResultValues(result)
+	}
+	return nil, fmt.Errorf("unknown variable query type %q", q.QueryType)
+}
+
+// enforceVariableQuery returns the series selector and the query of q with matchers added to their
+// selectors. Variables reading label names, label values or series select the series of all metrics
+// without a selector, the enforced matchers are then their selector.
+func enforceVariableQuery(q models.PrometheusVariableQuery, matchers []*labels.Matcher) (string, string, error) {
+	if len(matchers) == 0 {
+		return q.Match, q.Query, nil
+	}
+	if q.QueryType == models.VariableQueryTypeQueryResult {
+		query, err := models.EnforceLabelMatchers(q.Query, matchers)
+		return q.Match, query, err
+	}
+	var selectors []string
+	if q.Match != "" {
+		selectors = []string{q.Match}
+	}
+	selectors, err := models.EnforceSeriesSelectors(selectors, matchers)
+	if err != nil {
+		return "", "", err
+	}
+	return selectors[0], q.Query, nil
+}
+
+// queryResultValues returns a value for each series of a vector, made of the series, its value and its timestamp
+// in milliseconds, or the value of a scalar or string
+func queryResultValues(result instantResult) ([]MetricFindValue, error) {
+	switch result.ResultType {
+	case "scalar", "string":
+		var v [2]any
+		if err := json.Unmarshal(result.Result, &v); err != nil {
+			return nil, err
+		}
+		text, _ := v[1].(string)
+		return []MetricFindValue{{Text: text}}, nil
+	case "vector":
+		var samples []vectorSample
+		if err := json.Unmarshal(result.Result, &samples); err != nil {
+			return nil, err
+		}
+		values := make([]MetricFindValue, 0, len(samples))
+		for _, s := range samples {
+			ts, _ := s.Value[0].(float64)
+			value, _ := s.Value[1].(string)
+			text := fmt.Sprintf("%s %s %s", seriesText(s.Metric), value, strconv.FormatFloat(ts*1000, 'f', -1, 64))
+			values = append(values, MetricFindValue{Text: text, Expandable: true})
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown result type %q", result.ResultType)
+}
+
+// queryAPI calls an endpoint of the Prometheus HTTP API and returns the data of its response
+func queryAPI[T any](ctx context.Context, r *Resource, endpoint string, qv map[string]string) (T, error) {
+	var data T
+	res, err := r.promClient.QueryAPI(ctx, endpoint, qv)
+	if err != nil {
+		return data, err
+	}
+	rsp, err := client.DecodeAPIResponse[T](res, r.log.FromContext(ctx))
+	if err != nil {
+		return data, err
+	}
+	return rsp.Data, nil
+}
+
+// seriesText formats a series as metric{label="value",...}, like the variables of the frontend do
+func seriesText(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if name != "__name__" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labels[name]))
+	}
+	return labels["__name__"] + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func textValues(texts []string) []MetricFindValue {
+	values := make([]MetricFindValue, 0, len(texts))
+	for _, text := range texts {
+		values = append(values, MetricFindValue{Text: text})
+	}
+	return values
+}
+
+func formatMillis(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1000, 'f', -1, 64)
+}
+
+func jsonResponse(status int, v any) (*backend.CallResourceResponse, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &backend.CallResourceResponse{
+		Status:  status,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	}, nil
+}
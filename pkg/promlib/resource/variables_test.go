@@ -0,0 +1,91 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/promlib/client"
+)
+
+func TestResource_VariableQuery(t *testing.T) {
+	var lastRequest *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastRequest = r
+		switch r.URL.Path {
+		case "/api/v1/labels":
+			_, _ = w.Write([]byte(`{"status":"success","data":["__name__","job"]}`))
+		case "/api/v1/label/job/values":
+			_, _ = w.Write([]byte(`{"status":"success","data":["api","db"]}`))
+		case "/api/v1/label/unavailable/values":
+			// A proxy answering with a body that is not an error of Prometheus
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"success","data":["stale"]}`))
+		case "/api/v1/series":
+			_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"up","job":"api","instance":"a:9090"}]}`))
+		case "/api/v1/query":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up","job":"api"},"value":[1700000000.5,"1"]}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	r := &Resource{
+		promClient: client.NewClient(srv.Client(), http.MethodPost, srv.URL),
+		log:        log.New(),
+	}
+	query := func(body string) (int, VariableQueryResponse) {
+		resp, err := r.VariableQuery(context.Background(), &backend.CallResourceRequest{Path: "variable-query", Body: []byte(body)})
+		require.NoError(t, err)
+		var res VariableQueryResponse
+		require.NoError(t, json.Unmarshal(resp.Body, &res))
+		return resp.Status, res
+	}
+
+	t.Run("label_names", func(t *testing.T) {
+		status, res := query(`{"query":{"queryType":"label_names","match":"up"},"from":1000,"to":61000}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []MetricFindValue{{Text: "__name__"}, {Text: "job"}}, res.Values)
+		require.Equal(t, "up", lastRequest.URL.Query().Get("match[]"))
+		require.Equal(t, "1", lastRequest.URL.Query().Get("start"))
+		require.Equal(t, "61", lastRequest.URL.Query().Get("end"))
+	})
+
+	t.Run("label_values", func(t *testing.T) {
+		status, res := query(`{"query":{"queryType":"label_values","label":"job"},"from":1000,"to":61000}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []MetricFindValue{{Text: "api"}, {Text: "db"}}, res.Values)
+		require.Empty(t, lastRequest.URL.Query().Get("match[]"))
+	})
+
+	t.Run("series", func(t *testing.T) {
+		status, res := query(`{"query":{"queryType":"series","match":"up"},"from":1000,"to":61000}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []MetricFindValue{{Text: `up{instance="a:9090",job="api"}`, Expandable: true}}, res.Values)
+	})
+
+	t.Run("query_result", func(t *testing.T) {
+		status, res := query(`{"query":{"queryType":"query_result","query":"up"},"from":1000,"to":61000}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []MetricFindValue{{Text: `up{job="api"} 1 1700000000500`, Expandable: true}}, res.Values)
+		require.Equal(t, "61", lastRequest.URL.Query().Get("time"))
+	})
+
+	t.Run("unsuccessful responses fail", func(t *testing.T) {
+		status, res := query(`{"query":{"queryType":"label_values","label":"unavailable"},"from":1000,"to":61000}`)
+		require.Equal(t, http.StatusBadGateway, status)
+		require.Empty(t, res.Values)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		status, _ := query(`{"query":{"queryType":"label_values"}}`)
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+}
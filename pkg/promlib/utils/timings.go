@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TimingPhase is a phase of the execution of a query
+type TimingPhase string
+
+const (
+	// From sending a request until a connection is asked for, like when waiting for the concurrency limit
+	TimingPhaseQueue TimingPhase = "queue"
+	// Getting a connection: resolving, dialing and the TLS handshake, nearly zero when a connection is reused
+	TimingPhaseConnect TimingPhase = "connect"
+	// From getting a connection until the first byte of the response, mostly the evaluation of the query
+	TimingPhaseFirstByte TimingPhase = "firstByte"
+	// Reading and decoding the responses into frames
+	TimingPhaseDecode TimingPhase = "decode"
+	// Converting the frames to the format of the query
+	TimingPhaseConversion TimingPhase = "conversion"
+)
+
+var timingPhases = []TimingPhase{TimingPhaseQueue, TimingPhaseConnect, TimingPhaseFirstByte, TimingPhaseDecode, TimingPhaseConversion}
+
+// QueryTimings adds up the durations of each phase of the requests of a query. A nil QueryTimings
+// records nothing, so phases can be timed whether the query is timed or not.
+type QueryTimings struct {
+	mu        sync.Mutex
+	durations map[TimingPhase]time.Duration
+}
+
+// NewQueryTimings returns timings with all the phases at zero
+func NewQueryTimings() *QueryTimings {
+	return &QueryTimings{durations: map[TimingPhase]time.Duration{}}
+}
+
+// Add adds d to the duration of phase
+func (t *QueryTimings) Add(phase TimingPhase, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations[phase] += d
+}
+
+// Milliseconds returns the duration of every phase in milliseconds
+func (t *QueryTimings) Milliseconds() map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ms := make(map[string]float64, len(timingPhases))
+	for _, phase := range timingPhases {
+		ms[string(phase)] = float64(t.durations[phase].Microseconds()) / 1000
+	}
+	return ms
+}
+
+type queryTimingsKey struct{}
+
+// WithQueryTimings returns a context the phases of the requests sent with are recorded in t
+func WithQueryTimings(ctx context.Context, t *QueryTimings) context.Context {
+	return context.WithValue(ctx, queryTimingsKey{}, t)
+}
+
+// QueryTimingsFromContext returns the timings set by WithQueryTimings, nil when there are none
+func QueryTimingsFromContext(ctx context.Context) *QueryTimings {
+	t, _ := ctx.Value(queryTimingsKey{}).(*QueryTimings)
+	return t
+}
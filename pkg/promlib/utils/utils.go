@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"go.opentelemetry.io/otel/attribute"
@@ -21,6 +22,19 @@ func GetJsonData(settings backend.DataSourceInstanceSettings) (map[string]any, e
 	return jsonData, nil
 }
 
+// GetInt64Optional reads a non-negative whole number from jsonData, zero when it is not set
+func GetInt64Optional(jsonData map[string]any, key string) (int64, error) {
+	v, ok := jsonData[key]
+	if !ok || v == nil {
+		return 0, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return 0, fmt.Errorf("%s must be a non-negative whole number, got %v", key, v)
+	}
+	return int64(f), nil
+}
+
 // StartTrace setups a trace but does not panic if tracer is nil which helps with testing
 func StartTrace(ctx context.Context, tracer trace.Tracer, name string, attributes ...attribute.KeyValue) (context.Context, func()) {
 	if tracer == nil {
@@ -1317,6 +1317,22 @@ var (
 			HideFromAdminPage: true,
 			HideFromDocs:      true,
 		},
+		{
+			Name:              "grafanaManagedRecordingRulesWriteBatching",
+			Description:       "Splits the writes of Grafana-managed recording rules with many series into several requests.",
+			Stage:             FeatureStageExperimental,
+			Owner:             grafanaAlertingSquad,
+			HideFromAdminPage: true,
+			HideFromDocs:      true,
+		},
+		{
+			Name:              "grafanaManagedRecordingRulesWriteRetries",
+			Description:       "Retries the writes of Grafana-managed recording rules failing because of transient errors of their target.",
+			Stage:             FeatureStageExperimental,
+			Owner:             grafanaAlertingSquad,
+			HideFromAdminPage: true,
+			HideFromDocs:      true,
+		},
 	}
 )
 
@@ -710,4 +710,12 @@ const (
 	// FlagAuthZGRPCServer
 	// Enables the gRPC server for authorization
 	FlagAuthZGRPCServer = "authZGRPCServer"
+
+	// FlagGrafanaManagedRecordingRulesWriteBatching
+	// Splits the writes of Grafana-managed recording rules with many series into several requests.
+	FlagGrafanaManagedRecordingRulesWriteBatching = "grafanaManagedRecordingRulesWriteBatching"
+
+	// FlagGrafanaManagedRecordingRulesWriteRetries
+	// Retries the writes of Grafana-managed recording rules failing because of transient errors of their target.
+	FlagGrafanaManagedRecordingRulesWriteRetries = "grafanaManagedRecordingRulesWriteRetries"
 )
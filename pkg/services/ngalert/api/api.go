@@ -23,7 +23,9 @@ import (
 	"github.com/grafana/grafana/pkg/services/ngalert/sender"
 	"github.com/grafana/grafana/pkg/services/ngalert/state"
 	"github.com/grafana/grafana/pkg/services/ngalert/store"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/secrets"
 	"github.com/grafana/grafana/pkg/setting"
 )
 
@@ -76,6 +78,11 @@ type API struct {
 	Historian            Historian
 	Tracer               tracing.Tracer
 	AppUrl               *url.URL
+	SecretsService       secrets.Service
+	// Captures the writes of recording rules, nil when their results are not written
+	RecordingCapture *writer.PayloadCapture
+	// The metrics written by recording rules, nil when their results are not written
+	RecordedMetrics *writer.MetricCatalog
 
 	// Hooks can be used to replace API handlers for specific paths.
 	Hooks *Hooks
@@ -154,6 +161,11 @@ func (api *API) RegisterAPIEndpoints(m *metrics.API) {
 			log:                  logger,
 			alertmanagerProvider: api.AlertsRouter,
 			featureManager:       api.FeatureManager,
+			recordingRules:       api.Cfg.UnifiedAlerting.RecordingRules,
+			decryptFn:            api.SecretsService.Decrypt,
+			recordingCapture:     api.RecordingCapture,
+			recordedMetrics:      api.RecordedMetrics,
+			ruleStore:            api.RuleStore,
 		},
 	), m)
 
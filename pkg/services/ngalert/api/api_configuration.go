@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 
@@ -16,7 +17,9 @@ import (
 	apimodels "github.com/grafana/grafana/pkg/services/ngalert/api/tooling/definitions"
 	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/store"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/util"
 )
 
@@ -26,6 +29,12 @@ type ConfigSrv struct {
 	store                store.AdminConfigurationStore
 	log                  log.Logger
 	featureManager       featuremgmt.FeatureToggles
+	recordingRules       setting.RecordingRuleSettings
+	decryptFn            writer.DecryptFn
+	// nil when the results of recording rules are not written
+	recordingCapture *writer.PayloadCapture
+	recordedMetrics  *writer.MetricCatalog
+	ruleStore        RuleStore
 }
 
 func (srv ConfigSrv) RouteGetAlertmanagers(c *contextmodel.ReqContext) response.Response {
@@ -167,3 +176,142 @@ func (srv ConfigSrv) RouteGetAlertingStatus(c *contextmodel.ReqContext) response
 	}
 	return response.JSON(http.StatusOK, resp)
 }
+
+func (srv ConfigSrv) RouteVerifyRecordingRulesSettings(c *contextmodel.ReqContext) response.Response {
+	if !srv.featureManager.IsEnabledGlobally(featuremgmt.FlagGrafanaManagedRecordingRules) {
+		return ErrResp(http.StatusBadRequest, errors.New("recording rules are not enabled"), "")
+	}
+
+	v := writer.VerifySettings(c.Req.Context(), srv.recordingRules, srv.decryptFn, c.QueryBool("write"), srv.log)
+
+	resp := apimodels.RecordingRulesSettingsVerification{Valid: v.Valid()}
+	for _, err := range v.Errors {
+		resp.Errors = append(resp.Errors, apimodels.RecordingRulesSettingError{Field: err.Field, Message: err.Message})
+	}
+	for _, target := range v.Targets {
+		tv := apimodels.RecordingRulesTargetVerification{
+			Name:         target.Name,
+			URL:          target.URL,
+			EncodedBytes: target.EncodedBytes,
+			Written:      target.Written,
+			Failure:      string(target.Failure),
+		}
+		if target.Err != nil {
+			tv.Error = target.Err.Error()
+		}
+		resp.Targets = append(resp.Targets, tv)
+	}
+	return response.JSON(http.StatusOK, resp)
+}
+
+func (srv ConfigSrv) RouteGetRecordingRulesTargetSchema(c *contextmodel.ReqContext) response.Response {
+	if !srv.featureManager.IsEnabledGlobally(featuremgmt.FlagGrafanaManagedRecordingRules) {
+		return ErrResp(http.StatusBadRequest, errors.New("recording rules are not enabled"), "")
+	}
+	return response.JSON(http.StatusOK, writer.TargetSettingsSchema())
+}
+
+func (srv ConfigSrv) RoutePostRecordingRuleCapture(c *contextmodel.ReqContext, ruleUID string) response.Response {
+	if srv.recordingCapture == nil {
+		return ErrResp(http.StatusBadRequest, errors.New("the results of recording rules are not written"), "")
+	}
+	count := 1
+	if c.Query("count") != "" {
+		count = c.QueryInt("count")
+	}
+	key := ngmodels.AlertRuleKey{OrgID: c.SignedInUser.GetOrgID(), UID: ruleUID}
+	if err := srv.recordingCapture.Capture(key, count); err != nil {
+		return ErrResp(http.StatusBadRequest, err, "")
+	}
+	return response.JSON(http.StatusAccepted, apimodels.RecordingRuleCapture{Remaining: count, Writes: []apimodels.RecordingRuleCapturedWrite{}})
+}
+
+func (srv ConfigSrv) RouteGetRecordingRuleCapture(c *contextmodel.ReqContext, ruleUID string) response.Response {
+	if srv.recordingCapture == nil {
+		return ErrResp(http.StatusBadRequest, errors.New("the results of recording rules are not written"), "")
+	}
+	key := ngmodels.AlertRuleKey{OrgID: c.SignedInUser.GetOrgID(), UID: ruleUID}
+	writes, remaining, ok := srv.recordingCapture.Captured(key)
+	if !ok {
+		return ErrResp(http.StatusNotFound, fmt.Errorf("the writes of recording rule %s are not captured", ruleUID), "")
+	}
+
+	resp := apimodels.RecordingRuleCapture{Remaining: remaining, Writes: make([]apimodels.RecordingRuleCapturedWrite, 0, len(writes))}
+	for _, w := range writes {
+		cw := apimodels.RecordingRuleCapturedWrite{Target: w.Target, Metric: w.Name, Time: w.Time, Requests: make([]apimodels.RecordingRuleCapturedRequest, 0, len(w.Requests))}
+		if w.Err != nil {
+			cw.Error = w.Err.Error()
+		}
+		for _, r := range w.Requests {
+			cr := apimodels.RecordingRuleCapturedRequest{EncodedBytes: r.EncodedBytes, Series: make([]apimodels.RecordingRuleCapturedSeries, 0, len(r.Series))}
+			for _, series := range r.Series {
+				cs := apimodels.RecordingRuleCapturedSeries{Labels: series.Labels, Samples: make([]apimodels.RecordingRuleCapturedSample, 0, len(series.Samples))}
+				for _, sample := range series.Samples {
+					cs.Samples = append(cs.Samples, apimodels.RecordingRuleCapturedSample{
+						Timestamp: sample.Timestamp,
+						Value:     strconv.FormatFloat(sample.Value, 'f', -1, 64),
+					})
+				}
+				cr.Series = append(cr.Series, cs)
+			}
+			cw.Requests = append(cw.Requests, cr)
+		}
+		resp.Writes = append(resp.Writes, cw)
+	}
+	return response.JSON(http.StatusOK, resp)
+}
+
+func (srv ConfigSrv) RouteGetRecordedMetrics(c *contextmodel.ReqContext) response.Response {
+	if srv.recordedMetrics == nil {
+		return ErrResp(http.StatusBadRequest, errors.New("the results of recording rules are not written"), "")
+	}
+	orgID := c.SignedInUser.GetOrgID()
+	metrics := srv.recordedMetrics.Metrics(orgID)
+	resp := apimodels.RecordedMetrics{Metrics: make([]apimodels.RecordedMetric, 0, len(metrics))}
+	if len(metrics) == 0 {
+		return response.JSON(http.StatusOK, resp)
+	}
+
+	// Only the rules in the folders the user can read are returned, and the metrics written by one of them
+	namespaces, err := srv.ruleStore.GetUserVisibleNamespaces(c.Req.Context(), orgID, c.SignedInUser)
+	if err != nil {
+		return ErrResp(http.StatusInternalServerError, err, "failed to get namespaces visible to the user")
+	}
+	var uids []string
+	for _, m := range metrics {
+		for _, r := range m.Rules {
+			uids = append(uids, r.UID)
+		}
+	}
+	rules, err := srv.ruleStore.ListAlertRules(c.Req.Context(), &ngmodels.ListAlertRulesQuery{OrgID: orgID, RuleUIDs: uids})
+	if err != nil {
+		return ErrResp(http.StatusInternalServerError, err, "failed to get the recording rules")
+	}
+	readable := make(map[string]*ngmodels.AlertRule, len(rules))
+	for _, rule := range rules {
+		if _, ok := namespaces[rule.NamespaceUID]; ok {
+			readable[rule.UID] = rule
+		}
+	}
+
+	for _, m := range metrics {
+		rm := apimodels.RecordedMetric{Target: m.Target, Metric: m.Name, Labels: m.Labels}
+		for _, r := range m.Rules {
+			rule, ok := readable[r.UID]
+			if !ok {
+				continue
+			}
+			rm.Rules = append(rm.Rules, apimodels.RecordedMetricRule{
+				UID:       r.UID,
+				Title:     rule.Title,
+				FolderUID: rule.NamespaceUID,
+				LastWrite: r.LastWrite,
+				Series:    r.Series,
+			})
+		}
+		if len(rm.Rules) > 0 {
+			resp.Metrics = append(resp.Metrics, rm)
+		}
+	}
+	return response.JSON(http.StatusOK, resp)
+}
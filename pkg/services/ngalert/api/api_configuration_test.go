@@ -1,19 +1,31 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"math"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/datasources"
 	fakeDatasources "github.com/grafana/grafana/pkg/services/datasources/fakes"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/ngalert/api/tooling/definitions"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/store"
+	"github.com/grafana/grafana/pkg/services/ngalert/tests/fakes"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer/writertest"
 	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/setting"
 )
 
 func TestExternalAlertmanagerChoice(t *testing.T) {
@@ -123,6 +135,192 @@ func TestExternalAlertmanagerChoice(t *testing.T) {
 	}
 }
 
+func TestRouteVerifyRecordingRulesSettings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	request := func(t *testing.T, sut ConfigSrv, target string) (int, definitions.RecordingRulesSettingsVerification) {
+		t.Helper()
+		ctx := createRequestCtxInOrg(1)
+		ctx.Req = httptest.NewRequest(http.MethodPost, target, nil)
+		resp := sut.RouteVerifyRecordingRulesSettings(ctx)
+		var res definitions.RecordingRulesSettingsVerification
+		if resp.Status() == http.StatusOK {
+			require.NoError(t, json.Unmarshal(resp.Body(), &res))
+		}
+		return resp.Status(), res
+	}
+	newSut := func(settings setting.RecordingRuleSettings) ConfigSrv {
+		sut := createAPIAdminSut(t, nil, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRules))
+		sut.recordingRules = settings
+		sut.decryptFn = func(_ context.Context, payload []byte) ([]byte, error) { return payload, nil }
+		sut.log = log.NewNopLogger()
+		return sut
+	}
+
+	t.Run("recording rules must be enabled", func(t *testing.T) {
+		sut := createAPIAdminSut(t, nil, featuremgmt.WithFeatures())
+		status, _ := request(t, sut, "/api/v1/ngalert/recording_rules/verify")
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("reports invalid settings", func(t *testing.T) {
+		sut := newSut(setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{URL: "not a url", Timeout: time.Second},
+		})
+		status, res := request(t, sut, "/api/v1/ngalert/recording_rules/verify")
+		require.Equal(t, http.StatusOK, status)
+		require.False(t, res.Valid)
+		require.Len(t, res.Errors, 1)
+		require.Equal(t, "url", res.Errors[0].Field)
+	})
+
+	t.Run("sends test writes", func(t *testing.T) {
+		sut := newSut(setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{URL: server.URL, Timeout: time.Second},
+		})
+		status, res := request(t, sut, "/api/v1/ngalert/recording_rules/verify?write=true")
+		require.Equal(t, http.StatusOK, status)
+		require.True(t, res.Valid)
+		require.Len(t, res.Targets, 1)
+		require.True(t, res.Targets[0].Written)
+		require.Positive(t, res.Targets[0].EncodedBytes)
+		require.Empty(t, res.Targets[0].Error)
+	})
+}
+
+func TestRouteRecordingRuleCapture(t *testing.T) {
+	receiver := writertest.NewReceiver(t)
+	w, err := writer.NewTargetsWriter(setting.RecordingRuleSettings{RecordingRuleTargetSettings: receiver.Settings()}, nil, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	capture := writer.NewPayloadCapture(w)
+
+	sut := createAPIAdminSut(t, nil, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRules))
+	sut.recordingCapture = capture
+	request := func(t *testing.T, method, target string) (int, definitions.RecordingRuleCapture) {
+		t.Helper()
+		ctx := createRequestCtxInOrg(1)
+		ctx.Req = httptest.NewRequest(method, target, nil)
+		var resp response.Response
+		if method == http.MethodPost {
+			resp = sut.RoutePostRecordingRuleCapture(ctx, "rule")
+		} else {
+			resp = sut.RouteGetRecordingRuleCapture(ctx, "rule")
+		}
+		var res definitions.RecordingRuleCapture
+		if resp.Status()/100 == 2 {
+			require.NoError(t, json.Unmarshal(resp.Body(), &res))
+		}
+		return resp.Status(), res
+	}
+
+	status, _ := request(t, http.MethodGet, "/api/v1/ngalert/recording_rules/rule/capture")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = request(t, http.MethodPost, "/api/v1/ngalert/recording_rules/rule/capture?count=1000")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, res := request(t, http.MethodPost, "/api/v1/ngalert/recording_rules/rule/capture?count=2")
+	require.Equal(t, http.StatusAccepted, status)
+	require.Equal(t, 2, res.Remaining)
+
+	now := time.Unix(1700000000, 0)
+	frame := data.NewFrame("",
+		data.NewField("T", nil, []time.Time{now}),
+		data.NewField("value", data.Labels{"instance": "a"}, []float64{math.NaN()}),
+	)
+	frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: data.FrameTypeVersion{0, 1}})
+	ctx := ngmodels.WithRuleKey(context.Background(), ngmodels.AlertRuleKey{OrgID: 1, UID: "rule"})
+	require.NoError(t, capture.Write(ctx, "", "test_metric", now, data.Frames{frame}, nil))
+	require.Empty(t, receiver.Requests())
+
+	status, res = request(t, http.MethodGet, "/api/v1/ngalert/recording_rules/rule/capture")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, 1, res.Remaining)
+	require.Len(t, res.Writes, 1)
+	require.Equal(t, "test_metric", res.Writes[0].Metric)
+	require.Len(t, res.Writes[0].Requests, 1)
+	require.Equal(t, []definitions.RecordingRuleCapturedSeries{{
+		Labels:  map[string]string{"__name__": "test_metric", "instance": "a"},
+		Samples: []definitions.RecordingRuleCapturedSample{{Timestamp: now.UTC(), Value: "NaN"}},
+	}}, res.Writes[0].Requests[0].Series)
+
+	t.Run("results of recording rules must be written", func(t *testing.T) {
+		sut.recordingCapture = nil
+		status, _ := request(t, http.MethodPost, "/api/v1/ngalert/recording_rules/rule/capture")
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+}
+
+func TestRouteGetRecordingRulesTargetSchema(t *testing.T) {
+	t.Run("recording rules must be enabled", func(t *testing.T) {
+		sut := createAPIAdminSut(t, nil, featuremgmt.WithFeatures())
+		resp := sut.RouteGetRecordingRulesTargetSchema(createRequestCtxInOrg(1))
+		require.Equal(t, http.StatusBadRequest, resp.Status())
+	})
+
+	sut := createAPIAdminSut(t, nil, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRules))
+	resp := sut.RouteGetRecordingRulesTargetSchema(createRequestCtxInOrg(1))
+	require.Equal(t, http.StatusOK, resp.Status())
+	var schema definitions.RecordingRulesTargetSchema
+	require.NoError(t, json.Unmarshal(resp.Body(), &schema))
+	require.Equal(t, writer.SchemaDialect, schema["$schema"])
+	require.Equal(t, []any{"url"}, schema["required"])
+	require.Contains(t, schema["properties"], "auth_type")
+}
+
+func TestRouteGetRecordedMetrics(t *testing.T) {
+	catalog := writer.NewMetricCatalog(writer.NoopWriter{})
+	ruleStore := fakes.NewRuleStore(t)
+	ruleStore.PutRule(context.Background(),
+		&ngmodels.AlertRule{OrgID: 1, UID: "visible", Title: "Visible", NamespaceUID: "folder"},
+		&ngmodels.AlertRule{OrgID: 1, UID: "hidden", Title: "Hidden", NamespaceUID: "other-folder"},
+	)
+	// The user cannot read the rules of other-folder
+	ruleStore.Folders[1] = ruleStore.Folders[1][:1]
+
+	sut := createAPIAdminSut(t, nil, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRules))
+	sut.recordedMetrics = catalog
+	sut.ruleStore = ruleStore
+	request := func(t *testing.T) definitions.RecordedMetrics {
+		t.Helper()
+		ctx := createRequestCtxInOrg(1)
+		resp := sut.RouteGetRecordedMetrics(ctx)
+		require.Equal(t, http.StatusOK, resp.Status())
+		var res definitions.RecordedMetrics
+		require.NoError(t, json.Unmarshal(resp.Body(), &res))
+		return res
+	}
+	require.Empty(t, request(t).Metrics)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	frame := data.NewFrame("",
+		data.NewField("T", nil, []time.Time{now}),
+		data.NewField("value", data.Labels{"instance": "a"}, []float64{1}),
+	)
+	frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: data.FrameTypeVersion{0, 1}})
+	write := func(uid, name string) {
+		ctx := ngmodels.WithRuleKey(context.Background(), ngmodels.AlertRuleKey{OrgID: 1, UID: uid})
+		require.NoError(t, catalog.Write(ctx, "", name, now, data.Frames{frame}, map[string]string{"team": "web"}))
+	}
+	write("visible", "shared_metric")
+	write("hidden", "shared_metric")
+	write("hidden", "hidden_metric")
+	write("deleted", "deleted_metric")
+
+	require.Equal(t, []definitions.RecordedMetric{{
+		Metric: "shared_metric",
+		Labels: []string{"instance", "team"},
+		Rules:  []definitions.RecordedMetricRule{{UID: "visible", Title: "Visible", FolderUID: "folder", LastWrite: now, Series: 1}},
+	}}, request(t).Metrics)
+
+	t.Run("results of recording rules must be written", func(t *testing.T) {
+		sut.recordedMetrics = nil
+		require.Equal(t, http.StatusBadRequest, sut.RouteGetRecordedMetrics(createRequestCtxInOrg(1)).Status())
+	})
+}
+
 func createAPIAdminSut(t *testing.T,
 	datasources []*datasources.DataSource, features featuremgmt.FeatureToggles) ConfigSrv {
 	return ConfigSrv{
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -11,6 +12,7 @@ import (
 	apimodels "github.com/grafana/grafana/pkg/services/ngalert/api/tooling/definitions"
 	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/store"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/setting"
 	prommodels "github.com/prometheus/common/model"
 )
@@ -22,6 +24,8 @@ type RuleLimits struct {
 	BaseInterval time.Duration
 	// Whether recording rules are allowed.
 	RecordingRulesAllowed bool
+	// The names of the targets recording rules can write to, besides the default one.
+	RecordingRuleTargets []string
 }
 
 func RuleLimitsFromConfig(cfg *setting.UnifiedAlertingSettings, toggles featuremgmt.FeatureToggles) RuleLimits {
@@ -29,9 +33,18 @@ func RuleLimitsFromConfig(cfg *setting.UnifiedAlertingSettings, toggles featurem
 		DefaultRuleEvaluationInterval: cfg.DefaultRuleEvaluationInterval,
 		BaseInterval:                  cfg.BaseInterval,
 		RecordingRulesAllowed:         toggles.IsEnabledGlobally(featuremgmt.FlagGrafanaManagedRecordingRules),
+		RecordingRuleTargets:          recordingRuleTargetNames(cfg.RecordingRules),
 	}
 }
 
+func recordingRuleTargetNames(settings setting.RecordingRuleSettings) []string {
+	names := make([]string, 0, len(settings.Targets))
+	for _, target := range settings.Targets {
+		names = append(names, target.Name)
+	}
+	return names
+}
+
 // validateRuleNode validates API model (definitions.PostableExtendedRuleNode) and converts it to models.AlertRule
 func validateRuleNode(
 	ruleNode *apimodels.PostableExtendedRuleNode,
@@ -171,7 +184,20 @@ func validateRecordingRuleFields(in *apimodels.PostableExtendedRuleNode, newRule
 	if !prommodels.IsValidMetricName(metricName) {
 		return ngmodels.AlertRule{}, fmt.Errorf("%w: %s", ngmodels.ErrAlertRuleFailedValidation, "metric name for recording rule must be a valid Prometheus metric name")
 	}
+	if target := in.GrafanaManagedAlert.Record.Target; target != "" && !slices.Contains(limits.RecordingRuleTargets, target) {
+		return ngmodels.AlertRule{}, fmt.Errorf("%w: recording rule target %q is not configured", ngmodels.ErrAlertRuleFailedValidation, target)
+	}
 	newRule.Record = ModelRecordFromApiRecord(in.GrafanaManagedAlert.Record)
+	if filter := newRule.Record.Filter; filter != nil {
+		for _, refID := range filter.RefIDs {
+			if err := validateCondition(refID, in.GrafanaManagedAlert.Data, canPatch); err != nil {
+				return ngmodels.AlertRule{}, fmt.Errorf("%w: recording rule filter: %s", ngmodels.ErrAlertRuleFailedValidation, err.Error())
+			}
+		}
+		if _, err := writer.NewFrameFilter(filter); err != nil {
+			return ngmodels.AlertRule{}, fmt.Errorf("%w: recording rule filter: %s", ngmodels.ErrAlertRuleFailedValidation, err.Error())
+		}
+	}
 
 	newRule.NoDataState = ""
 	newRule.ExecErrState = ""
@@ -449,6 +449,48 @@ func TestValidateRuleNode_NoUID(t *testing.T) {
 				require.Equal(t, api.GrafanaManagedAlert.Record.Metric, alert.Record.Metric)
 			},
 		},
+		{
+			name: "accepts recording rule writing to a configured target",
+			limits: func() *RuleLimits {
+				lim := allowRecording(limits)
+				lim.RecordingRuleTargets = []string{"mimir"}
+				return lim
+			}(),
+			rule: func() *apimodels.PostableExtendedRuleNode {
+				r := validRule()
+				r.GrafanaManagedAlert.Record = &apimodels.Record{Metric: "some_metric", From: "A", Target: "mimir"}
+				r.GrafanaManagedAlert.Condition = ""
+				r.GrafanaManagedAlert.NoDataState = ""
+				r.GrafanaManagedAlert.ExecErrState = ""
+				r.GrafanaManagedAlert.NotificationSettings = nil
+				r.ApiRuleNode.For = nil
+				return &r
+			},
+			assert: func(t *testing.T, api *apimodels.PostableExtendedRuleNode, alert *models.AlertRule) {
+				require.Equal(t, "mimir", alert.Record.Target)
+			},
+		},
+		{
+			name:   "accepts recording rule with a filter of the frames written",
+			limits: allowRecording(limits),
+			rule: func() *apimodels.PostableExtendedRuleNode {
+				r := validRule()
+				r.GrafanaManagedAlert.Record = &apimodels.Record{Metric: "some_metric", From: "A", Filter: &apimodels.RecordFilter{
+					RefIDs:   []string{"A"},
+					Fields:   "requests_.*",
+					Matchers: `{job="api"}`,
+				}}
+				r.GrafanaManagedAlert.Condition = ""
+				r.GrafanaManagedAlert.NoDataState = ""
+				r.GrafanaManagedAlert.ExecErrState = ""
+				r.GrafanaManagedAlert.NotificationSettings = nil
+				r.ApiRuleNode.For = nil
+				return &r
+			},
+			assert: func(t *testing.T, api *apimodels.PostableExtendedRuleNode, alert *models.AlertRule) {
+				require.Equal(t, &models.RecordFilter{RefIDs: []string{"A"}, Fields: "requests_.*", Matchers: `{job="api"}`}, alert.Record.Filter)
+			},
+		},
 		{
 			name:   "recording rules ignore fields that only make sense for Alerting rules",
 			limits: allowRecording(limits),
@@ -690,6 +732,51 @@ func TestValidateRuleNodeFailures_NoUID(t *testing.T) {
 			},
 			expErr: "NOTEXIST does not exist",
 		},
+		{
+			name:   "rejects recording rule with target not configured",
+			limits: allowRecording(limits),
+			rule: func() *apimodels.PostableExtendedRuleNode {
+				r := validRule()
+				r.GrafanaManagedAlert.Record = &apimodels.Record{Metric: "my_metric", From: "A", Target: "unknown"}
+				r.GrafanaManagedAlert.Condition = ""
+				r.GrafanaManagedAlert.NoDataState = ""
+				r.GrafanaManagedAlert.ExecErrState = ""
+				r.GrafanaManagedAlert.NotificationSettings = nil
+				r.ApiRuleNode.For = nil
+				return &r
+			},
+			expErr: `target "unknown" is not configured`,
+		},
+		{
+			name:   "rejects recording rule with a filter of unknown refIDs",
+			limits: allowRecording(limits),
+			rule: func() *apimodels.PostableExtendedRuleNode {
+				r := validRule()
+				r.GrafanaManagedAlert.Record = &apimodels.Record{Metric: "my_metric", From: "A", Filter: &apimodels.RecordFilter{RefIDs: []string{"NOTEXIST"}}}
+				r.GrafanaManagedAlert.Condition = ""
+				r.GrafanaManagedAlert.NoDataState = ""
+				r.GrafanaManagedAlert.ExecErrState = ""
+				r.GrafanaManagedAlert.NotificationSettings = nil
+				r.ApiRuleNode.For = nil
+				return &r
+			},
+			expErr: "NOTEXIST does not exist",
+		},
+		{
+			name:   "rejects recording rule with invalid label matchers",
+			limits: allowRecording(limits),
+			rule: func() *apimodels.PostableExtendedRuleNode {
+				r := validRule()
+				r.GrafanaManagedAlert.Record = &apimodels.Record{Metric: "my_metric", From: "A", Filter: &apimodels.RecordFilter{Matchers: `{job=}`}}
+				r.GrafanaManagedAlert.Condition = ""
+				r.GrafanaManagedAlert.NoDataState = ""
+				r.GrafanaManagedAlert.ExecErrState = ""
+				r.GrafanaManagedAlert.NotificationSettings = nil
+				r.ApiRuleNode.For = nil
+				return &r
+			},
+			expErr: "invalid label matchers",
+		},
 	}
 
 	for _, testCase := range testCases {
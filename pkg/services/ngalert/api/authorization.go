@@ -88,6 +88,11 @@ func (api *API) authorize(method, path string) web.Handler {
 	case http.MethodGet + "/api/prometheus/grafana/api/v1/rules":
 		eval = ac.EvalPermission(ac.ActionAlertingRuleRead)
 
+	// The metrics recorded by the rules the user can read
+	case http.MethodGet + "/api/v1/ngalert/recording_rules/metrics":
+		// additional authorization is done in the request handler
+		eval = ac.EvalPermission(ac.ActionAlertingRuleRead)
+
 	// Grafana Rules Testing Paths
 	case http.MethodPost + "/api/v1/rule/test/grafana":
 		// additional authorization is done in the request handler
@@ -243,6 +248,13 @@ func (api *API) authorize(method, path string) web.Handler {
 		http.MethodGet + "/api/v1/ngalert/alertmanagers":
 		return middleware.ReqOrgAdmin
 
+	// The settings of recording rules are the ones of the instance
+	case http.MethodPost + "/api/v1/ngalert/recording_rules/verify",
+		http.MethodGet + "/api/v1/ngalert/recording_rules/schema",
+		http.MethodPost + "/api/v1/ngalert/recording_rules/{RuleUID}/capture",
+		http.MethodGet + "/api/v1/ngalert/recording_rules/{RuleUID}/capture":
+		return middleware.ReqGrafanaAdmin
+
 	// Grafana-only Provisioning Read Paths
 	case http.MethodGet + "/api/v1/provisioning/policies/export",
 		http.MethodGet + "/api/v1/provisioning/contact-points/export",
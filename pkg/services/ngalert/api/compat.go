@@ -460,28 +460,51 @@ func AlertRuleRecordExportFromRecord(r *models.Record) *definitions.AlertRuleRec
 	if r == nil {
 		return nil
 	}
-	return &definitions.AlertRuleRecordExport{
+	export := &definitions.AlertRuleRecordExport{
 		Metric: r.Metric,
 		From:   r.From,
 	}
+	if r.Target != "" {
+		export.Target = &r.Target
+	}
+	if r.Filter != nil {
+		export.Filter = &definitions.AlertRuleRecordFilterExport{RefIDs: r.Filter.RefIDs}
+		if r.Filter.Fields != "" {
+			export.Filter.Fields = &r.Filter.Fields
+		}
+		if r.Filter.Matchers != "" {
+			export.Filter.Matchers = &r.Filter.Matchers
+		}
+	}
+	return export
 }
 
 func ModelRecordFromApiRecord(r *definitions.Record) *models.Record {
 	if r == nil {
 		return nil
 	}
-	return &models.Record{
+	record := &models.Record{
 		Metric: r.Metric,
 		From:   r.From,
+		Target: r.Target,
+	}
+	if r.Filter != nil {
+		record.Filter = &models.RecordFilter{RefIDs: r.Filter.RefIDs, Fields: r.Filter.Fields, Matchers: r.Filter.Matchers}
 	}
+	return record
 }
 
 func ApiRecordFromModelRecord(r *models.Record) *definitions.Record {
 	if r == nil {
 		return nil
 	}
-	return &definitions.Record{
+	record := &definitions.Record{
 		Metric: r.Metric,
 		From:   r.From,
+		Target: r.Target,
+	}
+	if r.Filter != nil {
+		record.Filter = &definitions.RecordFilter{RefIDs: r.Filter.RefIDs, Fields: r.Filter.Fields, Matchers: r.Filter.Matchers}
 	}
+	return record
 }
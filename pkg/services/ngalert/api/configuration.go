@@ -36,3 +36,23 @@ func (f *ConfigurationApiHandler) handleRouteDeleteNGalertConfig(c *contextmodel
 func (f *ConfigurationApiHandler) handleRouteGetStatus(c *contextmodel.ReqContext) response.Response {
 	return f.grafana.RouteGetAlertingStatus(c)
 }
+
+func (f *ConfigurationApiHandler) handleRouteVerifyRecordingRulesSettings(c *contextmodel.ReqContext) response.Response {
+	return f.grafana.RouteVerifyRecordingRulesSettings(c)
+}
+
+func (f *ConfigurationApiHandler) handleRouteGetRecordingRulesTargetSchema(c *contextmodel.ReqContext) response.Response {
+	return f.grafana.RouteGetRecordingRulesTargetSchema(c)
+}
+
+func (f *ConfigurationApiHandler) handleRouteGetRecordedMetrics(c *contextmodel.ReqContext) response.Response {
+	return f.grafana.RouteGetRecordedMetrics(c)
+}
+
+func (f *ConfigurationApiHandler) handleRoutePostRecordingRuleCapture(c *contextmodel.ReqContext, ruleUID string) response.Response {
+	return f.grafana.RoutePostRecordingRuleCapture(c, ruleUID)
+}
+
+func (f *ConfigurationApiHandler) handleRouteGetRecordingRuleCapture(c *contextmodel.ReqContext, ruleUID string) response.Response {
+	return f.grafana.RouteGetRecordingRuleCapture(c, ruleUID)
+}
@@ -23,8 +23,13 @@ type ConfigurationApi interface {
 	RouteDeleteNGalertConfig(*contextmodel.ReqContext) response.Response
 	RouteGetAlertmanagers(*contextmodel.ReqContext) response.Response
 	RouteGetNGalertConfig(*contextmodel.ReqContext) response.Response
+	RouteGetRecordedMetrics(*contextmodel.ReqContext) response.Response
+	RouteGetRecordingRuleCapture(*contextmodel.ReqContext) response.Response
+	RouteGetRecordingRulesTargetSchema(*contextmodel.ReqContext) response.Response
 	RouteGetStatus(*contextmodel.ReqContext) response.Response
 	RoutePostNGalertConfig(*contextmodel.ReqContext) response.Response
+	RoutePostRecordingRuleCapture(*contextmodel.ReqContext) response.Response
+	RouteVerifyRecordingRulesSettings(*contextmodel.ReqContext) response.Response
 }
 
 func (f *ConfigurationApiHandler) RouteDeleteNGalertConfig(ctx *contextmodel.ReqContext) response.Response {
@@ -36,6 +41,17 @@ func (f *ConfigurationApiHandler) RouteGetAlertmanagers(ctx *contextmodel.ReqCon
 func (f *ConfigurationApiHandler) RouteGetNGalertConfig(ctx *contextmodel.ReqContext) response.Response {
 	return f.handleRouteGetNGalertConfig(ctx)
 }
+func (f *ConfigurationApiHandler) RouteGetRecordedMetrics(ctx *contextmodel.ReqContext) response.Response {
+	return f.handleRouteGetRecordedMetrics(ctx)
+}
+func (f *ConfigurationApiHandler) RouteGetRecordingRuleCapture(ctx *contextmodel.ReqContext) response.Response {
+	// Parse Path Parameters
+	ruleUIDParam := web.Params(ctx.Req)[":RuleUID"]
+	return f.handleRouteGetRecordingRuleCapture(ctx, ruleUIDParam)
+}
+func (f *ConfigurationApiHandler) RouteGetRecordingRulesTargetSchema(ctx *contextmodel.ReqContext) response.Response {
+	return f.handleRouteGetRecordingRulesTargetSchema(ctx)
+}
 func (f *ConfigurationApiHandler) RouteGetStatus(ctx *contextmodel.ReqContext) response.Response {
 	return f.handleRouteGetStatus(ctx)
 }
@@ -47,6 +63,14 @@ func (f *ConfigurationApiHandler) RoutePostNGalertConfig(ctx *contextmodel.ReqCo
 	}
 	return f.handleRoutePostNGalertConfig(ctx, conf)
 }
+func (f *ConfigurationApiHandler) RoutePostRecordingRuleCapture(ctx *contextmodel.ReqContext) response.Response {
+	// Parse Path Parameters
+	ruleUIDParam := web.Params(ctx.Req)[":RuleUID"]
+	return f.handleRoutePostRecordingRuleCapture(ctx, ruleUIDParam)
+}
+func (f *ConfigurationApiHandler) RouteVerifyRecordingRulesSettings(ctx *contextmodel.ReqContext) response.Response {
+	return f.handleRouteVerifyRecordingRulesSettings(ctx)
+}
 
 func (api *API) RegisterConfigurationApiEndpoints(srv ConfigurationApi, m *metrics.API) {
 	api.RouteRegister.Group("", func(group routing.RouteRegister) {
@@ -86,6 +110,42 @@ func (api *API) RegisterConfigurationApiEndpoints(srv ConfigurationApi, m *metri
 				m,
 			),
 		)
+		group.Get(
+			toMacaronPath("/api/v1/ngalert/recording_rules/metrics"),
+			requestmeta.SetOwner(requestmeta.TeamAlerting),
+			requestmeta.SetSLOGroup(requestmeta.SLOGroupHighSlow),
+			api.authorize(http.MethodGet, "/api/v1/ngalert/recording_rules/metrics"),
+			metrics.Instrument(
+				http.MethodGet,
+				"/api/v1/ngalert/recording_rules/metrics",
+				api.Hooks.Wrap(srv.RouteGetRecordedMetrics),
+				m,
+			),
+		)
+		group.Get(
+			toMacaronPath("/api/v1/ngalert/recording_rules/{RuleUID}/capture"),
+			requestmeta.SetOwner(requestmeta.TeamAlerting),
+			requestmeta.SetSLOGroup(requestmeta.SLOGroupHighSlow),
+			api.authorize(http.MethodGet, "/api/v1/ngalert/recording_rules/{RuleUID}/capture"),
+			metrics.Instrument(
+				http.MethodGet,
+				"/api/v1/ngalert/recording_rules/{RuleUID}/capture",
+				api.Hooks.Wrap(srv.RouteGetRecordingRuleCapture),
+				m,
+			),
+		)
+		group.Get(
+			toMacaronPath("/api/v1/ngalert/recording_rules/schema"),
+			requestmeta.SetOwner(requestmeta.TeamAlerting),
+			requestmeta.SetSLOGroup(requestmeta.SLOGroupHighSlow),
+			api.authorize(http.MethodGet, "/api/v1/ngalert/recording_rules/schema"),
+			metrics.Instrument(
+				http.MethodGet,
+				"/api/v1/ngalert/recording_rules/schema",
+				api.Hooks.Wrap(srv.RouteGetRecordingRulesTargetSchema),
+				m,
+			),
+		)
 		group.Get(
 			toMacaronPath("/api/v1/ngalert"),
 			requestmeta.SetOwner(requestmeta.TeamAlerting),
@@ -110,5 +170,29 @@ func (api *API) RegisterConfigurationApiEndpoints(srv ConfigurationApi, m *metri
 				m,
 			),
 		)
+		group.Post(
+			toMacaronPath("/api/v1/ngalert/recording_rules/{RuleUID}/capture"),
+			requestmeta.SetOwner(requestmeta.TeamAlerting),
+			requestmeta.SetSLOGroup(requestmeta.SLOGroupHighSlow),
+			api.authorize(http.MethodPost, "/api/v1/ngalert/recording_rules/{RuleUID}/capture"),
+			metrics.Instrument(
+				http.MethodPost,
+				"/api/v1/ngalert/recording_rules/{RuleUID}/capture",
+				api.Hooks.Wrap(srv.RoutePostRecordingRuleCapture),
+				m,
+			),
+		)
+		group.Post(
+			toMacaronPath("/api/v1/ngalert/recording_rules/verify"),
+			requestmeta.SetOwner(requestmeta.TeamAlerting),
+			requestmeta.SetSLOGroup(requestmeta.SLOGroupHighSlow),
+			api.authorize(http.MethodPost, "/api/v1/ngalert/recording_rules/verify"),
+			metrics.Instrument(
+				http.MethodPost,
+				"/api/v1/ngalert/recording_rules/verify",
+				api.Hooks.Wrap(srv.RouteVerifyRecordingRulesSettings),
+				m,
+			),
+		)
 	}, middleware.ReqSignedIn)
 }
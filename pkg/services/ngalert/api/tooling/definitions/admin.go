@@ -1,6 +1,8 @@
 package definitions
 
 import (
+	"time"
+
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
@@ -58,6 +60,92 @@ import (
 //       200: Ack
 //       500: Failure
 
+// swagger:route POST /v1/ngalert/recording_rules/verify configuration RouteVerifyRecordingRulesSettings
+//
+// Validates the settings of the targets the results of recording rules are written to, creates their clients and
+// encodes a sample write request for each of them. With write, a test write request is also sent to each of them.
+//
+//     Produces:
+//     - application/json
+//
+//     Responses:
+//       200: RecordingRulesSettingsVerification
+//       400: ValidationError
+
+// swagger:parameters RouteVerifyRecordingRulesSettings
+type VerifyRecordingRulesSettingsParams struct {
+	// Whether a test write request is sent to the targets, only encoded otherwise.
+	// in:query
+	// required:false
+	// default:false
+	Write bool `json:"write"`
+}
+
+// swagger:route GET /v1/ngalert/recording_rules/schema configuration RouteGetRecordingRulesTargetSchema
+//
+// Returns the JSON Schema of the settings of the targets the results of recording rules are written to, the keys of
+// their sections of the configuration, with the types of targets and the auth types of the instance.
+//
+//     Produces:
+//     - application/json
+//
+//     Responses:
+//       200: RecordingRulesTargetSchema
+//       400: ValidationError
+
+// swagger:route POST /v1/ngalert/recording_rules/{RuleUID}/capture configuration RoutePostRecordingRuleCapture
+//
+// Captures the next writes of a recording rule instead of sending them to their target. Their write requests are
+// encoded as they would be sent, and decoded back to the labels and values of their series. The writes of the rule
+// captured before are discarded.
+//
+//     Produces:
+//     - application/json
+//
+//     Responses:
+//       202: RecordingRuleCapture
+//       400: ValidationError
+
+// swagger:route GET /v1/ngalert/recording_rules/{RuleUID}/capture configuration RouteGetRecordingRuleCapture
+//
+// Returns the writes of a recording rule captured since their capture was started.
+//
+//     Produces:
+//     - application/json
+//
+//     Responses:
+//       200: RecordingRuleCapture
+//       400: ValidationError
+//       404: NotFound
+
+// swagger:route GET /v1/ngalert/recording_rules/metrics configuration RouteGetRecordedMetrics
+//
+// Returns the metrics written by the recording rules of the organization the user can read, with the names of their
+// labels and the rules writing them. Only the metrics written since Grafana started, in the last 24 hours, are returned.
+//
+//     Produces:
+//     - application/json
+//
+//     Responses:
+//       200: RecordedMetrics
+//       400: ValidationError
+
+// swagger:parameters RoutePostRecordingRuleCapture RouteGetRecordingRuleCapture
+type RecordingRuleCaptureParams struct {
+	// The UID of the recording rule
+	// in:path
+	RuleUID string
+}
+
+// swagger:parameters RoutePostRecordingRuleCapture
+type PostRecordingRuleCaptureParams struct {
+	// The number of writes to capture, at most 100.
+	// in:query
+	// required:false
+	// default:1
+	Count int `json:"count"`
+}
+
 // swagger:parameters RoutePostNGalertConfig
 type NGalertConfig struct {
 	// in:body
@@ -95,3 +183,105 @@ type AlertingStatus struct {
 	AlertmanagersChoice      AlertmanagersChoice `json:"alertmanagersChoice"`
 	NumExternalAlertmanagers int                 `json:"numExternalAlertmanagers"`
 }
+
+// swagger:model
+type RecordingRulesSettingsVerification struct {
+	// Whether the settings are valid and all the targets could be written to.
+	Valid bool `json:"valid"`
+	// The problems of the settings, the targets are not verified when there are any.
+	Errors  []RecordingRulesSettingError       `json:"errors,omitempty"`
+	Targets []RecordingRulesTargetVerification `json:"targets,omitempty"`
+}
+
+// swagger:model
+type RecordingRulesSettingError struct {
+	// The key of the setting in the recording_rules sections.
+	// example: target.mimir.url
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// swagger:model
+type RecordingRulesTargetVerification struct {
+	// Name of the target, empty for the default one.
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Size in bytes of the sample write request encoded for the target.
+	EncodedBytes int `json:"encodedBytes"`
+	// Whether a test write request was sent to the target.
+	Written bool `json:"written"`
+	// Why the target could not be written to: dns, tls, auth or connection.
+	Failure string `json:"failure,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// A JSON Schema, of the 2020-12 dialect.
+// swagger:model
+type RecordingRulesTargetSchema map[string]interface{}
+
+// swagger:model
+type RecordingRuleCapture struct {
+	// The number of writes that remain to be captured.
+	Remaining int                          `json:"remaining"`
+	Writes    []RecordingRuleCapturedWrite `json:"writes"`
+}
+
+// swagger:model
+type RecordingRuleCapturedWrite struct {
+	// Name of the target, empty for the default one.
+	Target string `json:"target"`
+	Metric string `json:"metric"`
+	// The time of the evaluation whose results were written.
+	Time time.Time `json:"time"`
+	// The write requests that would have been sent, one for each batch.
+	Requests []RecordingRuleCapturedRequest `json:"requests"`
+	// Why nothing would have been sent.
+	Error string `json:"error,omitempty"`
+}
+
+// swagger:model
+type RecordingRuleCapturedRequest struct {
+	// Size in bytes of the encoded write request.
+	EncodedBytes int                           `json:"encodedBytes"`
+	Series       []RecordingRuleCapturedSeries `json:"series"`
+}
+
+// swagger:model
+type RecordingRuleCapturedSeries struct {
+	Labels  map[string]string             `json:"labels"`
+	Samples []RecordingRuleCapturedSample `json:"samples"`
+}
+
+// swagger:model
+type RecordingRuleCapturedSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	// The value formatted as in the responses of the Prometheus API, as it may not be a number.
+	Value string `json:"value"`
+}
+
+// swagger:model
+type RecordedMetrics struct {
+	Metrics []RecordedMetric `json:"metrics"`
+}
+
+// swagger:model
+type RecordedMetric struct {
+	// Name of the target, empty for the default one.
+	Target string `json:"target"`
+	Metric string `json:"metric"`
+	// The names of the labels of its series.
+	Labels []string `json:"labels"`
+	// The recording rules writing the metric.
+	Rules []RecordedMetricRule `json:"rules"`
+}
+
+// swagger:model
+type RecordedMetricRule struct {
+	UID       string `json:"uid"`
+	Title     string `json:"title"`
+	FolderUID string `json:"folderUid"`
+	// The time of the evaluation of the last write of the metric by the rule.
+	LastWrite time.Time `json:"lastWrite"`
+	// The number of series of the last write.
+	Series int `json:"series"`
+}
@@ -487,6 +487,25 @@ type Record struct {
 	// required: true
 	// example: A
 	From string `json:"from" yaml:"from"`
+	// Name of the target the recorded metric is written to, as configured in the recording_rules.target sections.
+	// The default target is used when it is empty.
+	// example: mimir
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	// Selects the frames and fields of the results that are written. All of them are written when it is not set.
+	Filter *RecordFilter `json:"filter,omitempty" yaml:"filter,omitempty"`
+}
+
+// swagger:model
+type RecordFilter struct {
+	// The queries and expressions whose results are written, instead of the one of from.
+	// example: ["A", "B"]
+	RefIDs []string `json:"refIds,omitempty" yaml:"refIds,omitempty"`
+	// Regular expression the names of the value fields written must fully match.
+	// example: requests_.*
+	Fields string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Label matchers the series written must match.
+	// example: {job="api"}
+	Matchers string `json:"matchers,omitempty" yaml:"matchers,omitempty"`
 }
 
 // swagger:model
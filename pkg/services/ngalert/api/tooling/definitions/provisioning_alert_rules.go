@@ -307,6 +307,15 @@ type AlertRuleNotificationSettingsExport struct {
 
 // Record is the provisioned export of models.Record.
 type AlertRuleRecordExport struct {
-	Metric string `json:"metric" yaml:"metric" hcl:"metric"`
-	From   string `json:"from" yaml:"from" hcl:"from"`
+	Metric string                       `json:"metric" yaml:"metric" hcl:"metric"`
+	From   string                       `json:"from" yaml:"from" hcl:"from"`
+	Target *string                      `json:"target,omitempty" yaml:"target,omitempty" hcl:"target,optional"`
+	Filter *AlertRuleRecordFilterExport `json:"filter,omitempty" yaml:"filter,omitempty" hcl:"filter,block"`
+}
+
+// AlertRuleRecordFilterExport is the provisioned export of models.RecordFilter.
+type AlertRuleRecordFilterExport struct {
+	RefIDs   []string `json:"refIds,omitempty" yaml:"refIds,omitempty" hcl:"ref_ids,optional"`
+	Fields   *string  `json:"fields,omitempty" yaml:"fields,omitempty" hcl:"fields,optional"`
+	Matchers *string  `json:"matchers,omitempty" yaml:"matchers,omitempty" hcl:"matchers,optional"`
 }
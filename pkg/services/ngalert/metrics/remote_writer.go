@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type RemoteWriter struct {
+	ConnectionsTotal *prometheus.CounterVec
+	OpenConnections  *prometheus.GaugeVec
+	// The sizes of the write requests, before and after their compression
+	RequestUncompressedBytes *prometheus.HistogramVec
+	RequestCompressedBytes   *prometheus.HistogramVec
+}
+
+func NewRemoteWriterMetrics(r prometheus.Registerer) *RemoteWriter {
+	return &RemoteWriter{
+		ConnectionsTotal: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "remote_writer_connections_total",
+			Help:      "The total number of connections the write requests of recording rules were sent on, by whether they were reused from the idle pool.",
+		}, []string{"target", "reused"}),
+		OpenConnections: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "remote_writer_open_connections",
+			Help:      "The number of open connections to the targets of recording rules, in use or idle.",
+		}, []string{"target"}),
+		RequestUncompressedBytes: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "remote_writer_request_uncompressed_bytes",
+			Help:      "The size of the write requests of recording rules before their compression.",
+			Buckets:   requestSizeBuckets,
+		}, []string{"target"}),
+		RequestCompressedBytes: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: Subsystem,
+			Name:      "remote_writer_request_compressed_bytes",
+			Help:      "The size of the write requests of recording rules sent to their targets, once compressed.",
+			Buckets:   requestSizeBuckets,
+		}, []string{"target"}),
+	}
+}
+
+// requestSizeBuckets are the buckets of the sizes of write requests, from 1KiB to 64MiB
+var requestSizeBuckets = prometheus.ExponentialBuckets(1<<10, 4, 9)
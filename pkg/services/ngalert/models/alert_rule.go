@@ -814,6 +814,21 @@ type Record struct {
 	Metric string
 	// From contains a query RefID, indicating which expression node is the output of the recording rule.
 	From string
+	// Target is the name of the target the results are written to, the default one when empty.
+	Target string `json:",omitempty"`
+	// Filter selects the frames and fields of the results that are written, all of them when nil.
+	Filter *RecordFilter `json:",omitempty"`
+}
+
+// RecordFilter selects the frames and fields of the results of a recording rule that are written,
+// so a query can drive a rule without all of its series being recorded.
+type RecordFilter struct {
+	// RefIDs are the queries and expressions whose frames are written, instead of the one of From.
+	RefIDs []string `json:",omitempty"`
+	// Fields is a regular expression the names of the value fields written must fully match.
+	Fields string `json:",omitempty"`
+	// Matchers are the label matchers the series written must match, like {job="api"}.
+	Matchers string `json:",omitempty"`
 }
 
 func (r *Record) Fingerprint() data.Fingerprint {
@@ -828,5 +843,15 @@ func (r *Record) Fingerprint() data.Fingerprint {
 
 	writeString(r.Metric)
 	writeString(r.From)
+	if r.Target != "" {
+		writeString(r.Target)
+	}
+	if r.Filter != nil {
+		for _, refID := range r.Filter.RefIDs {
+			writeString(refID)
+		}
+		writeString(r.Filter.Fields)
+		writeString(r.Filter.Matchers)
+	}
 	return data.Fingerprint(h.Sum64())
 }
@@ -645,6 +645,14 @@ func CopyRule(r *AlertRule, mutators ...AlertRuleMutator) *AlertRule {
 		result.Record = &Record{
 			From:   r.Record.From,
 			Metric: r.Record.Metric,
+			Target: r.Record.Target,
+		}
+		if r.Record.Filter != nil {
+			result.Record.Filter = &RecordFilter{
+				RefIDs:   slices.Clone(r.Record.Filter.RefIDs),
+				Fields:   r.Record.Filter.Fields,
+				Matchers: r.Record.Filter.Matchers,
+			}
 		}
 	}
 
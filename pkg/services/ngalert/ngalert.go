@@ -2,6 +2,7 @@ package ngalert
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"time"
@@ -136,6 +137,8 @@ type AlertNG struct {
 	folderService       folder.Service
 	dashboardService    dashboards.DashboardService
 	api                 *api.API
+	// nil when the writes of recording rules are not self-monitored
+	recordingMonitor *writer.SelfMonitor
 
 	// Alerting notification services
 	MultiOrgAlertmanager *notifier.MultiOrgAlertmanager
@@ -333,27 +336,51 @@ func (ng *AlertNG) init() error {
 
 	evalFactory := eval.NewEvaluatorFactory(ng.Cfg.UnifiedAlerting, ng.DataSourceCache, ng.ExpressionService, ng.pluginsStore)
 
-	recordingWriter, err := createRecordingWriter(ng.FeatureToggles, ng.Cfg.UnifiedAlerting.RecordingRules)
+	recordingWriter, err := createRecordingWriter(initCtx, ng.FeatureToggles, ng.Cfg.UnifiedAlerting.RecordingRules, ng.SecretsService.Decrypt, ng.Metrics.GetRemoteWriterMetrics())
 	if err != nil {
 		return err
 	}
+	// The states of alerts are written with the writer of the targets, so they are not counted as
+	// the writes of recording rules
+	var alertStateWriter schedule.AlertStateWriter
+	if _, noop := recordingWriter.(writer.NoopWriter); !noop && ng.Cfg.UnifiedAlerting.RecordingRules.WriteAlertStates {
+		alertStateWriter = writer.NewAlertStateWriter(recordingWriter, ng.Cfg.UnifiedAlerting.RecordingRules.AlertStatesTarget)
+	}
+	if interval := ng.Cfg.UnifiedAlerting.RecordingRules.SelfMonitoringInterval; interval > 0 {
+		if _, noop := recordingWriter.(writer.NoopWriter); !noop {
+			ng.recordingMonitor = writer.NewSelfMonitor(recordingWriter, interval, map[string]string{"instance": ng.Cfg.InstanceName}, log.New("ngalert.writer"))
+			recordingWriter = ng.recordingMonitor
+		}
+	}
+	// The metrics recording rules write can be listed from the API, and their writes captured to
+	// debug them
+	var recordingCapture *writer.PayloadCapture
+	var recordedMetrics *writer.MetricCatalog
+	if _, noop := recordingWriter.(writer.NoopWriter); !noop {
+		recordedMetrics = writer.NewMetricCatalog(recordingWriter)
+		recordingCapture = writer.NewPayloadCapture(recordedMetrics)
+		recordingWriter = recordingCapture
+	}
 
 	schedCfg := schedule.SchedulerCfg{
-		MaxAttempts:          ng.Cfg.UnifiedAlerting.MaxAttempts,
-		C:                    clk,
-		BaseInterval:         ng.Cfg.UnifiedAlerting.BaseInterval,
-		MinRuleInterval:      ng.Cfg.UnifiedAlerting.MinInterval,
-		DisableGrafanaFolder: ng.Cfg.UnifiedAlerting.ReservedLabels.IsReservedLabelDisabled(models.FolderTitleLabel),
-		JitterEvaluations:    schedule.JitterStrategyFrom(ng.Cfg.UnifiedAlerting, ng.FeatureToggles),
-		AppURL:               appUrl,
-		EvaluatorFactory:     evalFactory,
-		RuleStore:            ng.store,
-		FeatureToggles:       ng.FeatureToggles,
-		Metrics:              ng.Metrics.GetSchedulerMetrics(),
-		AlertSender:          alertsRouter,
-		Tracer:               ng.tracer,
-		Log:                  log.New("ngalert.scheduler"),
-		RecordingWriter:      recordingWriter,
+		MaxAttempts:                     ng.Cfg.UnifiedAlerting.MaxAttempts,
+		C:                               clk,
+		BaseInterval:                    ng.Cfg.UnifiedAlerting.BaseInterval,
+		MinRuleInterval:                 ng.Cfg.UnifiedAlerting.MinInterval,
+		DisableGrafanaFolder:            ng.Cfg.UnifiedAlerting.ReservedLabels.IsReservedLabelDisabled(models.FolderTitleLabel),
+		JitterEvaluations:               schedule.JitterStrategyFrom(ng.Cfg.UnifiedAlerting, ng.FeatureToggles),
+		AppURL:                          appUrl,
+		EvaluatorFactory:                evalFactory,
+		RuleStore:                       ng.store,
+		FeatureToggles:                  ng.FeatureToggles,
+		Metrics:                         ng.Metrics.GetSchedulerMetrics(),
+		AlertSender:                     alertsRouter,
+		Tracer:                          ng.tracer,
+		Log:                             log.New("ngalert.scheduler"),
+		RecordingWriter:                 recordingWriter,
+		RecordingRulesEvaluationTimeout: ng.Cfg.UnifiedAlerting.RecordingRules.EvaluationTimeout,
+		RecordingRulesDrainTimeout:      ng.Cfg.UnifiedAlerting.RecordingRules.DrainTimeout,
+		AlertStateWriter:                alertStateWriter,
 	}
 
 	// There are a set of feature toggles available that act as short-circuits for common configurations.
@@ -436,6 +463,9 @@ func (ng *AlertNG) init() error {
 		Historian:            history,
 		Hooks:                api.NewHooks(ng.Log),
 		Tracer:               ng.tracer,
+		SecretsService:       ng.SecretsService,
+		RecordingCapture:     recordingCapture,
+		RecordedMetrics:      recordedMetrics,
 	}
 	ng.api.RegisterAPIEndpoints(ng.Metrics.GetAPIMetrics())
 
@@ -499,6 +529,12 @@ func (ng *AlertNG) Run(ctx context.Context) error {
 		children.Go(func() error {
 			return ng.stateManager.Run(subCtx)
 		})
+		if ng.recordingMonitor != nil {
+			children.Go(func() error {
+				ng.recordingMonitor.Run(subCtx)
+				return nil
+			})
+		}
 	}
 	return children.Wait()
 }
@@ -631,11 +667,48 @@ func createRemoteAlertmanager(cfg remote.AlertmanagerConfig, kvstore kvstore.KVS
 	return remote.NewAlertmanager(cfg, notifier.NewFileStore(cfg.OrgID, kvstore), decryptFn, autogenFn, m, tracer)
 }
 
-func createRecordingWriter(featureToggles featuremgmt.FeatureToggles, settings setting.RecordingRuleSettings) (schedule.RecordingWriter, error) {
+// probeRecordingWriter logs whether the targets of recording rules can be reached, without blocking startup
+func probeRecordingWriter(w *writer.TargetsWriter, settings setting.RecordingRuleSettings, logger log.Logger) {
+	timeout := settings.Timeout
+	for _, target := range settings.Targets {
+		timeout = max(timeout, target.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for target, err := range w.Probe(ctx) {
+		if target == "" {
+			target = "default"
+		}
+		var probeErr *writer.ProbeError
+		if errors.As(err, &probeErr) {
+			logger.Error("Recording rules target is not reachable", "target", target, "failure", probeErr.Failure, "error", probeErr.Err)
+			continue
+		}
+		logger.Info("Recording rules target is reachable", "target", target)
+	}
+}
+
+func createRecordingWriter(ctx context.Context, featureToggles featuremgmt.FeatureToggles, settings setting.RecordingRuleSettings, decryptFn writer.DecryptFn, m *metrics.RemoteWriter) (schedule.RecordingWriter, error) {
 	logger := log.New("ngalert.writer")
 
 	if featureToggles.IsEnabledGlobally(featuremgmt.FlagGrafanaManagedRecordingRules) {
-		return writer.NewPrometheusWriter(settings, logger)
+		if settings.URL == "" && len(settings.Targets) == 0 {
+			logger.Warn("Recording rules are enabled but no target is configured, their results are not written")
+			return writer.NoopWriter{}, nil
+		}
+		settings, err := writer.ResolveSecrets(ctx, settings, decryptFn)
+		if err != nil {
+			return nil, err
+		}
+		w, err := writer.NewTargetsWriter(settings, featureToggles, m, logger)
+		if err != nil {
+			return nil, err
+		}
+		if settings.StartupProbe {
+			go probeRecordingWriter(w, settings, logger)
+		}
+		return w, nil
 	}
 
 	return writer.NoopWriter{}, nil
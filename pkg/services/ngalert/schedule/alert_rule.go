@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -16,6 +17,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
 	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/state"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/user"
 	"github.com/grafana/grafana/pkg/util"
@@ -57,6 +59,9 @@ func newRuleFactory(
 	logger log.Logger,
 	tracer tracing.Tracer,
 	recordingWriter RecordingWriter,
+	recordingEvalTimeout time.Duration,
+	recordingDrainTimeout time.Duration,
+	alertStateWriter AlertStateWriter,
 	evalAppliedHook evalAppliedFunc,
 	stopAppliedHook stopAppliedFunc,
 ) ruleFactoryFunc {
@@ -72,6 +77,8 @@ func newRuleFactory(
 				met,
 				tracer,
 				recordingWriter,
+				recordingEvalTimeout,
+				recordingDrainTimeout,
 			)
 		}
 		return newAlertRule(
@@ -87,6 +94,7 @@ func newRuleFactory(
 			met,
 			logger,
 			tracer,
+			alertStateWriter,
 			evalAppliedHook,
 			stopAppliedHook,
 		)
@@ -115,6 +123,8 @@ type alertRule struct {
 	stateManager *state.Manager
 	evalFactory  eval.EvaluatorFactory
 	ruleProvider ruleProvider
+	// writes the states of the alerts of the rule after each evaluation, nil when they are not written
+	stateWriter AlertStateWriter
 
 	// Event hooks that are only used in tests.
 	evalAppliedHook evalAppliedFunc
@@ -138,6 +148,7 @@ func newAlertRule(
 	met *metrics.Scheduler,
 	logger log.Logger,
 	tracer tracing.Tracer,
+	stateWriter AlertStateWriter,
 	evalAppliedHook func(ngmodels.AlertRuleKey, time.Time),
 	stopAppliedHook func(ngmodels.AlertRuleKey),
 ) *alertRule {
@@ -155,6 +166,7 @@ func newAlertRule(
 		stateManager:         stateManager,
 		evalFactory:          evalFactory,
 		ruleProvider:         ruleProvider,
+		stateWriter:          stateWriter,
 		evalAppliedHook:      evalAppliedHook,
 		stopAppliedHook:      stopAppliedHook,
 		metrics:              met,
@@ -428,6 +440,12 @@ func (a *alertRule) evaluate(ctx context.Context, key ngmodels.AlertRuleKey, f f
 	}
 	sendDuration.Observe(a.clock.Now().Sub(start).Seconds())
 
+	if a.stateWriter != nil {
+		if err := a.stateWriter.WriteStates(ctx, e.scheduledAt, alertStates(processedStates)); err != nil {
+			logger.Warn("Failed to write the states of the alerts of the rule", "error", err)
+		}
+	}
+
 	return nil
 }
 
@@ -438,6 +456,42 @@ func (a *alertRule) notify(ctx context.Context, key ngmodels.AlertRuleKey, state
 	}
 }
 
+// alertStates returns the states of the alerts of transitions that are active, or were at the
+// previous evaluation. Their private labels, like __alert_rule_uid__, are not written.
+func alertStates(transitions []state.StateTransition) []writer.AlertState {
+	states := make([]writer.AlertState, 0, len(transitions))
+	for _, t := range transitions {
+		s := writer.AlertState{
+			State:         alertState(t.State.State),
+			PreviousState: alertState(t.PreviousState),
+			ActiveAt:      t.StartsAt,
+		}
+		if s.State == "" && s.PreviousState == "" {
+			continue
+		}
+		s.Labels = make(map[string]string, len(t.Labels))
+		for k, v := range t.Labels {
+			if !strings.HasPrefix(k, "__") {
+				s.Labels[k] = v
+			}
+		}
+		states = append(states, s)
+	}
+	return states
+}
+
+// alertState returns the alertstate of the ALERTS series of an alert in state, empty when it is not active
+func alertState(state eval.State) string {
+	switch state {
+	case eval.Pending:
+		return writer.AlertStatePending
+	case eval.Alerting:
+		return writer.AlertStateFiring
+	default:
+		return ""
+	}
+}
+
 func (a *alertRule) resetState(ctx context.Context, key ngmodels.AlertRuleKey, isPaused bool) {
 	rule := a.ruleProvider.get(key)
 	reason := ngmodels.StateReasonUpdated
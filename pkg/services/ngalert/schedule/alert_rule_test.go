@@ -25,6 +25,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 	models "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/state"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/util"
 )
 
@@ -261,7 +262,7 @@ func TestAlertRule(t *testing.T) {
 }
 
 func blankRuleForTests(ctx context.Context) *alertRule {
-	return newAlertRule(context.Background(), nil, false, 0, nil, nil, nil, nil, nil, nil, log.NewNopLogger(), nil, nil, nil)
+	return newAlertRule(context.Background(), nil, false, 0, nil, nil, nil, nil, nil, nil, log.NewNopLogger(), nil, nil, nil, nil)
 }
 
 func TestRuleRoutine(t *testing.T) {
@@ -765,5 +766,35 @@ func TestRuleRoutine(t *testing.T) {
 }
 
 func ruleFactoryFromScheduler(sch *schedule) ruleFactory {
-	return newRuleFactory(sch.appURL, sch.disableGrafanaFolder, sch.maxAttempts, sch.alertsSender, sch.stateManager, sch.evaluatorFactory, &sch.schedulableAlertRules, sch.clock, sch.featureToggles, sch.metrics, sch.log, sch.tracer, sch.recordingWriter, sch.evalAppliedFunc, sch.stopAppliedFunc)
+	return newRuleFactory(sch.appURL, sch.disableGrafanaFolder, sch.maxAttempts, sch.alertsSender, sch.stateManager, sch.evaluatorFactory, &sch.schedulableAlertRules, sch.clock, sch.featureToggles, sch.metrics, sch.log, sch.tracer, sch.recordingWriter, sch.recordingRulesEvaluationTimeout, sch.recordingRulesDrainTimeout, sch.alertStateWriter, sch.evalAppliedFunc, sch.stopAppliedFunc)
+}
+
+func TestAlertStates(t *testing.T) {
+	startsAt := time.Now()
+	transition := func(instance string, current, previous eval.State) state.StateTransition {
+		return state.StateTransition{
+			State: &state.State{
+				State:    current,
+				Labels:   data.Labels{"alertname": "test", "instance": instance, alertingModels.RuleUIDLabel: "rule"},
+				StartsAt: startsAt,
+			},
+			PreviousState: previous,
+		}
+	}
+	labels := func(instance string) map[string]string {
+		return map[string]string{"alertname": "test", "instance": instance}
+	}
+
+	states := alertStates([]state.StateTransition{
+		transition("a", eval.Pending, eval.Normal),
+		transition("b", eval.Alerting, eval.Pending),
+		transition("c", eval.Normal, eval.Alerting),
+		transition("d", eval.Normal, eval.Normal),
+		transition("e", eval.Error, eval.NoData),
+	})
+	require.Equal(t, []writer.AlertState{
+		{Labels: labels("a"), State: writer.AlertStatePending, ActiveAt: startsAt},
+		{Labels: labels("b"), State: writer.AlertStateFiring, PreviousState: writer.AlertStatePending, ActiveAt: startsAt},
+		{Labels: labels("c"), PreviousState: writer.AlertStateFiring, ActiveAt: startsAt},
+	}, states)
 }
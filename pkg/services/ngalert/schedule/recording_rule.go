@@ -2,18 +2,26 @@ package schedule
 
 import (
 	context "context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/expr"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/ngalert/eval"
 	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
 	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/util"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -39,9 +47,15 @@ type recordingRule struct {
 	tracer  tracing.Tracer
 
 	writer RecordingWriter
+	// zero when the evaluation is only bounded by the evaluation timeout of all rules
+	evalTimeout time.Duration
+	// how long a write in flight when the scheduler stops can complete, see writeContext
+	drainTimeout time.Duration
+	// the samples of the last write, not written again
+	written *writer.WrittenSamples
 }
 
-func newRecordingRule(parent context.Context, maxAttempts int64, clock clock.Clock, evalFactory eval.EvaluatorFactory, ft featuremgmt.FeatureToggles, logger log.Logger, metrics *metrics.Scheduler, tracer tracing.Tracer, writer RecordingWriter) *recordingRule {
+func newRecordingRule(parent context.Context, maxAttempts int64, clock clock.Clock, evalFactory eval.EvaluatorFactory, ft featuremgmt.FeatureToggles, logger log.Logger, metrics *metrics.Scheduler, tracer tracing.Tracer, recordingWriter RecordingWriter, evalTimeout, drainTimeout time.Duration) *recordingRule {
 	ctx, stop := util.WithCancelCause(parent)
 	return &recordingRule{
 		ctx:            ctx,
@@ -54,7 +68,10 @@ func newRecordingRule(parent context.Context, maxAttempts int64, clock clock.Clo
 		logger:         logger,
 		metrics:        metrics,
 		tracer:         tracer,
-		writer:         writer,
+		writer:         recordingWriter,
+		evalTimeout:    evalTimeout,
+		drainTimeout:   drainTimeout,
+		written:        writer.NewWrittenSamples(),
 	}
 }
 
@@ -163,6 +180,12 @@ func (r *recordingRule) doEvaluate(ctx context.Context, ev *Evaluation) {
 		if eval.IsNonRetryableError(err) {
 			break
 		}
+		// Evaluating the rule again would write the series that were written a second time
+		var partial *writer.PartialWriteError
+		if errors.As(err, &partial) {
+			logger.Warn("Recording rule results were partially written", "series", partial.Series, "written", partial.WrittenSeries, "failedBatches", len(partial.Failed))
+			break
+		}
 
 		if attempt < r.maxAttempts {
 			select {
@@ -189,10 +212,20 @@ func (r *recordingRule) doEvaluate(ctx context.Context, ev *Evaluation) {
 
 func (r *recordingRule) tryEvaluation(ctx context.Context, ev *Evaluation, logger log.Logger) error {
 	evalStart := r.clock.Now()
-	evalCtx := eval.NewContext(ctx, SchedulerUserFor(ev.rule.OrgID))
-	result, err := r.buildAndExecutePipeline(ctx, evalCtx, ev, logger)
+	// The write has its own timeout, a slow evaluation does not leave less time to it
+	pipelineCtx := ctx
+	if r.evalTimeout > 0 {
+		var cancel context.CancelFunc
+		pipelineCtx, cancel = context.WithTimeout(ctx, r.evalTimeout)
+		defer cancel()
+	}
+	evalCtx := eval.NewContext(pipelineCtx, SchedulerUserFor(ev.rule.OrgID))
+	result, err := r.buildAndExecutePipeline(pipelineCtx, evalCtx, ev, logger)
 	evalDur := r.clock.Now().Sub(evalStart)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("server side expressions pipeline timed out after %s: %w", r.evalTimeout, err)
+		}
 		return fmt.Errorf("server side expressions pipeline returned an error: %w", err)
 	}
 
@@ -207,7 +240,11 @@ func (r *recordingRule) tryEvaluation(ctx context.Context, ev *Evaluation, logge
 		attribute.Int64("results", int64(len(result.Responses))),
 	))
 
-	frames, err := r.frameRef(ev.rule.Record.From, result)
+	filter, err := writer.NewFrameFilter(ev.rule.Record.Filter)
+	if err != nil {
+		return fmt.Errorf("failed to select the frames to write: %w", err)
+	}
+	frames, err := r.recordedFrames(ev.rule.Record, result)
 	if err != nil {
 		span.SetStatus(codes.Error, "failed to extract frames from rule evaluation")
 		span.RecordError(err)
@@ -215,10 +252,34 @@ func (r *recordingRule) tryEvaluation(ctx context.Context, ev *Evaluation, logge
 	}
 
 	writeStart := r.clock.Now()
-	err = r.writer.Write(ctx, ev.rule.Record.Metric, writeStart, frames, ev.rule.Labels)
+	writeCtx, cancel := r.writeContext(ctx, writeTimeout(ev, writeStart))
+	defer cancel()
+	writeCtx = writer.WithWrittenSamples(writeCtx, r.written)
+	writeCtx = writer.WithFrameFilter(writeCtx, filter)
+	writeCtx = writer.WithDatasourceType(writeCtx, recordedDatasourceType(ev.rule))
+	err = r.writer.Write(writeCtx, ev.rule.Record.Target, ev.rule.Record.Metric, writeStart, frames, expandRecordingLabels(ev, logger))
 	writeDur := r.clock.Now().Sub(writeStart)
 
+	if err != nil && r.draining(ctx) {
+		var partial *writer.PartialWriteError
+		dropped := "all"
+		if errors.As(err, &partial) {
+			dropped = strconv.Itoa(partial.Series - partial.WrittenSeries)
+		}
+		logger.Warn("Recording rule results could not be written before the scheduler stopped", "drainTimeout", r.drainTimeout, "droppedSeries", dropped, "error", err)
+	}
 	if err != nil {
+		var partial *writer.PartialWriteError
+		if errors.As(err, &partial) {
+			span.SetAttributes(
+				attribute.Int("series", partial.Series),
+				attribute.Int("written_series", partial.WrittenSeries),
+				attribute.Int("failed_batches", len(partial.Failed)),
+			)
+			span.SetStatus(codes.Error, "failed to write some metrics")
+			span.RecordError(err)
+			return fmt.Errorf("metric remote write partially failed: %w", err)
+		}
 		span.SetStatus(codes.Error, "failed to write metrics")
 		span.RecordError(err)
 		return fmt.Errorf("metric remote write failed: %w", err)
@@ -232,6 +293,43 @@ func (r *recordingRule) tryEvaluation(ctx context.Context, ev *Evaluation, logge
 	return nil
 }
 
+// writeContext returns the context of a write lasting up to timeout. When the rule is stopped, the
+// write is aborted, unless it is because the scheduler stops when Grafana shuts down: the write can
+// then complete within the drain timeout, so restarts do not leave gaps in the recorded series.
+func (r *recordingRule) writeContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	stop := context.AfterFunc(ctx, func() {
+		if !r.draining(ctx) {
+			cancel()
+			return
+		}
+		time.AfterFunc(r.drainTimeout, cancel)
+	})
+	return writeCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// draining returns whether the writes of the rule can complete within the drain timeout, as the
+// scheduler stopped rather than the rule being deleted
+func (r *recordingRule) draining(ctx context.Context) bool {
+	err := ctx.Err()
+	return r.drainTimeout > 0 && err != nil && !errors.Is(err, errRuleDeleted)
+}
+
+// minWriteTimeout is the time left to the write of an evaluation that lasted until, or past, the next
+// evaluation of its rule, so its results are still written.
+const minWriteTimeout = 5 * time.Second
+
+// writeTimeout returns how long the write of the results of an evaluation starting at now can last:
+// until the next evaluation of the rule is scheduled, so the writes of a rule do not pile up when its
+// target is slow, and at least minWriteTimeout. Writes are also abandoned when the rule is stopped,
+// see writeContext.
+func writeTimeout(ev *Evaluation, now time.Time) time.Duration {
+	return max(ev.scheduledAt.Add(time.Duration(ev.rule.IntervalSeconds)*time.Second).Sub(now), minWriteTimeout)
+}
+
 func (r *recordingRule) buildAndExecutePipeline(ctx context.Context, evalCtx eval.EvaluationContext, ev *Evaluation, logger log.Logger) (*backend.QueryDataResponse, error) {
 	start := r.clock.Now()
 	evaluator, err := r.evalFactory.Create(evalCtx, ev.rule.GetEvalCondition())
@@ -267,3 +365,117 @@ func (r *recordingRule) frameRef(refID string, resp *backend.QueryDataResponse)
 
 	return nil, fmt.Errorf("no response with refID %s found in rule evaluation", refID)
 }
+
+// recordedFrames returns the frames of the results of the expression of From, or the ones of the
+// queries and expressions of the filter of record, with their refID so the writer selects them
+func (r *recordingRule) recordedFrames(record *ngmodels.Record, resp *backend.QueryDataResponse) (data.Frames, error) {
+	if record.Filter == nil || len(record.Filter.RefIDs) == 0 {
+		return r.frameRef(record.From, resp)
+	}
+	var frames data.Frames
+	for _, refID := range record.Filter.RefIDs {
+		if err := eval.FindConditionError(resp, refID); err != nil {
+			return nil, fmt.Errorf("the query failed with an error: %w", err)
+		}
+		refFrames, err := r.frameRef(refID, resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, frame := range refFrames {
+			frame.RefID = refID
+		}
+		frames = append(frames, refFrames...)
+	}
+	return frames, nil
+}
+
+// recordedDatasourceType returns the type of the data source of the queries whose frames are written,
+// so the writer converts them with the converters of the data source. It is empty when they are
+// expressions, or queries of data sources of different types or whose type is not in their model.
+func recordedDatasourceType(rule *ngmodels.AlertRule) string {
+	refIDs := []string{rule.Record.From}
+	if rule.Record.Filter != nil && len(rule.Record.Filter.RefIDs) > 0 {
+		refIDs = rule.Record.Filter.RefIDs
+	}
+	var typ string
+	for _, refID := range refIDs {
+		i := slices.IndexFunc(rule.Data, func(q ngmodels.AlertQuery) bool { return q.RefID == refID })
+		if i < 0 || expr.NodeTypeFromDatasourceUID(rule.Data[i].DatasourceUID) != expr.TypeDatasourceNode {
+			return ""
+		}
+		var model struct {
+			Datasource struct {
+				Type string `json:"type"`
+			} `json:"datasource"`
+		}
+		if err := json.Unmarshal(rule.Data[i].Model, &model); err != nil || model.Datasource.Type == "" {
+			return ""
+		}
+		if typ != "" && typ != model.Datasource.Type {
+			return ""
+		}
+		typ = model.Datasource.Type
+	}
+	return typ
+}
+
+// recordingLabelsVars are the variables the templates of the labels of recording rules can use
+const recordingLabelsVars = "{{- $ruleUID := .RuleUID -}}{{- $ruleName := .RuleName -}}{{- $ruleGroup := .RuleGroup -}}" +
+	"{{- $orgID := .OrgID -}}{{- $folderUID := .FolderUID -}}{{- $folder := .Folder -}}"
+
+// recordingLabelsData is the metadata of a recording rule its labels are expanded with
+type recordingLabelsData struct {
+	RuleUID   string
+	RuleName  string
+	RuleGroup string
+	OrgID     int64
+	FolderUID string
+	Folder    string
+}
+
+// expandRecordingLabels returns the labels of the rule of ev with their templates expanded with its
+// metadata, like {{ $ruleUID }} or {{ $folder }}, so the series written can be traced back to it.
+// The labels whose template cannot be expanded are kept as they are, and the error is logged.
+func expandRecordingLabels(ev *Evaluation, logger log.Logger) map[string]string {
+	var expanded map[string]string
+	for k, v := range ev.rule.Labels {
+		if !strings.Contains(v, "{{") {
+			continue
+		}
+		if expanded == nil {
+			expanded = make(map[string]string, len(ev.rule.Labels))
+			for k, v := range ev.rule.Labels {
+				expanded[k] = v
+			}
+		}
+		result, err := expandRecordingLabel(k, v, recordingLabelsData{
+			RuleUID:   ev.rule.UID,
+			RuleName:  ev.rule.Title,
+			RuleGroup: ev.rule.RuleGroup,
+			OrgID:     ev.rule.OrgID,
+			FolderUID: ev.rule.NamespaceUID,
+			Folder:    ev.folderTitle,
+		})
+		if err != nil {
+			logger.Error("Error in expanding the template of a recording rule label", "label", k, "error", err)
+			continue
+		}
+		expanded[k] = result
+	}
+	if expanded == nil {
+		return ev.rule.Labels
+	}
+	return expanded
+}
+
+func expandRecordingLabel(name, tmpl string, data recordingLabelsData) (string, error) {
+	t, err := template.New("__recording_" + name).Option("missingkey=error").Parse(recordingLabelsVars + tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -3,19 +3,26 @@ package schedule
 import (
 	"bytes"
 	context "context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/eval/eval_mocks"
 	models "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -145,9 +152,105 @@ func TestRecordingRule(t *testing.T) {
 	})
 }
 
+func TestRecordingRuleWriteTimeout(t *testing.T) {
+	rule := models.RuleGen.With(models.RuleGen.WithAllRecordingRules(), models.RuleGen.WithIntervalSeconds(60)).GenerateRef()
+	scheduledAt := time.Now()
+	ev := &Evaluation{scheduledAt: scheduledAt, rule: rule}
+
+	require.Equal(t, 45*time.Second, writeTimeout(ev, scheduledAt.Add(15*time.Second)))
+	// Evaluations lasting until or past the next evaluation of the rule still leave time to the write
+	require.Equal(t, minWriteTimeout, writeTimeout(ev, scheduledAt.Add(58*time.Second)))
+	require.Equal(t, minWriteTimeout, writeTimeout(ev, scheduledAt.Add(60*time.Second)))
+	require.Equal(t, minWriteTimeout, writeTimeout(ev, scheduledAt.Add(61*time.Second)))
+}
+
+func TestRecordingRuleWriteContext(t *testing.T) {
+	newRule := func(drainTimeout time.Duration) (*recordingRule, context.CancelFunc) {
+		parent, cancel := context.WithCancel(context.Background())
+		return newRecordingRule(parent, 0, nil, nil, nil, log.NewNopLogger(), nil, nil, writer.FakeWriter{}, 0, drainTimeout), cancel
+	}
+
+	t.Run("writes in flight drain when the scheduler stops", func(t *testing.T) {
+		r, stopScheduler := newRule(50 * time.Millisecond)
+		writeCtx, cancel := r.writeContext(r.ctx, time.Minute)
+		defer cancel()
+
+		stopScheduler()
+		require.NoError(t, writeCtx.Err())
+		require.Eventually(t, func() bool { return writeCtx.Err() != nil }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("writes are aborted when the rule is deleted", func(t *testing.T) {
+		r, stopScheduler := newRule(time.Minute)
+		defer stopScheduler()
+		writeCtx, cancel := r.writeContext(r.ctx, time.Minute)
+		defer cancel()
+
+		r.Stop(errRuleDeleted)
+		require.Eventually(t, func() bool { return writeCtx.Err() != nil }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("writes are aborted without drain timeout", func(t *testing.T) {
+		r, stopScheduler := newRule(0)
+		writeCtx, cancel := r.writeContext(r.ctx, time.Minute)
+		defer cancel()
+
+		stopScheduler()
+		require.Eventually(t, func() bool { return writeCtx.Err() != nil }, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestExpandRecordingLabels(t *testing.T) {
+	rule := models.RuleGen.With(models.RuleGen.WithAllRecordingRules()).GenerateRef()
+	rule.Labels = map[string]string{
+		"team":       "infra",
+		"provenance": "{{ $orgID }}/{{ $folder }}/{{ $ruleUID }}",
+		"group":      "{{ $ruleGroup | printf \"%q\" }}",
+		"invalid":    "{{ $unknown }}",
+	}
+	ev := &Evaluation{rule: rule, folderTitle: "Folder"}
+
+	labels := expandRecordingLabels(ev, log.NewNopLogger())
+	require.Equal(t, map[string]string{
+		"team":       "infra",
+		"provenance": fmt.Sprintf("%d/Folder/%s", rule.OrgID, rule.UID),
+		"group":      fmt.Sprintf("%q", rule.RuleGroup),
+		"invalid":    "{{ $unknown }}",
+	}, labels)
+	// The labels of the rule are not changed
+	require.Equal(t, "{{ $ruleGroup | printf \"%q\" }}", rule.Labels["group"])
+
+	// Labels without templates are written as they are
+	rule.Labels = map[string]string{"team": "infra"}
+	require.Equal(t, rule.Labels, expandRecordingLabels(ev, log.NewNopLogger()))
+}
+
+func TestRecordedFrames(t *testing.T) {
+	r := blankRecordingRuleForTests(context.Background())
+	resp := &backend.QueryDataResponse{Responses: backend.Responses{
+		"A": {Frames: data.Frames{data.NewFrame("a")}},
+		"B": {Frames: data.Frames{data.NewFrame("b")}},
+		"C": {Frames: data.Frames{data.NewFrame("c")}},
+	}}
+
+	frames, err := r.recordedFrames(&models.Record{From: "A"}, resp)
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	require.Equal(t, "a", frames[0].Name)
+
+	// The frames of the refIDs of the filter are written instead, and selected by their refID
+	frames, err = r.recordedFrames(&models.Record{From: "A", Filter: &models.RecordFilter{RefIDs: []string{"B", "C"}}}, resp)
+	require.NoError(t, err)
+	require.Len(t, frames, 2)
+	require.Equal(t, []string{"B", "C"}, []string{frames[0].RefID, frames[1].RefID})
+
+	_, err = r.recordedFrames(&models.Record{From: "A", Filter: &models.RecordFilter{RefIDs: []string{"D"}}}, resp)
+	require.ErrorContains(t, err, "no response with refID D")
+}
+
 func blankRecordingRuleForTests(ctx context.Context) *recordingRule {
 	ft := featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRules)
-	return newRecordingRule(context.Background(), 0, nil, nil, ft, log.NewNopLogger(), nil, nil, writer.FakeWriter{})
+	return newRecordingRule(context.Background(), 0, nil, nil, ft, log.NewNopLogger(), nil, nil, writer.FakeWriter{}, 0, 0)
 }
 
 func TestRecordingRule_Integration(t *testing.T) {
@@ -216,3 +319,83 @@ func TestRecordingRule_Integration(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestRecordingRule_PartialWrite(t *testing.T) {
+	gen := models.RuleGen.With(models.RuleGen.WithAllRecordingRules())
+	rule := gen.GenerateRef()
+	ruleStore := newFakeRulesStore()
+	ruleStore.PutRule(context.Background(), rule)
+	evaluator := &eval_mocks.ConditionEvaluatorMock{}
+	evaluator.EXPECT().EvaluateRaw(mock.Anything, mock.Anything).Return(&backend.QueryDataResponse{
+		Responses: backend.Responses{rule.Record.From: {Frames: data.Frames{data.NewFrame("")}}},
+	}, nil)
+	reg := prometheus.NewPedanticRegistry()
+	sch := setupScheduler(t, ruleStore, nil, reg, nil, eval_mocks.NewEvaluatorFactory(evaluator))
+	sch.maxAttempts = 3
+	var writes atomic.Int64
+	sch.recordingWriter = writer.FakeWriter{WriteFunc: func(context.Context, string, string, time.Time, data.Frames, map[string]string) error {
+		writes.Add(1)
+		return &writer.PartialWriteError{Batches: 2, Series: 3, WrittenSeries: 2, Failed: []writer.BatchError{{Batch: 1, Series: 1, Err: errors.New("rejected")}}}
+	}}
+
+	process := ruleFactoryFromScheduler(sch).new(context.Background(), rule)
+	evalDoneChan := make(chan time.Time)
+	process.(*recordingRule).evalAppliedHook = func(_ models.AlertRuleKey, t time.Time) {
+		evalDoneChan <- t
+	}
+	go func() {
+		_ = process.Run(rule.GetKey())
+	}()
+	process.Eval(&Evaluation{
+		scheduledAt: time.Now(),
+		rule:        rule,
+		folderTitle: ruleStore.getNamespaceTitle(rule.NamespaceUID),
+	})
+	_ = waitForTimeChannel(t, evalDoneChan)
+
+	// The rule is not evaluated again, which would write the written series twice
+	require.Equal(t, int64(1), writes.Load())
+	err := testutil.GatherAndCompare(reg, bytes.NewBufferString(fmt.Sprintf(`
+		# HELP grafana_alerting_rule_evaluation_attempts_total The total number of rule evaluation attempts.
+		# TYPE grafana_alerting_rule_evaluation_attempts_total counter
+		grafana_alerting_rule_evaluation_attempts_total{org="%[1]d"} 1
+		# HELP grafana_alerting_rule_evaluation_failures_total The total number of rule evaluation failures.
+		# TYPE grafana_alerting_rule_evaluation_failures_total counter
+		grafana_alerting_rule_evaluation_failures_total{org="%[1]d"} 1
+		`, rule.OrgID)),
+		"grafana_alerting_rule_evaluation_attempts_total",
+		"grafana_alerting_rule_evaluation_failures_total",
+	)
+	require.NoError(t, err)
+}
+
+func TestRecordedDatasourceType(t *testing.T) {
+	query := func(refID, uid, model string) models.AlertQuery {
+		return models.AlertQuery{RefID: refID, DatasourceUID: uid, Model: json.RawMessage(model)}
+	}
+	rule := &models.AlertRule{Data: []models.AlertQuery{
+		query("A", "es", `{"datasource": {"type": "elasticsearch", "uid": "es"}}`),
+		query("B", "es2", `{"datasource": {"type": "elasticsearch", "uid": "es2"}}`),
+		query("C", "prom", `{"datasource": {"type": "prometheus", "uid": "prom"}}`),
+		query("D", "__expr__", `{"datasource": {"type": "__expr__", "uid": "__expr__"}, "type": "math", "expression": "$A * 2"}`),
+		query("E", "legacy", `{"datasource": "legacy"}`),
+	}}
+	testCases := []struct {
+		name     string
+		record   *models.Record
+		expected string
+	}{
+		{name: "query", record: &models.Record{From: "A"}, expected: "elasticsearch"},
+		{name: "expression", record: &models.Record{From: "D"}},
+		{name: "no type in the model", record: &models.Record{From: "E"}},
+		{name: "unknown refID", record: &models.Record{From: "F"}},
+		{name: "filter of the same type", record: &models.Record{From: "D", Filter: &models.RecordFilter{RefIDs: []string{"A", "B"}}}, expected: "elasticsearch"},
+		{name: "filter of different types", record: &models.Record{From: "A", Filter: &models.RecordFilter{RefIDs: []string{"A", "C"}}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule.Record = tc.record
+			require.Equal(t, tc.expected, recordedDatasourceType(rule))
+		})
+	}
+}
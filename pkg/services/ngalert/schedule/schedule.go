@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
 	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
 	"github.com/grafana/grafana/pkg/services/ngalert/state"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer"
 	"github.com/grafana/grafana/pkg/util/ticker"
 )
 
@@ -48,7 +49,14 @@ type RulesStore interface {
 }
 
 type RecordingWriter interface {
-	Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
+	// Write writes the results of a recording rule to target, the default one when empty.
+	Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
+}
+
+// AlertStateWriter writes the states of the alerts of alert rules, see writer.AlertStateWriter.
+type AlertStateWriter interface {
+	// WriteStates writes the states of the alerts of an evaluation of a rule at its time t.
+	WriteStates(ctx context.Context, t time.Time, states []writer.AlertState) error
 }
 
 type schedule struct {
@@ -99,6 +107,12 @@ type schedule struct {
 	tracer tracing.Tracer
 
 	recordingWriter RecordingWriter
+	// the maximum duration of the evaluation of recording rules
+	recordingRulesEvaluationTimeout time.Duration
+	recordingRulesDrainTimeout      time.Duration
+
+	// writes the states of the alerts of alert rules, nil when they are not written
+	alertStateWriter AlertStateWriter
 }
 
 // SchedulerCfg is the scheduler configuration.
@@ -118,6 +132,14 @@ type SchedulerCfg struct {
 	Tracer               tracing.Tracer
 	Log                  log.Logger
 	RecordingWriter      RecordingWriter
+	// RecordingRulesEvaluationTimeout bounds the evaluation of recording rules, not the write of their results.
+	RecordingRulesEvaluationTimeout time.Duration
+	// RecordingRulesDrainTimeout is how long the writes of recording rules in flight when the scheduler
+	// stops can complete. They are aborted right away when it is zero.
+	RecordingRulesDrainTimeout time.Duration
+	// AlertStateWriter writes the states of the alerts of alert rules after each of their evaluations.
+	// They are not written when it is nil.
+	AlertStateWriter AlertStateWriter
 }
 
 // NewScheduler returns a new scheduler.
@@ -129,24 +151,27 @@ func NewScheduler(cfg SchedulerCfg, stateManager *state.Manager) *schedule {
 	}
 
 	sch := schedule{
-		registry:              newRuleRegistry(),
-		maxAttempts:           cfg.MaxAttempts,
-		clock:                 cfg.C,
-		baseInterval:          cfg.BaseInterval,
-		log:                   cfg.Log,
-		evaluatorFactory:      cfg.EvaluatorFactory,
-		ruleStore:             cfg.RuleStore,
-		metrics:               cfg.Metrics,
-		appURL:                cfg.AppURL,
-		disableGrafanaFolder:  cfg.DisableGrafanaFolder,
-		jitterEvaluations:     cfg.JitterEvaluations,
-		featureToggles:        cfg.FeatureToggles,
-		stateManager:          stateManager,
-		minRuleInterval:       cfg.MinRuleInterval,
-		schedulableAlertRules: alertRulesRegistry{rules: make(map[ngmodels.AlertRuleKey]*ngmodels.AlertRule)},
-		alertsSender:          cfg.AlertSender,
-		tracer:                cfg.Tracer,
-		recordingWriter:       cfg.RecordingWriter,
+		registry:                        newRuleRegistry(),
+		maxAttempts:                     cfg.MaxAttempts,
+		clock:                           cfg.C,
+		baseInterval:                    cfg.BaseInterval,
+		log:                             cfg.Log,
+		evaluatorFactory:                cfg.EvaluatorFactory,
+		ruleStore:                       cfg.RuleStore,
+		metrics:                         cfg.Metrics,
+		appURL:                          cfg.AppURL,
+		disableGrafanaFolder:            cfg.DisableGrafanaFolder,
+		jitterEvaluations:               cfg.JitterEvaluations,
+		featureToggles:                  cfg.FeatureToggles,
+		stateManager:                    stateManager,
+		minRuleInterval:                 cfg.MinRuleInterval,
+		schedulableAlertRules:           alertRulesRegistry{rules: make(map[ngmodels.AlertRuleKey]*ngmodels.AlertRule)},
+		alertsSender:                    cfg.AlertSender,
+		tracer:                          cfg.Tracer,
+		recordingWriter:                 cfg.RecordingWriter,
+		recordingRulesEvaluationTimeout: cfg.RecordingRulesEvaluationTimeout,
+		recordingRulesDrainTimeout:      cfg.RecordingRulesDrainTimeout,
+		alertStateWriter:                cfg.AlertStateWriter,
 	}
 
 	return &sch
@@ -264,6 +289,9 @@ func (sch *schedule) processTick(ctx context.Context, dispatcherGroup *errgroup.
 		sch.log,
 		sch.tracer,
 		sch.recordingWriter,
+		sch.recordingRulesEvaluationTimeout,
+		sch.recordingRulesDrainTimeout,
+		sch.alertStateWriter,
 		sch.evalAppliedFunc,
 		sch.stopAppliedFunc,
 	)
@@ -0,0 +1,121 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/prometheus/model/value"
+)
+
+// The names of the series of the states of alerts, like the ones of the alerting rules of Prometheus
+const (
+	// AlertsMetric is 1 for each pending or firing alert, with its state in the alertstate label
+	AlertsMetric = "ALERTS"
+	// AlertsForStateMetric is the Unix time each pending or firing alert became active
+	AlertsForStateMetric = "ALERTS_FOR_STATE"
+)
+
+// The states of the alerts in the alertstate label of their ALERTS series
+const (
+	AlertStatePending = "pending"
+	AlertStateFiring  = "firing"
+)
+
+// alertStateLabel is the label of the state of the ALERTS series
+const alertStateLabel = "alertstate"
+
+// staleMarker is the value marking the end of a series, like the staleness markers of Prometheus
+var staleMarker = math.Float64frombits(value.StaleNaN)
+
+// AlertState is the state of an alert at an evaluation of its rule.
+type AlertState struct {
+	// The labels of the alert, including its alertname
+	Labels map[string]string
+	// pending or firing, empty when the alert is not active
+	State string
+	// The state of the alert at the previous evaluation of its rule, whose series are marked stale
+	// when it changed
+	PreviousState string
+	// When the alert became active
+	ActiveAt time.Time
+}
+
+// AlertStateWriter writes the states of the alerts of alert rules to a target of recording rules,
+// as the ALERTS and ALERTS_FOR_STATE series Prometheus writes for its alerting rules, so the
+// dashboards of the alerts of Prometheus show the ones of Grafana too.
+type AlertStateWriter struct {
+	writer targetWriter
+	// The target the states are written to, the default one when empty
+	target string
+}
+
+// NewAlertStateWriter returns an AlertStateWriter writing the states of alerts to target with w.
+func NewAlertStateWriter(w targetWriter, target string) *AlertStateWriter {
+	return &AlertStateWriter{writer: w, target: target}
+}
+
+// WriteStates writes the series of the active alerts of an evaluation, at its time t. The series
+// of the alerts whose state changed since the previous evaluation are marked stale, so they end
+// right away instead of after the lookback delta of the queries.
+func (w *AlertStateWriter) WriteStates(ctx context.Context, t time.Time, states []AlertState) error {
+	var alerts, forState []alertSample
+	for _, s := range states {
+		if s.State != "" {
+			alerts = append(alerts, alertSample{labels: withAlertState(s.Labels, s.State), value: 1})
+			forState = append(forState, alertSample{labels: s.Labels, value: float64(s.ActiveAt.Unix())})
+		}
+		if s.PreviousState != "" && s.PreviousState != s.State {
+			alerts = append(alerts, alertSample{labels: withAlertState(s.Labels, s.PreviousState), value: staleMarker})
+			if s.State == "" {
+				forState = append(forState, alertSample{labels: s.Labels, value: staleMarker})
+			}
+		}
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	var errs []error
+	if err := w.writer.Write(ctx, w.target, AlertsMetric, t, alertFrames(t, alerts), nil); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write %s: %w", AlertsMetric, err))
+	}
+	if len(forState) > 0 {
+		if err := w.writer.Write(ctx, w.target, AlertsForStateMetric, t, alertFrames(t, forState), nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %s: %w", AlertsForStateMetric, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// alertSample is a sample of a series of the states of alerts
+type alertSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// alertFrames returns the frames of samples, one for each, as the frames of recording rules
+func alertFrames(t time.Time, samples []alertSample) data.Frames {
+	frames := make(data.Frames, 0, len(samples))
+	for _, s := range samples {
+		frame := data.NewFrame("",
+			data.NewField("time", nil, []time.Time{t}),
+			data.NewField("value", data.Labels(s.labels), []float64{s.value}),
+		)
+		frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: data.FrameTypeVersion{0, 1}})
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func withAlertState(labels map[string]string, state string) map[string]string {
+	l := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		l[k] = v
+	}
+	l[alertStateLabel] = state
+	return l
+}
@@ -0,0 +1,67 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer/writertest"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestAlertStateWriter(t *testing.T) {
+	receiver := writertest.NewReceiver(t)
+	mimir := receiver.Settings()
+	mimir.Name = "mimir"
+	targets, err := NewTargetsWriter(setting.RecordingRuleSettings{Targets: []setting.RecordingRuleTargetSettings{mimir}}, nil, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	w := NewAlertStateWriter(targets, "mimir")
+
+	now := time.Now()
+	activeAt := now.Add(-time.Minute)
+	labels := func(instance string) map[string]string {
+		return map[string]string{"alertname": "HighLatency", "instance": instance}
+	}
+	require.NoError(t, w.WriteStates(context.Background(), now, []AlertState{
+		{Labels: labels("a"), State: AlertStatePending, ActiveAt: activeAt},
+		{Labels: labels("b"), State: AlertStateFiring, PreviousState: AlertStatePending, ActiveAt: activeAt},
+		{Labels: labels("c"), PreviousState: AlertStateFiring, ActiveAt: activeAt},
+	}))
+
+	// The values are compared by their bits, as the stale markers are NaN values
+	received := make(map[string]uint64)
+	for _, s := range receiver.Series() {
+		require.Len(t, s.Samples, 1)
+		require.Equal(t, now.Unix()*1000, s.Samples[0].Timestamp)
+		received[fmt.Sprint(s.Labels)] = math.Float64bits(s.Samples[0].Value)
+	}
+	series := func(name, instance, state string) string {
+		l := labels(instance)
+		l["__name__"] = name
+		if state != "" {
+			l["alertstate"] = state
+		}
+		return fmt.Sprint(l)
+	}
+	require.Equal(t, map[string]uint64{
+		series(AlertsMetric, "a", AlertStatePending): math.Float64bits(1),
+		series(AlertsMetric, "b", AlertStateFiring):  math.Float64bits(1),
+		series(AlertsMetric, "b", AlertStatePending): value.StaleNaN,
+		series(AlertsMetric, "c", AlertStateFiring):  value.StaleNaN,
+		series(AlertsForStateMetric, "a", ""):        math.Float64bits(float64(activeAt.Unix())),
+		series(AlertsForStateMetric, "b", ""):        math.Float64bits(float64(activeAt.Unix())),
+		series(AlertsForStateMetric, "c", ""):        value.StaleNaN,
+	}, received)
+
+	t.Run("nothing is written without active alerts", func(t *testing.T) {
+		receiver.Reset()
+		require.NoError(t, w.WriteStates(context.Background(), now, nil))
+		require.Empty(t, receiver.Requests())
+	})
+}
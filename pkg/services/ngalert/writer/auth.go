@@ -0,0 +1,340 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana-aws-sdk/pkg/sigv4"
+	"github.com/grafana/grafana-azure-sdk-go/v2/azcredentials"
+	"github.com/grafana/grafana-azure-sdk-go/v2/azhttpclient"
+	"github.com/grafana/grafana-azure-sdk-go/v2/azsettings"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// The auth types of the built-in auth providers
+const (
+	AuthTypeBasic  = "basic"
+	AuthTypeBearer = "bearer"
+	AuthTypeOAuth2 = "oauth2"
+	AuthTypeSigV4  = "sigv4"
+	AuthTypeAzure  = "azure"
+)
+
+// AuthProvider authenticates the write requests to the targets whose auth type it is registered for.
+// Providers other than basic and bearer take their parameters from the auth subsection of the
+// target, see RecordingRuleTargetSettings.AuthParams.
+type AuthProvider interface {
+	// Validate returns the problems of the auth settings of a target. Their fields are relative to
+	// the section of the target, like auth.client_id.
+	Validate(target setting.RecordingRuleTargetSettings) []SettingError
+	// Configure sets up the authentication of the requests in the options of the HTTP client of a
+	// target, whose settings are valid and whose secrets are decrypted.
+	Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error
+}
+
+// AuthProviders holds the auth providers of writers, by auth type.
+type AuthProviders struct {
+	mtx       sync.RWMutex
+	providers map[string]AuthProvider
+}
+
+// DefaultAuthProviders are the auth providers of the writers of the targets of recording rules.
+// Providers are registered to it before the writers are created, when Grafana starts.
+var DefaultAuthProviders = NewAuthProviders()
+
+// NewAuthProviders returns the built-in auth providers: basic, bearer, oauth2, sigv4 and azure.
+func NewAuthProviders() *AuthProviders {
+	return &AuthProviders{providers: map[string]AuthProvider{
+		AuthTypeBasic:  basicAuth{},
+		AuthTypeBearer: bearerAuth{},
+		AuthTypeOAuth2: oauth2Auth{},
+		AuthTypeSigV4:  sigV4Auth{},
+		AuthTypeAzure:  azureAuth{},
+	}}
+}
+
+// Register registers the provider of an auth type. It fails when the type already has one.
+func (p *AuthProviders) Register(typ string, provider AuthProvider) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if _, ok := p.providers[typ]; ok {
+		return fmt.Errorf("an auth provider is already registered for recording rules targets with auth type %q", typ)
+	}
+	p.providers[typ] = provider
+	return nil
+}
+
+// Types returns the sorted auth types that have a provider.
+func (p *AuthProviders) Types() []string {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	return p.types()
+}
+
+// provider returns the provider of the auth type of target, nil when it has no authentication
+func (p *AuthProviders) provider(target setting.RecordingRuleTargetSettings) (AuthProvider, error) {
+	typ := authType(target)
+	if typ == "" {
+		return nil, nil
+	}
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	provider, ok := p.providers[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown auth type %q, must be one of %v", typ, p.types())
+	}
+	return provider, nil
+}
+
+func (p *AuthProviders) types() []string {
+	types := make([]string, 0, len(p.providers))
+	for typ := range p.providers {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// authType returns the auth type of target. When it is not set, it is basic or bearer when their
+// credentials are set, and empty otherwise.
+func authType(target setting.RecordingRuleTargetSettings) string {
+	switch {
+	case target.AuthType != "":
+		return target.AuthType
+	case target.BasicAuthUsername != "" || target.BasicAuthPassword != "":
+		return AuthTypeBasic
+	case target.BearerToken != "":
+		return AuthTypeBearer
+	default:
+		return ""
+	}
+}
+
+// basicAuth authenticates requests with the basic auth settings of the target
+type basicAuth struct{}
+
+func (basicAuth) Validate(target setting.RecordingRuleTargetSettings) []SettingError {
+	if target.BasicAuthUsername == "" {
+		return []SettingError{{Field: "basic_auth_username", Message: "is required with basic authentication"}}
+	}
+	return nil
+}
+
+func (basicAuth) AuthSchema() *Schema {
+	return &Schema{Required: []string{"basic_auth_username"}}
+}
+
+func (basicAuth) Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error {
+	opts.BasicAuth = &sdkhttpclient.BasicAuthOptions{
+		User:     target.BasicAuthUsername,
+		Password: target.BasicAuthPassword,
+	}
+	return nil
+}
+
+// bearerAuth sends the bearer token of the target in the Authorization header of requests
+type bearerAuth struct{}
+
+func (bearerAuth) Validate(target setting.RecordingRuleTargetSettings) []SettingError {
+	if target.BearerToken == "" {
+		return []SettingError{{Field: "bearer_token", Message: "is required with bearer authentication"}}
+	}
+	return nil
+}
+
+func (bearerAuth) AuthSchema() *Schema {
+	return &Schema{Required: []string{"bearer_token"}}
+}
+
+func (bearerAuth) Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error {
+	opts.Header.Set("Authorization", "Bearer "+target.BearerToken)
+	return nil
+}
+
+// oauth2Auth authenticates requests with the tokens of the OAuth2 client credentials flow. Its
+// parameters are client_id, client_secret, token_url and the comma-separated scopes.
+type oauth2Auth struct{}
+
+var oauth2RequiredParams = []string{"client_id", "client_secret", "token_url"}
+
+func (oauth2Auth) Validate(target setting.RecordingRuleTargetSettings) []SettingError {
+	return requiredAuthParams(target, oauth2RequiredParams...)
+}
+
+func (oauth2Auth) AuthSchema() *Schema {
+	return authParamsSchema(map[string]*Schema{
+		"client_id":     {Type: "string"},
+		"client_secret": {Type: "string", WriteOnly: true},
+		"token_url":     {Type: "string", Format: "uri"},
+		"scopes":        {Type: "string", Description: "The comma-separated scopes of the tokens."},
+	}, oauth2RequiredParams)
+}
+
+func (oauth2Auth) Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error {
+	config := clientcredentials.Config{
+		ClientID:     target.AuthParams["client_id"],
+		ClientSecret: target.AuthParams["client_secret"],
+		TokenURL:     target.AuthParams["token_url"],
+		Scopes:       util.SplitString(target.AuthParams["scopes"]),
+	}
+	opts.Middlewares = append(opts.Middlewares, sdkhttpclient.NamedMiddlewareFunc("OAuth2", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		// The tokens are requested with the transport of the target, so with its TLS and proxy
+		// settings, and they are cached and refreshed by the source
+		client := &http.Client{Transport: next}
+		if opts.Timeouts != nil {
+			client.Timeout = opts.Timeouts.Timeout
+		}
+		source := config.TokenSource(context.WithValue(context.Background(), oauth2.HTTPClient, client))
+		return &oauth2.Transport{Source: source, Base: next}
+	}))
+	return nil
+}
+
+// sigV4Auth signs requests with AWS Signature Version 4, like the ones to Amazon Managed Service
+// for Prometheus. Its parameters are the region, the service, aps by default, the auth type of
+// the AWS SDK, default or keys when access_key is set, access_key and secret_key, the profile of
+// the shared credentials, and the assume_role_arn and external_id of the role to assume.
+type sigV4Auth struct{}
+
+var (
+	sigV4RequiredParams = []string{"region"}
+	// The auth types of the AWS SDK
+	sigV4AuthTypes = []string{"default", "keys", "credentials", "ec2_iam_role"}
+)
+
+func (sigV4Auth) Validate(target setting.RecordingRuleTargetSettings) []SettingError {
+	errs := requiredAuthParams(target, sigV4RequiredParams...)
+	switch auth := target.AuthParams["auth"]; {
+	case auth == "" || auth == "keys":
+		if auth == "keys" || target.AuthParams["access_key"] != "" || target.AuthParams["secret_key"] != "" {
+			errs = append(errs, requiredAuthParams(target, "access_key", "secret_key")...)
+		}
+	case !slices.Contains(sigV4AuthTypes, auth):
+		errs = append(errs, SettingError{Field: "auth.auth", Message: fmt.Sprintf("must be one of %s, got %q", strings.Join(sigV4AuthTypes, ", "), auth)})
+	}
+	return errs
+}
+
+func (sigV4Auth) AuthSchema() *Schema {
+	return authParamsSchema(map[string]*Schema{
+		"region":          {Type: "string"},
+		"service":         {Type: "string", Default: "aps"},
+		"auth":            {Type: "string", Enum: sigV4AuthTypes, Description: "The auth type of the AWS SDK, keys when access_key is set and default otherwise."},
+		"access_key":      {Type: "string"},
+		"secret_key":      {Type: "string", WriteOnly: true},
+		"profile":         {Type: "string"},
+		"assume_role_arn": {Type: "string"},
+		"external_id":     {Type: "string"},
+	}, sigV4RequiredParams)
+}
+
+func (sigV4Auth) Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error {
+	config := &sigv4.Config{
+		AuthType:      target.AuthParams["auth"],
+		Profile:       target.AuthParams["profile"],
+		Service:       target.AuthParams["service"],
+		AccessKey:     target.AuthParams["access_key"],
+		SecretKey:     target.AuthParams["secret_key"],
+		AssumeRoleARN: target.AuthParams["assume_role_arn"],
+		ExternalID:    target.AuthParams["external_id"],
+		Region:        target.AuthParams["region"],
+	}
+	if config.Service == "" {
+		config.Service = "aps"
+	}
+	if config.AuthType == "" {
+		config.AuthType = "default"
+		if config.AccessKey != "" {
+			config.AuthType = "keys"
+		}
+	}
+	// Invalid settings fail the creation of the client, not its requests. The middleware creates
+	// the round tripper again with the next one
+	if _, err := sigv4.New(config, nil); err != nil {
+		return fmt.Errorf("invalid SigV4 settings: %w", err)
+	}
+	// Signed last, once the other middlewares set the headers of the request
+	opts.Middlewares = append(opts.Middlewares, sdkhttpclient.NamedMiddlewareFunc("SigV4", func(_ sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		rt, err := sigv4.New(config, next)
+		if err != nil {
+			return sdkhttpclient.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("invalid SigV4 settings: %w", err)
+			})
+		}
+		return rt
+	}))
+	return nil
+}
+
+// azureAuth authenticates requests with the tokens of Microsoft Entra ID, like the ones to Azure
+// Monitor workspaces. Its parameters are the comma-separated scopes of the tokens, the tenant_id,
+// client_id and client_secret of an app registration, and the cloud, AzureCloud by default. The
+// managed identity of Grafana, or its user-assigned one with client_id, is used when client_secret
+// is not set.
+type azureAuth struct{}
+
+var azureRequiredParams = []string{"scopes"}
+
+func (azureAuth) Validate(target setting.RecordingRuleTargetSettings) []SettingError {
+	errs := requiredAuthParams(target, azureRequiredParams...)
+	if target.AuthParams["client_secret"] != "" {
+		errs = append(errs, requiredAuthParams(target, "tenant_id", "client_id")...)
+	}
+	return errs
+}
+
+func (azureAuth) AuthSchema() *Schema {
+	return authParamsSchema(map[string]*Schema{
+		"scopes":        {Type: "string", Description: "The comma-separated scopes of the tokens."},
+		"tenant_id":     {Type: "string"},
+		"client_id":     {Type: "string"},
+		"client_secret": {Type: "string", WriteOnly: true, Description: "The secret of the app registration, the managed identity of Grafana is used when it is not set."},
+		"cloud":         {Type: "string", Default: azsettings.AzurePublic},
+	}, azureRequiredParams)
+}
+
+func (azureAuth) Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error {
+	settings := &azsettings.AzureSettings{Cloud: azsettings.AzurePublic}
+	var credentials azcredentials.AzureCredentials
+	if target.AuthParams["client_secret"] != "" {
+		cloud := target.AuthParams["cloud"]
+		if cloud == "" {
+			cloud = azsettings.AzurePublic
+		}
+		credentials = &azcredentials.AzureClientSecretCredentials{
+			AzureCloud:   cloud,
+			TenantId:     target.AuthParams["tenant_id"],
+			ClientId:     target.AuthParams["client_id"],
+			ClientSecret: target.AuthParams["client_secret"],
+		}
+	} else {
+		settings.ManagedIdentityEnabled = true
+		settings.ManagedIdentityClientId = target.AuthParams["client_id"]
+		credentials = &azcredentials.AzureManagedIdentityCredentials{ClientId: target.AuthParams["client_id"]}
+	}
+	authOpts := azhttpclient.NewAuthOptions(settings)
+	authOpts.Scopes(util.SplitString(target.AuthParams["scopes"]))
+	azhttpclient.AddAzureAuthentication(opts, authOpts, credentials)
+	return nil
+}
+
+// requiredAuthParams returns the errors of the auth parameters of target that are not set
+func requiredAuthParams(target setting.RecordingRuleTargetSettings, params ...string) []SettingError {
+	var errs []SettingError
+	for _, param := range params {
+		if target.AuthParams[param] == "" {
+			errs = append(errs, SettingError{Field: "auth." + param, Message: fmt.Sprintf("is required with %s authentication", target.AuthType)})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,121 @@
+package writer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+type headerAuth struct{}
+
+func (headerAuth) Validate(target setting.RecordingRuleTargetSettings) []SettingError {
+	return requiredAuthParams(target, "key")
+}
+
+func (headerAuth) Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error {
+	opts.Header.Set("X-Api-Key", target.AuthParams["key"])
+	return nil
+}
+
+func TestAuthProviders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"foo": "1"}})
+	write := func(t *testing.T, settings setting.RecordingRuleTargetSettings) {
+		t.Helper()
+		settings.URL, settings.Timeout = server.URL, time.Second
+		writer, err := NewPrometheusWriter(settings, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+	}
+
+	t.Run("auth type is inferred from the credentials", func(t *testing.T) {
+		require.Equal(t, AuthTypeBasic, authType(setting.RecordingRuleTargetSettings{BasicAuthUsername: "user"}))
+		require.Equal(t, AuthTypeBearer, authType(setting.RecordingRuleTargetSettings{BearerToken: "token"}))
+		require.Equal(t, AuthTypeOAuth2, authType(setting.RecordingRuleTargetSettings{AuthType: AuthTypeOAuth2, BearerToken: "token"}))
+		require.Empty(t, authType(setting.RecordingRuleTargetSettings{}))
+
+		write(t, setting.RecordingRuleTargetSettings{})
+		require.Empty(t, header.Get("Authorization"))
+	})
+
+	t.Run("oauth2", func(t *testing.T) {
+		tokens := 0
+		idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokens++
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			require.Equal(t, "metrics:write", r.Form.Get("scope"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "access-token", "token_type": "bearer", "expires_in": 3600}`))
+		}))
+		defer idp.Close()
+
+		settings := setting.RecordingRuleTargetSettings{
+			AuthType:   AuthTypeOAuth2,
+			AuthParams: map[string]string{"client_id": "grafana", "client_secret": "secret", "token_url": idp.URL, "scopes": "metrics:write"},
+		}
+		write(t, settings)
+		require.Equal(t, "Bearer access-token", header.Get("Authorization"))
+		require.Equal(t, 1, tokens)
+
+		// The tokens are requested with the TLS settings of the target
+		tlsIDP := httptest.NewTLSServer(idp.Config.Handler)
+		defer tlsIDP.Close()
+		settings.AuthParams["token_url"] = tlsIDP.URL
+		settings.TLSSkipVerify = true
+		write(t, settings)
+		require.Equal(t, "Bearer access-token", header.Get("Authorization"))
+		require.Equal(t, 2, tokens)
+	})
+
+	t.Run("sigv4", func(t *testing.T) {
+		write(t, setting.RecordingRuleTargetSettings{
+			AuthType:   AuthTypeSigV4,
+			AuthParams: map[string]string{"region": "us-east-1", "access_key": "key", "secret_key": "secret"},
+		})
+		require.True(t, strings.HasPrefix(header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/"), header.Get("Authorization"))
+		require.Contains(t, header.Get("Authorization"), "/us-east-1/aps/aws4_request")
+
+		err := sigV4Auth{}.Configure(setting.RecordingRuleTargetSettings{
+			AuthType:   AuthTypeSigV4,
+			AuthParams: map[string]string{"region": "us-east-1", "auth": "unknown"},
+		}, &sdkhttpclient.Options{})
+		require.ErrorContains(t, err, "invalid SigV4 settings")
+	})
+
+	t.Run("registered providers", func(t *testing.T) {
+		providers := DefaultAuthProviders
+		t.Cleanup(func() { DefaultAuthProviders = providers })
+		DefaultAuthProviders = NewAuthProviders()
+
+		require.NoError(t, DefaultAuthProviders.Register("header", headerAuth{}))
+		require.Error(t, DefaultAuthProviders.Register("header", headerAuth{}))
+		require.Error(t, DefaultAuthProviders.Register(AuthTypeBasic, headerAuth{}))
+		require.Equal(t, []string{"azure", "basic", "bearer", "header", "oauth2", "sigv4"}, DefaultAuthProviders.Types())
+
+		settings := setting.RecordingRuleTargetSettings{AuthType: "header", AuthParams: map[string]string{"key": "api-key"}}
+		write(t, settings)
+		require.Equal(t, "api-key", header.Get("X-Api-Key"))
+
+		settings.AuthParams = nil
+		settings.URL, settings.Timeout = server.URL, time.Second
+		_, err := NewPrometheusWriter(settings, nil, nil, log.NewNopLogger())
+		require.ErrorContains(t, err, "auth.key: is required with header authentication")
+	})
+}
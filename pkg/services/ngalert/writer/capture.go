@@ -0,0 +1,191 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/prometheus/prometheus/prompb"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// MaxCapturedWrites bounds the number of writes of a rule that can be captured at once
+const MaxCapturedWrites = 100
+
+// errCaptureUnsupported is the error of the captured writes to targets whose writer cannot
+// capture them, which were sent
+var errCaptureUnsupported = errors.New("the writer of the target cannot capture writes, the write was sent")
+
+// CapturedWrite is a write of a recording rule that was captured instead of being sent
+type CapturedWrite struct {
+	// The name of the target, empty for the default one
+	Target string
+	Name   string
+	// The time of the evaluation whose results were written
+	Time time.Time
+	// The requests that would have been sent, one for each batch
+	Requests []CapturedRequest
+	// Why nothing would have been sent, like a conversion error or a sample too old
+	Err error
+}
+
+// CapturedRequest is an encoded write request, decoded back to its series
+type CapturedRequest struct {
+	// The size of the request as it would have been sent, compressed
+	EncodedBytes int
+	Series       []CapturedSeries
+}
+
+// CapturedSeries is a series of a captured write request
+type CapturedSeries struct {
+	Labels  map[string]string
+	Samples []CapturedSample
+}
+
+// CapturedSample is a sample of a captured series
+type CapturedSample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// ruleCapture holds the captured writes of a rule, and how many remain to be captured
+type ruleCapture struct {
+	remaining int
+	writes    []CapturedWrite
+}
+
+// PayloadCapture captures the next writes of the recording rules it is asked to, to debug the
+// differences between their results and what their targets store. The write requests of a captured
+// write are encoded as they would be sent, decoded back to their series, and not sent.
+// Only the writes to Prometheus targets can be captured, the other ones are sent.
+type PayloadCapture struct {
+	next targetWriter
+
+	mtx      sync.Mutex
+	captures map[ngmodels.AlertRuleKey]*ruleCapture
+}
+
+// NewPayloadCapture returns a PayloadCapture of the writes of next
+func NewPayloadCapture(next targetWriter) *PayloadCapture {
+	return &PayloadCapture{
+		next:     next,
+		captures: make(map[ngmodels.AlertRuleKey]*ruleCapture),
+	}
+}
+
+// Capture captures the next n writes of rule, discarding its writes captured before.
+func (c *PayloadCapture) Capture(rule ngmodels.AlertRuleKey, n int) error {
+	if n <= 0 || n > MaxCapturedWrites {
+		return fmt.Errorf("the number of writes to capture must be between 1 and %d: %d", MaxCapturedWrites, n)
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.captures[rule] = &ruleCapture{remaining: n}
+	return nil
+}
+
+// Captured returns the writes of rule captured since Capture, and how many remain to be captured.
+// It returns false when its writes were never captured.
+func (c *PayloadCapture) Captured(rule ngmodels.AlertRuleKey) ([]CapturedWrite, int, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	capture, ok := c.captures[rule]
+	if !ok {
+		return nil, 0, false
+	}
+	return append([]CapturedWrite(nil), capture.writes...), capture.remaining, true
+}
+
+// Write writes the given frames to target with next, unless the write of the rule of ctx is captured
+func (c *PayloadCapture) Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	rule, ok := ngmodels.RuleKeyFromContext(ctx)
+	if !ok {
+		return c.next.Write(ctx, target, name, t, frames, extraLabels)
+	}
+	capture := c.take(rule)
+	if capture == nil {
+		return c.next.Write(ctx, target, name, t, frames, extraLabels)
+	}
+
+	w := &CapturedWrite{Target: target, Name: name, Time: t}
+	captured := false
+	err := c.next.Write(context.WithValue(ctx, captureKey{}, func(batches []promremote.TSList) error {
+		captured = true
+		return w.capture(batches)
+	}), target, name, t, frames, extraLabels)
+	switch {
+	case err != nil:
+		w.Err = err
+	case !captured:
+		w.Err = errCaptureUnsupported
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	// Not kept when the capture was restarted in the meantime
+	if c.captures[rule] == capture {
+		capture.writes = append(capture.writes, *w)
+	}
+	return nil
+}
+
+// take returns the capture of the next write of rule, nil when it is not captured
+func (c *PayloadCapture) take(rule ngmodels.AlertRuleKey) *ruleCapture {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	capture, ok := c.captures[rule]
+	if !ok || capture.remaining == 0 {
+		return nil
+	}
+	capture.remaining--
+	return capture
+}
+
+type captureKey struct{}
+
+// capturedBatches returns the function capturing the batches of a write instead of sending them,
+// when the write is captured
+func capturedBatches(ctx context.Context) (func([]promremote.TSList) error, bool) {
+	capture, ok := ctx.Value(captureKey{}).(func([]promremote.TSList) error)
+	return capture, ok
+}
+
+// capture encodes the requests of batches as the remote write client does, and decodes them back
+func (w *CapturedWrite) capture(batches []promremote.TSList) error {
+	for _, batch := range batches {
+		b, err := writeRequest(batch).Marshal()
+		if err != nil {
+			return fmt.Errorf("unable to marshal protobuf: %w", err)
+		}
+		encoded := snappy.Encode(nil, b)
+
+		decoded, err := snappy.Decode(nil, encoded)
+		if err != nil {
+			return fmt.Errorf("unable to decode the write request: %w", err)
+		}
+		var req prompb.WriteRequest
+		if err := req.Unmarshal(decoded); err != nil {
+			return fmt.Errorf("unable to unmarshal the write request: %w", err)
+		}
+
+		r := CapturedRequest{EncodedBytes: len(encoded), Series: make([]CapturedSeries, 0, len(req.Timeseries))}
+		for _, ts := range req.Timeseries {
+			s := CapturedSeries{Labels: make(map[string]string, len(ts.Labels)), Samples: make([]CapturedSample, 0, len(ts.Samples))}
+			for _, l := range ts.Labels {
+				s.Labels[l.Name] = l.Value
+			}
+			for _, sample := range ts.Samples {
+				s.Samples = append(s.Samples, CapturedSample{Timestamp: time.UnixMilli(sample.Timestamp).UTC(), Value: sample.Value})
+			}
+			r.Series = append(r.Series, s)
+		}
+		w.Requests = append(w.Requests, r)
+	}
+	return nil
+}
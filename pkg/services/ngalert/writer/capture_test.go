@@ -0,0 +1,87 @@
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestPayloadCapture(t *testing.T) {
+	writer, receiver := newReceiverWriter(t, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteBatching))
+	capture := NewPayloadCapture(targetWriteFunc(func(ctx context.Context, _, name string, ts time.Time, frames data.Frames, extraLabels map[string]string) error {
+		return writer.Write(ctx, name, ts, frames, extraLabels)
+	}))
+
+	rule := ngmodels.AlertRuleKey{OrgID: 1, UID: "rule"}
+	ctx := ngmodels.WithRuleKey(context.Background(), rule)
+	frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, []map[string]string{{"instance": "a"}})
+	now := time.Now()
+
+	_, _, ok := capture.Captured(rule)
+	require.False(t, ok)
+	require.Error(t, capture.Capture(rule, 0))
+	require.Error(t, capture.Capture(rule, MaxCapturedWrites+1))
+
+	require.NoError(t, capture.Capture(rule, 2))
+	// The writes of other rules are sent
+	require.NoError(t, capture.Write(ngmodels.WithRuleKey(context.Background(), ngmodels.AlertRuleKey{OrgID: 1, UID: "other"}), "", "test_metric", now, frames, nil))
+	require.Len(t, receiver.Requests(), 1)
+	receiver.Reset()
+
+	require.NoError(t, capture.Write(ctx, "", "test_metric", now, frames, map[string]string{"rule": "test"}))
+	writes, remaining, ok := capture.Captured(rule)
+	require.True(t, ok)
+	require.Equal(t, 1, remaining)
+	require.Len(t, writes, 1)
+	require.Empty(t, receiver.Requests())
+
+	w := writes[0]
+	require.NoError(t, w.Err)
+	require.Equal(t, "test_metric", w.Name)
+	require.Len(t, w.Requests, 1)
+	require.Positive(t, w.Requests[0].EncodedBytes)
+	require.Equal(t, []CapturedSeries{{
+		Labels:  map[string]string{"__name__": "test_metric", "instance": "a", "rule": "test"},
+		Samples: []CapturedSample{{Timestamp: time.Unix(now.Unix(), 0).UTC(), Value: extractValue(t, frames, map[string]string{"instance": "a"}, data.FrameTypeNumericMulti)}},
+	}}, w.Requests[0].Series)
+
+	// Writes that would not be sent are captured with their error
+	tooOld, _ := newReceiverWriter(t, nil, func(s *setting.RecordingRuleTargetSettings) { s.MaxSampleAge = time.Minute })
+	capture.next = targetWriteFunc(func(ctx context.Context, _, name string, ts time.Time, frames data.Frames, extraLabels map[string]string) error {
+		return tooOld.Write(ctx, name, ts, frames, extraLabels)
+	})
+	require.NoError(t, capture.Write(ctx, "", "test_metric", now.Add(-time.Hour), frames, nil))
+	writes, remaining, _ = capture.Captured(rule)
+	require.Zero(t, remaining)
+	require.Len(t, writes, 2)
+	require.ErrorIs(t, writes[1].Err, ErrSampleTooOld)
+
+	// Once all the writes are captured, they are sent again
+	capture.next = targetWriteFunc(func(ctx context.Context, _, name string, ts time.Time, frames data.Frames, extraLabels map[string]string) error {
+		return writer.Write(ctx, name, ts, frames, extraLabels)
+	})
+	require.NoError(t, capture.Write(ctx, "", "test_metric", now, frames, nil))
+	require.Len(t, receiver.Requests(), 1)
+
+	t.Run("writers that cannot capture writes send them", func(t *testing.T) {
+		sent := false
+		capture := NewPayloadCapture(targetWriteFunc(func(context.Context, string, string, time.Time, data.Frames, map[string]string) error {
+			sent = true
+			return nil
+		}))
+		require.NoError(t, capture.Capture(rule, 1))
+		require.NoError(t, capture.Write(ctx, "other", "test_metric", now, frames, nil))
+		require.True(t, sent)
+		writes, _, _ := capture.Captured(rule)
+		require.Len(t, writes, 1)
+		require.Equal(t, "other", writes[0].Target)
+		require.ErrorIs(t, writes[0].Err, errCaptureUnsupported)
+	})
+}
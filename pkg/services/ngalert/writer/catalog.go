@@ -0,0 +1,160 @@
+package writer
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// MetricCatalogRetention is how long a metric stays in the catalog after the last write of a rule,
+// so the metrics of deleted rules and of rules writing other metrics eventually leave it
+const MetricCatalogRetention = 24 * time.Hour
+
+// RecordedMetric is a metric written by recording rules to a target
+type RecordedMetric struct {
+	// The name of the target, empty for the default one
+	Target string
+	Name   string
+	// The names of the labels of its series, sorted
+	Labels []string
+	// The rules writing it, sorted by UID
+	Rules []RecordedMetricRule
+}
+
+// RecordedMetricRule is a rule writing a recorded metric
+type RecordedMetricRule struct {
+	UID string
+	// The time of the evaluation of the last write of the rule
+	LastWrite time.Time
+	// The number of series of the last write of the rule
+	Series int
+}
+
+// catalogKey identifies a metric of an organization in the catalog
+type catalogKey struct {
+	orgID  int64
+	target string
+	name   string
+}
+
+// catalogRule is what the catalog knows of the writes of a metric by a rule
+type catalogRule struct {
+	labels    map[string]struct{}
+	lastWrite time.Time
+	series    int
+}
+
+// MetricCatalog keeps the names and labels of the metrics recording rules write, and the rules writing
+// them, so users can find the metrics that are recorded and the rules producing them. Only the writes
+// that succeeded are kept, and the ones that were captured are not.
+type MetricCatalog struct {
+	next targetWriter
+	now  func() time.Time
+
+	mtx     sync.Mutex
+	metrics map[catalogKey]map[string]*catalogRule
+}
+
+// NewMetricCatalog returns a MetricCatalog of the writes of next
+func NewMetricCatalog(next targetWriter) *MetricCatalog {
+	return &MetricCatalog{
+		next:    next,
+		now:     time.Now,
+		metrics: make(map[catalogKey]map[string]*catalogRule),
+	}
+}
+
+// Write writes the given frames to target with next, and adds the metric to the catalog of the
+// organization of the rule of ctx when it is written.
+func (c *MetricCatalog) Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	if err := c.next.Write(ctx, target, name, t, frames, extraLabels); err != nil {
+		return err
+	}
+	rule, ok := ngmodels.RuleKeyFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if _, captured := capturedBatches(ctx); captured {
+		return nil
+	}
+	// The frames were written, so they can be read
+	samples, err := samplesFromFrames(ctx, "", frameFilterFromContext(ctx).Apply(frames))
+	if err != nil {
+		return nil
+	}
+	labels := make(map[string]struct{}, len(extraLabels))
+	for _, s := range samples {
+		for k := range s.Labels {
+			labels[k] = struct{}{}
+		}
+	}
+	for k := range extraLabels {
+		labels[k] = struct{}{}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	key := catalogKey{orgID: rule.OrgID, target: target, name: name}
+	rules, ok := c.metrics[key]
+	if !ok {
+		rules = make(map[string]*catalogRule)
+		c.metrics[key] = rules
+	}
+	rules[rule.UID] = &catalogRule{labels: labels, lastWrite: t, series: len(samples)}
+	return nil
+}
+
+// Metrics returns the metrics recorded in an organization, sorted by target and name. The metrics of
+// the rules that have not written them for MetricCatalogRetention are removed.
+func (c *MetricCatalog) Metrics(orgID int64) []RecordedMetric {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.expire()
+
+	var metrics []RecordedMetric
+	for key, rules := range c.metrics {
+		if key.orgID != orgID {
+			continue
+		}
+		m := RecordedMetric{Target: key.target, Name: key.name, Rules: make([]RecordedMetricRule, 0, len(rules))}
+		labels := make(map[string]struct{})
+		for uid, r := range rules {
+			m.Rules = append(m.Rules, RecordedMetricRule{UID: uid, LastWrite: r.lastWrite, Series: r.series})
+			for l := range r.labels {
+				labels[l] = struct{}{}
+			}
+		}
+		m.Labels = make([]string, 0, len(labels))
+		for l := range labels {
+			m.Labels = append(m.Labels, l)
+		}
+		slices.Sort(m.Labels)
+		slices.SortFunc(m.Rules, func(a, b RecordedMetricRule) int { return cmp.Compare(a.UID, b.UID) })
+		metrics = append(metrics, m)
+	}
+	slices.SortFunc(metrics, func(a, b RecordedMetric) int {
+		return cmp.Or(cmp.Compare(a.Target, b.Target), cmp.Compare(a.Name, b.Name))
+	})
+	return metrics
+}
+
+// expire removes the writes older than MetricCatalogRetention, c.mtx must be held
+func (c *MetricCatalog) expire() {
+	oldest := c.now().Add(-MetricCatalogRetention)
+	for key, rules := range c.metrics {
+		for uid, r := range rules {
+			if r.lastWrite.Before(oldest) {
+				delete(rules, uid)
+			}
+		}
+		if len(rules) == 0 {
+			delete(c.metrics, key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/stretchr/testify/require"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+func TestMetricCatalog(t *testing.T) {
+	var writeErr error
+	catalog := NewMetricCatalog(targetWriteFunc(func(context.Context, string, string, time.Time, data.Frames, map[string]string) error {
+		return writeErr
+	}))
+	now := time.Now()
+	catalog.now = func() time.Time { return now }
+
+	ruleCtx := func(orgID int64, uid string) context.Context {
+		return ngmodels.WithRuleKey(context.Background(), ngmodels.AlertRuleKey{OrgID: orgID, UID: uid})
+	}
+	frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, []map[string]string{{"instance": "a"}, {"job": "api"}})
+
+	require.NoError(t, catalog.Write(ruleCtx(1, "b"), "", "requests", now, frames, map[string]string{"team": "web"}))
+	require.NoError(t, catalog.Write(ruleCtx(1, "a"), "", "requests", now.Add(-time.Minute), frames[:1], nil))
+	require.NoError(t, catalog.Write(ruleCtx(1, "a"), "mimir", "errors", now, frames[:1], nil))
+	require.NoError(t, catalog.Write(ruleCtx(2, "c"), "", "latency", now, frames, nil))
+	// The writes without rules are not of recording rules
+	require.NoError(t, catalog.Write(context.Background(), "", "ALERTS", now, frames, nil))
+
+	t.Run("writes that failed are not recorded", func(t *testing.T) {
+		writeErr = errors.New("failed")
+		t.Cleanup(func() { writeErr = nil })
+		require.Error(t, catalog.Write(ruleCtx(1, "d"), "", "failed", now, frames, nil))
+	})
+
+	t.Run("captured writes are not recorded", func(t *testing.T) {
+		ctx := context.WithValue(ruleCtx(1, "e"), captureKey{}, func([]promremote.TSList) error { return nil })
+		require.NoError(t, catalog.Write(ctx, "", "captured", now, frames, nil))
+	})
+
+	require.Equal(t, []RecordedMetric{
+		{
+			Name:   "requests",
+			Labels: []string{"instance", "job", "team"},
+			Rules: []RecordedMetricRule{
+				{UID: "a", LastWrite: now.Add(-time.Minute), Series: 1},
+				{UID: "b", LastWrite: now, Series: 2},
+			},
+		},
+		{
+			Target: "mimir",
+			Name:   "errors",
+			Labels: []string{"instance"},
+			Rules:  []RecordedMetricRule{{UID: "a", LastWrite: now, Series: 1}},
+		},
+	}, catalog.Metrics(1))
+
+	t.Run("writes older than the retention expire", func(t *testing.T) {
+		require.NoError(t, catalog.Write(ruleCtx(1, "a"), "", "expired", now.Add(-time.Minute), frames, nil))
+		now = now.Add(MetricCatalogRetention - time.Second)
+		metrics := catalog.Metrics(1)
+		require.Len(t, metrics, 2)
+		require.Equal(t, "requests", metrics[0].Name)
+		require.Equal(t, []RecordedMetricRule{{UID: "b", LastWrite: metrics[0].Rules[0].LastWrite, Series: 2}}, metrics[0].Rules)
+		require.Equal(t, "errors", metrics[1].Name)
+	})
+}
@@ -0,0 +1,203 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+)
+
+// maxPooledBufferSize bounds the size of the buffers kept for the next writes. The requests of the
+// few rules with larger results are encoded in buffers that are not reused, so they are not held
+// in memory by the pool.
+const maxPooledBufferSize = 8 << 20
+
+// maxErrorBodySize bounds the part of the body of a failed response included in its error
+const maxErrorBodySize = 4 << 10
+
+// bufferPool holds the buffers write requests are encoded in, shared by the writers of all the
+// targets as many rules write every few seconds
+var bufferPool = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// getBuffer returns a buffer of size from the pool
+func getBuffer(size int) *[]byte {
+	b := bufferPool.Get().(*[]byte)
+	if cap(*b) < size {
+		*b = make([]byte, size)
+	}
+	*b = (*b)[:size]
+	return b
+}
+
+// putBuffer returns b to the pool, unless it is too large to be kept
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(b)
+}
+
+// remoteWriteClient is a promremote.Client encoding its requests in pooled buffers. The client of
+// promremote allocates two buffers the size of the request for each write.
+// Despite its interface, its requests can be sent with the other protocols of Prometheus targets.
+type remoteWriteClient struct {
+	url        string
+	userAgent  string
+	httpClient *http.Client
+	// The sizes of the requests before and after their compression are observed when they are set
+	uncompressedBytes prometheus.Observer
+	compressedBytes   prometheus.Observer
+	// The protocol of the requests, the remote write protocol 1.0 when nil
+	protocols *protocolSelector
+}
+
+func newRemoteWriteClient(url string, httpClient *http.Client) *remoteWriteClient {
+	return &remoteWriteClient{
+		url:        url,
+		userAgent:  "grafana-recording-rule",
+		httpClient: httpClient,
+	}
+}
+
+// withSizeMetrics observes the sizes of the requests of c to target in m
+func (c *remoteWriteClient) withSizeMetrics(m *metrics.RemoteWriter, target string) *remoteWriteClient {
+	c.uncompressedBytes = m.RequestUncompressedBytes.WithLabelValues(target)
+	c.compressedBytes = m.RequestCompressedBytes.WithLabelValues(target)
+	return c
+}
+
+func (c *remoteWriteClient) WriteTimeSeries(ctx context.Context, series promremote.TSList, opts promremote.WriteOptions) (promremote.WriteResult, promremote.WriteError) {
+	return c.WriteProto(ctx, writeRequest(series), opts)
+}
+
+func (c *remoteWriteClient) WriteProto(ctx context.Context, wr *prompb.WriteRequest, opts promremote.WriteOptions) (promremote.WriteResult, promremote.WriteError) {
+	var result promremote.WriteResult
+
+	protocol, err := c.protocols.protocol(ctx)
+	if err != nil {
+		return result, remoteWriteError{err: err}
+	}
+	data := getBuffer(0)
+	if *data, err = encodeRequest(protocol, wr, *data); err != nil {
+		putBuffer(data)
+		return result, remoteWriteError{err: err}
+	}
+	// OTLP requests are not compressed
+	size, payload, buffer := len(*data), *data, data
+	if protocol != ProtocolOTLP {
+		encoded := getBuffer(snappy.MaxEncodedLen(size))
+		payload, buffer = snappy.Encode(*encoded, *data), encoded
+		putBuffer(data)
+	}
+	if c.compressedBytes != nil {
+		c.uncompressedBytes.Observe(float64(size))
+		c.compressedBytes.Observe(float64(len(payload)))
+	}
+
+	// The transport may still be sending the body once the response is received, so the buffer
+	// is only reused once it closed it
+	body := &pooledBody{Reader: bytes.NewReader(payload), release: sync.OnceFunc(func() { putBuffer(buffer) })}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, body)
+	if err != nil {
+		_ = body.Close()
+		return result, remoteWriteError{err: err}
+	}
+	req.ContentLength = int64(len(payload))
+	setProtocolHeaders(req, protocol)
+	req.Header.Set("User-Agent", c.userAgent)
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return result, remoteWriteError{err: err}
+	}
+	defer func() {
+		// Drained so the connection can be reused
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+
+	result.StatusCode = res.StatusCode
+	if res.StatusCode/100 != 2 {
+		b, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+		if err != nil {
+			return result, remoteWriteError{err: fmt.Errorf("expected HTTP 200 status code: actual=%d, body_read_error=%s", res.StatusCode, err), code: res.StatusCode}
+		}
+		return result, remoteWriteError{err: fmt.Errorf("expected HTTP 200 status code: actual=%d, body=%s", res.StatusCode, b), code: res.StatusCode}
+	}
+	return result, nil
+}
+
+// writeRequest converts series to a remote write request
+func writeRequest(series promremote.TSList) *prompb.WriteRequest {
+	ts := make([]prompb.TimeSeries, len(series))
+	// The labels and samples of all the series share the same slices
+	labels := make([]prompb.Label, 0, labelCount(series))
+	samples := make([]prompb.Sample, len(series))
+	for i, s := range series {
+		start := len(labels)
+		for _, l := range s.Labels {
+			labels = append(labels, prompb.Label{Name: l.Name, Value: l.Value})
+		}
+		// Strict receivers reject series whose labels are not sorted by name
+		if !slices.IsSortedFunc(s.Labels, compareLabel) {
+			slices.SortFunc(labels[start:], func(a, b prompb.Label) int { return strings.Compare(a.Name, b.Name) })
+		}
+		samples[i] = prompb.Sample{Timestamp: s.Datapoint.Timestamp.UnixMilli(), Value: s.Datapoint.Value}
+		ts[i] = prompb.TimeSeries{Labels: labels[start:len(labels):len(labels)], Samples: samples[i : i+1 : i+1]}
+	}
+	return &prompb.WriteRequest{Timeseries: ts}
+}
+
+func labelCount(series promremote.TSList) int {
+	n := 0
+	for _, s := range series {
+		n += len(s.Labels)
+	}
+	return n
+}
+
+// pooledBody is the body of a request encoded in a pooled buffer, released when it is closed
+type pooledBody struct {
+	*bytes.Reader
+	release func()
+}
+
+func (b *pooledBody) Close() error {
+	b.release()
+	return nil
+}
+
+// remoteWriteError is the promremote.WriteError of a write, with the status of its response if
+// it failed because of it
+type remoteWriteError struct {
+	err  error
+	code int
+}
+
+func (e remoteWriteError) Error() string {
+	return e.err.Error()
+}
+
+func (e remoteWriteError) Unwrap() error {
+	return e.err
+}
+
+func (e remoteWriteError) StatusCode() int {
+	return e.code
+}
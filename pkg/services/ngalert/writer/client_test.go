@@ -0,0 +1,85 @@
+package writer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteWriteClient(t *testing.T) {
+	var received []prompb.WriteRequest
+	var header http.Header
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		compressed, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, r.ContentLength, int64(len(compressed)))
+		b, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		var req prompb.WriteRequest
+		require.NoError(t, req.Unmarshal(b))
+		received = append(received, req)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("too many series"))
+	}))
+	defer server.Close()
+
+	client := newRemoteWriteClient(server.URL, server.Client())
+	now := time.Now()
+	series := func(n int) promremote.TSList {
+		list := make(promremote.TSList, n)
+		for i := range list {
+			list[i] = promremote.TimeSeries{
+				Labels:    []promremote.Label{{Name: "__name__", Value: "test_metric"}, {Name: "series", Value: string(rune('a' + i))}},
+				Datapoint: promremote.Datapoint{Timestamp: now, Value: float64(i)},
+			}
+		}
+		return list
+	}
+
+	// Requests of different sizes are encoded in the same buffers
+	for _, n := range []int{3, 1, 2} {
+		result, err := client.WriteTimeSeries(context.Background(), series(n), promremote.WriteOptions{Headers: map[string]string{"X-Scope-OrgID": "tenant"}})
+		require.Nil(t, err)
+		require.Equal(t, http.StatusNoContent, result.StatusCode)
+	}
+	require.Equal(t, "snappy", header.Get("Content-Encoding"))
+	require.Equal(t, "grafana-recording-rule", header.Get("User-Agent"))
+	require.Equal(t, "tenant", header.Get("X-Scope-OrgID"))
+
+	require.Len(t, received, 3)
+	require.Len(t, received[1].Timeseries, 1)
+	last := received[2].Timeseries
+	require.Len(t, last, 2)
+	require.Equal(t, []prompb.Label{{Name: "__name__", Value: "test_metric"}, {Name: "series", Value: "b"}}, last[1].Labels)
+	require.Equal(t, []prompb.Sample{{Timestamp: now.UnixMilli(), Value: 1}}, last[1].Samples)
+
+	t.Run("labels are sorted by name", func(t *testing.T) {
+		unsorted := promremote.TSList{{
+			Labels:    []promremote.Label{{Name: "job", Value: "node"}, {Name: "__name__", Value: "test_metric"}, {Name: "instance", Value: "a"}},
+			Datapoint: promremote.Datapoint{Timestamp: now, Value: 1},
+		}}
+		require.Equal(t, []prompb.Label{
+			{Name: "__name__", Value: "test_metric"},
+			{Name: "instance", Value: "a"},
+			{Name: "job", Value: "node"},
+		}, writeRequest(unsorted).Timeseries[0].Labels)
+	})
+
+	t.Run("failed writes have the status and body of their response", func(t *testing.T) {
+		status = http.StatusBadRequest
+		_, err := client.WriteTimeSeries(context.Background(), series(1), promremote.WriteOptions{})
+		require.Error(t, err)
+		require.Equal(t, http.StatusBadRequest, err.StatusCode())
+		require.ErrorContains(t, err, "actual=400, body=too many series")
+	})
+}
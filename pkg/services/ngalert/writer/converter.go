@@ -0,0 +1,150 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Sample is a series of the results of a recording rule, converted from their frames
+type Sample struct {
+	// The labels of the series, without the name of the metric
+	Labels data.Labels
+	Value  float64
+	// The time of the data of the series, zero when the frames have none. It is only written when the
+	// timestamp source of the target is TimestampSourceFrame.
+	Time time.Time
+}
+
+// FrameConverter converts the frames of the results of a recording rule to the samples written,
+// for frames the numeric data reader does not read, like the aggregations of Elasticsearch.
+type FrameConverter interface {
+	ConvertFrames(frames data.Frames) ([]Sample, error)
+}
+
+// FrameConverterFunc is a FrameConverter function
+type FrameConverterFunc func(frames data.Frames) ([]Sample, error)
+
+func (f FrameConverterFunc) ConvertFrames(frames data.Frames) ([]Sample, error) {
+	return f(frames)
+}
+
+// ConverterKey is what the frames a converter is registered for have. When one of its fields is
+// empty, the converter converts the frames with any value of it.
+type ConverterKey struct {
+	// The type of the first frame, see data.FrameMeta
+	FrameType data.FrameType
+	// The type of the data source of the queries the frames are the results of, see WithDatasourceType
+	Datasource string
+}
+
+// FrameConverters holds the converters of frames, by the type of frames and data sources they convert.
+type FrameConverters struct {
+	mtx        sync.RWMutex
+	converters map[ConverterKey]FrameConverter
+}
+
+// DefaultFrameConverters are the converters of the frames written by recording rules. Converters are
+// registered to it before the writers are created, when Grafana starts. The frames without a converter
+// are read as numeric data.
+var DefaultFrameConverters = NewFrameConverters()
+
+// NewFrameConverters returns converters without any converter
+func NewFrameConverters() *FrameConverters {
+	return &FrameConverters{converters: make(map[ConverterKey]FrameConverter)}
+}
+
+// Register registers the converter of the frames of key. It fails when key already has one.
+func (c *FrameConverters) Register(key ConverterKey, converter FrameConverter) error {
+	if key == (ConverterKey{}) {
+		return errors.New("a frame converter must be registered for a frame type or a data source type")
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.converters[key]; ok {
+		return fmt.Errorf("a frame converter is already registered for frames of type %q of data sources of type %q", key.FrameType, key.Datasource)
+	}
+	c.converters[key] = converter
+	return nil
+}
+
+// converter returns the converter of frames from datasource. The converters of both their frame type
+// and datasource come first, then the ones of datasource and then the ones of their frame type.
+func (c *FrameConverters) converter(frames data.Frames, datasource string) (FrameConverter, bool) {
+	var frameType data.FrameType
+	if len(frames) > 0 && frames[0].Meta != nil {
+		frameType = frames[0].Meta.Type
+	}
+	keys := []ConverterKey{{FrameType: frameType, Datasource: datasource}, {Datasource: datasource}, {FrameType: frameType}}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	for _, key := range keys {
+		if key == (ConverterKey{}) {
+			continue
+		}
+		if converter, ok := c.converters[key]; ok {
+			return converter, true
+		}
+	}
+	return nil, false
+}
+
+// WithDatasourceType returns ctx with the type of the data source of the queries of the frames
+// written with it, so they are converted with the converters of the data source.
+func WithDatasourceType(ctx context.Context, datasource string) context.Context {
+	return context.WithValue(ctx, datasourceTypeKey{}, datasource)
+}
+
+type datasourceTypeKey struct{}
+
+func datasourceTypeFromContext(ctx context.Context) string {
+	datasource, _ := ctx.Value(datasourceTypeKey{}).(string)
+	return datasource
+}
+
+// samplesFromFrames returns the samples of frames, converted by their converter in DefaultFrameConverters
+// or read as numeric data. The times of the samples of numeric data are only set when the timestamp
+// source is TimestampSourceFrame.
+func samplesFromFrames(ctx context.Context, source string, frames data.Frames) ([]Sample, error) {
+	if converter, ok := DefaultFrameConverters.converter(frames, datasourceTypeFromContext(ctx)); ok {
+		samples, err := converter.ConvertFrames(frames)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert frames: %w", err)
+		}
+		return samples, ctx.Err()
+	}
+
+	refs, err := metricRefs(frames)
+	if err != nil {
+		return nil, err
+	}
+	var times map[*data.Field]time.Time
+	if source == TimestampSourceFrame {
+		times = frameTimes(frames)
+	}
+	samples := make([]Sample, 0, len(refs))
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f, err := metricValue(ref)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, Sample{Labels: ref.GetLabels(), Value: f, Time: times[ref.ValueField]})
+	}
+	return samples, nil
+}
+
+// sampleTime returns the time a sample is written with, the time of its data when source is
+// TimestampSourceFrame and it has one, and t otherwise
+func sampleTime(s Sample, t time.Time, source string) time.Time {
+	if source == TimestampSourceFrame && !s.Time.IsZero() {
+		return s.Time
+	}
+	return t
+}
@@ -0,0 +1,118 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+// namedConverter is a converter told apart from the others by its name
+type namedConverter string
+
+func (namedConverter) ConvertFrames(data.Frames) ([]Sample, error) {
+	return nil, nil
+}
+
+// aggregationsConverter converts the rows of frames of terms aggregations, with a string field of
+// the terms and a numeric field of their counts, like the ones of Elasticsearch
+var aggregationsConverter = FrameConverterFunc(func(frames data.Frames) ([]Sample, error) {
+	var samples []Sample
+	for _, frame := range frames {
+		if len(frame.Fields) != 2 {
+			return nil, errors.New("unexpected aggregations frame")
+		}
+		terms, counts := frame.Fields[0], frame.Fields[1]
+		for i := 0; i < frame.Rows(); i++ {
+			v, err := counts.FloatAt(i)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, Sample{Labels: data.Labels{terms.Name: terms.At(i).(string)}, Value: v})
+		}
+	}
+	return samples, nil
+})
+
+func TestFrameConverters(t *testing.T) {
+	converters := NewFrameConverters()
+	require.NoError(t, converters.Register(ConverterKey{FrameType: data.FrameTypeNumericWide}, namedConverter("wide")))
+	require.NoError(t, converters.Register(ConverterKey{Datasource: "elasticsearch"}, namedConverter("elasticsearch")))
+	require.NoError(t, converters.Register(ConverterKey{FrameType: data.FrameTypeNumericWide, Datasource: "elasticsearch"}, namedConverter("elasticsearch wide")))
+	require.ErrorContains(t, converters.Register(ConverterKey{Datasource: "elasticsearch"}, namedConverter("")), "already registered")
+	require.Error(t, converters.Register(ConverterKey{}, namedConverter("")))
+
+	frames := func(frameType data.FrameType) data.Frames {
+		f := data.NewFrame("")
+		f.SetMeta(&data.FrameMeta{Type: frameType})
+		return data.Frames{f}
+	}
+	converterOf := func(frames data.Frames, datasource string) FrameConverter {
+		c, _ := converters.converter(frames, datasource)
+		return c
+	}
+	require.Equal(t, namedConverter("elasticsearch wide"), converterOf(frames(data.FrameTypeNumericWide), "elasticsearch"))
+	require.Equal(t, namedConverter("elasticsearch"), converterOf(frames(data.FrameTypeNumericMulti), "elasticsearch"))
+	require.Equal(t, namedConverter("wide"), converterOf(frames(data.FrameTypeNumericWide), "prometheus"))
+	require.Equal(t, namedConverter("wide"), converterOf(frames(data.FrameTypeNumericWide), ""))
+	require.Nil(t, converterOf(frames(data.FrameTypeNumericMulti), "prometheus"))
+	require.Nil(t, converterOf(data.Frames{data.NewFrame("")}, ""))
+}
+
+func TestSeriesFromFramesConverter(t *testing.T) {
+	converters := DefaultFrameConverters
+	t.Cleanup(func() { DefaultFrameConverters = converters })
+	DefaultFrameConverters = NewFrameConverters()
+	require.NoError(t, DefaultFrameConverters.Register(ConverterKey{Datasource: "elasticsearch"}, aggregationsConverter))
+
+	now := time.Now()
+	frames := data.Frames{data.NewFrame("",
+		data.NewField("host", nil, []string{"a", "b"}),
+		data.NewField("count", nil, []float64{1, 2}),
+	)}
+	ctx := WithDatasourceType(context.Background(), "elasticsearch")
+
+	series, err := seriesFromFrames(ctx, "test_metric", now, "", frames, map[string]string{"team": "web"})
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	for i, host := range []string{"a", "b"} {
+		require.Equal(t, []string{"__name__", "host", "team"}, []string{series[i].Labels[0].Name, series[i].Labels[1].Name, series[i].Labels[2].Name})
+		require.Equal(t, host, series[i].Labels[1].Value)
+		require.Equal(t, float64(i+1), series[i].Datapoint.Value)
+		require.Equal(t, now.Unix(), series[i].Datapoint.Timestamp.Unix())
+	}
+
+	points, err := PointsFromFrames(ctx, "test_metric", now, "", frames, nil)
+	require.NoError(t, err)
+	require.Equal(t, []Point{
+		{Name: "test_metric", Labels: map[string]string{"host": "a"}, Metric: Metric{T: now.Unix(), V: 1}},
+		{Name: "test_metric", Labels: map[string]string{"host": "b"}, Metric: Metric{T: now.Unix(), V: 2}},
+	}, points)
+
+	t.Run("the times of the samples are written with the frame timestamp source", func(t *testing.T) {
+		delayed := now.Add(-time.Hour)
+		require.NoError(t, DefaultFrameConverters.Register(ConverterKey{Datasource: "cloudwatch"}, FrameConverterFunc(func(data.Frames) ([]Sample, error) {
+			return []Sample{{Value: 1, Time: delayed}}, nil
+		})))
+		ctx := WithDatasourceType(context.Background(), "cloudwatch")
+		series, err := seriesFromFrames(ctx, "test_metric", now, TimestampSourceFrame, frames, nil)
+		require.NoError(t, err)
+		require.Equal(t, delayed.Unix(), series[0].Datapoint.Timestamp.Unix())
+		series, err = seriesFromFrames(ctx, "test_metric", now, TimestampSourceEvaluation, frames, nil)
+		require.NoError(t, err)
+		require.Equal(t, now.Unix(), series[0].Datapoint.Timestamp.Unix())
+	})
+
+	t.Run("errors of converters fail the conversion", func(t *testing.T) {
+		_, err := seriesFromFrames(ctx, "test_metric", now, "", data.Frames{data.NewFrame("")}, nil)
+		require.ErrorContains(t, err, "failed to convert frames: unexpected aggregations frame")
+	})
+
+	t.Run("frames of other data sources are read as numeric data", func(t *testing.T) {
+		_, err := seriesFromFrames(WithDatasourceType(context.Background(), "prometheus"), "test_metric", now, "", frames, nil)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,111 @@
+package writer
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+)
+
+// WrittenSamples remembers the last sample written for each series of a recording rule, so the
+// samples written again with the same timestamp and value are not sent. This happens when the rule
+// is evaluated again in the same second, like when the scheduler catches up, or when its write is
+// retried after some of its batches failed. Targets would count them as duplicates or reject them.
+// The samples are compared after their timestamps are truncated to the second, as they are written,
+// so two evaluations of the same second with the same value write a single sample.
+// Only the series of the last write of the rule are remembered: when writes overlap, the one that
+// started last wins whatever the order they end in.
+type WrittenSamples struct {
+	mtx  sync.Mutex
+	last map[uint64]writtenSample
+	// The generation of the write last is remembered from, and of the last write started
+	lastGeneration    uint64
+	startedGeneration uint64
+}
+
+// pendingWrite is a write in progress of a rule, see WrittenSamples.skipWritten
+type pendingWrite struct {
+	samples    *WrittenSamples
+	generation uint64
+	// The series of the write that are known to be written
+	next map[uint64]writtenSample
+}
+
+type writtenSample struct {
+	timestamp int64
+	value     uint64
+}
+
+// NewWrittenSamples returns the WrittenSamples of a rule that did not write yet
+func NewWrittenSamples() *WrittenSamples {
+	return &WrittenSamples{last: make(map[uint64]writtenSample)}
+}
+
+type writtenSamplesKey struct{}
+
+// WithWrittenSamples returns ctx with the samples written by the rule it writes for, the ones
+// already written are then skipped by the writes with ctx.
+func WithWrittenSamples(ctx context.Context, samples *WrittenSamples) context.Context {
+	return context.WithValue(ctx, writtenSamplesKey{}, samples)
+}
+
+func writtenSamplesFromContext(ctx context.Context) *WrittenSamples {
+	samples, _ := ctx.Value(writtenSamplesKey{}).(*WrittenSamples)
+	return samples
+}
+
+// skipWritten starts a write, returning the series whose sample is not the last one written and
+// the number of the skipped ones. The series are filtered in place.
+// The series written by the write are recorded with the written method of the pending write, until
+// it is done.
+func (s *WrittenSamples) skipWritten(series promremote.TSList) (promremote.TSList, int, *pendingWrite) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.startedGeneration++
+	w := &pendingWrite{samples: s, generation: s.startedGeneration, next: make(map[uint64]writtenSample, len(series))}
+	kept := series[:0]
+	for _, ts := range series {
+		h, sample := seriesSample(ts)
+		if last, ok := s.last[h]; ok && last == sample {
+			w.next[h] = sample
+			continue
+		}
+		kept = append(kept, ts)
+	}
+	return kept, len(series) - len(kept), w
+}
+
+// written records that a batch of the write was written
+func (w *pendingWrite) written(batch promremote.TSList) {
+	w.samples.mtx.Lock()
+	defer w.samples.mtx.Unlock()
+	for _, ts := range batch {
+		h, sample := seriesSample(ts)
+		w.next[h] = sample
+	}
+}
+
+// done ends the write, its written series are the ones that are remembered unless a write
+// started after it already ended
+func (w *pendingWrite) done() {
+	w.samples.mtx.Lock()
+	defer w.samples.mtx.Unlock()
+	if w.generation > w.samples.lastGeneration {
+		w.samples.last, w.samples.lastGeneration = w.next, w.generation
+	}
+}
+
+// seriesSample returns the hash of the labels of a series, which are sorted, and its sample
+func seriesSample(ts promremote.TimeSeries) (uint64, writtenSample) {
+	h := fnv.New64a()
+	for _, l := range ts.Labels {
+		_, _ = h.Write([]byte(l.Name))
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(l.Value))
+		_, _ = h.Write([]byte{0xff})
+	}
+	// Values are compared by their bits, as NaN values are not equal to themselves
+	return h.Sum64(), writtenSample{timestamp: ts.Datapoint.Timestamp.UnixMilli(), value: math.Float64bits(ts.Datapoint.Value)}
+}
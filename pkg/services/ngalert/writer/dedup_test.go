@@ -0,0 +1,92 @@
+package writer
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrittenSamples(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sample := func(job string, t time.Time, value float64) promremote.TimeSeries {
+		return promremote.TimeSeries{
+			Labels: []promremote.Label{
+				{Name: "__name__", Value: "test_metric"},
+				{Name: "job", Value: job},
+			},
+			Datapoint: promremote.Datapoint{Timestamp: t, Value: value},
+		}
+	}
+
+	t.Run("samples of the last write are skipped", func(t *testing.T) {
+		s := NewWrittenSamples()
+		first := promremote.TSList{sample("api", now, 1), sample("db", now, math.NaN())}
+		kept, skipped, w := s.skipWritten(append(promremote.TSList{}, first...))
+		require.Len(t, kept, 2)
+		require.Zero(t, skipped)
+		w.written(kept)
+		w.done()
+
+		// Same timestamps and values, NaN included
+		kept, skipped, w = s.skipWritten(append(promremote.TSList{}, first...))
+		require.Empty(t, kept)
+		require.Equal(t, 2, skipped)
+		w.done()
+
+		// The skipped series are still remembered
+		kept, skipped, _ = s.skipWritten(promremote.TSList{sample("api", now, 1), sample("db", now, 2)})
+		require.Equal(t, promremote.TSList{sample("db", now, 2)}, kept)
+		require.Equal(t, 1, skipped)
+	})
+
+	t.Run("series of failed batches are not remembered", func(t *testing.T) {
+		s := NewWrittenSamples()
+		kept, _, w := s.skipWritten(promremote.TSList{sample("api", now, 1), sample("db", now, 1)})
+		// Only the first batch was written
+		w.written(kept[:1])
+		w.done()
+
+		kept, skipped, _ := s.skipWritten(promremote.TSList{sample("api", now, 1), sample("db", now, 1)})
+		require.Equal(t, promremote.TSList{sample("db", now, 1)}, kept)
+		require.Equal(t, 1, skipped)
+	})
+
+	t.Run("only the series of the last write are remembered", func(t *testing.T) {
+		s := NewWrittenSamples()
+		kept, _, w := s.skipWritten(promremote.TSList{sample("api", now, 1)})
+		w.written(kept)
+		w.done()
+		kept, _, w = s.skipWritten(promremote.TSList{sample("db", now, 1)})
+		w.written(kept)
+		w.done()
+
+		kept, skipped, _ := s.skipWritten(promremote.TSList{sample("api", now, 1)})
+		require.Len(t, kept, 1)
+		require.Zero(t, skipped)
+	})
+
+	t.Run("overlapping writes keep their own series", func(t *testing.T) {
+		s := NewWrittenSamples()
+		older, _, olderWrite := s.skipWritten(promremote.TSList{sample("api", now, 1)})
+		newer, _, newerWrite := s.skipWritten(promremote.TSList{sample("db", now.Add(time.Second), 1)})
+		olderWrite.written(older)
+		newerWrite.written(newer)
+		// The write that started last is remembered, even when it ends first
+		newerWrite.done()
+		olderWrite.done()
+
+		kept, skipped, _ := s.skipWritten(promremote.TSList{sample("api", now, 1), sample("db", now.Add(time.Second), 1)})
+		require.Equal(t, promremote.TSList{sample("api", now, 1)}, kept)
+		require.Equal(t, 1, skipped)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		require.Nil(t, writtenSamplesFromContext(context.Background()))
+		s := NewWrittenSamples()
+		require.Same(t, s, writtenSamplesFromContext(WithWrittenSamples(context.Background(), s)))
+	})
+}
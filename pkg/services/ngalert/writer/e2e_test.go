@@ -0,0 +1,240 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/writer/writertest"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// newReceiverWriter returns a PrometheusWriter writing to a new writertest.Receiver
+func newReceiverWriter(t *testing.T, features featuremgmt.FeatureToggles, configure ...func(*setting.RecordingRuleTargetSettings)) (*PrometheusWriter, *writertest.Receiver) {
+	t.Helper()
+	receiver := writertest.NewReceiver(t)
+	settings := receiver.Settings()
+	for _, f := range configure {
+		f(&settings)
+	}
+	writer, err := NewPrometheusWriter(settings, features, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	return writer, receiver
+}
+
+func TestPrometheusWriter_Receiver(t *testing.T) {
+	labels := []map[string]string{{"instance": "a"}, {"instance": "b"}}
+	expected := []map[string]string{
+		{"__name__": "test_metric", "instance": "a", "rule": "test"},
+		{"__name__": "test_metric", "instance": "b", "rule": "test"},
+	}
+
+	for _, frameType := range []data.FrameType{data.FrameTypeNumericWide, data.FrameTypeNumericLong, data.FrameTypeNumericMulti} {
+		t.Run(string(frameType), func(t *testing.T) {
+			writer, receiver := newReceiverWriter(t, nil)
+			frames := frameGenFromLabels(t, frameType, labels)
+			require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, map[string]string{"rule": "test"}))
+			receiver.RequireSeries(t, expected...)
+		})
+	}
+
+	t.Run("custom headers are sent with every request", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteBatching), func(s *setting.RecordingRuleTargetSettings) {
+			s.CustomHeaders = map[string]string{"X-Scope-OrgID": "tenant"}
+		})
+		series := make([]map[string]string, maxSeriesPerRequest+1)
+		for i := range series {
+			series[i] = map[string]string{"series": strconv.Itoa(i)}
+		}
+		frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, series)
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+
+		requests := receiver.Requests()
+		require.Len(t, requests, 2)
+		for _, req := range requests {
+			require.Equal(t, "tenant", req.Header.Get("X-Scope-OrgID"))
+		}
+		require.Len(t, receiver.Series(), maxSeriesPerRequest+1)
+	})
+
+	t.Run("batches are written when others fail", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteBatching))
+		receiver.RespondWith(http.StatusBadRequest)
+		series := make([]map[string]string, maxSeriesPerRequest+1)
+		for i := range series {
+			series[i] = map[string]string{"series": strconv.Itoa(i)}
+		}
+		frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, series)
+		err := writer.Write(context.Background(), "test_metric", time.Now(), frames, nil)
+
+		var partial *PartialWriteError
+		require.ErrorAs(t, err, &partial)
+		require.Equal(t, 2, partial.Batches)
+		require.Equal(t, maxSeriesPerRequest+1, partial.Series)
+		require.Equal(t, 1, partial.WrittenSeries)
+		require.Len(t, partial.Failed, 1)
+		require.Equal(t, 0, partial.Failed[0].Batch)
+		require.Equal(t, maxSeriesPerRequest, partial.Failed[0].Series)
+		require.ErrorContains(t, err, "failed to write 1 of 2 batches of recording rule points, 1 of 2001 series were written: batch 1 (2000 series)")
+		require.Equal(t, WriteFailurePartial, writeFailure(err, nil))
+		require.Len(t, receiver.Series(), 1)
+
+		// Writes failing entirely are not partial
+		receiver.RespondWith(http.StatusBadRequest, http.StatusBadRequest)
+		err = writer.Write(context.Background(), "test_metric", time.Now(), frames, nil)
+		require.Error(t, err)
+		require.False(t, errors.As(err, &partial))
+	})
+
+	t.Run("failed writes are retried", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteRetries))
+		receiver.RespondWith(http.StatusServiceUnavailable)
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, labels)
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected...)
+	})
+
+	t.Run("rejected writes fail", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteRetries))
+		receiver.RespondWith(http.StatusBadRequest)
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, labels)
+		require.Error(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+		require.Empty(t, receiver.Requests())
+	})
+
+	t.Run("slow writes time out", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil, func(s *setting.RecordingRuleTargetSettings) {
+			s.Timeout = 50 * time.Millisecond
+		})
+		receiver.Delay(time.Second)
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, labels)
+		start := time.Now()
+		require.Error(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+		require.Less(t, time.Since(start), time.Second)
+		require.Empty(t, receiver.Requests())
+	})
+
+	t.Run("samples older than the maximum sample age are not written", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil, func(s *setting.RecordingRuleTargetSettings) {
+			s.MaxSampleAge = time.Hour
+		})
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, labels)
+		err := writer.Write(context.Background(), "test_metric", time.Now().Add(-2*time.Hour), frames, nil)
+		require.ErrorIs(t, err, ErrSampleTooOld)
+		require.Empty(t, receiver.Requests())
+
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now().Add(-time.Minute), frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected...)
+	})
+
+	t.Run("samples of frames older than the maximum sample age are not written", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil, func(s *setting.RecordingRuleTargetSettings) {
+			s.MaxSampleAge = time.Hour
+			s.TimestampSource = TimestampSourceFrame
+		})
+		frame := func(ts time.Time, instance string) *data.Frame {
+			f := data.NewFrame("",
+				data.NewField("T", nil, []time.Time{ts}),
+				data.NewField("value", data.Labels{"instance": instance}, []float64{1}),
+			)
+			f.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: data.FrameTypeVersion{0, 1}})
+			return f
+		}
+		now := time.Now()
+
+		frames := data.Frames{frame(now.Add(-2*time.Hour), "a"), frame(now.Add(-time.Minute), "b")}
+		require.NoError(t, writer.Write(context.Background(), "test_metric", now, frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected[1])
+		receiver.Reset()
+
+		frames = data.Frames{frame(now.Add(-2*time.Hour), "a"), frame(now.Add(-3*time.Hour), "b")}
+		err := writer.Write(context.Background(), "test_metric", now, frames, nil)
+		require.ErrorIs(t, err, ErrSampleTooOld)
+		require.Empty(t, receiver.Requests())
+	})
+
+	t.Run("series with labels longer than the limits are not written", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil, func(s *setting.RecordingRuleTargetSettings) {
+			s.MaxLabelValueLength = 12
+		})
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"instance": "a"}, {"instance": "too long value"}})
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected[0])
+	})
+
+	t.Run("series rejected by Mimir because of their labels do not fail the write", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteRetries))
+		receiver.LimitLabelValueLength(12)
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"instance": "a"}, {"instance": "too long value"}})
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, map[string]string{"rule": "test"}))
+		// Not retried
+		require.Len(t, receiver.Requests(), 1)
+		receiver.RequireSeries(t, expected[0])
+
+		// The batch is counted as rejected, as the target does not tell which of its series were written
+		stats := &writeStats{}
+		require.NoError(t, writer.write(withWriteStats(context.Background(), stats), "test_metric", time.Now(), frames, nil, stats))
+		require.Equal(t, 2, stats.rejectedSeries)
+		require.Zero(t, stats.writtenSeries)
+
+		// Other rejections still fail the write
+		receiver.RespondWith(http.StatusBadRequest)
+		require.Error(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+	})
+
+	t.Run("samples already written by the rule are not written again", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteBatching))
+		ctx := WithWrittenSamples(context.Background(), NewWrittenSamples())
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, labels)
+		now := time.Now().Truncate(time.Second).Add(100 * time.Millisecond)
+		require.NoError(t, writer.Write(ctx, "test_metric", now, frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected...)
+
+		// Evaluated again in the same second, the timestamps of the samples are truncated to the second
+		receiver.Reset()
+		require.NoError(t, writer.Write(ctx, "test_metric", now.Add(800*time.Millisecond), frames, map[string]string{"rule": "test"}))
+		require.Empty(t, receiver.Requests())
+
+		// Only the series whose sample changed are written
+		changed := frameGenFromLabels(t, data.FrameTypeNumericWide, labels)
+		changed[0].Fields[1].Set(0, extractValue(t, frames, labels[0], data.FrameTypeNumericWide)+1)
+		changed[0].Fields[2].Set(0, extractValue(t, frames, labels[1], data.FrameTypeNumericWide))
+		require.NoError(t, writer.Write(ctx, "test_metric", now, changed, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected[0])
+
+		// The series of the writes that failed are written again
+		receiver.Reset()
+		receiver.RespondWith(http.StatusInternalServerError)
+		require.Error(t, writer.Write(ctx, "test_metric", now.Add(time.Minute), frames, map[string]string{"rule": "test"}))
+		require.NoError(t, writer.Write(ctx, "test_metric", now.Add(time.Minute), frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected...)
+
+		// Other rules write the same samples
+		receiver.Reset()
+		require.NoError(t, writer.Write(context.Background(), "test_metric", now.Add(time.Minute), frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t, expected...)
+	})
+
+	t.Run("middlewares of the registry decorate the writes", func(t *testing.T) {
+		receiver := writertest.NewReceiver(t)
+		r := NewRegistry()
+		r.Use(LabelsMiddleware(map[string]string{"cluster": "prod"}))
+		writer, err := r.New(receiver.Settings(), nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+
+		frames := frameGenFromLabels(t, data.FrameTypeNumericWide, labels)
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, map[string]string{"rule": "test"}))
+		receiver.RequireSeries(t,
+			map[string]string{"__name__": "test_metric", "cluster": "prod", "instance": "a", "rule": "test"},
+			map[string]string{"__name__": "test_metric", "cluster": "prod", "instance": "b", "rule": "test"},
+		)
+	})
+}
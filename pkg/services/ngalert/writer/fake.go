@@ -8,13 +8,13 @@ import (
 )
 
 type FakeWriter struct {
-	WriteFunc func(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
+	WriteFunc func(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
 }
 
-func (w FakeWriter) Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+func (w FakeWriter) Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
 	if w.WriteFunc == nil {
 		return nil
 	}
 
-	return w.WriteFunc(ctx, name, t, frames, extraLabels)
+	return w.WriteFunc(ctx, target, name, t, frames, extraLabels)
 }
@@ -0,0 +1,118 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+// FrameFilter selects the frames and value fields of the results of a recording rule that are
+// written, see ngmodels.RecordFilter. The time and label fields of the frames are always kept.
+type FrameFilter struct {
+	refIDs   []string
+	fields   *regexp.Regexp
+	matchers []*labels.Matcher
+}
+
+// NewFrameFilter returns the FrameFilter of filter, nil when it is nil.
+func NewFrameFilter(filter *ngmodels.RecordFilter) (*FrameFilter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	f := &FrameFilter{refIDs: filter.RefIDs}
+	if filter.Fields != "" {
+		re, err := regexp.Compile("^(?:" + filter.Fields + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid field selector %q: %w", filter.Fields, err)
+		}
+		f.fields = re
+	}
+	if filter.Matchers != "" {
+		matchers, err := parser.ParseMetricSelector(filter.Matchers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label matchers %q: %w", filter.Matchers, err)
+		}
+		// The name of the series written is the metric of the rule
+		for _, m := range matchers {
+			if m.Name == labels.MetricName {
+				return nil, fmt.Errorf("invalid label matchers %q: the name of the series cannot be matched", filter.Matchers)
+			}
+		}
+		f.matchers = matchers
+	}
+	return f, nil
+}
+
+// WithFrameFilter returns ctx with the filter of the frames written with it.
+func WithFrameFilter(ctx context.Context, filter *FrameFilter) context.Context {
+	return context.WithValue(ctx, frameFilterKey{}, filter)
+}
+
+type frameFilterKey struct{}
+
+func frameFilterFromContext(ctx context.Context) *FrameFilter {
+	filter, _ := ctx.Value(frameFilterKey{}).(*FrameFilter)
+	return filter
+}
+
+// Apply returns the frames with the refID the filter selects, with the value fields whose name and
+// labels it selects. The frames left without value fields are dropped. When none is left, the first
+// frame is returned without value fields, so the write is the one of an evaluation without data.
+func (f *FrameFilter) Apply(frames data.Frames) data.Frames {
+	if f == nil || len(frames) == 0 {
+		return frames
+	}
+	kept := make(data.Frames, 0, len(frames))
+	for _, frame := range frames {
+		if len(f.refIDs) > 0 && !slices.Contains(f.refIDs, frame.RefID) {
+			continue
+		}
+		fields := make([]*data.Field, 0, len(frame.Fields))
+		values := 0
+		for _, field := range frame.Fields {
+			if field.Type().Numeric() {
+				if !f.selects(field) {
+					continue
+				}
+				values++
+			}
+			fields = append(fields, field)
+		}
+		if values == 0 {
+			continue
+		}
+		if len(fields) == len(frame.Fields) {
+			kept = append(kept, frame)
+			continue
+		}
+		filtered := data.NewFrame(frame.Name, fields...)
+		filtered.RefID, filtered.Meta = frame.RefID, frame.Meta
+		kept = append(kept, filtered)
+	}
+	if len(kept) == 0 {
+		empty := data.NewFrame(frames[0].Name)
+		empty.RefID, empty.Meta = frames[0].RefID, frames[0].Meta
+		return data.Frames{empty}
+	}
+	return kept
+}
+
+// selects returns whether the value field is written
+func (f *FrameFilter) selects(field *data.Field) bool {
+	if f.fields != nil && !f.fields.MatchString(field.Name) {
+		return false
+	}
+	for _, m := range f.matchers {
+		if !m.Matches(field.Labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+)
+
+func TestNewFrameFilter(t *testing.T) {
+	f, err := NewFrameFilter(nil)
+	require.NoError(t, err)
+	require.Nil(t, f)
+
+	_, err = NewFrameFilter(&ngmodels.RecordFilter{Fields: "("})
+	require.ErrorContains(t, err, "invalid field selector")
+	_, err = NewFrameFilter(&ngmodels.RecordFilter{Matchers: `{job=}`})
+	require.ErrorContains(t, err, "invalid label matchers")
+	_, err = NewFrameFilter(&ngmodels.RecordFilter{Matchers: `up{job="api"}`})
+	require.ErrorContains(t, err, "the name of the series cannot be matched")
+}
+
+func TestFrameFilter_Apply(t *testing.T) {
+	now := time.Now()
+	frame := func(refID string, values ...*data.Field) *data.Frame {
+		f := data.NewFrame("", append([]*data.Field{data.NewField("time", nil, []time.Time{now})}, values...)...)
+		f.RefID = refID
+		f.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericWide, TypeVersion: data.FrameTypeVersion{0, 1}})
+		return f
+	}
+	frames := data.Frames{
+		frame("A",
+			data.NewField("requests_total", data.Labels{"job": "api"}, []float64{1}),
+			data.NewField("errors_total", data.Labels{"job": "api"}, []float64{2}),
+		),
+		frame("B",
+			data.NewField("requests_total", data.Labels{"job": "web"}, []float64{3}),
+		),
+	}
+	values := func(frames data.Frames) []float64 {
+		var v []float64
+		for _, f := range frames {
+			for _, field := range f.Fields {
+				if field.Type() == data.FieldTypeFloat64 {
+					v = append(v, field.At(0).(float64))
+				}
+			}
+		}
+		return v
+	}
+
+	testCases := []struct {
+		name     string
+		filter   *ngmodels.RecordFilter
+		expected []float64
+	}{
+		{
+			name:     "no filter",
+			expected: []float64{1, 2, 3},
+		},
+		{
+			name:     "refIDs",
+			filter:   &ngmodels.RecordFilter{RefIDs: []string{"B"}},
+			expected: []float64{3},
+		},
+		{
+			name:     "fields",
+			filter:   &ngmodels.RecordFilter{Fields: "requests_.*"},
+			expected: []float64{1, 3},
+		},
+		{
+			name:     "fields must fully match",
+			filter:   &ngmodels.RecordFilter{Fields: "requests"},
+			expected: nil,
+		},
+		{
+			name:     "matchers",
+			filter:   &ngmodels.RecordFilter{Matchers: `{job=~"api|db"}`},
+			expected: []float64{1, 2},
+		},
+		{
+			name:     "all of them",
+			filter:   &ngmodels.RecordFilter{RefIDs: []string{"A", "B"}, Fields: "requests_total", Matchers: `{job!="web"}`},
+			expected: []float64{1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFrameFilter(tc.filter)
+			require.NoError(t, err)
+			filtered := f.Apply(frames)
+			require.Equal(t, tc.expected, values(filtered))
+			for _, frame := range filtered {
+				require.NotNil(t, frame.Meta)
+				if len(tc.expected) > 0 {
+					require.Equal(t, "time", frame.Fields[0].Name)
+				}
+			}
+		})
+	}
+
+	t.Run("frames are written without the series that are not selected", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil)
+		targets := &TargetsWriter{defaultWriter: writer}
+		f, err := NewFrameFilter(&ngmodels.RecordFilter{Fields: "errors_total"})
+		require.NoError(t, err)
+		require.NoError(t, targets.Write(WithFrameFilter(context.Background(), f), "", "test_metric", now, frames, nil))
+		receiver.RequireSeries(t, map[string]string{"__name__": "test_metric", "job": "api"})
+	})
+
+	t.Run("writes with nothing selected have no data", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil)
+		targets := &TargetsWriter{defaultWriter: writer}
+		f, err := NewFrameFilter(&ngmodels.RecordFilter{RefIDs: []string{"C"}})
+		require.NoError(t, err)
+		require.NoError(t, targets.Write(WithFrameFilter(context.Background(), f), "", "test_metric", now, frames, nil))
+		require.Empty(t, receiver.Series())
+	})
+}
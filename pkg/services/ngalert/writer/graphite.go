@@ -0,0 +1,329 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// TypeGraphite is the type of the targets written to with the HTTP API of Graphite, like the one
+// of the Graphite stacks of Grafana Cloud, which takes the metrics as JSON.
+const TypeGraphite = "graphite"
+
+// The ways the labels of the series are written to graphite targets
+const (
+	// GraphiteLabelsTags writes the labels as the tags of the metrics, the default
+	GraphiteLabelsTags = "tags"
+	// GraphiteLabelsPath appends the values of the labels to the names of the metrics, sorted by the
+	// names of the labels, for the dashboards querying untagged metrics
+	GraphiteLabelsPath = "path"
+)
+
+// defaultGraphiteInterval is the interval of the metrics of the graphite targets whose interval is not set
+const defaultGraphiteInterval = time.Minute
+
+var (
+	// The characters Graphite does not allow in the names and values of tags
+	graphiteTagNameReplacer  = strings.NewReplacer(";", "_", "!", "_", "^", "_", "=", "_")
+	graphiteTagValueReplacer = strings.NewReplacer(";", "_", "~", "_")
+	// The characters of label values that are not kept in the nodes of metric paths
+	graphitePathNodeInvalid = regexp.MustCompile(`[^a-zA-Z0-9_:\-]`)
+)
+
+// graphiteMetric is a metric of the requests to the HTTP API of Graphite
+type graphiteMetric struct {
+	Name     string   `json:"name"`
+	Interval int      `json:"interval"`
+	Value    float64  `json:"value"`
+	Time     int64    `json:"time"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// graphiteNameRule replaces the matches of match in the names of the metrics with replacement
+type graphiteNameRule struct {
+	match       *regexp.Regexp
+	replacement string
+}
+
+// GraphiteWriter writes the points of recording rules to a graphite target.
+type GraphiteWriter struct {
+	httpClient *http.Client
+	// the name of the target, logged with the writes
+	target    string
+	url       string
+	prefix    string
+	nameRules []graphiteNameRule
+	// Whether the labels are appended to the names of the metrics instead of being their tags
+	labelsAsPath bool
+	interval     time.Duration
+	// The maximum durations of the conversion of the frames of a write, zero when it is not limited,
+	// and of its request
+	conversionTimeout time.Duration
+	timeout           time.Duration
+	// The maximum age of the samples that are written, zero when it is not limited
+	maxSampleAge time.Duration
+	// The source of the timestamps of the metrics, the time of the evaluation when empty
+	timestampSource string
+	logger          log.Logger
+}
+
+// NewGraphiteWriter returns a writer sending the points of recording rules to the HTTP API of a
+// graphite target. Its credentials must have been decrypted, see ResolveSecrets.
+// The basic auth username and password of the target are the instance ID and the token of Grafana
+// Cloud stacks, sent as a bearer token, unless another auth type is set.
+// The connections to the target are reported to m, which may be nil.
+func NewGraphiteWriter(settings setting.RecordingRuleTargetSettings, m *metrics.RemoteWriter, l log.Logger) (*GraphiteWriter, error) {
+	if err := validateTargetSettings(settings); err != nil {
+		return nil, err
+	}
+
+	auth, err := graphiteAuthProvider(settings)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := newHTTPClient(settings, auth, m)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]graphiteNameRule, 0, len(settings.GraphiteNameRules))
+	for _, rule := range settings.GraphiteNameRules {
+		match, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid graphite name rule %q: %w", rule.Match, err)
+		}
+		rules = append(rules, graphiteNameRule{match: match, replacement: rule.Replacement})
+	}
+	interval := settings.GraphiteInterval
+	if interval == 0 {
+		interval = defaultGraphiteInterval
+	}
+
+	return &GraphiteWriter{
+		httpClient:        httpClient,
+		target:            targetName(settings),
+		url:               settings.URL,
+		prefix:            settings.GraphitePrefix,
+		nameRules:         rules,
+		labelsAsPath:      settings.GraphiteLabels == GraphiteLabelsPath,
+		interval:          interval,
+		conversionTimeout: settings.ConversionTimeout,
+		timeout:           settings.Timeout,
+		maxSampleAge:      settings.MaxSampleAge,
+		timestampSource:   settings.TimestampSource,
+		logger:            l,
+	}, nil
+}
+
+// graphiteAuthProvider returns the auth provider of a graphite target, the Grafana Cloud one when
+// it has basic auth credentials and no auth type
+func graphiteAuthProvider(settings setting.RecordingRuleTargetSettings) (AuthProvider, error) {
+	if settings.AuthType == "" && authType(settings) == AuthTypeBasic {
+		return graphiteCloudAuth{}, nil
+	}
+	return DefaultAuthProviders.provider(settings)
+}
+
+// graphiteCloudAuth authenticates the requests to the Graphite stacks of Grafana Cloud, whose
+// tokens are sent along with the instance ID of the stack as a bearer token
+type graphiteCloudAuth struct{}
+
+func (graphiteCloudAuth) Validate(target setting.RecordingRuleTargetSettings) []SettingError {
+	return basicAuth{}.Validate(target)
+}
+
+func (graphiteCloudAuth) Configure(target setting.RecordingRuleTargetSettings, opts *sdkhttpclient.Options) error {
+	opts.Header.Set("Authorization", "Bearer "+target.BasicAuthUsername+":"+target.BasicAuthPassword)
+	return nil
+}
+
+// Write writes the given frames to the target in a single request. Its samples that are not
+// finite are skipped, as Graphite cannot store them, and so are the ones already written by the
+// rule when ctx has its WrittenSamples.
+// Writes are logged like the ones of the PrometheusWriter.
+func (w GraphiteWriter) Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	start := time.Now()
+	stats := &writeStats{}
+	err := w.write(withWriteStats(ctx, stats), name, t, frames, extraLabels, stats)
+
+	logCtx := []any{
+		"target", w.target,
+		"name", name,
+		"series", stats.series,
+		"written", stats.writtenSeries,
+		"dropped", stats.droppedSeries,
+		"duplicates", stats.duplicateSeries,
+		"old", stats.oldSeries,
+		"requests", stats.requests,
+		"bytes", stats.bytes,
+		"status", stats.statusCode,
+		"duration", time.Since(start),
+	}
+	l := w.logger.FromContext(ctx)
+	if err != nil {
+		l.Warn("Failed to write recording rule points", append(logCtx, "kind", writeFailure(err, stats.transportErr), "error", err)...)
+		return err
+	}
+	l.Debug("Wrote recording rule points", logCtx...)
+	return nil
+}
+
+func (w GraphiteWriter) write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string, stats *writeStats) error {
+	if err := checkSampleAge(t, w.maxSampleAge); err != nil {
+		return err
+	}
+
+	convertCtx := ctx
+	if w.conversionTimeout > 0 {
+		var cancel context.CancelFunc
+		convertCtx, cancel = context.WithTimeout(ctx, w.conversionTimeout)
+		defer cancel()
+	}
+	series, err := seriesFromFrames(convertCtx, name, t, w.timestampSource, frames, extraLabels)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("conversion of the frames timed out after %s", w.conversionTimeout)
+		}
+		return err
+	}
+	if w.timestampSource == TimestampSourceFrame {
+		if series, stats.oldSeries, err = dropOldSamples(series, w.maxSampleAge); err != nil {
+			return err
+		}
+	}
+	var pending *pendingWrite
+	if written := writtenSamplesFromContext(ctx); written != nil {
+		series, stats.duplicateSeries, pending = written.skipWritten(series)
+		defer pending.done()
+	}
+
+	points := make([]graphiteMetric, 0, len(series))
+	for _, ts := range series {
+		if math.IsNaN(ts.Datapoint.Value) || math.IsInf(ts.Datapoint.Value, 0) {
+			stats.droppedSeries++
+			continue
+		}
+		points = append(points, w.metric(name, ts))
+	}
+	stats.series = len(points)
+	if len(points) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(points)
+	if err != nil {
+		return fmt.Errorf("unable to marshal graphite metrics: %w", err)
+	}
+	writeCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	if err := w.send(writeCtx, body); err != nil {
+		return fmt.Errorf("failed to write recording rule points: %w", err)
+	}
+	stats.writtenSeries = len(points)
+	if pending != nil {
+		pending.written(series)
+	}
+	return nil
+}
+
+// metric converts a series to a graphite metric, named after the rule with the name rules and the
+// prefix of the target applied
+func (w GraphiteWriter) metric(name string, ts promremote.TimeSeries) graphiteMetric {
+	for _, rule := range w.nameRules {
+		name = rule.match.ReplaceAllString(name, rule.replacement)
+	}
+	if w.prefix != "" {
+		name = strings.TrimSuffix(w.prefix, ".") + "." + name
+	}
+
+	var tags []string
+	// The labels of the series are sorted by their names
+	for _, l := range ts.Labels {
+		switch {
+		case l.Name == "__name__":
+		case w.labelsAsPath:
+			name += "." + graphitePathNodeInvalid.ReplaceAllString(l.Value, "_")
+		case l.Value != "":
+			tags = append(tags, graphiteTagNameReplacer.Replace(l.Name)+"="+graphiteTagValueReplacer.Replace(l.Value))
+		}
+	}
+
+	return graphiteMetric{
+		Name:     name,
+		Interval: int(w.interval / time.Second),
+		Value:    ts.Datapoint.Value,
+		Time:     ts.Datapoint.Timestamp.Unix(),
+		Tags:     tags,
+	}
+}
+
+// send posts the JSON metrics of a write to the target
+func (w GraphiteWriter) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return remoteWriteError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "grafana-recording-rule")
+
+	res, err := w.httpClient.Do(req)
+	if err != nil {
+		return remoteWriteError{err: err}
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode/100 != 2 {
+		b, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+		if err != nil {
+			return remoteWriteError{err: fmt.Errorf("expected HTTP 2xx status code: actual=%d, body_read_error=%s", res.StatusCode, err), code: res.StatusCode}
+		}
+		return remoteWriteError{err: fmt.Errorf("expected HTTP 2xx status code: actual=%d, body=%s", res.StatusCode, b), code: res.StatusCode}
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+// Probe sends an empty list of metrics to the target, and returns a *ProbeError telling why it
+// failed when the target cannot be reached or rejects the credentials, like the one of the
+// PrometheusWriter.
+func (w GraphiteWriter) Probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, strings.NewReader("[]"))
+	if err != nil {
+		return &ProbeError{Failure: ProbeFailureConnection, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.httpClient.Do(req)
+	if err != nil {
+		return &ProbeError{Failure: probeFailure(err), Err: err}
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return &ProbeError{Failure: ProbeFailureAuth, Err: fmt.Errorf("target responded with status %d", res.StatusCode)}
+	}
+	return nil
+}
+
+// newGraphiteWriter is the WriterFactory of graphite targets
+func newGraphiteWriter(settings setting.RecordingRuleTargetSettings, _ featuremgmt.FeatureToggles, m *metrics.RemoteWriter, l log.Logger) (Writer, error) {
+	return NewGraphiteWriter(settings, m, l)
+}
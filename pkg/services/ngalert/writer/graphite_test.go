@@ -0,0 +1,104 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestGraphiteWriter(t *testing.T) {
+	var (
+		header   http.Header
+		received []graphiteMetric
+		status   = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = nil
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, []map[string]string{{"instance": "a;1", "job": "api"}, {"instance": "b.2", "job": "api"}})
+	value := func(instance string) float64 {
+		return extractValue(t, frames, map[string]string{"instance": instance, "job": "api"}, data.FrameTypeNumericMulti)
+	}
+	newWriter := func(t *testing.T, settings setting.RecordingRuleTargetSettings) *GraphiteWriter {
+		t.Helper()
+		settings.Type, settings.URL, settings.Timeout = TypeGraphite, server.URL, time.Second
+		w, err := DefaultRegistry.New(settings, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.IsType(t, &GraphiteWriter{}, w)
+		return w.(*GraphiteWriter)
+	}
+
+	t.Run("labels are written as tags", func(t *testing.T) {
+		w := newWriter(t, setting.RecordingRuleTargetSettings{
+			BasicAuthUsername: "123456",
+			BasicAuthPassword: "token",
+			GraphitePrefix:    "grafana.",
+			GraphiteNameRules: []setting.GraphiteNameRule{{Match: `^(\w+):(\w+)$`, Replacement: "$1.$2"}},
+		})
+		require.NoError(t, w.Write(context.Background(), "job:requests", now, frames, map[string]string{"rule": "requests"}))
+
+		require.Equal(t, "Bearer 123456:token", header.Get("Authorization"))
+		require.Equal(t, "application/json", header.Get("Content-Type"))
+		require.Equal(t, []graphiteMetric{
+			{Name: "grafana.job.requests", Interval: 60, Value: value("a;1"), Time: now.Unix(), Tags: []string{"instance=a_1", "job=api", "rule=requests"}},
+			{Name: "grafana.job.requests", Interval: 60, Value: value("b.2"), Time: now.Unix(), Tags: []string{"instance=b.2", "job=api", "rule=requests"}},
+		}, received)
+	})
+
+	t.Run("labels are appended to the names with path", func(t *testing.T) {
+		w := newWriter(t, setting.RecordingRuleTargetSettings{
+			BearerToken:      "token",
+			GraphiteLabels:   GraphiteLabelsPath,
+			GraphiteInterval: 10 * time.Second,
+		})
+		require.NoError(t, w.Write(context.Background(), "requests", now, frames, nil))
+
+		require.Equal(t, "Bearer token", header.Get("Authorization"))
+		require.Equal(t, []graphiteMetric{
+			{Name: "requests.a_1.api", Interval: 10, Value: value("a;1"), Time: now.Unix()},
+			{Name: "requests.b_2.api", Interval: 10, Value: value("b.2"), Time: now.Unix()},
+		}, received)
+	})
+
+	t.Run("rejected writes", func(t *testing.T) {
+		status = http.StatusBadRequest
+		t.Cleanup(func() { status = http.StatusOK })
+
+		err := newWriter(t, setting.RecordingRuleTargetSettings{}).Write(context.Background(), "requests", now, frames, nil)
+		var writeErr promremote.WriteError
+		require.True(t, errors.As(err, &writeErr))
+		require.Equal(t, http.StatusBadRequest, writeErr.StatusCode())
+	})
+
+	t.Run("probe", func(t *testing.T) {
+		w := newWriter(t, setting.RecordingRuleTargetSettings{})
+		require.NoError(t, w.Probe(context.Background()))
+		require.Empty(t, received)
+
+		status = http.StatusUnauthorized
+		t.Cleanup(func() { status = http.StatusOK })
+		var probeErr *ProbeError
+		require.True(t, errors.As(w.Probe(context.Background()), &probeErr))
+		require.Equal(t, ProbeFailureAuth, probeErr.Failure)
+	})
+}
@@ -0,0 +1,160 @@
+package writer
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+)
+
+// bucketLabel is the label of the upper bounds of the buckets of classic histograms
+const bucketLabel = "le"
+
+// histogramTolerance is the relative decrease of the counts of consecutive buckets that is not a
+// violation, like in histogram_quantile, as the rates of the buckets computed by rules are not exact
+const histogramTolerance = 1e-12
+
+// histogramViolation is a problem of a bucket of a histogram, all whose buckets are then not written
+type histogramViolation struct {
+	// The index of the bucket in the series of the write, and of all the buckets of its histogram
+	series  int
+	buckets []int
+	message string
+}
+
+// histogramBucket is a bucket of a histogram, with its upper bound
+type histogramBucket struct {
+	series int
+	le     float64
+}
+
+// dropInvalidHistograms returns series without the buckets of the classic histograms that are not
+// valid, when they are validated. The series with an le label are the buckets of the histogram of
+// their other labels. The bounds of the buckets must be distinct numbers, the last one +Inf, and
+// their counts must not be negative or decrease as their bounds increase. The quantiles of stored
+// histograms violating them would be wrong, so none of their buckets are written.
+// Each violation is logged with the series of its bucket.
+func (w PrometheusWriter) dropInvalidHistograms(ctx context.Context, series promremote.TSList) (promremote.TSList, int) {
+	if !w.validateHistograms {
+		return series, 0
+	}
+	violations := histogramViolations(series)
+	if len(violations) == 0 {
+		return series, 0
+	}
+
+	invalid := make(map[int]bool)
+	for _, v := range violations {
+		w.logger.FromContext(ctx).Warn("Recording rule histogram not written, its buckets are not valid",
+			"target", w.target,
+			"violation", v.message,
+			"buckets", len(v.buckets),
+			"series", seriesString(series[v.series].Labels))
+		for _, i := range v.buckets {
+			invalid[i] = true
+		}
+	}
+	kept := series[:0]
+	for i, s := range series {
+		if !invalid[i] {
+			kept = append(kept, s)
+		}
+	}
+	return kept, len(series) - len(kept)
+}
+
+// histogramViolations returns the violations of the buckets of the histograms of series, in the
+// order of the series
+func histogramViolations(series promremote.TSList) []histogramViolation {
+	var keys []string
+	histograms := make(map[string][]int)
+	for i, s := range series {
+		key, ok := histogramKey(s.Labels)
+		if !ok {
+			continue
+		}
+		if _, ok := histograms[key]; !ok {
+			keys = append(keys, key)
+		}
+		histograms[key] = append(histograms[key], i)
+	}
+
+	var violations []histogramViolation
+	for _, key := range keys {
+		violations = append(violations, bucketViolations(series, histograms[key])...)
+	}
+	slices.SortStableFunc(violations, func(a, b histogramViolation) int { return a.series - b.series })
+	return violations
+}
+
+// bucketViolations returns the violations of the buckets of a histogram, the indexes of their series
+func bucketViolations(series promremote.TSList, indexes []int) []histogramViolation {
+	var violations []histogramViolation
+	violation := func(i int, format string, args ...any) {
+		violations = append(violations, histogramViolation{series: i, buckets: indexes, message: fmt.Sprintf(format, args...)})
+	}
+
+	buckets := make([]histogramBucket, 0, len(indexes))
+	for _, i := range indexes {
+		le := labelValue(series[i].Labels, bucketLabel)
+		bound, err := strconv.ParseFloat(le, 64)
+		if err != nil || math.IsNaN(bound) {
+			violation(i, "the bound %q of the bucket is not a number", le)
+			continue
+		}
+		buckets = append(buckets, histogramBucket{series: i, le: bound})
+	}
+	slices.SortStableFunc(buckets, func(a, b histogramBucket) int { return cmp.Compare(a.le, b.le) })
+
+	for j, b := range buckets {
+		count := series[b.series].Datapoint.Value
+		switch {
+		case math.IsNaN(count) || count < 0:
+			violation(b.series, "the count %g of the bucket is negative or not a number", count)
+		case j == 0:
+		case b.le == buckets[j-1].le:
+			violation(b.series, "the bucket has the same bound %g as another", b.le)
+		default:
+			previous := series[buckets[j-1].series].Datapoint.Value
+			if count < previous-math.Abs(previous)*histogramTolerance {
+				violation(b.series, "the count %g of the bucket is lower than the count %g of the bucket with the bound %g", count, previous, buckets[j-1].le)
+			}
+		}
+	}
+	if len(buckets) > 0 && !math.IsInf(buckets[len(buckets)-1].le, 1) {
+		violation(buckets[len(buckets)-1].series, "the histogram has no +Inf bucket")
+	}
+	return violations
+}
+
+// histogramKey returns the labels of a series other than its le label, which the buckets of its
+// histogram have, and whether it has one
+func histogramKey(labels []promremote.Label) (string, bool) {
+	var b strings.Builder
+	bucket := false
+	for _, l := range labels {
+		if l.Name == bucketLabel {
+			bucket = true
+			continue
+		}
+		b.WriteString(l.Name)
+		b.WriteByte(0xff)
+		b.WriteString(l.Value)
+		b.WriteByte(0xff)
+	}
+	return b.String(), bucket
+}
+
+func labelValue(labels []promremote.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
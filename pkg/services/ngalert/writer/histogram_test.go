@@ -0,0 +1,133 @@
+package writer
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// buckets returns the series of the buckets of a histogram of job, with the counts of the bounds
+func buckets(job string, counts map[string]float64) promremote.TSList {
+	series := make(promremote.TSList, 0, len(counts))
+	for le, count := range counts {
+		series = append(series, promremote.TimeSeries{
+			Labels: []promremote.Label{
+				{Name: "__name__", Value: "test_bucket"},
+				{Name: "job", Value: job},
+				{Name: "le", Value: le},
+			},
+			Datapoint: promremote.Datapoint{Value: count},
+		})
+	}
+	return series
+}
+
+func TestHistogramViolations(t *testing.T) {
+	testCases := []struct {
+		name     string
+		counts   map[string]float64
+		expected []string
+	}{
+		{
+			name:   "valid",
+			counts: map[string]float64{"0.1": 1, "1": 3, "+Inf": 3},
+		},
+		{
+			name:   "float errors of rates are tolerated",
+			counts: map[string]float64{"1": 0.30000000000000004, "+Inf": 0.3},
+		},
+		{
+			name:     "decreasing counts",
+			counts:   map[string]float64{"0.1": 1, "1": 3, "10": 2, "+Inf": 3},
+			expected: []string{"the count 2 of the bucket is lower than the count 3 of the bucket with the bound 1"},
+		},
+		{
+			name:     "negative count",
+			counts:   map[string]float64{"1": -1, "+Inf": 3},
+			expected: []string{"the count -1 of the bucket is negative or not a number"},
+		},
+		{
+			name:     "duplicate bounds",
+			counts:   map[string]float64{"1": 1, "1.0": 1, "+Inf": 3},
+			expected: []string{"the bucket has the same bound 1 as another"},
+		},
+		{
+			name:     "invalid bound",
+			counts:   map[string]float64{"fast": 1, "+Inf": 3},
+			expected: []string{`the bound "fast" of the bucket is not a number`},
+		},
+		{
+			name:     "no +Inf bucket",
+			counts:   map[string]float64{"0.1": 1, "1": 3},
+			expected: []string{"the histogram has no +Inf bucket"},
+		},
+		{
+			name:     "NaN count",
+			counts:   map[string]float64{"1": math.NaN(), "+Inf": 3},
+			expected: []string{"the count NaN of the bucket is negative or not a number"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			series := buckets("api", tc.counts)
+			violations := histogramViolations(series)
+			messages := make([]string, 0, len(violations))
+			for _, v := range violations {
+				messages = append(messages, v.message)
+				require.Len(t, v.buckets, len(series))
+			}
+			require.ElementsMatch(t, tc.expected, messages)
+		})
+	}
+
+	t.Run("series without le label are not buckets", func(t *testing.T) {
+		series := promremote.TSList{{Labels: []promremote.Label{{Name: "__name__", Value: "test_metric"}}, Datapoint: promremote.Datapoint{Value: -1}}}
+		require.Empty(t, histogramViolations(series))
+	})
+}
+
+func TestPrometheusWriter_InvalidHistograms(t *testing.T) {
+	bucketFrames := func(counts map[string]map[string]float64) data.Frames {
+		var frames data.Frames
+		for job, jobCounts := range counts {
+			for le, count := range jobCounts {
+				frame := data.NewFrame("test",
+					data.NewField("T", nil, []time.Time{time.Now()}),
+					data.NewField("value", data.Labels{"job": job, "le": le}, []float64{count}),
+				)
+				frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: data.FrameTypeVersion{0, 1}})
+				frames = append(frames, frame)
+			}
+		}
+		return frames
+	}
+	frames := bucketFrames(map[string]map[string]float64{
+		"api": {"1": 1, "+Inf": 2},
+		"web": {"1": 3, "+Inf": 2},
+	})
+
+	t.Run("only the buckets of the valid histograms are written", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil, func(s *setting.RecordingRuleTargetSettings) {
+			s.ValidateHistograms = true
+		})
+		require.NoError(t, writer.Write(context.Background(), "test_bucket", time.Now(), frames, nil))
+		receiver.RequireSeries(t,
+			map[string]string{"__name__": "test_bucket", "job": "api", "le": "+Inf"},
+			map[string]string{"__name__": "test_bucket", "job": "api", "le": "1"},
+		)
+	})
+
+	t.Run("histograms are not validated by default", func(t *testing.T) {
+		writer, receiver := newReceiverWriter(t, nil)
+		require.NoError(t, writer.Write(context.Background(), "test_bucket", time.Now(), frames, nil))
+		require.Len(t, receiver.Series(), 4)
+	})
+}
@@ -0,0 +1,91 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+)
+
+// maxLoggedLabelLength bounds the length of the label values logged with the series that are dropped
+const maxLoggedLabelLength = 256
+
+// mimirLabelTooLongErrors are the ids of the errors of Mimir rejecting series because of the length
+// of their labels
+var mimirLabelTooLongErrors = []string{"err-mimir-label-name-too-long", "err-mimir-label-value-too-long"}
+
+// dropLongLabels returns series without the ones with a label name or value longer than the limits
+// of the target, which it would reject. They are not truncated, as series only differing after the
+// limit would then be written as the same one. Each dropped series is logged.
+func (w PrometheusWriter) dropLongLabels(ctx context.Context, series promremote.TSList) (promremote.TSList, int) {
+	if w.maxLabelNameLength <= 0 && w.maxLabelValueLength <= 0 {
+		return series, 0
+	}
+	kept := series[:0]
+	for _, s := range series {
+		if l, ok := w.longLabel(s.Labels); ok {
+			w.logger.FromContext(ctx).Warn("Recording rule series not written, its label is longer than the limit of the target",
+				"target", w.target,
+				"label", truncateLabel(l.Name),
+				"nameLength", len(l.Name),
+				"valueLength", len(l.Value),
+				"maxNameLength", w.maxLabelNameLength,
+				"maxValueLength", w.maxLabelValueLength,
+				"series", seriesString(s.Labels))
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return kept, len(series) - len(kept)
+}
+
+// longLabel returns the first of labels whose name or value is longer than the limits
+func (w PrometheusWriter) longLabel(labels []promremote.Label) (promremote.Label, bool) {
+	for _, l := range labels {
+		if (w.maxLabelNameLength > 0 && len(l.Name) > w.maxLabelNameLength) || (w.maxLabelValueLength > 0 && len(l.Value) > w.maxLabelValueLength) {
+			return l, true
+		}
+	}
+	return promremote.Label{}, false
+}
+
+// labelTooLongRejection returns whether err is the rejection of a write request by Mimir because of
+// the length of the labels of some of its series. Mimir writes the other series of the request.
+func labelTooLongRejection(err error) bool {
+	var writeErr promremote.WriteError
+	if !errors.As(err, &writeErr) || writeErr.StatusCode() != http.StatusBadRequest {
+		return false
+	}
+	for _, id := range mimirLabelTooLongErrors {
+		if strings.Contains(err.Error(), id) {
+			return true
+		}
+	}
+	return false
+}
+
+// seriesString formats labels like the series of Prometheus, with the long values truncated
+func seriesString(labels []promremote.Label) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(truncateLabel(l.Name))
+		b.WriteString(`="`)
+		b.WriteString(truncateLabel(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func truncateLabel(s string) string {
+	if len(s) <= maxLoggedLabelLength {
+		return s
+	}
+	return s[:maxLoggedLabelLength] + "..."
+}
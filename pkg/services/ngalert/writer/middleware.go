@@ -0,0 +1,67 @@
+package writer
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// WriteFunc writes the points of a recording rule, see Writer.Write.
+type WriteFunc func(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
+
+// Middleware decorates the writes of writers, to change what is written or how, by calling next
+// with other arguments, not calling it, or acting around it.
+type Middleware func(next WriteFunc) WriteFunc
+
+// chainedWriter is a writer whose writes go through middlewares
+type chainedWriter struct {
+	Writer
+	write WriteFunc
+}
+
+// Chain returns w with its writes going through middlewares, the first one being called first.
+// Probes are not changed.
+func Chain(w Writer, middlewares ...Middleware) Writer {
+	if len(middlewares) == 0 {
+		return w
+	}
+	write := w.Write
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		write = middlewares[i](write)
+	}
+	return chainedWriter{Writer: w, write: write}
+}
+
+func (w chainedWriter) Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	return w.write(ctx, name, t, frames, extraLabels)
+}
+
+// LabelsMiddleware adds labels to the series written, like a tenant or the instance of Grafana.
+// The labels of the rules take precedence.
+func LabelsMiddleware(labels map[string]string) Middleware {
+	return func(next WriteFunc) WriteFunc {
+		return func(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+			merged := make(map[string]string, len(labels)+len(extraLabels))
+			for k, v := range labels {
+				merged[k] = v
+			}
+			for k, v := range extraLabels {
+				merged[k] = v
+			}
+			return next(ctx, name, t, frames, merged)
+		}
+	}
+}
+
+// FilterMiddleware skips the writes of the recording rules for which keep returns false.
+func FilterMiddleware(keep func(ctx context.Context, name string) bool) Middleware {
+	return func(next WriteFunc) WriteFunc {
+		return func(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+			if !keep(ctx, name) {
+				return nil
+			}
+			return next(ctx, name, t, frames, extraLabels)
+		}
+	}
+}
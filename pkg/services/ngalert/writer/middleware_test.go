@@ -0,0 +1,72 @@
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next WriteFunc) WriteFunc {
+			return func(ctx context.Context, metric string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+				calls = append(calls, name)
+				return next(ctx, metric, t, frames, extraLabels)
+			}
+		}
+	}
+
+	var labels map[string]string
+	w := Chain(writeFunc(func(_ context.Context, name string, _ time.Time, _ data.Frames, extraLabels map[string]string) error {
+		calls = append(calls, "writer")
+		labels = extraLabels
+		return nil
+	}), record("first"), LabelsMiddleware(map[string]string{"tenant": "default", "rule": "default"}), record("second"))
+
+	require.NoError(t, w.Write(context.Background(), "test_metric", time.Now(), nil, map[string]string{"rule": "label"}))
+	require.Equal(t, []string{"first", "second", "writer"}, calls)
+	require.Equal(t, map[string]string{"tenant": "default", "rule": "label"}, labels)
+
+	t.Run("filtered writes are skipped", func(t *testing.T) {
+		calls = nil
+		w := Chain(w, FilterMiddleware(func(_ context.Context, name string) bool { return name != "skipped" }))
+		require.NoError(t, w.Write(context.Background(), "skipped", time.Now(), nil, nil))
+		require.Empty(t, calls)
+		require.NoError(t, w.Write(context.Background(), "kept", time.Now(), nil, nil))
+		require.Equal(t, []string{"first", "second", "writer"}, calls)
+	})
+
+	t.Run("writers of the registry use its middlewares", func(t *testing.T) {
+		calls = nil
+		r := NewRegistry()
+		require.NoError(t, r.Register("recorded", func(setting.RecordingRuleTargetSettings, featuremgmt.FeatureToggles, *metrics.RemoteWriter, log.Logger) (Writer, error) {
+			return &recordedWriter{}, nil
+		}))
+		r.Use(record("registry"))
+		w, err := r.New(setting.RecordingRuleTargetSettings{Type: "recorded"}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, w.Write(context.Background(), "test_metric", time.Now(), nil, nil))
+		require.NoError(t, w.Probe(context.Background()))
+		require.Equal(t, []string{"registry"}, calls)
+	})
+}
+
+// writeFunc is a writer writing with a function
+type writeFunc WriteFunc
+
+func (f writeFunc) Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	return f(ctx, name, t, frames, extraLabels)
+}
+
+func (f writeFunc) Probe(context.Context) error {
+	return nil
+}
@@ -9,6 +9,6 @@ import (
 
 type NoopWriter struct{}
 
-func (w NoopWriter) Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+func (w NoopWriter) Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
 	return nil
 }
@@ -0,0 +1,43 @@
+package writer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BatchError is the error of a batch of series that could not be written
+type BatchError struct {
+	// The index of the batch among the batches of the write
+	Batch int
+	// The number of series of the batch, or of all the remaining batches when the write was
+	// aborted before them
+	Series int
+	Err    error
+}
+
+// PartialWriteError is returned by the writes split into batches when some of them were written
+// and others were not. The series of the written batches are persisted by the target.
+type PartialWriteError struct {
+	Batches       int
+	Series        int
+	WrittenSeries int
+	Failed        []BatchError
+}
+
+func (e *PartialWriteError) Error() string {
+	failures := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		failures = append(failures, fmt.Sprintf("batch %d (%d series): %s", f.Batch+1, f.Series, f.Err))
+	}
+	return fmt.Sprintf("failed to write %d of %d batches of recording rule points, %d of %d series were written: %s",
+		len(e.Failed), e.Batches, e.WrittenSeries, e.Series, strings.Join(failures, "; "))
+}
+
+// Unwrap returns the errors of the failed batches
+func (e *PartialWriteError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
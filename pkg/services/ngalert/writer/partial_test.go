@@ -0,0 +1,34 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialWriteError(t *testing.T) {
+	err := &PartialWriteError{
+		Batches:       3,
+		Series:        25,
+		WrittenSeries: 10,
+		Failed: []BatchError{
+			{Batch: 1, Series: 10, Err: ErrSampleTooOld},
+			{Batch: 2, Series: 5, Err: context.DeadlineExceeded},
+		},
+	}
+
+	require.EqualError(t, err, "failed to write 2 of 3 batches of recording rule points, 10 of 25 series were written: "+
+		"batch 2 (10 series): "+ErrSampleTooOld.Error()+"; batch 3 (5 series): context deadline exceeded")
+	require.Equal(t, []error{ErrSampleTooOld, context.DeadlineExceeded}, err.Unwrap())
+
+	var wrapped error = err
+	require.ErrorIs(t, wrapped, ErrSampleTooOld)
+	require.ErrorIs(t, wrapped, context.DeadlineExceeded)
+	require.NotErrorIs(t, wrapped, context.Canceled)
+
+	var partial *PartialWriteError
+	require.True(t, errors.As(wrapped, &partial))
+	require.Equal(t, 10, partial.WrittenSeries)
+}
@@ -0,0 +1,57 @@
+package writer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+)
+
+// poolMiddleware counts the connections the requests to a target are sent on, by whether they
+// were reused from the idle pool
+func poolMiddleware(m *metrics.RemoteWriter, target string) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("recording-rules-connection-pool", func(_ sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			trace := &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					m.ConnectionsTotal.WithLabelValues(target, strconv.FormatBool(info.Reused)).Inc()
+				},
+			}
+			return next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		})
+	})
+}
+
+// countConnections makes transport count its open connections to a target
+func countConnections(transport *http.Transport, m *metrics.RemoteWriter, target string) {
+	open := m.OpenConnections.WithLabelValues(target)
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		open.Inc()
+		return &countedConn{Conn: conn, closed: sync.OnceFunc(open.Dec)}, nil
+	}
+}
+
+// countedConn is a connection counted as open until it is closed
+type countedConn struct {
+	net.Conn
+	closed func()
+}
+
+func (c *countedConn) Close() error {
+	c.closed()
+	return c.Conn.Close()
+}
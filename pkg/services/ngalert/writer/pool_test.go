@@ -0,0 +1,79 @@
+package writer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestConnectionPoolMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := metrics.NewRemoteWriterMetrics(prometheus.NewRegistry())
+	writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{
+		Name:                "mimir",
+		URL:                 server.URL,
+		Timeout:             time.Second,
+		MaxIdleConnsPerHost: 1,
+		IdleConnTimeout:     time.Minute,
+	}, nil, m, log.NewNopLogger())
+	require.NoError(t, err)
+
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"foo": "1"}})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+	}
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.ConnectionsTotal.WithLabelValues("mimir", "false")))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.ConnectionsTotal.WithLabelValues("mimir", "true")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.OpenConnections.WithLabelValues("mimir")))
+}
+
+func TestRequestSizeMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := metrics.NewRemoteWriterMetrics(prometheus.NewRegistry())
+	writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{
+		Name:    "mimir",
+		URL:     server.URL,
+		Timeout: time.Second,
+	}, nil, m, log.NewNopLogger())
+	require.NoError(t, err)
+
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"foo": "1"}, {"foo": "2"}})
+	for i := 0; i < 2; i++ {
+		require.NoError(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+	}
+
+	uncompressed := histogram(t, m.RequestUncompressedBytes.WithLabelValues("mimir"))
+	compressed := histogram(t, m.RequestCompressedBytes.WithLabelValues("mimir"))
+	require.Equal(t, uint64(2), uncompressed.GetSampleCount())
+	require.Equal(t, uint64(2), compressed.GetSampleCount())
+	require.Positive(t, uncompressed.GetSampleSum())
+	require.Positive(t, compressed.GetSampleSum())
+}
+
+func histogram(t *testing.T, o prometheus.Observer) *dto.Histogram {
+	t.Helper()
+	var metric dto.Metric
+	require.NoError(t, o.(prometheus.Histogram).Write(&metric))
+	return metric.GetHistogram()
+}
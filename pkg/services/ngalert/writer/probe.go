@@ -0,0 +1,92 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/golang/snappy"
+)
+
+// ProbeFailure is the reason the write target could not be reached.
+type ProbeFailure string
+
+const (
+	ProbeFailureDNS        ProbeFailure = "dns"
+	ProbeFailureTLS        ProbeFailure = "tls"
+	ProbeFailureAuth       ProbeFailure = "auth"
+	ProbeFailureConnection ProbeFailure = "connection"
+)
+
+// ProbeError is returned by Probe when the write target is not reachable.
+type ProbeError struct {
+	Failure ProbeFailure
+	Err     error
+}
+
+func (e *ProbeError) Error() string {
+	return fmt.Sprintf("%s failure: %s", e.Failure, e.Err)
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// Probe sends an empty write request to the target, and returns a *ProbeError telling why it
+// failed when the target cannot be reached or rejects the credentials. Other responses are not
+// errors, as the target may not accept empty requests.
+// The protocol of the target is detected by the probe when it is auto, see detectProtocol.
+func (w PrometheusWriter) Probe(ctx context.Context) error {
+	if w.protocols != nil && w.protocols.configured == ProtocolAuto {
+		_, err := w.protocols.protocol(ctx)
+		return err
+	}
+
+	// An empty protobuf message is an empty payload
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(snappy.Encode(nil, nil)))
+	if err != nil {
+		return &ProbeError{Failure: ProbeFailureConnection, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	res, err := w.httpClient.Do(req)
+	if err != nil {
+		return &ProbeError{Failure: probeFailure(err), Err: err}
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return &ProbeError{Failure: ProbeFailureAuth, Err: fmt.Errorf("target responded with status %d", res.StatusCode)}
+	}
+	return nil
+}
+
+// probeFailure returns the reason of an error sending a request
+func probeFailure(err error) ProbeFailure {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ProbeFailureDNS
+	}
+
+	var (
+		recordErr    tls.RecordHeaderError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		invalidErr   x509.CertificateInvalidError
+		hostnameErr  x509.HostnameError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &verifyErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &hostnameErr) {
+		return ProbeFailureTLS
+	}
+	return ProbeFailureConnection
+}
@@ -0,0 +1,63 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestPrometheusWriter_Probe(t *testing.T) {
+	probe := func(t *testing.T, url string) error {
+		t.Helper()
+		writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{URL: url, Timeout: time.Second}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		return writer.Probe(context.Background())
+	}
+	failure := func(t *testing.T, err error) ProbeFailure {
+		t.Helper()
+		var probeErr *ProbeError
+		require.True(t, errors.As(err, &probeErr), "expected a probe error, got %v", err)
+		return probeErr.Failure
+	}
+
+	t.Run("reachable target", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+		require.NoError(t, probe(t, server.URL))
+	})
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+		require.Equal(t, ProbeFailureAuth, failure(t, probe(t, server.URL)))
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+		require.Equal(t, ProbeFailureTLS, failure(t, probe(t, server.URL)))
+	})
+
+	t.Run("unknown host", func(t *testing.T) {
+		require.Equal(t, ProbeFailureDNS, failure(t, probe(t, "http://recording-rules.invalid")))
+	})
+
+	t.Run("refused connection", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		url := server.URL
+		server.Close()
+		require.Equal(t, ProbeFailureConnection, failure(t, probe(t, url)))
+	})
+}
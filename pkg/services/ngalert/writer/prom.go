@@ -2,16 +2,32 @@ package writer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/grafana/dataplane/sdata/numeric"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"golang.org/x/net/http/httpproxy"
+
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
 	"github.com/grafana/grafana/pkg/setting"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
+// ErrSampleTooOld is returned by the writes of samples older than the maximum sample age of their
+// target, which it would reject as out of bounds. Nothing is written. When the samples are stamped with
+// the time of their frame, only the old ones are not written, and the error is returned when all are.
+var ErrSampleTooOld = errors.New("sample is older than the maximum sample age of the target")
+
 // Metric represents a Prometheus time series metric.
 type Metric struct {
 	T int64
@@ -25,38 +41,24 @@ type Point struct {
 	Metric Metric
 }
 
-func PointsFromFrames(name string, t time.Time, frames data.Frames, extraLabels map[string]string) ([]Point, error) {
-	cr, err := numeric.CollectionReaderFromFrames(frames)
-	if err != nil {
-		return nil, err
-	}
-
-	col, err := cr.GetCollection(false)
+// PointsFromFrames converts frames to points, with the time t, or the time of their frame with
+// TimestampSourceFrame. The frames that have a converter in DefaultFrameConverters are converted
+// by it, the others are read as numeric data. It stops when ctx is done.
+func PointsFromFrames(ctx context.Context, name string, t time.Time, source string, frames data.Frames, extraLabels map[string]string) ([]Point, error) {
+	samples, err := samplesFromFrames(ctx, source, frames)
 	if err != nil {
 		return nil, err
 	}
 
-	points := make([]Point, 0, len(col.Refs))
-	for _, ref := range col.Refs {
-		var f float64
-		if fp, empty, err := ref.NullableFloat64Value(); !empty && fp != nil {
-			f = *fp
-		} else if err != nil {
-			return nil, fmt.Errorf("unable to get float64 value: %w", err)
-		} else {
-			return nil, fmt.Errorf("unable to get metric value")
+	points := make([]Point, 0, len(samples))
+	for _, s := range samples {
+		// The labels are copied once, into a map large enough for the extra labels
+		labels := make(map[string]string, len(s.Labels)+len(extraLabels))
+		for k, v := range s.Labels {
+			if k != "__name__" {
+				labels[k] = v
+			}
 		}
-
-		metric := Metric{
-			T: t.Unix(),
-			V: f,
-		}
-
-		labels := ref.GetLabels().Copy()
-		if labels == nil {
-			labels = data.Labels{}
-		}
-		delete(labels, "__name__")
 		for k, v := range extraLabels {
 			labels[k] = v
 		}
@@ -64,37 +66,407 @@ func PointsFromFrames(name string, t time.Time, frames data.Frames, extraLabels
 		points = append(points, Point{
 			Name:   name,
 			Labels: labels,
-			Metric: metric,
+			Metric: Metric{
+				T: sampleTime(s, t, source).Unix(),
+				V: s.Value,
+			},
 		})
 	}
 
 	return points, nil
 }
 
+// metricRefs returns the numeric metrics of frames
+func metricRefs(frames data.Frames) ([]numeric.MetricRef, error) {
+	cr, err := numeric.CollectionReaderFromFrames(frames)
+	if err != nil {
+		return nil, err
+	}
+
+	col, err := cr.GetCollection(false)
+	if err != nil {
+		return nil, err
+	}
+	return col.Refs, nil
+}
+
+// metricValue returns the value of a numeric metric
+func metricValue(ref numeric.MetricRef) (float64, error) {
+	fp, empty, err := ref.NullableFloat64Value()
+	switch {
+	case !empty && fp != nil:
+		return *fp, nil
+	case err != nil:
+		return 0, fmt.Errorf("unable to get float64 value: %w", err)
+	default:
+		return 0, fmt.Errorf("unable to get metric value")
+	}
+}
+
 type PrometheusWriter struct {
-	logger log.Logger
+	client promremote.Client
+	// The protocol of the requests of client, detected at the first write or probe when it is auto
+	protocols  *protocolSelector
+	httpClient *http.Client
+	// the name of the target, logged with the writes
+	target string
+	url    string
+	// The maximum durations of the conversion of the frames of a write, zero when it is not limited,
+	// and of the write of its series, including all their requests
+	conversionTimeout time.Duration
+	timeout           time.Duration
+	// The maximum age of the samples that are written, zero when it is not limited
+	maxSampleAge time.Duration
+	// The maximum length of the names and values of the labels of the series written, zero when it
+	// is not limited
+	maxLabelNameLength  int
+	maxLabelValueLength int
+	// Whether the buckets of classic histograms are validated before they are written
+	validateHistograms bool
+	// The source of the timestamps of the series, the time of the evaluation when empty
+	timestampSource string
+	// Toggles the optional behaviors of writes, see Write. nil when they are all disabled.
+	features featuremgmt.FeatureToggles
+	logger   log.Logger
 }
 
+// NewPrometheusWriter returns a writer sending the points of recording rules to the remote write
+// endpoint of a target. Its credentials must have been decrypted, see ResolveSecrets.
+// The batching and retries of writes are enabled by features, and the connections to the target
+// and the sizes of the write requests are reported to m. Both may be nil.
 func NewPrometheusWriter(
-	settings setting.RecordingRuleSettings,
+	settings setting.RecordingRuleTargetSettings,
+	features featuremgmt.FeatureToggles,
+	m *metrics.RemoteWriter,
 	l log.Logger,
 ) (*PrometheusWriter, error) {
+	if err := validateTargetSettings(settings); err != nil {
+		return nil, err
+	}
+
+	auth, err := DefaultAuthProviders.provider(settings)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := newHTTPClient(settings, auth, m)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case settings.URL == "":
+		return nil, errors.New("failed to create recording rules remote write client: remote write URL should not be blank")
+	case settings.Timeout <= 0:
+		return nil, fmt.Errorf("failed to create recording rules remote write client: timeout should be greater than 0: %s", settings.Timeout)
+	}
+
+	client := newRemoteWriteClient(settings.URL, httpClient)
+	if m != nil {
+		client = client.withSizeMetrics(m, targetName(settings))
+	}
+	client.protocols = newProtocolSelector(settings.Protocol, client.detectProtocol, l.New("target", targetName(settings)))
+
 	return &PrometheusWriter{
-		logger: l,
+		client:              client,
+		protocols:           client.protocols,
+		httpClient:          httpClient,
+		target:              targetName(settings),
+		url:                 settings.URL,
+		conversionTimeout:   settings.ConversionTimeout,
+		timeout:             settings.Timeout,
+		maxSampleAge:        settings.MaxSampleAge,
+		maxLabelNameLength:  settings.MaxLabelNameLength,
+		maxLabelValueLength: settings.MaxLabelValueLength,
+		validateHistograms:  settings.ValidateHistograms,
+		timestampSource:     settings.TimestampSource,
+		features:            features,
+		logger:              l,
 	}, nil
 }
 
+// newHTTPClient returns the HTTP client of the write requests to a target, authenticated by auth
+// unless it is nil. Its connections are reported to m when it is not nil.
+func newHTTPClient(settings setting.RecordingRuleTargetSettings, auth AuthProvider, m *metrics.RemoteWriter) (*http.Client, error) {
+	opts := sdkhttpclient.Options{
+		Timeouts: &sdkhttpclient.TimeoutOptions{
+			Timeout: settings.Timeout,
+			// Kept idle, so frequent writes do not open a new connection each time
+			MaxIdleConns:        settings.MaxIdleConnsPerHost,
+			MaxIdleConnsPerHost: settings.MaxIdleConnsPerHost,
+			IdleConnTimeout:     settings.IdleConnTimeout,
+		},
+		Header:      http.Header{},
+		Middlewares: append(sdkhttpclient.DefaultMiddlewares(), statsMiddleware()),
+	}
+	if auth != nil {
+		if err := auth.Configure(settings, &opts); err != nil {
+			return nil, fmt.Errorf("failed to configure the authentication of recording rules write requests: %w", err)
+		}
+	}
+	for k, v := range settings.CustomHeaders {
+		opts.Header.Set(k, v)
+	}
+	if settings.TLSCACert != "" || settings.TLSClientCert != "" || settings.TLSClientKey != "" || settings.TLSSkipVerify {
+		opts.TLS = &sdkhttpclient.TLSOptions{
+			CACertificate:      settings.TLSCACert,
+			ClientCertificate:  settings.TLSClientCert,
+			ClientKey:          settings.TLSClientKey,
+			InsecureSkipVerify: settings.TLSSkipVerify,
+		}
+	}
+
+	var proxy func(*url.URL) (*url.URL, error)
+	if settings.ProxyURL != "" {
+		proxy = (&httpproxy.Config{
+			HTTPProxy:  settings.ProxyURL,
+			HTTPSProxy: settings.ProxyURL,
+			NoProxy:    settings.NoProxy,
+		}).ProxyFunc()
+	}
+	if m != nil {
+		opts.Middlewares = append(opts.Middlewares, poolMiddleware(m, targetName(settings)))
+	}
+	opts.ConfigureTransport = func(_ sdkhttpclient.Options, transport *http.Transport) {
+		if proxy != nil {
+			transport.Proxy = func(req *http.Request) (*url.URL, error) { return proxy(req.URL) }
+		}
+		if m != nil {
+			countConnections(transport, m, targetName(settings))
+		}
+	}
+
+	httpClient, err := sdkhttpclient.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording rules HTTP client: %w", err)
+	}
+	return httpClient, nil
+}
+
 // Write writes the given frames to the Prometheus remote write endpoint.
-// TODO: stub implementation, does not make any remote write calls.
+// The series are written in batches of maxSeriesPerRequest when the feature
+// grafanaManagedRecordingRulesWriteBatching is enabled, and the requests failing because of
+// transient errors are retried when grafanaManagedRecordingRulesWriteRetries is. The features
+// are checked at each write, so they can be toggled at runtime.
+// The conversion of the frames and the write of their series have their own timeouts, so a slow
+// conversion does not leave less time to the write.
+// When only some of the batches could be written, the error is a *PartialWriteError.
+// The series with labels longer than the limits of the target are not written, and the ones it
+// rejects because of them do not fail the write, see dropLongLabels. The buckets of the histograms
+// that are not valid are not written either when they are validated, see dropInvalidHistograms.
+// The samples already written by the rule are skipped when ctx has its WrittenSamples.
+// Writes are logged at debug level, and failed ones at warn level with the kind of their error,
+// along with the rule of ctx, the target, and the size, duration and response of the write.
 func (w PrometheusWriter) Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	start := time.Now()
+	stats := &writeStats{}
+	err := w.write(withWriteStats(ctx, stats), name, t, frames, extraLabels, stats)
+
+	logCtx := []any{
+		"target", w.target,
+		"name", name,
+		"series", stats.series,
+		"written", stats.writtenSeries,
+		"dropped", stats.droppedSeries,
+		"invalid", stats.invalidSeries,
+		"duplicates", stats.duplicateSeries,
+		"old", stats.oldSeries,
+		"rejected", stats.rejectedSeries,
+		"batches", stats.batches,
+		"requests", stats.requests,
+		"bytes", stats.bytes,
+		"status", stats.statusCode,
+		"duration", time.Since(start),
+	}
 	l := w.logger.FromContext(ctx)
+	if err != nil {
+		l.Warn("Failed to write recording rule points", append(logCtx, "kind", writeFailure(err, stats.transportErr), "error", err)...)
+		return err
+	}
+	l.Debug("Wrote recording rule points", logCtx...)
+	return nil
+}
+
+func (w PrometheusWriter) write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string, stats *writeStats) error {
+	if err := checkSampleAge(t, w.maxSampleAge); err != nil {
+		return err
+	}
 
-	points, err := PointsFromFrames(name, t, frames, extraLabels)
+	convertCtx := ctx
+	if w.conversionTimeout > 0 {
+		var cancel context.CancelFunc
+		convertCtx, cancel = context.WithTimeout(ctx, w.conversionTimeout)
+		defer cancel()
+	}
+	series, err := seriesFromFrames(convertCtx, name, t, w.timestampSource, frames, extraLabels)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("conversion of the frames timed out after %s", w.conversionTimeout)
+		}
 		return err
 	}
+	// The samples stamped with the time of their frame may be older than the evaluation
+	if w.timestampSource == TimestampSourceFrame {
+		if series, stats.oldSeries, err = dropOldSamples(series, w.maxSampleAge); err != nil {
+			return err
+		}
+	}
+	series, stats.droppedSeries = w.dropLongLabels(ctx, series)
+	series, stats.invalidSeries = w.dropInvalidHistograms(ctx, series)
+	var pending *pendingWrite
+	if written := writtenSamplesFromContext(ctx); written != nil {
+		series, stats.duplicateSeries, pending = written.skipWritten(series)
+		defer pending.done()
+		// Nothing is sent when all the samples were already written
+		if len(series) == 0 && stats.duplicateSeries > 0 {
+			return nil
+		}
+	}
 
-	// TODO: placeholder for actual remote write call
-	l.Debug("writing points", "points", points)
+	batches := []promremote.TSList{series}
+	if w.enabled(ctx, featuremgmt.FlagGrafanaManagedRecordingRulesWriteBatching) {
+		batches = batchSeries(series, maxSeriesPerRequest)
+	}
+	// Captured writes are encoded as they would be sent, but not sent, see PayloadCapture
+	if capture, ok := capturedBatches(ctx); ok {
+		return capture(batches)
+	}
+	retries := 0
+	if w.enabled(ctx, featuremgmt.FlagGrafanaManagedRecordingRulesWriteRetries) {
+		retries = maxWriteRetries
+	}
+
+	stats.series, stats.batches = len(series), len(batches)
+
+	writeCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	// A batch failing does not prevent the next ones from being written, as it may only be
+	// rejected because of some of its series
+	var failed []BatchError
+	for i, batch := range batches {
+		// The remaining batches are not written when the rule is stopped or its deadline is reached
+		if err := writeCtx.Err(); err != nil {
+			failed = append(failed, BatchError{
+				Batch:  i,
+				Series: len(series) - i*len(batches[0]),
+				Err:    fmt.Errorf("write of recording rule points aborted after %d of %d batches: %w", i, len(batches), err),
+			})
+			break
+		}
+		if err := w.writeWithRetries(writeCtx, batch, retries); err != nil {
+			// The other series of the batch were written, the rejected ones are named in the error
+			if labelTooLongRejection(err) {
+				w.logger.FromContext(ctx).Warn("Recording rule series rejected by the target, their labels are longer than its limits", "target", w.target, "batch", i+1, "error", err)
+				stats.rejectedSeries += len(batch)
+				continue
+			}
+			failed = append(failed, BatchError{Batch: i, Series: len(batch), Err: fmt.Errorf("failed to write recording rule points: %w", err)})
+			continue
+		}
+		stats.writtenSeries += len(batch)
+		if pending != nil {
+			pending.written(batch)
+		}
+	}
+
+	switch {
+	case len(failed) == 0:
+		return nil
+	case stats.writtenSeries == 0:
+		// Nothing was written, as when the series are written in a single request
+		return failed[0].Err
+	default:
+		return &PartialWriteError{Batches: len(batches), Series: len(series), WrittenSeries: stats.writtenSeries, Failed: failed}
+	}
+}
+
+// checkSampleAge returns an ErrSampleTooOld error when the sample at t is older than maxAge, if it is set
+func checkSampleAge(t time.Time, maxAge time.Duration) error {
+	if age := time.Since(t); maxAge > 0 && age > maxAge {
+		return fmt.Errorf("%w: the sample at %s is %s old, the maximum is %s", ErrSampleTooOld, t.Format(time.RFC3339), age.Round(time.Second), maxAge)
+	}
 	return nil
 }
+
+// dropOldSamples removes the series whose sample is older than maxAge, if it is set, and returns the
+// number of series removed. When none is left, the error of the newest sample is returned.
+func dropOldSamples(series promremote.TSList, maxAge time.Duration) (promremote.TSList, int, error) {
+	if maxAge <= 0 || len(series) == 0 {
+		return series, 0, nil
+	}
+	var newest time.Time
+	kept := series[:0]
+	for _, s := range series {
+		if s.Datapoint.Timestamp.After(newest) {
+			newest = s.Datapoint.Timestamp
+		}
+		if checkSampleAge(s.Datapoint.Timestamp, maxAge) == nil {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, len(series), checkSampleAge(newest, maxAge)
+	}
+	return kept, len(series) - len(kept), nil
+}
+
+// seriesFromFrames converts frames to the series of a write request, like PointsFromFrames.
+// The labels of each series are built directly in the slice of the request, as converting the
+// points would copy them once more for the large results of some rules.
+// The series are stamped with the time t, or the time of their frame with TimestampSourceFrame.
+func seriesFromFrames(ctx context.Context, name string, t time.Time, source string, frames data.Frames, extraLabels map[string]string) (promremote.TSList, error) {
+	samples, err := samplesFromFrames(ctx, source, frames)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(promremote.TSList, 0, len(samples))
+	for _, s := range samples {
+		labels := make([]promremote.Label, 0, len(s.Labels)+len(extraLabels)+1)
+		labels = append(labels, promremote.Label{Name: "__name__", Value: name})
+		for k, v := range s.Labels {
+			if _, ok := extraLabels[k]; !ok && k != "__name__" {
+				labels = append(labels, promremote.Label{Name: k, Value: v})
+			}
+		}
+		for k, v := range extraLabels {
+			if k != "__name__" {
+				labels = append(labels, promremote.Label{Name: k, Value: v})
+			}
+		}
+		// Remote write requires the labels of a series to be sorted
+		slices.SortFunc(labels, compareLabel)
+
+		series = append(series, promremote.TimeSeries{
+			Labels: labels,
+			Datapoint: promremote.Datapoint{
+				Timestamp: time.Unix(sampleTime(s, t, source).Unix(), 0),
+				Value:     s.Value,
+			},
+		})
+	}
+	// The series are sorted too, so the requests of the same results are the same whatever the
+	// order the data source returned them in, and they are split into the same batches
+	slices.SortFunc(series, func(a, b promremote.TimeSeries) int { return compareLabels(a.Labels, b.Labels) })
+	return series, ctx.Err()
+}
+
+func compareLabel(a, b promremote.Label) int {
+	return strings.Compare(a.Name, b.Name)
+}
+
+// compareLabels compares two sorted sets of labels by the names and then the values of their labels
+func compareLabels(a, b []promremote.Label) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i].Name, b[i].Name); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a[i].Value, b[i].Value); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+func (w PrometheusWriter) enabled(ctx context.Context, flag string) bool {
+	return w.features != nil && w.features.IsEnabled(ctx, flag)
+}
@@ -0,0 +1,100 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/stretchr/testify/require"
+)
+
+func benchmarkFrames(series int) data.Frames {
+	fields := make([]*data.Field, 0, series+1)
+	fields = append(fields, data.NewField("T", nil, []time.Time{time.Now()}))
+	for i := 0; i < series; i++ {
+		labels := data.Labels{
+			"instance": "host-" + strconv.Itoa(i),
+			"job":      "node",
+			"cluster":  "prod",
+			"region":   "eu-west-1",
+		}
+		fields = append(fields, data.NewField("value", labels, []float64{float64(i)}))
+	}
+	frame := data.NewFrame("test", fields...)
+	frame.SetMeta(&data.FrameMeta{
+		Type:        data.FrameTypeNumericWide,
+		TypeVersion: data.FrameTypeVersion{0, 1},
+	})
+	return data.Frames{frame}
+}
+
+// maxAllocsPerSeries is the allocation budget of the conversion of each series of a result
+const maxAllocsPerSeries = 4
+
+func TestSeriesFromFramesAllocations(t *testing.T) {
+	frames := benchmarkFrames(1000)
+	extraLabels := map[string]string{"rule": "test", "team": "alerting"}
+	allocs := testing.AllocsPerRun(10, func() {
+		_, _ = seriesFromFrames(context.Background(), "test_metric", time.Now(), "", frames, extraLabels)
+	})
+	require.LessOrEqual(t, allocs, float64(maxAllocsPerSeries*1000))
+}
+
+func BenchmarkPointsFromFrames(b *testing.B) {
+	extraLabels := map[string]string{"rule": "test", "team": "alerting"}
+	for _, series := range []int{10, 1000, 100000} {
+		frames := benchmarkFrames(series)
+		b.Run(fmt.Sprintf("%d series", series), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := PointsFromFrames(context.Background(), "test_metric", time.Now(), "", frames, extraLabels); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSeriesFromFrames(b *testing.B) {
+	extraLabels := map[string]string{"rule": "test", "team": "alerting"}
+	for _, series := range []int{10, 1000, 100000} {
+		frames := benchmarkFrames(series)
+		b.Run(fmt.Sprintf("%d series", series), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := seriesFromFrames(context.Background(), "test_metric", time.Now(), "", frames, extraLabels); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRemoteWriteClient(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := newRemoteWriteClient(server.URL, server.Client())
+	series, err := seriesFromFrames(context.Background(), "test_metric", time.Now(), "", benchmarkFrames(maxSeriesPerRequest), nil)
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.WriteTimeSeries(context.Background(), series, promremote.WriteOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
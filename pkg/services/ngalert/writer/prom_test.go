@@ -1,19 +1,335 @@
 package writer
 
 import (
+	"context"
+	"io"
 	"math"
 	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"slices"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/golang/snappy"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/setting"
 )
 
 func TestPrometheusWriter_Write(t *testing.T) {
-	t.Skip("TODO: implement")
+	var received []prompb.TimeSeries
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		compressed, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		b, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		var req prompb.WriteRequest
+		require.NoError(t, req.Unmarshal(b))
+		received = req.Timeseries
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	settings := setting.RecordingRuleTargetSettings{
+		URL:               server.URL + "/api/v1/push",
+		BasicAuthUsername: "user",
+		BasicAuthPassword: "password",
+		CustomHeaders:     map[string]string{"X-Scope-OrgID": "tenant"},
+		Timeout:           time.Second,
+	}
+	writer, err := NewPrometheusWriter(settings, nil, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	now := time.Now()
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"foo": "1"}})
+	require.NoError(t, writer.Write(context.Background(), "test_metric", now, frames, map[string]string{"extra": "label"}))
+
+	user, password, ok := (&http.Request{Header: header}).BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user", user)
+	require.Equal(t, "password", password)
+	require.Equal(t, "tenant", header.Get("X-Scope-OrgID"))
+
+	require.Len(t, received, 1)
+	require.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "test_metric"},
+		{Name: "extra", Value: "label"},
+		{Name: "foo", Value: "1"},
+	}, received[0].Labels)
+	require.Len(t, received[0].Samples, 1)
+	require.Equal(t, now.Unix()*1000, received[0].Samples[0].Timestamp)
+
+	t.Run("bearer token", func(t *testing.T) {
+		settings := settings
+		settings.BasicAuthUsername, settings.BasicAuthPassword = "", ""
+		settings.BearerToken = "token"
+		writer, err := NewPrometheusWriter(settings, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, writer.Write(context.Background(), "test_metric", now, frames, nil))
+		require.Equal(t, "Bearer token", header.Get("Authorization"))
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		var proxied []string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = append(proxied, r.URL.String())
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer proxy.Close()
+
+		settings := settings
+		settings.URL = "http://mimir.example/api/v1/push"
+		settings.ProxyURL = proxy.URL
+		writer, err := NewPrometheusWriter(settings, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, writer.Write(context.Background(), "test_metric", now, frames, nil))
+		require.Equal(t, []string{"http://mimir.example/api/v1/push"}, proxied)
+
+		// Hosts of no_proxy are written to directly
+		settings.NoProxy = "mimir.example"
+		writer, err = NewPrometheusWriter(settings, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.Error(t, writer.Write(context.Background(), "test_metric", now, frames, nil))
+		require.Len(t, proxied, 1)
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer failing.Close()
+		settings := settings
+		settings.URL = failing.URL
+		writer, err := NewPrometheusWriter(settings, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.Error(t, writer.Write(context.Background(), "test_metric", now, frames, nil))
+	})
+}
+
+func TestPrometheusWriter_WriteFeatures(t *testing.T) {
+	var requests, failures int
+	var series []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		compressed, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		b, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		var req prompb.WriteRequest
+		require.NoError(t, req.Unmarshal(b))
+		series = append(series, len(req.Timeseries))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	settings := setting.RecordingRuleTargetSettings{URL: server.URL, Timeout: time.Second}
+	labels := make([]map[string]string, maxSeriesPerRequest+1)
+	for i := range labels {
+		labels[i] = map[string]string{"series": strconv.Itoa(i)}
+	}
+	frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, labels)
+
+	write := func(features featuremgmt.FeatureToggles) error {
+		requests, series = 0, nil
+		writer, err := NewPrometheusWriter(settings, features, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		return writer.Write(context.Background(), "test_metric", time.Now(), frames, nil)
+	}
+
+	t.Run("series are written in a single request without batching", func(t *testing.T) {
+		require.NoError(t, write(featuremgmt.WithFeatures()))
+		require.Equal(t, []int{maxSeriesPerRequest + 1}, series)
+	})
+
+	t.Run("series are written in batches with batching", func(t *testing.T) {
+		require.NoError(t, write(featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteBatching)))
+		require.Equal(t, []int{maxSeriesPerRequest, 1}, series)
+	})
+
+	t.Run("transient errors fail writes without retries", func(t *testing.T) {
+		failures = 1
+		require.Error(t, write(featuremgmt.WithFeatures()))
+		require.Equal(t, 1, requests)
+	})
+
+	t.Run("transient errors are retried with retries", func(t *testing.T) {
+		failures = 1
+		require.NoError(t, write(featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteRetries)))
+		require.Equal(t, 2, requests)
+	})
+}
+
+func TestPrometheusWriter_WriteTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"foo": "1"}})
+
+	t.Run("conversion is bounded by its timeout", func(t *testing.T) {
+		writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{URL: server.URL, ConversionTimeout: time.Nanosecond, Timeout: time.Second}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		err = writer.Write(context.Background(), "test_metric", time.Now(), frames, nil)
+		require.EqualError(t, err, "conversion of the frames timed out after 1ns")
+	})
+
+	t.Run("retries are bounded by the write timeout", func(t *testing.T) {
+		writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{URL: server.URL, Timeout: 100 * time.Millisecond},
+			featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteRetries), nil, log.NewNopLogger())
+		require.NoError(t, err)
+		start := time.Now()
+		require.Error(t, writer.Write(context.Background(), "test_metric", time.Now(), frames, nil))
+		require.Less(t, time.Since(start), writeRetryBackoff)
+	})
+
+	t.Run("retries stop when the context is done", func(t *testing.T) {
+		writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{URL: server.URL, Timeout: time.Minute},
+			featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteRetries), nil, log.NewNopLogger())
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = writer.Write(ctx, "test_metric", time.Now(), frames, nil)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorContains(t, err, "after 1 attempts")
+	})
+
+	t.Run("remaining batches are not written when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			cancel()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		labels := make([]map[string]string, 2*maxSeriesPerRequest)
+		for i := range labels {
+			labels[i] = map[string]string{"series": strconv.Itoa(i)}
+		}
+		writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{URL: server.URL, Timeout: time.Second},
+			featuremgmt.WithFeatures(featuremgmt.FlagGrafanaManagedRecordingRulesWriteBatching), nil, log.NewNopLogger())
+		require.NoError(t, err)
+		err = writer.Write(ctx, "test_metric", time.Now(), frameGenFromLabels(t, data.FrameTypeNumericMulti, labels), nil)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 1, requests)
+	})
+
+	t.Run("conversion stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := PointsFromFrames(ctx, "test_metric", time.Now(), "", frames, nil)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestRetryableWriteError(t *testing.T) {
+	ctx := context.Background()
+	require.True(t, retryableWriteError(ctx, writeErr(http.StatusServiceUnavailable)))
+	require.True(t, retryableWriteError(ctx, writeErr(http.StatusTooManyRequests)))
+	require.True(t, retryableWriteError(ctx, writeErr(0)))
+	require.False(t, retryableWriteError(ctx, writeErr(http.StatusBadRequest)))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.False(t, retryableWriteError(canceled, writeErr(http.StatusServiceUnavailable)))
+}
+
+type writeErr int
+
+func (e writeErr) Error() string   { return "write failed" }
+func (e writeErr) StatusCode() int { return int(e) }
+
+func TestSeriesFromFrames(t *testing.T) {
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"__name__": "original", "foo": "1", "rule": "frame"}})
+	now := time.Now()
+	series, err := seriesFromFrames(context.Background(), "test_metric", now, "", frames, map[string]string{"rule": "extra", "__name__": "extra"})
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	// The name of the rule and its extra labels take precedence over the labels of the frames
+	require.Equal(t, []promremote.Label{
+		{Name: "__name__", Value: "test_metric"},
+		{Name: "foo", Value: "1"},
+		{Name: "rule", Value: "extra"},
+	}, series[0].Labels)
+	require.Equal(t, now.Unix(), series[0].Datapoint.Timestamp.Unix())
+}
+
+func TestSeriesFromFramesTimestampSource(t *testing.T) {
+	now := time.Now()
+	delayed := now.Add(-10 * time.Minute)
+	frame := func(ts time.Time, instance string) *data.Frame {
+		f := data.NewFrame("",
+			data.NewField("T", nil, []time.Time{ts}),
+			data.NewField("value", data.Labels{"instance": instance}, []float64{1}),
+		)
+		f.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: data.FrameTypeVersion{0, 1}})
+		return f
+	}
+	// The frame without time is stamped with the time of the evaluation
+	frames := data.Frames{frame(delayed, "a"), frame(time.Time{}, "b")}
+
+	timestamps := func(source string) []int64 {
+		series, err := seriesFromFrames(context.Background(), "test_metric", now, source, frames, nil)
+		require.NoError(t, err)
+		ts := make([]int64, 0, len(series))
+		for _, s := range series {
+			ts = append(ts, s.Datapoint.Timestamp.Unix())
+		}
+		return ts
+	}
+	require.Equal(t, []int64{now.Unix(), now.Unix()}, timestamps(""))
+	require.Equal(t, []int64{now.Unix(), now.Unix()}, timestamps(TimestampSourceEvaluation))
+	require.Equal(t, []int64{delayed.Unix(), now.Unix()}, timestamps(TimestampSourceFrame))
+
+	points, err := PointsFromFrames(context.Background(), "test_metric", now, TimestampSourceFrame, frames, nil)
+	require.NoError(t, err)
+	require.Equal(t, delayed.Unix(), points[0].Metric.T)
+	require.Equal(t, now.Unix(), points[1].Metric.T)
+}
+
+func TestSeriesFromFramesOrder(t *testing.T) {
+	labels := []map[string]string{{"instance": "b", "job": "node"}, {"instance": "a"}, {"instance": "a", "job": "node"}}
+	reversed := slices.Clone(labels)
+	slices.Reverse(reversed)
+
+	now := time.Now()
+	encode := func(labels []map[string]string) []byte {
+		series, err := seriesFromFrames(context.Background(), "test_metric", now, "", frameGenFromLabels(t, data.FrameTypeNumericMulti, labels), map[string]string{"rule": "test"})
+		require.NoError(t, err)
+		for i := range series {
+			series[i].Datapoint.Value = 1
+		}
+		b, err := writeRequest(series).Marshal()
+		require.NoError(t, err)
+		return b
+	}
+	require.Equal(t, encode(labels), encode(reversed))
+
+	series, err := seriesFromFrames(context.Background(), "test_metric", now, "", frameGenFromLabels(t, data.FrameTypeNumericMulti, labels), nil)
+	require.NoError(t, err)
+	var instances []string
+	for _, s := range series {
+		instances = append(instances, s.Labels[1].Value)
+	}
+	require.Equal(t, []string{"a", "a", "b"}, instances)
+	require.Len(t, series[0].Labels, 2)
 }
 
 func TestPointsFromFrames(t *testing.T) {
@@ -36,7 +352,7 @@ func TestPointsFromFrames(t *testing.T) {
 				frames := data.Frames{data.NewFrame("test")}
 				now := time.Now()
 
-				_, err := PointsFromFrames("test", now, frames, extraLabels)
+				_, err := PointsFromFrames(context.Background(), "test", now, "", frames, extraLabels)
 				require.Error(t, err)
 			})
 		}
@@ -49,7 +365,7 @@ func TestPointsFromFrames(t *testing.T) {
 				frames := frameGenFromLabels(t, tc.frameType, series)
 				now := time.Now()
 
-				points, err := PointsFromFrames("test", now, frames, extraLabels)
+				points, err := PointsFromFrames(context.Background(), "test", now, "", frames, extraLabels)
 
 				require.NoError(t, err)
 				require.Len(t, points, len(series))
@@ -0,0 +1,259 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// The protocols of the write requests to Prometheus targets
+const (
+	// ProtocolRemoteWriteV1 is the Prometheus remote write protocol 1.0, the one of the targets
+	// whose protocol is not set
+	ProtocolRemoteWriteV1 = "remote_write_v1"
+	// ProtocolRemoteWriteV2 is the Prometheus remote write protocol 2.0
+	ProtocolRemoteWriteV2 = "remote_write_v2"
+	// ProtocolOTLP is the OTLP/HTTP protocol with protobuf payloads, the series being written as gauges
+	ProtocolOTLP = "otlp"
+	// ProtocolAuto detects the protocol of the target the first time it is written to, see
+	// detectProtocol
+	ProtocolAuto = "auto"
+)
+
+// Protocols are the protocols a Prometheus target can be written to with
+var Protocols = []string{ProtocolRemoteWriteV1, ProtocolRemoteWriteV2, ProtocolOTLP, ProtocolAuto}
+
+// remoteWriteV2ContentType is the content type of the requests of the remote write protocol 2.0
+const remoteWriteV2ContentType = "application/x-protobuf;proto=io.prometheus.write.v2.Request"
+
+// remoteWriteV2SamplesWritten is the header of the responses of the receivers of the remote write
+// protocol 2.0, which receivers of the protocol 1.0 do not set
+const remoteWriteV2SamplesWritten = "X-Prometheus-Remote-Write-Samples-Written"
+
+// otlpScope is the instrumentation scope of the metrics written with OTLP
+const otlpScope = "grafana-recording-rules"
+
+// protocolSelector holds the protocol of a target, detected once with detect when it is auto
+type protocolSelector struct {
+	configured string
+	detect     func(context.Context) (string, error)
+	logger     log.Logger
+
+	mtx      sync.Mutex
+	detected string
+}
+
+func newProtocolSelector(protocol string, detect func(context.Context) (string, error), l log.Logger) *protocolSelector {
+	return &protocolSelector{configured: protocol, detect: detect, logger: l}
+}
+
+// protocol returns the protocol of the requests to the target, detecting it when it is auto and
+// was not detected yet. The detection is attempted again at the next write when the target could
+// not be reached, or rejected the credentials.
+func (s *protocolSelector) protocol(ctx context.Context) (string, error) {
+	if s == nil || s.configured == "" {
+		return ProtocolRemoteWriteV1, nil
+	}
+	if s.configured != ProtocolAuto {
+		return s.configured, nil
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.detected != "" {
+		return s.detected, nil
+	}
+	protocol, err := s.detect(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the protocol of the target: %w", err)
+	}
+	s.logger.FromContext(ctx).Info("Detected the protocol of the recording rules target", "protocol", protocol)
+	s.detected = protocol
+	return protocol, nil
+}
+
+// detectProtocol sends empty requests of each protocol to the target, and returns the first one
+// it accepts. Receivers of the remote write protocol 2.0 tell it with the headers of their
+// responses, as receivers of the 1.0 one accept its requests too. OTLP requests are not snappy
+// encoded, so remote write receivers reject them. The remote write protocol 1.0 is returned
+// when no request is accepted, like when the target rejects empty requests.
+// It returns a *ProbeError when the target cannot be reached or rejects the credentials.
+func (c *remoteWriteClient) detectProtocol(ctx context.Context) (string, error) {
+	for _, protocol := range []string{ProtocolRemoteWriteV2, ProtocolOTLP, ProtocolRemoteWriteV1} {
+		body, err := encodeRequest(protocol, &prompb.WriteRequest{}, nil)
+		if err != nil {
+			return "", err
+		}
+		if protocol != ProtocolOTLP {
+			body = snappy.Encode(nil, body)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+		if err != nil {
+			return "", &ProbeError{Failure: ProbeFailureConnection, Err: err}
+		}
+		setProtocolHeaders(req, protocol)
+		req.Header.Set("User-Agent", c.userAgent)
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			return "", &ProbeError{Failure: probeFailure(err), Err: err}
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+
+		switch {
+		case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+			return "", &ProbeError{Failure: ProbeFailureAuth, Err: fmt.Errorf("target responded with status %d", res.StatusCode)}
+		case res.StatusCode/100 != 2:
+			continue
+		case protocol == ProtocolRemoteWriteV2 && res.Header.Get(remoteWriteV2SamplesWritten) == "":
+			continue
+		}
+		return protocol, nil
+	}
+	return ProtocolRemoteWriteV1, nil
+}
+
+// setProtocolHeaders sets the headers telling the protocol of a write request
+func setProtocolHeaders(req *http.Request, protocol string) {
+	switch protocol {
+	case ProtocolRemoteWriteV2:
+		req.Header.Set("Content-Type", remoteWriteV2ContentType)
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "2.0.0")
+	case ProtocolOTLP:
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	default:
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+}
+
+// encodeRequest appends the payload of wr in protocol to b, before its compression
+func encodeRequest(protocol string, wr *prompb.WriteRequest, b []byte) ([]byte, error) {
+	switch protocol {
+	case ProtocolRemoteWriteV2:
+		return appendRequestV2(b, wr), nil
+	case ProtocolOTLP:
+		payload, err := otlpRequest(wr).MarshalProto()
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal OTLP request: %w", err)
+		}
+		return append(b, payload...), nil
+	default:
+		size := wr.Size()
+		b = slices.Grow(b, size)[len(b) : len(b)+size]
+		n, err := wr.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal protobuf: %w", err)
+		}
+		return b[size-n:], nil
+	}
+}
+
+// The field numbers of the messages of the remote write protocol 2.0, see
+// https://prometheus.io/docs/specs/remote_write_spec_2_0/
+const (
+	requestV2Symbols    = 4
+	requestV2Timeseries = 5
+	seriesV2LabelsRefs  = 1
+	seriesV2Samples     = 2
+	sampleV2Value       = 1
+	sampleV2Timestamp   = 2
+)
+
+// appendRequestV2 appends wr encoded as an io.prometheus.write.v2.Request to b. Its label names
+// and values are written once in the symbols of the request, and referenced by the series.
+func appendRequestV2(b []byte, wr *prompb.WriteRequest) []byte {
+	// The first symbol is always the empty string
+	symbols := []string{""}
+	refs := map[string]uint64{"": 0}
+	ref := func(s string) uint64 {
+		r, ok := refs[s]
+		if !ok {
+			r = uint64(len(symbols))
+			refs[s] = r
+			symbols = append(symbols, s)
+		}
+		return r
+	}
+
+	var series, labels, sample []byte
+	for _, ts := range wr.Timeseries {
+		labels = labels[:0]
+		for _, l := range ts.Labels {
+			labels = protowire.AppendVarint(labels, ref(l.Name))
+			labels = protowire.AppendVarint(labels, ref(l.Value))
+		}
+		var s []byte
+		s = protowire.AppendTag(s, seriesV2LabelsRefs, protowire.BytesType)
+		s = protowire.AppendBytes(s, labels)
+		for _, smp := range ts.Samples {
+			sample = sample[:0]
+			sample = protowire.AppendTag(sample, sampleV2Value, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(smp.Value))
+			sample = protowire.AppendTag(sample, sampleV2Timestamp, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(smp.Timestamp))
+			s = protowire.AppendTag(s, seriesV2Samples, protowire.BytesType)
+			s = protowire.AppendBytes(s, sample)
+		}
+		series = protowire.AppendTag(series, requestV2Timeseries, protowire.BytesType)
+		series = protowire.AppendBytes(series, s)
+	}
+
+	for _, symbol := range symbols {
+		b = protowire.AppendTag(b, requestV2Symbols, protowire.BytesType)
+		b = protowire.AppendString(b, symbol)
+	}
+	return append(b, series...)
+}
+
+// otlpRequest converts wr to an OTLP export request, each series being a data point of the gauge
+// named after it, with its other labels as attributes
+func otlpRequest(wr *prompb.WriteRequest) pmetricotlp.ExportRequest {
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(otlpScope)
+
+	gauges := make(map[string]pmetric.Gauge)
+	for _, ts := range wr.Timeseries {
+		var name string
+		attributes := pcommon.NewMap()
+		attributes.EnsureCapacity(len(ts.Labels))
+		for _, l := range ts.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			attributes.PutStr(l.Name, l.Value)
+		}
+		gauge, ok := gauges[name]
+		if !ok {
+			m := sm.Metrics().AppendEmpty()
+			m.SetName(name)
+			gauge = m.SetEmptyGauge()
+			gauges[name] = gauge
+		}
+		for _, s := range ts.Samples {
+			dp := gauge.DataPoints().AppendEmpty()
+			attributes.CopyTo(dp.Attributes())
+			dp.SetTimestamp(pcommon.Timestamp(s.Timestamp * 1e6))
+			dp.SetDoubleValue(s.Value)
+		}
+	}
+	return pmetricotlp.NewExportRequestFromMetrics(md)
+}
@@ -0,0 +1,212 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// protocolReceiver receives the write requests of a protocol, rejecting the ones of the others like
+// the receivers of each protocol do
+func protocolReceiver(t *testing.T, protocol string, received *[][]byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if protocol != ProtocolOTLP {
+			if body, err = snappy.Decode(nil, body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		switch {
+		case protocol == ProtocolOTLP && r.Header.Get("Content-Encoding") != "":
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		case protocol == ProtocolRemoteWriteV2 && r.Header.Get("Content-Type") == remoteWriteV2ContentType:
+			w.Header().Set(remoteWriteV2SamplesWritten, "0")
+		}
+		*received = append(*received, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDetectProtocol(t *testing.T) {
+	for _, protocol := range []string{ProtocolRemoteWriteV1, ProtocolRemoteWriteV2, ProtocolOTLP} {
+		t.Run(protocol, func(t *testing.T) {
+			var received [][]byte
+			server := protocolReceiver(t, protocol, &received)
+			detected, err := newRemoteWriteClient(server.URL, server.Client()).detectProtocol(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, protocol, detected)
+		})
+	}
+
+	t.Run("rejected credentials", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		_, err := newRemoteWriteClient(server.URL, server.Client()).detectProtocol(context.Background())
+		var probeErr *ProbeError
+		require.True(t, errors.As(err, &probeErr))
+		require.Equal(t, ProbeFailureAuth, probeErr.Failure)
+	})
+
+	t.Run("detected once", func(t *testing.T) {
+		detections := 0
+		selector := newProtocolSelector(ProtocolAuto, func(context.Context) (string, error) {
+			detections++
+			if detections == 1 {
+				return "", errors.New("unreachable")
+			}
+			return ProtocolOTLP, nil
+		}, log.NewNopLogger())
+
+		_, err := selector.protocol(context.Background())
+		require.Error(t, err)
+		for i := 0; i < 2; i++ {
+			protocol, err := selector.protocol(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, ProtocolOTLP, protocol)
+		}
+		require.Equal(t, 2, detections)
+	})
+}
+
+func TestWriteProtocols(t *testing.T) {
+	now := time.Now()
+	frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, []map[string]string{{"instance": "a"}, {"instance": "b"}})
+	write := func(t *testing.T, protocol string) []byte {
+		t.Helper()
+		var received [][]byte
+		server := protocolReceiver(t, protocol, &received)
+		writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{
+			URL:      server.URL,
+			Timeout:  time.Second,
+			Protocol: ProtocolAuto,
+		}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, writer.Probe(context.Background()))
+		require.NoError(t, writer.Write(context.Background(), "test_metric", now, frames, nil))
+		// The last request is the write, after the ones of the detection
+		require.NotEmpty(t, received)
+		return received[len(received)-1]
+	}
+	value := func(instance string) float64 {
+		return extractValue(t, frames, map[string]string{"instance": instance}, data.FrameTypeNumericMulti)
+	}
+
+	t.Run("remote write 2.0", func(t *testing.T) {
+		symbols, series := decodeRequestV2(t, write(t, ProtocolRemoteWriteV2))
+		require.Equal(t, []string{"", "__name__", "test_metric", "instance", "a", "b"}, symbols)
+		require.Equal(t, []seriesV2{
+			{labelsRefs: []uint64{1, 2, 3, 4}, value: value("a"), timestamp: now.Unix() * 1000},
+			{labelsRefs: []uint64{1, 2, 3, 5}, value: value("b"), timestamp: now.Unix() * 1000},
+		}, series)
+	})
+
+	t.Run("otlp", func(t *testing.T) {
+		req := pmetricotlp.NewExportRequest()
+		require.NoError(t, req.UnmarshalProto(write(t, ProtocolOTLP)))
+		metrics := req.Metrics().ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		require.Equal(t, 1, metrics.Len())
+		require.Equal(t, "test_metric", metrics.At(0).Name())
+		points := metrics.At(0).Gauge().DataPoints()
+		require.Equal(t, 2, points.Len())
+		for i, instance := range []string{"a", "b"} {
+			require.Equal(t, map[string]any{"instance": instance}, points.At(i).Attributes().AsRaw())
+			require.Equal(t, value(instance), points.At(i).DoubleValue())
+			require.Equal(t, time.Unix(now.Unix(), 0).UTC(), points.At(i).Timestamp().AsTime())
+		}
+	})
+
+	t.Run("remote write 1.0", func(t *testing.T) {
+		var req prompb.WriteRequest
+		require.NoError(t, req.Unmarshal(write(t, ProtocolRemoteWriteV1)))
+		require.Len(t, req.Timeseries, 2)
+	})
+}
+
+type seriesV2 struct {
+	labelsRefs []uint64
+	value      float64
+	timestamp  int64
+}
+
+// decodeRequestV2 decodes the symbols and the series of a request of the remote write protocol 2.0,
+// with a single sample each
+func decodeRequestV2(t *testing.T, b []byte) ([]string, []seriesV2) {
+	t.Helper()
+	var symbols []string
+	var series []seriesV2
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.Equal(t, protowire.BytesType, typ)
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		require.Positive(t, n)
+		b = b[n:]
+		switch num {
+		case requestV2Symbols:
+			symbols = append(symbols, string(v))
+		case requestV2Timeseries:
+			series = append(series, decodeSeriesV2(t, v))
+		}
+	}
+	return symbols, series
+}
+
+func decodeSeriesV2(t *testing.T, b []byte) seriesV2 {
+	t.Helper()
+	var s seriesV2
+	for len(b) > 0 {
+		num, _, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		require.Positive(t, n)
+		b = b[n:]
+		switch num {
+		case seriesV2LabelsRefs:
+			for len(v) > 0 {
+				ref, n := protowire.ConsumeVarint(v)
+				s.labelsRefs = append(s.labelsRefs, ref)
+				v = v[n:]
+			}
+		case seriesV2Samples:
+			for len(v) > 0 {
+				num, _, n := protowire.ConsumeTag(v)
+				v = v[n:]
+				switch num {
+				case sampleV2Value:
+					bits, n := protowire.ConsumeFixed64(v)
+					s.value = math.Float64frombits(bits)
+					v = v[n:]
+				case sampleV2Timestamp:
+					ts, n := protowire.ConsumeVarint(v)
+					s.timestamp = int64(ts)
+					v = v[n:]
+				}
+			}
+		}
+	}
+	return s
+}
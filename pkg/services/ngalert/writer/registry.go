@@ -0,0 +1,121 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// TypePrometheus is the type of the targets written to with the Prometheus remote write protocol,
+// the one of the targets whose type is not set.
+const TypePrometheus = "prometheus"
+
+// Writer writes the points of recording rules to a target.
+type Writer interface {
+	// Write writes the given frames to the target.
+	Write(ctx context.Context, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
+	// Probe checks that the target can be written to, without writing any point.
+	Probe(ctx context.Context) error
+}
+
+// WriterFactory creates the writer of a target from its settings, whose credentials have been decrypted.
+// The metrics are nil when the writer is not used by recording rules, like when settings are verified.
+type WriterFactory func(settings setting.RecordingRuleTargetSettings, features featuremgmt.FeatureToggles, m *metrics.RemoteWriter, l log.Logger) (Writer, error)
+
+// Registry holds the writer factories of the types of targets, and the middlewares the writes of
+// all the writers it creates go through.
+type Registry struct {
+	mtx         sync.RWMutex
+	factories   map[string]WriterFactory
+	middlewares []Middleware
+}
+
+// DefaultRegistry is the registry the writers of the targets of recording rules are created from.
+// Backends are registered to it before the writers are created, when Grafana starts.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns a registry with the factories of the Prometheus and graphite writers.
+func NewRegistry() *Registry {
+	r := &Registry{factories: make(map[string]WriterFactory)}
+	r.factories[TypePrometheus] = func(settings setting.RecordingRuleTargetSettings, features featuremgmt.FeatureToggles, m *metrics.RemoteWriter, l log.Logger) (Writer, error) {
+		return NewPrometheusWriter(settings, features, m, l)
+	}
+	r.factories[TypeGraphite] = newGraphiteWriter
+	return r
+}
+
+// Register registers the factory of the writers of a type of targets.
+// It fails when the type already has one.
+func (r *Registry) Register(typ string, factory WriterFactory) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.factories[typ]; ok {
+		return fmt.Errorf("a writer factory is already registered for recording rules targets of type %q", typ)
+	}
+	r.factories[typ] = factory
+	return nil
+}
+
+// Use adds middlewares to the writers created after it, after the ones already added, see Chain.
+func (r *Registry) Use(middlewares ...Middleware) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Types returns the sorted types of targets that have a factory.
+func (r *Registry) Types() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	types := make([]string, 0, len(r.factories))
+	for typ := range r.factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates the writer of a target with the factory of its type, its writes going through the
+// middlewares of the registry.
+func (r *Registry) New(settings setting.RecordingRuleTargetSettings, features featuremgmt.FeatureToggles, m *metrics.RemoteWriter, l log.Logger) (Writer, error) {
+	typ := targetType(settings)
+	r.mtx.RLock()
+	factory, ok := r.factories[typ]
+	middlewares := r.middlewares
+	r.mtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown recording rules target type %q, must be one of %v", typ, r.Types())
+	}
+	w, err := factory(settings, features, m, l)
+	if err != nil {
+		return nil, err
+	}
+	return Chain(w, middlewares...), nil
+}
+
+// defaultTargetName is the name the default target is logged with
+const defaultTargetName = "default"
+
+// targetName returns the name of a target as it is logged
+func targetName(settings setting.RecordingRuleTargetSettings) string {
+	if settings.Name == "" {
+		return defaultTargetName
+	}
+	return settings.Name
+}
+
+func targetType(settings setting.RecordingRuleTargetSettings) string {
+	if settings.Type == "" {
+		return TypePrometheus
+	}
+	return settings.Type
+}
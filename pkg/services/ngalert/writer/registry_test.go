@@ -0,0 +1,59 @@
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("prometheus writers are created for targets without type", func(t *testing.T) {
+		r := NewRegistry()
+		w, err := r.New(setting.RecordingRuleTargetSettings{URL: "http://mimir/api/v1/push", Timeout: time.Second}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.IsType(t, &PrometheusWriter{}, w)
+	})
+
+	t.Run("registered factories create the writers of their type", func(t *testing.T) {
+		r := NewRegistry()
+		written := &recordedWriter{}
+		require.NoError(t, r.Register("influx", func(setting.RecordingRuleTargetSettings, featuremgmt.FeatureToggles, *metrics.RemoteWriter, log.Logger) (Writer, error) {
+			return written, nil
+		}))
+		require.ErrorContains(t, r.Register(TypePrometheus, nil), "already registered")
+		require.Equal(t, []string{TypeGraphite, "influx", TypePrometheus}, r.Types())
+
+		w, err := newTargetsWriter(r, setting.RecordingRuleSettings{
+			Targets: []setting.RecordingRuleTargetSettings{{Name: "influx", Type: "influx", URL: "http://influx/api/v2/write", Timeout: time.Second}},
+		}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, w.Write(context.Background(), "influx", "test_metric", time.Now(), nil, nil))
+		require.Equal(t, []string{"test_metric"}, written.names)
+	})
+
+	t.Run("unknown types are rejected", func(t *testing.T) {
+		_, err := NewRegistry().New(setting.RecordingRuleTargetSettings{Type: "otlp", URL: "http://otlp", Timeout: time.Second}, nil, nil, log.NewNopLogger())
+		require.EqualError(t, err, `unknown recording rules target type "otlp", must be one of [graphite prometheus]`)
+	})
+}
+
+type recordedWriter struct {
+	names []string
+}
+
+func (w *recordedWriter) Write(_ context.Context, name string, _ time.Time, _ data.Frames, _ map[string]string) error {
+	w.names = append(w.names, name)
+	return nil
+}
+
+func (w *recordedWriter) Probe(context.Context) error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+)
+
+const (
+	// maxSeriesPerRequest is the number of series of each write request when writes are batched
+	maxSeriesPerRequest = 2000
+	// maxWriteRetries is the number of times a write request is retried when retries are enabled
+	maxWriteRetries = 3
+	// writeRetryBackoff is the wait before the first retry of a write request, doubling for the next ones
+	writeRetryBackoff = 500 * time.Millisecond
+)
+
+// batchSeries splits series into batches of at most size series
+func batchSeries(series promremote.TSList, size int) []promremote.TSList {
+	batches := make([]promremote.TSList, 0, (len(series)+size-1)/size)
+	for start := 0; start < len(series); start += size {
+		batches = append(batches, series[start:min(start+size, len(series))])
+	}
+	return batches
+}
+
+// writeWithRetries writes series, retrying up to retries times when the target fails with a
+// transient error. It stops waiting for the next attempt when ctx is done, returning its error
+// along with the one of the last attempt.
+func (w PrometheusWriter) writeWithRetries(ctx context.Context, series promremote.TSList, retries int) error {
+	backoff := writeRetryBackoff
+	for attempt := 0; ; attempt++ {
+		_, err := w.client.WriteTimeSeries(ctx, series, promremote.WriteOptions{})
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w after %d attempts: %w", ctx.Err(), attempt+1, err)
+		}
+		if attempt == retries || !retryableWriteError(ctx, err) {
+			return err
+		}
+
+		w.logger.FromContext(ctx).Debug("Retrying recording rule write after a transient error", "attempt", attempt+1, "wait", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w after %d attempts: %w", ctx.Err(), attempt+1, err)
+		}
+		backoff *= 2
+	}
+}
+
+// retryableWriteError returns whether a write request failing with err may succeed when sent again:
+// when the target is rate limiting, failing, or could not be reached
+func retryableWriteError(ctx context.Context, err promremote.WriteError) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	code := err.StatusCode()
+	return code == 0 || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,127 @@
+package writer
+
+// SchemaDialect is the JSON Schema dialect of the schema of the settings of the targets
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the durations of the settings, like 30s or 1h30m
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// Schema is a JSON Schema, with the keywords the schema of the settings of the targets uses.
+type Schema struct {
+	Schema            string              `json:"$schema,omitempty"`
+	Title             string              `json:"title,omitempty"`
+	Description       string              `json:"description,omitempty"`
+	Type              string              `json:"type,omitempty"`
+	Format            string              `json:"format,omitempty"`
+	Pattern           string              `json:"pattern,omitempty"`
+	Enum              []string            `json:"enum,omitempty"`
+	Const             string              `json:"const,omitempty"`
+	Default           any                 `json:"default,omitempty"`
+	Minimum           *int                `json:"minimum,omitempty"`
+	WriteOnly         bool                `json:"writeOnly,omitempty"`
+	Properties        map[string]*Schema  `json:"properties,omitempty"`
+	Required          []string            `json:"required,omitempty"`
+	DependentRequired map[string][]string `json:"dependentRequired,omitempty"`
+	// A *Schema, or false when the object cannot have other properties
+	AdditionalProperties any       `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema `json:"allOf,omitempty"`
+	If                   *Schema   `json:"if,omitempty"`
+	Then                 *Schema   `json:"then,omitempty"`
+}
+
+// AuthSchemaProvider is implemented by the auth providers that describe the settings of the targets
+// of their auth type, see TargetSettingsSchema.
+type AuthSchemaProvider interface {
+	// AuthSchema returns the schema the settings of a target of the auth type match, like the
+	// parameters of its auth subsection
+	AuthSchema() *Schema
+}
+
+// TargetSettingsSchema returns the JSON Schema of the settings of a target of recording rules, the keys
+// of its section and of its subsections, so they can be validated before they are written to the
+// configuration. The types of targets and the auth types are the ones registered to DefaultRegistry and
+// DefaultAuthProviders, and the auth providers implementing AuthSchemaProvider describe their settings.
+// Like validateTarget, it does not check the settings of the auth types that are not set explicitly.
+func TargetSettingsSchema() *Schema {
+	s := &Schema{
+		Schema:      SchemaDialect,
+		Title:       "Recording rules target",
+		Description: "The settings of a target the results of recording rules are written to, the keys of its section of the configuration.",
+		Type:        "object",
+		Properties: map[string]*Schema{
+			"type":                    {Type: "string", Enum: DefaultRegistry.Types(), Default: TypePrometheus, Description: "The type of the target."},
+			"url":                     {Type: "string", Format: "uri", Pattern: "^https?://", Description: "The URL the series are written to."},
+			"protocol":                {Type: "string", Enum: Protocols, Default: ProtocolRemoteWriteV1, Description: "The protocol of the write requests to a prometheus target."},
+			"basic_auth_username":     {Type: "string"},
+			"basic_auth_password":     {Type: "string", WriteOnly: true},
+			"bearer_token":            {Type: "string", WriteOnly: true},
+			"auth_type":               {Type: "string", Enum: DefaultAuthProviders.Types(), Description: "The type of the authentication of the write requests, basic or bearer when their settings are set."},
+			"auth":                    {Type: "object", AdditionalProperties: &Schema{Type: "string"}, Description: "The parameters of the auth type."},
+			"tls_ca_cert":             {Type: "string"},
+			"tls_client_cert":         {Type: "string"},
+			"tls_client_key":          {Type: "string", WriteOnly: true},
+			"tls_skip_verify":         {Type: "boolean", Default: false},
+			"custom_headers":          {Type: "object", AdditionalProperties: &Schema{Type: "string"}, Description: "The headers sent with the write requests."},
+			"secret_headers":          {Type: "string", Description: "The comma-separated names of the custom headers whose values are secret."},
+			"proxy_url":               {Type: "string", Format: "uri", Pattern: "^(https?|socks5)://", Description: "The proxy of the write requests, instead of the one of the environment."},
+			"no_proxy":                {Type: "string", Description: "The comma-separated hosts that are not written to through the proxy."},
+			"conversion_timeout":      durationSchema("The maximum duration of the conversion of the results of a rule to series, 0 when it is not limited."),
+			"timeout":                 durationSchema("The maximum duration of a write, which must be positive."),
+			"max_idle_conns_per_host": {Type: "integer", Minimum: new(int), Description: "The maximum number of idle connections kept to the target."},
+			"idle_conn_timeout":       durationSchema("How long idle connections are kept."),
+			"max_sample_age":          durationSchema("The maximum age of the samples written, 0 when it is not limited."),
+			"max_label_name_length":   {Type: "integer", Minimum: new(int), Default: 0, Description: "The maximum length of the names of labels, 0 when it is not limited."},
+			"max_label_value_length":  {Type: "integer", Minimum: new(int), Default: 0, Description: "The maximum length of the values of labels, 0 when it is not limited."},
+			"validate_histograms":     {Type: "boolean", Default: false, Description: "Whether the buckets of classic histograms are validated before they are written."},
+			"timestamp_source":        {Type: "string", Enum: TimestampSources, Default: TimestampSourceEvaluation, Description: "The source of the timestamps of the series written."},
+			"graphite_prefix":         {Type: "string", Description: "The prefix of the names of the metrics written to a graphite target."},
+			"graphite_labels":         {Type: "string", Enum: []string{GraphiteLabelsTags, GraphiteLabelsPath}, Default: GraphiteLabelsTags, Description: "Whether labels are written to a graphite target as tags or appended to the names of the metrics."},
+			"graphite_interval":       durationSchema("The interval of the metrics written to a graphite target, 1m when 0."),
+			"graphite_name_rules": {
+				Type:                 "object",
+				AdditionalProperties: &Schema{Type: "string"},
+				Description:          "The replacements of the matches of regular expressions, the keys, in the names of the metrics written to a graphite target, applied in order.",
+			},
+		},
+		DependentRequired: map[string][]string{
+			"basic_auth_password": {"basic_auth_username"},
+			"no_proxy":            {"proxy_url"},
+			"tls_client_cert":     {"tls_client_key"},
+			"tls_client_key":      {"tls_client_cert"},
+		},
+		Required:             []string{"url"},
+		AdditionalProperties: false,
+	}
+
+	DefaultAuthProviders.mtx.RLock()
+	defer DefaultAuthProviders.mtx.RUnlock()
+	for _, typ := range DefaultAuthProviders.types() {
+		provider, ok := DefaultAuthProviders.providers[typ].(AuthSchemaProvider)
+		if !ok {
+			continue
+		}
+		s.AllOf = append(s.AllOf, &Schema{
+			If: &Schema{
+				Properties: map[string]*Schema{"auth_type": {Const: typ}},
+				Required:   []string{"auth_type"},
+			},
+			Then: provider.AuthSchema(),
+		})
+	}
+	return s
+}
+
+func durationSchema(description string) *Schema {
+	return &Schema{Type: "string", Pattern: durationPattern, Description: description}
+}
+
+// authParamsSchema returns the schema of the settings of the targets of an auth type whose auth
+// subsection has params, the required ones being set
+func authParamsSchema(params map[string]*Schema, required []string) *Schema {
+	auth := &Schema{Type: "object", Properties: params, Required: required}
+	s := &Schema{Properties: map[string]*Schema{"auth": auth}}
+	if len(required) > 0 {
+		s.Required = []string{"auth"}
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package writer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestTargetSettingsSchema(t *testing.T) {
+	s := TargetSettingsSchema()
+	require.Equal(t, []string{TypeGraphite, TypePrometheus}, s.Properties["type"].Enum)
+	require.Equal(t, DefaultAuthProviders.Types(), s.Properties["auth_type"].Enum)
+	require.Equal(t, Protocols, s.Properties["protocol"].Enum)
+
+	_, err := json.Marshal(s)
+	require.NoError(t, err)
+
+	authSchema := func(s *Schema, typ string) *Schema {
+		for _, cond := range s.AllOf {
+			if cond.If.Properties["auth_type"].Const == typ {
+				return cond.Then
+			}
+		}
+		return nil
+	}
+	require.Equal(t, []string{"basic_auth_username"}, authSchema(s, AuthTypeBasic).Required)
+	require.Equal(t, []string{"client_id", "client_secret", "token_url"}, authSchema(s, AuthTypeOAuth2).Properties["auth"].Required)
+	require.Equal(t, sigV4AuthTypes, authSchema(s, AuthTypeSigV4).Properties["auth"].Properties["auth"].Enum)
+
+	t.Run("the fields of the problems of settings are in the schema", func(t *testing.T) {
+		targets := []setting.RecordingRuleTargetSettings{
+			{
+				URL:                 "ftp://host",
+				Protocol:            "unknown",
+				TimestampSource:     "unknown",
+				NoProxy:             "localhost",
+				Timeout:             -time.Second,
+				ConversionTimeout:   -time.Second,
+				MaxSampleAge:        -time.Second,
+				MaxLabelNameLength:  -1,
+				MaxLabelValueLength: -1,
+				BasicAuthPassword:   "password",
+				BearerToken:         "token",
+				TLSClientKey:        "key",
+			},
+			{Type: TypeGraphite, GraphiteLabels: "unknown", GraphiteInterval: -time.Second, GraphiteNameRules: []setting.GraphiteNameRule{{Match: "("}}, TLSClientCert: "cert"},
+			{URL: "http://host", ProxyURL: "ftp://proxy", Timeout: time.Second, AuthType: "unknown"},
+			{URL: "http://host", Timeout: time.Second, AuthType: AuthTypeOAuth2},
+			{URL: "http://host", Timeout: time.Second, AuthType: AuthTypeSigV4, AuthParams: map[string]string{"auth": "keys"}},
+			{URL: "http://host", Timeout: time.Second, AuthType: AuthTypeAzure, AuthParams: map[string]string{"client_secret": "secret"}},
+		}
+		for _, target := range targets {
+			errs := &SettingsError{}
+			validateTarget(errs, "", target)
+			require.NotEmpty(t, errs.Errors)
+			for _, err := range errs.Errors {
+				param, ok := strings.CutPrefix(err.Field, "auth.")
+				if !ok {
+					require.Contains(t, s.Properties, err.Field)
+					continue
+				}
+				require.Contains(t, authSchema(s, target.AuthType).Properties["auth"].Properties, param)
+			}
+		}
+	})
+
+	t.Run("the auth providers describing their settings are in the schema", func(t *testing.T) {
+		providers := DefaultAuthProviders
+		t.Cleanup(func() { DefaultAuthProviders = providers })
+		DefaultAuthProviders = NewAuthProviders()
+		require.NoError(t, DefaultAuthProviders.Register("header", headerAuth{}))
+
+		s := TargetSettingsSchema()
+		require.Contains(t, s.Properties["auth_type"].Enum, "header")
+		require.Nil(t, authSchema(s, "header"))
+	})
+}
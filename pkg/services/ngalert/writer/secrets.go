@@ -0,0 +1,94 @@
+package writer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// DecryptFn decrypts a value encrypted with the secrets service of Grafana.
+type DecryptFn func(ctx context.Context, payload []byte) ([]byte, error)
+
+// encryptedValue matches the values of settings encrypted with the secrets service,
+// written as $__encrypted{<base64 encrypted value>}
+var encryptedValue = regexp.MustCompile(`^\$__encrypted\{([^}]*)\}$`)
+
+// ResolveSecrets returns settings with the encrypted credentials, custom headers and auth parameters
+// of their targets decrypted by decrypt.
+// Values that are not encrypted are returned as they are.
+func ResolveSecrets(ctx context.Context, settings setting.RecordingRuleSettings, decrypt DecryptFn) (setting.RecordingRuleSettings, error) {
+	var err error
+	if settings.RecordingRuleTargetSettings, err = resolveTargetSecrets(ctx, settings.RecordingRuleTargetSettings, decrypt); err != nil {
+		return settings, err
+	}
+	targets := make([]setting.RecordingRuleTargetSettings, 0, len(settings.Targets))
+	for _, target := range settings.Targets {
+		target, err := resolveTargetSecrets(ctx, target, decrypt)
+		if err != nil {
+			return settings, err
+		}
+		targets = append(targets, target)
+	}
+	settings.Targets = targets
+	return settings, nil
+}
+
+func resolveTargetSecrets(ctx context.Context, target setting.RecordingRuleTargetSettings, decrypt DecryptFn) (setting.RecordingRuleTargetSettings, error) {
+	section := "recording_rules"
+	if target.Name != "" {
+		section = "recording_rules.target." + target.Name
+	}
+	secrets := map[string]*string{
+		"basic_auth_password": &target.BasicAuthPassword,
+		"bearer_token":        &target.BearerToken,
+		"tls_client_key":      &target.TLSClientKey,
+	}
+	for key, value := range secrets {
+		decrypted, err := decryptValue(ctx, *value, decrypt)
+		if err != nil {
+			return target, fmt.Errorf("failed to decrypt %s %s: %w", section, key, err)
+		}
+		*value = decrypted
+	}
+
+	// Copied, so the headers of the settings of Grafana are left encrypted
+	headers := make(map[string]string, len(target.CustomHeaders))
+	for name, value := range target.CustomHeaders {
+		decrypted, err := decryptValue(ctx, value, decrypt)
+		if err != nil {
+			return target, fmt.Errorf("failed to decrypt %s custom header %s: %w", section, name, err)
+		}
+		headers[name] = decrypted
+	}
+	target.CustomHeaders = headers
+
+	params := make(map[string]string, len(target.AuthParams))
+	for name, value := range target.AuthParams {
+		decrypted, err := decryptValue(ctx, value, decrypt)
+		if err != nil {
+			return target, fmt.Errorf("failed to decrypt %s auth parameter %s: %w", section, name, err)
+		}
+		params[name] = decrypted
+	}
+	target.AuthParams = params
+	return target, nil
+}
+
+func decryptValue(ctx context.Context, value string, decrypt DecryptFn) (string, error) {
+	m := encryptedValue.FindStringSubmatch(value)
+	if m == nil {
+		return value, nil
+	}
+	payload, err := base64.StdEncoding.DecodeString(m[1])
+	if err != nil {
+		return "", err
+	}
+	decrypted, err := decrypt(ctx, payload)
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
@@ -0,0 +1,93 @@
+package writer
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	decrypt := func(_ context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "bad" {
+			return nil, errors.New("decryption failed")
+		}
+		return []byte("decrypted-" + string(payload)), nil
+	}
+	encrypted := func(v string) string {
+		return "$__encrypted{" + base64.StdEncoding.EncodeToString([]byte(v)) + "}"
+	}
+
+	t.Run("decrypts encrypted credentials", func(t *testing.T) {
+		settings, err := ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{
+				BasicAuthUsername: "user",
+				BasicAuthPassword: encrypted("password"),
+				BearerToken:       encrypted("token"),
+				TLSClientKey:      encrypted("key"),
+			},
+			Targets: []setting.RecordingRuleTargetSettings{{Name: "mimir", BearerToken: encrypted("mimir-token")}},
+		}, decrypt)
+		require.NoError(t, err)
+		require.Equal(t, "user", settings.BasicAuthUsername)
+		require.Equal(t, "decrypted-password", settings.BasicAuthPassword)
+		require.Equal(t, "decrypted-token", settings.BearerToken)
+		require.Equal(t, "decrypted-key", settings.TLSClientKey)
+		require.Equal(t, "decrypted-mimir-token", settings.Targets[0].BearerToken)
+	})
+
+	t.Run("decrypts encrypted custom headers", func(t *testing.T) {
+		headers := map[string]string{"X-Api-Key": encrypted("key"), "X-Scope-OrgID": "tenant"}
+		settings, err := ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{CustomHeaders: headers},
+		}, decrypt)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"X-Api-Key": "decrypted-key", "X-Scope-OrgID": "tenant"}, settings.CustomHeaders)
+		// The headers of the settings are left as they are
+		require.Equal(t, encrypted("key"), headers["X-Api-Key"])
+
+		_, err = ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			Targets: []setting.RecordingRuleTargetSettings{{Name: "mimir", CustomHeaders: map[string]string{"X-Api-Key": encrypted("bad")}}},
+		}, decrypt)
+		require.ErrorContains(t, err, "recording_rules.target.mimir custom header X-Api-Key")
+	})
+
+	t.Run("decrypts encrypted auth parameters", func(t *testing.T) {
+		settings, err := ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{
+				AuthParams: map[string]string{"client_id": "grafana", "client_secret": encrypted("secret")},
+			},
+		}, decrypt)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"client_id": "grafana", "client_secret": "decrypted-secret"}, settings.AuthParams)
+
+		_, err = ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			Targets: []setting.RecordingRuleTargetSettings{{Name: "mimir", AuthParams: map[string]string{"secret_key": encrypted("bad")}}},
+		}, decrypt)
+		require.ErrorContains(t, err, "recording_rules.target.mimir auth parameter secret_key")
+	})
+
+	t.Run("plain values are kept", func(t *testing.T) {
+		settings, err := ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{BasicAuthPassword: "password"},
+		}, decrypt)
+		require.NoError(t, err)
+		require.Equal(t, "password", settings.BasicAuthPassword)
+	})
+
+	t.Run("errors are returned", func(t *testing.T) {
+		_, err := ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			Targets: []setting.RecordingRuleTargetSettings{{Name: "mimir", BearerToken: encrypted("bad")}},
+		}, decrypt)
+		require.ErrorContains(t, err, "recording_rules.target.mimir bearer_token")
+
+		_, err = ResolveSecrets(context.Background(), setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{BearerToken: "$__encrypted{not base64}"},
+		}, decrypt)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,159 @@
+package writer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// The metrics written by SelfMonitor
+const (
+	SelfMonitoringWritesMetric   = "grafana_recording_rule_writes_total"
+	SelfMonitoringFailuresMetric = "grafana_recording_rule_write_failures_total"
+	SelfMonitoringDurationMetric = "grafana_recording_rule_write_duration_seconds_total"
+)
+
+// targetWriter writes the results of recording rules to one of the targets, like TargetsWriter
+type targetWriter interface {
+	Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
+}
+
+// SelfMonitor counts the writes of recording rules, their failures by kind and their duration, by
+// target, and writes the counters to the default target periodically. Operators can then alert on
+// the health of recording rules where their results are written, even when Grafana is not scraped.
+type SelfMonitor struct {
+	next     targetWriter
+	interval time.Duration
+	// The labels of all the series of the counters, identifying the instance of Grafana
+	labels map[string]string
+	logger log.Logger
+
+	mtx       sync.Mutex
+	writes    map[string]float64
+	failures  map[selfMonitorFailure]float64
+	durations map[string]float64
+}
+
+type selfMonitorFailure struct {
+	target string
+	kind   WriteFailure
+}
+
+// NewSelfMonitor returns a SelfMonitor of the writes of next, writing its counters every interval
+// once it runs, with labels
+func NewSelfMonitor(next targetWriter, interval time.Duration, labels map[string]string, l log.Logger) *SelfMonitor {
+	return &SelfMonitor{
+		next:      next,
+		interval:  interval,
+		labels:    labels,
+		logger:    l,
+		writes:    make(map[string]float64),
+		failures:  make(map[selfMonitorFailure]float64),
+		durations: make(map[string]float64),
+	}
+}
+
+// Write writes the given frames to target with the monitored writer, and counts the write
+func (m *SelfMonitor) Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	start := time.Now()
+	err := m.next.Write(ctx, target, name, t, frames, extraLabels)
+	m.record(target, time.Since(start), err)
+	return err
+}
+
+func (m *SelfMonitor) record(target string, duration time.Duration, err error) {
+	if target == "" {
+		target = defaultTargetName
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.writes[target]++
+	m.durations[target] += duration.Seconds()
+	if err != nil {
+		m.failures[selfMonitorFailure{target: target, kind: writeFailure(err, nil)}]++
+	}
+}
+
+// Run writes the counters every interval until ctx is done. Its own writes are not counted.
+func (m *SelfMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			m.flush(ctx, t)
+		}
+	}
+}
+
+// flush writes the counters at t, each metric with its own write
+func (m *SelfMonitor) flush(ctx context.Context, t time.Time) {
+	for _, metric := range m.frames(t) {
+		writeCtx, cancel := context.WithTimeout(ctx, m.interval)
+		if err := m.next.Write(writeCtx, "", metric.name, t, metric.frames, m.labels); err != nil {
+			m.logger.Warn("Failed to write the self-monitoring metrics of recording rules", "metric", metric.name, "error", err)
+		}
+		cancel()
+	}
+}
+
+type selfMonitorMetric struct {
+	name   string
+	frames data.Frames
+}
+
+// frames returns the frames of the metrics that have series, a numeric multi frame for each series
+func (m *SelfMonitor) frames(t time.Time) []selfMonitorMetric {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	byTarget := func(counters map[string]float64) []selfMonitorSeries {
+		series := make([]selfMonitorSeries, 0, len(counters))
+		for target, v := range counters {
+			series = append(series, selfMonitorSeries{labels: data.Labels{"target": target}, value: v})
+		}
+		return series
+	}
+	failures := make([]selfMonitorSeries, 0, len(m.failures))
+	for f, v := range m.failures {
+		failures = append(failures, selfMonitorSeries{labels: data.Labels{"target": f.target, "kind": string(f.kind)}, value: v})
+	}
+
+	var metrics []selfMonitorMetric
+	for _, metric := range []struct {
+		name   string
+		series []selfMonitorSeries
+	}{
+		{SelfMonitoringWritesMetric, byTarget(m.writes)},
+		{SelfMonitoringFailuresMetric, failures},
+		{SelfMonitoringDurationMetric, byTarget(m.durations)},
+	} {
+		if len(metric.series) == 0 {
+			continue
+		}
+		sort.Slice(metric.series, func(i, j int) bool { return metric.series[i].labels.String() < metric.series[j].labels.String() })
+		frames := make(data.Frames, 0, len(metric.series))
+		for _, s := range metric.series {
+			frame := data.NewFrame(metric.name,
+				data.NewField("T", nil, []time.Time{t}),
+				data.NewField("value", s.labels, []float64{s.value}),
+			)
+			frame.SetMeta(&data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: data.FrameTypeVersion{0, 1}})
+			frames = append(frames, frame)
+		}
+		metrics = append(metrics, selfMonitorMetric{name: metric.name, frames: frames})
+	}
+	return metrics
+}
+
+type selfMonitorSeries struct {
+	labels data.Labels
+	value  float64
+}
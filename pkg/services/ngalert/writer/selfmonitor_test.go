@@ -0,0 +1,72 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+type targetWriteFunc func(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error
+
+func (f targetWriteFunc) Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	return f(ctx, target, name, t, frames, extraLabels)
+}
+
+func TestSelfMonitor(t *testing.T) {
+	written := map[string][]Point{}
+	var targets []string
+	monitor := NewSelfMonitor(targetWriteFunc(func(ctx context.Context, target, name string, ts time.Time, frames data.Frames, extraLabels map[string]string) error {
+		if name == "rule_metric" {
+			if target == "mimir" {
+				return writeErr(429)
+			}
+			return nil
+		}
+		targets = append(targets, target)
+		points, err := PointsFromFrames(ctx, name, ts, "", frames, extraLabels)
+		require.NoError(t, err)
+		written[name] = points
+		return nil
+	}), time.Minute, map[string]string{"instance": "grafana-1"}, log.NewNopLogger())
+
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"foo": "1"}})
+	require.NoError(t, monitor.Write(context.Background(), "", "rule_metric", time.Now(), frames, nil))
+	require.NoError(t, monitor.Write(context.Background(), "", "rule_metric", time.Now(), frames, nil))
+	require.Error(t, monitor.Write(context.Background(), "mimir", "rule_metric", time.Now(), frames, nil))
+
+	now := time.Now()
+	monitor.flush(context.Background(), now)
+
+	// The counters are written to the default target, and not counted
+	require.Equal(t, []string{"", "", ""}, targets)
+	value := func(name string, labels map[string]string) float64 {
+		t.Helper()
+		for _, p := range written[name] {
+			if p.Labels["target"] == labels["target"] && p.Labels["kind"] == labels["kind"] {
+				require.Equal(t, "grafana-1", p.Labels["instance"])
+				require.Equal(t, now.Unix(), p.Metric.T)
+				return p.Metric.V
+			}
+		}
+		t.Fatalf("no %s series with %v", name, labels)
+		return 0
+	}
+	require.Equal(t, 2.0, value(SelfMonitoringWritesMetric, map[string]string{"target": "default"}))
+	require.Equal(t, 1.0, value(SelfMonitoringWritesMetric, map[string]string{"target": "mimir"}))
+	require.Len(t, written[SelfMonitoringFailuresMetric], 1)
+	require.Equal(t, 1.0, value(SelfMonitoringFailuresMetric, map[string]string{"target": "mimir", "kind": "rate_limit"}))
+	require.Len(t, written[SelfMonitoringDurationMetric], 2)
+
+	t.Run("metrics without series are not written", func(t *testing.T) {
+		monitor := NewSelfMonitor(targetWriteFunc(func(context.Context, string, string, time.Time, data.Frames, map[string]string) error {
+			return errors.New("should not be written")
+		}), time.Minute, nil, log.NewNopLogger())
+		require.Empty(t, monitor.frames(time.Now()))
+	})
+}
@@ -0,0 +1,155 @@
+package writer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// SettingError is a problem of a writer setting, named after its key in the [recording_rules] section.
+type SettingError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// SettingsError is returned for invalid writer settings, with all of their problems.
+type SettingsError struct {
+	Errors []SettingError `json:"errors"`
+}
+
+func (e *SettingsError) Error() string {
+	problems := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		problems = append(problems, err.Field+": "+err.Message)
+	}
+	return "invalid recording rules settings: " + strings.Join(problems, "; ")
+}
+
+func (e *SettingsError) add(field, format string, args ...any) {
+	e.Errors = append(e.Errors, SettingError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// validateSettings returns a *SettingsError with all the problems of settings, or nil when they are valid.
+// The default target is optional when there are named ones.
+func validateSettings(settings setting.RecordingRuleSettings) error {
+	errs := &SettingsError{}
+	if settings.URL != "" || len(settings.Targets) == 0 {
+		validateTarget(errs, "", settings.RecordingRuleTargetSettings)
+	}
+	for _, target := range settings.Targets {
+		validateTarget(errs, "target."+target.Name+".", target)
+	}
+	if settings.AlertStatesTarget != "" && !slices.ContainsFunc(settings.Targets, func(t setting.RecordingRuleTargetSettings) bool {
+		return t.Name == settings.AlertStatesTarget
+	}) {
+		errs.add("alert_states_target", "must be the name of a target, got %q", settings.AlertStatesTarget)
+	}
+	return errs.orNil()
+}
+
+// validateTargetSettings returns a *SettingsError with all the problems of the settings of a target,
+// or nil when they are valid
+func validateTargetSettings(target setting.RecordingRuleTargetSettings) error {
+	errs := &SettingsError{}
+	validateTarget(errs, "", target)
+	return errs.orNil()
+}
+
+// validateTarget adds the problems of the settings of a target to errs, with fields prefixed by prefix
+func validateTarget(errs *SettingsError, prefix string, target setting.RecordingRuleTargetSettings) {
+	if target.URL == "" {
+		errs.add(prefix+"url", "is required")
+	} else if u, err := url.Parse(target.URL); err != nil {
+		errs.add(prefix+"url", "is not a valid URL: %s", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		errs.add(prefix+"url", "must be an http or https URL, got scheme %q", u.Scheme)
+	} else if u.Host == "" {
+		errs.add(prefix+"url", "has no host")
+	}
+
+	if target.Protocol != "" && !slices.Contains(Protocols, target.Protocol) {
+		errs.add(prefix+"protocol", "must be one of %s, got %q", strings.Join(Protocols, ", "), target.Protocol)
+	}
+
+	if targetType(target) == TypeGraphite {
+		validateGraphite(errs, prefix, target)
+	}
+	if target.TimestampSource != "" && !slices.Contains(TimestampSources, target.TimestampSource) {
+		errs.add(prefix+"timestamp_source", "must be one of %s, got %q", strings.Join(TimestampSources, ", "), target.TimestampSource)
+	}
+
+	if target.ProxyURL != "" {
+		if u, err := url.Parse(target.ProxyURL); err != nil {
+			errs.add(prefix+"proxy_url", "is not a valid URL: %s", err)
+		} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" {
+			errs.add(prefix+"proxy_url", "must be an http, https or socks5 URL, got scheme %q", u.Scheme)
+		} else if u.Host == "" {
+			errs.add(prefix+"proxy_url", "has no host")
+		}
+	} else if target.NoProxy != "" {
+		errs.add(prefix+"proxy_url", "is required with no_proxy")
+	}
+
+	if target.Timeout <= 0 {
+		errs.add(prefix+"timeout", "must be positive, got %s", target.Timeout)
+	}
+	if target.ConversionTimeout < 0 {
+		errs.add(prefix+"conversion_timeout", "must not be negative, got %s", target.ConversionTimeout)
+	}
+	if target.MaxSampleAge < 0 {
+		errs.add(prefix+"max_sample_age", "must not be negative, got %s", target.MaxSampleAge)
+	}
+	if target.MaxLabelNameLength < 0 {
+		errs.add(prefix+"max_label_name_length", "must not be negative, got %d", target.MaxLabelNameLength)
+	}
+	if target.MaxLabelValueLength < 0 {
+		errs.add(prefix+"max_label_value_length", "must not be negative, got %d", target.MaxLabelValueLength)
+	}
+
+	if target.BasicAuthPassword != "" && target.BasicAuthUsername == "" {
+		errs.add(prefix+"basic_auth_username", "is required with basic_auth_password")
+	}
+	if target.BearerToken != "" && (target.BasicAuthUsername != "" || target.BasicAuthPassword != "") {
+		errs.add(prefix+"bearer_token", "cannot be used with basic authentication")
+	}
+	if provider, err := DefaultAuthProviders.provider(target); err != nil {
+		errs.add(prefix+"auth_type", "%s", err)
+	} else if provider != nil && target.AuthType != "" {
+		for _, err := range provider.Validate(target) {
+			errs.add(prefix+err.Field, "%s", err.Message)
+		}
+	}
+	if (target.TLSClientCert == "") != (target.TLSClientKey == "") {
+		if target.TLSClientCert == "" {
+			errs.add(prefix+"tls_client_cert", "is required with tls_client_key")
+		} else {
+			errs.add(prefix+"tls_client_key", "is required with tls_client_cert")
+		}
+	}
+}
+
+// validateGraphite adds the problems of the settings of a graphite target to errs
+func validateGraphite(errs *SettingsError, prefix string, target setting.RecordingRuleTargetSettings) {
+	if target.GraphiteLabels != "" && target.GraphiteLabels != GraphiteLabelsTags && target.GraphiteLabels != GraphiteLabelsPath {
+		errs.add(prefix+"graphite_labels", "must be one of %s or %s, got %q", GraphiteLabelsTags, GraphiteLabelsPath, target.GraphiteLabels)
+	}
+	if target.GraphiteInterval < 0 {
+		errs.add(prefix+"graphite_interval", "must not be negative, got %s", target.GraphiteInterval)
+	}
+	for _, rule := range target.GraphiteNameRules {
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			errs.add(prefix+"graphite_name_rules", "%q is not a valid regular expression: %s", rule.Match, err)
+		}
+	}
+}
+
+func (e *SettingsError) orNil() error {
+	if len(e.Errors) > 0 {
+		return e
+	}
+	return nil
+}
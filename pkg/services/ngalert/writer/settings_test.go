@@ -0,0 +1,179 @@
+package writer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestValidateSettings(t *testing.T) {
+	valid := setting.RecordingRuleSettings{
+		RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{
+			URL:     "https://prometheus.example.com/api/v1/write",
+			Timeout: 10 * time.Second,
+		},
+	}
+
+	t.Run("valid settings", func(t *testing.T) {
+		require.NoError(t, validateSettings(valid))
+
+		settings := valid
+		settings.BasicAuthUsername, settings.BasicAuthPassword = "user", "password"
+		settings.TLSClientCert, settings.TLSClientKey = "cert", "key"
+		settings.ProxyURL, settings.NoProxy = "http://proxy:3128", "localhost,.internal"
+		require.NoError(t, validateSettings(settings))
+
+		// The default target is optional with named ones
+		settings = setting.RecordingRuleSettings{
+			Targets: []setting.RecordingRuleTargetSettings{{Name: "mimir", URL: "http://mimir/api/v1/push", Timeout: time.Second}},
+		}
+		require.NoError(t, validateSettings(settings))
+	})
+
+	testCases := []struct {
+		name     string
+		mutate   func(*setting.RecordingRuleSettings)
+		expected []SettingError
+	}{
+		{
+			name:     "missing url",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.URL = "" },
+			expected: []SettingError{{Field: "url", Message: "is required"}},
+		},
+		{
+			name:     "url without scheme",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.URL = "prometheus:9090/api/v1/write" },
+			expected: []SettingError{{Field: "url", Message: `must be an http or https URL, got scheme "prometheus"`}},
+		},
+		{
+			name:     "url without host",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.URL = "http:///api/v1/write" },
+			expected: []SettingError{{Field: "url", Message: "has no host"}},
+		},
+		{
+			name:     "password without username",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.BasicAuthPassword = "password" },
+			expected: []SettingError{{Field: "basic_auth_username", Message: "is required with basic_auth_password"}},
+		},
+		{
+			name:     "proxy url with unsupported scheme",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.ProxyURL = "ftp://proxy:3128" },
+			expected: []SettingError{{Field: "proxy_url", Message: `must be an http, https or socks5 URL, got scheme "ftp"`}},
+		},
+		{
+			name:     "no proxy without proxy url",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.NoProxy = "localhost" },
+			expected: []SettingError{{Field: "proxy_url", Message: "is required with no_proxy"}},
+		},
+		{
+			name:     "negative max sample age",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.MaxSampleAge = -time.Minute },
+			expected: []SettingError{{Field: "max_sample_age", Message: "must not be negative, got -1m0s"}},
+		},
+		{
+			name:     "negative max label value length",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.MaxLabelValueLength = -1 },
+			expected: []SettingError{{Field: "max_label_value_length", Message: "must not be negative, got -1"}},
+		},
+		{
+			name:     "unknown protocol",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.Protocol = "graphite" },
+			expected: []SettingError{{Field: "protocol", Message: `must be one of remote_write_v1, remote_write_v2, otlp, auto, got "graphite"`}},
+		},
+		{
+			name:     "unknown timestamp source",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.TimestampSource = "query" },
+			expected: []SettingError{{Field: "timestamp_source", Message: `must be one of evaluation, frame, got "query"`}},
+		},
+		{
+			name: "invalid graphite settings",
+			mutate: func(s *setting.RecordingRuleSettings) {
+				s.Type = TypeGraphite
+				s.GraphiteLabels = "nodes"
+				s.GraphiteInterval = -time.Second
+				s.GraphiteNameRules = []setting.GraphiteNameRule{{Match: "(a"}}
+			},
+			expected: []SettingError{
+				{Field: "graphite_labels", Message: `must be one of tags or path, got "nodes"`},
+				{Field: "graphite_interval", Message: "must not be negative, got -1s"},
+				{Field: "graphite_name_rules", Message: "\"(a\" is not a valid regular expression: error parsing regexp: missing closing ): `(a`"},
+			},
+		},
+		{
+			name:     "unknown alert states target",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.AlertStatesTarget = "mimir" },
+			expected: []SettingError{{Field: "alert_states_target", Message: `must be the name of a target, got "mimir"`}},
+		},
+		{
+			name:     "unknown auth type",
+			mutate:   func(s *setting.RecordingRuleSettings) { s.AuthType = "kerberos" },
+			expected: []SettingError{{Field: "auth_type", Message: `unknown auth type "kerberos", must be one of [azure basic bearer oauth2 sigv4]`}},
+		},
+		{
+			name: "auth parameters missing",
+			mutate: func(s *setting.RecordingRuleSettings) {
+				s.AuthType = AuthTypeOAuth2
+				s.AuthParams = map[string]string{"client_id": "grafana"}
+			},
+			expected: []SettingError{
+				{Field: "auth.client_secret", Message: "is required with oauth2 authentication"},
+				{Field: "auth.token_url", Message: "is required with oauth2 authentication"},
+			},
+		},
+		{
+			name: "client key without certificate",
+			mutate: func(s *setting.RecordingRuleSettings) {
+				s.TLSClientKey = "key"
+			},
+			expected: []SettingError{{Field: "tls_client_cert", Message: "is required with tls_client_key"}},
+		},
+		{
+			name: "all problems at once",
+			mutate: func(s *setting.RecordingRuleSettings) {
+				s.URL = "ftp://prometheus"
+				s.Timeout = 0
+				s.BasicAuthUsername = "user"
+				s.BearerToken = "token"
+				s.TLSClientCert = "cert"
+			},
+			expected: []SettingError{
+				{Field: "url", Message: `must be an http or https URL, got scheme "ftp"`},
+				{Field: "timeout", Message: "must be positive, got 0s"},
+				{Field: "bearer_token", Message: "cannot be used with basic authentication"},
+				{Field: "tls_client_key", Message: "is required with tls_client_cert"},
+			},
+		},
+		{
+			name: "named targets",
+			mutate: func(s *setting.RecordingRuleSettings) {
+				s.Targets = []setting.RecordingRuleTargetSettings{
+					{Name: "mimir", URL: "http://mimir/api/v1/push", Timeout: time.Second},
+					{Name: "other", URL: "other", Timeout: time.Second},
+				}
+			},
+			expected: []SettingError{{Field: "target.other.url", Message: `must be an http or https URL, got scheme ""`}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := valid
+			tc.mutate(&settings)
+			err := validateSettings(settings)
+
+			var settingsErr *SettingsError
+			require.True(t, errors.As(err, &settingsErr))
+			require.Equal(t, tc.expected, settingsErr.Errors)
+		})
+	}
+
+	t.Run("writer is not created with invalid settings", func(t *testing.T) {
+		settings := valid
+		settings.Timeout = -time.Second
+		_, err := NewTargetsWriter(settings, nil, nil, nil)
+		require.EqualError(t, err, "invalid recording rules settings: timeout: must be positive, got -1s")
+	})
+}
@@ -0,0 +1,114 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/m3db/prometheus_remote_client_golang/promremote"
+)
+
+// WriteFailure is the kind of error a write failed with, logged with it.
+type WriteFailure string
+
+const (
+	WriteFailureConversion WriteFailure = "conversion"
+	WriteFailurePartial    WriteFailure = "partial"
+	WriteFailureTooOld     WriteFailure = "too_old"
+	WriteFailureTimeout    WriteFailure = "timeout"
+	WriteFailureCanceled   WriteFailure = "canceled"
+	WriteFailureAuth       WriteFailure = "auth"
+	WriteFailureRateLimit  WriteFailure = "rate_limit"
+	WriteFailureRejected   WriteFailure = "rejected"
+	WriteFailureTarget     WriteFailure = "target"
+)
+
+// writeStats are the statistics of a write, logged when it is done
+type writeStats struct {
+	series  int
+	batches int
+	// the series of the batches that were written
+	writtenSeries int
+	// the series that were not written as their labels are longer than the limits of the target, or
+	// their value cannot be stored by it
+	droppedSeries int
+	// the buckets of the histograms that were not written as they are not valid, see dropInvalidHistograms
+	invalidSeries int
+	// the series that were not written as their sample was already, see WrittenSamples
+	duplicateSeries int
+	// the series that were not written as their sample is older than the maximum sample age, only the
+	// samples stamped with the time of their frame can be
+	oldSeries int
+	// the series of the batches rejected by the target because of the labels of some of their series,
+	// the others may have been written
+	rejectedSeries int
+	requests       int
+	// the size of the encoded requests that were sent
+	bytes int64
+	// the status of the last response, zero when there was none
+	statusCode int
+	// the error of the last request that could not be sent, which the remote write client does not wrap
+	transportErr error
+}
+
+type writeStatsKey struct{}
+
+func withWriteStats(ctx context.Context, stats *writeStats) context.Context {
+	return context.WithValue(ctx, writeStatsKey{}, stats)
+}
+
+// statsMiddleware counts the requests sent with write stats in their context, their size and the
+// status of their responses
+func statsMiddleware() sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("recording-rules-write-stats", func(_ sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			res, err := next.RoundTrip(req)
+			if stats, ok := req.Context().Value(writeStatsKey{}).(*writeStats); ok {
+				stats.requests++
+				stats.bytes += req.ContentLength
+				if res != nil {
+					stats.statusCode = res.StatusCode
+				}
+				stats.transportErr = err
+			}
+			return res, err
+		})
+	})
+}
+
+// writeFailure returns the kind of error of a failed write, and of the last request that could
+// not be sent, if any
+func writeFailure(err, transportErr error) WriteFailure {
+	var partial *PartialWriteError
+	switch {
+	case errors.As(err, &partial):
+		return WriteFailurePartial
+	case errors.Is(err, context.DeadlineExceeded):
+		return WriteFailureTimeout
+	case errors.Is(err, context.Canceled):
+		return WriteFailureCanceled
+	case errors.Is(err, ErrSampleTooOld):
+		return WriteFailureTooOld
+	}
+
+	var writeErr promremote.WriteError
+	if !errors.As(err, &writeErr) {
+		return WriteFailureConversion
+	}
+	switch code := writeErr.StatusCode(); {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return WriteFailureAuth
+	case code == http.StatusTooManyRequests:
+		return WriteFailureRateLimit
+	case code >= http.StatusInternalServerError:
+		return WriteFailureTarget
+	case code >= http.StatusBadRequest:
+		return WriteFailureRejected
+	}
+	// The request could not be sent, or no response was received
+	if transportErr != nil {
+		return WriteFailure(probeFailure(transportErr))
+	}
+	return WriteFailure(probeFailure(err))
+}
@@ -0,0 +1,65 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestWriteStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	writer, err := NewPrometheusWriter(setting.RecordingRuleTargetSettings{URL: server.URL, Timeout: time.Second}, nil, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	stats := &writeStats{}
+	frames := frameGenFromLabels(t, data.FrameTypeNumericMulti, []map[string]string{{"foo": "1"}, {"foo": "2"}})
+	require.NoError(t, writer.write(withWriteStats(context.Background(), stats), "test_metric", time.Now(), frames, nil, stats))
+	require.Equal(t, 2, stats.series)
+	require.Equal(t, 1, stats.batches)
+	require.Equal(t, 1, stats.requests)
+	require.Positive(t, stats.bytes)
+	require.Equal(t, http.StatusNoContent, stats.statusCode)
+}
+
+func TestWriteFailure(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected WriteFailure
+	}{
+		{err: fmt.Errorf("aborted: %w", context.DeadlineExceeded), expected: WriteFailureTimeout},
+		{err: context.Canceled, expected: WriteFailureCanceled},
+		{err: writeErr(http.StatusUnauthorized), expected: WriteFailureAuth},
+		{err: writeErr(http.StatusTooManyRequests), expected: WriteFailureRateLimit},
+		{err: fmt.Errorf("failed: %w", writeErr(http.StatusBadRequest)), expected: WriteFailureRejected},
+		{err: writeErr(http.StatusBadGateway), expected: WriteFailureTarget},
+		{err: errors.New("unable to get metric value"), expected: WriteFailureConversion},
+		{err: fmt.Errorf("%w: too old", ErrSampleTooOld), expected: WriteFailureTooOld},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.expected), func(t *testing.T) {
+			require.Equal(t, tc.expected, writeFailure(tc.err, nil))
+		})
+	}
+
+	t.Run("errors sending requests", func(t *testing.T) {
+		transportErr := &url.Error{Op: "Post", URL: "http://mimir", Err: &net.DNSError{Err: "no such host", Name: "mimir"}}
+		require.Equal(t, WriteFailure(ProbeFailureDNS), writeFailure(writeErr(0), transportErr))
+		require.Equal(t, WriteFailure(ProbeFailureConnection), writeFailure(writeErr(0), nil))
+	})
+}
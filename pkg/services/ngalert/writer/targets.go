@@ -0,0 +1,89 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/ngalert/metrics"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// TargetsWriter writes the points of recording rules to the target they reference by name,
+// or to the default one.
+type TargetsWriter struct {
+	// nil when there is no default target
+	defaultWriter Writer
+	writers       map[string]Writer
+}
+
+// NewTargetsWriter returns a writer for the default and named targets of settings, whose
+// credentials must have been decrypted, see ResolveSecrets. The writers of the targets are
+// created by the factories of their type in DefaultRegistry.
+func NewTargetsWriter(settings setting.RecordingRuleSettings, features featuremgmt.FeatureToggles, m *metrics.RemoteWriter, l log.Logger) (*TargetsWriter, error) {
+	return newTargetsWriter(DefaultRegistry, settings, features, m, l)
+}
+
+func newTargetsWriter(registry *Registry, settings setting.RecordingRuleSettings, features featuremgmt.FeatureToggles, m *metrics.RemoteWriter, l log.Logger) (*TargetsWriter, error) {
+	if err := validateSettings(settings); err != nil {
+		return nil, err
+	}
+
+	w := &TargetsWriter{writers: make(map[string]Writer, len(settings.Targets))}
+	if settings.URL != "" {
+		defaultWriter, err := registry.New(settings.RecordingRuleTargetSettings, features, m, l)
+		if err != nil {
+			return nil, err
+		}
+		w.defaultWriter = defaultWriter
+	}
+	for _, target := range settings.Targets {
+		writer, err := registry.New(target, features, m, l)
+		if err != nil {
+			return nil, fmt.Errorf("recording rules target %s: %w", target.Name, err)
+		}
+		w.writers[target.Name] = writer
+	}
+	return w, nil
+}
+
+// Write writes the given frames to target, the default one when it is empty. Only the frames
+// and fields selected by the FrameFilter of ctx are written.
+func (w *TargetsWriter) Write(ctx context.Context, target, name string, t time.Time, frames data.Frames, extraLabels map[string]string) error {
+	writer, err := w.writer(target)
+	if err != nil {
+		return err
+	}
+	return writer.Write(ctx, name, t, frameFilterFromContext(ctx).Apply(frames), extraLabels)
+}
+
+// Probe probes all the targets, see Writer.Probe. It returns the errors by target name,
+// the default one being empty.
+func (w *TargetsWriter) Probe(ctx context.Context) map[string]error {
+	errs := make(map[string]error, len(w.writers)+1)
+	if w.defaultWriter != nil {
+		errs[""] = w.defaultWriter.Probe(ctx)
+	}
+	for name, writer := range w.writers {
+		errs[name] = writer.Probe(ctx)
+	}
+	return errs
+}
+
+func (w *TargetsWriter) writer(target string) (Writer, error) {
+	if target == "" {
+		if w.defaultWriter == nil {
+			return nil, fmt.Errorf("no default recording rules target is configured")
+		}
+		return w.defaultWriter, nil
+	}
+	writer, ok := w.writers[target]
+	if !ok {
+		return nil, fmt.Errorf("recording rules target %q is not configured", target)
+	}
+	return writer, nil
+}
@@ -0,0 +1,55 @@
+package writer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestTargetsWriter_Write(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	frames := frameGenFromLabels(t, data.FrameTypeNumericWide, []map[string]string{{"foo": "1"}})
+	now := time.Now()
+
+	t.Run("writes to the target of the rule", func(t *testing.T) {
+		requests = nil
+		writer, err := NewTargetsWriter(setting.RecordingRuleSettings{
+			RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{URL: server.URL + "/default", Timeout: time.Second},
+			Targets: []setting.RecordingRuleTargetSettings{
+				{Name: "mimir", URL: server.URL + "/mimir", Timeout: time.Second},
+			},
+		}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+
+		require.NoError(t, writer.Write(context.Background(), "", "test_metric", now, frames, nil))
+		require.NoError(t, writer.Write(context.Background(), "mimir", "test_metric", now, frames, nil))
+		require.Equal(t, []string{"/default", "/mimir"}, requests)
+
+		require.ErrorContains(t, writer.Write(context.Background(), "unknown", "test_metric", now, frames, nil), `"unknown" is not configured`)
+	})
+
+	t.Run("default target is optional", func(t *testing.T) {
+		writer, err := NewTargetsWriter(setting.RecordingRuleSettings{
+			Targets: []setting.RecordingRuleTargetSettings{
+				{Name: "mimir", URL: server.URL + "/mimir", Timeout: time.Second},
+			},
+		}, nil, nil, log.NewNopLogger())
+		require.NoError(t, err)
+
+		require.ErrorContains(t, writer.Write(context.Background(), "", "test_metric", now, frames, nil), "no default recording rules target")
+	})
+}
@@ -0,0 +1,52 @@
+package writer
+
+import (
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// The sources of the timestamps of the series written to a target
+const (
+	// The time of the evaluation of the rule, the default
+	TimestampSourceEvaluation = "evaluation"
+	// The time of the frame of each series, or the time of the evaluation when it has none
+	TimestampSourceFrame = "frame"
+)
+
+// TimestampSources are the sources of the timestamps of the series written to a target
+var TimestampSources = []string{TimestampSourceEvaluation, TimestampSourceFrame}
+
+// frameTimes returns the times of the frames of the value fields of frames. The time of a frame is
+// the last one of its first time field, as a frame of numeric data has a single row. The fields
+// of the frames without a time are not in the map.
+func frameTimes(frames data.Frames) map[*data.Field]time.Time {
+	times := make(map[*data.Field]time.Time)
+	for _, frame := range frames {
+		t, ok := frameTime(frame)
+		if !ok {
+			continue
+		}
+		for _, field := range frame.Fields {
+			if field.Type().Numeric() {
+				times[field] = t
+			}
+		}
+	}
+	return times
+}
+
+func frameTime(frame *data.Frame) (time.Time, bool) {
+	for _, field := range frame.Fields {
+		if !field.Type().Time() {
+			continue
+		}
+		for i := field.Len() - 1; i >= 0; i-- {
+			if t, ok := field.ConcreteAt(i); ok && !t.(time.Time).IsZero() {
+				return t.(time.Time), true
+			}
+		}
+		return time.Time{}, false
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,119 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// verificationMetric is the name of the series of the sample write request encoded by VerifySettings
+const verificationMetric = "grafana_recording_rules_verification"
+
+// Verification is the result of VerifySettings.
+type Verification struct {
+	// The problems of the settings, the targets are not verified when there are any
+	Errors  []SettingError
+	Targets []TargetVerification
+}
+
+// Valid returns whether the settings are valid and all the targets could be written to.
+func (v Verification) Valid() bool {
+	if len(v.Errors) > 0 {
+		return false
+	}
+	for _, target := range v.Targets {
+		if target.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// TargetVerification is the result of the verification of a target.
+type TargetVerification struct {
+	// Empty for the default target
+	Name string
+	URL  string
+	// The size of the sample write request encoded for the target
+	EncodedBytes int
+	// Whether a test write was sent to the target
+	Written bool
+	// Why the test write failed, when it did
+	Failure ProbeFailure
+	Err     error
+}
+
+// VerifySettings validates settings and creates the clients of their targets, which decrypts their
+// credentials and parses their certificates, and encodes a sample write request for each of them.
+// When write is set, a test write is also sent to them, see Writer.Probe.
+func VerifySettings(ctx context.Context, settings setting.RecordingRuleSettings, decrypt DecryptFn, write bool, l log.Logger) Verification {
+	var v Verification
+
+	settings, err := ResolveSecrets(ctx, settings, decrypt)
+	if err != nil {
+		v.Errors = append(v.Errors, SettingError{Field: "secrets", Message: err.Error()})
+		return v
+	}
+	var settingsErr *SettingsError
+	if err := validateSettings(settings); errors.As(err, &settingsErr) {
+		v.Errors = settingsErr.Errors
+		return v
+	} else if err != nil {
+		v.Errors = append(v.Errors, SettingError{Message: err.Error()})
+		return v
+	}
+
+	sample, err := encodeSample(time.Now())
+	if err != nil {
+		v.Errors = append(v.Errors, SettingError{Message: err.Error()})
+		return v
+	}
+
+	targets := settings.Targets
+	if settings.URL != "" {
+		targets = append([]setting.RecordingRuleTargetSettings{settings.RecordingRuleTargetSettings}, targets...)
+	}
+	for _, target := range targets {
+		tv := TargetVerification{Name: target.Name, URL: target.URL}
+		w, err := DefaultRegistry.New(target, nil, nil, l)
+		if err != nil {
+			tv.Err = err
+			v.Targets = append(v.Targets, tv)
+			continue
+		}
+		tv.EncodedBytes = len(sample)
+
+		if write {
+			tv.Written = true
+			var probeErr *ProbeError
+			if err := w.Probe(ctx); errors.As(err, &probeErr) {
+				tv.Failure, tv.Err = probeErr.Failure, probeErr.Err
+			} else if err != nil {
+				tv.Err = err
+			}
+		}
+		v.Targets = append(v.Targets, tv)
+	}
+	return v
+}
+
+// encodeSample encodes a write request with a single sample, as the writer does
+func encodeSample(t time.Time) ([]byte, error) {
+	req := prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: verificationMetric}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: t.UnixMilli()}},
+		}},
+	}
+	b, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, b), nil
+}
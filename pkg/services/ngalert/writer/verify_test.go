@@ -0,0 +1,99 @@
+package writer
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestVerifySettings(t *testing.T) {
+	decrypt := func(_ context.Context, payload []byte) ([]byte, error) {
+		if string(payload) == "bad" {
+			return nil, errors.New("decryption failed")
+		}
+		return payload, nil
+	}
+
+	var written int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		written++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	settings := setting.RecordingRuleSettings{
+		RecordingRuleTargetSettings: setting.RecordingRuleTargetSettings{
+			URL:         server.URL,
+			BearerToken: "$__encrypted{" + base64.StdEncoding.EncodeToString([]byte("token")) + "}",
+			Timeout:     time.Second,
+		},
+		Targets: []setting.RecordingRuleTargetSettings{
+			{Name: "other", URL: server.URL, Timeout: time.Second},
+		},
+	}
+
+	t.Run("dry run encodes a sample for each target", func(t *testing.T) {
+		written = 0
+		v := VerifySettings(context.Background(), settings, decrypt, false, log.NewNopLogger())
+		require.True(t, v.Valid())
+		require.Empty(t, v.Errors)
+		require.Len(t, v.Targets, 2)
+		require.Equal(t, "", v.Targets[0].Name)
+		require.Equal(t, "other", v.Targets[1].Name)
+		for _, target := range v.Targets {
+			require.Positive(t, target.EncodedBytes)
+			require.False(t, target.Written)
+		}
+		require.Zero(t, written)
+	})
+
+	t.Run("test write reports the failing targets", func(t *testing.T) {
+		written = 0
+		v := VerifySettings(context.Background(), settings, decrypt, true, log.NewNopLogger())
+		require.False(t, v.Valid())
+		require.Equal(t, 2, written)
+		require.True(t, v.Targets[0].Written)
+		require.NoError(t, v.Targets[0].Err)
+		require.Equal(t, ProbeFailureAuth, v.Targets[1].Failure)
+		require.Error(t, v.Targets[1].Err)
+	})
+
+	t.Run("invalid settings are reported by field", func(t *testing.T) {
+		invalid := settings
+		invalid.Timeout = 0
+		v := VerifySettings(context.Background(), invalid, decrypt, true, log.NewNopLogger())
+		require.False(t, v.Valid())
+		require.Equal(t, []SettingError{{Field: "timeout", Message: "must be positive, got 0s"}}, v.Errors)
+		require.Empty(t, v.Targets)
+	})
+
+	t.Run("secrets that cannot be decrypted are reported", func(t *testing.T) {
+		invalid := settings
+		invalid.BearerToken = "$__encrypted{" + base64.StdEncoding.EncodeToString([]byte("bad")) + "}"
+		v := VerifySettings(context.Background(), invalid, decrypt, false, log.NewNopLogger())
+		require.False(t, v.Valid())
+		require.Equal(t, "secrets", v.Errors[0].Field)
+	})
+
+	t.Run("invalid certificates are reported for their target", func(t *testing.T) {
+		invalid := settings
+		invalid.TLSCACert = "not a certificate"
+		v := VerifySettings(context.Background(), invalid, decrypt, false, log.NewNopLogger())
+		require.False(t, v.Valid())
+		require.Error(t, v.Targets[0].Err)
+		require.NoError(t, v.Targets[1].Err)
+	})
+}
@@ -0,0 +1,237 @@
+// Package writertest provides an in-process remote write receiver to test the writers of
+// recording rules against, without running Prometheus or Mimir.
+package writertest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// Request is a remote write request received by a Receiver
+type Request struct {
+	Header http.Header
+	Series []prompb.TimeSeries
+}
+
+// Series is a time series received by a Receiver, with its labels as a map
+type Series struct {
+	Labels  map[string]string
+	Samples []prompb.Sample
+}
+
+// Receiver is a remote write server recording the requests it receives.
+// Requests that are not snappy compressed protobuf write requests fail the test.
+type Receiver struct {
+	t      testing.TB
+	server *httptest.Server
+
+	mtx      sync.Mutex
+	requests []Request
+	statuses []int
+	delay    time.Duration
+	// zero when the length of label values is not limited
+	maxLabelValueLength int
+}
+
+// NewReceiver starts a Receiver, closed when the test completes
+func NewReceiver(t testing.TB) *Receiver {
+	t.Helper()
+	r := &Receiver{t: t}
+	r.server = httptest.NewServer(http.HandlerFunc(r.handle))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *Receiver) handle(w http.ResponseWriter, req *http.Request) {
+	r.mtx.Lock()
+	delay, maxLabelValueLength := r.delay, r.maxLabelValueLength
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	r.mtx.Unlock()
+
+	compressed, err := io.ReadAll(req.Body)
+	if !assertNoError(r.t, err, w) {
+		return
+	}
+	// The request is canceled when the writer times out only once its body was read
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	if status >= 200 && status < 300 {
+		b, err := snappy.Decode(nil, compressed)
+		if !assertNoError(r.t, err, w) {
+			return
+		}
+		var wr prompb.WriteRequest
+		if !assertNoError(r.t, wr.Unmarshal(b), w) {
+			return
+		}
+
+		series, rejected := limitLabelValueLength(wr.Timeseries, maxLabelValueLength)
+		r.mtx.Lock()
+		r.requests = append(r.requests, Request{Header: req.Header.Clone(), Series: series})
+		r.mtx.Unlock()
+		if rejected != "" {
+			http.Error(w, rejected, http.StatusBadRequest)
+			return
+		}
+	}
+	w.WriteHeader(status)
+}
+
+// limitLabelValueLength returns series without the ones with label values longer than limit, and the
+// error Mimir rejects the first of them with
+func limitLabelValueLength(series []prompb.TimeSeries, limit int) ([]prompb.TimeSeries, string) {
+	if limit <= 0 {
+		return series, ""
+	}
+	kept := make([]prompb.TimeSeries, 0, len(series))
+	rejected := ""
+	for _, s := range series {
+		long := ""
+		for _, l := range s.Labels {
+			if len(l.Value) > limit {
+				long = l.Name
+				break
+			}
+		}
+		if long == "" {
+			kept = append(kept, s)
+		} else if rejected == "" {
+			rejected = fmt.Sprintf("received a series whose label value length exceeds the limit, label: '%s' (err-mimir-label-value-too-long)", long)
+		}
+	}
+	return kept, rejected
+}
+
+// assertNoError fails the test without stopping it, as it is called by the server, and responds
+// with an error so the writer fails too
+func assertNoError(t testing.TB, err error, w http.ResponseWriter) bool {
+	if err != nil {
+		t.Errorf("invalid remote write request: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// URL returns the URL of the remote write endpoint of the Receiver
+func (r *Receiver) URL() string {
+	return r.server.URL + "/api/v1/push"
+}
+
+// Settings returns the settings of a target writing to the Receiver
+func (r *Receiver) Settings() setting.RecordingRuleTargetSettings {
+	return setting.RecordingRuleTargetSettings{
+		URL:                 r.URL(),
+		Timeout:             5 * time.Second,
+		ConversionTimeout:   5 * time.Second,
+		MaxIdleConnsPerHost: 1,
+		IdleConnTimeout:     time.Minute,
+	}
+}
+
+// RespondWith makes the next requests fail with statuses, one for each of them.
+// Requests are recorded only when they are successful.
+func (r *Receiver) RespondWith(statuses ...int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.statuses = append(r.statuses, statuses...)
+}
+
+// LimitLabelValueLength makes the Receiver reject the series with label values longer than limit like
+// Mimir: the other series of the requests are recorded, and they fail with the error of the first
+// rejected series.
+func (r *Receiver) LimitLabelValueLength(limit int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.maxLabelValueLength = limit
+}
+
+// Delay makes the Receiver wait for d before responding to requests
+func (r *Receiver) Delay(d time.Duration) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.delay = d
+}
+
+// Requests returns the successful requests received so far
+func (r *Receiver) Requests() []Request {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+// Series returns all the series received so far, sorted by their labels
+func (r *Receiver) Series() []Series {
+	var series []Series
+	for _, req := range r.Requests() {
+		for _, ts := range req.Series {
+			labels := make(map[string]string, len(ts.Labels))
+			for _, l := range ts.Labels {
+				labels[l.Name] = l.Value
+			}
+			series = append(series, Series{Labels: labels, Samples: ts.Samples})
+		}
+	}
+	sort.SliceStable(series, func(i, j int) bool {
+		return labelsKey(series[i].Labels) < labelsKey(series[j].Labels)
+	})
+	return series
+}
+
+// Reset forgets the requests received so far
+func (r *Receiver) Reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.requests = nil
+}
+
+// RequireSeries requires the Receiver to have received exactly one sample for each of the series
+// with labels, and no other series
+func (r *Receiver) RequireSeries(t testing.TB, labels ...map[string]string) {
+	t.Helper()
+	series := r.Series()
+	require.Len(t, series, len(labels))
+
+	expected := append([]map[string]string(nil), labels...)
+	sort.SliceStable(expected, func(i, j int) bool {
+		return labelsKey(expected[i]) < labelsKey(expected[j])
+	})
+	for i, s := range series {
+		require.Equal(t, expected[i], s.Labels)
+		require.Len(t, s.Samples, 1)
+	}
+}
+
+func labelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key string
+	for _, name := range names {
+		key += name + "\xff" + labels[name] + "\xff"
+	}
+	return key
+}
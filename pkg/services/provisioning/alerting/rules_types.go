@@ -258,11 +258,30 @@ func (nsV1 *NotificationSettingsV1) mapToModel() (models.NotificationSettings, e
 type RecordV1 struct {
 	Metric values.StringValue `json:"metric" yaml:"metric"`
 	From   values.StringValue `json:"from" yaml:"from"`
+	Target values.StringValue `json:"target" yaml:"target"`
+	Filter *RecordFilterV1    `json:"filter" yaml:"filter"`
+}
+
+type RecordFilterV1 struct {
+	RefIDs   []values.StringValue `json:"refIds" yaml:"refIds"`
+	Fields   values.StringValue   `json:"fields" yaml:"fields"`
+	Matchers values.StringValue   `json:"matchers" yaml:"matchers"`
 }
 
 func (record *RecordV1) mapToModel() (models.Record, error) {
-	return models.Record{
+	r := models.Record{
 		Metric: record.Metric.Value(),
 		From:   record.From.Value(),
-	}, nil
+		Target: record.Target.Value(),
+	}
+	if record.Filter != nil {
+		r.Filter = &models.RecordFilter{
+			Fields:   record.Filter.Fields.Value(),
+			Matchers: record.Filter.Matchers.Value(),
+		}
+		for _, refID := range record.Filter.RefIDs {
+			r.Filter.RefIDs = append(r.Filter.RefIDs, refID.Value())
+		}
+	}
+	return r, nil
 }
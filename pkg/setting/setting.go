@@ -89,6 +89,8 @@ type Cfg struct {
 	configFiles                  []string
 	appliedCommandLineProperties []string
 	appliedEnvOverrides          []string
+	// keys whose values are redacted regardless of their name, by section
+	redactedKeys map[string]map[string]bool
 
 	// HTTP Server Settings
 	CertFile          string
@@ -563,6 +565,26 @@ func ToAbsUrl(relativeUrl string) string {
 	return AppUrl + relativeUrl
 }
 
+// redactKey marks the value of a key as sensitive, so it is redacted like the ones of sensitive
+// keys when settings are listed, when its name does not tell that it is
+func (cfg *Cfg) redactKey(section, key string) {
+	if cfg.redactedKeys == nil {
+		cfg.redactedKeys = make(map[string]map[string]bool)
+	}
+	if cfg.redactedKeys[section] == nil {
+		cfg.redactedKeys[section] = make(map[string]bool)
+	}
+	cfg.redactedKeys[section][key] = true
+}
+
+// RedactedKeyValue returns the value of a key of a section as it is listed, see RedactedValue.
+func (cfg *Cfg) RedactedKeyValue(section, key, value string) string {
+	if value != "" && cfg.redactedKeys[section][key] {
+		return RedactedPassword
+	}
+	return RedactedValue(EnvKey(section, key), value)
+}
+
 func RedactedValue(key, value string) string {
 	if value == "" {
 		return ""
@@ -583,6 +605,8 @@ func RedactedValue(key, value string) string {
 		"CLIENT_SECRET",
 		"ENTERPRISE_LICENSE",
 		"GF_ENTITY_API_DB_PASS",
+		"BEARER_TOKEN",
+		"TLS_CLIENT_KEY",
 	} {
 		if match, err := regexp.MatchString(pattern, uppercased); match && err == nil {
 			return RedactedPassword
@@ -522,6 +522,12 @@ func TestRedactedValue(t *testing.T) {
 			value:    "some_license_key_test",
 			expected: RedactedPassword,
 		},
+		{
+			desc:     "bearer token with non-empty value",
+			key:      "GF_RECORDING_RULES_BEARER_TOKEN",
+			value:    "token",
+			expected: RedactedPassword,
+		},
 		{
 			desc:     "sensitive key with empty value",
 			key:      "private_key_path",
@@ -2,6 +2,7 @@ package setting
 
 import (
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -59,10 +60,15 @@ const (
 	// with intervals that are not exactly divided by this number not to be evaluated
 	SchedulerBaseInterval = 10 * time.Second
 	// DefaultRuleEvaluationInterval indicates a default interval of for how long a rule should be evaluated to change state from Pending to Alerting
-	DefaultRuleEvaluationInterval  = SchedulerBaseInterval * 6 // == 60 seconds
-	stateHistoryDefaultEnabled     = true
-	lokiDefaultMaxQueryLength      = 721 * time.Hour // 30d1h, matches the default value in Loki
-	defaultRecordingRequestTimeout = 10 * time.Second
+	DefaultRuleEvaluationInterval     = SchedulerBaseInterval * 6 // == 60 seconds
+	stateHistoryDefaultEnabled        = true
+	lokiDefaultMaxQueryLength         = 721 * time.Hour // 30d1h, matches the default value in Loki
+	defaultRecordingRequestTimeout    = 10 * time.Second
+	defaultRecordingConversionTimeout = 10 * time.Second
+	// http.DefaultTransport keeps 2 idle connections per host, less than the concurrent writes of recording rules
+	defaultRecordingMaxIdleConnsPerHost = 10
+	defaultRecordingIdleConnTimeout     = 90 * time.Second
+	defaultRecordingDrainTimeout        = 10 * time.Second
 )
 
 type UnifiedAlertingSettings struct {
@@ -115,12 +121,95 @@ type UnifiedAlertingSettings struct {
 	NotificationLogRetention time.Duration
 }
 
+// RecordingRuleSettings are the settings of the targets the results of recording rules are written to:
+// the default one, from the [recording_rules] section, and the named ones that rules can reference,
+// from the [recording_rules.target.<name>] sections.
 type RecordingRuleSettings struct {
+	RecordingRuleTargetSettings
+	Targets []RecordingRuleTargetSettings
+	// Whether the targets are probed at startup, to log why they are not reachable
+	StartupProbe bool
+	// The maximum duration of the evaluation of the queries of a recording rule
+	EvaluationTimeout time.Duration
+	// How long the writes in flight when Grafana shuts down can complete, zero when they are aborted
+	DrainTimeout time.Duration
+	// How often the counters of the writes of recording rules are written to the default target,
+	// zero when they are not
+	SelfMonitoringInterval time.Duration
+	// Whether the states of the alerts of alert rules are written like the ALERTS series of
+	// Prometheus, and the named target they are written to, the default one when empty
+	WriteAlertStates  bool
+	AlertStatesTarget string
+}
+
+// RecordingRuleTargetSettings are the settings of a remote write target of recording rules.
+// The password, bearer token and TLS client key can be encrypted with the secrets service
+// of Grafana, written as $__encrypted{<base64>}. They are decrypted when the writer is created.
+type RecordingRuleTargetSettings struct {
+	// Empty for the default target
+	Name string
+	// The type of the writer of the target, prometheus when empty
+	Type              string
 	URL               string
 	BasicAuthUsername string
 	BasicAuthPassword string
-	CustomHeaders     map[string]string
+	BearerToken       string
+	TLSCACert         string
+	TLSClientCert     string
+	TLSClientKey      string
+	TLSSkipVerify     bool
+	// The type of the authentication of the write requests, inferred from the basic auth and bearer
+	// settings when empty, and the parameters of its provider, read from the auth subsection of the
+	// target. The values of the parameters can be encrypted too.
+	AuthType   string
+	AuthParams map[string]string
+	// The protocol of the write requests to a prometheus target, the remote write protocol 1.0 when
+	// empty, or auto to detect it
+	Protocol string
+	// The values of custom headers can be encrypted too
+	CustomHeaders map[string]string
+	// The names of the custom headers whose values are secret, the encrypted ones and the ones listed
+	// by secret_headers. Their values are redacted when settings are listed.
+	SecretHeaders []string
+	// The proxy of the write requests, instead of the one of the environment of Grafana when set,
+	// and the hosts that are not written to through it, like NO_PROXY
+	ProxyURL string
+	NoProxy  string
+	// The maximum duration of the conversion of the results of a rule to series, zero when it is
+	// not limited, and of their write, including all its requests
+	ConversionTimeout time.Duration
 	Timeout           time.Duration
+	// The maximum number of idle connections kept to the target, and how long they are kept
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// The maximum age of the samples written to the target, older ones are not written. Zero when
+	// their age is not limited.
+	MaxSampleAge time.Duration
+	// The maximum length of the names and values of the labels of the series written to the
+	// target, the series with longer ones are not written. Zero when their length is not limited.
+	MaxLabelNameLength  int
+	MaxLabelValueLength int
+	// Whether the series with an le label are validated as the buckets of classic histograms before
+	// they are written to prometheus targets, the ones of invalid histograms not being written
+	ValidateHistograms bool
+	// The source of the timestamps of the series written, the time of the evaluation of their rule
+	// when empty, or frame for the time of their frame when it has one, so the series of delayed
+	// data sources are stored at the time of their data
+	TimestampSource string
+	// The settings of graphite targets: the prefix of the names of the metrics, whether the labels
+	// are written as tags or appended to the names, tags when empty, the interval of the metrics,
+	// one minute when zero, and the rules rewriting the names of the metrics, applied in order
+	GraphitePrefix    string
+	GraphiteLabels    string
+	GraphiteInterval  time.Duration
+	GraphiteNameRules []GraphiteNameRule
+}
+
+// GraphiteNameRule replaces the matches of a regular expression in the names of the metrics written to
+// a graphite target, like regexp.ReplaceAllString
+type GraphiteNameRule struct {
+	Match       string
+	Replacement string
 }
 
 // RemoteAlertmanagerSettings contains the configuration needed
@@ -191,6 +280,79 @@ func (cfg *Cfg) readUnifiedAlertingEnabledSetting(section *ini.Section) (*bool,
 	return &unifiedAlerting, nil
 }
 
+// recordingRuleTargetSectionPrefix is the prefix of the sections of the named targets of recording rules
+const recordingRuleTargetSectionPrefix = "recording_rules.target."
+
+// readRecordingRuleTarget reads the settings of a target of recording rules from section, its
+// custom headers from section.custom_headers, its auth parameters from section.auth and its graphite
+// name rules from section.graphite_name_rules. The values of
+// the secret headers and of the encrypted auth parameters are redacted.
+// Named targets are only read from the configuration, there is no provisioning of them.
+func (cfg *Cfg) readRecordingRuleTarget(iniFile *ini.File, section, name string, defaultConversionTimeout, defaultTimeout time.Duration) RecordingRuleTargetSettings {
+	sec := iniFile.Section(section)
+	target := RecordingRuleTargetSettings{
+		Name:              name,
+		Type:              strings.ToLower(sec.Key("type").MustString("")),
+		Protocol:          strings.ToLower(sec.Key("protocol").MustString("")),
+		URL:               sec.Key("url").MustString(""),
+		BasicAuthUsername: sec.Key("basic_auth_username").MustString(""),
+		BasicAuthPassword: sec.Key("basic_auth_password").MustString(""),
+		BearerToken:       sec.Key("bearer_token").MustString(""),
+		AuthType:          strings.ToLower(sec.Key("auth_type").MustString("")),
+		TLSCACert:         sec.Key("tls_ca_cert").MustString(""),
+		TLSClientCert:     sec.Key("tls_client_cert").MustString(""),
+		TLSClientKey:      sec.Key("tls_client_key").MustString(""),
+		TLSSkipVerify:     sec.Key("tls_skip_verify").MustBool(false),
+		ProxyURL:          sec.Key("proxy_url").MustString(""),
+		NoProxy:           sec.Key("no_proxy").MustString(""),
+		ConversionTimeout: sec.Key("conversion_timeout").MustDuration(defaultConversionTimeout),
+		Timeout:           sec.Key("timeout").MustDuration(defaultTimeout),
+		// Not inherited by named targets, which are usually on other hosts
+		MaxIdleConnsPerHost: sec.Key("max_idle_conns_per_host").MustInt(defaultRecordingMaxIdleConnsPerHost),
+		IdleConnTimeout:     sec.Key("idle_conn_timeout").MustDuration(defaultRecordingIdleConnTimeout),
+		MaxSampleAge:        sec.Key("max_sample_age").MustDuration(0),
+		MaxLabelNameLength:  sec.Key("max_label_name_length").MustInt(0),
+		MaxLabelValueLength: sec.Key("max_label_value_length").MustInt(0),
+		ValidateHistograms:  sec.Key("validate_histograms").MustBool(false),
+		TimestampSource:     strings.ToLower(sec.Key("timestamp_source").MustString("")),
+		GraphitePrefix:      sec.Key("graphite_prefix").MustString(""),
+		GraphiteLabels:      strings.ToLower(sec.Key("graphite_labels").MustString("")),
+		GraphiteInterval:    sec.Key("graphite_interval").MustDuration(0),
+	}
+
+	secretHeaders := make(map[string]bool)
+	for _, header := range util.SplitString(sec.Key("secret_headers").MustString("")) {
+		secretHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+
+	headersSection := section + ".custom_headers"
+	headers := iniFile.Section(headersSection).Keys()
+	target.CustomHeaders = make(map[string]string, len(headers))
+	for _, key := range headers {
+		target.CustomHeaders[key.Name()] = key.Value()
+		if secretHeaders[http.CanonicalHeaderKey(key.Name())] || strings.HasPrefix(key.Value(), "$__encrypted{") {
+			target.SecretHeaders = append(target.SecretHeaders, key.Name())
+			cfg.redactKey(headersSection, key.Name())
+		}
+	}
+
+	// The keys are the regular expressions, in the order of the rules
+	for _, key := range iniFile.Section(section + ".graphite_name_rules").Keys() {
+		target.GraphiteNameRules = append(target.GraphiteNameRules, GraphiteNameRule{Match: key.Name(), Replacement: key.Value()})
+	}
+
+	authSection := section + ".auth"
+	params := iniFile.Section(authSection).Keys()
+	target.AuthParams = make(map[string]string, len(params))
+	for _, key := range params {
+		target.AuthParams[key.Name()] = key.Value()
+		if strings.HasPrefix(key.Value(), "$__encrypted{") {
+			cfg.redactKey(authSection, key.Name())
+		}
+	}
+	return target
+}
+
 // ReadUnifiedAlertingSettings reads both the `unified_alerting` and `alerting` sections of the configuration while preferring configuration the `alerting` section.
 // It first reads the `unified_alerting` section, then looks for non-defaults on the `alerting` section and prefers those.
 //
@@ -408,17 +570,21 @@ func (cfg *Cfg) ReadUnifiedAlertingSettings(iniFile *ini.File) error {
 
 	rr := iniFile.Section("recording_rules")
 	uaCfgRecordingRules := RecordingRuleSettings{
-		URL:               rr.Key("url").MustString(""),
-		BasicAuthUsername: rr.Key("basic_auth_username").MustString(""),
-		BasicAuthPassword: rr.Key("basic_auth_password").MustString(""),
-		Timeout:           rr.Key("timeout").MustDuration(defaultRecordingRequestTimeout),
+		RecordingRuleTargetSettings: cfg.readRecordingRuleTarget(iniFile, "recording_rules", "", defaultRecordingConversionTimeout, defaultRecordingRequestTimeout),
+		StartupProbe:                rr.Key("startup_probe").MustBool(false),
+		EvaluationTimeout:           rr.Key("evaluation_timeout").MustDuration(uaCfg.EvaluationTimeout),
+		DrainTimeout:                rr.Key("drain_timeout").MustDuration(defaultRecordingDrainTimeout),
+		SelfMonitoringInterval:      rr.Key("self_monitoring_interval").MustDuration(0),
+		WriteAlertStates:            rr.Key("write_alert_states").MustBool(false),
+		AlertStatesTarget:           rr.Key("alert_states_target").MustString(""),
 	}
-
-	rrHeaders := iniFile.Section("recording_rules.custom_headers")
-	rrHeadersKeys := rrHeaders.Keys()
-	uaCfgRecordingRules.CustomHeaders = make(map[string]string, len(rrHeadersKeys))
-	for _, key := range rrHeadersKeys {
-		uaCfgRecordingRules.CustomHeaders[key.Name()] = key.Value()
+	for _, section := range iniFile.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), recordingRuleTargetSectionPrefix)
+		if !ok || name == "" || strings.Contains(name, ".") {
+			continue
+		}
+		target := cfg.readRecordingRuleTarget(iniFile, section.Name(), name, uaCfgRecordingRules.ConversionTimeout, uaCfgRecordingRules.Timeout)
+		uaCfgRecordingRules.Targets = append(uaCfgRecordingRules.Targets, target)
 	}
 
 	uaCfg.RecordingRules = uaCfgRecordingRules
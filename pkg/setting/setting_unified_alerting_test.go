@@ -2,6 +2,8 @@ package setting
 
 import (
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -346,3 +348,197 @@ func TestHARedisTLSSettings(t *testing.T) {
 	require.Equal(t, cipherSuites, cfg.UnifiedAlerting.HARedisTLSConfig.CipherSuites)
 	require.Equal(t, minVersion, cfg.UnifiedAlerting.HARedisTLSConfig.MinVersion)
 }
+
+func TestRecordingRuleTargetSettings(t *testing.T) {
+	f, err := ini.Load([]byte(`
+[recording_rules]
+url = http://default/api/v1/write
+timeout = 20s
+conversion_timeout = 5s
+evaluation_timeout = 15s
+self_monitoring_interval = 1m
+write_alert_states = true
+alert_states_target = mimir
+
+[recording_rules.custom_headers]
+X-Default = default
+
+[recording_rules.target.mimir]
+url = http://mimir/api/v1/push
+basic_auth_username = user
+auth_type = OAuth2
+proxy_url = http://proxy:3128
+no_proxy = .internal
+max_idle_conns_per_host = 20
+idle_conn_timeout = 30s
+max_sample_age = 1h
+max_label_name_length = 1024
+max_label_value_length = 2048
+validate_histograms = true
+timestamp_source = Frame
+
+[recording_rules.target.mimir.custom_headers]
+X-Scope-OrgID = tenant
+
+[recording_rules.target.mimir.auth]
+client_id = grafana
+token_url = http://idp/token
+
+[recording_rules.target.other]
+type = Prometheus
+protocol = Auto
+url = http://other/api/v1/write
+timeout = 5s
+conversion_timeout = 1s
+
+[recording_rules.target.graphite]
+type = graphite
+url = http://graphite/graphite/metrics
+graphite_prefix = grafana
+graphite_labels = Path
+graphite_interval = 30s
+
+[recording_rules.target.graphite.graphite_name_rules]
+` + "`^(\\w+):(\\w+)$`" + ` = $1.$2
+_total$ =
+`))
+	require.NoError(t, err)
+
+	cfg := NewCfg()
+	require.NoError(t, cfg.ReadUnifiedAlertingSettings(f))
+
+	settings := cfg.UnifiedAlerting.RecordingRules
+	require.Equal(t, 15*time.Second, settings.EvaluationTimeout)
+	require.Equal(t, defaultRecordingDrainTimeout, settings.DrainTimeout)
+	require.Equal(t, time.Minute, settings.SelfMonitoringInterval)
+	require.True(t, settings.WriteAlertStates)
+	require.Equal(t, "mimir", settings.AlertStatesTarget)
+	require.Equal(t, RecordingRuleTargetSettings{
+		URL:                 "http://default/api/v1/write",
+		ConversionTimeout:   5 * time.Second,
+		Timeout:             20 * time.Second,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+		CustomHeaders:       map[string]string{"X-Default": "default"},
+		AuthParams:          map[string]string{},
+	}, settings.RecordingRuleTargetSettings)
+	require.Equal(t, []RecordingRuleTargetSettings{
+		{
+			Name:                "mimir",
+			URL:                 "http://mimir/api/v1/push",
+			BasicAuthUsername:   "user",
+			AuthType:            "oauth2",
+			AuthParams:          map[string]string{"client_id": "grafana", "token_url": "http://idp/token"},
+			ProxyURL:            "http://proxy:3128",
+			NoProxy:             ".internal",
+			ConversionTimeout:   5 * time.Second,
+			Timeout:             20 * time.Second,
+			MaxIdleConnsPerHost: 20,
+			IdleConnTimeout:     30 * time.Second,
+			MaxSampleAge:        time.Hour,
+			MaxLabelNameLength:  1024,
+			MaxLabelValueLength: 2048,
+			ValidateHistograms:  true,
+			TimestampSource:     "frame",
+			CustomHeaders:       map[string]string{"X-Scope-OrgID": "tenant"},
+		},
+		{
+			Name:                "other",
+			Type:                "prometheus",
+			Protocol:            "auto",
+			URL:                 "http://other/api/v1/write",
+			ConversionTimeout:   time.Second,
+			Timeout:             5 * time.Second,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			CustomHeaders:       map[string]string{},
+			AuthParams:          map[string]string{},
+		},
+		{
+			Name:                "graphite",
+			Type:                "graphite",
+			URL:                 "http://graphite/graphite/metrics",
+			ConversionTimeout:   5 * time.Second,
+			Timeout:             20 * time.Second,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			CustomHeaders:       map[string]string{},
+			AuthParams:          map[string]string{},
+			GraphitePrefix:      "grafana",
+			GraphiteLabels:      "path",
+			GraphiteInterval:    30 * time.Second,
+			GraphiteNameRules:   []GraphiteNameRule{{Match: `^(\w+):(\w+)$`, Replacement: "$1.$2"}, {Match: "_total$"}},
+		},
+	}, settings.Targets)
+}
+
+func TestRecordingRuleSecretHeaders(t *testing.T) {
+	f, err := ini.Load([]byte(`
+[recording_rules]
+url = http://default/api/v1/write
+secret_headers = x-api-key
+
+[recording_rules.custom_headers]
+X-Api-Key = key
+X-Scope-OrgID = tenant
+
+[recording_rules.target.mimir]
+url = http://mimir/api/v1/push
+
+[recording_rules.target.mimir.custom_headers]
+Authorization = $__encrypted{a2V5}
+
+[recording_rules.target.mimir.auth]
+client_id = grafana
+access_token = $__encrypted{a2V5}
+`))
+	require.NoError(t, err)
+
+	cfg := NewCfg()
+	require.NoError(t, cfg.ReadUnifiedAlertingSettings(f))
+
+	settings := cfg.UnifiedAlerting.RecordingRules
+	require.Equal(t, []string{"X-Api-Key"}, settings.SecretHeaders)
+	require.Equal(t, "key", settings.CustomHeaders["X-Api-Key"])
+	require.Equal(t, []string{"Authorization"}, settings.Targets[0].SecretHeaders)
+
+	require.Equal(t, RedactedPassword, cfg.RedactedKeyValue("recording_rules.custom_headers", "X-Api-Key", "key"))
+	require.Equal(t, "tenant", cfg.RedactedKeyValue("recording_rules.custom_headers", "X-Scope-OrgID", "tenant"))
+	require.Equal(t, RedactedPassword, cfg.RedactedKeyValue("recording_rules.target.mimir.custom_headers", "Authorization", "$__encrypted{a2V5}"))
+	require.Equal(t, RedactedPassword, cfg.RedactedKeyValue("recording_rules.target.mimir.auth", "access_token", "$__encrypted{a2V5}"))
+	require.Equal(t, "grafana", cfg.RedactedKeyValue("recording_rules.target.mimir.auth", "client_id", "grafana"))
+}
+
+func TestRecordingRuleSettingsExpansion(t *testing.T) {
+	t.Setenv("RECORDING_RULES_URL", "http://mimir/api/v1/push")
+	t.Setenv("RECORDING_RULES_TENANT", "tenant")
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0600))
+
+	f, err := ini.Load([]byte(`
+[recording_rules]
+url = ${RECORDING_RULES_URL}
+basic_auth_username = $__env{RECORDING_RULES_TENANT}
+basic_auth_password = $__file{` + passwordFile + `}
+
+[recording_rules.custom_headers]
+X-Scope-OrgID = $__env{RECORDING_RULES_TENANT}
+
+[recording_rules.target.other]
+url = http://other/api/v1/write
+bearer_token = $__file{` + passwordFile + `}
+`))
+	require.NoError(t, err)
+	require.NoError(t, expandConfig(f))
+
+	cfg := NewCfg()
+	require.NoError(t, cfg.ReadUnifiedAlertingSettings(f))
+
+	settings := cfg.UnifiedAlerting.RecordingRules
+	require.Equal(t, "http://mimir/api/v1/push", settings.URL)
+	require.Equal(t, "tenant", settings.BasicAuthUsername)
+	require.Equal(t, "secret", settings.BasicAuthPassword)
+	require.Equal(t, map[string]string{"X-Scope-OrgID": "tenant"}, settings.CustomHeaders)
+	require.Len(t, settings.Targets, 1)
+	require.Equal(t, "secret", settings.Targets[0].BearerToken)
+}